@@ -0,0 +1,202 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/chainguard-dev/apkregress/internal"
+	"github.com/spf13/cobra"
+)
+
+var (
+	bumpsChanged string
+	bumpsPR      int
+	bumpsComment bool
+)
+
+var bumpsCmd = &cobra.Command{
+	Use:   "bumps",
+	Short: "Test the reverse dependencies of the packages bumped by wolfictl or an update PR",
+	Long: `Read the melange configs bumped by wolfictl bump or an automated update PR
+and test the reverse dependencies of every package they produce against --repo
+in a single run, then report the outcome of each bump.
+
+--changed names a file listing the changed configs, one per line, as paths
+relative to --repo-path or package names ("-" reads standard input). --pr
+takes the changed configs of a GitHub pull request against --repo-path,
+read at its head with the gh CLI, and --comment posts the per-bump results
+on it. The results are also written to bumps.json in the log directory.`,
+	Example: `  git diff --name-only main | apkregress bumps --changed - --repo-path wolfi-os \
+    --repo https://packages.wolfi.dev/os
+  apkregress bumps --pr 31337 --comment --repo-path wolfi-os --repo https://packages.wolfi.dev/os`,
+	Args: cobra.NoArgs,
+	RunE: runBumps,
+}
+
+func init() {
+	bumpsCmd.Flags().StringVar(&bumpsChanged, "changed", "", "File listing the bumped configs or packages, one per line (- for stdin)")
+	bumpsCmd.Flags().IntVar(&bumpsPR, "pr", 0, "Number of a GitHub pull request whose changed configs to test")
+	bumpsCmd.Flags().BoolVar(&bumpsComment, "comment", false, "Comment the per-bump results on the pull request given with --pr")
+	bumpsCmd.Flags().StringVar(&bodyOverflow, "overflow", "gist", "Where --comment puts results that don't fit into the comment: gist, log-dir (linked via --log-url) or none to cut them")
+
+	rootCmd.AddCommand(bumpsCmd)
+}
+
+func runBumps(cmd *cobra.Command, args []string) error {
+	if (bumpsChanged == "") == (bumpsPR == 0) {
+		return fmt.Errorf("exactly one of --changed and --pr is required")
+	}
+	if bumpsComment && bumpsPR == 0 {
+		return fmt.Errorf("--comment requires --pr")
+	}
+	if apkRepo == "" {
+		return fmt.Errorf("--repo is required")
+	}
+	if repoPath == "" {
+		return fmt.Errorf("--repo-path is required")
+	}
+	if packageName != "" || packageFile != "" || apkoConfigDir != "" {
+		return fmt.Errorf("bumps selects packages itself and can't be combined with --package, --package-file or --apko-configs")
+	}
+
+	repoPaths, err := resolveRepoPaths(repoPath)
+	if err != nil {
+		return err
+	}
+	if len(repoPaths) > 1 {
+		return fmt.Errorf("bumps supports a single --repo-path")
+	}
+	repoPaths, cleanup, err := isolateRepoPaths(repoPaths)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	stopCleanup := cleanupOnSignal(cleanup)
+	defer stopCleanup()
+	path := repoPaths[0]
+
+	if err := internal.ValidateRepoType(repoType); err != nil {
+		return err
+	}
+	if err := internal.CheckRepoArch(apkRepo); err != nil {
+		return err
+	}
+	if sbomMode != "restrict" && sbomMode != "prioritize" {
+		return fmt.Errorf("invalid SBOM mode: %s (must be restrict or prioritize)", sbomMode)
+	}
+	if err := checkPlatform(); err != nil {
+		return err
+	}
+	if err := configureNetwork(); err != nil {
+		return err
+	}
+
+	locator, err := internal.NewConfigLocator(path, yamlLayout)
+	if err != nil {
+		return err
+	}
+
+	var entries []string
+	var ref, source string
+	if bumpsPR != 0 {
+		entries, ref, err = internal.PullRequestConfigs(path, bumpsPR)
+		source = fmt.Sprintf("PR #%d", bumpsPR)
+	} else {
+		entries, err = readChangedList(bumpsChanged)
+		source = bumpsChanged
+	}
+	if err != nil {
+		return err
+	}
+
+	bumps, err := internal.LoadBumps(path, entries, ref, locator)
+	if err != nil {
+		return err
+	}
+	if len(bumps) == 0 {
+		fmt.Printf("No melange configs bumped in %s\n", source)
+		return nil
+	}
+
+	fmt.Printf("%d melange configs bumped in %s:\n", len(bumps), source)
+	for _, bump := range bumps {
+		fmt.Printf("  - %s %s (%s)\n", bump.Name(), bump.Version, strings.Join(bump.Packages, ", "))
+	}
+
+	if err := checkCandidateRepo(""); err != nil {
+		return err
+	}
+
+	label := "bumps"
+	if bumpsPR != 0 {
+		label = fmt.Sprintf("pr-%d", bumpsPR)
+	}
+	runner := internal.NewRegressionTestRunner(label, apkRepo, path, repoType, concurrency, verbose, hangTimeout, markdownOutput)
+	if err := configureRunner(runner); err != nil {
+		return err
+	}
+	runner.SetConfigLocator(locator)
+
+	sbomPackages, err := loadSBOMPackages(sbomFiles, sbomImages)
+	if err != nil {
+		return err
+	}
+	if len(sbomPackages) > 0 {
+		runner.SetSBOMPackages(sbomPackages, sbomMode == "restrict")
+	}
+
+	overflow, err := newBodyOverflow(runner.LogDirOverflow())
+	if err != nil {
+		return err
+	}
+
+	stop, err := controlRun(runner)
+	if err != nil {
+		return err
+	}
+	defer stop()
+
+	results, runErr := runner.RunBumps(bumps)
+	if bumpsComment && results != nil {
+		body := fmt.Sprintf("## apkregress\n\nReverse dependencies tested against %s.\n\n%s", apkRepo, internal.BumpResultsMarkdown(results))
+		body = internal.FitGitHubBody(body, fmt.Sprintf("pr-%d-bumps", bumpsPR), overflow)
+		if err := internal.CommentOnPullRequest(path, bumpsPR, body); err != nil {
+			fmt.Printf("Warning: %v\n", err)
+		}
+	}
+	return runErr
+}
+
+// readChangedList reads the non-empty lines of path, or of standard input
+// for "-". Lines starting with # are comments.
+func readChangedList(path string) ([]string, error) {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read changed configs: %w", err)
+		}
+		defer f.Close()
+		r = f
+	}
+
+	var entries []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		entries = append(entries, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read changed configs: %w", err)
+	}
+	return entries, nil
+}
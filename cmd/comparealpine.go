@@ -0,0 +1,87 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/chainguard-dev/apkregress/internal"
+	"github.com/spf13/cobra"
+)
+
+var (
+	alpineBranch       string
+	alpineRepositories []string
+	compareAlpineJSON  bool
+)
+
+var compareAlpineCmd = &cobra.Command{
+	Use:   "compare-alpine",
+	Short: "Compare the reverse dependencies of a package with those in Alpine",
+	Long: `Find the reverse dependencies of --package in the --repo-type index and in
+upstream Alpine's APKINDEX, and list those in both and those in only one, to
+estimate the blast radius of a change in Alpine as well. Versioned Wolfi
+packages are looked up by their Alpine name, e.g. py3.12-requests as
+py3-requests.
+
+Nothing is tested: Alpine packages are built from APKBUILDs in aports rather
+than melange configs.`,
+	Example: `  apkregress compare-alpine --package openssl
+  apkregress compare-alpine --package openssl --alpine-branch v3.21 --json`,
+	Args: cobra.NoArgs,
+	RunE: runCompareAlpine,
+}
+
+func init() {
+	compareAlpineCmd.Flags().StringVar(&alpineBranch, "alpine-branch", "edge", "Alpine branch to compare with, e.g. edge or v3.21")
+	compareAlpineCmd.Flags().StringSliceVar(&alpineRepositories, "alpine-repos", internal.DefaultAlpineRepositories, "Alpine repositories to search, e.g. main,community,testing")
+	compareAlpineCmd.Flags().BoolVar(&compareAlpineJSON, "json", false, "Print the comparison as JSON")
+
+	rootCmd.AddCommand(compareAlpineCmd)
+}
+
+func runCompareAlpine(cmd *cobra.Command, args []string) error {
+	if packageName == "" {
+		return fmt.Errorf("--package is required")
+	}
+	if err := internal.ValidateRepoType(repoType); err != nil {
+		return err
+	}
+	if err := configureNetwork(); err != nil {
+		return err
+	}
+
+	client := internal.NewApkraneClient(verbose, repoType)
+	if !noIndexCache {
+		cache, err := internal.NewIndexCache(cacheDir)
+		if err != nil {
+			return err
+		}
+		client.SetIndexCache(cache)
+	}
+	alpine := internal.NewApkraneClient(verbose, internal.RepoTypeAlpine)
+	alpine.SetAlpineBranch(alpineBranch, alpineRepositories)
+	if verifyIndex || len(indexKeys) > 0 {
+		client.SetIndexVerification(indexKeys)
+		alpine.SetIndexVerification(indexKeys)
+	}
+
+	if err := client.ValidatePackage(packageName); err != nil {
+		return err
+	}
+	comparison, err := internal.CompareAlpineConsumers(packageName, client, alpine)
+	if err != nil {
+		return err
+	}
+
+	if compareAlpineJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(comparison)
+	}
+	comparison.WriteText(os.Stdout)
+	return nil
+}
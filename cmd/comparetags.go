@@ -0,0 +1,130 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/chainguard-dev/apkregress/internal"
+	"github.com/spf13/cobra"
+)
+
+var (
+	compareFrom string
+	compareTo   string
+)
+
+var compareTagsCmd = &cobra.Command{
+	Use:   "compare-tags",
+	Short: "Test the reverse dependencies of every package changed between two git refs",
+	Long: `Find the melange configs in --repo-path that were added or modified between
+two git refs, e.g. the tags of a weekly rebuild wave, and test the reverse
+dependencies of every package they produce against --repo in a single run.`,
+	Example: `  apkregress compare-tags --repo-path wolfi-os --from 2025-01-06 --to 2025-01-13 \
+    --repo https://packages.wolfi.dev/os`,
+	RunE: runCompareTags,
+}
+
+func init() {
+	compareTagsCmd.Flags().StringVar(&compareFrom, "from", "", "Git ref of the previous release (required)")
+	compareTagsCmd.Flags().StringVar(&compareTo, "to", "", "Git ref of the release to validate (required)")
+	compareTagsCmd.MarkFlagRequired("from")
+	compareTagsCmd.MarkFlagRequired("to")
+
+	rootCmd.AddCommand(compareTagsCmd)
+}
+
+func runCompareTags(cmd *cobra.Command, args []string) error {
+	if apkRepo == "" {
+		return fmt.Errorf("--repo is required")
+	}
+	if repoPath == "" {
+		return fmt.Errorf("--repo-path is required")
+	}
+	if packageName != "" || packageFile != "" || apkoConfigDir != "" {
+		return fmt.Errorf("compare-tags selects packages itself and can't be combined with --package, --package-file or --apko-configs")
+	}
+
+	repoPaths, err := resolveRepoPaths(repoPath)
+	if err != nil {
+		return err
+	}
+	if len(repoPaths) > 1 {
+		return fmt.Errorf("compare-tags supports a single --repo-path")
+	}
+	repoPaths, cleanup, err := isolateRepoPaths(repoPaths)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	stopCleanup := cleanupOnSignal(cleanup)
+	defer stopCleanup()
+	path := repoPaths[0]
+
+	if err := internal.ValidateRepoType(repoType); err != nil {
+		return err
+	}
+	if err := internal.CheckRepoArch(apkRepo); err != nil {
+		return err
+	}
+	if sbomMode != "restrict" && sbomMode != "prioritize" {
+		return fmt.Errorf("invalid SBOM mode: %s (must be restrict or prioritize)", sbomMode)
+	}
+	if err := checkPlatform(); err != nil {
+		return err
+	}
+	if err := configureNetwork(); err != nil {
+		return err
+	}
+
+	changed, err := internal.ChangedConfigs(path, compareFrom, compareTo)
+	if err != nil {
+		return err
+	}
+	if len(changed) == 0 {
+		fmt.Printf("No melange configs changed between %s and %s\n", compareFrom, compareTo)
+		return nil
+	}
+
+	var targets []string
+	fmt.Printf("%d melange configs changed between %s and %s:\n", len(changed), compareFrom, compareTo)
+	for _, config := range changed {
+		fmt.Printf("  - %s (%s)\n", config.Path, strings.Join(config.Packages, ", "))
+		targets = append(targets, config.Packages...)
+	}
+
+	if err := checkCandidateRepo(""); err != nil {
+		return err
+	}
+
+	locator, err := internal.NewConfigLocator(path, yamlLayout)
+	if err != nil {
+		return err
+	}
+
+	// The label names the log directory and the summary; refs may contain
+	// slashes
+	label := strings.ReplaceAll(fmt.Sprintf("%s..%s", compareFrom, compareTo), "/", "_")
+	runner := internal.NewRegressionTestRunner(label, apkRepo, path, repoType, concurrency, verbose, hangTimeout, markdownOutput)
+	if err := configureRunner(runner); err != nil {
+		return err
+	}
+	runner.SetConfigLocator(locator)
+
+	sbomPackages, err := loadSBOMPackages(sbomFiles, sbomImages)
+	if err != nil {
+		return err
+	}
+	if len(sbomPackages) > 0 {
+		runner.SetSBOMPackages(sbomPackages, sbomMode == "restrict")
+	}
+
+	stop, err := controlRun(runner)
+	if err != nil {
+		return err
+	}
+	defer stop()
+	return runner.RunReverseDependencies(targets)
+}
@@ -0,0 +1,69 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package cmd
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/chainguard-dev/apkregress/internal"
+	"github.com/spf13/cobra"
+)
+
+// registerFlagCompletions completes the values of flags the shells can't
+// complete from files. The completion scripts themselves come from cobra's
+// completion subcommand.
+func registerFlagCompletions() {
+	rootCmd.RegisterFlagCompletionFunc("package", completePackages)
+	rootCmd.RegisterFlagCompletionFunc("repo-type", completeRepoTypes)
+	rootCmd.RegisterFlagCompletionFunc("dep-kind", cobra.FixedCompletions([]string{internal.DepKindRuntime, internal.DepKindBuild, internal.DepKindAll}, cobra.ShellCompDirectiveNoFileComp))
+}
+
+// completeRepoTypes completes --repo-type with the built-in repository types
+// and those of the config file
+func completeRepoTypes(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	// Completion doesn't run applyPresets; an invalid config file leaves
+	// the built-in types
+	loadRepoTypes()
+	return internal.RepoTypeNames(), cobra.ShellCompDirectiveNoFileComp
+}
+
+// completePackages completes --package with the packages that have a config
+// in --repo-path, or in the current directory without one. The last entry of
+// a comma-separated list is completed.
+func completePackages(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	listed := ""
+	if i := strings.LastIndex(toComplete, ","); i >= 0 {
+		listed, toComplete = toComplete[:i+1], toComplete[i+1:]
+	}
+
+	paths := []string{"."}
+	if repoPath != "" {
+		paths = strings.Split(repoPath, ",")
+	}
+
+	seen := make(map[string]bool)
+	for _, path := range paths {
+		locator, err := internal.NewConfigLocator(path, yamlLayout)
+		if err != nil {
+			continue
+		}
+		packages, err := locator.Packages()
+		if err != nil {
+			continue
+		}
+		for _, pkg := range packages {
+			if strings.HasPrefix(pkg, toComplete) {
+				seen[listed+pkg] = true
+			}
+		}
+	}
+
+	completions := make([]string, 0, len(seen))
+	for pkg := range seen {
+		completions = append(completions, pkg)
+	}
+	sort.Strings(completions)
+	return completions, cobra.ShellCompDirectiveNoFileComp
+}
@@ -0,0 +1,78 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+func TestCompletePackages(t *testing.T) {
+	wolfi, enterprise := t.TempDir(), t.TempDir()
+	for dir, names := range map[string][]string{wolfi: {"openssl", "openssh", "curl"}, enterprise: {"openssl", "openjdk-21"}} {
+		for _, name := range names {
+			if err := os.WriteFile(filepath.Join(dir, name+".yaml"), []byte("package:\n  name: "+name+"\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	defer func(path, layout string) { repoPath, yamlLayout = path, layout }(repoPath, yamlLayout)
+	repoPath, yamlLayout = wolfi+","+enterprise, "flat"
+
+	completions, directive := completePackages(rootCmd, nil, "open")
+	expected := []string{"openjdk-21", "openssh", "openssl"}
+	if !reflect.DeepEqual(completions, expected) {
+		t.Errorf("Expected %v, got %v", expected, completions)
+	}
+	if directive != cobra.ShellCompDirectiveNoFileComp {
+		t.Errorf("Expected file completion to be disabled, got %v", directive)
+	}
+}
+
+func TestGendocs(t *testing.T) {
+	defer func(format, dir string) { gendocsFormat, gendocsDir = format, dir }(gendocsFormat, gendocsDir)
+
+	for _, tt := range []struct {
+		format string
+		file   string
+	}{
+		{"man", "apkregress.1"},
+		{"markdown", "apkregress.md"},
+	} {
+		gendocsFormat, gendocsDir = tt.format, t.TempDir()
+		if err := runGendocs(gendocsCmd, nil); err != nil {
+			t.Fatalf("Expected no error for %s, got %v", tt.format, err)
+		}
+		if _, err := os.Stat(filepath.Join(gendocsDir, tt.file)); err != nil {
+			t.Errorf("Expected %s to be generated: %v", tt.file, err)
+		}
+	}
+
+	gendocsFormat = "html"
+	if err := runGendocs(gendocsCmd, nil); err == nil {
+		t.Error("Expected an error for an unknown format")
+	}
+}
+
+func TestCompletePackagesList(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"openssl", "zlib"} {
+		if err := os.WriteFile(filepath.Join(dir, name+".yaml"), []byte("package:\n  name: "+name+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	defer func(path, layout string) { repoPath, yamlLayout = path, layout }(repoPath, yamlLayout)
+	repoPath, yamlLayout = dir, "flat"
+
+	completions, _ := completePackages(rootCmd, nil, "openssl,z")
+	if !reflect.DeepEqual(completions, []string{"openssl,zlib"}) {
+		t.Errorf("Expected the last entry of the list to be completed, got %v", completions)
+	}
+}
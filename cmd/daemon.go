@@ -0,0 +1,157 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/chainguard-dev/apkregress/internal"
+	"github.com/spf13/cobra"
+)
+
+var (
+	queueDir      string
+	daemonWorkers int
+	daemonListen  string
+	daemonToken   string
+	workRoot      string
+)
+
+var daemonCmd = &cobra.Command{
+	Use:   "daemon",
+	Short: "Run queued regression jobs as a shared service",
+	Long: `Run regression jobs from a persistent queue, one apkregress process per job.
+Jobs are submitted with "apkregress submit" or the HTTP API (--listen):
+
+  POST /jobs           submit {"args": [...], "workDir": "..."}
+  GET  /jobs           list jobs
+  GET  /jobs/<id>      show a job
+  GET  /jobs/<id>/log  the job's output
+  GET  /healthz        200 while the daemon runs
+  GET  /readyz         200 while it processes jobs, 503 while starting or stopping
+
+Requests to /jobs must carry "Authorization: Bearer <token>" with the token
+of --token or $APKREGRESS_DAEMON_TOKEN, and jobs submitted over HTTP must run
+within --work-root.
+
+--health-listen serves /healthz and /readyz alone, e.g. on a port only the
+orchestrator reaches. On SIGTERM the daemon stops taking jobs and asks the
+running ones to stop, killing them after 20 seconds. Jobs interrupted by a
+restart are run again.`,
+	RunE: runDaemon,
+}
+
+var submitCmd = &cobra.Command{
+	Use:   "submit -- [apkregress flags]",
+	Short: "Queue a regression job for the daemon",
+	Long: `Queue a regression job for "apkregress daemon". The arguments after -- are
+passed to apkregress when the job runs, in the current directory.`,
+	Example: `  apkregress submit -- --package openssl --repo ./packages --repo-path .`,
+	RunE:    runSubmit,
+}
+
+func init() {
+	for _, c := range []*cobra.Command{daemonCmd, submitCmd} {
+		c.Flags().StringVar(&queueDir, "queue-dir", "", "Directory of the job queue (default: queue/ in the user cache directory)")
+	}
+	daemonCmd.Flags().IntVar(&daemonWorkers, "workers", 1, "Number of jobs to run at once")
+	daemonCmd.Flags().StringVar(&daemonListen, "listen", "", "Address to serve the HTTP API on, e.g. 127.0.0.1:8080")
+	daemonCmd.Flags().StringVar(&daemonToken, "token", "", "Bearer token required by the HTTP API (default: $"+internal.DaemonTokenEnv+")")
+	daemonCmd.Flags().StringVar(&workRoot, "work-root", "", "Directory that jobs submitted over the HTTP API must run within (default: the current directory)")
+
+	rootCmd.AddCommand(daemonCmd, submitCmd)
+}
+
+func runDaemon(cmd *cobra.Command, args []string) error {
+	queue, err := internal.NewJobQueue(queueDir)
+	if err != nil {
+		return err
+	}
+
+	daemon, err := internal.NewDaemon(queue, daemonWorkers, verbose)
+	if err != nil {
+		return err
+	}
+
+	if daemonListen != "" {
+		token := daemonToken
+		if token == "" {
+			token = os.Getenv(internal.DaemonTokenEnv)
+		}
+		if token == "" {
+			return fmt.Errorf("--listen requires a token, given with --token or $%s", internal.DaemonTokenEnv)
+		}
+		daemon.SetToken(token)
+
+		root := workRoot
+		if root == "" {
+			root = "."
+		}
+		if err := daemon.SetWorkRoot(root); err != nil {
+			return err
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if daemonListen != "" {
+		server := &http.Server{Addr: daemonListen, Handler: daemon.Handler()}
+		go func() {
+			if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				fmt.Fprintf(os.Stderr, "Error: API server failed: %v\n", err)
+				stop()
+			}
+		}()
+		defer func() {
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			server.Shutdown(shutdownCtx)
+		}()
+		fmt.Printf("Serving API on %s\n", daemonListen)
+	}
+	if healthListen != "" {
+		stopHealth, err := internal.ServeHealth(healthListen, daemon.Health())
+		if err != nil {
+			return err
+		}
+		defer stopHealth()
+		fmt.Printf("Serving health checks on %s\n", healthListen)
+	}
+
+	fmt.Printf("Processing jobs with %d workers\n", daemonWorkers)
+	return daemon.Run(ctx)
+}
+
+func runSubmit(cmd *cobra.Command, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("no apkregress arguments given, e.g. apkregress submit -- --package openssl ...")
+	}
+
+	queue, err := internal.NewJobQueue(queueDir)
+	if err != nil {
+		return err
+	}
+
+	workDir, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("failed to determine working directory: %w", err)
+	}
+
+	job, err := queue.Submit(args, workDir)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Submitted job %s\n", job.ID)
+	fmt.Printf("Output will be written to %s\n", job.LogPath)
+	return nil
+}
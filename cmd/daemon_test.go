@@ -0,0 +1,54 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package cmd
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/chainguard-dev/apkregress/internal"
+)
+
+func TestRunSubmit(t *testing.T) {
+	origQueueDir := queueDir
+	defer func() { queueDir = origQueueDir }()
+
+	queueDir = t.TempDir()
+
+	if err := runSubmit(nil, nil); err == nil {
+		t.Error("Expected error when submitting without arguments")
+	}
+
+	args := []string{"--package", "openssl", "--repo", "./packages", "--repo-path", "."}
+	if err := runSubmit(nil, args); err != nil {
+		t.Fatalf("Failed to submit job: %v", err)
+	}
+
+	queue, err := internal.NewJobQueue(queueDir)
+	if err != nil {
+		t.Fatalf("Failed to open queue: %v", err)
+	}
+	jobs, err := queue.Jobs()
+	if err != nil {
+		t.Fatalf("Failed to list jobs: %v", err)
+	}
+	if len(jobs) != 1 {
+		t.Fatalf("Expected 1 job, got %d", len(jobs))
+	}
+
+	wd, _ := os.Getwd()
+	if !reflect.DeepEqual(jobs[0].Args, args) || jobs[0].WorkDir != wd || jobs[0].Status != internal.JobQueued {
+		t.Errorf("Unexpected job: %+v", jobs[0])
+	}
+}
+
+func TestSubcommands(t *testing.T) {
+	for _, name := range []string{"daemon", "submit", "compare-tags", "bumps", "trends", "report", "reproduce", "triage", "version", "update", "gendocs"} {
+		cmd, _, err := rootCmd.Find([]string{name})
+		if err != nil || cmd.Name() != name {
+			t.Errorf("Expected %s subcommand, got %v (%v)", name, cmd, err)
+		}
+	}
+}
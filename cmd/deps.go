@@ -0,0 +1,76 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/chainguard-dev/apkregress/internal"
+	"github.com/spf13/cobra"
+)
+
+var depsJSON bool
+
+var depsCmd = &cobra.Command{
+	Use:   "deps <package>",
+	Short: "List the dependencies of a package that the candidate repository changes",
+	Long: `List the dependencies of a package in the --repo-type index that the candidate
+repository --repo provides at another version, the inverse of finding the
+consumers of a changed package, to help explain why a consumer regressed.
+Dependencies are matched with the candidate packages by name or by what they
+provide, e.g. so:libssl.so.3. A package that isn't in the index is looked up
+as an origin, with the dependencies of all its packages.`,
+	Example: `  apkregress deps curl --repo ./packages
+  apkregress deps curl --repo https://apk.cgr.dev/chainguard-private --repo-type enterprise --json`,
+	Args: cobra.ExactArgs(1),
+	RunE: runDeps,
+}
+
+func init() {
+	depsCmd.Flags().BoolVar(&depsJSON, "json", false, "Print the changed dependencies as JSON")
+
+	rootCmd.AddCommand(depsCmd)
+}
+
+func runDeps(cmd *cobra.Command, args []string) error {
+	if apkRepo == "" {
+		return fmt.Errorf("--repo is required")
+	}
+	if err := internal.ValidateRepoType(repoType); err != nil {
+		return err
+	}
+	if err := internal.CheckRepoArch(apkRepo); err != nil {
+		return err
+	}
+	if err := configureNetwork(); err != nil {
+		return err
+	}
+
+	client := internal.NewApkraneClient(verbose, repoType)
+	if !noIndexCache {
+		cache, err := internal.NewIndexCache(cacheDir)
+		if err != nil {
+			return err
+		}
+		client.SetIndexCache(cache)
+	}
+	if verifyIndex || len(indexKeys) > 0 {
+		client.SetIndexVerification(indexKeys)
+	}
+
+	changes, err := internal.FindChangedDependencies(args[0], client, apkRepo)
+	if err != nil {
+		return err
+	}
+
+	if depsJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(changes)
+	}
+	changes.WriteText(os.Stdout)
+	return nil
+}
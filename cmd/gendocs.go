@@ -0,0 +1,59 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/cobra/doc"
+)
+
+var (
+	gendocsFormat string
+	gendocsDir    string
+)
+
+var gendocsCmd = &cobra.Command{
+	Use:   "gendocs",
+	Short: "Generate man pages or markdown documentation for all commands",
+	Example: `  apkregress gendocs --format man --dir /usr/local/share/man/man1
+  apkregress gendocs --format markdown --dir docs`,
+	Args: cobra.NoArgs,
+	RunE: runGendocs,
+}
+
+func init() {
+	gendocsCmd.Flags().StringVar(&gendocsFormat, "format", "man", "Documentation format: man or markdown")
+	gendocsCmd.Flags().StringVar(&gendocsDir, "dir", "docs", "Directory to write the documentation to")
+	gendocsCmd.RegisterFlagCompletionFunc("format", cobra.FixedCompletions([]string{"man", "markdown"}, cobra.ShellCompDirectiveNoFileComp))
+
+	rootCmd.AddCommand(gendocsCmd)
+}
+
+func runGendocs(cmd *cobra.Command, args []string) error {
+	if err := os.MkdirAll(gendocsDir, 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", gendocsDir, err)
+	}
+
+	// Dates would make the generated files differ on every run
+	root := cmd.Root()
+	root.DisableAutoGenTag = true
+
+	var err error
+	switch gendocsFormat {
+	case "man":
+		err = doc.GenManTree(root, &doc.GenManHeader{Title: "APKREGRESS", Section: "1"}, gendocsDir)
+	case "markdown":
+		err = doc.GenMarkdownTree(root, gendocsDir)
+	default:
+		return fmt.Errorf("invalid format: %s (must be man or markdown)", gendocsFormat)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to generate documentation: %w", err)
+	}
+	fmt.Printf("Wrote %s documentation to %s\n", gendocsFormat, gendocsDir)
+	return nil
+}
@@ -0,0 +1,90 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/chainguard-dev/apkregress/internal"
+	"github.com/spf13/cobra"
+)
+
+var (
+	reportFormat string
+	reportOutput string
+	reportLimit  int
+)
+
+var reportCmd = &cobra.Command{
+	Use:   "report [run-id]",
+	Short: "Render a run stored in the results database",
+	Long: `Render the outcome of a run recorded in the results database (--results-db)
+as markdown, HTML or JSON, also once its log directory has been pruned. The
+run is given by its ID, the name of its log directory, or the log directory
+itself. Without a run, the latest runs are listed.`,
+	Example: `  apkregress report
+  apkregress report regression-test-openssl-20250106-120000
+  apkregress report regression-test-openssl-20250106-120000 --format html -o report.html`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runReport,
+}
+
+func init() {
+	reportCmd.Flags().StringVar(&reportFormat, "format", internal.ReportMarkdown, "Format of the report: markdown, html or json")
+	reportCmd.Flags().StringVarP(&reportOutput, "output", "o", "", "Write the report to this file instead of stdout")
+	reportCmd.Flags().IntVar(&reportLimit, "limit", 20, "Number of runs to list without a run; 0 lists all")
+	reportCmd.RegisterFlagCompletionFunc("format", cobra.FixedCompletions([]string{internal.ReportMarkdown, internal.ReportHTML, internal.ReportJSON}, cobra.ShellCompDirectiveNoFileComp))
+
+	rootCmd.AddCommand(reportCmd)
+}
+
+func runReport(cmd *cobra.Command, args []string) error {
+	if err := internal.ValidateReportFormat(reportFormat); err != nil {
+		return err
+	}
+	db, err := internal.NewResultsDB(resultsDBPath)
+	if err != nil {
+		return err
+	}
+
+	if len(args) == 0 {
+		runs, err := db.Runs()
+		if err != nil {
+			return err
+		}
+		if len(runs) == 0 {
+			fmt.Println("No runs recorded in the results database")
+			return nil
+		}
+		return internal.WriteRunList(os.Stdout, runs, reportLimit)
+	}
+
+	records, err := db.FindRun(args[0])
+	if err != nil {
+		return err
+	}
+	decisions, err := db.TriageDecisions()
+	if err != nil {
+		return err
+	}
+	report := internal.NewRunReport(records, decisions)
+
+	out := os.Stdout
+	if reportOutput != "" {
+		file, err := os.Create(reportOutput)
+		if err != nil {
+			return fmt.Errorf("failed to create %s: %w", reportOutput, err)
+		}
+		defer file.Close()
+		out = file
+	}
+	if err := report.Write(out, reportFormat); err != nil {
+		return err
+	}
+	if reportOutput != "" {
+		fmt.Printf("Wrote %s\n", reportOutput)
+	}
+	return nil
+}
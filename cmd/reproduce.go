@@ -0,0 +1,217 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/chainguard-dev/apkregress/internal"
+	"github.com/spf13/cobra"
+)
+
+var reproduceWithoutRepo bool
+
+var reproduceCmd = &cobra.Command{
+	Use:   "reproduce <logdir> <package>",
+	Short: "Re-run the test of one package from an earlier run",
+	Long: `Re-run the with-repo test of a package exactly as an earlier run did, using
+the run.json manifest in its log directory, and stream the output to the
+terminal. Flags given on the command line override the recorded ones, e.g.
+--repo-path to use a different checkout.
+
+The logs are written to a reproduce-<timestamp> directory below <logdir>.`,
+	Example: `  apkregress reproduce logs/regression-test-openssl-20250106-120000 curl --without-repo`,
+	Args:    cobra.ExactArgs(2),
+	RunE:    runReproduce,
+}
+
+func init() {
+	reproduceCmd.Flags().BoolVar(&reproduceWithoutRepo, "without-repo", false, "Also run the test without the candidate repository")
+
+	rootCmd.AddCommand(reproduceCmd)
+}
+
+func runReproduce(cmd *cobra.Command, args []string) error {
+	return reproducePackage(args[0], args[1], reproduceWithoutRepo)
+}
+
+// reproducePackage re-runs the tests of pkg recorded in logDir, with the
+// candidate repository and, if withoutRepo is set, without it
+func reproducePackage(logDir, pkg string, withoutRepo bool) error {
+	manifest, err := internal.LoadRunManifest(logDir)
+	if err != nil {
+		return err
+	}
+	if manifest.Flags["apko-configs"] != "" {
+		return fmt.Errorf("reproducing apko config builds is not supported")
+	}
+	if err := applyManifestFlags(manifest); err != nil {
+		return err
+	}
+	if err := checkPlatform(); err != nil {
+		return err
+	}
+	if err := configureNetwork(); err != nil {
+		return err
+	}
+
+	if gitRef != "" || isolateTree {
+		// The recorded commit is exact even if the ref has moved since
+		ref := manifest.RepoCommit
+		if ref == "" {
+			ref = gitRef
+		}
+		if ref == "" {
+			ref = "HEAD"
+		}
+		worktree, err := internal.NewWorktree(repoPath, ref)
+		if err != nil {
+			return err
+		}
+		defer worktree.Remove()
+		repoPath = worktree.Path
+	} else {
+		unlock, err := lockRepoPaths([]string{repoPath})
+		if err != nil {
+			return err
+		}
+		defer unlock()
+	}
+
+	if commit := internal.GitCommit(repoPath); manifest.RepoCommit != "" && commit != manifest.RepoCommit {
+		fmt.Printf("Warning: %s is at %s, but the run tested %s\n", repoPath, commit, manifest.RepoCommit)
+	}
+	if changes := internal.ToolchainChanges(manifest.Toolchain, repoPath); len(changes) > 0 {
+		fmt.Printf("Warning: the tooling differs from the run's: %s\n", strings.Join(changes, ", "))
+	}
+
+	locator, err := internal.NewConfigLocator(repoPath, yamlLayout)
+	if err != nil {
+		return err
+	}
+
+	reproduceDir := filepath.Join(logDir, fmt.Sprintf("reproduce-%s", time.Now().Format("20060102-150405")))
+	if err := os.MkdirAll(reproduceDir, 0755); err != nil {
+		return fmt.Errorf("failed to create log directory %s: %w", reproduceDir, err)
+	}
+
+	melange := internal.NewMelangeClient(repoPath, verbose, reproduceDir, hangTimeout)
+	if testCommand != "" {
+		if err := melange.SetTestCommand(testCommand); err != nil {
+			return fmt.Errorf("invalid --test-command: %w", err)
+		}
+	}
+	if err := melange.CheckTools(); err != nil {
+		return err
+	}
+	melange.SetConfigLocator(locator)
+	melange.SetOutput(os.Stdout)
+	melange.SetArtifactCollection(collectArts)
+	melange.SetAuth(internal.RepositoryAuth(manifest.RepoType, manifest.APKRepo))
+	if melangeRunner != "" {
+		melange.SetRunner(melangeRunner)
+	}
+	melange.SetPipelineFilter(pipelineOnly, pipelineSkip)
+	// The pins resolved by the run, so that the same versions are installed
+	melange.SetPins(manifest.Pins)
+	if buildCacheDir != "" {
+		if err := melange.SetBuildCache(buildCacheDir); err != nil {
+			return err
+		}
+	}
+	if apkCacheDir != "" {
+		melange.SetAPKCache(apkCacheDir)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	scenarios := []bool{true}
+	if withoutRepo {
+		scenarios = append(scenarios, false)
+	}
+
+	results := make(map[bool]internal.TestResult)
+	for _, withRepo := range scenarios {
+		label := map[bool]string{true: "with", false: "without"}[withRepo]
+		fmt.Printf("=== Testing %s %s the candidate repository %s ===\n", pkg, label, apkRepo)
+
+		result := melange.Execute(ctx, pkg, internal.ExecuteOptions{WithRepo: withRepo, APKRepo: apkRepo})
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		results[withRepo] = result
+		fmt.Printf("=== %s %s the candidate repository: %s after %v (log: %s) ===\n",
+			pkg, label, result.Classification, result.Duration.Round(time.Second), result.LogPath)
+	}
+
+	withRepo := results[true]
+	if withRepo.Success {
+		fmt.Printf("\n%s passes with the candidate repository; the failure did not reproduce\n", pkg)
+		return nil
+	}
+	if withoutRepo, ok := results[false]; ok && withoutRepo.Success {
+		return fmt.Errorf("regression reproduced: %s fails with the candidate repository and passes without", pkg)
+	}
+	return fmt.Errorf("%s fails with the candidate repository: %v", pkg, withRepo.Error)
+}
+
+// applyManifestFlags sets the flags recorded in a run manifest that weren't
+// given on the command line. Paths are resolved against the directory the
+// run was started in.
+func applyManifestFlags(manifest *internal.RunManifest) error {
+	repoPathGiven := sharedFlags.Changed("repo-path")
+
+	for name, value := range manifest.Flags {
+		flag := sharedFlags.Lookup(name)
+		if flag == nil || flag.Changed {
+			continue
+		}
+
+		if strings.HasSuffix(flag.Value.Type(), "Slice") {
+			value = strings.TrimSuffix(strings.TrimPrefix(value, "["), "]")
+			if value == "" {
+				continue
+			}
+		}
+		switch name {
+		case "repo", "repo-path", "build-cache-dir", "apk-cache-dir":
+			value = manifestPath(manifest.WorkDir, value)
+		}
+
+		if err := sharedFlags.Set(name, value); err != nil {
+			return fmt.Errorf("invalid recorded flag --%s=%s: %w", name, value, err)
+		}
+	}
+
+	// Runs with --git-ref or --isolate-worktree tested a temporary worktree,
+	// which is checked out again from the recorded --repo-path
+	if !repoPathGiven && gitRef == "" && !isolateTree && manifest.RepoPath != "" {
+		repoPath = manifest.RepoPath
+	}
+	if apkRepo == "" {
+		apkRepo = manifest.APKRepo
+	}
+	return nil
+}
+
+// manifestPath resolves a relative local path recorded in a manifest against
+// workDir; URLs and absolute paths are returned unchanged
+func manifestPath(workDir, value string) string {
+	if value == "" || workDir == "" || filepath.IsAbs(value) {
+		return value
+	}
+	if u, err := url.Parse(value); err == nil && u.Scheme != "" {
+		return value
+	}
+	return filepath.Join(workDir, value)
+}
@@ -0,0 +1,104 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package cmd
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/chainguard-dev/apkregress/internal"
+)
+
+func TestApplyManifestFlags(t *testing.T) {
+	origRepo, origRepoPath, origTimeout, origMirrors := apkRepo, repoPath, hangTimeout, mirrorFlags
+	defer func() {
+		apkRepo, repoPath, hangTimeout, mirrorFlags = origRepo, origRepoPath, origTimeout, origMirrors
+		for _, name := range []string{"repo", "repo-path", "hang-timeout", "mirror"} {
+			sharedFlags.Lookup(name).Changed = false
+		}
+	}()
+
+	// Given on the command line
+	if err := sharedFlags.Set("repo-path", "/checkout/os"); err != nil {
+		t.Fatal(err)
+	}
+
+	manifest := &internal.RunManifest{
+		WorkDir:  "/src",
+		RepoPath: "/src/os",
+		Flags: map[string]string{
+			"repo":         "packages",
+			"repo-path":    "os",
+			"hang-timeout": "1h0m0s",
+			"mirror":       "[https://packages.wolfi.dev/os=https://mirror.example/os]",
+			"unknown-flag": "value",
+		},
+	}
+	if err := applyManifestFlags(manifest); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if apkRepo != "/src/packages" {
+		t.Errorf("Expected repo /src/packages, got %s", apkRepo)
+	}
+	if repoPath != "/checkout/os" {
+		t.Errorf("Expected repo path from the command line, got %s", repoPath)
+	}
+	if hangTimeout != time.Hour {
+		t.Errorf("Expected hang timeout 1h, got %v", hangTimeout)
+	}
+	if expected := []string{"https://packages.wolfi.dev/os=https://mirror.example/os"}; !reflect.DeepEqual(mirrorFlags, expected) {
+		t.Errorf("Expected mirrors %v, got %v", expected, mirrorFlags)
+	}
+}
+
+func TestManifestPath(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected string
+	}{
+		{"packages", "/src/packages"},
+		{"/abs/packages", "/abs/packages"},
+		{"https://packages.wolfi.dev/os", "https://packages.wolfi.dev/os"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if got := manifestPath("/src", tt.value); got != tt.expected {
+			t.Errorf("manifestPath(%q): expected %s, got %s", tt.value, tt.expected, got)
+		}
+	}
+}
+
+func TestApplyManifestFlagsGitRef(t *testing.T) {
+	origRepo, origRepoPath, origGitRef := apkRepo, repoPath, gitRef
+	defer func() {
+		apkRepo, repoPath, gitRef = origRepo, origRepoPath, origGitRef
+		for _, name := range []string{"repo", "repo-path", "git-ref"} {
+			sharedFlags.Lookup(name).Changed = false
+		}
+	}()
+
+	// The run tested a temporary worktree of the recorded --repo-path
+	manifest := &internal.RunManifest{
+		WorkDir:  "/src",
+		RepoPath: "/tmp/apkregress-worktree-123",
+		Flags: map[string]string{
+			"repo":      "packages",
+			"repo-path": "os",
+			"git-ref":   "v1",
+		},
+	}
+	if err := applyManifestFlags(manifest); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if repoPath != "/src/os" {
+		t.Errorf("Expected repo path /src/os, got %s", repoPath)
+	}
+	if gitRef != "v1" {
+		t.Errorf("Expected git ref v1, got %s", gitRef)
+	}
+}
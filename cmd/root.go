@@ -5,14 +5,21 @@ package cmd
 
 import (
 	"bufio"
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"sort"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/chainguard-dev/apkregress/internal"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
 var (
@@ -25,8 +32,115 @@ var (
 	verbose        bool
 	hangTimeout    time.Duration
 	markdownOutput bool
+	sbomFiles      []string
+	sbomImages     []string
+	sbomMode       string
+	apkoConfigDir  string
+	cacheDir       string
+	noIndexCache   bool
+	resultsDBPath  string
+	noResultsDB    bool
+	yamlLayout     string
+	repoKeys       []string
+	expectVersion  string
+	skipRepoCheck  bool
+	heartbeatURL   string
+	heartbeatEvery time.Duration
+	buildCacheDir  string
+	apkCacheDir    string
+	httpProxy      string
+	httpsProxy     string
+	noProxy        string
+	mirrorFlags    []string
+	credsFile      string
+	noAdvisories   bool
+	previousRun    string
+	logURL         string
+	gitRef         string
+	isolateTree    bool
+	skipUnchanged  bool
+	controlSocket  string
+	collectArts    bool
+	snapshotRegs   bool
+	failOnEmpty    bool
+	pipelineOnly   []string
+	pipelineSkip   []string
+	testCommand    string
+	twoPhase       bool
+	ciMode         bool
+	githubSummary  bool
+	summaryJSON    bool
+	compressLogs   bool
+	watchdogAfter  time.Duration
+	maxPackages    int
+	assumeYes      bool
+	chunkSize      int
+	tmpRoot        string
+	cleanLeaks     bool
+	gatePath       string
+	candidateMode  string
+	profileName    string
+	configPath     string
+	excludes       []string
+	noInfraBackoff bool
+	preHook        string
+	postHook       string
+	preTestHook    string
+	postTestHook   string
+	processorSpecs []string
+	matrixName     string
+	force          bool
+	depth          int
+	pruneOnRegress bool
+	verifyIndex    bool
+	indexKeys      []string
+	pinsFile       string
+	follow         []string
+	notes          []string
+	resultFiles    []string
+	resultFormats  []string
+	resultsDir     string
+	noLock         bool
+	testPrevious   bool
+	failureBudget  string
+	depKind        string
+	healthListen   string
+	// targetVersion is the version of --package given as name@version
+	targetVersion string
 )
 
+// health is reported on --health-listen, and marked stopping on a stop
+// signal
+var health *internal.Health
+
+// runContext is cancelled to stop the run in progress, with errWedged or a
+// signalError as the cause
+var runContext, stopRun = context.WithCancelCause(context.Background())
+
+// errWedged stops runs in which the watchdog noticed no progress
+var errWedged = errors.New("no test started or finished for --watchdog-timeout")
+
+// signalError stops runs on a stop signal
+type signalError struct {
+	sig os.Signal
+}
+
+func (e *signalError) Error() string {
+	return fmt.Sprintf("received signal %v", e.sig)
+}
+
+// stopTimeout is how long a stopped run has to stop its tests and report
+// its results before the process exits regardless
+const stopTimeout = internal.StopGrace + 5*time.Second
+
+// melangeRunner is the melange runner the host needs, set by checkPlatform
+var melangeRunner string
+
+// sharedFlags are the flags of the root command, which its subcommands
+// inherit. Referencing rootCmd from run functions would be an initialization
+// cycle.
+var sharedFlags *pflag.FlagSet
+
 var rootCmd = &cobra.Command{
 	Use:   "apkregress",
 	Short: "Test reverse dependencies of a package for regressions",
@@ -34,92 +148,1243 @@ var rootCmd = &cobra.Command{
 and melange to test each reverse dependency against a provided APK repository.
 Tests are run with and without the APK repository to detect regressions.
 Supports wolfi-dev/os, chainguard-dev/enterprise-packages, and chainguard-dev/extra-packages repositories.`,
-	RunE: runRegressionTest,
+	PersistentPreRunE: applyPresets,
+	RunE:              runRegressionTest,
 }
 
 func Execute() error {
 	return rootCmd.Execute()
 }
 
+// ExitEmpty is the exit status of runs that found no reverse dependencies
+// with --fail-on-empty, so that CI can tell "nothing to test" apart from
+// failures
+const ExitEmpty = 3
+
+// ExitWedged is the exit status of runs the watchdog stopped because no
+// test started or finished for --watchdog-timeout
+const ExitWedged = 4
+
+// ExitCandidateChanged is the exit status of runs aborted because the
+// candidate repository changed, with --candidate-change=abort, so that CI can
+// retry once the repository settled
+const ExitCandidateChanged = 10
+
+// Exit statuses of runs that failed before testing, so that CI can retry or
+// alert on the cause
+const (
+	ExitAuth           = 5
+	ExitIndexFetch     = 6
+	ExitUnreachable    = 7
+	ExitMakeMissing    = 8
+	ExitMelangeMissing = 9
+	ExitTargetBroken   = 11
+	ExitIndexSignature = 12
+	ExitRepoLocked     = 13
+)
+
+// setupErrors maps the errors of runs that failed before testing to their
+// exit status and a hint on how to fix them, checked in order since an error
+// may wrap several
+var setupErrors = []struct {
+	err  error
+	code int
+	hint string
+}{
+	{internal.ErrIndexSignature, ExitIndexSignature, "the index may have been tampered with; check --index-key and --repo-key if the repository is signed with another key"},
+	{internal.ErrAuth, ExitAuth, "run 'chainctl auth login', or pass --credentials-file for repositories chainctl can't authenticate to"},
+	{internal.ErrRepoUnreachable, ExitUnreachable, "check the network connection, and --http-proxy or --mirror if the repository is only reachable through them"},
+	{internal.ErrIndexFetch, ExitIndexFetch, "check that --repo is an APK repository with an index for this architecture"},
+	{internal.ErrMakeMissing, ExitMakeMissing, "install make, which package tests are run with"},
+	{internal.ErrMelangeMissing, ExitMelangeMissing, "install melange, which package tests are run with"},
+	{internal.ErrRepoLocked, ExitRepoLocked, "wait for the other run to finish, use --isolate-worktree, or pass --no-lock if it doesn't build in this checkout"},
+	{internal.ErrTargetBroken, ExitTargetBroken, "fix the package's own test first, or pass --force to test its reverse dependencies anyway"},
+}
+
+// ExitCode returns the exit status for the error Execute returned
+func ExitCode(err error) int {
+	var budgetErr *internal.BudgetExceededError
+	if errors.As(err, &budgetErr) {
+		return budgetErr.ExitCode
+	}
+	if errors.Is(err, internal.ErrNoReverseDependencies) {
+		return ExitEmpty
+	}
+	if errors.Is(err, internal.ErrCandidateChanged) {
+		return ExitCandidateChanged
+	}
+	if errors.Is(err, errWedged) {
+		return ExitWedged
+	}
+	var sigErr *signalError
+	if errors.As(err, &sigErr) {
+		if s, ok := sigErr.sig.(syscall.Signal); ok {
+			return 128 + int(s)
+		}
+	}
+	for _, setup := range setupErrors {
+		if errors.Is(err, setup.err) {
+			return setup.code
+		}
+	}
+	return 1
+}
+
+// ErrorHint returns how to fix the error Execute returned, or "" if there's
+// nothing more to say than the error
+func ErrorHint(err error) string {
+	for _, setup := range setupErrors {
+		if errors.Is(err, setup.err) {
+			return setup.hint
+		}
+	}
+	return ""
+}
+
 func init() {
-	rootCmd.PersistentFlags().StringVarP(&packageName, "package", "p", "", "Package name to find reverse dependencies for")
+	rootCmd.PersistentFlags().StringVarP(&packageName, "package", "p", "", "Package name to find reverse dependencies for; a comma-separated list tests the reverse dependencies of all of them together, and name@version, e.g. openssl@3.3.2-r1, ties the run to that build in the candidate repository")
 	rootCmd.PersistentFlags().StringVarP(&packageFile, "package-file", "f", "", "File containing list of package names (one per line)")
 	rootCmd.PersistentFlags().StringVarP(&apkRepo, "repo", "r", "", "APK repository URL to test against (required)")
-	rootCmd.PersistentFlags().StringVarP(&repoPath, "repo-path", "w", "", "Path to package repository (wolfi-dev/os, chainguard-dev/enterprise-packages, or chainguard-dev/extra-packages) (required)")
-	rootCmd.PersistentFlags().StringVarP(&repoType, "repo-type", "t", "wolfi", "Repository type: wolfi, enterprise, or extras")
-	rootCmd.PersistentFlags().IntVarP(&concurrency, "concurrency", "c", 4, "Number of concurrent test jobs")
-	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "Enable verbose output")
+	rootCmd.PersistentFlags().StringVarP(&repoPath, "repo-path", "w", "", "Path to package repository (wolfi-dev/os, chainguard-dev/enterprise-packages, or chainguard-dev/extra-packages); a comma-separated list pairs paths with repository types (required)")
+	rootCmd.PersistentFlags().StringVarP(&repoType, "repo-type", "t", "wolfi", "Repository type: wolfi, enterprise, extras, or one defined in the config file; a comma-separated list tests each in turn")
+	rootCmd.PersistentFlags().IntVarP(&concurrency, "concurrency", "c", 4, "Number of concurrent test jobs; 0 picks one per two CPUs, limited by memory")
+	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "Enable verbose output, including the command line of every test")
 	rootCmd.PersistentFlags().DurationVar(&hangTimeout, "hang-timeout", 30*time.Minute, "Timeout for hung tests (default: 30m)")
+	rootCmd.PersistentFlags().DurationVar(&watchdogAfter, "watchdog-timeout", 0, "Stop a run in which no test started or finished for this long, dumping goroutine stacks (default: the longest test timeout plus 15m; negative disables)")
 	rootCmd.PersistentFlags().BoolVarP(&markdownOutput, "markdown", "m", false, "Output test summary in markdown format for GitHub issues")
+	rootCmd.PersistentFlags().StringSliceVar(&repoKeys, "repo-key", nil, "Public key (file or URL) the candidate repository index must be signed with (repeatable)")
+	rootCmd.PersistentFlags().BoolVar(&verifyIndex, "verify-index", false, "Fail unless the package index and the candidate repository index are signed with a trusted key: --index-key, or the repository type's signing key, and --repo-key for the candidate repository if given")
+	rootCmd.PersistentFlags().StringSliceVar(&indexKeys, "index-key", nil, "Public key (file or URL) the package index must be signed with, implies --verify-index (repeatable)")
+	rootCmd.PersistentFlags().StringVar(&expectVersion, "expect-version", "", "Version of --package the candidate repository must contain, e.g. 3.3.2 or 3.3.2-r1")
+	rootCmd.PersistentFlags().BoolVar(&skipRepoCheck, "skip-repo-check", false, "Don't validate the candidate repository index before testing")
+	rootCmd.PersistentFlags().StringVar(&gitRef, "git-ref", "", "Test the package configs at this git ref of repo-path, checked out into a temporary worktree")
+	rootCmd.PersistentFlags().BoolVar(&isolateTree, "isolate-worktree", false, "Run in a clean temporary git worktree of repo-path (at --git-ref, or HEAD), isolated from edits and other runs in the checkout")
+	rootCmd.PersistentFlags().BoolVar(&noLock, "no-lock", false, "Don't lock repo-path against other runs building in the same checkout")
+	rootCmd.PersistentFlags().StringVar(&yamlLayout, "yaml-layout", "flat", "Where package YAML files live in repo-path: flat, recursive, or a pattern such as packages/{name}/{name}.yaml")
+	rootCmd.PersistentFlags().StringVar(&buildCacheDir, "build-cache-dir", "", "Directory of build caches (ccache, Go, cargo) shared by all melange tests")
+	rootCmd.PersistentFlags().StringVar(&apkCacheDir, "apk-cache-dir", "", "Directory of downloaded APKs shared by all tests and builds")
+	rootCmd.PersistentFlags().StringVar(&cacheDir, "cache-dir", "", "Directory for cached package indexes (default: user cache directory)")
+	rootCmd.PersistentFlags().BoolVar(&noIndexCache, "no-index-cache", false, "Always download the package index instead of using the cache")
+	rootCmd.PersistentFlags().StringVar(&resultsDBPath, "results-db", "", "Results database recording every run (default: results.jsonl in the user cache directory)")
+	rootCmd.PersistentFlags().BoolVar(&noResultsDB, "no-results-db", false, "Don't record this run in the results database")
+	rootCmd.PersistentFlags().BoolVar(&noAdvisories, "no-advisories", false, "Don't annotate the summary with the vulnerabilities the candidate version of --package fixes")
+	rootCmd.PersistentFlags().StringVar(&preHook, "pre-hook", "", "Shell command to run before the first test, e.g. to warm caches; gets the run manifest as JSON on stdin and fails the run if it fails")
+	rootCmd.PersistentFlags().StringVar(&postHook, "post-hook", "", "Shell command to run once the run finished, e.g. to notify; gets the run summary as JSON on stdin")
+	rootCmd.PersistentFlags().StringVar(&preTestHook, "pre-test-hook", "", "Shell command to run before the tests of each package, with APKREGRESS_TEST_PACKAGE set")
+	rootCmd.PersistentFlags().StringVar(&postTestHook, "post-test-hook", "", "Shell command to run after the tests of each package, with APKREGRESS_TEST_PACKAGE and APKREGRESS_TEST_STATUS set and the results as JSON on stdin")
+	rootCmd.PersistentFlags().BoolVar(&force, "force", false, "Test the reverse dependencies of --package even if the package fails its own test with the candidate repository")
+	rootCmd.PersistentFlags().IntVar(&depth, "depth", 1, "Test consumers up to this many levels away from --package, nearest first: 1 tests its reverse dependencies, 2 their reverse dependencies as well, and so on")
+	rootCmd.PersistentFlags().StringVar(&pinsFile, "pins", "", "File of packages (name or name=version) with-repo tests install at their version in the candidate repository, even if the baseline has a higher one")
+	rootCmd.PersistentFlags().StringVar(&depKind, "dep-kind", internal.DepKindAll, "Test the consumers that depend on --package at runtime (listed in the index), at build time (declared in their melange config), or all")
+	rootCmd.PersistentFlags().BoolVar(&pruneOnRegress, "prune-on-regression", false, "With --depth above 1, don't test the consumers of a consumer that regresses unless they also depend on one that doesn't")
+	rootCmd.PersistentFlags().StringArrayVar(&processorSpecs, "result-processor", nil, "Pass the results to a processor as they come in, given as NAME or NAME:CONFIG, e.g. exec:./upload.py to stream them as NDJSON to a command or file:results.ndjson (repeatable)")
+	rootCmd.PersistentFlags().BoolVar(&noInfraBackoff, "no-infra-backoff", false, "Report tests that fail on the infrastructure (docker daemon, network, memory, full disk, qemu) right away, instead of testing them again and reducing the concurrency after a burst of such failures")
+	rootCmd.PersistentFlags().BoolVar(&skipUnchanged, "skip-unchanged", false, "Don't test packages whose config, candidate dependency versions and melange version match an earlier passing run in the results database")
+	rootCmd.PersistentFlags().StringVar(&candidateMode, "candidate-change", "", "Check the candidate repository index during the run and abort (\"abort\") or warn (\"warn\") when it changes, so all packages are tested against one build")
+	rootCmd.PersistentFlags().StringVar(&gatePath, "gate", "", "Expected results (results.json, log directory or a file listing \"regressions\" and \"hung\" packages such as summary.json) to fail the run only on new regressions and newly hung tests")
+	rootCmd.PersistentFlags().StringVar(&failureBudget, "failure-budget", "", "Fail the run only when its regressions exceed this failure budget in the config file, e.g. more than 2 python packages, with the exit status of the exceeded rule")
+	rootCmd.PersistentFlags().StringVar(&healthListen, "health-listen", "", "Serve /healthz and /readyz on this address, e.g. :8081, for the liveness and readiness probes of a container orchestrator")
+	rootCmd.PersistentFlags().StringVar(&previousRun, "previous-results", "", "results.json (or log directory) of an earlier run to compare regressions with (default: the latest run in the results database)")
+	rootCmd.PersistentFlags().BoolVar(&collectArts, "collect-artifacts", false, "Collect core dumps, a snapshot of the workspace of failed tests and files tests write to $APKREGRESS_ARTIFACTS_DIR in the log directory")
+	rootCmd.PersistentFlags().IntVar(&maxPackages, "max-packages", 500, "Ask before testing more packages than this, showing the estimated runtime; 0 disables")
+	rootCmd.PersistentFlags().BoolVarP(&assumeYes, "yes", "y", false, "Test more than --max-packages packages without asking")
+	rootCmd.PersistentFlags().StringVar(&tmpRoot, "tmp-root", "", "Directory for the temporary files of tests, in a subdirectory per run (default: the system's temporary directory)")
+	rootCmd.PersistentFlags().BoolVar(&cleanLeaks, "clean-leaks", false, "Remove the temporary files a run leaves behind, e.g. read-only files of killed tests, instead of only reporting them")
+	rootCmd.PersistentFlags().IntVar(&chunkSize, "chunk-size", 0, "Test packages in chunks of this size, writing the result files and an intermediate summary after each chunk; 0 tests all at once")
+	rootCmd.PersistentFlags().BoolVar(&failOnEmpty, "fail-on-empty", false, "Exit with status 3 when no reverse dependencies are found, instead of succeeding")
+	rootCmd.PersistentFlags().BoolVar(&snapshotRegs, "snapshot-regressions", false, "Re-run the with-repo test of each regression keeping melange's workspace and archive it in the log directory")
+	rootCmd.PersistentFlags().BoolVar(&testPrevious, "test-previous", false, "Also test the previous version of each consumer that fails with the candidate repository, from the git history of its config")
+	rootCmd.PersistentFlags().StringSliceVar(&pipelineOnly, "test-pipeline-only", nil, "Only run the melange test pipelines matching these names, e.g. python/import, for a faster signal pass (requires melange support)")
+	rootCmd.PersistentFlags().StringSliceVar(&pipelineSkip, "test-pipeline-skip", nil, "Don't run the melange test pipelines matching these names (requires melange support)")
+	rootCmd.PersistentFlags().StringVar(&testCommand, "test-command", "", "Run tests with this command template instead of make, e.g. 'melange test {{.Config}} {{.ExtraRepos}} {{.ExtraOpts}}'")
+	rootCmd.PersistentFlags().BoolVar(&twoPhase, "two-phase", false, "Run a quick install smoke test of every package first and only test in full those it shows to be affected by the candidate repository")
+	rootCmd.PersistentFlags().BoolVar(&ciMode, "ci", false, "Preset for CI jobs: enables --github-summary, --summary-json and --compress-logs, picks the concurrency automatically unless --concurrency is given and names log directories safely for artifact upload")
+	rootCmd.PersistentFlags().BoolVar(&githubSummary, "github-summary", false, "In GitHub Actions, append the markdown summary to the job summary and set step outputs such as log-dir and regressions")
+	rootCmd.PersistentFlags().BoolVar(&summaryJSON, "summary-json", false, "Write the run summary to summary.json in the log directory")
+	rootCmd.PersistentFlags().StringSliceVar(&resultFiles, "result-files", nil, "Package lists to write result files for: successful, failed, regressions, hung and skipped, or none (default: all)")
+	rootCmd.PersistentFlags().StringSliceVar(&resultFormats, "result-format", []string{"txt"}, "Formats to write the result files in: txt, json or csv (comma-separated for several)")
+	rootCmd.PersistentFlags().StringVar(&resultsDir, "results-dir", "", "Directory to write the result files to instead of the log directory")
+	rootCmd.PersistentFlags().BoolVar(&compressLogs, "compress-logs", false, "Gzip the logs of passing tests when the run finishes; logs of failed tests are kept as they are")
+	rootCmd.PersistentFlags().StringVar(&logURL, "log-url", "", "URL the logs directory is published at, e.g. by CI; the markdown summary links each log to it")
+	rootCmd.PersistentFlags().StringVar(&controlSocket, "control-socket", "", "Unix socket to serve an HTTP API on for adding or cancelling packages and pausing the run while it is in progress")
+	rootCmd.PersistentFlags().StringVar(&heartbeatURL, "heartbeat-url", "", "URL to POST run progress to periodically as JSON")
+	rootCmd.PersistentFlags().DurationVar(&heartbeatEvery, "heartbeat-interval", 30*time.Second, "Interval between heartbeats")
+	rootCmd.PersistentFlags().StringVar(&httpProxy, "http-proxy", "", "Proxy for HTTP requests of apkregress and the tools it runs (sets HTTP_PROXY)")
+	rootCmd.PersistentFlags().StringVar(&httpsProxy, "https-proxy", "", "Proxy for HTTPS requests of apkregress and the tools it runs (sets HTTPS_PROXY)")
+	rootCmd.PersistentFlags().StringVar(&noProxy, "no-proxy", "", "Comma-separated hosts to reach without a proxy (sets NO_PROXY)")
+	rootCmd.PersistentFlags().StringSliceVar(&mirrorFlags, "mirror", nil, "Fetch repository URLs starting with FROM from TO instead, given as FROM=TO (repeatable)")
+	rootCmd.PersistentFlags().StringVar(&credsFile, "credentials-file", "", "YAML file with the credentials to use for each repository host, for index discovery and tests")
+	rootCmd.PersistentFlags().StringVar(&apkoConfigDir, "apko-configs", "", "Directory of apko image configs to build with and without the APK repository")
+	rootCmd.PersistentFlags().StringSliceVar(&sbomFiles, "sbom", nil, "SPDX or CycloneDX JSON SBOM listing shipped packages (repeatable)")
+	rootCmd.PersistentFlags().StringSliceVar(&sbomImages, "sbom-image", nil, "Image reference whose SBOM attestation lists shipped packages (repeatable)")
+	rootCmd.PersistentFlags().StringArrayVar(&notes, "note", nil, "Note about the run, e.g. \"validating openssl 3.3 bump\", recorded in the manifest and shown in reports and history; - reads it from stdin (repeatable)")
+	rootCmd.PersistentFlags().StringSliceVar(&follow, "follow", nil, "Stream the test output of these packages to the terminal while the run continues (repeatable)")
+	rootCmd.PersistentFlags().StringSliceVar(&excludes, "exclude", nil, "Don't test packages matching these names or globs, e.g. llvm-* (repeatable)")
+	rootCmd.PersistentFlags().StringVar(&profileName, "profile", "", "Apply the flag values of this profile in the config file, e.g. quick or nightly; flags given explicitly win")
+	rootCmd.PersistentFlags().StringVar(&configPath, "config", "", "Config file defining the profiles for --profile and matrices for --matrix (default: config.yaml in the user config directory)")
+	rootCmd.PersistentFlags().StringVar(&matrixName, "matrix", "", "Test every reverse dependency in each variant of this matrix in the config file, e.g. with and without a feature flag or against alternative candidate repositories, and report the results by variant")
+	rootCmd.PersistentFlags().StringVar(&sbomMode, "sbom-mode", "restrict", "How to use SBOM packages: restrict (only test shipped consumers) or prioritize (test them first)")
+
+	sharedFlags = rootCmd.PersistentFlags()
+	registerFlagCompletions()
 
-	rootCmd.MarkPersistentFlagRequired("repo")
-	rootCmd.MarkPersistentFlagRequired("repo-path")
+	// --repo and --repo-path are checked by runRegressionTest rather than
+	// marked required, since subcommands such as daemon inherit them
+}
+
+// applyPresets applies --profile and then --ci before any command runs, and
+// registers the repository types of the config file
+func applyPresets(cmd *cobra.Command, args []string) error {
+	if err := applyProfile(); err != nil {
+		return err
+	}
+	if err := loadRepoTypes(); err != nil {
+		return err
+	}
+	return applyCIPreset(cmd, args)
+}
+
+// loadRepoTypes registers the repository types defined in the config file
+// alongside the built-in ones. Without --config, a missing default config
+// file defines none.
+func loadRepoTypes() error {
+	path := configPath
+	if path == "" {
+		var err error
+		if path, err = internal.DefaultConfigPath(); err != nil {
+			return nil
+		}
+	}
+	types, err := internal.LoadRepoTypes(path)
+	if err != nil {
+		return err
+	}
+	return internal.RegisterRepoTypes(types)
+}
+
+// applyProfile applies the flag values of --profile unless they are given
+// explicitly
+func applyProfile() error {
+	if profileName == "" {
+		return nil
+	}
+	path := configPath
+	if path == "" {
+		var err error
+		if path, err = internal.DefaultConfigPath(); err != nil {
+			return err
+		}
+	}
+	values, err := internal.LoadProfile(path, profileName)
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		flag := sharedFlags.Lookup(name)
+		if flag == nil || name == "profile" || name == "config" {
+			return fmt.Errorf("profile %s: unknown flag --%s", profileName, name)
+		}
+		if sharedFlags.Changed(name) {
+			continue
+		}
+		items := values[name]
+		if _, repeatable := flag.Value.(pflag.SliceValue); len(items) > 1 && !repeatable {
+			return fmt.Errorf("profile %s: --%s takes a single value", profileName, name)
+		}
+		for _, item := range items {
+			if err := sharedFlags.Set(name, item); err != nil {
+				return fmt.Errorf("profile %s: invalid --%s: %w", profileName, name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// ciPreset are the flag values --ci sets unless they are given explicitly
+var ciPreset = map[string]string{
+	"github-summary": "true",
+	"summary-json":   "true",
+	"compress-logs":  "true",
+	"concurrency":    "0",
+}
+
+// applyCIPreset applies --ci and resolves --concurrency 0 before any command
+// runs
+func applyCIPreset(cmd *cobra.Command, args []string) error {
+	if ciMode {
+		for name, value := range ciPreset {
+			if sharedFlags.Changed(name) {
+				continue
+			}
+			if err := sharedFlags.Set(name, value); err != nil {
+				return fmt.Errorf("failed to apply --ci: %w", err)
+			}
+		}
+	}
+
+	switch {
+	case concurrency < 0:
+		return fmt.Errorf("invalid --concurrency %d: must be 0 (automatic) or more", concurrency)
+	case concurrency == 0:
+		concurrency = internal.AutoConcurrency()
+		// Record the concurrency picked, e.g. in run manifests
+		if err := sharedFlags.Set("concurrency", fmt.Sprint(concurrency)); err != nil {
+			return err
+		}
+	}
+
+	if githubSummary && !ciMode && internal.GitHubActionsFromEnv() == nil {
+		fmt.Println("Warning: --github-summary has no effect outside GitHub Actions ($GITHUB_STEP_SUMMARY is not set)")
+	}
+	return nil
 }
 
 func runRegressionTest(cmd *cobra.Command, args []string) error {
-	// Validate that either package or package-file is provided, but not both
-	if packageName == "" && packageFile == "" {
-		return fmt.Errorf("either --package or --package-file must be specified")
+	// Validate that exactly one of package, package-file or apko-configs is provided
+	if packageName == "" && packageFile == "" && apkoConfigDir == "" {
+		return fmt.Errorf("either --package, --package-file or --apko-configs must be specified")
 	}
 	if packageName != "" && packageFile != "" {
 		return fmt.Errorf("cannot specify both --package and --package-file")
 	}
+	if apkoConfigDir != "" && (packageName != "" || packageFile != "") {
+		return fmt.Errorf("cannot combine --apko-configs with --package or --package-file")
+	}
+	if apkRepo == "" {
+		return fmt.Errorf("--repo is required")
+	}
+	if repoPath == "" {
+		return fmt.Errorf("--repo-path is required")
+	}
+	if strings.Contains(packageName, "@") {
+		if strings.Contains(packageName, ",") {
+			return fmt.Errorf("a version (name@version) can only be given with a single --package")
+		}
+		if expectVersion != "" {
+			return fmt.Errorf("cannot combine --package name@version with --expect-version")
+		}
+		name, version, err := internal.ParsePackageVersion(packageName)
+		if err != nil {
+			return err
+		}
+		packageName, targetVersion = name, version
+	}
+	if packageName != "" {
+		for _, name := range strings.Split(packageName, ",") {
+			if err := internal.ValidatePackageName(name); err != nil {
+				return fmt.Errorf("invalid --package: %w", err)
+			}
+		}
+	}
 
-	if !filepath.IsAbs(repoPath) {
-		absPath, err := filepath.Abs(repoPath)
+	if healthListen != "" {
+		health = internal.NewHealth()
+		stopHealth, err := internal.ServeHealth(healthListen, health)
 		if err != nil {
-			return fmt.Errorf("failed to resolve repository path: %w", err)
+			return err
+		}
+		defer stopHealth()
+	}
+
+	repoPaths, err := resolveRepoPaths(repoPath)
+	if err != nil {
+		return err
+	}
+	repoPaths, cleanup, err := isolateRepoPaths(repoPaths)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	stopCleanup := cleanupOnSignal(cleanup)
+	defer stopCleanup()
+	repoPath = strings.Join(repoPaths, ",")
+
+	// Validate repository types
+	repoTypes := strings.Split(repoType, ",")
+	for _, t := range repoTypes {
+		if t == internal.RepoTypeAlpine {
+			return fmt.Errorf("alpine packages can't be tested, only their reverse dependencies compared; use apkregress compare-alpine")
+		}
+		if err := internal.ValidateRepoType(t); err != nil {
+			return err
+		}
+	}
+	if len(repoPaths) > 1 && len(repoPaths) != len(repoTypes) {
+		return fmt.Errorf("--repo-path lists %d paths but --repo-type lists %d types", len(repoPaths), len(repoTypes))
+	}
+	if len(repoTypes) > 1 && packageName == "" {
+		return fmt.Errorf("multiple repository types are only supported with --package")
+	}
+	targets := strings.Split(packageName, ",")
+	if len(targets) > 1 && len(repoTypes) > 1 {
+		return fmt.Errorf("multiple repository types are only supported with a single --package")
+	}
+	var variants []internal.Variant
+	if matrixName != "" {
+		if packageName == "" || len(targets) > 1 {
+			return fmt.Errorf("--matrix is only supported with a single --package")
 		}
-		repoPath = absPath
+		if variants, err = loadMatrix(); err != nil {
+			return err
+		}
+	}
+	if len(targets) > 1 && expectVersion != "" {
+		return fmt.Errorf("--expect-version requires a single --package")
+	}
+
+	if err := internal.CheckRepoArch(apkRepo); err != nil {
+		return err
+	}
+
+	if expectVersion != "" && packageName == "" {
+		return fmt.Errorf("--expect-version requires --package")
 	}
 
-	if _, err := os.Stat(repoPath); os.IsNotExist(err) {
-		return fmt.Errorf("repository path does not exist: %s", repoPath)
+	if sbomMode != "restrict" && sbomMode != "prioritize" {
+		return fmt.Errorf("invalid SBOM mode: %s (must be restrict or prioritize)", sbomMode)
+	}
+	if (len(sbomFiles) > 0 || len(sbomImages) > 0) && packageName == "" {
+		return fmt.Errorf("--sbom and --sbom-image only filter the reverse dependencies of --package; list the packages to test in --package-file instead")
+	}
+	if controlSocket != "" && apkoConfigDir != "" {
+		return fmt.Errorf("--control-socket is not supported with --apko-configs")
+	}
+	if twoPhase && apkoConfigDir != "" {
+		return fmt.Errorf("--two-phase is not supported with --apko-configs")
+	}
+
+	if err := checkPlatform(); err != nil {
+		return err
+	}
+	if err := configureNetwork(); err != nil {
+		return err
 	}
 
-	// Validate repository type
-	if repoType != "wolfi" && repoType != "enterprise" && repoType != "extras" {
-		return fmt.Errorf("invalid repository type: %s (must be wolfi, enterprise, or extras)", repoType)
+	if len(repoTypes) > 1 || len(variants) > 0 {
+		return runMatrix(repoTypes, repoPaths, variants)
+	}
+
+	locator, err := internal.NewConfigLocator(repoPath, yamlLayout)
+	if err != nil {
+		return err
+	}
+
+	if apkoConfigDir != "" {
+		// apko config mode: build image configs with and without the repository
+		if _, err := os.Stat(apkoConfigDir); err != nil {
+			return fmt.Errorf("apko config directory does not exist: %s", apkoConfigDir)
+		}
+		if err := checkCandidateRepo(""); err != nil {
+			return err
+		}
+		runner := internal.NewRegressionTestRunnerFromApkoConfigs(apkoConfigDir, apkRepo, concurrency, verbose, hangTimeout, markdownOutput)
+		if err := configureRunner(runner); err != nil {
+			return err
+		}
+		return runner.RunApkoConfigs(apkoConfigDir)
 	}
 
 	if packageFile != "" {
 		// Package file mode: test packages directly from file
-		packages, err := readPackageFile(packageFile)
+		specs, err := readPackageSpecs(packageFile, locator)
 		if err != nil {
-			return fmt.Errorf("failed to read package file: %w", err)
+			return err
+		}
+		if err := checkCandidateRepo(""); err != nil {
+			return err
+		}
+		packages := make([]string, 0, len(specs))
+		options := make(map[string]internal.PackageOptions)
+		for _, spec := range specs {
+			packages = append(packages, spec.Name)
+			options[spec.Name] = spec.Options
 		}
 		runner := internal.NewRegressionTestRunnerFromPackageList(packages, apkRepo, repoPath, repoType, concurrency, verbose, hangTimeout, markdownOutput)
+		if err := configureRunner(runner); err != nil {
+			return err
+		}
+		runner.SetConfigLocator(locator)
+		runner.SetPackageOptions(options)
+		stop, err := controlRun(runner)
+		if err != nil {
+			return err
+		}
+		defer stop()
 		return runner.RunFromPackageList(packages)
 	} else {
-		// Single package mode: find reverse dependencies and test them
+		// Package mode: find reverse dependencies and test them, those of
+		// several packages together
+		for _, target := range targets {
+			if err := checkCandidateRepo(target); err != nil {
+				return err
+			}
+		}
 		runner := internal.NewRegressionTestRunner(packageName, apkRepo, repoPath, repoType, concurrency, verbose, hangTimeout, markdownOutput)
+		if err := configureRunner(runner); err != nil {
+			return err
+		}
+		runner.SetConfigLocator(locator)
+		sbomPackages, err := loadSBOMPackages(sbomFiles, sbomImages)
+		if err != nil {
+			return err
+		}
+		if len(sbomPackages) > 0 {
+			runner.SetSBOMPackages(sbomPackages, sbomMode == "restrict")
+		}
+		stop, err := controlRun(runner)
+		if err != nil {
+			return err
+		}
+		defer stop()
+		if len(targets) > 1 {
+			return runner.RunTargets(targets)
+		}
 		return runner.Run()
 	}
 }
 
-func readPackageFile(filename string) ([]string, error) {
+// resolveRepoPaths splits a comma-separated --repo-path into absolute paths
+// and checks that each exists
+func resolveRepoPaths(value string) ([]string, error) {
+	var paths []string
+	for _, path := range strings.Split(value, ",") {
+		if !filepath.IsAbs(path) {
+			absPath, err := filepath.Abs(path)
+			if err != nil {
+				return nil, fmt.Errorf("failed to resolve repository path: %w", err)
+			}
+			path = absPath
+		}
+
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			return nil, fmt.Errorf("repository path does not exist: %s", path)
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+// isolateRepoPaths replaces each repository path with a temporary worktree
+// at --git-ref (or HEAD with --isolate-worktree), so that the tested configs
+// can't change during the run and concurrent runs or edits in the checkout
+// don't interfere. Paths tested in place are locked instead (see
+// lockRepoPaths). The returned function removes the worktrees or releases
+// the locks.
+func isolateRepoPaths(paths []string) ([]string, func(), error) {
+	var worktrees []*internal.Worktree
+	cleanup := func() {
+		for _, worktree := range worktrees {
+			if err := worktree.Remove(); err != nil {
+				fmt.Printf("Warning: %v\n", err)
+			}
+		}
+	}
+	if gitRef == "" && !isolateTree {
+		unlock, err := lockRepoPaths(paths)
+		if err != nil {
+			return nil, nil, err
+		}
+		return paths, unlock, nil
+	}
+
+	ref := gitRef
+	if ref == "" {
+		ref = "HEAD"
+	}
+
+	isolated := make([]string, len(paths))
+	for i, path := range paths {
+		worktree, err := internal.NewWorktree(path, ref)
+		if err != nil {
+			cleanup()
+			return nil, nil, err
+		}
+		worktrees = append(worktrees, worktree)
+		isolated[i] = worktree.Path
+		fmt.Printf("Testing %s at %s (%s) in worktree %s\n", path, ref, internal.GitCommit(worktree.Path), worktree.Path)
+	}
+	return isolated, cleanup, nil
+}
+
+// lockRepoPaths locks each repository path against other runs, unless
+// --no-lock is given. The returned function releases the locks.
+func lockRepoPaths(paths []string) (func(), error) {
+	var locks []*internal.RepoLock
+	unlock := func() {
+		for _, lock := range locks {
+			lock.Unlock()
+		}
+	}
+	if noLock {
+		return unlock, nil
+	}
+
+	locked := make(map[string]bool)
+	for _, path := range paths {
+		// Repository types may share a path
+		if locked[path] {
+			continue
+		}
+		lock, err := internal.LockRepoPath(path)
+		if err != nil {
+			unlock()
+			return nil, err
+		}
+		locked[path] = true
+		locks = append(locks, lock)
+	}
+	return unlock, nil
+}
+
+// loadMatrix returns the variants of --matrix in the config file
+func loadMatrix() ([]internal.Variant, error) {
+	path := configPath
+	if path == "" {
+		var err error
+		if path, err = internal.DefaultConfigPath(); err != nil {
+			return nil, err
+		}
+	}
+	return internal.LoadMatrix(path, matrixName)
+}
+
+// loadFailureBudget returns --failure-budget from the config file
+func loadFailureBudget() (*internal.FailureBudget, error) {
+	path := configPath
+	if path == "" {
+		var err error
+		if path, err = internal.DefaultConfigPath(); err != nil {
+			return nil, err
+		}
+	}
+	return internal.LoadFailureBudget(path, failureBudget)
+}
+
+// runMatrix tests the reverse dependencies of --package in every repository
+// type and variant of --matrix, if given, and merges the reports. A single
+// repository path is shared by all types; otherwise paths and types are
+// paired in order.
+func runMatrix(repoTypes, repoPaths []string, variants []internal.Variant) error {
+	sbomPackages, err := loadSBOMPackages(sbomFiles, sbomImages)
+	if err != nil {
+		return err
+	}
+
+	var runners []*internal.RegressionTestRunner
+	for i, t := range repoTypes {
+		path := repoPaths[0]
+		if len(repoPaths) > 1 {
+			path = repoPaths[i]
+		}
+
+		locator, err := internal.NewConfigLocator(path, yamlLayout)
+		if err != nil {
+			return err
+		}
+
+		// Without a matrix, each repository type is tested once
+		runnerVariants := []*internal.Variant{nil}
+		if len(variants) > 0 {
+			runnerVariants = runnerVariants[:0]
+			for i := range variants {
+				runnerVariants = append(runnerVariants, &variants[i])
+			}
+		}
+		for _, variant := range runnerVariants {
+			runner := internal.NewRegressionTestRunner(packageName, apkRepo, path, t, concurrency, verbose, hangTimeout, markdownOutput)
+			if err := configureRunner(runner); err != nil {
+				return err
+			}
+			runner.SetConfigLocator(locator)
+			if len(sbomPackages) > 0 {
+				runner.SetSBOMPackages(sbomPackages, sbomMode == "restrict")
+			}
+			if variant != nil {
+				runner.SetVariant(*variant)
+			}
+			runners = append(runners, runner)
+		}
+	}
+
+	// Fetch the indexes of all repository types and the candidate repository
+	// at once; validation and discovery then use the loaded indexes
+	internal.PrefetchIndexes(runners)
+	if err := checkCandidateRepo(packageName); err != nil {
+		return err
+	}
+	checked := map[string]bool{apkRepo: true}
+	for _, variant := range variants {
+		if variant.Repo == "" || checked[variant.Repo] {
+			continue
+		}
+		checked[variant.Repo] = true
+		if err := checkCandidateRepoAt(variant.Repo, packageName); err != nil {
+			return fmt.Errorf("variant %s: %w", variant.Name, err)
+		}
+	}
+
+	stop, err := controlRun(runners...)
+	if err != nil {
+		return err
+	}
+	defer stop()
+	return internal.NewMatrixRunner(packageName, apkRepo, runners, markdownOutput).Run()
+}
+
+// controlRun lets the run of runners be paused with SIGUSR1 and resumed with
+// SIGUSR2, and serves the control API on --control-socket, if given. The
+// returned function stops both.
+func controlRun(runners ...*internal.RegressionTestRunner) (func(), error) {
+	stopSignals := internal.WatchPauseSignals(runners)
+	if controlSocket == "" {
+		return stopSignals, nil
+	}
+
+	stopServing, err := internal.ServeControl(controlSocket, runners)
+	if err != nil {
+		stopSignals()
+		return nil, err
+	}
+	return func() {
+		stopServing()
+		stopSignals()
+	}, nil
+}
+
+// checkCandidateRepo validates the candidate repository unless
+// --skip-repo-check is set. The index must list target, if given, at
+// --expect-version, or exactly at the version of --package name@version.
+func checkCandidateRepo(target string) error {
+	return checkCandidateRepoAt(apkRepo, target)
+}
+
+// checkCandidateRepoAt is checkCandidateRepo for the candidate repository
+// repo
+func checkCandidateRepoAt(repo, target string) error {
+	if skipRepoCheck {
+		return nil
+	}
+	keys := candidateKeys()
+	if len(keys) == 0 && (verifyIndex || len(indexKeys) > 0) {
+		return fmt.Errorf("candidate repository check failed: %w: no signing key is known for %s; pass --repo-key", internal.ErrIndexSignature, repo)
+	}
+	version := expectVersion
+	if targetVersion != "" {
+		version = targetVersion
+	}
+	if err := internal.ValidateCandidateRepo(repo, keys, target, version, verbose); err != nil {
+		return fmt.Errorf("candidate repository check failed: %w", err)
+	}
+	return nil
+}
+
+// candidateKeys returns the keys the candidate repository index must be
+// signed with: --repo-key, or else with --verify-index the keys of the
+// package index
+func candidateKeys() []string {
+	if len(repoKeys) > 0 || !(verifyIndex || len(indexKeys) > 0) {
+		return repoKeys
+	}
+	if len(indexKeys) > 0 {
+		return indexKeys
+	}
+	var keys []string
+	for _, t := range strings.Split(repoType, ",") {
+		keys = append(keys, internal.DefaultIndexKeys(t)...)
+	}
+	return keys
+}
+
+// checkPlatform refuses to run on hosts that can't run melange tests, with
+// guidance on where to run instead, and selects the melange runner
+func checkPlatform() error {
+	runner, err := internal.CheckPlatform()
+	if err != nil {
+		return fmt.Errorf("unsupported platform: %w", err)
+	}
+	if runner != "" && runner != melangeRunner {
+		fmt.Printf("Running melange tests with the %s runner on %s\n", runner, internal.DetectPlatform())
+	}
+	melangeRunner = runner
+	return nil
+}
+
+// checkPipelineFilter makes sure the installed melange can filter test
+// pipelines, since it would otherwise fail every test on the unknown option
+func checkPipelineFilter() error {
+	for flag, values := range map[string][]string{"test-pipeline-only": pipelineOnly, "test-pipeline-skip": pipelineSkip} {
+		if len(values) == 0 {
+			continue
+		}
+		for _, value := range values {
+			// Options reach melange through MELANGE_EXTRA_OPTS, split on spaces
+			if strings.ContainsAny(value, " \t") {
+				return fmt.Errorf("invalid --%s %q: pipeline names can't contain whitespace", flag, value)
+			}
+		}
+		if err := checkMelangeOption(flag, flag); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkMelangeOption makes sure the test command of the installed melange
+// has option, which flag needs
+func checkMelangeOption(option, flag string) error {
+	supported, err := internal.MelangeTestSupports("--" + option)
+	if err != nil {
+		return err
+	}
+	if !supported {
+		return fmt.Errorf("--%s requires a melange whose test command supports --%s; update melange or drop --%s", flag, option, flag)
+	}
+	return nil
+}
+
+// configureNetwork applies the proxy and mirror flags before anything is
+// fetched
+func configureNetwork() error {
+	cfg := internal.NetworkConfig{
+		HTTPProxy:  httpProxy,
+		HTTPSProxy: httpsProxy,
+		NoProxy:    noProxy,
+	}
+	for _, flag := range mirrorFlags {
+		mirror, err := internal.ParseMirror(flag)
+		if err != nil {
+			return err
+		}
+		cfg.Mirrors = append(cfg.Mirrors, mirror)
+	}
+	if credsFile != "" {
+		creds, err := internal.LoadCredentials(credsFile)
+		if err != nil {
+			return err
+		}
+		cfg.Credentials = creds
+	}
+	return internal.ConfigureNetwork(cfg)
+}
+
+// currentInvocation describes how apkregress was started, for the run
+// manifest
+func currentInvocation() internal.Invocation {
+	invocation := internal.Invocation{
+		Args:  os.Args[1:],
+		Flags: make(map[string]string),
+	}
+	invocation.WorkDir, _ = os.Getwd()
+	sharedFlags.VisitAll(func(f *pflag.Flag) {
+		invocation.Flags[f.Name] = f.Value.String()
+	})
+	return invocation
+}
+
+// configureRunner applies the settings shared by all run modes
+func configureRunner(runner *internal.RegressionTestRunner) error {
+	if !noIndexCache {
+		cache, err := internal.NewIndexCache(cacheDir)
+		if err != nil {
+			return err
+		}
+		runner.SetIndexCache(cache)
+	}
+
+	if verifyIndex || len(indexKeys) > 0 {
+		if skipRepoCheck {
+			return fmt.Errorf("--verify-index can't be combined with --skip-repo-check")
+		}
+		runner.SetIndexVerification(indexKeys)
+	}
+
+	if !noResultsDB {
+		db, err := internal.NewResultsDB(resultsDBPath)
+		if err != nil {
+			return err
+		}
+		runner.SetResultsDB(db)
+	}
+
+	if heartbeatURL != "" {
+		runner.SetHeartbeat(heartbeatURL, heartbeatEvery)
+	}
+
+	if apkCacheDir != "" {
+		if err := runner.SetAPKCache(apkCacheDir); err != nil {
+			return err
+		}
+	}
+
+	if buildCacheDir != "" {
+		if err := runner.SetBuildCache(buildCacheDir); err != nil {
+			return err
+		}
+	}
+
+	if previousRun != "" {
+		runner.SetPreviousResults(previousRun)
+	}
+
+	if err := runner.SetCandidateChange(candidateMode); err != nil {
+		return fmt.Errorf("invalid --candidate-change: %w", err)
+	}
+
+	if gatePath != "" {
+		runner.SetGate(gatePath)
+	}
+
+	if failureBudget != "" {
+		if gatePath != "" {
+			return fmt.Errorf("--failure-budget can't be combined with --gate")
+		}
+		budget, err := loadFailureBudget()
+		if err != nil {
+			return err
+		}
+		runner.SetFailureBudget(budget)
+	}
+
+	if logURL != "" {
+		runner.SetLogURL(logURL)
+	}
+
+	if err := runner.SetExclude(excludes); err != nil {
+		return fmt.Errorf("invalid --exclude: %w", err)
+	}
+	ignored, err := runner.LoadIgnoreFile()
+	if err != nil {
+		return err
+	}
+	if len(ignored) > 0 && verbose {
+		fmt.Printf("Ignoring packages matching %s from %s\n", strings.Join(ignored, ", "), internal.IgnoreFile)
+	}
+	runner.SetFollow(follow)
+
+	runNotes, err := operatorNotes()
+	if err != nil {
+		return err
+	}
+	runner.SetNotes(runNotes)
+
+	runner.SetSkipUnchanged(skipUnchanged)
+	runner.SetInfraBackoff(!noInfraBackoff)
+	runner.SetForce(force)
+	runner.SetHooks(internal.Hooks{PreRun: preHook, PostRun: postHook, PreTest: preTestHook, PostTest: postTestHook})
+	for _, spec := range processorSpecs {
+		processor, err := internal.NewResultProcessor(spec)
+		if err != nil {
+			return fmt.Errorf("invalid --result-processor: %w", err)
+		}
+		runner.AddResultProcessor(processor)
+	}
+	runner.SetArtifactCollection(collectArts)
+	runner.SetWorkspaceSnapshots(snapshotRegs)
+	runner.SetTestPrevious(testPrevious)
+	runner.SetFailOnEmpty(failOnEmpty)
+
+	if melangeRunner != "" {
+		runner.SetMelangeRunner(melangeRunner)
+	}
+
+	if len(pipelineOnly) > 0 || len(pipelineSkip) > 0 {
+		if err := checkPipelineFilter(); err != nil {
+			return err
+		}
+		runner.SetPipelineFilter(pipelineOnly, pipelineSkip)
+	}
+
+	if testCommand != "" {
+		if err := runner.SetTestCommand(testCommand); err != nil {
+			return fmt.Errorf("invalid --test-command: %w", err)
+		}
+	}
+
+	if pinsFile != "" {
+		pins, err := internal.LoadPins(pinsFile)
+		if err != nil {
+			return fmt.Errorf("invalid --pins: %w", err)
+		}
+		if err := checkMelangeOption("test-package-append", "pins"); err != nil {
+			return err
+		}
+		runner.SetPins(pins)
+	}
+
+	if twoPhase {
+		// The smoke tests skip every test pipeline
+		if err := checkMelangeOption("test-pipeline-skip", "two-phase"); err != nil {
+			return err
+		}
+		runner.SetTwoPhase(true)
+	}
+
+	if githubSummary {
+		runner.SetGitHubActions(internal.GitHubActionsFromEnv())
+	}
+	runner.SetSummaryJSON(summaryJSON)
+	dir := resultsDir
+	if dir != "" {
+		var err error
+		if dir, err = filepath.Abs(dir); err != nil {
+			return err
+		}
+	}
+	files, err := internal.NewResultFiles(resultFiles, resultFormats, dir)
+	if err != nil {
+		return err
+	}
+	runner.SetResultFiles(files)
+	runner.SetLogCompression(compressLogs)
+	runner.SetArtifactLogDir(ciMode)
+
+	runner.SetContext(runContext)
+	runner.SetWatchdog(watchdogAfter, func() {
+		fmt.Fprintf(os.Stderr, "Stopping the wedged run\n")
+		stopRun(errWedged)
+	})
+
+	runner.SetPackageBudget(maxPackages, confirmLargeRun)
+	if chunkSize < 0 {
+		return fmt.Errorf("--chunk-size must not be negative, got %d", chunkSize)
+	}
+	runner.SetChunkSize(chunkSize)
+
+	if depth < 1 {
+		return fmt.Errorf("--depth must be at least 1, got %d", depth)
+	}
+	runner.SetDepth(depth)
+	runner.SetPruneOnRegression(pruneOnRegress)
+	if err := runner.SetDepKind(depKind); err != nil {
+		return fmt.Errorf("invalid --dep-kind: %w", err)
+	}
+
+	runner.SetTempRoot(tmpRoot)
+	runner.SetCleanLeaks(cleanLeaks)
+	runner.SetAdvisoryCheck(!noAdvisories)
+	runner.SetInvocation(currentInvocation())
+	runner.SetHealth(health)
+	runner.SetTargetVersion(targetVersion)
+	return nil
+}
+
+// cleanupOnSignal stops the run when the process is interrupted or
+// terminated, e.g. by a container stop: the run asks its tests to stop,
+// kills those still running after a grace period and reports the results
+// so far. If a stopped run, also one the watchdog stopped, doesn't return
+// within stopTimeout, or a second signal arrives, the tests in progress are
+// killed, the temporary directories of the runs removed and cleanup called
+// before exiting, since deferred cleanups don't run then. It returns a
+// function to stop handling the signals.
+func cleanupOnSignal(cleanup func()) func() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	done := make(chan struct{})
+	go func() {
+		select {
+		case sig := <-signals:
+			health.SetStopping()
+			fmt.Fprintf(os.Stderr, "\nReceived %v, stopping the running tests and writing the results so far; send it again to stop at once\n", sig)
+			stopRun(&signalError{sig})
+		case <-runContext.Done():
+			health.SetStopping()
+		case <-done:
+			return
+		}
+
+		timer := time.NewTimer(stopTimeout)
+		defer timer.Stop()
+		select {
+		case sig := <-signals:
+			fmt.Fprintf(os.Stderr, "\nReceived %v again\n", sig)
+		case <-timer.C:
+			fmt.Fprintf(os.Stderr, "\nThe run didn't stop within %v\n", stopTimeout)
+		case <-done:
+			return
+		}
+		fmt.Fprintf(os.Stderr, "Killing %d running tests and removing temporary directories\n", internal.KillRunningTests())
+		internal.CleanupTempDirs()
+		cleanup()
+		os.Exit(ExitCode(context.Cause(runContext)))
+	}()
+	return func() {
+		signal.Stop(signals)
+		close(done)
+	}
+}
+
+// confirmLargeRun asks whether to test more than --max-packages packages,
+// unless --yes was given. Without a terminal to ask on, the run is refused.
+func confirmLargeRun(count int, estimate time.Duration) bool {
+	if assumeYes {
+		return true
+	}
+	if info, err := os.Stdin.Stat(); err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return false
+	}
+	return promptYes(os.Stdin, os.Stdout, fmt.Sprintf("Test all %d packages (about %v)? [y/N] ", count, estimate.Round(time.Minute)))
+}
+
+// stdinNotes is the note read from stdin for --note -, which can only be
+// read once however many runners are configured
+var stdinNotes *string
+
+// operatorNotes returns the --note values, with - replaced by what stdin
+// holds
+func operatorNotes() ([]string, error) {
+	var result []string
+	for _, note := range notes {
+		if note == "-" {
+			if stdinNotes == nil {
+				text, err := readNote(os.Stdin)
+				if err != nil {
+					return nil, err
+				}
+				stdinNotes = &text
+			}
+			note = *stdinNotes
+		}
+		if note = strings.TrimSpace(note); note != "" {
+			result = append(result, note)
+		}
+	}
+	return result, nil
+}
+
+// readNote reads a note from in, e.g. piped in from a file or an editor
+func readNote(in io.Reader) (string, error) {
+	data, err := io.ReadAll(in)
+	if err != nil {
+		return "", fmt.Errorf("failed to read --note from stdin: %w", err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// promptYes asks question on out and tells whether the answer read from in
+// is yes
+func promptYes(in io.Reader, out io.Writer, question string) bool {
+	fmt.Fprint(out, question)
+	answer, _ := bufio.NewReader(in).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true
+	}
+	return false
+}
+
+// readPackageSpecs returns the packages selected by --package-file. A
+// directory selects every package defined in it; otherwise the file is read
+// and glob entries are expanded against the packages the locator knows about.
+func readPackageSpecs(packageFile string, locator internal.ConfigLocator) ([]internal.PackageSpec, error) {
+	if info, err := os.Stat(packageFile); err == nil && info.IsDir() {
+		names, err := internal.ListPackageYAMLs(packageFile)
+		if err != nil {
+			return nil, err
+		}
+		if len(names) == 0 {
+			return nil, fmt.Errorf("no package YAML files found in directory %s", packageFile)
+		}
+		specs := make([]internal.PackageSpec, 0, len(names))
+		for _, name := range names {
+			specs = append(specs, internal.PackageSpec{Name: name})
+		}
+		return specs, nil
+	}
+
+	specs, err := readPackageFile(packageFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read package file: %w", err)
+	}
+
+	available, err := locator.Packages()
+	if err != nil {
+		return nil, err
+	}
+
+	specs, unmatched, err := internal.ExpandPackageGlobs(specs, available)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", packageFile, err)
+	}
+	for _, pattern := range unmatched {
+		fmt.Printf("Warning: package pattern %s does not match any package\n", pattern)
+	}
+	if len(specs) == 0 {
+		return nil, fmt.Errorf("no packages matched in file %s", packageFile)
+	}
+
+	return specs, nil
+}
+
+// readPackageFile reads package entries, one per line, with optional inline
+// options (see internal.ParsePackageSpec). Repeated entries are dropped with a
+// warning; repeated entries with different options are an error.
+func readPackageFile(filename string) ([]internal.PackageSpec, error) {
 	file, err := os.Open(filename)
 	if err != nil {
 		return nil, err
 	}
 	defer file.Close()
 
-	var packages []string
+	var specs []internal.PackageSpec
 	scanner := bufio.NewScanner(file)
+	lineNumber := 0
 	for scanner.Scan() {
+		lineNumber++
 		line := strings.TrimSpace(scanner.Text())
-		if line != "" && !strings.HasPrefix(line, "#") {
-			packages = append(packages, line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
 		}
+
+		spec, err := internal.ParsePackageSpec(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", filename, lineNumber, err)
+		}
+		spec.Line = lineNumber
+		specs = append(specs, spec)
 	}
 
 	if err := scanner.Err(); err != nil {
 		return nil, err
 	}
 
-	if len(packages) == 0 {
+	if len(specs) == 0 {
 		return nil, fmt.Errorf("no packages found in file %s", filename)
 	}
 
+	specs, duplicates, err := internal.DedupePackageSpecs(specs)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	for _, dup := range duplicates {
+		fmt.Printf("Warning: ignoring duplicate entry for %s at %s:%d\n", dup.Name, filename, dup.Line)
+	}
+
+	return specs, nil
+}
+
+// loadSBOMPackages collects the package names listed in the given SBOM files
+// and image SBOM attestations.
+func loadSBOMPackages(files, images []string) ([]string, error) {
+	var packages []string
+	for _, file := range files {
+		names, err := internal.LoadSBOMPackages(file)
+		if err != nil {
+			return nil, err
+		}
+		packages = append(packages, names...)
+	}
+	for _, image := range images {
+		names, err := internal.FetchImageSBOMPackages(image, verbose)
+		if err != nil {
+			return nil, err
+		}
+		packages = append(packages, names...)
+	}
 	return packages, nil
 }
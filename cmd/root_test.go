@@ -4,11 +4,18 @@
 package cmd
 
 import (
+	"fmt"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"reflect"
 	"strings"
+	"syscall"
 	"testing"
 	"time"
+
+	"github.com/chainguard-dev/apkregress/internal"
+	"github.com/spf13/pflag"
 )
 
 func TestReadPackageFile(t *testing.T) {
@@ -98,8 +105,8 @@ package3
 					t.Errorf("Expected %d packages, got %d", len(tt.expectedPkgs), len(packages))
 				}
 				for i, pkg := range packages {
-					if i < len(tt.expectedPkgs) && pkg != tt.expectedPkgs[i] {
-						t.Errorf("Expected package %d to be '%s', got '%s'", i, tt.expectedPkgs[i], pkg)
+					if i < len(tt.expectedPkgs) && pkg.Name != tt.expectedPkgs[i] {
+						t.Errorf("Expected package %d to be '%s', got '%s'", i, tt.expectedPkgs[i], pkg.Name)
 					}
 				}
 			}
@@ -107,6 +114,112 @@ package3
 	}
 }
 
+func TestReadPackageFileOptionsAndDuplicates(t *testing.T) {
+	tests := []struct {
+		name          string
+		fileContent   string
+		expectedPkgs  []string
+		expectedError string
+	}{
+		{
+			name: "duplicates are dropped",
+			fileContent: `curl
+git
+curl`,
+			expectedPkgs: []string{"curl", "git"},
+		},
+		{
+			name: "inline options",
+			fileContent: `llvm timeout=2h skip-without-repo
+curl # inline comment`,
+			expectedPkgs: []string{"llvm", "curl"},
+		},
+		{
+			name: "conflicting duplicates",
+			fileContent: `llvm timeout=2h
+llvm timeout=3h`,
+			expectedError: "conflicting entries for package llvm (lines 1 and 2)",
+		},
+		{
+			name:          "unknown option",
+			fileContent:   `llvm retries=3`,
+			expectedError: ":1: unknown option",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "packages.txt")
+			if err := os.WriteFile(path, []byte(tt.fileContent), 0644); err != nil {
+				t.Fatalf("Failed to write package file: %v", err)
+			}
+
+			specs, err := readPackageFile(path)
+			if tt.expectedError != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.expectedError) {
+					t.Errorf("Expected error containing '%s', got: %v", tt.expectedError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			var names []string
+			for _, spec := range specs {
+				names = append(names, spec.Name)
+			}
+			if strings.Join(names, ",") != strings.Join(tt.expectedPkgs, ",") {
+				t.Errorf("Expected packages %v, got %v", tt.expectedPkgs, names)
+			}
+		})
+	}
+}
+
+func TestReadPackageSpecs(t *testing.T) {
+	repoDir := t.TempDir()
+	for _, name := range []string{"curl", "py3-requests", "py3-urllib3"} {
+		if err := os.WriteFile(filepath.Join(repoDir, name+".yaml"), []byte("test"), 0644); err != nil {
+			t.Fatalf("Failed to write YAML: %v", err)
+		}
+	}
+
+	locator, err := internal.NewConfigLocator(repoDir, "flat")
+	if err != nil {
+		t.Fatalf("Failed to create locator: %v", err)
+	}
+
+	// A directory selects every package defined in it
+	specs, err := readPackageSpecs(repoDir, locator)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(specs) != 3 {
+		t.Errorf("Expected 3 packages from directory, got %d", len(specs))
+	}
+
+	// Globs are expanded against the repository
+	path := filepath.Join(t.TempDir(), "packages.txt")
+	if err := os.WriteFile(path, []byte("py3-* timeout=1h\n"), 0644); err != nil {
+		t.Fatalf("Failed to write package file: %v", err)
+	}
+	specs, err = readPackageSpecs(path, locator)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(specs) != 2 || specs[0].Name != "py3-requests" || specs[1].Name != "py3-urllib3" || specs[1].Options.Timeout != time.Hour {
+		t.Errorf("Unexpected expanded packages: %+v", specs)
+	}
+
+	// Patterns that match nothing leave no packages to test
+	if err := os.WriteFile(path, []byte("ruby-*\n"), 0644); err != nil {
+		t.Fatalf("Failed to write package file: %v", err)
+	}
+	if _, err := readPackageSpecs(path, locator); err == nil {
+		t.Error("Expected error when no packages match")
+	}
+}
+
 func TestReadPackageFileNonExistent(t *testing.T) {
 	_, err := readPackageFile("/nonexistent/file.txt")
 	if err == nil {
@@ -147,7 +260,7 @@ func TestRunRegressionTestValidation(t *testing.T) {
 			apkRepo:       "http://example.com",
 			repoPath:      "/tmp",
 			repoType:      "wolfi",
-			expectedError: "either --package or --package-file must be specified",
+			expectedError: "either --package, --package-file or --apko-configs must be specified",
 		},
 		{
 			name:          "both package and package file specified",
@@ -167,6 +280,24 @@ func TestRunRegressionTestValidation(t *testing.T) {
 			repoType:      "wolfi",
 			expectedError: "repository path does not exist",
 		},
+		{
+			name:          "missing repo",
+			packageName:   "test-pkg",
+			packageFile:   "",
+			apkRepo:       "",
+			repoPath:      "/tmp",
+			repoType:      "wolfi",
+			expectedError: "--repo is required",
+		},
+		{
+			name:          "missing repo path",
+			packageName:   "test-pkg",
+			packageFile:   "",
+			apkRepo:       "http://example.com",
+			repoPath:      "",
+			repoType:      "wolfi",
+			expectedError: "--repo-path is required",
+		},
 		{
 			name:          "invalid repo type",
 			packageName:   "test-pkg",
@@ -312,4 +443,456 @@ func TestCommandStructure(t *testing.T) {
 	if cmd.RunE == nil {
 		t.Error("Expected command to have a RunE function")
 	}
-}
\ No newline at end of file
+}
+func TestRunRegressionTestApkoConfigValidation(t *testing.T) {
+	origPackageName := packageName
+	origPackageFile := packageFile
+	origApkoConfigDir := apkoConfigDir
+	origApkRepo := apkRepo
+	origRepoPath := repoPath
+
+	defer func() {
+		packageName = origPackageName
+		packageFile = origPackageFile
+		apkoConfigDir = origApkoConfigDir
+		apkRepo = origApkRepo
+		repoPath = origRepoPath
+	}()
+
+	packageName = "test-pkg"
+	packageFile = ""
+	apkoConfigDir = "/tmp"
+	apkRepo = "http://example.com"
+	repoPath = "/tmp"
+
+	err := runRegressionTest(nil, nil)
+	if err == nil || !strings.Contains(err.Error(), "cannot combine --apko-configs") {
+		t.Errorf("Expected apko config conflict error, got: %v", err)
+	}
+
+	packageName = ""
+	apkoConfigDir = "/nonexistent/apko"
+
+	err = runRegressionTest(nil, nil)
+	if err == nil || !strings.Contains(err.Error(), "apko config directory does not exist") {
+		t.Errorf("Expected missing apko config directory error, got: %v", err)
+	}
+}
+
+func TestRunRegressionTestMultipleRepoTypes(t *testing.T) {
+	origPackageName := packageName
+	origPackageFile := packageFile
+	origApkoConfigDir := apkoConfigDir
+	origRepoPath := repoPath
+	origRepoType := repoType
+
+	defer func() {
+		packageName = origPackageName
+		packageFile = origPackageFile
+		apkoConfigDir = origApkoConfigDir
+		repoPath = origRepoPath
+		repoType = origRepoType
+	}()
+
+	origApkRepo := apkRepo
+	defer func() { apkRepo = origApkRepo }()
+
+	tmpDir := t.TempDir()
+	apkoConfigDir = ""
+	apkRepo = "http://example.com"
+
+	tests := []struct {
+		name          string
+		packageName   string
+		packageFile   string
+		repoPath      string
+		repoType      string
+		expectedError string
+	}{
+		{
+			name:          "invalid type in list",
+			packageName:   "test-pkg",
+			repoPath:      tmpDir,
+			repoType:      "wolfi,invalid",
+			expectedError: "invalid repository type: invalid",
+		},
+		{
+			name:          "path and type counts differ",
+			packageName:   "test-pkg",
+			repoPath:      tmpDir + "," + tmpDir,
+			repoType:      "wolfi,enterprise,extras",
+			expectedError: "--repo-path lists 2 paths but --repo-type lists 3 types",
+		},
+		{
+			name:          "missing path in list",
+			packageName:   "test-pkg",
+			repoPath:      tmpDir + ",/nonexistent/path",
+			repoType:      "wolfi,enterprise",
+			expectedError: "repository path does not exist: /nonexistent/path",
+		},
+		{
+			name:          "multiple types with package file",
+			packageFile:   "/tmp/packages.txt",
+			repoPath:      tmpDir,
+			repoType:      "wolfi,enterprise",
+			expectedError: "multiple repository types are only supported with --package",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			packageName = tt.packageName
+			packageFile = tt.packageFile
+			repoPath = tt.repoPath
+			repoType = tt.repoType
+
+			err := runRegressionTest(nil, nil)
+			if err == nil || !strings.Contains(err.Error(), tt.expectedError) {
+				t.Errorf("Expected error to contain '%s', got: %v", tt.expectedError, err)
+			}
+		})
+	}
+}
+
+func TestRunRegressionTestExpectVersionRequiresPackage(t *testing.T) {
+	origPackageName := packageName
+	origPackageFile := packageFile
+	origRepoPath := repoPath
+	origRepoType := repoType
+	origExpectVersion := expectVersion
+
+	defer func() {
+		packageName = origPackageName
+		packageFile = origPackageFile
+		repoPath = origRepoPath
+		repoType = origRepoType
+		expectVersion = origExpectVersion
+	}()
+
+	origApkRepo := apkRepo
+	defer func() { apkRepo = origApkRepo }()
+
+	packageName = ""
+	packageFile = "/tmp/packages.txt"
+	apkRepo = "http://example.com"
+	repoPath = t.TempDir()
+	repoType = "wolfi"
+	expectVersion = "3.3.2"
+
+	err := runRegressionTest(nil, nil)
+	if err == nil || !strings.Contains(err.Error(), "--expect-version requires --package") {
+		t.Errorf("Expected --expect-version error, got: %v", err)
+	}
+}
+
+func TestRunRegressionTestSBOMRequiresPackage(t *testing.T) {
+	origPackageName, origPackageFile, origApkRepo, origRepoPath := packageName, packageFile, apkRepo, repoPath
+	origSBOMFiles := sbomFiles
+	defer func() {
+		packageName, packageFile, apkRepo, repoPath = origPackageName, origPackageFile, origApkRepo, origRepoPath
+		sbomFiles = origSBOMFiles
+	}()
+	packageName = ""
+	packageFile = "/tmp/packages.txt"
+	apkRepo = "http://example.com"
+	repoPath = t.TempDir()
+	sbomFiles = []string{"sbom.json"}
+
+	err := runRegressionTest(nil, nil)
+	if err == nil || !strings.Contains(err.Error(), "--sbom and --sbom-image only filter the reverse dependencies of --package") {
+		t.Errorf("Expected --sbom error, got: %v", err)
+	}
+}
+
+func TestRunRegressionTestPackageVersion(t *testing.T) {
+	origPackageName, origApkRepo, origRepoPath := packageName, apkRepo, repoPath
+	origExpectVersion, origTargetVersion := expectVersion, targetVersion
+	defer func() {
+		packageName, apkRepo, repoPath = origPackageName, origApkRepo, origRepoPath
+		expectVersion, targetVersion = origExpectVersion, origTargetVersion
+	}()
+	apkRepo = "http://example.com"
+	repoPath = t.TempDir()
+
+	tests := []struct {
+		name          string
+		packageName   string
+		expectVersion string
+		expectedError string
+	}{
+		{"several packages", "openssl@3.3.2-r1,curl", "", "a version (name@version) can only be given with a single --package"},
+		{"with expect-version", "openssl@3.3.2-r1", "3.3.2", "cannot combine --package name@version with --expect-version"},
+		{"without release", "openssl@3.3.2", "", "the version must include the release"},
+		{"path in name", "../openssl", "", "invalid --package: invalid package name"},
+		{"path in versioned name", "sub/openssl@3.3.2-r1", "", "invalid --package: invalid package name"},
+		{"empty name in list", "openssl,,curl", "", "invalid --package: empty package name"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			packageName, expectVersion = tt.packageName, tt.expectVersion
+			err := runRegressionTest(nil, nil)
+			if err == nil || !strings.Contains(err.Error(), tt.expectedError) {
+				t.Errorf("Expected error to contain '%s', got: %v", tt.expectedError, err)
+			}
+		})
+	}
+}
+
+func TestIsolateRepoPaths(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+	origGitRef, origIsolate := gitRef, isolateTree
+	defer func() { gitRef, isolateTree = origGitRef, origIsolate }()
+
+	repo := t.TempDir()
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"commit", "-q", "--allow-empty", "-m", "initial"},
+	} {
+		cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		cmd.Dir = repo
+		if output, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %v\n%s", args, err, output)
+		}
+	}
+	// Uncommitted edits stay out of the isolated worktree
+	if err := os.WriteFile(filepath.Join(repo, "curl.yaml"), []byte("package:\n  name: curl\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	gitRef, isolateTree = "", false
+	paths, cleanup, err := isolateRepoPaths([]string{repo})
+	if err != nil || len(paths) != 1 || paths[0] != repo {
+		t.Errorf("Expected the checkout to be used as is, got %v (%v)", paths, err)
+	}
+	cleanup()
+
+	isolateTree = true
+	paths, cleanup, err = isolateRepoPaths([]string{repo})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if paths[0] == repo {
+		t.Fatal("Expected a worktree")
+	}
+	if internal.GitCommit(paths[0]) != internal.GitCommit(repo) {
+		t.Errorf("Expected the worktree at HEAD of the checkout")
+	}
+	if _, err := os.Stat(filepath.Join(paths[0], "curl.yaml")); !os.IsNotExist(err) {
+		t.Errorf("Expected uncommitted files to be left out of the worktree")
+	}
+
+	cleanup()
+	if _, err := os.Stat(paths[0]); !os.IsNotExist(err) {
+		t.Errorf("Expected the worktree to be removed")
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	if code := ExitCode(fmt.Errorf("wolfi: %w", internal.ErrNoReverseDependencies)); code != ExitEmpty {
+		t.Errorf("Expected exit status %d for an empty run, got %d", ExitEmpty, code)
+	}
+	if code := ExitCode(fmt.Errorf("wolfi: %w", internal.ErrCandidateChanged)); code != ExitCandidateChanged {
+		t.Errorf("Expected exit status %d for an aborted run, got %d", ExitCandidateChanged, code)
+	}
+	if code := ExitCode(fmt.Errorf("run stopped: %w", errWedged)); code != ExitWedged {
+		t.Errorf("Expected exit status %d for a wedged run, got %d", ExitWedged, code)
+	}
+	if code := ExitCode(fmt.Errorf("run stopped: %w", &signalError{syscall.SIGTERM})); code != 143 {
+		t.Errorf("Expected exit status 143 for a terminated run, got %d", code)
+	}
+	if code := ExitCode(fmt.Errorf("found 2 regressions")); code != 1 {
+		t.Errorf("Expected exit status 1, got %d", code)
+	}
+	if code := ExitCode(fmt.Errorf("wolfi: %w", &internal.BudgetExceededError{ExitCode: 20})); code != 20 {
+		t.Errorf("Expected the exit status of the exceeded budget rule, got %d", code)
+	}
+	if hint := ErrorHint(fmt.Errorf("found 2 regressions")); hint != "" {
+		t.Errorf("Expected no hint, got %q", hint)
+	}
+
+	// Failure budgets can't use the exit statuses of other outcomes
+	for _, code := range []int{ExitEmpty, ExitWedged, ExitCandidateChanged, ExitAuth, ExitIndexFetch, ExitUnreachable, ExitMakeMissing, ExitMelangeMissing, ExitTargetBroken, ExitIndexSignature, ExitRepoLocked} {
+		if code < internal.ReservedExitCodeMin || code > internal.ReservedExitCodeMax {
+			t.Errorf("Expected exit status %d to be reserved from failure budgets", code)
+		}
+	}
+
+	for _, setup := range setupErrors {
+		err := fmt.Errorf("failed to run apkrane ls: %w", setup.err)
+		if code := ExitCode(err); code != setup.code {
+			t.Errorf("Expected exit status %d for %v, got %d", setup.code, setup.err, code)
+		}
+		if hint := ErrorHint(err); hint != setup.hint {
+			t.Errorf("Expected hint %q for %v, got %q", setup.hint, setup.err, hint)
+		}
+	}
+
+	// Errors classified as a failed fetch due to authentication map to the
+	// authentication status
+	err := fmt.Errorf("%w: %w", internal.ErrAuth, internal.ErrIndexFetch)
+	if code := ExitCode(err); code != ExitAuth {
+		t.Errorf("Expected exit status %d, got %d", ExitAuth, code)
+	}
+}
+
+func TestApplyCIPreset(t *testing.T) {
+	restore := func() {
+		for name := range ciPreset {
+			flag := sharedFlags.Lookup(name)
+			flag.Value.Set(flag.DefValue)
+			flag.Changed = false
+		}
+		ciMode = false
+	}
+	restore()
+	defer restore()
+
+	// Explicit flags win over the preset
+	if err := sharedFlags.Set("compress-logs", "false"); err != nil {
+		t.Fatal(err)
+	}
+	ciMode = true
+	if err := applyCIPreset(nil, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if !githubSummary || !summaryJSON {
+		t.Errorf("Expected --ci to enable --github-summary and --summary-json")
+	}
+	if compressLogs {
+		t.Errorf("Expected an explicit --compress-logs=false to be kept")
+	}
+	if concurrency != internal.AutoConcurrency() {
+		t.Errorf("Expected automatic concurrency %d, got %d", internal.AutoConcurrency(), concurrency)
+	}
+	if value := sharedFlags.Lookup("concurrency").Value.String(); value != fmt.Sprint(concurrency) {
+		t.Errorf("Expected the picked concurrency to be recorded, got %s", value)
+	}
+
+	restore()
+	if err := sharedFlags.Set("concurrency", "-1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := applyCIPreset(nil, nil); err == nil {
+		t.Error("Expected a negative concurrency to be rejected")
+	}
+}
+
+func TestApplyProfile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	config := `profiles:
+  nightly:
+    hang-timeout: 10m
+    summary-json: true
+    exclude: [llvm-*, gcc]
+    note: [nightly run, "validating openssl, curl"]
+  recursive:
+    profile: nightly
+  typo:
+    hang-timeot: 10m
+`
+	if err := os.WriteFile(path, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	names := []string{"hang-timeout", "summary-json", "exclude", "note", "concurrency"}
+	restore := func() {
+		for _, name := range names {
+			flag := sharedFlags.Lookup(name)
+			if value, ok := flag.Value.(pflag.SliceValue); ok {
+				value.Replace(nil)
+			} else {
+				flag.Value.Set(flag.DefValue)
+			}
+			flag.Changed = false
+		}
+		profileName, configPath = "", ""
+	}
+	restore()
+	defer restore()
+
+	// Explicit flags win over the profile
+	if err := sharedFlags.Set("hang-timeout", "1h"); err != nil {
+		t.Fatal(err)
+	}
+	profileName, configPath = "nightly", path
+	if err := applyProfile(); err != nil {
+		t.Fatal(err)
+	}
+	if hangTimeout != time.Hour {
+		t.Errorf("Expected an explicit --hang-timeout to be kept, got %v", hangTimeout)
+	}
+	if !summaryJSON {
+		t.Errorf("Expected the profile to enable --summary-json")
+	}
+	if !reflect.DeepEqual(excludes, []string{"llvm-*", "gcc"}) {
+		t.Errorf("Expected the profile's exclusions, got %v", excludes)
+	}
+	if !reflect.DeepEqual(notes, []string{"nightly run", "validating openssl, curl"}) {
+		t.Errorf("Expected the profile's notes, got %v", notes)
+	}
+
+	for _, name := range []string{"recursive", "typo", "missing"} {
+		restore()
+		profileName, configPath = name, path
+		if err := applyProfile(); err == nil {
+			t.Errorf("Expected profile %s to be rejected", name)
+		}
+	}
+}
+
+func TestPromptYes(t *testing.T) {
+	tests := []struct {
+		answer   string
+		expected bool
+	}{
+		{"y\n", true},
+		{"Yes\n", true},
+		{"n\n", false},
+		{"\n", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		var out strings.Builder
+		if got := promptYes(strings.NewReader(tt.answer), &out, "Continue? "); got != tt.expected {
+			t.Errorf("Expected %v for %q, got %v", tt.expected, tt.answer, got)
+		}
+		if out.String() != "Continue? " {
+			t.Errorf("Expected the question, got %q", out.String())
+		}
+	}
+}
+
+func TestOperatorNotes(t *testing.T) {
+	oldNotes, oldStdin := notes, os.Stdin
+	defer func() {
+		notes, os.Stdin, stdinNotes = oldNotes, oldStdin, nil
+	}()
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.WriteString("  from stdin\nsecond line\n")
+	w.Close()
+	os.Stdin = r
+	stdinNotes = nil
+	notes = []string{"validating openssl 3.3 bump", "-", " "}
+
+	expected := []string{"validating openssl 3.3 bump", "from stdin\nsecond line"}
+	got, err := operatorNotes()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+
+	// Stdin is read once for every runner
+	if got, _ := operatorNotes(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %q again, got %q", expected, got)
+	}
+}
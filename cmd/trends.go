@@ -0,0 +1,78 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/chainguard-dev/apkregress/internal"
+	"github.com/spf13/cobra"
+)
+
+var (
+	trendsSince time.Duration
+	trendsLimit int
+	trendsCSV   string
+)
+
+var trendsCmd = &cobra.Command{
+	Use:   "trends",
+	Short: "Report per-package trends from the results database",
+	Long: `Aggregate the results database (--results-db) into per-package regression
+rates, flakiness (how often the outcome flips between runs) and average test
+durations, plus the number of regressions per week, to find chronically
+problematic consumers.`,
+	Example: `  apkregress trends --since 720h --limit 50
+  apkregress trends --csv trends.csv`,
+	RunE: runTrends,
+}
+
+func init() {
+	trendsCmd.Flags().DurationVar(&trendsSince, "since", 0, "Only include runs started within this duration, e.g. 720h (default: all runs)")
+	trendsCmd.Flags().IntVar(&trendsLimit, "limit", 20, "Number of packages to list; 0 lists all")
+	trendsCmd.Flags().StringVar(&trendsCSV, "csv", "", "Also write the per-package trends to this CSV file")
+
+	rootCmd.AddCommand(trendsCmd)
+}
+
+func runTrends(cmd *cobra.Command, args []string) error {
+	db, err := internal.NewResultsDB(resultsDBPath)
+	if err != nil {
+		return err
+	}
+	runs, err := db.Runs()
+	if err != nil {
+		return err
+	}
+
+	var since time.Time
+	if trendsSince > 0 {
+		since = time.Now().Add(-trendsSince)
+	}
+
+	trends := internal.ComputeTrends(runs, since)
+	if len(trends.Packages) == 0 {
+		fmt.Println("No results recorded in this period")
+		return nil
+	}
+
+	if err := trends.WriteTables(os.Stdout, trendsLimit); err != nil {
+		return err
+	}
+
+	if trendsCSV != "" {
+		file, err := os.Create(trendsCSV)
+		if err != nil {
+			return fmt.Errorf("failed to create %s: %w", trendsCSV, err)
+		}
+		defer file.Close()
+		if err := trends.WriteCSV(file); err != nil {
+			return fmt.Errorf("failed to write %s: %w", trendsCSV, err)
+		}
+		fmt.Printf("\nWrote %s\n", trendsCSV)
+	}
+	return nil
+}
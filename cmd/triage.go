@@ -0,0 +1,272 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/chainguard-dev/apkregress/internal"
+	"github.com/spf13/cobra"
+)
+
+var (
+	triageIssueRepo string
+	triageSteps     []string
+	// bodyOverflow is where issues and comments put what doesn't fit
+	bodyOverflow string
+)
+
+var triageCmd = &cobra.Command{
+	Use:   "triage <logdir>",
+	Short: "Walk through the regressions of a run and record verdicts",
+	Long: `Show each regression of a run with the log lines that explain the failure
+and record whether it is real, flaky or already known. Verdicts are stored
+next to the results database (--results-db) and shown when the package
+regresses again. A regression can also be re-run (see "apkregress reproduce")
+or reported as a GitHub issue with the gh CLI.`,
+	Example: `  apkregress triage logs/regression-test-openssl-20250106-120000 --issue-repo wolfi-dev/os
+  apkregress triage logs/regression-test-openssl-20250106-120000 --step 'python/*'`,
+	Args: cobra.ExactArgs(1),
+	RunE: runTriage,
+}
+
+func init() {
+	triageCmd.Flags().StringVar(&triageIssueRepo, "issue-repo", "", "GitHub repository to open issues in, e.g. wolfi-dev/os (default: the repository of the current directory)")
+	triageCmd.Flags().StringVar(&bodyOverflow, "overflow", "gist", "Where issues put log excerpts that don't fit into them: gist, log-dir (linked via --log-url) or none to cut them")
+	triageCmd.Flags().StringSliceVar(&triageSteps, "step", nil, "Only triage regressions failing in a test pipeline step matching these globs, e.g. python/* (comma-separated)")
+
+	rootCmd.AddCommand(triageCmd)
+}
+
+// triageSession walks through regressions, reading choices from in
+type triageSession struct {
+	in        *bufio.Reader
+	out       io.Writer
+	db        *internal.ResultsDB
+	manifest  *internal.RunManifest
+	decisions []internal.TriageDecision
+	// rerun re-runs a regression's tests, createIssue reports it
+	rerun       func(regression internal.Regression) error
+	createIssue func(title, body string) (string, error)
+	// overflow stores what doesn't fit into an issue
+	overflow internal.BodyOverflow
+}
+
+func runTriage(cmd *cobra.Command, args []string) error {
+	logDir := args[0]
+
+	// Runs predating run manifests can still be triaged
+	manifest, err := internal.LoadRunManifest(logDir)
+	if err != nil {
+		manifest = &internal.RunManifest{RunID: filepath.Base(logDir)}
+	}
+
+	resultsDir := manifest.ResultsDir
+	if resultsDir == "" {
+		resultsDir = logDir
+	}
+	regressions, err := internal.LoadRegressionsFrom(resultsDir, logDir, manifest.RepoType)
+	if err != nil {
+		return err
+	}
+	regressions = internal.FilterRegressionsByStep(regressions, triageSteps)
+	if len(regressions) == 0 {
+		fmt.Println("No regressions to triage")
+		return nil
+	}
+
+	db, err := internal.NewResultsDB(resultsDBPath)
+	if err != nil {
+		return err
+	}
+	decisions, err := db.TriageDecisions()
+	if err != nil {
+		return err
+	}
+
+	overflow, err := newBodyOverflow(internal.NewLogDirOverflow(logDir, logURL))
+	if err != nil {
+		return err
+	}
+
+	session := &triageSession{
+		in:        bufio.NewReader(os.Stdin),
+		out:       os.Stdout,
+		db:        db,
+		manifest:  manifest,
+		decisions: decisions,
+		rerun: func(regression internal.Regression) error {
+			return reproducePackage(filepath.Dir(regression.LogPath), regression.Package, true)
+		},
+		createIssue: func(title, body string) (string, error) {
+			return internal.CreateGitHubIssue(triageIssueRepo, title, body)
+		},
+		overflow: overflow,
+	}
+	return session.run(regressions)
+}
+
+func (s *triageSession) run(regressions []internal.Regression) error {
+	for i := 0; i < len(regressions); i++ {
+		regression := regressions[i]
+		s.show(i+1, len(regressions), regression)
+
+		for done := false; !done; {
+			fmt.Fprintf(s.out, "[r]eal, [f]laky, [k]nown, re-r[u]n, open [i]ssue, [s]kip, [q]uit > ")
+			line, err := s.in.ReadString('\n')
+			if err != nil && line == "" {
+				// End of input ends the session like quitting
+				fmt.Fprintln(s.out)
+				return nil
+			}
+
+			switch strings.TrimSpace(line) {
+			case "r":
+				done = true
+				if err := s.record(regression, internal.VerdictReal, ""); err != nil {
+					return err
+				}
+			case "f":
+				done = true
+				if err := s.record(regression, internal.VerdictFlaky, ""); err != nil {
+					return err
+				}
+			case "k":
+				done = true
+				if err := s.record(regression, internal.VerdictKnown, ""); err != nil {
+					return err
+				}
+			case "u":
+				if err := s.rerun(regression); err != nil {
+					fmt.Fprintf(s.out, "%v\n", err)
+				}
+			case "i":
+				url, err := s.createIssue(s.issue(regression))
+				if err != nil {
+					fmt.Fprintf(s.out, "%v\n", err)
+					continue
+				}
+				fmt.Fprintf(s.out, "Opened %s\n", url)
+				done = true
+				if err := s.record(regression, internal.VerdictReal, url); err != nil {
+					return err
+				}
+			case "s":
+				done = true
+			case "q":
+				return nil
+			}
+		}
+	}
+
+	fmt.Fprintf(s.out, "Triaged all %d regressions\n", len(regressions))
+	return nil
+}
+
+// show prints a regression with its previous verdict and log excerpts
+func (s *triageSession) show(n, total int, regression internal.Regression) {
+	fmt.Fprintf(s.out, "\n=== [%d/%d] %s", n, total, regression.Package)
+	if regression.RepoType != "" {
+		fmt.Fprintf(s.out, " (%s)", regression.RepoType)
+	}
+	fmt.Fprintf(s.out, " ===\nlog: %s\n", regression.LogPath)
+	if regression.FailingStep != "" {
+		fmt.Fprintf(s.out, "failing step: %s\n", regression.FailingStep)
+	}
+	if regression.ArtifactsDir != "" {
+		fmt.Fprintf(s.out, "artifacts: %s\n", regression.ArtifactsDir)
+	}
+	if regression.WorkspaceSnapshot != "" {
+		fmt.Fprintf(s.out, "workspace: %s\n", regression.WorkspaceSnapshot)
+	}
+
+	if previous := internal.LatestTriage(s.decisions, regression.Package, regression.RepoType); previous != nil {
+		fmt.Fprintf(s.out, "Previously triaged as %s in %s", previous.Verdict, previous.RunID)
+		if previous.Issue != "" {
+			fmt.Fprintf(s.out, " (%s)", previous.Issue)
+		}
+		fmt.Fprintln(s.out)
+	}
+
+	excerpts, err := internal.ExcerptLog(regression.LogPath, 3)
+	switch {
+	case err != nil:
+		fmt.Fprintf(s.out, "%v\n", err)
+	case len(excerpts) == 0:
+		fmt.Fprintf(s.out, "No failure lines recognised in the log\n")
+	}
+	for _, excerpt := range excerpts {
+		fmt.Fprint(s.out, excerpt)
+	}
+}
+
+func (s *triageSession) record(regression internal.Regression, verdict, issue string) error {
+	decision := internal.TriageDecision{
+		Package:   regression.Package,
+		RepoType:  regression.RepoType,
+		Target:    s.manifest.Target,
+		RunID:     s.manifest.RunID,
+		Verdict:   verdict,
+		Issue:     issue,
+		DecidedAt: time.Now(),
+	}
+	if err := s.db.AppendTriage(decision); err != nil {
+		return err
+	}
+	s.decisions = append(s.decisions, decision)
+	fmt.Fprintf(s.out, "Marked %s as %s\n", regression.Package, verdict)
+	return nil
+}
+
+// issue returns the title and body of a GitHub issue reporting a regression
+func (s *triageSession) issue(regression internal.Regression) (string, string) {
+	title := fmt.Sprintf("%s regresses with %s", regression.Package, s.manifest.Target)
+
+	var body strings.Builder
+	fmt.Fprintf(&body, "`%s` fails with the candidate repository but passes without it.\n\n", regression.Package)
+	if s.manifest.APKRepo != "" {
+		fmt.Fprintf(&body, "- Candidate repository: %s\n", s.manifest.APKRepo)
+	}
+	if s.manifest.RepoType != "" || regression.RepoType != "" {
+		fmt.Fprintf(&body, "- Repository type: %s\n", regression.RepoType)
+	}
+	if s.manifest.RepoCommit != "" {
+		fmt.Fprintf(&body, "- Package repository commit: %s\n", s.manifest.RepoCommit)
+	}
+	if regression.FailingStep != "" {
+		fmt.Fprintf(&body, "- Failing test step: `%s`\n", regression.FailingStep)
+	}
+	fmt.Fprintf(&body, "- Run: %s\n", s.manifest.RunID)
+
+	if excerpts, err := internal.ExcerptLog(regression.LogPath, 3); err == nil && len(excerpts) > 0 {
+		fmt.Fprintf(&body, "\n### Log excerpts\n\n```\n")
+		for _, excerpt := range excerpts {
+			fmt.Fprint(&body, excerpt)
+		}
+		fmt.Fprintf(&body, "```\n")
+	}
+
+	fmt.Fprintf(&body, "\n*Reported with apkregress triage*\n")
+	return title, internal.FitGitHubBody(body.String(), regression.Package+"-regression", s.overflow)
+}
+
+// newBodyOverflow returns where issues and comments put what doesn't fit
+// into them by --overflow, logDir for log-dir
+func newBodyOverflow(logDir internal.DirOverflow) (internal.BodyOverflow, error) {
+	switch bodyOverflow {
+	case "gist":
+		return internal.GistOverflow{}, nil
+	case "log-dir":
+		return logDir, nil
+	case "none":
+		return nil, nil
+	}
+	return nil, fmt.Errorf("invalid --overflow %q, expected gist, log-dir or none", bodyOverflow)
+}
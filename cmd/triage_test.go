@@ -0,0 +1,84 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/chainguard-dev/apkregress/internal"
+)
+
+func TestTriageSession(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "curl_with_repo.log"), []byte("step\n--- FAIL: TestGet\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := internal.NewResultsDB(filepath.Join(dir, "results.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	regressions := []internal.Regression{
+		{Package: "curl", RepoType: "wolfi", LogPath: filepath.Join(dir, "curl_with_repo.log")},
+		{Package: "git", RepoType: "wolfi", LogPath: filepath.Join(dir, "git_with_repo.log")},
+		{Package: "jq", RepoType: "wolfi", LogPath: filepath.Join(dir, "jq_with_repo.log")},
+	}
+
+	var out bytes.Buffer
+	var reruns []string
+	session := &triageSession{
+		// Unknown input is asked again; the issue fails once before opening
+		in:       bufio.NewReader(strings.NewReader("x\nu\nf\ni\ni\nq\n")),
+		out:      &out,
+		db:       db,
+		manifest: &internal.RunManifest{RunID: "run-1", Target: "openssl"},
+		rerun: func(regression internal.Regression) error {
+			reruns = append(reruns, regression.Package)
+			return nil
+		},
+	}
+	issues := 0
+	session.createIssue = func(title, body string) (string, error) {
+		issues++
+		if issues == 1 {
+			return "", fmt.Errorf("gh not found")
+		}
+		if title != "git regresses with openssl" {
+			t.Errorf("Unexpected issue title %q", title)
+		}
+		return "https://github.com/wolfi-dev/os/issues/7", nil
+	}
+
+	if err := session.run(regressions); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(reruns) != 1 || reruns[0] != "curl" {
+		t.Errorf("Expected curl to be re-run, got %v", reruns)
+	}
+	if !strings.Contains(out.String(), "[test failure] line 2") {
+		t.Errorf("Expected the log excerpt in the output, got:\n%s", out.String())
+	}
+
+	decisions, err := db.TriageDecisions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(decisions) != 2 {
+		t.Fatalf("Expected 2 decisions, got %+v", decisions)
+	}
+	if d := decisions[0]; d.Package != "curl" || d.Verdict != internal.VerdictFlaky || d.RunID != "run-1" {
+		t.Errorf("Unexpected decision for curl: %+v", d)
+	}
+	if d := decisions[1]; d.Package != "git" || d.Verdict != internal.VerdictReal || d.Issue != "https://github.com/wolfi-dev/os/issues/7" {
+		t.Errorf("Unexpected decision for git: %+v", d)
+	}
+}
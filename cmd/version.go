@@ -0,0 +1,136 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/chainguard-dev/apkregress/internal"
+	"github.com/spf13/cobra"
+)
+
+var (
+	versionJSON bool
+	updateCheck bool
+	updateForce bool
+)
+
+var versionCmd = &cobra.Command{
+	Use:   "version",
+	Short: "Print the version, commit and build date of apkregress",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		build := internal.CurrentBuild()
+		if versionJSON {
+			enc := json.NewEncoder(cmd.OutOrStdout())
+			enc.SetIndent("", "  ")
+			return enc.Encode(build)
+		}
+
+		out := cmd.OutOrStdout()
+		fmt.Fprintf(out, "apkregress %s\n", build.Version)
+		if build.Commit != "" {
+			modified := ""
+			if build.Modified {
+				modified = " (modified)"
+			}
+			fmt.Fprintf(out, "commit:  %s%s\n", build.Commit, modified)
+		}
+		if build.BuildDate != "" {
+			fmt.Fprintf(out, "built:   %s\n", build.BuildDate)
+		}
+		fmt.Fprintf(out, "go:      %s %s\n", build.GoVersion, build.Platform)
+		return nil
+	},
+}
+
+var updateCmd = &cobra.Command{
+	Use:   "update",
+	Short: "Replace apkregress with the latest release",
+	Long: `Download the binary of the latest GitHub release for this platform, verify it
+against the release's checksums.txt and replace the running binary with it.
+
+Only a newer release replaces the binary. Builds without a release version
+(devel) or newer than the latest release are kept unless --force is given.`,
+	Example: `  apkregress update --check
+  apkregress update`,
+	Args: cobra.NoArgs,
+	RunE: runUpdate,
+}
+
+func init() {
+	versionCmd.Flags().BoolVar(&versionJSON, "json", false, "Print the build information as JSON")
+	updateCmd.Flags().BoolVar(&updateCheck, "check", false, "Only report whether a newer release is available")
+	updateCmd.Flags().BoolVar(&updateForce, "force", false, "Replace devel builds and builds newer than the latest release")
+
+	rootCmd.Version = internal.CurrentBuild().String()
+	rootCmd.AddCommand(versionCmd)
+	rootCmd.AddCommand(updateCmd)
+}
+
+func runUpdate(cmd *cobra.Command, args []string) error {
+	current := internal.CurrentBuild().Version
+	release, err := internal.FetchLatestRelease(internal.LatestReleaseURL)
+	if err != nil {
+		return err
+	}
+
+	update, message, err := planUpdate(current, release, updateCheck, updateForce)
+	if err != nil {
+		return err
+	}
+	if !update {
+		fmt.Println(message)
+		return nil
+	}
+
+	path, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to locate the apkregress binary: %w", err)
+	}
+	if path, err = filepath.EvalSymlinks(path); err != nil {
+		return fmt.Errorf("failed to locate the apkregress binary: %w", err)
+	}
+
+	if err := internal.UpdateBinary(release, path); err != nil {
+		return err
+	}
+	fmt.Printf("Updated %s from %s to %s\n", path, current, release.Tag)
+	return nil
+}
+
+// planUpdate tells whether to replace the running apkregress, at version
+// current, with a release, or else what to report. Only a newer release
+// replaces it; devel builds and builds newer than the release are replaced
+// with force alone, and never when only checking.
+func planUpdate(current string, release *internal.Release, check, force bool) (bool, string, error) {
+	c, err := internal.CompareReleaseVersions(current, release.Tag)
+	switch {
+	case err != nil:
+		if check {
+			return false, fmt.Sprintf("apkregress %s is the latest release (running %s, which is not a release build)", release.Tag, current), nil
+		}
+		if !force {
+			return false, "", fmt.Errorf("not replacing apkregress %s, which is not a release build, with %s: use --force to replace it", current, release.Tag)
+		}
+		return true, "", nil
+	case c == 0:
+		return false, fmt.Sprintf("apkregress %s is the latest release", current), nil
+	case c > 0:
+		if check {
+			return false, fmt.Sprintf("apkregress %s is newer than the latest release %s", current, release.Tag), nil
+		}
+		if !force {
+			return false, "", fmt.Errorf("not replacing apkregress %s with the older release %s: use --force to replace it", current, release.Tag)
+		}
+		return true, "", nil
+	}
+	if check {
+		return false, fmt.Sprintf("apkregress %s is available (running %s): %s", release.Tag, current, release.URL), nil
+	}
+	return true, "", nil
+}
@@ -0,0 +1,63 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/chainguard-dev/apkregress/internal"
+)
+
+func TestPlanUpdate(t *testing.T) {
+	release := &internal.Release{Tag: "v1.2.3", URL: "https://example.com/releases/v1.2.3"}
+
+	tests := []struct {
+		name            string
+		current         string
+		check           bool
+		force           bool
+		expectedUpdate  bool
+		expectedMessage string
+		expectedError   string
+	}{
+		{name: "older build", current: "v1.2.2", expectedUpdate: true},
+		{name: "older build check", current: "v1.2.2", check: true, expectedMessage: "apkregress v1.2.3 is available (running v1.2.2)"},
+		{name: "prerelease of the release", current: "v1.2.3-rc.1", expectedUpdate: true},
+		{name: "latest release", current: "v1.2.3", expectedMessage: "is the latest release"},
+		{name: "latest release check", current: "v1.2.3", check: true, expectedMessage: "is the latest release"},
+		{name: "latest release forced", current: "v1.2.3", force: true, expectedMessage: "is the latest release"},
+		{name: "newer build", current: "v1.3.0", expectedError: "not replacing apkregress v1.3.0 with the older release v1.2.3"},
+		{name: "newer build check", current: "v1.3.0", check: true, expectedMessage: "apkregress v1.3.0 is newer than the latest release v1.2.3"},
+		{name: "newer build forced", current: "v1.3.0", force: true, expectedUpdate: true},
+		{name: "newer build forced check", current: "v1.3.0", check: true, force: true, expectedMessage: "is newer than the latest release"},
+		{name: "devel build", current: "devel", expectedError: "not a release build"},
+		{name: "devel build check", current: "devel", check: true, expectedMessage: "running devel, which is not a release build"},
+		{name: "devel build forced", current: "devel", force: true, expectedUpdate: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			update, message, err := planUpdate(tt.current, release, tt.check, tt.force)
+			if tt.expectedError != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.expectedError) {
+					t.Errorf("Expected error containing %q, got %v", tt.expectedError, err)
+				}
+				if update {
+					t.Error("Expected no update on error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if update != tt.expectedUpdate {
+				t.Errorf("Expected update %v, got %v", tt.expectedUpdate, update)
+			}
+			if !strings.Contains(message, tt.expectedMessage) {
+				t.Errorf("Expected message containing %q, got %q", tt.expectedMessage, message)
+			}
+		})
+	}
+}
@@ -0,0 +1,175 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// AdvisoryReport lists the vulnerabilities a candidate update fixes
+type AdvisoryReport struct {
+	Package string `json:"package"`
+	// FromVersion is the version currently in the package index; empty if
+	// it is unknown
+	FromVersion string `json:"fromVersion,omitempty"`
+	ToVersion   string `json:"toVersion"`
+	Feed        string `json:"feed"`
+	// Fixes maps each version between FromVersion (exclusive) and ToVersion
+	// (inclusive) to the vulnerabilities it fixes
+	Fixes map[string][]string `json:"fixes"`
+}
+
+// Vulnerabilities returns the fixed vulnerabilities, sorted
+func (a *AdvisoryReport) Vulnerabilities() []string {
+	var vulns []string
+	for _, ids := range a.Fixes {
+		vulns = append(vulns, ids...)
+	}
+	sort.Strings(vulns)
+	return vulns
+}
+
+// securityDB is the secdb feed published next to each package repository
+type securityDB struct {
+	Packages []struct {
+		Pkg struct {
+			Name     string              `json:"name"`
+			Secfixes map[string][]string `json:"secfixes"`
+		} `json:"pkg"`
+	} `json:"packages"`
+}
+
+// advisoryFeedURL returns the security feed of a repository type, or "" if
+// it has none
+func advisoryFeedURL(repoType string) string {
+	return repoTypeOrDefault(repoType).Advisories
+}
+
+// fetchSecurityDB downloads and parses a security feed
+func fetchSecurityDB(feedURL string) (*securityDB, error) {
+	req, err := http.NewRequest(http.MethodGet, mirrorURL(feedURL), nil)
+	if err != nil {
+		return nil, err
+	}
+	auth, _, err := hostCredentials(req.URL.Host)
+	if err != nil {
+		return nil, err
+	}
+	auth.apply(req)
+
+	client := &http.Client{Timeout: 2 * time.Minute}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch advisory feed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch advisory feed %s: %s", feedURL, resp.Status)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read advisory feed: %w", err)
+	}
+
+	var db securityDB
+	if err := json.Unmarshal(data, &db); err != nil {
+		return nil, fmt.Errorf("invalid advisory feed %s: %w", feedURL, err)
+	}
+	return &db, nil
+}
+
+// correlateAdvisories returns the vulnerabilities of packageName fixed after
+// fromVersion up to and including toVersion. With an unknown fromVersion
+// only the fixes of toVersion itself are reported.
+func correlateAdvisories(db *securityDB, packageName, fromVersion, toVersion string) map[string][]string {
+	fixes := make(map[string][]string)
+	for _, pkg := range db.Packages {
+		if pkg.Pkg.Name != packageName {
+			continue
+		}
+
+		for version, ids := range pkg.Pkg.Secfixes {
+			// Version "0" lists vulnerabilities that never affected the package
+			if version == "0" || len(ids) == 0 {
+				continue
+			}
+
+			var included bool
+			if fromVersion == "" {
+				included = compareAPKVersions(version, toVersion) == 0
+			} else {
+				included = compareAPKVersions(version, fromVersion) > 0 && compareAPKVersions(version, toVersion) <= 0
+			}
+			if included {
+				fixes[version] = append(fixes[version], ids...)
+			}
+		}
+	}
+	return fixes
+}
+
+// latestVersion returns the newest version of packageName among entries
+func latestVersion(entries []apkIndexEntry, packageName string) string {
+	var latest string
+	for _, entry := range entries {
+		if entry.Name == packageName && (latest == "" || compareAPKVersions(entry.Version, latest) > 0) {
+			latest = entry.Version
+		}
+	}
+	return latest
+}
+
+// String describes the fixes for the text summary, e.g.
+// "openssl 3.3.1-r0 → 3.3.2-r0 fixes CVE-2024-1, CVE-2024-2"
+func (a *AdvisoryReport) String() string {
+	from := a.FromVersion
+	if from == "" {
+		from = "?"
+	}
+
+	vulns := a.Vulnerabilities()
+	if len(vulns) == 0 {
+		return fmt.Sprintf("%s %s → %s fixes no published advisories", a.Package, from, a.ToVersion)
+	}
+	return fmt.Sprintf("%s %s → %s fixes %s", a.Package, from, a.ToVersion, strings.Join(vulns, ", "))
+}
+
+// printMarkdown writes the fixes as a section of the markdown summary
+func (a *AdvisoryReport) printMarkdown(w io.Writer) {
+	fmt.Fprintf(w, "\n### 🛡️ Security Fixes\n\n")
+	from := a.FromVersion
+	if from == "" {
+		from = "?"
+	}
+	fmt.Fprintf(w, "Updating `%s` from `%s` to `%s`", a.Package, from, a.ToVersion)
+
+	if len(a.Fixes) == 0 {
+		fmt.Fprintf(w, " addresses no published advisories.\n")
+		return
+	}
+	fmt.Fprintf(w, " addresses:\n\n")
+
+	versions := make([]string, 0, len(a.Fixes))
+	for version := range a.Fixes {
+		versions = append(versions, version)
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		return compareAPKVersions(versions[i], versions[j]) < 0
+	})
+	for _, version := range versions {
+		ids := append([]string(nil), a.Fixes[version]...)
+		sort.Strings(ids)
+		for _, id := range ids {
+			fmt.Fprintf(w, "- %s (fixed in `%s`)\n", id, version)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+const testSecurityDB = `{
+  "apkurl": "{{urlprefix}}/{{reponame}}/{{arch}}/{{pkg.name}}-{{pkg.ver}}.apk",
+  "packages": [
+    {"pkg": {"name": "openssl", "secfixes": {
+      "0": ["CVE-2020-0001"],
+      "3.3.0-r0": ["CVE-2024-0001"],
+      "3.3.1-r0": ["CVE-2024-0002"],
+      "3.3.2-r0": ["CVE-2024-0004", "CVE-2024-0003"],
+      "3.3.3-r0": ["CVE-2024-0005"]
+    }}},
+    {"pkg": {"name": "curl", "secfixes": {"8.9.1-r0": ["CVE-2024-1000"]}}}
+  ]
+}`
+
+func TestCorrelateAdvisories(t *testing.T) {
+	var db securityDB
+	if err := json.Unmarshal([]byte(testSecurityDB), &db); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		fromVersion string
+		toVersion   string
+		expected    map[string][]string
+	}{
+		{
+			name:        "fixes between versions",
+			fromVersion: "3.3.0-r0",
+			toVersion:   "3.3.2-r1",
+			expected: map[string][]string{
+				"3.3.1-r0": {"CVE-2024-0002"},
+				"3.3.2-r0": {"CVE-2024-0004", "CVE-2024-0003"},
+			},
+		},
+		{
+			name:        "unknown current version",
+			fromVersion: "",
+			toVersion:   "3.3.2-r0",
+			expected: map[string][]string{
+				"3.3.2-r0": {"CVE-2024-0004", "CVE-2024-0003"},
+			},
+		},
+		{
+			name:        "no fixes",
+			fromVersion: "3.3.2-r0",
+			toVersion:   "3.3.2-r1",
+			expected:    map[string][]string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fixes := correlateAdvisories(&db, "openssl", tt.fromVersion, tt.toVersion)
+			if !reflect.DeepEqual(fixes, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, fixes)
+			}
+		})
+	}
+}
+
+func TestFetchSecurityDB(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/os/security.json" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(testSecurityDB))
+	}))
+	defer server.Close()
+
+	db, err := fetchSecurityDB(server.URL + "/os/security.json")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(db.Packages) != 2 {
+		t.Errorf("Expected 2 packages, got %d", len(db.Packages))
+	}
+
+	if _, err := fetchSecurityDB(server.URL + "/missing.json"); err == nil {
+		t.Error("Expected error for missing feed")
+	}
+}
+
+func TestAdvisoryReportString(t *testing.T) {
+	report := &AdvisoryReport{
+		Package:     "openssl",
+		FromVersion: "3.3.1-r0",
+		ToVersion:   "3.3.2-r0",
+		Fixes:       map[string][]string{"3.3.2-r0": {"CVE-2024-0004", "CVE-2024-0003"}},
+	}
+
+	expected := "openssl 3.3.1-r0 → 3.3.2-r0 fixes CVE-2024-0003, CVE-2024-0004"
+	if got := report.String(); got != expected {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+}
@@ -0,0 +1,219 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+)
+
+// RepoTypeAlpine is the upstream Alpine repository type. Alpine's packages
+// are built from APKBUILDs rather than melange configs, so its reverse
+// dependencies can be found but not tested.
+const RepoTypeAlpine = "alpine"
+
+// alpineMirror is where Alpine's repositories are served, by branch
+const alpineMirror = "https://dl-cdn.alpinelinux.org/alpine"
+
+// DefaultAlpineRepositories are the Alpine repositories searched for reverse
+// dependencies; testing holds packages that aren't in a release
+var DefaultAlpineRepositories = []string{"main", "community"}
+
+// alpineIndexURLs returns the index URLs of the repositories of an Alpine
+// branch, e.g. edge or v3.21
+func alpineIndexURLs(branch string, repositories []string, arch string) []string {
+	urls := make([]string, 0, len(repositories))
+	for _, repository := range repositories {
+		urls = append(urls, fmt.Sprintf("%s/%s/%s/%s/APKINDEX.tar.gz", alpineMirror, branch, repository, arch))
+	}
+	return urls
+}
+
+// SetAlpineBranch selects the Alpine branch and repositories the alpine
+// repository type reads (default: edge, DefaultAlpineRepositories)
+func (a *ApkraneClient) SetAlpineBranch(branch string, repositories []string) {
+	a.alpineBranch = branch
+	a.alpineRepositories = repositories
+}
+
+// loadAlpineIndex reads the packages of every repository of the Alpine
+// branch into one index, since Alpine splits its packages between
+// repositories where Wolfi has one
+func (a *ApkraneClient) loadAlpineIndex() ([]Package, error) {
+	branch, repositories := a.alpineBranch, a.alpineRepositories
+	if branch == "" {
+		branch = "edge"
+	}
+	if len(repositories) == 0 {
+		repositories = DefaultAlpineRepositories
+	}
+	if a.verifyIndex && len(a.indexKeys) == 0 {
+		return nil, fmt.Errorf("%w: no signing key is known for the alpine index; pass --index-key", ErrIndexSignature)
+	}
+
+	builder := newIndexBuilder()
+	for _, location := range alpineIndexURLs(branch, repositories, hostArch()) {
+		indexURL := mirrorURL(location)
+		auth, err := a.credentials(urlHost(indexURL))
+		if err != nil {
+			return nil, fmt.Errorf("failed to setup authentication: %w", err)
+		}
+		data, err := fetchIndex(indexURL, auth)
+		if err != nil {
+			return nil, err
+		}
+		// The packages of an index only count once its signature verifies
+		repository := newIndexBuilder()
+		sig, signed, err := scanAPKIndex(data, repository.addEntry)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid index %s: %w", ErrIndexFetch, indexURL, err)
+		}
+		if a.verifyIndex {
+			if err := verifyAPKIndex(&apkIndex{Signature: sig, Signed: signed}, a.indexKeys); err != nil {
+				return nil, fmt.Errorf("%w: index %s: %w", ErrIndexSignature, indexURL, err)
+			}
+		}
+		if a.verbose {
+			fmt.Printf("Read %d packages from %s\n", len(repository.packages), indexURL)
+		}
+		for _, pkg := range repository.packages {
+			builder.add(pkg)
+		}
+	}
+
+	a.packages = builder.packages
+	return a.packages, nil
+}
+
+// alpineNames map the versioned package streams of Wolfi to the names Alpine
+// gives the same software, e.g. py3.12-requests to py3-requests. Alpine
+// packages one version of most runtimes, named without the version.
+var alpineNames = []struct {
+	pattern     *regexp.Regexp
+	replacement string
+}{
+	{regexp.MustCompile(`^py3\.\d+-(.+)$`), "py3-$1"},
+	{regexp.MustCompile(`^python-3\.\d+(-.+)?$`), "python3$1"},
+	{regexp.MustCompile(`^ruby3\.\d+-(.+)$`), "ruby-$1"},
+	{regexp.MustCompile(`^ruby-3\.\d+(-.+)?$`), "ruby$1"},
+	{regexp.MustCompile(`^go-1\.\d+$`), "go"},
+	{regexp.MustCompile(`^nodejs-\d+$`), "nodejs"},
+	{regexp.MustCompile(`^perl-5\.\d+$`), "perl"},
+	{regexp.MustCompile(`^openjdk-(\d+)(-.+)?$`), "openjdk$1$2"},
+	{regexp.MustCompile(`^(llvm|clang)-(\d+)(-.+)?$`), "$1$2$3"},
+	{regexp.MustCompile(`^openssl-3\.\d+$`), "openssl"},
+}
+
+// AlpinePackageName returns the name Alpine gives a Wolfi package, which is
+// the same name unless it is a versioned stream (see alpineNames)
+func AlpinePackageName(name string) string {
+	for _, n := range alpineNames {
+		if n.pattern.MatchString(name) {
+			return n.pattern.ReplaceAllString(name, n.replacement)
+		}
+	}
+	return name
+}
+
+// AlpineComparison compares the reverse dependencies of a package in a
+// Chainguard repository type and in Alpine, by Alpine name
+type AlpineComparison struct {
+	Package string `json:"package"`
+	// AlpinePackage is the name of the package in Alpine
+	AlpinePackage string `json:"alpinePackage"`
+	RepoType      string `json:"repoType"`
+	// Consumers are the reverse dependencies in RepoType, and
+	// AlpineConsumers those in Alpine
+	Consumers       []string `json:"consumers"`
+	AlpineConsumers []string `json:"alpineConsumers"`
+	// Both are the consumers in both, by Alpine name, and OnlyRepoType and
+	// OnlyAlpine those in one of them
+	Both         []string `json:"both"`
+	OnlyRepoType []string `json:"onlyRepoType"`
+	OnlyAlpine   []string `json:"onlyAlpine"`
+}
+
+// CompareAlpineConsumers finds the reverse dependencies of packageName with
+// client and those of its Alpine name with alpine, and compares them
+func CompareAlpineConsumers(packageName string, client, alpine *ApkraneClient) (*AlpineComparison, error) {
+	consumers, err := client.GetReverseDependencies(packageName)
+	if err != nil {
+		return nil, err
+	}
+	alpineName := AlpinePackageName(packageName)
+	alpineConsumers, err := alpine.GetReverseDependencies(alpineName)
+	if err != nil {
+		return nil, fmt.Errorf("alpine: %w", err)
+	}
+	return compareConsumers(packageName, alpineName, client.repoTypeName(), consumers, alpineConsumers), nil
+}
+
+// compareConsumers compares consumers, mapped to their Alpine names, with
+// alpineConsumers
+func compareConsumers(packageName, alpineName, repoType string, consumers, alpineConsumers []string) *AlpineComparison {
+	inAlpine := make(map[string]bool, len(alpineConsumers))
+	for _, origin := range alpineConsumers {
+		inAlpine[origin] = true
+	}
+
+	comparison := &AlpineComparison{
+		Package:         packageName,
+		AlpinePackage:   alpineName,
+		RepoType:        repoType,
+		Consumers:       nonNil(consumers),
+		AlpineConsumers: nonNil(alpineConsumers),
+		Both:            []string{},
+		OnlyRepoType:    []string{},
+		OnlyAlpine:      []string{},
+	}
+	matched := make(map[string]bool)
+	for _, origin := range consumers {
+		name := AlpinePackageName(origin)
+		if !inAlpine[name] {
+			comparison.OnlyRepoType = append(comparison.OnlyRepoType, origin)
+			continue
+		}
+		if !matched[name] {
+			matched[name] = true
+			comparison.Both = append(comparison.Both, name)
+		}
+	}
+	for _, origin := range alpineConsumers {
+		if !matched[origin] {
+			comparison.OnlyAlpine = append(comparison.OnlyAlpine, origin)
+		}
+	}
+	sort.Strings(comparison.Both)
+	return comparison
+}
+
+// WriteText writes the comparison for the terminal
+func (c *AlpineComparison) WriteText(w io.Writer) {
+	var alpineName string
+	if c.AlpinePackage != c.Package {
+		alpineName = fmt.Sprintf(" (as %s)", c.AlpinePackage)
+	}
+	fmt.Fprintf(w, "Reverse dependencies of %s:\n", c.Package)
+	fmt.Fprintf(w, "  %s: %d\n", c.RepoType, len(c.Consumers))
+	fmt.Fprintf(w, "  alpine: %d%s\n", len(c.AlpineConsumers), alpineName)
+	fmt.Fprintf(w, "  in both: %d\n", len(c.Both))
+
+	for _, list := range []struct {
+		title    string
+		packages []string
+	}{
+		{"Only in " + c.RepoType, c.OnlyRepoType},
+		{"Only in alpine", c.OnlyAlpine},
+	} {
+		if len(list.packages) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "\n%s (%d):\n", list.title, len(list.packages))
+		for _, pkg := range list.packages {
+			fmt.Fprintf(w, "  - %s\n", pkg)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAlpinePackageName(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected string
+	}{
+		{"openssl", "openssl"},
+		{"openssl-3.3", "openssl"},
+		{"py3.12-requests", "py3-requests"},
+		{"py3-requests", "py3-requests"},
+		{"python-3.12", "python3"},
+		{"python-3.12-dev", "python3-dev"},
+		{"ruby3.3-rake", "ruby-rake"},
+		{"ruby-3.3", "ruby"},
+		{"go-1.23", "go"},
+		{"nodejs-22", "nodejs"},
+		{"openjdk-17", "openjdk17"},
+		{"openjdk-21-jre", "openjdk21-jre"},
+		{"llvm-19", "llvm19"},
+		{"clang-19-dev", "clang19-dev"},
+		{"perl-5.40", "perl"},
+		{"perl-json", "perl-json"},
+	}
+
+	for _, tt := range tests {
+		if got := AlpinePackageName(tt.name); got != tt.expected {
+			t.Errorf("Expected %s for %s, got %s", tt.expected, tt.name, got)
+		}
+	}
+}
+
+func TestCompareConsumers(t *testing.T) {
+	comparison := compareConsumers("python-3.12", "python3", "wolfi",
+		[]string{"py3.12-requests", "py3.13-requests", "glib", "wolfi-only"},
+		[]string{"glib", "py3-requests", "alpine-only"})
+
+	if !reflect.DeepEqual(comparison.Both, []string{"glib", "py3-requests"}) {
+		t.Errorf("Expected glib and py3-requests in both, got %v", comparison.Both)
+	}
+	if !reflect.DeepEqual(comparison.OnlyRepoType, []string{"wolfi-only"}) {
+		t.Errorf("Expected only wolfi-only in wolfi, got %v", comparison.OnlyRepoType)
+	}
+	if !reflect.DeepEqual(comparison.OnlyAlpine, []string{"alpine-only"}) {
+		t.Errorf("Expected only alpine-only in alpine, got %v", comparison.OnlyAlpine)
+	}
+
+	var out bytes.Buffer
+	comparison.WriteText(&out)
+	for _, expected := range []string{"wolfi: 4", "alpine: 3 (as python3)", "in both: 2", "Only in alpine (1):\n  - alpine-only"} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("Expected %q in the comparison, got:\n%s", expected, out.String())
+		}
+	}
+}
+
+func TestLoadAlpineIndex(t *testing.T) {
+	indexes := map[string]string{
+		"/alpine/v3.21/main/" + hostArch() + "/APKINDEX.tar.gz":      "P:openssl\nV:3.3.2-r0\no:openssl\n\nP:curl\nV:8.11.0-r0\no:curl\nD:so:libssl.so.3 openssl\n",
+		"/alpine/v3.21/community/" + hostArch() + "/APKINDEX.tar.gz": "P:py3-cryptography\nV:44.0.0-r0\no:py3-cryptography\nD:openssl\n",
+	}
+	var requested []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = append(requested, r.URL.Path)
+		index, ok := indexes[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(gzipTar(t, "APKINDEX", []byte(index)))
+	}))
+	defer server.Close()
+
+	defer ConfigureNetwork(NetworkConfig{})
+	if err := ConfigureNetwork(NetworkConfig{Mirrors: []Mirror{{From: alpineMirror, To: server.URL + "/alpine"}}}); err != nil {
+		t.Fatal(err)
+	}
+
+	client := NewApkraneClient(false, RepoTypeAlpine)
+	client.SetAlpineBranch("v3.21", []string{"main", "community"})
+	consumers, err := client.GetReverseDependencies("openssl")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(consumers, []string{"curl", "py3-cryptography"}) {
+		t.Errorf("Expected the consumers in both repositories, got %v", consumers)
+	}
+	if len(requested) != 2 {
+		t.Errorf("Expected both repositories to be fetched once, got %v", requested)
+	}
+
+	client = NewApkraneClient(false, RepoTypeAlpine)
+	client.SetAlpineBranch("v3.21", []string{"testing"})
+	if _, err := client.GetReverseDependencies("openssl"); err == nil {
+		t.Errorf("Expected a missing repository to fail")
+	}
+}
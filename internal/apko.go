@@ -0,0 +1,199 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+type ApkoClient struct {
+	verbose     bool
+	logDir      string
+	hangTimeout time.Duration
+	apkCacheDir string
+}
+
+func NewApkoClient(verbose bool, logDir string, hangTimeout time.Duration) *ApkoClient {
+	return &ApkoClient{
+		verbose:     verbose,
+		logDir:      logDir,
+		hangTimeout: hangTimeout,
+	}
+}
+
+// SetAPKCache makes all builds share one APK download cache
+func (a *ApkoClient) SetAPKCache(dir string) {
+	a.apkCacheDir = dir
+}
+
+// FindApkoConfigs walks dir and returns a map from a config name (its path
+// relative to dir, without extension) to the config path, for every YAML file
+// that looks like an apko image configuration.
+func FindApkoConfigs(dir string) (map[string]string, error) {
+	// apko runs in a temporary directory, so config paths must be absolute
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve apko config directory: %w", err)
+	}
+
+	configs := make(map[string]string)
+
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		ext := filepath.Ext(path)
+		if ext != ".yaml" && ext != ".yml" {
+			return nil
+		}
+
+		isApko, err := isApkoConfig(path)
+		if err != nil {
+			return err
+		}
+		if !isApko {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		configs[strings.TrimSuffix(rel, ext)] = path
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to search for apko configs in %s: %w", dir, err)
+	}
+
+	return configs, nil
+}
+
+// isApkoConfig reports whether the YAML file at path has a top-level
+// "contents" key, which every apko image configuration has.
+func isApkoConfig(path string) (bool, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if strings.HasPrefix(scanner.Text(), "contents:") {
+			return true, nil
+		}
+	}
+	return false, scanner.Err()
+}
+
+// BuildConfig builds the apko configuration at configPath, optionally with the
+// candidate APK repository appended, to surface package resolution and image
+// composition failures.
+func (a *ApkoClient) BuildConfig(name, configPath string, withRepo bool, apkRepo string) error {
+	return a.RunBuild(name, configPath, withRepo, apkRepo).Error
+}
+
+// RunBuild builds an apko configuration and returns its detailed result
+func (a *ApkoClient) RunBuild(name, configPath string, withRepo bool, apkRepo string) TestResult {
+	return a.runBuildResult(context.Background(), name, configPath, ExecuteOptions{WithRepo: withRepo, APKRepo: apkRepo})
+}
+
+func (a *ApkoClient) runBuildResult(ctx context.Context, name, configPath string, opts ExecuteOptions) TestResult {
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = a.hangTimeout
+	}
+
+	startedAt := time.Now()
+	var usage testUsage
+	logPath, err := a.runBuild(ctx, name, configPath, opts.WithRepo, opts.APKRepo, timeout, opts.WorkDir, &usage)
+	result := newTestResult(name, opts.WithRepo, startedAt, logPath, err)
+	result.PeakMemory, result.CPUTime = usage.PeakMemory, usage.CPUTime
+	result.OOMKilled = usage.OOMKilled && !result.Success
+	return result
+}
+
+// Executor returns a TestExecutor that builds the given configs, keyed by
+// the names FindApkoConfigs returns
+func (a *ApkoClient) Executor(configs map[string]string) TestExecutor {
+	return TestExecutorFunc(func(ctx context.Context, name string, opts ExecuteOptions) TestResult {
+		return a.runBuildResult(ctx, name, configs[name], opts)
+	})
+}
+
+// runBuild runs `apko build` and returns the path of its log file. The resources
+// the build used are recorded in usage.
+func (a *ApkoClient) runBuild(ctx context.Context, name, configPath string, withRepo bool, apkRepo string, timeout time.Duration, tempDir string, usage *testUsage) (string, error) {
+	if _, err := os.Stat(configPath); os.IsNotExist(err) {
+		return "", ErrPackageYAMLNotFound
+	}
+
+	// Create temporary directory for build output unless the caller provided one
+	if tempDir == "" {
+		var err error
+		tempDir, err = os.MkdirTemp("", "apko-build-")
+		if err != nil {
+			return "", fmt.Errorf("failed to create temp directory: %w", err)
+		}
+		defer os.RemoveAll(tempDir)
+	}
+
+	// Config names may contain path separators, flatten them for the log file name
+	safeName := strings.ReplaceAll(name, string(filepath.Separator), "_")
+	logFileName := fmt.Sprintf("%s_%s.log", safeName, map[bool]string{true: "with_repo", false: "without_repo"}[withRepo])
+	logFilePath := filepath.Join(a.logDir, logFileName)
+
+	logFile, err := os.Create(logFilePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to create log file %s: %w", logFilePath, err)
+	}
+	defer logFile.Close()
+
+	args := []string{"build", "--arch", hostArch()}
+	if withRepo {
+		args = append(args, "--repository-append", mirrorURL(apkRepo))
+	}
+	if a.apkCacheDir != "" {
+		args = append(args, "--cache-dir", a.apkCacheDir)
+	}
+	args = append(args, configPath, fmt.Sprintf("apkregress.local/%s:test", strings.ToLower(safeName)), filepath.Join(tempDir, "image.tar"))
+
+	if a.verbose {
+		fmt.Printf("Building apko config %s %s (log: %s)\n", configPath, map[bool]string{true: "with APK repository", false: "without APK repository"}[withRepo], logFilePath)
+	}
+
+	cmd := exec.Command("apko", args...)
+	cmd.Dir = tempDir
+	cmd.Env = append(os.Environ(), fmt.Sprintf("TMPDIR=%s", tempDir))
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+
+	if err := startInProcessGroup(cmd); err != nil {
+		return logFilePath, fmt.Errorf("failed to start apko build for %s: %w", name, err)
+	}
+	defer trackTest(cmd)()
+
+	if err := waitMeasured(ctx, cmd, timeout, usage); err != nil {
+		if errors.Is(err, ErrTestHung) {
+			fmt.Fprintf(logFile, "\n\n=== BUILD HUNG - KILLED AFTER %v ===\n", timeout)
+			return logFilePath, ErrTestHung
+		}
+		return logFilePath, fmt.Errorf("apko build %s failed: %w", name, err)
+	}
+	return logFilePath, nil
+}
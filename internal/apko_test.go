@@ -0,0 +1,79 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFindApkoConfigs(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	files := map[string]string{
+		"python.yaml":             "contents:\n  packages:\n    - python-3.12\n",
+		"images/nginx/config.yml": "archs:\n  - x86_64\ncontents:\n  packages:\n    - nginx\n",
+		"melange.yaml":            "package:\n  name: foo\n",
+		"README.md":               "contents: not yaml\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(tmpDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	configs, err := FindApkoConfigs(tmpDir)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := []string{filepath.Join("images", "nginx", "config"), "python"}
+	if !reflect.DeepEqual(sortedKeys(configs), expected) {
+		t.Errorf("Expected configs %v, got %v", expected, sortedKeys(configs))
+	}
+
+	if !filepath.IsAbs(configs["python"]) {
+		t.Errorf("Expected absolute config path, got %s", configs["python"])
+	}
+}
+
+func TestBuildConfigNotFound(t *testing.T) {
+	client := NewApkoClient(false, t.TempDir(), time.Minute)
+
+	err := client.BuildConfig("missing", "/nonexistent/config.yaml", true, "http://example.com/repo")
+	if !errors.Is(err, ErrPackageYAMLNotFound) {
+		t.Errorf("Expected ErrPackageYAMLNotFound, got %v", err)
+	}
+}
+
+func TestBuildConfigLogFile(t *testing.T) {
+	tmpDir := t.TempDir()
+	logDir := filepath.Join(tmpDir, "logs")
+	if err := os.MkdirAll(logDir, 0755); err != nil {
+		t.Fatalf("Failed to create log dir: %v", err)
+	}
+
+	configPath := filepath.Join(tmpDir, "config.yaml")
+	if err := os.WriteFile(configPath, []byte("contents: {}\n"), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	client := NewApkoClient(false, logDir, time.Second)
+
+	// apko is most likely not installed, we only care about the log file
+	client.BuildConfig(filepath.Join("images", "base"), configPath, false, "http://example.com/repo")
+
+	expectedLogFile := filepath.Join(logDir, "images_base_without_repo.log")
+	if _, err := os.Stat(expectedLogFile); os.IsNotExist(err) {
+		t.Errorf("Expected log file %s to be created", expectedLogFile)
+	}
+}
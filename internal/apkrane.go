@@ -6,8 +6,12 @@ package internal
 import (
 	"bufio"
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"runtime"
@@ -18,9 +22,31 @@ import (
 type ApkraneClient struct {
 	verbose  bool
 	repoType string
+	packages []Package
+	cache    *IndexCache
+	token    string
+	// indexURL and validators identify the revision of the loaded index
+	indexURL   string
+	validators indexValidators
+	// indexDigest identifies the content of the loaded index, which keys
+	// its cached reverse-dependency map, if it is known
+	indexDigest string
+	// indexKeys are the keys the index must be signed with, if verifyIndex
+	// is set
+	verifyIndex bool
+	indexKeys   []string
+	// alpineBranch and alpineRepositories select the Alpine index with the
+	// alpine repository type (see SetAlpineBranch)
+	alpineBranch       string
+	alpineRepositories []string
+	// consumers is the reverse-dependency map of packages (see
+	// reverseDependencies)
+	consumers map[string][]string
 }
 
 type Package struct {
+	Name         string   `json:"Name"`
+	Version      string   `json:"Version"`
 	Origin       string   `json:"Origin"`
 	Dependencies []string `json:"Dependencies"`
 }
@@ -32,98 +58,273 @@ func NewApkraneClient(verbose bool, repoType string) *ApkraneClient {
 	}
 }
 
+// hostArch returns the APK architecture name for the host, e.g. x86_64
+func hostArch() string {
+	arch := runtime.GOARCH
+	if arch == "amd64" {
+		arch = "x86_64"
+	}
+	return arch
+}
+
 func (a *ApkraneClient) getIndexURL(arch string) string {
-	switch a.repoType {
-	case "enterprise":
-		return fmt.Sprintf("https://apk.cgr.dev/chainguard-private/%s/APKINDEX.tar.gz", arch)
-	case "extras":
-		return fmt.Sprintf("https://apk.cgr.dev/extra-packages/%s/APKINDEX.tar.gz", arch)
-	default: // "wolfi"
-		return fmt.Sprintf("https://packages.wolfi.dev/os/%s/APKINDEX.tar.gz", arch)
+	return repoTypeOrDefault(a.repoType).indexURL(arch)
+}
+
+// SetIndexCache enables on-disk caching of parsed indexes
+func (a *ApkraneClient) SetIndexCache(cache *IndexCache) {
+	a.cache = cache
+}
+
+func (a *ApkraneClient) requiresAuth() bool {
+	return repoTypeRequiresAuth(a.repoType)
+}
+
+// repoTypeRequiresAuth reports whether the packages of repoType are served
+// with chainctl authentication, like those of apk.cgr.dev
+func repoTypeRequiresAuth(repoType string) bool {
+	t, ok := LookupRepoType(repoType)
+	return ok && t.Auth == RepoAuthChainctl
+}
+
+// authToken returns a chainctl token for the repository type's audience,
+// fetching it only once
+func (a *ApkraneClient) authToken() (string, error) {
+	if a.token != "" {
+		return a.token, nil
+	}
+
+	token, err := chainctlAudienceToken(repoTypeOrDefault(a.repoType).audience())
+	if err != nil {
+		return "", err
 	}
+
+	a.token = token
+	return a.token, nil
 }
 
-func (a *ApkraneClient) setupAuth(cmd *exec.Cmd) error {
-	// Get authentication token using chainctl
-	tokenCmd := exec.Command("chainctl", "auth", "token", "--audience", "apk.cgr.dev")
+// chainctlAudienceToken gets an authentication token for audience using
+// chainctl
+func chainctlAudienceToken(audience string) (string, error) {
+	tokenCmd := exec.Command("chainctl", "auth", "token", "--audience", audience)
 	tokenOutput, err := tokenCmd.Output()
 	if err != nil {
-		return fmt.Errorf("failed to get authentication token: %w", err)
+		return "", fmt.Errorf("%w: failed to get a token from chainctl for %s: %w", ErrAuth, audience, commandError(err))
 	}
+	return strings.TrimSpace(string(tokenOutput)), nil
+}
 
-	token := strings.TrimSpace(string(tokenOutput))
-	httpAuth := fmt.Sprintf("basic:apk.cgr.dev:user:%s", token)
+// credentials returns the credentials for fetching the index from host: those
+// in the credentials file, or else the chainctl token for private
+// repository types. host differs from the index's host when the repository
+// is mirrored.
+func (a *ApkraneClient) credentials(host string) (basicAuth, error) {
+	if auth, ok, err := hostCredentials(host); ok {
+		return auth, err
+	}
+	if !a.requiresAuth() {
+		return basicAuth{}, nil
+	}
+
+	token, err := a.authToken()
+	if err != nil {
+		return basicAuth{}, err
+	}
+	return basicAuth{Username: "user", Password: token}, nil
+}
 
+// setupAuth passes auth to cmd for host
+func (a *ApkraneClient) setupAuth(cmd *exec.Cmd, host string, auth basicAuth) {
 	// Set environment variable for the command
-	cmd.Env = append(os.Environ(), fmt.Sprintf("HTTP_AUTH=%s", httpAuth))
+	cmd.Env = append(os.Environ(), fmt.Sprintf("HTTP_AUTH=%s", auth.httpAuth(host)))
 
 	if a.verbose {
-		fmt.Printf("Setting up authentication for %s repository\n", a.repoType)
+		fmt.Printf("Setting up authentication for %s\n", host)
 	}
-
-	return nil
 }
 
-func (a *ApkraneClient) GetReverseDependencies(packageName string) ([]string, error) {
-	if a.verbose {
-		fmt.Printf("Finding reverse dependencies for package: %s\n", packageName)
+// loadIndex fetches and parses the package index for the client's repository
+// type. The parsed index is kept on the client so that subsequent lookups
+// don't download it again.
+func (a *ApkraneClient) loadIndex() ([]Package, error) {
+	if a.packages != nil {
+		return a.packages, nil
+	}
+	if FakeBackend() {
+		packages, err := fakePackages()
+		if err != nil {
+			return nil, fmt.Errorf("failed to load the fake index: %w", err)
+		}
+		a.packages = packages
+		return packages, nil
+	}
+	if a.repoType == RepoTypeAlpine {
+		return a.loadAlpineIndex()
 	}
 
-	arch := runtime.GOARCH
-	if arch == "amd64" {
-		arch = "x86_64"
+	indexURL := mirrorURL(a.getIndexURL(hostArch()))
+	auth, err := a.credentials(urlHost(indexURL))
+	if err != nil {
+		return nil, fmt.Errorf("failed to setup authentication: %w", err)
 	}
+	if a.verifyIndex {
+		return a.loadVerifiedIndex(indexURL, auth)
+	}
+
+	var validators indexValidators
+	if a.cache != nil {
+		var err error
+		validators, err = fetchIndexValidators(indexURL, auth)
+		if err != nil && a.verbose {
+			fmt.Printf("Warning: failed to check index revision, not using cache: %v\n", err)
+		}
 
-	indexURL := a.getIndexURL(arch)
+		if cached, ok := a.cache.load(indexURL, validators); ok {
+			if a.verbose {
+				fmt.Printf("Using cached index for %s\n", indexURL)
+			}
+			a.indexURL = indexURL
+			a.validators = validators
+			a.indexDigest = cached.Digest
+			a.packages = cached.Packages
+			return a.packages, nil
+		}
+	}
 
 	cmd := exec.Command("apkrane", "ls", "--json", "--latest", indexURL)
 
-	// Set up authentication for enterprise and extras repositories
-	if a.repoType == "enterprise" || a.repoType == "extras" {
-		if err := a.setupAuth(cmd); err != nil {
-			return nil, fmt.Errorf("failed to setup authentication: %w", err)
-		}
+	// Set up authentication for enterprise and extras repositories, and
+	// hosts in the credentials file
+	if auth.Password != "" {
+		a.setupAuth(cmd, urlHost(indexURL), auth)
 	}
-	output, err := cmd.Output()
+	packages, digest, err := a.readApkraneOutput(cmd)
 	if err != nil {
 		return nil, fmt.Errorf("failed to run apkrane ls for %s: %w", indexURL, err)
 	}
 
-	var packages []Package
-	scanner := bufio.NewScanner(bytes.NewReader(output))
+	if a.cache != nil {
+		if err := a.cache.store(indexURL, validators, digest, packages); err != nil && a.verbose {
+			fmt.Printf("Warning: failed to cache index: %v\n", err)
+		}
+	}
+
+	a.indexURL = indexURL
+	a.validators = validators
+	a.indexDigest = digest
+	a.packages = packages
+	return packages, nil
+}
+
+// readApkraneOutput reads the packages apkrane ls --json prints, one per
+// line, as they are printed rather than once apkrane exits, so that its
+// output isn't held in memory besides the packages. It also returns the
+// digest of the output, which identifies the index's packages.
+func (a *ApkraneClient) readApkraneOutput(cmd *exec.Cmd) ([]Package, string, error) {
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, "", err
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Start(); err != nil {
+		return nil, "", fetchError(err)
+	}
+
+	builder := newIndexBuilder()
+	digest := sha256.New()
+	scanner := bufio.NewScanner(io.TeeReader(stdout, digest))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
 	for scanner.Scan() {
-		line := scanner.Text()
-		if strings.TrimSpace(line) == "" {
+		line := scanner.Bytes()
+		if len(bytes.TrimSpace(line)) == 0 {
 			continue
 		}
 
 		var pkg Package
-		if err := json.Unmarshal([]byte(line), &pkg); err != nil {
+		if err := json.Unmarshal(line, &pkg); err != nil {
 			if a.verbose {
 				fmt.Printf("Warning: failed to parse JSON line: %s\n", err)
 			}
 			continue
 		}
-		packages = append(packages, pkg)
+		builder.add(pkg)
+	}
+	scanErr := scanner.Err()
+	if scanErr != nil {
+		// Let apkrane exit rather than block on a full pipe
+		io.Copy(io.Discard, stdout)
 	}
 
-	if err := scanner.Err(); err != nil {
-		return nil, fmt.Errorf("failed to read apkrane output: %w", err)
+	if err := cmd.Wait(); err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			exitErr.Stderr = stderr.Bytes()
+		}
+		return nil, "", fetchError(err)
+	}
+	if scanErr != nil {
+		return nil, "", fmt.Errorf("failed to read apkrane output: %w", scanErr)
 	}
+	return builder.packages, "sha256:" + hex.EncodeToString(digest.Sum(nil)), nil
+}
 
-	originSet := make(map[string]bool)
+// ErrUnknownPackage indicates that a target package isn't in the index
+var ErrUnknownPackage = errors.New("package not found in the index")
+
+// ValidatePackage checks that packageName is a package, origin or dependency
+// in the index, so that a typo isn't reported as a package without reverse
+// dependencies. The error suggests close matches.
+func (a *ApkraneClient) ValidatePackage(packageName string) error {
+	packages, err := a.loadIndex()
+	if err != nil {
+		return err
+	}
+
+	var names []string
 	for _, pkg := range packages {
-		if pkg.Dependencies == nil {
-			continue
+		if pkg.Name == packageName || pkg.Origin == packageName {
+			return nil
 		}
-
+		// Reverse dependencies match dependencies by substring
 		for _, dep := range pkg.Dependencies {
 			if strings.Contains(dep, packageName) {
-				if pkg.Origin != "" {
-					originSet[pkg.Origin] = true
-				}
+				return nil
 			}
 		}
+		names = append(names, pkg.Name)
+		if pkg.Origin != "" {
+			names = append(names, pkg.Origin)
+		}
+	}
+
+	err = fmt.Errorf("%w: %s is not in the %s index", ErrUnknownPackage, packageName, a.repoTypeName())
+	if matches := closestMatches(packageName, names, 3); len(matches) > 0 {
+		err = fmt.Errorf("%w (did you mean %s?)", err, strings.Join(matches, ", "))
+	}
+	return err
+}
+
+// repoTypeName names the repository type in messages
+func (a *ApkraneClient) repoTypeName() string {
+	if a.repoType == "" {
+		return "wolfi"
+	}
+	return a.repoType
+}
+
+func (a *ApkraneClient) GetReverseDependencies(packageName string) ([]string, error) {
+	if a.verbose {
+		fmt.Printf("Finding reverse dependencies for package: %s\n", packageName)
+	}
+
+	consumers, err := a.reverseDependencies()
+	if err != nil {
+		return nil, err
+	}
+
+	originSet := make(map[string]bool)
+	for _, origin := range consumersOf(consumers, packageName) {
+		originSet[origin] = true
 	}
 
 	var origins []string
@@ -138,3 +339,46 @@ func (a *ApkraneClient) GetReverseDependencies(packageName string) ([]string, er
 
 	return origins, nil
 }
+
+// OriginsForPackages maps binary package names (as listed in an SBOM) to the
+// origins that produce them. Names that are already origins map to themselves.
+func (a *ApkraneClient) OriginsForPackages(names []string) (map[string]bool, error) {
+	packages, err := a.loadIndex()
+	if err != nil {
+		return nil, err
+	}
+
+	wanted := make(map[string]bool, len(names))
+	for _, name := range names {
+		wanted[name] = true
+	}
+
+	origins := make(map[string]bool)
+	for _, pkg := range packages {
+		if pkg.Origin == "" {
+			continue
+		}
+		if wanted[pkg.Name] || wanted[pkg.Origin] {
+			origins[pkg.Origin] = true
+		}
+	}
+
+	return origins, nil
+}
+
+// LatestVersion returns the newest version of packageName in the index, or
+// "" if it isn't listed
+func (a *ApkraneClient) LatestVersion(packageName string) (string, error) {
+	packages, err := a.loadIndex()
+	if err != nil {
+		return "", err
+	}
+
+	var latest string
+	for _, pkg := range packages {
+		if pkg.Name == packageName && (latest == "" || compareAPKVersions(pkg.Version, latest) > 0) {
+			latest = pkg.Version
+		}
+	}
+	return latest, nil
+}
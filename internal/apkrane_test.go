@@ -4,7 +4,9 @@
 package internal
 
 import (
+	"errors"
 	"runtime"
+	"strings"
 	"testing"
 )
 
@@ -272,4 +274,32 @@ func TestPackageJSONParsing(t *testing.T) {
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+func TestValidatePackage(t *testing.T) {
+	client := NewApkraneClient(false, "wolfi")
+	client.packages = []Package{
+		{Name: "openssl", Origin: "openssl", Dependencies: []string{"so:libcrypto.so.3"}},
+		{Name: "libcrypto3", Origin: "openssl"},
+		{Name: "openssh", Origin: "openssh", Dependencies: []string{"so:libcrypto.so.3"}},
+		{Name: "curl", Origin: "curl"},
+	}
+
+	for _, name := range []string{"openssl", "libcrypto3", "libcrypto.so.3"} {
+		if err := client.ValidatePackage(name); err != nil {
+			t.Errorf("Expected %s to be valid, got %v", name, err)
+		}
+	}
+
+	err := client.ValidatePackage("opensll")
+	if !errors.Is(err, ErrUnknownPackage) {
+		t.Fatalf("Expected ErrUnknownPackage, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "did you mean openssl, openssh?") {
+		t.Errorf("Expected suggestions, got %v", err)
+	}
+
+	err = client.ValidatePackage("zzzzzzzz")
+	if !errors.Is(err, ErrUnknownPackage) || strings.Contains(err.Error(), "did you mean") {
+		t.Errorf("Expected no suggestions for an unrelated name, got %v", err)
+	}
+}
@@ -0,0 +1,130 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"strconv"
+	"strings"
+)
+
+// apkSuffixOrder ranks the version suffixes apk knows: pre-release suffixes
+// sort before the plain version, patch suffixes after it
+var apkSuffixOrder = map[string]int{
+	"alpha": -4,
+	"beta":  -3,
+	"pre":   -2,
+	"rc":    -1,
+	"cvs":   1,
+	"svn":   2,
+	"git":   3,
+	"hg":    4,
+	"p":     5,
+}
+
+// compareAPKVersions compares two APK versions such as 3.3.2_rc1-r0,
+// returning -1, 0 or 1
+func compareAPKVersions(a, b string) int {
+	upstreamA, releaseA := splitAPKRelease(a)
+	upstreamB, releaseB := splitAPKRelease(b)
+
+	partsA := strings.Split(upstreamA, "_")
+	partsB := strings.Split(upstreamB, "_")
+	if c := compareVersionNumbers(partsA[0], partsB[0]); c != 0 {
+		return c
+	}
+
+	for i := 1; i < len(partsA) || i < len(partsB); i++ {
+		var suffixA, suffixB string
+		if i < len(partsA) {
+			suffixA = partsA[i]
+		}
+		if i < len(partsB) {
+			suffixB = partsB[i]
+		}
+		if c := compareVersionSuffixes(suffixA, suffixB); c != 0 {
+			return c
+		}
+	}
+
+	return compareInts(releaseA, releaseB)
+}
+
+// splitAPKRelease splits a version into its upstream version and release
+// number, e.g. 3.3.2-r1 into 3.3.2 and 1
+func splitAPKRelease(version string) (string, int) {
+	i := strings.LastIndex(version, "-r")
+	if i < 0 {
+		return version, 0
+	}
+	release, err := strconv.Atoi(version[i+2:])
+	if err != nil {
+		return version, 0
+	}
+	return version[:i], release
+}
+
+// compareVersionNumbers compares dotted versions segment by segment; a
+// segment is a number optionally followed by a letter, e.g. 1.1.1w
+func compareVersionNumbers(a, b string) int {
+	segmentsA := strings.Split(a, ".")
+	segmentsB := strings.Split(b, ".")
+	for i := 0; i < len(segmentsA) || i < len(segmentsB); i++ {
+		// A version with more segments is newer: 1.2.1 > 1.2
+		if i >= len(segmentsA) {
+			return -1
+		}
+		if i >= len(segmentsB) {
+			return 1
+		}
+
+		numberA, letterA := splitVersionSegment(segmentsA[i])
+		numberB, letterB := splitVersionSegment(segmentsB[i])
+		if c := compareInts(numberA, numberB); c != 0 {
+			return c
+		}
+		if c := strings.Compare(letterA, letterB); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+func splitVersionSegment(segment string) (int, string) {
+	i := 0
+	for i < len(segment) && segment[i] >= '0' && segment[i] <= '9' {
+		i++
+	}
+	number, _ := strconv.Atoi(segment[:i])
+	return number, segment[i:]
+}
+
+// compareVersionSuffixes compares suffixes such as rc1 or p2; an empty
+// suffix is the plain version
+func compareVersionSuffixes(a, b string) int {
+	nameA, numberA := splitVersionSuffix(a)
+	nameB, numberB := splitVersionSuffix(b)
+	if c := compareInts(apkSuffixOrder[nameA], apkSuffixOrder[nameB]); c != 0 {
+		return c
+	}
+	return compareInts(numberA, numberB)
+}
+
+func splitVersionSuffix(suffix string) (string, int) {
+	i := 0
+	for i < len(suffix) && (suffix[i] < '0' || suffix[i] > '9') {
+		i++
+	}
+	number, _ := strconv.Atoi(suffix[i:])
+	return suffix[:i], number
+}
+
+func compareInts(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
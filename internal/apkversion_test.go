@@ -0,0 +1,29 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import "testing"
+
+func TestCompareAPKVersions(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected int
+	}{
+		{"3.3.2-r0", "3.3.2-r0", 0},
+		{"3.3.2-r1", "3.3.2-r0", 1},
+		{"3.3.2-r0", "3.3.10-r0", -1},
+		{"3.3-r5", "3.3.1-r0", -1},
+		{"1.1.1w-r0", "1.1.1v-r3", 1},
+		{"3.3.2_rc1-r0", "3.3.2-r0", -1},
+		{"3.3.2_p1-r0", "3.3.2-r0", 1},
+		{"3.3.2_alpha1-r0", "3.3.2_beta1-r0", -1},
+		{"2.0", "1.9-r9", 1},
+	}
+
+	for _, tt := range tests {
+		if got := compareAPKVersions(tt.a, tt.b); got != tt.expected {
+			t.Errorf("compareAPKVersions(%s, %s): expected %d, got %d", tt.a, tt.b, tt.expected, got)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+// ArtifactsEnv names the environment variable pointing tests at the
+// directory to deposit artifacts in when artifact collection is enabled
+const ArtifactsEnv = "APKREGRESS_ARTIFACTS_DIR"
+
+// workspaceArchive is the name of the snapshot of a failed test's scratch
+// directory, which holds melange's workspace
+const workspaceArchive = "workspace.tar.gz"
+
+// coreDumpPattern matches the file names core dumps are written to by
+// default: core, core.<pid> and <program>.core
+var coreDumpPattern = regexp.MustCompile(`^(core(\.\d+)?|.+\.core)$`)
+
+// artifactDir returns the directory the artifacts of a test are collected
+// in, e.g. logs/.../artifacts/curl/with_repo
+func artifactDir(logDir, packageName string, withRepo bool) string {
+	return filepath.Join(logDir, "artifacts", packageName, map[bool]string{true: "with_repo", false: "without_repo"}[withRepo])
+}
+
+// collectArtifacts copies the core dumps left in a test's scratch directory
+// to dir and, if the test failed, snapshots the whole scratch directory.
+// dir is removed again if it ends up empty, e.g. after a passing test that
+// deposited nothing.
+func collectArtifacts(workDir, dir string, failed bool) error {
+	err := filepath.WalkDir(workDir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || !entry.Type().IsRegular() || !coreDumpPattern.MatchString(entry.Name()) {
+			return nil
+		}
+		return copyFile(path, filepath.Join(dir, entry.Name()))
+	})
+	if err == nil && failed {
+		err = archiveDir(workDir, filepath.Join(dir, workspaceArchive))
+	}
+
+	// Remove fails for non-empty directories, which is what is wanted
+	os.Remove(dir)
+	return err
+}
+
+// hasArtifacts reports whether dir exists and contains anything
+func hasArtifacts(dir string) bool {
+	entries, err := os.ReadDir(dir)
+	return err == nil && len(entries) > 0
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("failed to collect %s: %w", src, err)
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return fmt.Errorf("failed to collect %s: %w", src, err)
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return fmt.Errorf("failed to collect %s: %w", src, err)
+	}
+	return out.Close()
+}
+
+// archiveDir writes the regular files, directories and symlinks below dir to
+// a gzipped tarball at dst
+func archiveDir(dir, dst string) error {
+	file, err := os.Create(dst)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", dst, err)
+	}
+	defer file.Close()
+
+	zw := gzip.NewWriter(file)
+	tw := tar.NewWriter(zw)
+
+	err = filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			// Files removed or unreadable while walking are left out
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil || rel == "." {
+			return err
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return nil
+		}
+		var link string
+		switch {
+		case info.Mode()&os.ModeSymlink != 0:
+			if link, err = os.Readlink(path); err != nil {
+				return nil
+			}
+		case !info.Mode().IsRegular() && !info.IsDir():
+			// Sockets, devices and pipes can't be archived meaningfully
+			return nil
+		}
+
+		hdr, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		hdr.Name = filepath.ToSlash(rel)
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		src, err := os.Open(path)
+		if err != nil {
+			return nil
+		}
+		defer src.Close()
+		_, err = io.Copy(tw, src)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to archive %s: %w", dir, err)
+	}
+
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("failed to archive %s: %w", dir, err)
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("failed to archive %s: %w", dir, err)
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestCoreDumpPattern(t *testing.T) {
+	tests := map[string]bool{
+		"core":        true,
+		"core.1234":   true,
+		"python.core": true,
+		"core.c":      false,
+		"score":       false,
+		"corefile":    false,
+	}
+	for name, expected := range tests {
+		if got := coreDumpPattern.MatchString(name); got != expected {
+			t.Errorf("Expected %s to match %v, got %v", name, expected, got)
+		}
+	}
+}
+
+func TestCollectArtifacts(t *testing.T) {
+	workDir := t.TempDir()
+	os.MkdirAll(filepath.Join(workDir, "melange-workspace", "src"), 0755)
+	os.WriteFile(filepath.Join(workDir, "melange-workspace", "src", "main.c"), []byte("int main() {}"), 0644)
+	os.WriteFile(filepath.Join(workDir, "melange-workspace", "core.42"), []byte("dump"), 0644)
+	os.Symlink("src/main.c", filepath.Join(workDir, "melange-workspace", "link"))
+
+	dir := artifactDir(t.TempDir(), "curl", true)
+	if filepath.Base(dir) != "with_repo" || filepath.Base(filepath.Dir(dir)) != "curl" {
+		t.Errorf("Unexpected artifact directory %s", dir)
+	}
+	os.MkdirAll(dir, 0755)
+
+	if err := collectArtifacts(workDir, dir, true); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "core.42")); err != nil || string(data) != "dump" {
+		t.Errorf("Expected the core dump to be collected, got %q (%v)", data, err)
+	}
+
+	file, err := os.Open(filepath.Join(dir, workspaceArchive))
+	if err != nil {
+		t.Fatalf("Expected a workspace snapshot: %v", err)
+	}
+	defer file.Close()
+	zr, err := gzip.NewReader(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	tr := tar.NewReader(zr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, hdr.Name)
+		if hdr.Name == "melange-workspace/link" && hdr.Linkname != "src/main.c" {
+			t.Errorf("Expected the symlink to be kept, got %q", hdr.Linkname)
+		}
+	}
+	sort.Strings(names)
+	expected := []string{"melange-workspace", "melange-workspace/core.42", "melange-workspace/link", "melange-workspace/src", "melange-workspace/src/main.c"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("Expected %v, got %v", expected, names)
+	}
+}
+
+func TestCollectArtifactsRemovesEmptyDirectory(t *testing.T) {
+	workDir := t.TempDir()
+	os.WriteFile(filepath.Join(workDir, "output.txt"), []byte("ok"), 0644)
+
+	dir := artifactDir(t.TempDir(), "curl", false)
+	os.MkdirAll(dir, 0755)
+
+	if err := collectArtifacts(workDir, dir, false); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if hasArtifacts(dir) {
+		t.Errorf("Expected no artifacts for a passing test")
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("Expected the empty artifact directory to be removed, got %v", err)
+	}
+}
@@ -0,0 +1,148 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// runBaseline is the outcome of an earlier run that regressions are compared
+// against, to tell new regressions from known ones
+type runBaseline struct {
+	// Source describes where the baseline came from, e.g. its run ID
+	Source   string
+	Statuses map[string]string
+}
+
+// LatestRun returns the most recent run recorded for target, repoType and
+// the variant of a test matrix ("" outside one), or nil if there is none
+func (db *ResultsDB) LatestRun(target, repoType, variant string) (*RunRecord, error) {
+	runs, err := db.Runs()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := len(runs) - 1; i >= 0; i-- {
+		if runs[i].Target == target && runs[i].RepoType == repoType && runs[i].Variant == variant {
+			return &runs[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// baselineFromRun returns the package statuses of a recorded run
+func baselineFromRun(run *RunRecord) *runBaseline {
+	baseline := &runBaseline{
+		Source:   run.RunID,
+		Statuses: make(map[string]string, len(run.Packages)),
+	}
+	for _, pkg := range run.Packages {
+		baseline.Statuses[pkg.Package] = pkg.Status
+	}
+	return baseline
+}
+
+// loadBaselineResults reads the package statuses from the results.json of an
+// earlier run, given as the file or the run's log directory. Results tagged
+// with another repository type are ignored.
+func loadBaselineResults(path, repoType string) (*runBaseline, error) {
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		path = filepath.Join(path, "results.json")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read previous results: %w", err)
+	}
+
+	var results []struct {
+		Package  string `json:"package"`
+		WithRepo bool   `json:"withRepo"`
+		Success  bool   `json:"success"`
+		Hung     bool   `json:"hung"`
+		Skipped  bool   `json:"skipped"`
+		RepoType string `json:"repoType"`
+		Infra    string `json:"infra"`
+	}
+	if err := json.Unmarshal(data, &results); err != nil {
+		return nil, fmt.Errorf("invalid previous results %s: %w", path, err)
+	}
+
+	type outcome struct {
+		withRepo, withoutRepo            bool
+		ranWithout, hung, skipped, infra bool
+	}
+	outcomes := make(map[string]*outcome)
+	for _, result := range results {
+		if result.RepoType != "" && repoType != "" && result.RepoType != repoType {
+			continue
+		}
+		o := outcomes[result.Package]
+		if o == nil {
+			o = &outcome{}
+			outcomes[result.Package] = o
+		}
+		o.hung = o.hung || result.Hung
+		o.skipped = o.skipped || result.Skipped
+		o.infra = o.infra || result.Infra != ""
+		if result.WithRepo {
+			o.withRepo = result.Success
+		} else {
+			o.ranWithout = true
+			o.withoutRepo = result.Success
+		}
+	}
+
+	baseline := &runBaseline{
+		Source:   path,
+		Statuses: make(map[string]string, len(outcomes)),
+	}
+	for pkg, o := range outcomes {
+		switch {
+		case o.skipped:
+			baseline.Statuses[pkg] = StatusSkipped
+		case o.hung:
+			baseline.Statuses[pkg] = StatusHung
+		case o.infra:
+			baseline.Statuses[pkg] = StatusInfra
+		case o.withRepo:
+			baseline.Statuses[pkg] = StatusPass
+		case o.ranWithout && o.withoutRepo:
+			baseline.Statuses[pkg] = StatusRegression
+		default:
+			baseline.Statuses[pkg] = StatusFail
+		}
+	}
+	return baseline, nil
+}
+
+// regressionNote tells whether a regression was already present in the
+// baseline, or "" without a baseline
+func (b *runBaseline) regressionNote(pkg string) string {
+	if b == nil {
+		return ""
+	}
+	if b.Statuses[pkg] == StatusRegression {
+		return "previously seen"
+	}
+	return "new since last run"
+}
+
+// fixed returns the packages that regressed in the baseline and pass now
+func (b *runBaseline) fixed(statuses map[string]string) []string {
+	if b == nil {
+		return nil
+	}
+
+	var fixed []string
+	for _, pkg := range sortedKeys(b.Statuses) {
+		if b.Statuses[pkg] == StatusRegression && statuses[pkg] == StatusPass {
+			fixed = append(fixed, pkg)
+		}
+	}
+	return fixed
+}
@@ -0,0 +1,107 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestResultsDBLatestRun(t *testing.T) {
+	db, _ := NewResultsDB(filepath.Join(t.TempDir(), "results.jsonl"))
+
+	for _, run := range []RunRecord{
+		{RunID: "run-1", Target: "openssl", RepoType: "wolfi"},
+		{RunID: "run-2", Target: "openssl", RepoType: "enterprise"},
+		{RunID: "run-3", Target: "curl", RepoType: "wolfi"},
+		{RunID: "run-4", Target: "openssl", RepoType: "wolfi"},
+		{RunID: "run-5", Target: "curl", RepoType: "wolfi"},
+	} {
+		if err := db.Append(run); err != nil {
+			t.Fatalf("Failed to append run: %v", err)
+		}
+	}
+
+	run, err := db.LatestRun("openssl", "wolfi", "")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if run == nil || run.RunID != "run-4" {
+		t.Errorf("Expected run-4, got %v", run)
+	}
+
+	run, err = db.LatestRun("zlib", "wolfi", "")
+	if err != nil || run != nil {
+		t.Errorf("Expected no run, got %v (%v)", run, err)
+	}
+}
+
+func TestLoadBaselineResults(t *testing.T) {
+	dir := t.TempDir()
+	results := `[
+  {"package": "curl", "withRepo": true, "success": true},
+  {"package": "git", "withRepo": true, "success": false},
+  {"package": "git", "withRepo": false, "success": true},
+  {"package": "nginx", "withRepo": true, "success": false},
+  {"package": "nginx", "withRepo": false, "success": false},
+  {"package": "llvm", "withRepo": true, "success": false, "hung": true},
+  {"package": "missing", "withRepo": true, "success": false, "skipped": true},
+  {"package": "python", "withRepo": true, "success": false, "repoType": "enterprise"},
+  {"package": "python", "withRepo": false, "success": true, "repoType": "enterprise"}
+]`
+	if err := os.WriteFile(filepath.Join(dir, "results.json"), []byte(results), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	baseline, err := loadBaselineResults(dir, "wolfi")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := map[string]string{
+		"curl":    StatusPass,
+		"git":     StatusRegression,
+		"nginx":   StatusFail,
+		"llvm":    StatusHung,
+		"missing": StatusSkipped,
+	}
+	if !reflect.DeepEqual(baseline.Statuses, expected) {
+		t.Errorf("Expected %v, got %v", expected, baseline.Statuses)
+	}
+
+	if _, err := loadBaselineResults(filepath.Join(dir, "missing.json"), "wolfi"); err == nil {
+		t.Error("Expected error for missing results")
+	}
+}
+
+func TestBaselineAnnotations(t *testing.T) {
+	baseline := &runBaseline{Statuses: map[string]string{
+		"git":   StatusRegression,
+		"rust":  StatusRegression,
+		"cmake": StatusRegression,
+		"nginx": StatusPass,
+	}}
+
+	if note := baseline.regressionNote("git"); note != "previously seen" {
+		t.Errorf("Expected git to be previously seen, got %q", note)
+	}
+	if note := baseline.regressionNote("nginx"); note != "new since last run" {
+		t.Errorf("Expected nginx to be new, got %q", note)
+	}
+	if note := baseline.regressionNote("unknown"); note != "new since last run" {
+		t.Errorf("Expected unknown to be new, got %q", note)
+	}
+
+	var none *runBaseline
+	if note := none.regressionNote("git"); note != "" {
+		t.Errorf("Expected no note without baseline, got %q", note)
+	}
+
+	statuses := map[string]string{"git": StatusRegression, "rust": StatusPass, "cmake": StatusFail}
+	if fixed := baseline.fixed(statuses); !reflect.DeepEqual(fixed, []string{"rust"}) {
+		t.Errorf("Expected [rust], got %v", fixed)
+	}
+}
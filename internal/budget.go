@@ -0,0 +1,56 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// defaultPackageEstimate is the assumed duration of testing a package, both
+// with and without the candidate repository, when the results database has
+// no history
+const defaultPackageEstimate = 10 * time.Minute
+
+// ErrOverBudget is returned when a run would test more packages than
+// allowed and wasn't confirmed
+var ErrOverBudget = errors.New("too many packages to test")
+
+// SetPackageBudget asks confirm before testing more than limit packages,
+// with the estimated runtime, so that a package with thousands of reverse
+// dependencies doesn't start a multi-day run by accident. The run fails with
+// ErrOverBudget unless confirm returns true. A limit of 0 disables the check.
+func (r *RegressionTestRunner) SetPackageBudget(limit int, confirm func(count int, estimate time.Duration) bool) {
+	r.maxPackages = limit
+	r.confirmBudget = confirm
+}
+
+// checkPackageBudget makes sure testing packages is within the budget or
+// confirmed
+func (r *RegressionTestRunner) checkPackageBudget(packages []string, history map[string]time.Duration) error {
+	if r.maxPackages <= 0 || len(packages) <= r.maxPackages {
+		return nil
+	}
+
+	estimate := estimateRunTime(packages, history, r.concurrency)
+	fmt.Printf("Found %d packages to test, more than --max-packages %d; this takes about %v at concurrency %d\n",
+		len(packages), r.maxPackages, estimate.Round(time.Minute), max(r.concurrency, 1))
+	if r.confirmBudget != nil && r.confirmBudget(len(packages), estimate) {
+		return nil
+	}
+	return fmt.Errorf("%w: %d packages exceed --max-packages %d; pass --yes to test them anyway", ErrOverBudget, len(packages), r.maxPackages)
+}
+
+// estimateRunTime predicts how long testing packages takes from their
+// historical durations, or defaultPackageEstimate each if there's no history
+func estimateRunTime(packages []string, history map[string]time.Duration, concurrency int) time.Duration {
+	if len(history) == 0 {
+		history = make(map[string]time.Duration, len(packages))
+		for _, pkg := range packages {
+			history[pkg] = defaultPackageEstimate
+		}
+	}
+	return newETAEstimator(packages, history, concurrency).estimate(time.Now())
+}
@@ -0,0 +1,55 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestEstimateRunTime(t *testing.T) {
+	packages := []string{"curl", "git", "rust", "wget"}
+
+	if got := estimateRunTime(packages, nil, 2); got != 2*defaultPackageEstimate {
+		t.Errorf("Expected %v without history, got %v", 2*defaultPackageEstimate, got)
+	}
+
+	// Packages without history take the average of those with history
+	history := map[string]time.Duration{"curl": time.Hour, "git": 3 * time.Hour}
+	if got := estimateRunTime(packages, history, 2); got != 4*time.Hour {
+		t.Errorf("Expected 4h, got %v", got)
+	}
+}
+
+func TestCheckPackageBudget(t *testing.T) {
+	runner := NewRegressionTestRunner("openssl", "https://example.com/repo", t.TempDir(), "wolfi", 2, false, 30*time.Minute, false)
+	packages := []string{"curl", "git", "wget"}
+
+	tests := []struct {
+		name      string
+		limit     int
+		confirm   func(int, time.Duration) bool
+		expectErr bool
+	}{
+		{name: "disabled", limit: 0},
+		{name: "within budget", limit: 3},
+		{name: "over budget without confirmation", limit: 2, expectErr: true},
+		{name: "over budget refused", limit: 2, confirm: func(int, time.Duration) bool { return false }, expectErr: true},
+		{name: "over budget confirmed", limit: 2, confirm: func(count int, _ time.Duration) bool { return count == 3 }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runner.SetPackageBudget(tt.limit, tt.confirm)
+			err := runner.checkPackageBudget(packages, nil)
+			if tt.expectErr != (err != nil) {
+				t.Fatalf("Expected error %v, got %v", tt.expectErr, err)
+			}
+			if err != nil && !errors.Is(err, ErrOverBudget) {
+				t.Errorf("Expected ErrOverBudget, got %v", err)
+			}
+		})
+	}
+}
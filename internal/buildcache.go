@@ -0,0 +1,56 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// melangeCacheMount is where melange mounts its --cache-dir in the build
+// environment
+const melangeCacheMount = "/var/cache/melange"
+
+// buildCaches are the tool caches shared between tests: the subdirectory of
+// the build cache each one lives in, and the variable pointing the tool at it
+var buildCaches = []struct {
+	dir string
+	env string
+}{
+	{dir: "ccache", env: "CCACHE_DIR"},
+	{dir: "gomodcache", env: "GOMODCACHE"},
+	{dir: "gocache", env: "GOCACHE"},
+	{dir: "cargo", env: "CARGO_HOME"},
+}
+
+// buildCacheEnvFile is the name of the environment file written to the build
+// cache directory
+const buildCacheEnvFile = "apkregress.env"
+
+// PrepareBuildCache creates the shared tool caches (ccache, Go module and
+// build caches, cargo registry) below dir and writes an environment file
+// pointing the tools at them inside melange's build environment. It returns
+// the path of the environment file.
+func PrepareBuildCache(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve build cache directory: %w", err)
+	}
+
+	var env strings.Builder
+	for _, cache := range buildCaches {
+		if err := os.MkdirAll(filepath.Join(dir, cache.dir), 0755); err != nil {
+			return "", fmt.Errorf("failed to create build cache directory: %w", err)
+		}
+		fmt.Fprintf(&env, "%s=%s/%s\n", cache.env, melangeCacheMount, cache.dir)
+	}
+
+	envFile := filepath.Join(dir, buildCacheEnvFile)
+	if err := os.WriteFile(envFile, []byte(env.String()), 0644); err != nil {
+		return "", fmt.Errorf("failed to write build cache environment: %w", err)
+	}
+	return envFile, nil
+}
@@ -0,0 +1,97 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPrepareBuildCache(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "cache")
+
+	envFile, err := PrepareBuildCache(dir)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if envFile != filepath.Join(dir, "apkregress.env") {
+		t.Errorf("Unexpected env file: %s", envFile)
+	}
+
+	for _, sub := range []string{"ccache", "gomodcache", "gocache", "cargo"} {
+		if info, err := os.Stat(filepath.Join(dir, sub)); err != nil || !info.IsDir() {
+			t.Errorf("Expected cache directory %s to exist", sub)
+		}
+	}
+
+	content, err := os.ReadFile(envFile)
+	if err != nil {
+		t.Fatalf("Failed to read env file: %v", err)
+	}
+	for _, line := range []string{"CCACHE_DIR=/var/cache/melange/ccache", "GOMODCACHE=/var/cache/melange/gomodcache", "CARGO_HOME=/var/cache/melange/cargo"} {
+		if !strings.Contains(string(content), line+"\n") {
+			t.Errorf("Expected env file to contain %s, got:\n%s", line, content)
+		}
+	}
+
+	// Preparing an existing cache must keep its contents
+	marker := filepath.Join(dir, "ccache", "marker")
+	os.WriteFile(marker, []byte("x"), 0644)
+	if _, err := PrepareBuildCache(dir); err != nil {
+		t.Fatalf("Unexpected error on existing cache: %v", err)
+	}
+	if _, err := os.Stat(marker); err != nil {
+		t.Error("Expected existing cache contents to be kept")
+	}
+}
+
+func TestMelangeExtraOpts(t *testing.T) {
+	client := NewMelangeClient(t.TempDir(), false, t.TempDir(), time.Minute)
+
+	if opts := client.extraOpts(false, "https://example.com/repo"); len(opts) != 0 {
+		t.Errorf("Expected no extra options, got %v", opts)
+	}
+	expected := []string{"--repository-append", "https://example.com/repo"}
+	if opts := client.extraOpts(true, "https://example.com/repo"); !reflect.DeepEqual(opts, expected) {
+		t.Errorf("Expected %v, got %v", expected, opts)
+	}
+
+	cacheDir := t.TempDir()
+	if err := client.SetBuildCache(cacheDir); err != nil {
+		t.Fatalf("Failed to set build cache: %v", err)
+	}
+	expected = []string{"--cache-dir", cacheDir, "--env-file", filepath.Join(cacheDir, "apkregress.env")}
+	if opts := client.extraOpts(false, "https://example.com/repo"); !reflect.DeepEqual(opts, expected) {
+		t.Errorf("Expected %v, got %v", expected, opts)
+	}
+}
+
+func TestSetAPKCache(t *testing.T) {
+	runner := NewRegressionTestRunner("openssl", "https://example.com/repo", t.TempDir(), "wolfi", 1, false, time.Minute, false)
+	dir := filepath.Join(t.TempDir(), "apks")
+
+	if err := runner.SetAPKCache(dir); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		t.Error("Expected APK cache directory to be created")
+	}
+
+	expected := []string{"--repository-append", "https://example.com/repo", "--apk-cache-dir", dir}
+	if opts := runner.melange.extraOpts(true, "https://example.com/repo"); !reflect.DeepEqual(opts, expected) {
+		t.Errorf("Expected %v, got %v", expected, opts)
+	}
+
+	apkoRunner := NewRegressionTestRunnerFromApkoConfigs(t.TempDir(), "https://example.com/repo", 1, false, time.Minute, false)
+	if err := apkoRunner.SetAPKCache(dir); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if apkoRunner.apko.apkCacheDir != dir {
+		t.Errorf("Expected apko cache dir %s, got %s", dir, apkoRunner.apko.apkCacheDir)
+	}
+}
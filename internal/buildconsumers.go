@@ -0,0 +1,122 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// providedPackages returns the names of the packages target stands for in
+// the index: itself and, if it's an origin, the packages it builds, e.g.
+// openssl-dev
+func (a *ApkraneClient) providedPackages(target string) (map[string]bool, error) {
+	packages, err := a.loadIndex()
+	if err != nil {
+		return nil, err
+	}
+	provided := map[string]bool{target: true}
+	for _, pkg := range packages {
+		if pkg.Origin == target {
+			provided[pkg.Name] = true
+		}
+	}
+	return provided, nil
+}
+
+// buildConsumers returns the origins whose melange config installs one of
+// provided in its build environment, with the packages it declares. Configs
+// that can't be parsed are skipped, as not every YAML file in a package
+// repository is a melange config.
+func buildConsumers(locator ConfigLocator, provided map[string]bool) (map[string][]string, error) {
+	names, err := locator.Packages()
+	if err != nil {
+		return nil, err
+	}
+
+	consumers := make(map[string][]string)
+	parsed := make(map[string]bool)
+	for _, name := range names {
+		path, err := locator.Locate(name)
+		if err != nil || parsed[path] {
+			continue
+		}
+		parsed[path] = true
+		config, err := LoadMelangeConfig(path)
+		if err != nil {
+			continue
+		}
+		origin := config.Package.Name
+		if origin == "" {
+			origin = name
+		}
+		if provided[origin] {
+			continue
+		}
+		for _, dep := range config.Environment.Contents.Packages {
+			if provided[dependencyName(dep)] {
+				consumers[origin] = append(consumers[origin], dep)
+			}
+		}
+	}
+	return consumers, nil
+}
+
+// findBuildConsumers cross-checks the reverse dependencies of target in the
+// index, known, with the build environments of the melange configs in the
+// package repository. The index only lists runtime dependencies, so it
+// misses consumers that only build against target, e.g. with its -dev
+// package. It returns those consumers, sorted.
+func (r *RegressionTestRunner) findBuildConsumers(target string, known []string) ([]string, error) {
+	if r.melange == nil || r.melange.locator == nil {
+		return nil, nil
+	}
+	provided, err := r.apkrane.providedPackages(target)
+	if err != nil {
+		return nil, err
+	}
+	consumers, err := buildConsumers(r.melange.locator, provided)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read melange configs: %w", err)
+	}
+
+	if r.buildDeps == nil {
+		r.buildDeps = make(map[string][]string)
+		r.runtimeDeps = make(map[string]bool)
+	}
+	for _, pkg := range known {
+		r.runtimeDeps[pkg] = true
+	}
+	var missing []string
+	for origin, deps := range consumers {
+		r.buildDeps[origin] = deps
+		if !r.runtimeDeps[origin] {
+			missing = append(missing, origin)
+		}
+	}
+	sort.Strings(missing)
+
+	if len(missing) > 0 {
+		fmt.Printf("Found %d build-time consumers of %s the index doesn't list: %s\n", len(missing), target, strings.Join(missing, ", "))
+	}
+	if r.verbose {
+		for _, origin := range missing {
+			fmt.Printf("  - %s builds with %s\n", origin, strings.Join(consumers[origin], ", "))
+		}
+	}
+	return missing, nil
+}
+
+// addDirect adds origins to the direct consumers of the graph
+func (g *ConsumerGraph) addDirect(origins []string) {
+	if len(origins) == 0 {
+		return
+	}
+	if len(g.Levels) == 0 {
+		g.Levels = [][]string{nil}
+	}
+	g.Levels[0] = append(g.Levels[0], origins...)
+	sort.Strings(g.Levels[0])
+}
@@ -0,0 +1,58 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFindBuildConsumers(t *testing.T) {
+	repoPath := t.TempDir()
+	configs := map[string]string{
+		"curl.yaml":    "package:\n  name: curl\nenvironment:\n  contents:\n    packages: [build-base, openssl-dev]\n",
+		"nginx.yaml":   "package:\n  name: nginx\nenvironment:\n  contents:\n    packages: [build-base, openssl-dev>3, zlib-dev]\n",
+		"haproxy.yaml": "package:\n  name: haproxy\nenvironment:\n  contents:\n    packages: [openssl]\n",
+		"zlib.yaml":    "package:\n  name: zlib\nenvironment:\n  contents:\n    packages: [build-base]\n",
+		"openssl.yaml": "package:\n  name: openssl\nenvironment:\n  contents:\n    packages: [openssl-dev]\n",
+		"broken.yaml":  "package: [\n",
+	}
+	for name, content := range configs {
+		if err := os.WriteFile(filepath.Join(repoPath, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	runner := NewRegressionTestRunner("openssl", "https://example.com/repo", repoPath, "wolfi", 2, false, time.Minute, false)
+	runner.apkrane.packages = append([]Package{{Name: "openssl-dev", Origin: "openssl"}}, consumerIndex...)
+
+	graph, err := runner.apkrane.GetConsumerGraph("openssl", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	missing, err := runner.findBuildConsumers("openssl", graph.Packages())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"haproxy", "nginx"}; !reflect.DeepEqual(missing, expected) {
+		t.Errorf("Expected build-time consumers %v, got %v", expected, missing)
+	}
+	expectedDeps := map[string][]string{
+		"curl":    {"openssl-dev"},
+		"haproxy": {"openssl"},
+		"nginx":   {"openssl-dev>3"},
+	}
+	if !reflect.DeepEqual(runner.buildDeps, expectedDeps) {
+		t.Errorf("Expected declared build dependencies %v, got %v", expectedDeps, runner.buildDeps)
+	}
+
+	graph.addDirect(missing)
+	expectedLevels := [][]string{{"curl", "haproxy", "nginx", "wget"}, {"git", "httpie"}}
+	if !reflect.DeepEqual(graph.Levels, expectedLevels) {
+		t.Errorf("Expected levels %v, got %v", expectedLevels, graph.Levels)
+	}
+}
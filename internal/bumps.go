@@ -0,0 +1,295 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Bump is a melange config whose package version was bumped, e.g. by
+// wolfictl bump or an automated update PR
+type Bump struct {
+	Path string `json:"path"`
+	// Packages are the main package and subpackages the config produces
+	Packages []string `json:"packages"`
+	// Version is the full version after the bump, e.g. 3.3.2-r0
+	Version string `json:"version"`
+}
+
+// Name is the name of the bumped package
+func (b Bump) Name() string {
+	return b.Packages[0]
+}
+
+// BumpResult is the outcome of testing the reverse dependencies of a bump
+type BumpResult struct {
+	Bump
+	// PreviousVersion is the version in the index the bump replaces
+	PreviousVersion     string   `json:"previousVersion,omitempty"`
+	ReverseDependencies int      `json:"reverseDependencies"`
+	Tested              int      `json:"tested"`
+	Regressions         []string `json:"regressions"`
+}
+
+// LoadBumps reads the bumped configs in the package repository at repoPath.
+// Entries ending in .yaml or .yml are config paths relative to repoPath,
+// anything else is a package name found with locator. Configs are read at
+// the git ref, or from the working tree if ref is empty. YAML files that
+// aren't melange configs, such as pipelines, are left out.
+func LoadBumps(repoPath string, entries []string, ref string, locator ConfigLocator) ([]Bump, error) {
+	seen := make(map[string]bool)
+	var bumps []Bump
+	for _, entry := range entries {
+		path := filepath.ToSlash(entry)
+		if !strings.HasSuffix(entry, ".yaml") && !strings.HasSuffix(entry, ".yml") {
+			located, err := locator.Locate(entry)
+			if err != nil {
+				return nil, fmt.Errorf("no melange config for %s: %w", entry, err)
+			}
+			rel, err := filepath.Rel(repoPath, located)
+			if err != nil {
+				return nil, err
+			}
+			path = filepath.ToSlash(rel)
+		}
+		if seen[path] {
+			continue
+		}
+		seen[path] = true
+
+		data, err := readConfigAt(repoPath, path, ref)
+		if err != nil {
+			return nil, err
+		}
+		var config MelangeConfig
+		if err := yaml.Unmarshal(data, &config); err != nil || config.Package.Name == "" {
+			continue
+		}
+		bumps = append(bumps, Bump{
+			Path:     filepath.FromSlash(path),
+			Packages: config.PackageNames(),
+			Version:  fmt.Sprintf("%s-r%d", config.Package.Version, config.Package.Epoch),
+		})
+	}
+
+	sort.Slice(bumps, func(i, j int) bool { return bumps[i].Path < bumps[j].Path })
+	return bumps, nil
+}
+
+// readConfigAt reads the file at path, relative to repoPath, at the git ref
+// or from the working tree if ref is empty
+func readConfigAt(repoPath, path, ref string) ([]byte, error) {
+	if ref == "" {
+		data, err := os.ReadFile(filepath.Join(repoPath, filepath.FromSlash(path)))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		return data, nil
+	}
+
+	show := exec.Command("git", "show", fmt.Sprintf("%s:%s", ref, path))
+	show.Dir = repoPath
+	data, err := show.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s at %s: %w", path, ref, commandError(err))
+	}
+	return data, nil
+}
+
+// PullRequestConfigs returns the YAML files a GitHub pull request against
+// the repository at repoPath adds or modifies, and the commit of its head to
+// read them at. The head is fetched from origin; the gh CLI lists the files.
+func PullRequestConfigs(repoPath string, number int) (paths []string, ref string, err error) {
+	diff := exec.Command("gh", "pr", "diff", strconv.Itoa(number), "--name-only")
+	diff.Dir = repoPath
+	output, err := diff.Output()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to list the files of PR #%d: %w", number, commandError(err))
+	}
+
+	fetch := exec.Command("git", "fetch", "-q", "origin", fmt.Sprintf("refs/pull/%d/head", number))
+	fetch.Dir = repoPath
+	if _, err := fetch.Output(); err != nil {
+		return nil, "", fmt.Errorf("failed to fetch PR #%d: %w", number, commandError(err))
+	}
+	revParse := exec.Command("git", "rev-parse", "FETCH_HEAD")
+	revParse.Dir = repoPath
+	head, err := revParse.Output()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to resolve the head of PR #%d: %w", number, commandError(err))
+	}
+	ref = strings.TrimSpace(string(head))
+
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		path := strings.TrimSpace(scanner.Text())
+		if !strings.HasSuffix(path, ".yaml") && !strings.HasSuffix(path, ".yml") {
+			continue
+		}
+		// Files the PR deletes don't exist at its head
+		exists := exec.Command("git", "cat-file", "-e", fmt.Sprintf("%s:%s", ref, path))
+		exists.Dir = repoPath
+		if exists.Run() != nil {
+			continue
+		}
+		paths = append(paths, path)
+	}
+	return paths, ref, scanner.Err()
+}
+
+// CommentOnPullRequest posts body as a comment on a GitHub pull request
+// against the repository at repoPath with the gh CLI
+func CommentOnPullRequest(repoPath string, number int, body string) error {
+	cmd := exec.Command("gh", "pr", "comment", strconv.Itoa(number), "--body-file", "-")
+	cmd.Dir = repoPath
+	cmd.Stdin = strings.NewReader(body)
+	if _, err := cmd.Output(); err != nil {
+		return fmt.Errorf("failed to comment on PR #%d: %w", number, commandError(err))
+	}
+	return nil
+}
+
+// RunBumps tests the reverse dependencies of every package the bumps
+// produce in a single run, then reports the outcome of each bump and
+// writes it to bumps.json in the log directory. The results are returned
+// with the error of the run.
+func (r *RegressionTestRunner) RunBumps(bumps []Bump) ([]BumpResult, error) {
+	var targets []string
+	for _, bump := range bumps {
+		targets = append(targets, bump.Packages...)
+	}
+	runErr := r.RunReverseDependencies(targets)
+	if runErr != nil && r.packageResults == nil && !r.empty {
+		return nil, runErr
+	}
+
+	results := r.bumpResults(bumps)
+	r.printBumpResults(results)
+	r.writeBumpsJSON(results)
+	return results, runErr
+}
+
+// bumpResults counts the tested reverse dependencies and regressions of each
+// bump
+func (r *RegressionTestRunner) bumpResults(bumps []Bump) []BumpResult {
+	skipped := make(map[string]bool)
+	for _, pkg := range r.summary.Skipped {
+		skipped[pkg] = true
+	}
+	regressed := make(map[string]bool)
+	for _, pkg := range r.summary.Regressions {
+		regressed[pkg] = true
+	}
+
+	results := make([]BumpResult, 0, len(bumps))
+	for _, bump := range bumps {
+		result := BumpResult{Bump: bump, Regressions: []string{}}
+		if previous, err := r.apkrane.LatestVersion(bump.Name()); err == nil {
+			result.PreviousVersion = previous
+		}
+
+		for _, consumer := range r.bumpConsumers(bump) {
+			result.ReverseDependencies++
+			if _, tested := r.packageResults[consumer]; tested && !skipped[consumer] {
+				result.Tested++
+			}
+			if regressed[consumer] {
+				result.Regressions = append(result.Regressions, consumer)
+			}
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// bumpConsumers returns the sorted reverse dependencies of the packages of
+// bump. A run of a single target doesn't record targets, so all of its
+// packages are consumers of that target.
+func (r *RegressionTestRunner) bumpConsumers(bump Bump) []string {
+	if len(r.targetsOf) == 0 {
+		return sortedKeys(r.packageResults)
+	}
+
+	produced := make(map[string]bool)
+	for _, pkg := range bump.Packages {
+		produced[pkg] = true
+	}
+	var consumers []string
+	for _, consumer := range sortedKeys(r.targetsOf) {
+		for _, target := range r.targetsOf[consumer] {
+			if produced[target] {
+				consumers = append(consumers, consumer)
+				break
+			}
+		}
+	}
+	return consumers
+}
+
+// versionChange describes the version change of a bump, e.g.
+// "3.3.1-r2 → 3.3.2-r0"
+func (b BumpResult) versionChange() string {
+	if b.PreviousVersion == "" {
+		return b.Version
+	}
+	return fmt.Sprintf("%s → %s", b.PreviousVersion, b.Version)
+}
+
+// printBumpResults lists the outcome of each bump
+func (r *RegressionTestRunner) printBumpResults(results []BumpResult) {
+	if r.markdownOutput {
+		fmt.Printf("\n%s", BumpResultsMarkdown(results))
+		return
+	}
+
+	fmt.Printf("\nResults by bump:\n")
+	for _, b := range results {
+		fmt.Printf("  - %s %s (%s): %d reverse dependencies, %d tested, %d regressions", b.Name(), b.versionChange(), b.Path, b.ReverseDependencies, b.Tested, len(b.Regressions))
+		if len(b.Regressions) > 0 {
+			fmt.Printf(": %s", strings.Join(b.Regressions, ", "))
+		}
+		fmt.Println()
+	}
+}
+
+// BumpResultsMarkdown renders the outcome of each bump as a markdown table,
+// e.g. for a PR comment
+func BumpResultsMarkdown(results []BumpResult) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "### 📦 Results by Bump\n\n")
+	fmt.Fprintf(&b, "| Package | Version | Reverse dependencies | Tested | Regressions |\n")
+	fmt.Fprintf(&b, "|---------|---------|----------------------|--------|-------------|\n")
+	for _, result := range results {
+		regressions := "none"
+		if len(result.Regressions) > 0 {
+			regressions = fmt.Sprintf("**%d**: `%s`", len(result.Regressions), strings.Join(result.Regressions, "`, `"))
+		}
+		fmt.Fprintf(&b, "| `%s` | %s | %d | %d | %s |\n", result.Name(), result.versionChange(), result.ReverseDependencies, result.Tested, regressions)
+	}
+	return b.String()
+}
+
+// writeBumpsJSON writes the outcome of each bump to bumps.json
+func (r *RegressionTestRunner) writeBumpsJSON(results []BumpResult) {
+	data, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		fmt.Printf("Warning: failed to encode bumps.json: %v\n", err)
+		return
+	}
+
+	if err := os.WriteFile(filepath.Join(r.logDir, "bumps.json"), append(data, '\n'), 0644); err != nil {
+		fmt.Printf("Warning: failed to write bumps.json: %v\n", err)
+	}
+}
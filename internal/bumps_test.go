@@ -0,0 +1,157 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadBumps(t *testing.T) {
+	repo := t.TempDir()
+	write := func(name, content string) {
+		t.Helper()
+		path := filepath.Join(repo, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("openssl.yaml", "package:\n  name: openssl\n  version: 3.3.2\n  epoch: 1\nsubpackages:\n  - name: libssl3\n")
+	write("zlib.yaml", "package:\n  name: zlib\n  version: 1.3.1\n")
+	write("pipelines/build.yaml", "name: build\npipeline: []\n")
+
+	locator, err := NewConfigLocator(repo, "flat")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bumps, err := LoadBumps(repo, []string{"zlib", "openssl.yaml", "pipelines/build.yaml", "zlib.yaml"}, "", locator)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	expected := []Bump{
+		{Path: "openssl.yaml", Packages: []string{"openssl", "libssl3"}, Version: "3.3.2-r1"},
+		{Path: "zlib.yaml", Packages: []string{"zlib"}, Version: "1.3.1-r0"},
+	}
+	if !reflect.DeepEqual(bumps, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, bumps)
+	}
+
+	if _, err := LoadBumps(repo, []string{"curl"}, "", locator); err == nil {
+		t.Errorf("Expected an error for a package without a config")
+	}
+	if _, err := LoadBumps(repo, []string{"curl.yaml"}, "", locator); err == nil {
+		t.Errorf("Expected an error for a missing config")
+	}
+}
+
+func TestLoadBumpsAtRef(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+
+	repo := t.TempDir()
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		cmd.Dir = repo
+		if output, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %v\n%s", args, err, output)
+		}
+	}
+	path := filepath.Join(repo, "openssl.yaml")
+
+	git("init", "-q")
+	if err := os.WriteFile(path, []byte("package:\n  name: openssl\n  version: 3.3.2\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	git("add", ".")
+	git("commit", "-q", "-m", "bump")
+	git("tag", "bump")
+	if err := os.WriteFile(path, []byte("package:\n  name: openssl\n  version: 3.3.1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	locator, err := NewConfigLocator(repo, "flat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bumps, err := LoadBumps(repo, []string{"openssl.yaml"}, "bump", locator)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(bumps) != 1 || bumps[0].Version != "3.3.2-r0" {
+		t.Errorf("Expected the config to be read at the ref, got %+v", bumps)
+	}
+}
+
+func TestRunBumps(t *testing.T) {
+	repoPath := t.TempDir()
+	for _, name := range []string{"curl", "git", "nginx"} {
+		if err := os.WriteFile(filepath.Join(repoPath, name+".yaml"), []byte("package:\n  name: "+name+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	runner := NewRegressionTestRunner("bumps", "https://example.com/repo", repoPath, "wolfi", 2, true, time.Minute, false)
+	logDir := t.TempDir()
+	runner.setLogDir(logDir)
+	runner.apkrane.packages = []Package{
+		{Name: "openssl", Origin: "openssl", Version: "3.3.1-r2"},
+		{Name: "libssl3", Origin: "openssl", Version: "3.3.1-r2"},
+		{Name: "zlib", Origin: "zlib", Version: "1.3.0-r0"},
+		{Name: "curl", Origin: "curl", Dependencies: []string{"libssl3", "zlib"}},
+		{Name: "git", Origin: "git", Dependencies: []string{"zlib"}},
+		{Name: "nginx", Origin: "nginx", Dependencies: []string{"openssl"}},
+	}
+	runner.SetExecutor(TestExecutorFunc(func(ctx context.Context, pkg string, opts ExecuteOptions) TestResult {
+		var err error
+		if pkg == "nginx" && opts.WithRepo {
+			err = errors.New("test failed")
+		}
+		return newTestResult(pkg, opts.WithRepo, time.Now(), "", err)
+	}))
+
+	bumps := []Bump{
+		{Path: "openssl.yaml", Packages: []string{"openssl", "libssl3"}, Version: "3.3.2-r0"},
+		{Path: "zlib.yaml", Packages: []string{"zlib"}, Version: "1.3.1-r0"},
+	}
+	results, err := runner.RunBumps(bumps)
+	if err == nil || err.Error() != "found 1 regressions" {
+		t.Errorf("Expected 1 regression, got %v", err)
+	}
+
+	expected := []BumpResult{
+		{Bump: bumps[0], PreviousVersion: "3.3.1-r2", ReverseDependencies: 2, Tested: 2, Regressions: []string{"nginx"}},
+		{Bump: bumps[1], PreviousVersion: "1.3.0-r0", ReverseDependencies: 2, Tested: 2, Regressions: []string{}},
+	}
+	if !reflect.DeepEqual(results, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, results)
+	}
+
+	data, err := os.ReadFile(filepath.Join(logDir, "bumps.json"))
+	if err != nil {
+		t.Fatalf("Expected bumps.json to be written: %v", err)
+	}
+	var written []BumpResult
+	if err := json.Unmarshal(data, &written); err != nil || !reflect.DeepEqual(written, expected) {
+		t.Errorf("Expected bumps.json to hold the results, got %s (%v)", data, err)
+	}
+
+	markdown := BumpResultsMarkdown(results)
+	if !strings.Contains(markdown, "| `openssl` | 3.3.1-r2 → 3.3.2-r0 | 2 | 2 | **1**: `nginx` |") {
+		t.Errorf("Unexpected markdown:\n%s", markdown)
+	}
+}
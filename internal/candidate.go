@@ -0,0 +1,424 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// apkIndexEntry is a package listed in an APKINDEX
+type apkIndexEntry struct {
+	Name    string
+	Version string
+	Origin  string
+	// Provides are the names of the virtual packages, shared objects and
+	// commands the package provides, without versions
+	Provides []string
+	// Dependencies are the package's dependencies as listed, with version
+	// constraints
+	Dependencies []string
+}
+
+// apkIndexSignature is the signature of an APKINDEX: the signing key's name
+// and the hash the signature was computed with
+type apkIndexSignature struct {
+	KeyName   string
+	Hash      crypto.Hash
+	Signature []byte
+}
+
+// apkIndex is a parsed APKINDEX.tar.gz
+type apkIndex struct {
+	Signature *apkIndexSignature
+	// Signed is the part of the file the signature covers
+	Signed   []byte
+	Packages []apkIndexEntry
+}
+
+// ValidateCandidateRepo checks that the candidate repository serves an index
+// for the host architecture before any test runs, so that a --repo pointing
+// at the wrong architecture or an unindexed directory fails early instead of
+// silently testing nothing. When keys are given the index signature must
+// verify with one of them, and when packageName is given the index must
+// contain it, at version if that is set.
+func ValidateCandidateRepo(repo string, keys []string, packageName, version string, verbose bool) error {
+	indexURL := candidateIndexURL(repo, hostArch())
+	if verbose {
+		fmt.Printf("Checking candidate repository index %s\n", indexURL)
+	}
+
+	index, err := loadCandidateIndex(indexURL)
+	if err != nil {
+		return err
+	}
+
+	if len(keys) > 0 {
+		if err := verifyAPKIndex(index, keys); err != nil {
+			return fmt.Errorf("%w: index %s: %w", ErrIndexSignature, indexURL, err)
+		}
+	}
+
+	if len(index.Packages) == 0 {
+		return fmt.Errorf("index %s lists no packages", indexURL)
+	}
+
+	if packageName == "" {
+		return nil
+	}
+
+	var versions []string
+	for _, pkg := range index.Packages {
+		if pkg.Name != packageName {
+			continue
+		}
+		if version == "" || pkg.Version == version || strings.HasPrefix(pkg.Version, version+"-r") {
+			return nil
+		}
+		versions = append(versions, pkg.Version)
+	}
+
+	if len(versions) == 0 {
+		return fmt.Errorf("package %s is not in the candidate repository index %s", packageName, indexURL)
+	}
+	return fmt.Errorf("candidate repository has %s %s, expected version %s", packageName, strings.Join(versions, ", "), version)
+}
+
+// apkArchitectures are the architecture names used as directory names in
+// APK repositories
+var apkArchitectures = []string{"x86_64", "aarch64", "armv7", "armhf", "x86", "ppc64le", "s390x", "riscv64", "loongarch64"}
+
+// repoArch returns the architecture a repository URL or path refers to,
+// i.e. its last path segment naming an architecture, or "" if there is none
+func repoArch(repo string) string {
+	segments := strings.Split(strings.TrimSuffix(repo, "/"), "/")
+	for i := len(segments) - 1; i >= 0; i-- {
+		for _, arch := range apkArchitectures {
+			if segments[i] == arch {
+				return arch
+			}
+		}
+	}
+	return ""
+}
+
+// CheckRepoArch returns an error if the candidate repository refers to a
+// different architecture than the host's, which is both the architecture of
+// the index used to discover reverse dependencies and the one melange builds
+// for. Such runs would test packages that are never installed.
+func CheckRepoArch(repo string) error {
+	arch := repoArch(repo)
+	if arch == "" || arch == hostArch() {
+		return nil
+	}
+
+	segments := strings.Split(repo, "/")
+	for i := len(segments) - 1; i >= 0; i-- {
+		if segments[i] == arch {
+			segments[i] = hostArch()
+			break
+		}
+	}
+	return fmt.Errorf("--repo %s is for %s, but tests run on %s (did you mean %s?)", repo, arch, hostArch(), strings.Join(segments, "/"))
+}
+
+// candidateIndexURL returns the location of the APKINDEX for arch in repo,
+// which is either the index itself or the repository root (as passed to
+// --repository-append), below which each architecture has a directory
+func candidateIndexURL(repo, arch string) string {
+	if strings.HasSuffix(repo, "APKINDEX.tar.gz") {
+		return repo
+	}
+	return fmt.Sprintf("%s/%s/APKINDEX.tar.gz", strings.TrimSuffix(repo, "/"), arch)
+}
+
+// fetchCandidateIndex reads an index from a URL or a local path
+func fetchCandidateIndex(location string) ([]byte, error) {
+	u, err := url.Parse(location)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		path := strings.TrimPrefix(location, "file://")
+		data, err := os.ReadFile(path)
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("%w: candidate repository has no index at %s; check that --repo points at an indexed repository with packages for %s", ErrIndexFetch, path, hostArch())
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: failed to read candidate repository index: %w", ErrIndexFetch, err)
+		}
+		recordIndexDigest(path, data)
+		return data, nil
+	}
+
+	if FakeBackend() {
+		return fakeIndex("fixtures/candidate")
+	}
+
+	// apk.cgr.dev needs a token whichever mirror serves it; the credentials
+	// file applies to the host actually contacted
+	req, err := http.NewRequest(http.MethodGet, mirrorURL(location), nil)
+	if err != nil {
+		return nil, err
+	}
+	auth, ok, err := hostCredentials(req.URL.Host)
+	if !ok && u.Host == "apk.cgr.dev" {
+		auth, err = chainctlCredentials(req.URL.Host, u.Host)
+	}
+	if err != nil {
+		return nil, err
+	}
+	auth.apply(req)
+
+	client := &http.Client{Timeout: 5 * time.Minute}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch candidate repository index: %w", fetchError(err))
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, fmt.Errorf("%w: candidate repository has no index at %s (%s); check that --repo points at an indexed repository with packages for %s", ErrIndexFetch, location, resp.Status, hostArch())
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return nil, fmt.Errorf("%w: candidate repository index %s: %s", ErrAuth, location, resp.Status)
+	default:
+		return nil, fmt.Errorf("%w: candidate repository index %s: %s", ErrIndexFetch, location, resp.Status)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to read candidate repository index: %w", ErrIndexFetch, err)
+	}
+	recordIndexDigest(req.URL.String(), data)
+	return data, nil
+}
+
+// parseAPKIndex parses an APKINDEX.tar.gz. Signed indexes consist of two
+// concatenated gzip streams: a tarball holding the signature, followed by
+// the signed tarball holding the APKINDEX file.
+func parseAPKIndex(data []byte) (*apkIndex, error) {
+	index := &apkIndex{}
+	sig, signed, err := scanAPKIndex(data, func(entry apkIndexEntry) {
+		index.Packages = append(index.Packages, entry)
+	})
+	if err != nil {
+		return nil, err
+	}
+	index.Signature, index.Signed = sig, signed
+	return index, nil
+}
+
+// scanAPKIndex passes the packages of an APKINDEX.tar.gz to fn as they are
+// decompressed, so that the uncompressed index, which is several times the
+// size of the download, is never held in memory. It returns the signature
+// and the part of data it covers if the index is signed.
+func scanAPKIndex(data []byte, fn func(apkIndexEntry)) (*apkIndexSignature, []byte, error) {
+	// bytes.Reader is an io.ByteReader, so gzip doesn't read past the end of
+	// the first stream and the reader's offset marks where the second begins
+	reader := bytes.NewReader(data)
+	zr, err := gzip.NewReader(reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	zr.Multistream(false)
+
+	tr := tar.NewReader(zr)
+	hdr, err := tr.Next()
+	if err != nil {
+		return nil, nil, err
+	}
+	sig, err := readSignature(hdr, tr)
+	if err != nil {
+		return nil, nil, err
+	}
+	if sig == nil {
+		return nil, nil, scanAPKIndexEntries(tr, hdr, fn)
+	}
+
+	if _, err := io.Copy(io.Discard, zr); err != nil {
+		return nil, nil, err
+	}
+	signed := data[len(data)-reader.Len():]
+	if err := zr.Reset(reader); err != nil {
+		return nil, nil, fmt.Errorf("missing index after signature: %w", err)
+	}
+	zr.Multistream(false)
+
+	tr = tar.NewReader(zr)
+	hdr, err = tr.Next()
+	if err != nil {
+		return nil, nil, fmt.Errorf("missing index after signature: %w", err)
+	}
+	return sig, signed, scanAPKIndexEntries(tr, hdr, fn)
+}
+
+// readSignature returns the signature in the first file of a tarball, or
+// nil if the tarball isn't a signature
+func readSignature(hdr *tar.Header, r io.Reader) (*apkIndexSignature, error) {
+	var hash crypto.Hash
+	var keyName string
+	switch {
+	case strings.HasPrefix(hdr.Name, ".SIGN.RSA256."):
+		hash, keyName = crypto.SHA256, strings.TrimPrefix(hdr.Name, ".SIGN.RSA256.")
+	case strings.HasPrefix(hdr.Name, ".SIGN.RSA."):
+		hash, keyName = crypto.SHA1, strings.TrimPrefix(hdr.Name, ".SIGN.RSA.")
+	default:
+		return nil, nil
+	}
+
+	signature, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return &apkIndexSignature{KeyName: keyName, Hash: hash, Signature: signature}, nil
+}
+
+// scanAPKIndexEntries finds the APKINDEX file in a tarball, starting at the
+// file of hdr, and passes its package stanzas to fn as they are read
+func scanAPKIndexEntries(tr *tar.Reader, hdr *tar.Header, fn func(apkIndexEntry)) error {
+	for hdr.Name != "APKINDEX" {
+		var err error
+		hdr, err = tr.Next()
+		if err == io.EOF {
+			return errors.New("no APKINDEX file found")
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	var current apkIndexEntry
+	scanner := bufio.NewScanner(tr)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			if current.Name != "" {
+				fn(current)
+			}
+			current = apkIndexEntry{}
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "P":
+			current.Name = value
+		case "V":
+			current.Version = value
+		case "o":
+			current.Origin = value
+		case "D":
+			current.Dependencies = strings.Fields(value)
+		case "p":
+			for _, provide := range strings.Fields(value) {
+				name, _, _ := strings.Cut(provide, "=")
+				current.Provides = append(current.Provides, name)
+			}
+		}
+	}
+	if current.Name != "" {
+		fn(current)
+	}
+
+	return scanner.Err()
+}
+
+// verifyAPKIndex checks the index signature against the public key files
+// whose base name matches the signing key name
+func verifyAPKIndex(index *apkIndex, keys []string) error {
+	if index.Signature == nil {
+		return errors.New("index is not signed")
+	}
+
+	for _, keyFile := range keys {
+		if filepath.Base(keyFile) != index.Signature.KeyName {
+			continue
+		}
+
+		key, err := loadRSAPublicKey(keyFile)
+		if err != nil {
+			return err
+		}
+
+		var digest []byte
+		if index.Signature.Hash == crypto.SHA256 {
+			sum := sha256.Sum256(index.Signed)
+			digest = sum[:]
+		} else {
+			sum := sha1.Sum(index.Signed)
+			digest = sum[:]
+		}
+
+		if err := rsa.VerifyPKCS1v15(key, index.Signature.Hash, digest, index.Signature.Signature); err != nil {
+			return fmt.Errorf("signature does not verify with key %s", keyFile)
+		}
+		return nil
+	}
+
+	return fmt.Errorf("index is signed with key %s, which is not among the provided keys", index.Signature.KeyName)
+}
+
+// loadRSAPublicKey reads a PEM encoded RSA public key from a file or an
+// http(s) URL
+func loadRSAPublicKey(path string) (*rsa.PublicKey, error) {
+	data, err := readKey(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key %s: %w", path, err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("key %s is not PEM encoded", path)
+	}
+
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse key %s: %w", path, err)
+	}
+	key, ok := pub.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("key %s is not an RSA key", path)
+	}
+	return key, nil
+}
+
+// readKey reads a key file, or downloads it. Keys are fetched from where
+// they are published, never from a --mirror, which verification guards
+// against.
+func readKey(location string) ([]byte, error) {
+	if !strings.HasPrefix(location, "https://") && !strings.HasPrefix(location, "http://") {
+		return os.ReadFile(location)
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Get(location)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET returned %s", resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+}
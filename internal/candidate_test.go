@@ -0,0 +1,245 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// gzipTar returns a gzipped tarball holding a single file
+func gzipTar(t *testing.T, name string, content []byte) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(zw)
+	if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content))}); err != nil {
+		t.Fatalf("Failed to write tar header: %v", err)
+	}
+	if _, err := tw.Write(content); err != nil {
+		t.Fatalf("Failed to write tar content: %v", err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("Failed to close tar: %v", err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("Failed to close gzip: %v", err)
+	}
+	return buf.Bytes()
+}
+
+const testAPKIndex = `P:openssl
+V:3.3.2-r1
+o:openssl
+
+P:libcrypto3
+V:3.3.2-r1
+o:openssl
+`
+
+// signedIndex returns an APKINDEX.tar.gz signed with key under keyName, and
+// writes the matching public key to dir/keyName
+func signedIndex(t *testing.T, dir, keyName string) []byte {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	pub, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v", err)
+	}
+	pemKey := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pub})
+	if err := os.WriteFile(filepath.Join(dir, keyName), pemKey, 0644); err != nil {
+		t.Fatalf("Failed to write key: %v", err)
+	}
+
+	signed := gzipTar(t, "APKINDEX", []byte(testAPKIndex))
+	digest := sha256.Sum256(signed)
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatalf("Failed to sign index: %v", err)
+	}
+
+	return append(gzipTar(t, ".SIGN.RSA256."+keyName, signature), signed...)
+}
+
+func TestCandidateIndexURL(t *testing.T) {
+	tests := []struct {
+		repo     string
+		expected string
+	}{
+		{"https://example.com/os", "https://example.com/os/x86_64/APKINDEX.tar.gz"},
+		{"https://example.com/os/", "https://example.com/os/x86_64/APKINDEX.tar.gz"},
+		{"https://example.com/os/x86_64/APKINDEX.tar.gz", "https://example.com/os/x86_64/APKINDEX.tar.gz"},
+		{"./packages", "./packages/x86_64/APKINDEX.tar.gz"},
+	}
+
+	for _, tt := range tests {
+		if got := candidateIndexURL(tt.repo, "x86_64"); got != tt.expected {
+			t.Errorf("candidateIndexURL(%s): expected %s, got %s", tt.repo, tt.expected, got)
+		}
+	}
+}
+
+func TestCheckRepoArch(t *testing.T) {
+	other := "aarch64"
+	if hostArch() == "aarch64" {
+		other = "x86_64"
+	}
+
+	tests := []struct {
+		repo          string
+		expectedError string
+	}{
+		{repo: "https://packages.wolfi.dev/os"},
+		{repo: "https://packages.wolfi.dev/os/" + hostArch() + "/APKINDEX.tar.gz"},
+		{repo: "/work/packages/" + hostArch()},
+		{repo: "./packages"},
+		{
+			repo:          "https://packages.wolfi.dev/os/" + other + "/APKINDEX.tar.gz",
+			expectedError: "did you mean https://packages.wolfi.dev/os/" + hostArch() + "/APKINDEX.tar.gz?",
+		},
+		{
+			repo:          "/work/packages/" + other + "/",
+			expectedError: "is for " + other + ", but tests run on " + hostArch(),
+		},
+	}
+
+	for _, tt := range tests {
+		err := CheckRepoArch(tt.repo)
+		if tt.expectedError == "" {
+			if err != nil {
+				t.Errorf("Unexpected error for %s: %v", tt.repo, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.expectedError) {
+			t.Errorf("Expected error for %s to contain '%s', got: %v", tt.repo, tt.expectedError, err)
+		}
+	}
+}
+
+func TestParseAPKIndex(t *testing.T) {
+	dir := t.TempDir()
+
+	index, err := parseAPKIndex(signedIndex(t, dir, "test.rsa.pub"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if index.Signature == nil || index.Signature.KeyName != "test.rsa.pub" || index.Signature.Hash != crypto.SHA256 {
+		t.Errorf("Unexpected signature: %+v", index.Signature)
+	}
+	if len(index.Packages) != 2 || index.Packages[1].Name != "libcrypto3" || index.Packages[1].Origin != "openssl" {
+		t.Errorf("Unexpected packages: %+v", index.Packages)
+	}
+
+	if err := verifyAPKIndex(index, []string{filepath.Join(dir, "test.rsa.pub")}); err != nil {
+		t.Errorf("Expected signature to verify, got: %v", err)
+	}
+
+	// A key with the right name but different material must not verify
+	other := t.TempDir()
+	signedIndex(t, other, "test.rsa.pub")
+	if err := verifyAPKIndex(index, []string{filepath.Join(other, "test.rsa.pub")}); err == nil {
+		t.Error("Expected signature verification to fail with a different key")
+	}
+
+	if err := verifyAPKIndex(index, []string{filepath.Join(dir, "unrelated.rsa.pub")}); err == nil || !strings.Contains(err.Error(), "not among the provided keys") {
+		t.Errorf("Expected missing key error, got: %v", err)
+	}
+
+	unsigned, err := parseAPKIndex(gzipTar(t, "APKINDEX", []byte(testAPKIndex)))
+	if err != nil {
+		t.Fatalf("Unexpected error for unsigned index: %v", err)
+	}
+	if unsigned.Signature != nil || len(unsigned.Packages) != 2 {
+		t.Errorf("Unexpected unsigned index: %+v", unsigned)
+	}
+	if err := verifyAPKIndex(unsigned, []string{filepath.Join(dir, "test.rsa.pub")}); err == nil {
+		t.Error("Expected error verifying an unsigned index")
+	}
+}
+
+func TestValidateCandidateRepo(t *testing.T) {
+	dir := t.TempDir()
+	data := signedIndex(t, dir, "test.rsa.pub")
+	key := filepath.Join(dir, "test.rsa.pub")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/os/"+hostArch()+"/APKINDEX.tar.gz" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(data)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name          string
+		repo          string
+		keys          []string
+		packageName   string
+		version       string
+		expectedError string
+	}{
+		{name: "valid repository", repo: server.URL + "/os", keys: []string{key}, packageName: "openssl"},
+		{name: "version without release", repo: server.URL + "/os", packageName: "openssl", version: "3.3.2"},
+		{name: "exact version", repo: server.URL + "/os", packageName: "openssl", version: "3.3.2-r1"},
+		{name: "no target package", repo: server.URL + "/os"},
+		{name: "wrong path", repo: server.URL + "/wrong", expectedError: "candidate repository has no index"},
+		{name: "missing package", repo: server.URL + "/os", packageName: "curl", expectedError: "package curl is not in the candidate repository index"},
+		{name: "wrong version", repo: server.URL + "/os", packageName: "openssl", version: "3.4.0", expectedError: "candidate repository has openssl 3.3.2-r1, expected version 3.4.0"},
+		{name: "untrusted key", repo: server.URL + "/os", keys: []string{filepath.Join(dir, "other.rsa.pub")}, expectedError: "not among the provided keys"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateCandidateRepo(tt.repo, tt.keys, tt.packageName, tt.version, false)
+			if tt.expectedError == "" {
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.expectedError) {
+				t.Errorf("Expected error to contain '%s', got: %v", tt.expectedError, err)
+			}
+		})
+	}
+}
+
+func TestValidateCandidateRepoLocal(t *testing.T) {
+	repo := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(repo, hostArch()), 0755); err != nil {
+		t.Fatalf("Failed to create arch dir: %v", err)
+	}
+
+	if err := ValidateCandidateRepo(repo, nil, "", "", false); err == nil || !strings.Contains(err.Error(), "candidate repository has no index") {
+		t.Errorf("Expected missing index error, got: %v", err)
+	}
+
+	indexPath := filepath.Join(repo, hostArch(), "APKINDEX.tar.gz")
+	if err := os.WriteFile(indexPath, gzipTar(t, "APKINDEX", []byte(testAPKIndex)), 0644); err != nil {
+		t.Fatalf("Failed to write index: %v", err)
+	}
+	if err := ValidateCandidateRepo(repo, nil, "libcrypto3", "", false); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,258 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// What a run does when the candidate repository changes while it is in
+// progress (see SetCandidateChange)
+const (
+	CandidateChangeAbort = "abort"
+	CandidateChangeWarn  = "warn"
+)
+
+// candidatePollInterval is how often the candidate repository index is
+// checked for changes
+const candidatePollInterval = 5 * time.Minute
+
+// ErrCandidateChanged indicates that a run was aborted because the
+// candidate repository changed while it was in progress
+var ErrCandidateChanged = errors.New("candidate repository changed during the run")
+
+// candidateChange is a change of the candidate repository index during a
+// run
+type candidateChange struct {
+	At   time.Time `json:"at"`
+	From string    `json:"from"`
+	To   string    `json:"to"`
+	// Stale are the packages whose with-repo test started before the
+	// change, i.e. ran against an earlier build
+	Stale []string `json:"stale,omitempty"`
+}
+
+// SetCandidateChange records the digest of the candidate repository index
+// when testing starts and checks it while the run is in progress, so that a
+// repository being rebuilt doesn't leave half the packages tested against
+// one build and half against another. With CandidateChangeAbort, the run
+// stops starting tests and fails with ErrCandidateChanged; with
+// CandidateChangeWarn it warns, loads the version change again and lists
+// the packages tested against an earlier build in the summary. "" doesn't
+// check.
+func (r *RegressionTestRunner) SetCandidateChange(mode string) error {
+	switch mode {
+	case "", CandidateChangeAbort, CandidateChangeWarn:
+		r.candidateChange = mode
+		return nil
+	}
+	return fmt.Errorf("unknown candidate change mode %q, expected %s or %s", mode, CandidateChangeAbort, CandidateChangeWarn)
+}
+
+// candidateWatch checks the candidate repository index for changes in the
+// background
+type candidateWatch struct {
+	location string
+	interval time.Duration
+	onChange func(candidateChange)
+
+	mu      sync.Mutex
+	digest  string
+	changes []candidateChange
+
+	stopOnce sync.Once
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// candidateDigest returns the digest of the index at location
+func candidateDigest(location string) (string, error) {
+	data, err := fetchCandidateIndex(location)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:]), nil
+}
+
+// startCandidateWatch records the digest of the candidate repository index
+// and starts checking it, if enabled
+func (r *RegressionTestRunner) startCandidateWatch() (*candidateWatch, error) {
+	if r.candidateChange == "" {
+		return nil, nil
+	}
+
+	location := candidateIndexURL(r.apkRepo, hostArch())
+	digest, err := candidateDigest(location)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record the candidate repository index: %w", err)
+	}
+
+	interval := r.candidatePoll
+	if interval <= 0 {
+		interval = candidatePollInterval
+	}
+	w := &candidateWatch{
+		location: location,
+		interval: interval,
+		onChange: r.candidateChanged,
+		digest:   digest,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go w.run()
+	return w, nil
+}
+
+func (w *candidateWatch) run() {
+	defer close(w.done)
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-ticker.C:
+			w.check()
+		}
+	}
+}
+
+// check compares the index with the digest recorded last. Failed fetches
+// are ignored, since the next check will tell.
+func (w *candidateWatch) check() {
+	digest, err := candidateDigest(w.location)
+	if err != nil {
+		return
+	}
+
+	w.mu.Lock()
+	if digest == w.digest {
+		w.mu.Unlock()
+		return
+	}
+	change := candidateChange{At: time.Now(), From: w.digest, To: digest}
+	w.digest = digest
+	w.changes = append(w.changes, change)
+	w.mu.Unlock()
+
+	w.onChange(change)
+}
+
+// Stop stops checking
+func (w *candidateWatch) Stop() {
+	if w == nil {
+		return
+	}
+	w.stopOnce.Do(func() { close(w.stop) })
+	<-w.done
+}
+
+// results returns the changes seen, with the packages tested against an
+// earlier build
+func (w *candidateWatch) results(packageResults map[string]map[bool]TestResult) []candidateChange {
+	if w == nil {
+		return nil
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for i := range w.changes {
+		w.changes[i].Stale = startedBefore(packageResults, w.changes[i].At)
+	}
+	return w.changes
+}
+
+// startedBefore returns the packages whose with-repo test started before t
+func startedBefore(packageResults map[string]map[bool]TestResult, t time.Time) []string {
+	var packages []string
+	for pkg, results := range packageResults {
+		result, ok := results[true]
+		if ok && !result.Skipped && !result.StartedAt.IsZero() && result.StartedAt.Before(t) {
+			packages = append(packages, pkg)
+		}
+	}
+	sort.Strings(packages)
+	return packages
+}
+
+// candidateChanged reacts to a change of the candidate repository
+func (r *RegressionTestRunner) candidateChanged(change candidateChange) {
+	r.printResult("\n⚠️  Candidate repository %s changed during the run (%s → %s)\n", r.apkRepo, shortDigest(change.From), shortDigest(change.To))
+
+	if r.candidateChange == CandidateChangeAbort {
+		if queue := r.activeQueue(); queue != nil {
+			cancelled, _ := r.Cancel(queue.queued())
+			r.printResult("Aborting: %d queued packages won't be tested, running tests finish\n", len(cancelled))
+		}
+		return
+	}
+
+	// The rest of the run tests the new build, whose version may differ.
+	// The summary reads it once the watch stopped.
+	if r.packageName != "" && r.apko == nil {
+		if change, err := r.loadVersionChange(); err == nil && change != nil {
+			r.versionChange = change
+			r.printResult("Version change now: %s\n", change)
+		}
+	}
+}
+
+// candidateErr fails a run aborted because the candidate repository changed
+func (r *RegressionTestRunner) candidateErr() error {
+	if r.candidateChange != CandidateChangeAbort || len(r.summary.CandidateChanges) == 0 {
+		return nil
+	}
+	change := r.summary.CandidateChanges[0]
+	return fmt.Errorf("%w: %s changed from %s to %s, results are incomplete", ErrCandidateChanged, r.apkRepo, shortDigest(change.From), shortDigest(change.To))
+}
+
+// shortDigest abbreviates a digest for messages, e.g. "sha256:1a2b3c4d5e6f"
+func shortDigest(digest string) string {
+	algorithm, hash, ok := strings.Cut(digest, ":")
+	if !ok || len(hash) <= 12 {
+		return digest
+	}
+	return algorithm + ":" + hash[:12]
+}
+
+// printCandidateChanges writes the changes of the candidate repository to
+// the text summary
+func printCandidateChanges(w io.Writer, changes []candidateChange) {
+	for _, change := range changes {
+		fmt.Fprintf(w, "\n⚠️  Candidate repository changed at %s (%s → %s)", change.At.Format(time.TimeOnly), shortDigest(change.From), shortDigest(change.To))
+		if len(change.Stale) == 0 {
+			fmt.Fprintf(w, "\n")
+			continue
+		}
+		fmt.Fprintf(w, "; tested against the earlier build:\n")
+		for _, pkg := range change.Stale {
+			fmt.Fprintf(w, "  - %s\n", pkg)
+		}
+	}
+}
+
+// printCandidateChangesMarkdown writes the changes of the candidate
+// repository to the markdown summary
+func printCandidateChangesMarkdown(w io.Writer, changes []candidateChange) {
+	if len(changes) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\n### ⚠️ Candidate Repository Changed\n\n")
+	fmt.Fprintf(w, "The candidate repository changed while the run was in progress, so not every package was tested against the same build:\n\n")
+	for _, change := range changes {
+		fmt.Fprintf(w, "- %s: `%s` → `%s`", change.At.Format(time.TimeOnly), shortDigest(change.From), shortDigest(change.To))
+		if len(change.Stale) > 0 {
+			fmt.Fprintf(w, ", %d packages tested against the earlier build: `%s`", len(change.Stale), strings.Join(change.Stale, "`, `"))
+		}
+		fmt.Fprintf(w, "\n")
+	}
+}
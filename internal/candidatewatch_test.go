@@ -0,0 +1,133 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSetCandidateChange(t *testing.T) {
+	tests := []struct {
+		mode    string
+		wantErr bool
+	}{
+		{"", false},
+		{CandidateChangeAbort, false},
+		{CandidateChangeWarn, false},
+		{"ignore", true},
+	}
+
+	for _, tt := range tests {
+		runner := NewRegressionTestRunner("", "https://example.com/repo", t.TempDir(), "wolfi", 1, false, time.Minute, false)
+		if err := runner.SetCandidateChange(tt.mode); (err != nil) != tt.wantErr {
+			t.Errorf("Expected error %v for %q, got %v", tt.wantErr, tt.mode, err)
+		}
+	}
+}
+
+func TestShortDigest(t *testing.T) {
+	tests := []struct {
+		digest   string
+		expected string
+	}{
+		{"sha256:1a2b3c4d5e6f7a8b9c0d", "sha256:1a2b3c4d5e6f"},
+		{"sha256:1a2b", "sha256:1a2b"},
+		{"unknown", "unknown"},
+	}
+
+	for _, tt := range tests {
+		if got := shortDigest(tt.digest); got != tt.expected {
+			t.Errorf("Expected %s, got %s", tt.expected, got)
+		}
+	}
+}
+
+func TestStartedBefore(t *testing.T) {
+	change := time.Now()
+	results := map[string]map[bool]TestResult{
+		"curl": {true: {Package: "curl", WithRepo: true, StartedAt: change.Add(-time.Minute)}},
+		"git":  {true: {Package: "git", WithRepo: true, StartedAt: change.Add(time.Minute)}},
+		// Only the with-repo test used the candidate repository
+		"wget": {false: {Package: "wget", StartedAt: change.Add(-time.Minute)}},
+		// Cached results weren't tested in this run
+		"jq": {true: {Package: "jq", WithRepo: true}},
+	}
+
+	if got := startedBefore(results, change); !reflect.DeepEqual(got, []string{"curl"}) {
+		t.Errorf("Expected [curl], got %v", got)
+	}
+}
+
+// candidateChangeRun tests packages one at a time while the candidate
+// repository index is rewritten during the first test
+func candidateChangeRun(t *testing.T, mode string) (*RegressionTestRunner, error) {
+	t.Helper()
+	repoPath := t.TempDir()
+	packages := []string{"curl", "git", "jq", "wget"}
+	for _, pkg := range packages {
+		if err := os.WriteFile(filepath.Join(repoPath, pkg+".yaml"), []byte("package:\n  name: "+pkg+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	index := filepath.Join(t.TempDir(), "APKINDEX.tar.gz")
+	if err := os.WriteFile(index, []byte("build 1"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	runner := NewRegressionTestRunner("", index, repoPath, "wolfi", 1, false, time.Minute, false)
+	runner.setLogDir(t.TempDir())
+	runner.candidatePoll = 10 * time.Millisecond
+	if err := runner.SetCandidateChange(mode); err != nil {
+		t.Fatal(err)
+	}
+	var rebuild sync.Once
+	runner.SetExecutor(TestExecutorFunc(func(ctx context.Context, pkg string, opts ExecuteOptions) TestResult {
+		result := newTestResult(pkg, opts.WithRepo, time.Now(), "", nil)
+		rebuild.Do(func() {
+			os.WriteFile(index, []byte("build 2"), 0644)
+			time.Sleep(200 * time.Millisecond)
+		})
+		return result
+	}))
+
+	err := runner.RunFromPackageList(packages)
+	return runner, err
+}
+
+func TestCandidateChangeAbort(t *testing.T) {
+	runner, err := candidateChangeRun(t, CandidateChangeAbort)
+	if !errors.Is(err, ErrCandidateChanged) {
+		t.Fatalf("Expected ErrCandidateChanged, got %v", err)
+	}
+	if len(runner.summary.CandidateChanges) != 1 {
+		t.Fatalf("Expected 1 candidate change, got %v", runner.summary.CandidateChanges)
+	}
+	if runner.summary.Tested != 1 {
+		t.Errorf("Expected the queued packages to be cancelled, got %d tested", runner.summary.Tested)
+	}
+}
+
+func TestCandidateChangeWarn(t *testing.T) {
+	runner, err := candidateChangeRun(t, CandidateChangeWarn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if runner.summary.Tested != 4 {
+		t.Errorf("Expected every package to be tested, got %d", runner.summary.Tested)
+	}
+	changes := runner.summary.CandidateChanges
+	if len(changes) != 1 {
+		t.Fatalf("Expected 1 candidate change, got %v", changes)
+	}
+	if !reflect.DeepEqual(changes[0].Stale, []string{"curl"}) {
+		t.Errorf("Expected curl to be tested against the earlier build, got %v", changes[0].Stale)
+	}
+}
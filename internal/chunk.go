@@ -0,0 +1,63 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"fmt"
+	"time"
+)
+
+// SetChunkSize tests packages in chunks of size: the next chunk only starts
+// once every test of the previous one finished, and in between the result
+// files are written and an intermediate summary is printed, so that the
+// results of a day-long run are durable and reviewable as it goes. 0 tests
+// all packages at once.
+func (r *RegressionTestRunner) SetChunkSize(size int) {
+	r.chunkSize = size
+}
+
+// flushChunk writes the results collected so far once a chunk finished
+func (r *RegressionTestRunner) flushChunk(tally *resultTally, packageResults map[string]map[bool]TestResult) {
+	r.chunksDone++
+	total := r.activeQueue().size()
+	chunks := (total + r.chunkSize - 1) / r.chunkSize
+	tested := len(packageResults) - len(tally.skipped) - len(tally.archExcluded) - len(tally.untestable) - len(tally.infra) - len(tally.oomKilled)
+
+	r.writeResultFiles(tally.successful, tally.failed, tally.regressions, tally.hungTests, tally.skipped)
+	r.writeResultsJSON(packageResults)
+	if r.summaryJSON {
+		r.summary = runSummary{
+			Total:       total,
+			Tested:      tested,
+			Skipped:     tally.skipped,
+			Successful:  tally.successful,
+			Failed:      tally.failed,
+			Regressions: tally.regressions,
+			Hung:        tally.hungTests,
+
+			ArchExcluded: tally.archExcluded,
+			Untestable:   tally.untestable,
+			Infra:        tally.infra,
+			OOMKilled:    tally.oomKilled,
+		}
+		writeSummaryJSON(r.logDir, r.summaryReport())
+	}
+
+	fmt.Printf("\n=== Chunk %d of %d done after %v ===\n", r.chunksDone, chunks, time.Since(r.startTime).Round(time.Second))
+	fmt.Printf("Packages tested: %d\n", tested)
+	fmt.Printf("Regressions detected: %d\n", len(tally.regressions))
+	fmt.Printf("Hung tests: %d\n", len(tally.hungTests))
+	fmt.Printf("Successful packages: %d\n", len(tally.successful))
+	fmt.Printf("Failed packages: %d\n", len(tally.failed))
+	if len(tally.infra) > 0 {
+		fmt.Printf("Infrastructure failures (not counted): %d\n", len(tally.infra))
+	}
+	if len(tally.oomKilled) > 0 {
+		fmt.Printf("Killed for lack of memory (not counted): %d\n", len(tally.oomKilled))
+	}
+	for _, pkg := range tally.regressions {
+		fmt.Printf("  - %s (regression)\n", pkg)
+	}
+	fmt.Printf("Results so far written to %s\n\n", r.logDir)
+}
@@ -0,0 +1,389 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"bufio"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// testMemory is the memory set aside for each concurrent test when choosing
+// the concurrency automatically; melange test environments of large
+// packages easily use a few GiB
+const testMemory = 4 << 30
+
+// AutoConcurrency returns the number of packages to test at once on this
+// host: one per two CPUs, fewer if the memory doesn't allow it, at least 1
+func AutoConcurrency() int {
+	return autoConcurrency(runtime.NumCPU(), totalMemory())
+}
+
+func autoConcurrency(cpus int, memory uint64) int {
+	n := cpus / 2
+	if memory > 0 {
+		n = min(n, int(memory/testMemory))
+	}
+	return max(n, 1)
+}
+
+// totalMemory returns the memory of the host in bytes, or 0 if unknown
+func totalMemory() uint64 {
+	file, err := os.Open("/proc/meminfo")
+	if err != nil {
+		return 0
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "MemTotal:" {
+			kb, err := strconv.ParseUint(fields[1], 10, 64)
+			if err != nil {
+				return 0
+			}
+			return kb * 1024
+		}
+	}
+	return 0
+}
+
+// GitHubActions is where a run in a GitHub Actions job reports to: the job
+// summary and the step outputs files
+type GitHubActions struct {
+	SummaryPath string
+	OutputPath  string
+}
+
+// GitHubActionsFromEnv returns the files GitHub Actions provides the current
+// step, or nil outside GitHub Actions
+func GitHubActionsFromEnv() *GitHubActions {
+	g := &GitHubActions{
+		SummaryPath: os.Getenv("GITHUB_STEP_SUMMARY"),
+		OutputPath:  os.Getenv("GITHUB_OUTPUT"),
+	}
+	if g.SummaryPath == "" && g.OutputPath == "" {
+		return nil
+	}
+	return g
+}
+
+// appendSummary appends the markdown written by write to the job summary
+func (g *GitHubActions) appendSummary(write func(w io.Writer)) error {
+	if g.SummaryPath == "" {
+		return nil
+	}
+	file, err := os.OpenFile(g.SummaryPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open job summary: %w", err)
+	}
+	write(file)
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write job summary: %w", err)
+	}
+	return nil
+}
+
+// setOutputs appends outputs to the step outputs, for later steps, e.g. one
+// uploading the log directory as an artifact
+func (g *GitHubActions) setOutputs(outputs map[string]string) error {
+	if g.OutputPath == "" {
+		return nil
+	}
+	var b strings.Builder
+	names := make([]string, 0, len(outputs))
+	for name := range outputs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(&b, "%s=%s\n", name, outputs[name])
+	}
+
+	file, err := os.OpenFile(g.OutputPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open step outputs: %w", err)
+	}
+	if _, err := file.WriteString(b.String()); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write step outputs: %w", err)
+	}
+	return file.Close()
+}
+
+// githubOutputs are the step outputs describing a run whose log directory
+// is logDir. The run ID, its base name, suits as an artifact name.
+func githubOutputs(logDir string, summary SummaryReport) map[string]string {
+	return map[string]string{
+		"log-dir":     logDir,
+		"run-id":      filepath.Base(logDir),
+		"regressions": strconv.Itoa(len(summary.Regressions)),
+		"hung":        strconv.Itoa(len(summary.Hung)),
+		"failed":      strconv.Itoa(len(summary.Failed)),
+		"tested":      strconv.Itoa(summary.Tested),
+	}
+}
+
+// SetArtifactLogDir names the log directory so that it can be uploaded as a
+// CI artifact of the same name, see artifactLogDir
+func (r *RegressionTestRunner) SetArtifactLogDir(enabled bool) {
+	r.artifactLogDir = enabled
+	if enabled {
+		r.setLogDir(artifactLogDir(r.logDir))
+	}
+}
+
+// artifactUnsafe matches the characters artifact names shouldn't contain,
+// e.g. the commas of several --package targets
+var artifactUnsafe = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// maxArtifactName keeps log directory names of runs with many targets well
+// within file name and artifact name limits
+const maxArtifactName = 100
+
+// artifactLogDir replaces the characters of the base name of dir that
+// aren't safe in artifact names and shortens it if needed, keeping it
+// unique with a digest of the full name
+func artifactLogDir(dir string) string {
+	name := artifactUnsafe.ReplaceAllString(filepath.Base(dir), "_")
+	if len(name) > maxArtifactName {
+		digest := sha256.Sum256([]byte(filepath.Base(dir)))
+		name = name[:maxArtifactName-13] + "-" + hex.EncodeToString(digest[:])[:12]
+	}
+	return filepath.Join(filepath.Dir(dir), name)
+}
+
+// summaryJSONFile is the name of the run summary in each log directory
+const summaryJSONFile = "summary.json"
+
+// SummaryReport is the outcome of a run, written to summary.json in the log
+// directory for CI jobs and dashboards. results.json has the individual
+// test results.
+type SummaryReport struct {
+	Package     string   `json:"package"`
+	APKRepo     string   `json:"apkRepo"`
+	LogDir      string   `json:"logDir"`
+	Duration    string   `json:"duration"`
+	Total       int      `json:"total"`
+	Tested      int      `json:"tested"`
+	Skipped     []string `json:"skipped"`
+	Successful  []string `json:"successful"`
+	Failed      []string `json:"failed"`
+	Regressions []string `json:"regressions"`
+	Hung        []string `json:"hung"`
+	Fixed       []string `json:"fixed,omitempty"`
+	// ArchExcluded are the packages not built for the architecture tests
+	// ran on
+	ArchExcluded []string `json:"archExcluded,omitempty"`
+	// Untestable are the packages the Makefile has no test target for
+	Untestable []string `json:"untestable,omitempty"`
+	// VersionChange is the version of the package in the index and in the
+	// candidate repository
+	VersionChange *VersionChange `json:"versionChange,omitempty"`
+	// PackageVersion is the version of the package the run was tied to
+	// with package@version
+	PackageVersion string `json:"packageVersion,omitempty"`
+	// Memory are the packages whose tests used the most memory
+	Memory []packageMemory `json:"memory,omitempty"`
+	// Utilization is how well the tests used the workers and the machine
+	Utilization utilization `json:"utilization"`
+	// Unchanged and Unaffected count the packages not tested with
+	// --skip-unchanged and --two-phase
+	Unchanged  int `json:"unchanged,omitempty"`
+	Unaffected int `json:"unaffected,omitempty"`
+	// Gate is how the run deviates from the expected results, with --gate
+	Gate *gateResult `json:"gate,omitempty"`
+	// Budget is how the regressions fit the failure budget, with
+	// --failure-budget
+	Budget *budgetResult `json:"failureBudget,omitempty"`
+	// CandidateChanges are the changes of the candidate repository during
+	// the run, with --candidate-change
+	CandidateChanges []candidateChange `json:"candidateChanges,omitempty"`
+	// Backoffs are the reductions of the concurrency after infrastructure
+	// failures
+	Backoffs []concurrencyBackoff `json:"backoffs,omitempty"`
+	// Infra are the packages whose tests failed on the infrastructure, with
+	// the failure
+	Infra []string `json:"infra,omitempty"`
+	// OOMKilled are the packages whose tests were killed for lack of memory
+	OOMKilled []oomKill `json:"oomKilled,omitempty"`
+	// Pruned are the consumers not tested because the consumers they depend
+	// on regressed, with --prune-on-regression
+	Pruned []string `json:"pruned,omitempty"`
+	// Ecosystems are the outcomes by language ecosystem, if the packages
+	// span several
+	Ecosystems []ecosystemStats `json:"ecosystems,omitempty"`
+	// DependencyKinds are the outcomes by how the consumers depend on the
+	// package, if they depend on it in several ways
+	DependencyKinds []depKindStats `json:"dependencyKinds,omitempty"`
+	// Notes are the operator's notes about the run (--note)
+	Notes []string `json:"notes,omitempty"`
+	// Variants maps the packages that don't pass in every variant of a test
+	// matrix to their status in each variant
+	Variants map[string]map[string]string `json:"variants,omitempty"`
+	// NoReverseDependencies is set when there was nothing to test
+	NoReverseDependencies bool `json:"noReverseDependencies,omitempty"`
+}
+
+// SetSummaryJSON writes summary.json to the log directory when a run
+// finishes
+func (r *RegressionTestRunner) SetSummaryJSON(enabled bool) {
+	r.summaryJSON = enabled
+}
+
+// SetGitHubActions appends the markdown summary of each run to the job
+// summary of GitHub Actions and sets step outputs such as log-dir
+func (r *RegressionTestRunner) SetGitHubActions(g *GitHubActions) {
+	r.github = g
+}
+
+// SetLogCompression gzips the logs of passing tests when a run finishes,
+// which make up most of a log directory uploaded as a CI artifact. Logs of
+// failed tests are kept as they are for triage.
+func (r *RegressionTestRunner) SetLogCompression(enabled bool) {
+	r.compressLogs = enabled
+}
+
+// summaryReport returns the summary of the finished run
+func (r *RegressionTestRunner) summaryReport() SummaryReport {
+	return SummaryReport{
+		Package:               r.packageName,
+		APKRepo:               r.apkRepo,
+		LogDir:                r.logDir,
+		Duration:              time.Since(r.startTime).Round(time.Second).String(),
+		Total:                 r.summary.Total,
+		Tested:                r.summary.Tested,
+		Skipped:               nonNil(r.summary.Skipped),
+		Successful:            nonNil(r.summary.Successful),
+		Failed:                nonNil(r.summary.Failed),
+		Regressions:           nonNil(r.summary.Regressions),
+		Hung:                  nonNil(r.summary.Hung),
+		Fixed:                 r.summary.Fixed,
+		ArchExcluded:          r.summary.ArchExcluded,
+		Untestable:            r.summary.Untestable,
+		VersionChange:         r.versionChange,
+		PackageVersion:        r.targetVersion,
+		Memory:                r.summary.Memory,
+		Utilization:           r.summary.Utilization,
+		Gate:                  r.summary.Gate,
+		Budget:                r.summary.Budget,
+		CandidateChanges:      r.summary.CandidateChanges,
+		Backoffs:              r.summary.Backoffs,
+		Infra:                 r.summary.Infra,
+		OOMKilled:             r.summary.OOMKilled,
+		Pruned:                r.summary.Pruned,
+		Ecosystems:            r.summary.Ecosystems,
+		DependencyKinds:       r.summary.DepKinds,
+		Notes:                 r.notes,
+		Unchanged:             len(r.unchanged),
+		Unaffected:            len(r.unaffected),
+		NoReverseDependencies: r.empty,
+	}
+}
+
+// nonNil makes empty package lists encode as [] rather than null
+func nonNil(packages []string) []string {
+	if packages == nil {
+		return []string{}
+	}
+	return packages
+}
+
+// writeSummaryJSON writes summary to summary.json in dir
+func writeSummaryJSON(dir string, summary SummaryReport) {
+	data, err := json.MarshalIndent(summary, "", "  ")
+	if err != nil {
+		fmt.Printf("Warning: failed to encode %s: %v\n", summaryJSONFile, err)
+		return
+	}
+	if err := os.WriteFile(filepath.Join(dir, summaryJSONFile), append(data, '\n'), 0644); err != nil {
+		fmt.Printf("Warning: failed to write %s: %v\n", summaryJSONFile, err)
+	}
+}
+
+// reportCI writes the CI outputs of a finished run, whose markdown summary
+// is written by markdown
+func (r *RegressionTestRunner) reportCI(markdown func(w io.Writer)) {
+	summary := r.summaryReport()
+	if r.summaryJSON {
+		writeSummaryJSON(r.logDir, summary)
+	}
+	if r.github == nil || r.inMatrix {
+		return
+	}
+	if err := r.github.appendSummary(markdown); err != nil {
+		fmt.Printf("Warning: %v\n", err)
+	}
+	if err := r.github.setOutputs(githubOutputs(r.logDir, summary)); err != nil {
+		fmt.Printf("Warning: %v\n", err)
+	}
+}
+
+// compressPassingLogs gzips the logs of the packages that passed, updating
+// their log paths
+func compressPassingLogs(packageResults map[string]map[bool]TestResult, statuses map[string]string) {
+	for _, pkg := range sortedKeys(packageResults) {
+		if statuses[pkg] != StatusPass {
+			continue
+		}
+		for withRepo, result := range packageResults[pkg] {
+			if result.LogPath == "" {
+				continue
+			}
+			compressed, err := gzipFile(result.LogPath)
+			if err != nil {
+				fmt.Printf("Warning: failed to compress log %s: %v\n", result.LogPath, err)
+				continue
+			}
+			result.LogPath = compressed
+			packageResults[pkg][withRepo] = result
+		}
+	}
+}
+
+// gzipFile replaces path with a gzipped copy at path.gz and returns its path
+func gzipFile(path string) (string, error) {
+	in, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer in.Close()
+
+	dst := path + ".gz"
+	out, err := os.Create(dst)
+	if err != nil {
+		return "", err
+	}
+	// The header carries no name or time, so that identical logs compress
+	// identically and are still deduplicated
+	zw := gzip.NewWriter(out)
+	if _, err := io.Copy(zw, in); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return "", err
+	}
+	if err := zw.Close(); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return "", err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(dst)
+		return "", err
+	}
+	return dst, os.Remove(path)
+}
@@ -0,0 +1,148 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAutoConcurrency(t *testing.T) {
+	tests := []struct {
+		name     string
+		cpus     int
+		memory   uint64
+		expected int
+	}{
+		{"one per two cpus", 16, 64 << 30, 8},
+		{"limited by memory", 16, 16 << 30, 4},
+		{"memory unknown", 8, 0, 4},
+		{"single cpu", 1, 64 << 30, 1},
+		{"little memory", 4, 2 << 30, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := autoConcurrency(tt.cpus, tt.memory); got != tt.expected {
+				t.Errorf("Expected %d, got %d", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestArtifactLogDir(t *testing.T) {
+	if got := artifactLogDir("logs/regression-test-curl,openssl-20250101-120000"); got != "logs/regression-test-curl_openssl-20250101-120000" {
+		t.Errorf("Expected commas to be replaced, got %s", got)
+	}
+	if got := artifactLogDir("logs/regression-test-openssl-20250101-120000"); got != "logs/regression-test-openssl-20250101-120000" {
+		t.Errorf("Expected a safe name to be kept, got %s", got)
+	}
+
+	long := "logs/regression-test-" + strings.Repeat("py3-package,", 20) + "-20250101-120000"
+	got := artifactLogDir(long)
+	if len(filepath.Base(got)) != maxArtifactName {
+		t.Errorf("Expected the name to be shortened to %d characters, got %s", maxArtifactName, got)
+	}
+	if other := artifactLogDir(long + "1"); other == got {
+		t.Errorf("Expected shortened names of different runs to differ, got %s", got)
+	}
+}
+
+func TestRunnerCIOutputs(t *testing.T) {
+	repoPath := t.TempDir()
+	for _, name := range []string{"curl", "git"} {
+		if err := os.WriteFile(filepath.Join(repoPath, name+".yaml"), []byte("package:\n  name: "+name+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	runner := NewRegressionTestRunner("openssl", "https://example.com/repo", repoPath, "wolfi", 2, false, time.Minute, false)
+	logDir := t.TempDir()
+	runner.setLogDir(logDir)
+	github := &GitHubActions{
+		SummaryPath: filepath.Join(t.TempDir(), "summary.md"),
+		OutputPath:  filepath.Join(t.TempDir(), "output"),
+	}
+	runner.SetGitHubActions(github)
+	runner.SetSummaryJSON(true)
+	runner.SetLogCompression(true)
+	runner.SetExecutor(TestExecutorFunc(func(ctx context.Context, pkg string, opts ExecuteOptions) TestResult {
+		logPath := filepath.Join(logDir, fmt.Sprintf("%s_%v.log", pkg, opts.WithRepo))
+		if err := os.WriteFile(logPath, []byte(pkg+" test output\n"), 0644); err != nil {
+			t.Error(err)
+		}
+		var err error
+		if pkg == "curl" && opts.WithRepo {
+			err = errors.New("test failed")
+		}
+		return newTestResult(pkg, opts.WithRepo, time.Now(), logPath, err)
+	}))
+
+	if err := runner.RunFromPackageList([]string{"curl", "git"}); err == nil {
+		t.Error("Expected the regression to fail the run")
+	}
+
+	data, err := os.ReadFile(filepath.Join(logDir, summaryJSONFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var summary SummaryReport
+	if err := json.Unmarshal(data, &summary); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(summary.Regressions, []string{"curl"}) || !reflect.DeepEqual(summary.Successful, []string{"git"}) {
+		t.Errorf("Expected curl to regress and git to pass, got %+v", summary)
+	}
+
+	// Passing logs are compressed, the logs of the regression kept
+	if _, err := os.Stat(filepath.Join(logDir, "curl_true.log")); err != nil {
+		t.Errorf("Expected the log of the regression to be kept: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(logDir, "git_true.log")); !os.IsNotExist(err) {
+		t.Errorf("Expected the passing log to be replaced, got %v", err)
+	}
+	compressed := runner.packageResults["git"][true].LogPath
+	if compressed != filepath.Join(logDir, "git_true.log.gz") {
+		t.Errorf("Expected the result to point to the compressed log, got %s", compressed)
+	}
+	file, err := os.Open(compressed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	zr, err := gzip.NewReader(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if content, err := io.ReadAll(zr); err != nil || string(content) != "git test output\n" {
+		t.Errorf("Expected the compressed log to hold the output, got %q (%v)", content, err)
+	}
+
+	markdown, err := os.ReadFile(github.SummaryPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(markdown), "## APK Regression Test Summary") {
+		t.Errorf("Expected the markdown summary in the job summary, got %q", markdown)
+	}
+	outputs, err := os.ReadFile(github.OutputPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"log-dir=" + logDir, "regressions=1", "tested=2"} {
+		if !strings.Contains(string(outputs), line+"\n") {
+			t.Errorf("Expected step output %q, got %q", line, outputs)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// FailureCluster is a set of failed or regressed packages whose with-repo
+// logs fail the same way, typically a shared cause such as a removed symbol
+// or an unreachable repository
+type FailureCluster struct {
+	// Category is the log pattern category of the error, e.g. "link error"
+	Category string
+	// Error is the error line of the first package's log
+	Error    string
+	Packages []string
+	LogPath  string
+}
+
+// maxErrorLineLength caps the error line shown for a cluster
+const maxErrorLineLength = 160
+
+// signatureNoise matches the parts of error lines that differ between
+// packages failing for the same reason, replaced in order: log prefixes,
+// hex digests, paths and file names, then numbers
+var signatureNoise = []struct {
+	pattern     *regexp.Regexp
+	replacement string
+}{
+	{regexp.MustCompile(`^\S*\d{4}[-/]\d{2}[-/]\d{2}[T ]\d{2}:\d{2}:\d{2}\S*\s+`), ""},
+	{regexp.MustCompile(`^[^|]{0,40}\|\s+`), ""},
+	{regexp.MustCompile(`\b[0-9a-f]{7,}\b`), "<hex>"},
+	{regexp.MustCompile(`[\w.+-]*(/[\w.+-]+)+/?`), "<path>"},
+	{regexp.MustCompile(`\b[\w+-]+\.(o|lo|a|so|c|cc|cpp|cxx|h|hpp|go|rs|py|js|java)\b`), "<path>"},
+	{regexp.MustCompile(`\d+`), "<n>"},
+}
+
+// clusterFailures groups the failed and regressed packages of a run by the
+// signature of the error in their with-repo logs. Clusters of a single
+// package are left out; the largest cluster comes first.
+func clusterFailures(packageResults map[string]map[bool]TestResult, statuses map[string]string) []FailureCluster {
+	bySignature := make(map[string]*FailureCluster)
+	for _, pkg := range sortedKeys(statuses) {
+		if statuses[pkg] != StatusFail && statuses[pkg] != StatusRegression {
+			continue
+		}
+		result, ok := packageResults[pkg][true]
+		if !ok || result.LogPath == "" {
+			continue
+		}
+
+		category, line := logErrorLine(result.LogPath)
+		if line == "" {
+			continue
+		}
+		signature := category + "\x00" + errorSignature(line, pkg)
+		cluster, ok := bySignature[signature]
+		if !ok {
+			cluster = &FailureCluster{Category: category, Error: truncateLine(line), LogPath: result.LogPath}
+			bySignature[signature] = cluster
+		}
+		cluster.Packages = append(cluster.Packages, pkg)
+	}
+
+	var clusters []FailureCluster
+	for _, cluster := range bySignature {
+		if len(cluster.Packages) > 1 {
+			clusters = append(clusters, *cluster)
+		}
+	}
+	sort.Slice(clusters, func(i, j int) bool {
+		if len(clusters[i].Packages) != len(clusters[j].Packages) {
+			return len(clusters[i].Packages) > len(clusters[j].Packages)
+		}
+		return clusters[i].Packages[0] < clusters[j].Packages[0]
+	})
+	return clusters
+}
+
+// logErrorLine returns the line of a log that best explains its failure: the
+// last line of the most specific failure category, or else the last
+// non-empty line
+func logErrorLine(path string) (string, string) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", ""
+	}
+	defer file.Close()
+
+	best := len(logPatterns)
+	var category, line, last string
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		last = text
+		for i, p := range logPatterns {
+			if i > best {
+				break
+			}
+			if p.pattern.MatchString(text) {
+				best, category, line = i, p.category, text
+				break
+			}
+		}
+	}
+
+	if line == "" {
+		return "", last
+	}
+	return category, line
+}
+
+// errorSignature normalizes an error line so that the same error in
+// different packages compares equal
+func errorSignature(line, pkg string) string {
+	for _, noise := range signatureNoise {
+		line = noise.pattern.ReplaceAllString(line, noise.replacement)
+	}
+	line = strings.ReplaceAll(line, pkg, "<pkg>")
+	return strings.Join(strings.Fields(line), " ")
+}
+
+func truncateLine(line string) string {
+	runes := []rune(line)
+	if len(runes) <= maxErrorLineLength {
+		return line
+	}
+	return string(runes[:maxErrorLineLength]) + "…"
+}
+
+// String describes the cluster, e.g. `17 packages failed with "undefined
+// reference to SSL_CTX_set_options" (link error): curl, git, ...`
+func (c FailureCluster) String() string {
+	category := ""
+	if c.Category != "" {
+		category = fmt.Sprintf(" (%s)", c.Category)
+	}
+	return fmt.Sprintf("%d packages failed with %q%s: %s", len(c.Packages), c.Error, category, strings.Join(c.Packages, ", "))
+}
+
+// markdown describes the cluster for the markdown summary, linking the error
+// to the first package's log if link is set
+func (c FailureCluster) markdown(link string) string {
+	message := fmt.Sprintf("`%s`", strings.ReplaceAll(c.Error, "`", "'"))
+	if link != "" {
+		message = fmt.Sprintf("[%s](%s)", message, link)
+	}
+	if c.Category != "" {
+		message += fmt.Sprintf(" (%s)", c.Category)
+	}
+
+	packages := make([]string, len(c.Packages))
+	for i, pkg := range c.Packages {
+		packages[i] = fmt.Sprintf("`%s`", pkg)
+	}
+	return fmt.Sprintf("**%d packages** failed with %s: %s", len(c.Packages), message, strings.Join(packages, ", "))
+}
@@ -0,0 +1,112 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestClusterFailures(t *testing.T) {
+	dir := t.TempDir()
+	logs := map[string]string{
+		"curl": "building curl\n/usr/bin/ld: curl.o: undefined reference to `SSL_CTX_set_options'\nmake: *** [Makefile:12: all] Error 1\n",
+		"git":  "building git\n/usr/bin/ld: http.o: undefined reference to `SSL_CTX_set_options'\nmake: *** [Makefile:40: git] Error 2\n",
+		"jq":   "building jq\n/usr/bin/ld: src/jq.o: undefined reference to `SSL_CTX_set_options'\n",
+		// Same wording, different missing symbol
+		"wget":          "/usr/bin/ld: wget.o: undefined reference to `SSL_new'\n",
+		"zlib":          "--- FAIL: TestInflate\n",
+		"nginx":         "building nginx\n/usr/bin/ld: nginx.o: undefined reference to `SSL_CTX_set_options'\n",
+		"unreachable-1": "ERROR: unable to fetch https://apk.example/os/x86_64/APKINDEX.tar.gz: connection refused\n",
+		"unreachable-2": "ERROR: unable to fetch https://apk.example/os/x86_64/APKINDEX.tar.gz: connection refused\n",
+	}
+	packageResults := make(map[string]map[bool]TestResult)
+	statuses := map[string]string{
+		"curl":          StatusRegression,
+		"git":           StatusFail,
+		"jq":            StatusRegression,
+		"wget":          StatusRegression,
+		"zlib":          StatusFail,
+		"nginx":         StatusPass,
+		"unreachable-1": StatusFail,
+		"unreachable-2": StatusFail,
+	}
+	for pkg, content := range logs {
+		path := filepath.Join(dir, pkg+"_with_repo.log")
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		packageResults[pkg] = map[bool]TestResult{true: {LogPath: path}}
+	}
+
+	clusters := clusterFailures(packageResults, statuses)
+	if len(clusters) != 2 {
+		t.Fatalf("Expected 2 clusters, got %+v", clusters)
+	}
+
+	if !reflect.DeepEqual(clusters[0].Packages, []string{"curl", "git", "jq"}) {
+		t.Errorf("Expected curl, git and jq in the largest cluster, got %v", clusters[0].Packages)
+	}
+	if clusters[0].Category != "link error" || clusters[0].Error != "/usr/bin/ld: curl.o: undefined reference to `SSL_CTX_set_options'" {
+		t.Errorf("Unexpected cluster: %+v", clusters[0])
+	}
+	if !strings.HasPrefix(clusters[0].String(), "3 packages failed with \"/usr/bin/ld: curl.o: undefined reference") {
+		t.Errorf("Unexpected description %q", clusters[0].String())
+	}
+
+	if !reflect.DeepEqual(clusters[1].Packages, []string{"unreachable-1", "unreachable-2"}) {
+		t.Errorf("Expected the unreachable packages in a cluster, got %v", clusters[1].Packages)
+	}
+}
+
+func TestLogErrorLine(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		content          string
+		expectedCategory string
+		expectedLine     string
+	}{
+		{"building\nmain.c:1: error: boom\nmake: *** [all] Error 1\n", "compile error", "main.c:1: error: boom"},
+		{"--- FAIL: TestA\n--- FAIL: TestB\nFAIL\n", "test failure", "FAIL"},
+		{"building\nexit status 1\n\n", "", "exit status 1"},
+	}
+
+	for i, tt := range tests {
+		path := filepath.Join(dir, "test.log")
+		if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		category, line := logErrorLine(path)
+		if category != tt.expectedCategory || line != tt.expectedLine {
+			t.Errorf("Test %d: expected %q (%s), got %q (%s)", i, tt.expectedLine, tt.expectedCategory, line, category)
+		}
+	}
+}
+
+func TestErrorSignature(t *testing.T) {
+	tests := []struct {
+		line1, pkg1 string
+		line2, pkg2 string
+		same        bool
+	}{
+		{"curl.c:12: error: 'X509' undeclared", "curl", "git.c:480: error: 'X509' undeclared", "git", true},
+		{"2025-01-06T12:00:00Z ERROR build failed in /home/build/curl-8.1", "curl", "2025-01-07T08:30:12Z ERROR build failed in /home/build/git-2.4", "git", true},
+		{"checksum a1b2c3d4e5f6 mismatch", "curl", "checksum 0f9e8d7c6b5a mismatch", "git", true},
+		{"undefined reference to `SSL_new'", "curl", "undefined reference to `SSL_free'", "git", false},
+	}
+
+	for _, tt := range tests {
+		sig1, sig2 := errorSignature(tt.line1, tt.pkg1), errorSignature(tt.line2, tt.pkg2)
+		if (sig1 == sig2) != tt.same {
+			t.Errorf("Expected same=%v for %q and %q, got signatures %q and %q", tt.same, tt.line1, tt.line2, sig1, sig2)
+		}
+	}
+
+	if line := truncateLine(strings.Repeat("x", 200)); line != strings.Repeat("x", maxErrorLineLength)+"…" {
+		t.Errorf("Expected the line to be truncated, got %q", line)
+	}
+}
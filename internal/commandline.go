@@ -0,0 +1,64 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"os/exec"
+	"strings"
+)
+
+// secretEnv are the environment variables whose values aren't recorded
+var secretEnv = []string{"HTTP_AUTH"}
+
+// CommandLine is the exact command a test ran, recorded so that it can be
+// reproduced outside apkregress
+type CommandLine struct {
+	Dir string `json:"dir"`
+	// Env are the variables set on top of the environment apkregress ran
+	// in, e.g. MELANGE_EXTRA_OPTS, with credentials redacted
+	Env  []string `json:"env,omitempty"`
+	Args []string `json:"args"`
+}
+
+// newCommandLine records cmd, whose environment is inherited variables
+// followed by its own
+func newCommandLine(cmd *exec.Cmd, inherited int) *CommandLine {
+	var env []string
+	if len(cmd.Env) > inherited {
+		for _, variable := range cmd.Env[inherited:] {
+			name, _, _ := strings.Cut(variable, "=")
+			if isOneOf(secretEnv, name) {
+				variable = name + "=<redacted>"
+			}
+			env = append(env, variable)
+		}
+	}
+	return &CommandLine{Dir: cmd.Dir, Env: env, Args: cmd.Args}
+}
+
+// String formats the command line for a shell, e.g.
+// cd /src/os && TMPDIR=/tmp/x MELANGE_EXTRA_OPTS='--runner docker' make test/curl
+func (c *CommandLine) String() string {
+	var parts []string
+	if c.Dir != "" {
+		parts = append(parts, "cd", shellQuote(c.Dir), "&&")
+	}
+	for _, variable := range c.Env {
+		name, value, _ := strings.Cut(variable, "=")
+		parts = append(parts, name+"="+shellQuote(value))
+	}
+	for _, arg := range c.Args {
+		parts = append(parts, shellQuote(arg))
+	}
+	return strings.Join(parts, " ")
+}
+
+// shellQuote quotes s for a POSIX shell unless it only has characters
+// that don't need quoting
+func shellQuote(s string) string {
+	if s != "" && strings.Trim(s, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_./:=,+@%") == "" {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
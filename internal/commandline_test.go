@@ -0,0 +1,46 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"os/exec"
+	"reflect"
+	"testing"
+)
+
+func TestCommandLine(t *testing.T) {
+	cmd := exec.Command("make", "test/curl")
+	cmd.Dir = "/src/os"
+	cmd.Env = []string{"HOME=/root", "TMPDIR=/tmp/melange-build-curl", "MELANGE_EXTRA_OPTS=--repository-append https://example.com/repo --runner docker", "HTTP_AUTH=basic:apk.cgr.dev:user:secret"}
+
+	command := newCommandLine(cmd, 1)
+	expectedEnv := []string{"TMPDIR=/tmp/melange-build-curl", "MELANGE_EXTRA_OPTS=--repository-append https://example.com/repo --runner docker", "HTTP_AUTH=<redacted>"}
+	if !reflect.DeepEqual(command.Env, expectedEnv) {
+		t.Errorf("Expected env %v, got %v", expectedEnv, command.Env)
+	}
+
+	expected := "cd /src/os && TMPDIR=/tmp/melange-build-curl MELANGE_EXTRA_OPTS='--repository-append https://example.com/repo --runner docker' HTTP_AUTH='<redacted>' make test/curl"
+	if got := command.String(); got != expected {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"test/curl", "test/curl"},
+		{"", "''"},
+		{"a b", "'a b'"},
+		{"it's", `'it'\''s'`},
+		{"$HOME", "'$HOME'"},
+	}
+
+	for _, tt := range tests {
+		if got := shellQuote(tt.input); got != tt.expected {
+			t.Errorf("Expected %s for %q, got %s", tt.expected, tt.input, got)
+		}
+	}
+}
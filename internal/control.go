@@ -0,0 +1,141 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// controlRequest is the body of a request changing the queued packages
+type controlRequest struct {
+	Packages []string `json:"packages"`
+	Priority int      `json:"priority"`
+}
+
+// ControlHandler returns the HTTP API for controlling a run while it is in
+// progress:
+//
+//	GET  /queue          packages waiting to be tested, in order
+//	POST /queue          add {"packages": [...], "priority": 10}
+//	POST /queue/cancel   cancel queued {"packages": [...]}
+//	POST /pause          stop starting tests; running tests finish
+//	POST /resume         continue a paused run
+//	POST /follow         stream the test output of {"packages": [...]}
+//	POST /unfollow       stop streaming it
+//
+// Requests apply to whichever of runners is testing, e.g. the current
+// repository type of a matrix run.
+func ControlHandler(runners []*RegressionTestRunner) http.Handler {
+	// apply calls op with the runner in progress
+	apply := func(op func(r *RegressionTestRunner) (interface{}, error)) (interface{}, error) {
+		for _, runner := range runners {
+			value, err := op(runner)
+			if errors.Is(err, ErrNoRunInProgress) {
+				continue
+			}
+			return value, err
+		}
+		return nil, ErrNoRunInProgress
+	}
+
+	// packages adapts the operations returning packages, which are encoded
+	// as an empty list rather than null
+	packages := func(op func(r *RegressionTestRunner) ([]string, error)) func(r *RegressionTestRunner) (interface{}, error) {
+		return func(r *RegressionTestRunner) (interface{}, error) {
+			names, err := op(r)
+			if names == nil {
+				names = []string{}
+			}
+			return names, err
+		}
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := strings.Trim(r.URL.Path, "/")
+
+		var req controlRequest
+		if r.Method == http.MethodPost && (strings.HasPrefix(path, "queue") || path == "follow" || path == "unfollow") {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, fmt.Sprintf("invalid request: %v", err), http.StatusBadRequest)
+				return
+			}
+		}
+
+		var key string
+		var op func(runner *RegressionTestRunner) (interface{}, error)
+		switch {
+		case path == "queue" && r.Method == http.MethodGet:
+			key, op = "queued", packages((*RegressionTestRunner).QueuedPackages)
+		case path == "queue" && r.Method == http.MethodPost:
+			key, op = "added", packages(func(runner *RegressionTestRunner) ([]string, error) {
+				return runner.Enqueue(req.Packages, req.Priority)
+			})
+		case path == "queue/cancel" && r.Method == http.MethodPost:
+			key, op = "cancelled", packages(func(runner *RegressionTestRunner) ([]string, error) {
+				return runner.Cancel(req.Packages)
+			})
+		case path == "pause" && r.Method == http.MethodPost:
+			key, op = "paused", func(runner *RegressionTestRunner) (interface{}, error) {
+				return true, runner.Pause()
+			}
+		case path == "resume" && r.Method == http.MethodPost:
+			key, op = "paused", func(runner *RegressionTestRunner) (interface{}, error) {
+				return false, runner.Resume()
+			}
+		case path == "follow" && r.Method == http.MethodPost:
+			key, op = "followed", packages(func(runner *RegressionTestRunner) ([]string, error) {
+				return runner.Follow(req.Packages)
+			})
+		case path == "unfollow" && r.Method == http.MethodPost:
+			key, op = "unfollowed", packages(func(runner *RegressionTestRunner) ([]string, error) {
+				return runner.Unfollow(req.Packages)
+			})
+		case path == "queue" || path == "queue/cancel" || path == "pause" || path == "resume" || path == "follow" || path == "unfollow":
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		default:
+			http.NotFound(w, r)
+			return
+		}
+
+		value, err := apply(op)
+		switch {
+		case errors.Is(err, ErrNoRunInProgress):
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{key: value})
+	})
+}
+
+// ServeControl serves the control API of runners on the Unix socket at
+// path until the returned function is called
+func ServeControl(path string, runners []*RegressionTestRunner) (func(), error) {
+	// A socket left behind by a run that crashed would make Listen fail
+	if info, err := os.Stat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+		os.Remove(path)
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on control socket %s: %w", path, err)
+	}
+
+	server := &http.Server{Handler: ControlHandler(runners)}
+	go server.Serve(listener)
+
+	return func() {
+		server.Close()
+		os.Remove(path)
+	}, nil
+}
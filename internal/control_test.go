@@ -0,0 +1,127 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRunnerEnqueueAndCancel(t *testing.T) {
+	runner := NewRegressionTestRunnerFromPackageList([]string{"a", "c"}, "https://example.com/repo", "/tmp", "wolfi", 1, true, time.Minute, false)
+	runner.setLogDir(t.TempDir())
+
+	if _, err := runner.Enqueue([]string{"b"}, 0); !errors.Is(err, ErrNoRunInProgress) {
+		t.Errorf("Expected ErrNoRunInProgress before the run, got %v", err)
+	}
+
+	var mu sync.Mutex
+	var tested []string
+	runner.SetExecutor(TestExecutorFunc(func(ctx context.Context, pkg string, opts ExecuteOptions) TestResult {
+		mu.Lock()
+		tested = append(tested, pkg)
+		mu.Unlock()
+
+		if pkg == "a" {
+			for _, invalid := range [][]string{{""}, {"../etc/passwd"}, {"b", "sub/pkg"}, {"b", "a..b"}} {
+				if added, err := runner.Enqueue(invalid, 5); err == nil || added != nil {
+					t.Errorf("Expected %q to be rejected, got %v (%v)", invalid, added, err)
+				}
+			}
+			if added, err := runner.Enqueue([]string{"b", "a"}, 5); err != nil || !reflect.DeepEqual(added, []string{"b"}) {
+				t.Errorf("Expected b to be added, got %v (%v)", added, err)
+			}
+			if cancelled, err := runner.Cancel([]string{"c"}); err != nil || !reflect.DeepEqual(cancelled, []string{"c"}) {
+				t.Errorf("Expected c to be cancelled, got %v (%v)", cancelled, err)
+			}
+		}
+		return newTestResult(pkg, opts.WithRepo, time.Now(), "", nil)
+	}))
+
+	if err := runner.RunFromPackageList([]string{"a", "c"}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if !reflect.DeepEqual(tested, []string{"a", "b"}) {
+		t.Errorf("Expected a and b to be tested, got %v", tested)
+	}
+	if runner.summary.Total != 2 {
+		t.Errorf("Expected a total of 2 packages, got %d", runner.summary.Total)
+	}
+	if !reflect.DeepEqual(runner.manifest.Packages, []string{"a", "b"}) {
+		t.Errorf("Expected the manifest to list a and b, got %v", runner.manifest.Packages)
+	}
+	if _, err := runner.Cancel([]string{"a"}); !errors.Is(err, ErrNoRunInProgress) {
+		t.Errorf("Expected ErrNoRunInProgress after the run, got %v", err)
+	}
+}
+
+func TestControlHandler(t *testing.T) {
+	runner := NewRegressionTestRunnerFromPackageList([]string{"a", "c"}, "https://example.com/repo", "/tmp", "wolfi", 1, true, time.Minute, false)
+	runner.setLogDir(t.TempDir())
+	handler := ControlHandler([]*RegressionTestRunner{runner})
+
+	request := func(method, path, body string) (int, map[string][]string) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+		var response map[string][]string
+		json.Unmarshal(rec.Body.Bytes(), &response)
+		return rec.Code, response
+	}
+
+	if code, _ := request(http.MethodGet, "/queue", ""); code != http.StatusConflict {
+		t.Errorf("Expected 409 without a run in progress, got %d", code)
+	}
+	if code, _ := request(http.MethodDelete, "/queue", ""); code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected 405, got %d", code)
+	}
+	if code, _ := request(http.MethodGet, "/jobs", ""); code != http.StatusNotFound {
+		t.Errorf("Expected 404, got %d", code)
+	}
+
+	runner.SetExecutor(TestExecutorFunc(func(ctx context.Context, pkg string, opts ExecuteOptions) TestResult {
+		if pkg == "a" {
+			if code, response := request(http.MethodPost, "/queue", `{"packages": ["b"], "priority": 1}`); code != http.StatusOK || !reflect.DeepEqual(response["added"], []string{"b"}) {
+				t.Errorf("Expected b to be added, got %d %v", code, response)
+			}
+			if code, response := request(http.MethodGet, "/queue", ""); code != http.StatusOK || !reflect.DeepEqual(response["queued"], []string{"b", "c"}) {
+				t.Errorf("Expected b and c to be queued, got %d %v", code, response)
+			}
+			if code, response := request(http.MethodPost, "/queue/cancel", `{"packages": ["c", "x"]}`); code != http.StatusOK || !reflect.DeepEqual(response["cancelled"], []string{"c"}) {
+				t.Errorf("Expected c to be cancelled, got %d %v", code, response)
+			}
+			if code, _ := request(http.MethodPost, "/queue", `{"packages": ["../../tmp/x"]}`); code != http.StatusBadRequest {
+				t.Errorf("Expected 400 for an invalid package name, got %d", code)
+			}
+			if code, _ := request(http.MethodPost, "/queue", `not json`); code != http.StatusBadRequest {
+				t.Errorf("Expected 400 for an invalid request, got %d", code)
+			}
+			if code, _ := request(http.MethodPost, "/pause", ""); code != http.StatusOK || !runner.activeQueue().isPaused() {
+				t.Errorf("Expected the run to be paused, got %d", code)
+			}
+			if code, _ := request(http.MethodGet, "/resume", ""); code != http.StatusMethodNotAllowed {
+				t.Errorf("Expected 405, got %d", code)
+			}
+			if code, _ := request(http.MethodPost, "/resume", ""); code != http.StatusOK || runner.activeQueue().isPaused() {
+				t.Errorf("Expected the run to be resumed, got %d", code)
+			}
+		}
+		return newTestResult(pkg, opts.WithRepo, time.Now(), "", nil)
+	}))
+
+	if err := runner.RunFromPackageList([]string{"a", "c"}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !reflect.DeepEqual(runner.summary.Successful, []string{"a", "b"}) {
+		t.Errorf("Expected a and b to pass, got %v", runner.summary.Successful)
+	}
+}
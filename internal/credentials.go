@@ -0,0 +1,127 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Credential authenticates requests to one repository host with HTTP basic
+// auth. The password is given literally, read from an environment variable
+// or obtained from chainctl for an audience, so that tokens can be kept out
+// of the file.
+type Credential struct {
+	Username         string `yaml:"username"`
+	Password         string `yaml:"password"`
+	PasswordEnv      string `yaml:"passwordEnv"`
+	ChainctlAudience string `yaml:"chainctlAudience"`
+}
+
+// credentialsFile is the format of the file given to LoadCredentials:
+//
+//	hosts:
+//	  apk.cgr.dev:
+//	    chainctlAudience: apk.cgr.dev
+//	  artifactory.example.com:
+//	    username: ci
+//	    passwordEnv: ARTIFACTORY_TOKEN
+type credentialsFile struct {
+	Hosts map[string]Credential `yaml:"hosts"`
+}
+
+// credentials are the configured credentials by host
+var credentials map[string]Credential
+
+// LoadCredentials reads the per-host credentials file at path
+func LoadCredentials(path string) (map[string]Credential, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read credentials file: %w", err)
+	}
+
+	var file credentialsFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse credentials file %s: %w", path, err)
+	}
+	for host, cred := range file.Hosts {
+		sources := 0
+		for _, source := range []string{cred.Password, cred.PasswordEnv, cred.ChainctlAudience} {
+			if source != "" {
+				sources++
+			}
+		}
+		if sources != 1 {
+			return nil, fmt.Errorf("credentials for %s in %s: expected exactly one of password, passwordEnv and chainctlAudience", host, path)
+		}
+	}
+	return file.Hosts, nil
+}
+
+// basicAuth is a username and password for HTTP basic auth. The zero value
+// sends no credentials.
+type basicAuth struct {
+	Username string
+	Password string
+}
+
+// apply adds the credentials to req, if there are any
+func (b basicAuth) apply(req *http.Request) {
+	if b.Password != "" {
+		req.SetBasicAuth(b.Username, b.Password)
+	}
+}
+
+// httpAuth returns the credentials in the HTTP_AUTH format apkrane, melange
+// and apko read, which applies them to requests to host only
+func (b basicAuth) httpAuth(host string) string {
+	return fmt.Sprintf("basic:%s:%s:%s", host, b.Username, b.Password)
+}
+
+// hostCredentials returns the credentials configured for host. ok is false if
+// there are none.
+func hostCredentials(host string) (auth basicAuth, ok bool, err error) {
+	cred, ok := credentials[host]
+	if !ok {
+		return basicAuth{}, false, nil
+	}
+
+	auth.Username = cred.Username
+	if auth.Username == "" {
+		auth.Username = "user"
+	}
+	switch {
+	case cred.Password != "":
+		auth.Password = cred.Password
+	case cred.PasswordEnv != "":
+		auth.Password = os.Getenv(cred.PasswordEnv)
+		if auth.Password == "" {
+			return basicAuth{}, true, fmt.Errorf("%w: credentials for %s: %s is not set", ErrAuth, host, cred.PasswordEnv)
+		}
+	default:
+		token, err := chainctlAudienceToken(cred.ChainctlAudience)
+		if err != nil {
+			return basicAuth{}, true, fmt.Errorf("credentials for %s: %w", host, err)
+		}
+		auth.Password = token
+	}
+	return auth, true, nil
+}
+
+// chainctlCredentials returns the credentials for host, which serves a
+// repository authenticated with chainctl tokens for audience, e.g.
+// apk.cgr.dev: those configured for it, or else a chainctl token
+func chainctlCredentials(host, audience string) (basicAuth, error) {
+	if auth, ok, err := hostCredentials(host); ok {
+		return auth, err
+	}
+	token, err := chainctlAudienceToken(audience)
+	if err != nil {
+		return basicAuth{}, err
+	}
+	return basicAuth{Username: "user", Password: token}, nil
+}
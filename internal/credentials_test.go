@@ -0,0 +1,126 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadCredentials(t *testing.T) {
+	tests := []struct {
+		name          string
+		content       string
+		expectedError string
+	}{
+		{
+			name:    "valid",
+			content: "hosts:\n  apk.cgr.dev:\n    chainctlAudience: apk.cgr.dev\n  mirror.example.com:\n    username: ci\n    passwordEnv: MIRROR_TOKEN\n",
+		},
+		{
+			name:          "no password",
+			content:       "hosts:\n  mirror.example.com:\n    username: ci\n",
+			expectedError: "expected exactly one of password, passwordEnv and chainctlAudience",
+		},
+		{
+			name:          "two passwords",
+			content:       "hosts:\n  mirror.example.com:\n    password: secret\n    passwordEnv: MIRROR_TOKEN\n",
+			expectedError: "expected exactly one of password, passwordEnv and chainctlAudience",
+		},
+		{
+			name:          "invalid YAML",
+			content:       "hosts: [",
+			expectedError: "failed to parse credentials file",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "credentials.yaml")
+			if err := os.WriteFile(path, []byte(tt.content), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			creds, err := LoadCredentials(path)
+			if tt.expectedError == "" {
+				if err != nil {
+					t.Fatalf("Expected no error, got %v", err)
+				}
+				if creds["mirror.example.com"].PasswordEnv != "MIRROR_TOKEN" || creds["apk.cgr.dev"].ChainctlAudience != "apk.cgr.dev" {
+					t.Errorf("Unexpected credentials: %+v", creds)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.expectedError) {
+				t.Errorf("Expected error containing %q, got %v", tt.expectedError, err)
+			}
+		})
+	}
+}
+
+func TestHostCredentials(t *testing.T) {
+	defer ConfigureNetwork(NetworkConfig{})
+	if err := ConfigureNetwork(NetworkConfig{Credentials: map[string]Credential{
+		"literal.example.com": {Username: "ci", Password: "secret"},
+		"env.example.com":     {PasswordEnv: "APKREGRESS_TEST_TOKEN"},
+	}}); err != nil {
+		t.Fatal(err)
+	}
+
+	auth, ok, err := hostCredentials("literal.example.com")
+	if !ok || err != nil || auth != (basicAuth{Username: "ci", Password: "secret"}) {
+		t.Errorf("Expected literal credentials, got %+v (%v, %v)", auth, ok, err)
+	}
+
+	if _, ok, err := hostCredentials("env.example.com"); !ok || err == nil {
+		t.Errorf("Expected an error for an unset password variable, got %v", err)
+	}
+	t.Setenv("APKREGRESS_TEST_TOKEN", "token")
+	auth, _, err = hostCredentials("env.example.com")
+	if err != nil || auth != (basicAuth{Username: "user", Password: "token"}) {
+		t.Errorf("Expected the password from the environment and the default username, got %+v (%v)", auth, err)
+	}
+
+	if _, ok, _ := hostCredentials("other.example.com"); ok {
+		t.Errorf("Expected no credentials for an unconfigured host")
+	}
+}
+
+func TestCredentialsApplyToRequests(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "ci" || pass != "secret" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Write([]byte("index"))
+	}))
+	defer server.Close()
+	host := urlHost(server.URL)
+
+	if _, err := fetchCandidateIndex(server.URL + "/APKINDEX.tar.gz"); err == nil {
+		t.Fatalf("Expected the request to be rejected without credentials")
+	}
+
+	defer ConfigureNetwork(NetworkConfig{})
+	if err := ConfigureNetwork(NetworkConfig{Credentials: map[string]Credential{host: {Username: "ci", Password: "secret"}}}); err != nil {
+		t.Fatal(err)
+	}
+	data, err := fetchCandidateIndex(server.URL + "/APKINDEX.tar.gz")
+	if err != nil || string(data) != "index" {
+		t.Errorf("Expected the index to be fetched with the configured credentials, got %q (%v)", data, err)
+	}
+
+	t.Setenv("HTTP_AUTH", "")
+	auth := RepositoryAuth("wolfi", server.URL+"/os")
+	if auth == nil {
+		t.Fatalf("Expected tests against the candidate repository to get its credentials")
+	}
+	if value, err := auth(); err != nil || value != "basic:"+host+":ci:secret" {
+		t.Errorf("Expected HTTP_AUTH for %s, got %q (%v)", host, value, err)
+	}
+}
@@ -0,0 +1,352 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// jobStopGrace is how long a job has to stop its tests and clean up when
+// the daemon stops, within the default grace period of container
+// orchestrators
+const jobStopGrace = 20 * time.Second
+
+// DaemonTokenEnv names the environment variable holding the bearer token of
+// the daemon's HTTP API, when --token isn't given
+const DaemonTokenEnv = "APKREGRESS_DAEMON_TOKEN"
+
+// Daemon runs the jobs of a JobQueue with bounded parallelism. Each job runs
+// apkregress as a separate process, so jobs can't interfere with each other
+// and a crash only fails the job that caused it.
+type Daemon struct {
+	queue        *JobQueue
+	workers      int
+	executable   string
+	pollInterval time.Duration
+	verbose      bool
+
+	// token is the bearer token every /jobs request must carry
+	token string
+	// workRoot is the directory jobs submitted over HTTP must run within
+	workRoot string
+
+	mu      sync.Mutex
+	running map[string]bool
+
+	// health is ready while jobs are being processed
+	health *Health
+}
+
+// NewDaemon returns a daemon running up to workers jobs at once
+func NewDaemon(queue *JobQueue, workers int, verbose bool) (*Daemon, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("failed to locate apkregress executable: %w", err)
+	}
+
+	if workers < 1 {
+		workers = 1
+	}
+
+	return &Daemon{
+		queue:        queue,
+		workers:      workers,
+		executable:   executable,
+		pollInterval: 5 * time.Second,
+		verbose:      verbose,
+		running:      make(map[string]bool),
+		health:       NewHealth(),
+	}, nil
+}
+
+// Run processes queued jobs until ctx is done. Jobs still running then are
+// killed and left queued, so they run again when the daemon restarts.
+func (d *Daemon) Run(ctx context.Context) error {
+	recovered, err := d.queue.Recover()
+	if err != nil {
+		return fmt.Errorf("failed to recover jobs: %w", err)
+	}
+	for _, job := range recovered {
+		fmt.Printf("Requeued interrupted job %s\n", job.ID)
+	}
+	d.health.SetReady()
+
+	var wg sync.WaitGroup
+	ticker := time.NewTicker(d.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		for _, job := range d.claim() {
+			wg.Add(1)
+			go func(job *Job) {
+				defer wg.Done()
+				d.runJob(ctx, job)
+			}(job)
+		}
+
+		select {
+		case <-ctx.Done():
+			d.health.SetStopping()
+			wg.Wait()
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// claim marks as many queued jobs as there are free workers as running
+func (d *Daemon) claim() []*Job {
+	jobs, err := d.queue.Jobs()
+	if err != nil {
+		fmt.Printf("Warning: failed to list jobs: %v\n", err)
+		return nil
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var claimed []*Job
+	for _, job := range jobs {
+		if len(d.running) >= d.workers {
+			break
+		}
+		if job.Status != JobQueued || d.running[job.ID] {
+			continue
+		}
+
+		job.Status = JobRunning
+		job.StartedAt = time.Now()
+		if err := d.queue.Save(job); err != nil {
+			fmt.Printf("Warning: failed to start job %s: %v\n", job.ID, err)
+			continue
+		}
+		d.running[job.ID] = true
+		claimed = append(claimed, job)
+	}
+	return claimed
+}
+
+func (d *Daemon) runJob(ctx context.Context, job *Job) {
+	defer func() {
+		d.mu.Lock()
+		delete(d.running, job.ID)
+		d.mu.Unlock()
+	}()
+
+	fmt.Printf("Starting job %s: apkregress %s\n", job.ID, strings.Join(job.Args, " "))
+
+	err := d.execute(ctx, job)
+	if ctx.Err() != nil {
+		// Interrupted by shutdown: leave the job to be recovered on restart
+		fmt.Printf("Job %s interrupted\n", job.ID)
+		return
+	}
+
+	job.FinishedAt = time.Now()
+	job.ExitCode = exitCode(err)
+	job.Status = JobSucceeded
+	job.Error = ""
+	if err != nil {
+		job.Status = JobFailed
+		job.Error = err.Error()
+	}
+	if saveErr := d.queue.Save(job); saveErr != nil {
+		fmt.Printf("Warning: failed to save job %s: %v\n", job.ID, saveErr)
+	}
+
+	fmt.Printf("Finished job %s: %s after %v\n", job.ID, job.Status, job.FinishedAt.Sub(job.StartedAt).Round(time.Second))
+}
+
+// execute runs a job's apkregress process, logging its output
+func (d *Daemon) execute(ctx context.Context, job *Job) error {
+	logFile, err := os.Create(job.LogPath)
+	if err != nil {
+		return fmt.Errorf("failed to create log file %s: %w", job.LogPath, err)
+	}
+	defer logFile.Close()
+
+	cmd := exec.Command(d.executable, job.Args...)
+	cmd.Dir = job.WorkDir
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+
+	if err := startInProcessGroup(cmd); err != nil {
+		return fmt.Errorf("failed to start job: %w", err)
+	}
+	return waitTerminating(ctx, cmd, jobStopGrace)
+}
+
+// SetToken sets the bearer token required by the /jobs API. Without one,
+// every /jobs request is refused.
+func (d *Daemon) SetToken(token string) {
+	d.token = token
+}
+
+// SetWorkRoot sets the directory that the working directories of jobs
+// submitted over HTTP must be within
+func (d *Daemon) SetWorkRoot(root string) error {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return fmt.Errorf("failed to resolve work root: %w", err)
+	}
+	root, err = filepath.EvalSymlinks(root)
+	if err != nil {
+		return fmt.Errorf("failed to resolve work root: %w", err)
+	}
+	d.workRoot = root
+	return nil
+}
+
+// Health returns the health of the daemon, for its probe endpoints
+func (d *Daemon) Health() *Health {
+	return d.health
+}
+
+// Handler returns the HTTP API of the daemon:
+//
+//	POST /jobs           submit {"args": [...], "workDir": "..."}
+//	GET  /jobs           list jobs
+//	GET  /jobs/<id>      show a job
+//	GET  /jobs/<id>/log  the job's output
+//	GET  /healthz        200 while the daemon answers
+//	GET  /readyz         200 while it processes jobs
+//
+// Requests to /jobs must carry the daemon's token as a bearer token.
+func (d *Daemon) Handler() http.Handler {
+	health := d.health.Handler()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isHealthPath(r.URL.Path) {
+			health.ServeHTTP(w, r)
+			return
+		}
+		path := strings.Trim(r.URL.Path, "/")
+		parts := strings.Split(path, "/")
+		if parts[0] != "jobs" || len(parts) > 3 {
+			http.NotFound(w, r)
+			return
+		}
+		if !d.authorized(r) {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		switch {
+		case len(parts) == 1 && r.Method == http.MethodPost:
+			d.handleSubmit(w, r)
+		case len(parts) == 1 && r.Method == http.MethodGet:
+			jobs, err := d.queue.Jobs()
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			writeJSON(w, http.StatusOK, jobs)
+		case len(parts) >= 2 && r.Method == http.MethodGet:
+			job, err := d.queue.Get(parts[1])
+			if errors.Is(err, ErrJobNotFound) {
+				http.NotFound(w, r)
+				return
+			}
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			if len(parts) == 2 {
+				writeJSON(w, http.StatusOK, job)
+				return
+			}
+			if parts[2] != "log" {
+				http.NotFound(w, r)
+				return
+			}
+			d.handleLog(w, r, job)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+}
+
+// authorized reports whether a request carries the daemon's token
+func (d *Daemon) authorized(r *http.Request) bool {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && d.token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(d.token)) == 1
+}
+
+// checkWorkDir resolves a job's working directory, which must be within the
+// work root so the API can't run jobs anywhere on the machine
+func (d *Daemon) checkWorkDir(dir string) (string, error) {
+	if d.workRoot == "" {
+		return "", errors.New("no work root configured")
+	}
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(d.workRoot, dir)
+	}
+	resolved, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return "", fmt.Errorf("invalid workDir: %w", err)
+	}
+	rel, err := filepath.Rel(d.workRoot, resolved)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("workDir %s is outside %s", dir, d.workRoot)
+	}
+	return resolved, nil
+}
+
+func (d *Daemon) handleSubmit(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Args    []string `json:"args"`
+		WorkDir string   `json:"workDir"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("invalid job: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	workDir, err := d.checkWorkDir(req.WorkDir)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	job, err := d.queue.Submit(req.Args, workDir)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, http.StatusCreated, job)
+}
+
+func (d *Daemon) handleLog(w http.ResponseWriter, r *http.Request, job *Job) {
+	file, err := os.Open(job.LogPath)
+	if os.IsNotExist(err) {
+		http.Error(w, "job has not started", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer file.Close()
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	io.Copy(w, file)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
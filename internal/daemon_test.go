@@ -0,0 +1,199 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestDaemon(t *testing.T, executable string, workers int) *Daemon {
+	t.Helper()
+
+	queue, err := NewJobQueue(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+	daemon, err := NewDaemon(queue, workers, false)
+	if err != nil {
+		t.Fatalf("Failed to create daemon: %v", err)
+	}
+	daemon.executable = executable
+	daemon.pollInterval = 10 * time.Millisecond
+	return daemon
+}
+
+func TestDaemonRunsJobs(t *testing.T) {
+	daemon := newTestDaemon(t, "/bin/sh", 2)
+	workDir := t.TempDir()
+
+	ok, _ := daemon.queue.Submit([]string{"-c", "pwd"}, workDir)
+	failing, _ := daemon.queue.Submit([]string{"-c", "exit 3"}, workDir)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- daemon.Run(ctx) }()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		a, _ := daemon.queue.Get(ok.ID)
+		b, _ := daemon.queue.Get(failing.ID)
+		if a.Status != JobQueued && a.Status != JobRunning && b.Status != JobQueued && b.Status != JobRunning {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("Daemon failed: %v", err)
+	}
+
+	job, _ := daemon.queue.Get(ok.ID)
+	if job.Status != JobSucceeded || job.ExitCode != 0 {
+		t.Errorf("Expected job to succeed, got %+v", job)
+	}
+	output, err := os.ReadFile(job.LogPath)
+	if err != nil || strings.TrimSpace(string(output)) != workDir {
+		t.Errorf("Expected job to run in %s, got %q (%v)", workDir, output, err)
+	}
+
+	job, _ = daemon.queue.Get(failing.ID)
+	if job.Status != JobFailed || job.ExitCode != 3 {
+		t.Errorf("Expected job to fail with exit code 3, got %+v", job)
+	}
+}
+
+func TestDaemonShutdownLeavesJobRunning(t *testing.T) {
+	daemon := newTestDaemon(t, "/bin/sh", 1)
+	job, _ := daemon.queue.Submit([]string{"-c", "sleep 60"}, t.TempDir())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- daemon.Run(ctx) }()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if j, _ := daemon.queue.Get(job.ID); j.Status == JobRunning {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	<-done
+
+	// The interrupted job is requeued when the daemon starts again
+	recovered, err := daemon.queue.Recover()
+	if err != nil {
+		t.Fatalf("Failed to recover: %v", err)
+	}
+	if len(recovered) != 1 || recovered[0].ID != job.ID {
+		t.Errorf("Expected %s to be recovered, got %v", job.ID, recovered)
+	}
+}
+
+func TestDaemonHandler(t *testing.T) {
+	daemon := newTestDaemon(t, "/bin/true", 1)
+	daemon.SetToken("secret")
+	root := t.TempDir()
+	if err := daemon.SetWorkRoot(root); err != nil {
+		t.Fatalf("Failed to set work root: %v", err)
+	}
+	workDir := filepath.Join(root, "work")
+	if err := os.Mkdir(workDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(daemon.Handler())
+	defer server.Close()
+
+	do := func(method, path, token, body string) *http.Response {
+		t.Helper()
+		req, _ := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s failed: %v", method, path, err)
+		}
+		return resp
+	}
+
+	resp := do(http.MethodPost, "/jobs", "secret", `{"args": ["--package", "curl"], "workDir": "`+workDir+`"}`)
+	var job Job
+	json.NewDecoder(resp.Body).Decode(&job)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated || job.ID == "" || job.WorkDir != workDir {
+		t.Fatalf("Unexpected submit response %s: %+v", resp.Status, job)
+	}
+
+	tests := []struct {
+		method         string
+		path           string
+		token          string
+		body           string
+		expectedStatus int
+	}{
+		{method: http.MethodGet, path: "/jobs", token: "secret", expectedStatus: http.StatusOK},
+		{method: http.MethodGet, path: "/jobs/" + job.ID, token: "secret", expectedStatus: http.StatusOK},
+		{method: http.MethodGet, path: "/jobs/" + job.ID + "/log", token: "secret", expectedStatus: http.StatusNotFound},
+		{method: http.MethodGet, path: "/jobs/missing", token: "secret", expectedStatus: http.StatusNotFound},
+		{method: http.MethodGet, path: "/other", token: "secret", expectedStatus: http.StatusNotFound},
+		{method: http.MethodPost, path: "/jobs", token: "secret", body: `{"args": []}`, expectedStatus: http.StatusBadRequest},
+		{method: http.MethodPost, path: "/jobs", token: "secret", body: `not json`, expectedStatus: http.StatusBadRequest},
+		{method: http.MethodDelete, path: "/jobs", token: "secret", expectedStatus: http.StatusMethodNotAllowed},
+		// Every /jobs request needs the token
+		{method: http.MethodGet, path: "/jobs", expectedStatus: http.StatusUnauthorized},
+		{method: http.MethodGet, path: "/jobs/" + job.ID, token: "wrong", expectedStatus: http.StatusUnauthorized},
+		{method: http.MethodPost, path: "/jobs", body: `{"args": ["-c", "id"]}`, expectedStatus: http.StatusUnauthorized},
+		// Jobs only run within the work root
+		{method: http.MethodPost, path: "/jobs", token: "secret", body: `{"args": ["--package", "curl"]}`, expectedStatus: http.StatusCreated},
+		{method: http.MethodPost, path: "/jobs", token: "secret", body: `{"args": ["--package", "curl"], "workDir": "work"}`, expectedStatus: http.StatusCreated},
+		{method: http.MethodPost, path: "/jobs", token: "secret", body: `{"args": ["--package", "curl"], "workDir": "/"}`, expectedStatus: http.StatusForbidden},
+		{method: http.MethodPost, path: "/jobs", token: "secret", body: `{"args": ["--package", "curl"], "workDir": "../"}`, expectedStatus: http.StatusForbidden},
+		{method: http.MethodPost, path: "/jobs", token: "secret", body: `{"args": ["--package", "curl"], "workDir": "missing"}`, expectedStatus: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		resp := do(tt.method, tt.path, tt.token, tt.body)
+		resp.Body.Close()
+		if resp.StatusCode != tt.expectedStatus {
+			t.Errorf("%s %s %s: expected status %d, got %d", tt.method, tt.path, tt.body, tt.expectedStatus, resp.StatusCode)
+		}
+	}
+}
+
+func TestDaemonHandlerWithoutToken(t *testing.T) {
+	daemon := newTestDaemon(t, "/bin/true", 1)
+	if err := daemon.SetWorkRoot(t.TempDir()); err != nil {
+		t.Fatalf("Failed to set work root: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	daemon.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/jobs", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected a daemon without a token to refuse jobs requests, got %d", rec.Code)
+	}
+}
+
+func TestDaemonWorkRootSymlink(t *testing.T) {
+	daemon := newTestDaemon(t, "/bin/true", 1)
+	root := t.TempDir()
+	if err := daemon.SetWorkRoot(root); err != nil {
+		t.Fatalf("Failed to set work root: %v", err)
+	}
+	if err := os.Symlink("/", filepath.Join(root, "escape")); err != nil {
+		t.Fatal(err)
+	}
+
+	if dir, err := daemon.checkWorkDir("escape"); err == nil {
+		t.Errorf("Expected a symlink out of the work root to be rejected, got %s", dir)
+	}
+}
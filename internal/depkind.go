@@ -0,0 +1,211 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Kinds of dependency a consumer has on the package: at runtime, as listed
+// in the index, at build time, as declared in its melange config, or both.
+// Runtime-only regressions need other testing than build breakage.
+const (
+	DepKindRuntime = "runtime"
+	DepKindBuild   = "build"
+	DepKindBoth    = "both"
+	// DepKindAll selects consumers of every kind
+	DepKindAll = "all"
+)
+
+// depKinds are the kinds consumers can be selected by
+var depKinds = []string{DepKindRuntime, DepKindBuild, DepKindAll}
+
+// SetDepKind selects the consumers tested by how they depend on the
+// package: runtime tests those the index lists, build those whose melange
+// config declares it, and all ("" too) both
+func (r *RegressionTestRunner) SetDepKind(kind string) error {
+	if kind != "" && !isOneOf(depKinds, kind) {
+		return fmt.Errorf("invalid dependency kind: %s (must be %s)", kind, strings.Join(depKinds, ", "))
+	}
+	r.depKind = kind
+	return nil
+}
+
+// consumerKind returns how a consumer depends on the package
+func (r *RegressionTestRunner) consumerKind(pkg string) string {
+	_, build := r.buildDeps[pkg]
+	switch {
+	case build && r.runtimeDeps[pkg]:
+		return DepKindBoth
+	case build:
+		return DepKindBuild
+	default:
+		return DepKindRuntime
+	}
+}
+
+// selectsKind reports whether consumers of kind are tested with the
+// selected dependency kind; those depending on the package both ways
+// always are
+func (r *RegressionTestRunner) selectsKind(kind string) bool {
+	switch r.depKind {
+	case DepKindRuntime:
+		return kind != DepKindBuild
+	case DepKindBuild:
+		return kind != DepKindRuntime
+	}
+	return true
+}
+
+// selectConsumers reports how the consumers depend on the package and
+// returns those of the selected kind
+func (r *RegressionTestRunner) selectConsumers(consumers []string) []string {
+	if r.buildDeps == nil {
+		return consumers
+	}
+	counts := make(map[string]int)
+	var selected, dropped []string
+	for _, pkg := range consumers {
+		kind := r.consumerKind(pkg)
+		counts[kind]++
+		if r.selectsKind(kind) {
+			selected = append(selected, pkg)
+		} else {
+			dropped = append(dropped, pkg)
+		}
+	}
+
+	fmt.Printf("Consumers by dependency kind: %d runtime, %d build, %d both\n", counts[DepKindRuntime], counts[DepKindBuild], counts[DepKindBoth])
+	if len(dropped) > 0 {
+		fmt.Printf("Not testing %d consumers without a %s dependency (--dep-kind %s)\n", len(dropped), r.depKind, r.depKind)
+		if r.verbose {
+			for _, pkg := range dropped {
+				fmt.Printf("  - %s (%s)\n", pkg, r.consumerKind(pkg))
+			}
+		}
+	}
+	return selected
+}
+
+// dependencyKinds maps the consumers to how they depend on the package, for
+// the run manifest, or returns nil if build-time dependencies weren't
+// looked up
+func (r *RegressionTestRunner) dependencyKinds(packages []string) map[string]string {
+	if r.buildDeps == nil {
+		return nil
+	}
+	kinds := make(map[string]string, len(packages))
+	for _, pkg := range packages {
+		kinds[pkg] = r.consumerKind(pkg)
+	}
+	return kinds
+}
+
+// keep removes the consumers not in selected from the graph
+func (g *ConsumerGraph) keep(selected []string) {
+	kept := make(map[string]bool, len(selected))
+	for _, pkg := range selected {
+		kept[pkg] = true
+	}
+	var levels [][]string
+	for _, level := range g.Levels {
+		var remaining []string
+		for _, pkg := range level {
+			if kept[pkg] {
+				remaining = append(remaining, pkg)
+			}
+		}
+		if len(remaining) > 0 {
+			levels = append(levels, remaining)
+		}
+	}
+	for pkg := range g.Parents {
+		if !kept[pkg] {
+			delete(g.Parents, pkg)
+		}
+	}
+	g.Levels = levels
+}
+
+// depKindStats summarizes the tested consumers of one dependency kind
+type depKindStats struct {
+	Kind        string `json:"kind"`
+	Tested      int    `json:"tested"`
+	Failed      int    `json:"failed"`
+	Regressions int    `json:"regressions"`
+	Hung        int    `json:"hung"`
+}
+
+// depKindBreakdown counts the outcomes of the tested packages by how they
+// depend on the package. It is empty unless they depend on it in more than
+// one way.
+func (r *RegressionTestRunner) depKindBreakdown(statuses map[string]string) []depKindStats {
+	if r.buildDeps == nil {
+		return nil
+	}
+	stats := make(map[string]*depKindStats)
+	for _, pkg := range sortedKeys(statuses) {
+		status := statuses[pkg]
+		switch status {
+		case StatusPass, StatusFail, StatusRegression, StatusHung:
+		default:
+			continue
+		}
+		kind := r.consumerKind(pkg)
+		s, ok := stats[kind]
+		if !ok {
+			s = &depKindStats{Kind: kind}
+			stats[kind] = s
+		}
+		s.Tested++
+		switch status {
+		case StatusFail:
+			s.Failed++
+		case StatusRegression:
+			s.Regressions++
+		case StatusHung:
+			s.Hung++
+		}
+	}
+	if len(stats) < 2 {
+		return nil
+	}
+
+	breakdown := make([]depKindStats, 0, len(stats))
+	for _, s := range stats {
+		breakdown = append(breakdown, *s)
+	}
+	order := map[string]int{DepKindRuntime: 0, DepKindBuild: 1, DepKindBoth: 2}
+	sort.Slice(breakdown, func(i, j int) bool {
+		return order[breakdown[i].Kind] < order[breakdown[j].Kind]
+	})
+	return breakdown
+}
+
+// printDepKinds lists the outcomes by dependency kind in the text summary
+func printDepKinds(w io.Writer, breakdown []depKindStats) {
+	if len(breakdown) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\nResults by dependency kind:\n")
+	for _, s := range breakdown {
+		fmt.Fprintf(w, "  - %s: %d tested, %d regressions, %d failed, %d hung\n", s.Kind, s.Tested, s.Regressions, s.Failed, s.Hung)
+	}
+}
+
+// printDepKindsMarkdown is printDepKinds for the markdown summary
+func printDepKindsMarkdown(w io.Writer, breakdown []depKindStats) {
+	if len(breakdown) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\n### 🔗 Results by Dependency Kind\n\n")
+	fmt.Fprintf(w, "| Dependency | Tested | Regressions | Failed | Hung |\n")
+	fmt.Fprintf(w, "|------------|--------|-------------|--------|------|\n")
+	for _, s := range breakdown {
+		fmt.Fprintf(w, "| %s | %d | %d | %d | %d |\n", s.Kind, s.Tested, s.Regressions, s.Failed, s.Hung)
+	}
+}
@@ -0,0 +1,89 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSelectConsumers(t *testing.T) {
+	consumers := []string{"curl", "haproxy", "nginx", "wget"}
+
+	tests := []struct {
+		kind     string
+		expected []string
+	}{
+		{"", consumers},
+		{DepKindAll, consumers},
+		{DepKindRuntime, []string{"curl", "wget"}},
+		{DepKindBuild, []string{"curl", "haproxy", "nginx"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.kind, func(t *testing.T) {
+			runner := &RegressionTestRunner{
+				buildDeps:   map[string][]string{"curl": {"openssl-dev"}, "haproxy": {"openssl"}, "nginx": {"openssl-dev>3"}},
+				runtimeDeps: map[string]bool{"curl": true, "wget": true},
+			}
+			if err := runner.SetDepKind(tt.kind); err != nil {
+				t.Fatal(err)
+			}
+			if selected := runner.selectConsumers(consumers); !reflect.DeepEqual(selected, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, selected)
+			}
+		})
+	}
+
+	runner := &RegressionTestRunner{}
+	if err := runner.SetDepKind("test"); err == nil {
+		t.Error("Expected an error for an invalid dependency kind")
+	}
+}
+
+func TestDepKindBreakdown(t *testing.T) {
+	runner := &RegressionTestRunner{
+		buildDeps:   map[string][]string{"curl": {"openssl-dev"}, "nginx": {"openssl-dev"}},
+		runtimeDeps: map[string]bool{"curl": true, "wget": true, "git": true},
+	}
+	statuses := map[string]string{
+		"curl":  StatusRegression,
+		"nginx": StatusRegression,
+		"wget":  StatusPass,
+		"git":   StatusFail,
+	}
+
+	expected := []depKindStats{
+		{Kind: DepKindRuntime, Tested: 2, Failed: 1},
+		{Kind: DepKindBuild, Tested: 1, Regressions: 1},
+		{Kind: DepKindBoth, Tested: 1, Regressions: 1},
+	}
+	if breakdown := runner.depKindBreakdown(statuses); !reflect.DeepEqual(breakdown, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, breakdown)
+	}
+
+	kinds := runner.dependencyKinds([]string{"curl", "nginx", "wget"})
+	if expected := map[string]string{"curl": DepKindBoth, "nginx": DepKindBuild, "wget": DepKindRuntime}; !reflect.DeepEqual(kinds, expected) {
+		t.Errorf("Expected %v, got %v", expected, kinds)
+	}
+
+	if breakdown := (&RegressionTestRunner{}).depKindBreakdown(statuses); breakdown != nil {
+		t.Errorf("Expected no breakdown without build-time dependencies, got %+v", breakdown)
+	}
+}
+
+func TestConsumerGraphKeep(t *testing.T) {
+	graph := ConsumerGraph{
+		Levels:  [][]string{{"curl", "nginx", "wget"}, {"git", "httpie"}},
+		Parents: map[string][]string{"git": {"curl"}, "httpie": {"curl", "wget"}},
+	}
+	graph.keep([]string{"curl", "nginx", "git"})
+
+	if expected := [][]string{{"curl", "nginx"}, {"git"}}; !reflect.DeepEqual(graph.Levels, expected) {
+		t.Errorf("Expected levels %v, got %v", expected, graph.Levels)
+	}
+	if expected := map[string][]string{"git": {"curl"}}; !reflect.DeepEqual(graph.Parents, expected) {
+		t.Errorf("Expected parents %v, got %v", expected, graph.Parents)
+	}
+}
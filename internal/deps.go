@@ -0,0 +1,144 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"fmt"
+	"io"
+	"sort"
+)
+
+// ChangedDependency is a dependency of a package that the candidate
+// repository provides at another version than the repository type's index
+type ChangedDependency struct {
+	// Dependency is the dependency as the package lists it, e.g.
+	// so:libssl.so.3 or openssl>3
+	Dependency string `json:"dependency"`
+	// Provider is the candidate package providing it
+	Provider string `json:"provider"`
+	// BaselineVersion is the version of Provider in the index, or "" if
+	// the candidate repository adds it
+	BaselineVersion  string `json:"baselineVersion,omitempty"`
+	CandidateVersion string `json:"candidateVersion"`
+}
+
+// DependencyChanges lists which dependencies of a package the candidate
+// repository changes, the inverse of finding the consumers of a changed
+// package, e.g. to explain why a consumer regressed
+type DependencyChanges struct {
+	Package  string `json:"package"`
+	RepoType string `json:"repoType"`
+	// Dependencies are the dependencies of the package's binary packages in
+	// the index
+	Dependencies []string            `json:"dependencies"`
+	Changed      []ChangedDependency `json:"changed"`
+}
+
+// FindChangedDependencies finds the dependencies of packageName, a package
+// or the origin of packages in client's index, that the candidate
+// repository apkRepo provides at another version
+func FindChangedDependencies(packageName string, client *ApkraneClient, apkRepo string) (*DependencyChanges, error) {
+	packages, err := client.loadIndex()
+	if err != nil {
+		return nil, err
+	}
+	deps := packageDependencies(packages, packageName)
+	if deps == nil {
+		return nil, fmt.Errorf("%w: %s is not a package in the %s index", ErrUnknownPackage, packageName, client.repoTypeName())
+	}
+
+	candidate, err := loadCandidateIndex(candidateIndexURL(apkRepo, hostArch()))
+	if err != nil {
+		return nil, err
+	}
+	return compareDependencies(packageName, client.repoTypeName(), deps, packages, candidate.Packages), nil
+}
+
+// packageDependencies returns the dependencies of the package packageName,
+// or else of the packages of the origin packageName, or nil if there is
+// neither
+func packageDependencies(packages []Package, packageName string) []string {
+	var named, fromOrigin []Package
+	for _, pkg := range packages {
+		switch {
+		case pkg.Name == packageName:
+			named = append(named, pkg)
+		case pkg.Origin == packageName:
+			fromOrigin = append(fromOrigin, pkg)
+		}
+	}
+	if len(named) == 0 {
+		named = fromOrigin
+	}
+	if len(named) == 0 {
+		return nil
+	}
+
+	seen := make(map[string]bool)
+	deps := []string{}
+	for _, pkg := range named {
+		for _, dep := range pkg.Dependencies {
+			if !seen[dep] {
+				seen[dep] = true
+				deps = append(deps, dep)
+			}
+		}
+	}
+	sort.Strings(deps)
+	return deps
+}
+
+// compareDependencies matches deps with the candidate packages providing
+// them, by name or by what they provide, and keeps those whose version
+// differs from the baseline's
+func compareDependencies(packageName, repoType string, deps []string, baseline []Package, candidate []apkIndexEntry) *DependencyChanges {
+	baselineVersions := make(map[string]string, len(baseline))
+	for _, pkg := range baseline {
+		baselineVersions[pkg.Name] = pkg.Version
+	}
+
+	// The newest candidate version providing each name
+	providers := make(map[string]apkIndexEntry)
+	for _, entry := range candidate {
+		for _, name := range append([]string{entry.Name}, entry.Provides...) {
+			current, ok := providers[name]
+			if !ok || compareAPKVersions(entry.Version, current.Version) > 0 {
+				providers[name] = entry
+			}
+		}
+	}
+
+	changes := &DependencyChanges{
+		Package:      packageName,
+		RepoType:     repoType,
+		Dependencies: deps,
+		Changed:      []ChangedDependency{},
+	}
+	for _, dep := range deps {
+		provider, ok := providers[dependencyName(dep)]
+		if !ok || baselineVersions[provider.Name] == provider.Version {
+			continue
+		}
+		changes.Changed = append(changes.Changed, ChangedDependency{
+			Dependency:       dep,
+			Provider:         provider.Name,
+			BaselineVersion:  baselineVersions[provider.Name],
+			CandidateVersion: provider.Version,
+		})
+	}
+	return changes
+}
+
+// WriteText writes the changed dependencies for the terminal
+func (d *DependencyChanges) WriteText(w io.Writer) {
+	fmt.Fprintf(w, "%s has %d dependencies in the %s index, %d changed in the candidate repository\n",
+		d.Package, len(d.Dependencies), d.RepoType, len(d.Changed))
+	for _, change := range d.Changed {
+		from := change.BaselineVersion
+		if from == "" {
+			from = "new"
+		}
+		fmt.Fprintf(w, "  - %s: %s %s -> %s\n", change.Dependency, change.Provider, from, change.CandidateVersion)
+	}
+}
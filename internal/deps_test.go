@@ -0,0 +1,64 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPackageDependencies(t *testing.T) {
+	packages := []Package{
+		{Name: "curl", Origin: "curl", Dependencies: []string{"so:libcurl.so.4", "so:libc.so.6"}},
+		{Name: "libcurl-openssl4", Origin: "curl", Dependencies: []string{"so:libssl.so.3", "so:libc.so.6"}},
+	}
+
+	tests := []struct {
+		name     string
+		expected []string
+	}{
+		{"libcurl-openssl4", []string{"so:libc.so.6", "so:libssl.so.3"}},
+		{"curl", []string{"so:libc.so.6", "so:libcurl.so.4"}},
+		{"wget", nil},
+	}
+
+	for _, tt := range tests {
+		if got := packageDependencies(packages, tt.name); !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("Expected %v for %s, got %v", tt.expected, tt.name, got)
+		}
+	}
+}
+
+func TestCompareDependencies(t *testing.T) {
+	baseline := []Package{
+		{Name: "libssl3", Version: "3.3.2-r0"},
+		{Name: "zlib", Version: "1.3.1-r4"},
+		{Name: "glibc", Version: "2.40-r1"},
+	}
+	candidate := []apkIndexEntry{
+		{Name: "libssl3", Version: "3.3.3-r0", Provides: []string{"so:libssl.so.3"}},
+		{Name: "libssl3", Version: "3.3.2-r1", Provides: []string{"so:libssl.so.3"}},
+		{Name: "zlib", Version: "1.3.1-r4", Provides: []string{"so:libz.so.1"}},
+		{Name: "brotli", Version: "1.1.0-r0"},
+	}
+
+	changes := compareDependencies("curl", "wolfi", []string{"brotli>1", "so:libc.so.6", "so:libssl.so.3", "so:libz.so.1"}, baseline, candidate)
+	expected := []ChangedDependency{
+		{Dependency: "brotli>1", Provider: "brotli", CandidateVersion: "1.1.0-r0"},
+		{Dependency: "so:libssl.so.3", Provider: "libssl3", BaselineVersion: "3.3.2-r0", CandidateVersion: "3.3.3-r0"},
+	}
+	if !reflect.DeepEqual(changes.Changed, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, changes.Changed)
+	}
+
+	var out bytes.Buffer
+	changes.WriteText(&out)
+	for _, line := range []string{"curl has 4 dependencies in the wolfi index, 2 changed", "brotli>1: brotli new -> 1.1.0-r0", "so:libssl.so.3: libssl3 3.3.2-r0 -> 3.3.3-r0"} {
+		if !strings.Contains(out.String(), line) {
+			t.Errorf("Expected %q in the output, got:\n%s", line, out.String())
+		}
+	}
+}
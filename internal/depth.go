@@ -0,0 +1,218 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ConsumerGraph is the reverse dependencies of a package up to a depth, by
+// distance: the direct consumers, the consumers of those, and so on
+type ConsumerGraph struct {
+	// Levels are the origins at each distance, nearest first
+	Levels [][]string
+	// Parents maps each transitive consumer to the consumers one level
+	// nearer that it depends on
+	Parents map[string][]string
+}
+
+// Packages returns the consumers nearest first, the order they are tested in
+func (g ConsumerGraph) Packages() []string {
+	var packages []string
+	for _, level := range g.Levels {
+		packages = append(packages, level...)
+	}
+	return packages
+}
+
+// children returns the consumers depending on each consumer one level nearer
+func (g ConsumerGraph) children() map[string][]string {
+	children := make(map[string][]string)
+	for pkg, parents := range g.Parents {
+		for _, parent := range parents {
+			children[parent] = append(children[parent], pkg)
+		}
+	}
+	for _, pkgs := range children {
+		sort.Strings(pkgs)
+	}
+	return children
+}
+
+// GetConsumerGraph returns the reverse dependencies of packageName up to
+// depth levels away. The first level is GetReverseDependencies; each further
+// one the origins depending on a package built by the level before, leaving
+// out origins already found nearer.
+func (a *ApkraneClient) GetConsumerGraph(packageName string, depth int) (ConsumerGraph, error) {
+	direct, err := a.GetReverseDependencies(packageName)
+	if err != nil {
+		return ConsumerGraph{}, err
+	}
+	graph := ConsumerGraph{Levels: [][]string{direct}, Parents: make(map[string][]string)}
+	if depth <= 1 || len(direct) == 0 {
+		return graph, nil
+	}
+
+	packages, err := a.loadIndex()
+	if err != nil {
+		return ConsumerGraph{}, err
+	}
+
+	seen := map[string]bool{packageName: true}
+	for _, origin := range direct {
+		seen[origin] = true
+	}
+
+	level := direct
+	for distance := 2; distance <= depth && len(level) > 0; distance++ {
+		// The packages built by the previous level, by the origin building
+		// them
+		inLevel := make(map[string]bool, len(level))
+		for _, origin := range level {
+			inLevel[origin] = true
+		}
+		builtBy := make(map[string]string)
+		for _, pkg := range packages {
+			if inLevel[pkg.Origin] {
+				builtBy[pkg.Name] = pkg.Origin
+			}
+		}
+
+		parents := make(map[string]map[string]bool)
+		for _, pkg := range packages {
+			if pkg.Origin == "" || seen[pkg.Origin] {
+				continue
+			}
+			for _, dep := range pkg.Dependencies {
+				parent, ok := builtBy[dependencyName(dep)]
+				if !ok {
+					continue
+				}
+				if parents[pkg.Origin] == nil {
+					parents[pkg.Origin] = make(map[string]bool)
+				}
+				parents[pkg.Origin][parent] = true
+			}
+		}
+
+		next := sortedKeys(parents)
+		for _, origin := range next {
+			seen[origin] = true
+			graph.Parents[origin] = sortedKeys(parents[origin])
+		}
+		if len(next) > 0 {
+			graph.Levels = append(graph.Levels, next)
+		}
+		level = next
+	}
+
+	if a.verbose {
+		fmt.Printf("Found %d reverse dependencies up to depth %d\n", len(graph.Packages()), depth)
+	}
+	return graph, nil
+}
+
+// dependencyName returns the package name of an index dependency, without a
+// version constraint. Conflicts ("!name") depend on nothing.
+func dependencyName(dep string) string {
+	if strings.HasPrefix(dep, "!") {
+		return ""
+	}
+	if i := strings.IndexAny(dep, "<>=~"); i >= 0 {
+		return dep[:i]
+	}
+	return dep
+}
+
+// SetDepth tests consumers up to depth levels away from the package: its
+// direct reverse dependencies with 1, their reverse dependencies as well
+// with 2, and so on. Nearer consumers are tested first.
+func (r *RegressionTestRunner) SetDepth(depth int) {
+	r.depth = depth
+}
+
+// SetPruneOnRegression stops testing the consumers of a consumer that
+// regresses, with a depth above 1, since the regression nearer to the package
+// is the signal and their tests mostly repeat it. Consumers that also depend
+// on one that didn't regress are tested still.
+func (r *RegressionTestRunner) SetPruneOnRegression(enabled bool) {
+	r.pruneOnRegression = enabled
+}
+
+// setConsumerGraph keeps the graph of the consumers under test for pruning
+func (r *RegressionTestRunner) setConsumerGraph(graph ConsumerGraph) {
+	r.consumerParents = graph.Parents
+	r.consumerChildren = graph.children()
+}
+
+// pruneConsumers cancels the queued consumers of pkg, which regressed, whose
+// every parent regressed or was pruned, and so on down the graph. Consumers
+// whose tests started already finish.
+func (r *RegressionTestRunner) pruneConsumers(pkg string, statuses map[string]string) {
+	if !r.pruneOnRegression || len(r.consumerChildren[pkg]) == 0 {
+		return
+	}
+
+	pruned := make(map[string]bool, len(r.pruned))
+	for _, p := range r.pruned {
+		pruned[p] = true
+	}
+	blocked := func(parent string) bool {
+		return statuses[parent] == StatusRegression || pruned[parent]
+	}
+
+	pending := []string{pkg}
+	for len(pending) > 0 {
+		parent := pending[0]
+		pending = pending[1:]
+		for _, child := range r.consumerChildren[parent] {
+			if pruned[child] {
+				continue
+			}
+			all := true
+			for _, p := range r.consumerParents[child] {
+				if !blocked(p) {
+					all = false
+					break
+				}
+			}
+			if !all {
+				continue
+			}
+			cancelled, err := r.Cancel([]string{child})
+			if err != nil || len(cancelled) == 0 {
+				continue
+			}
+			pruned[child] = true
+			r.pruned = append(r.pruned, child)
+			pending = append(pending, child)
+			r.printResult("✂️  %s: PRUNED (consumer of %s, which regressed)\n", child, pkg)
+		}
+	}
+}
+
+// levelCounts describes the number of consumers at each distance, e.g.
+// "12 direct, 40 at depth 2"
+func (g ConsumerGraph) levelCounts() string {
+	counts := make([]string, 0, len(g.Levels))
+	for i, level := range g.Levels {
+		if i == 0 {
+			counts = append(counts, fmt.Sprintf("%d direct", len(level)))
+			continue
+		}
+		counts = append(counts, fmt.Sprintf("%d at depth %d", len(level), i+1))
+	}
+	return strings.Join(counts, ", ")
+}
+
+// parentNote names the consumers a pruned consumer depends on, e.g.
+// " (depends on curl, git)"
+func parentNote(parents []string) string {
+	if len(parents) == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" (depends on %s)", strings.Join(parents, ", "))
+}
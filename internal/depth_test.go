@@ -0,0 +1,141 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// consumerIndex is openssl with two direct consumers, curl and wget, and
+// their consumers: git through libcurl, httpie through both
+var consumerIndex = []Package{
+	{Name: "openssl", Origin: "openssl"},
+	{Name: "curl", Origin: "curl", Dependencies: []string{"openssl"}},
+	{Name: "libcurl", Origin: "curl", Dependencies: []string{"openssl>=3"}},
+	{Name: "wget", Origin: "wget", Dependencies: []string{"openssl"}},
+	{Name: "git", Origin: "git", Dependencies: []string{"libcurl"}},
+	{Name: "httpie", Origin: "httpie", Dependencies: []string{"curl", "wget"}},
+	{Name: "git-lfs", Origin: "git-lfs", Dependencies: []string{"git", "!curl"}},
+}
+
+func TestGetConsumerGraph(t *testing.T) {
+	tests := []struct {
+		name            string
+		depth           int
+		expectedLevels  [][]string
+		expectedParents map[string][]string
+	}{
+		{"direct", 1, [][]string{{"curl", "wget"}}, map[string][]string{}},
+		{"depth 2", 2, [][]string{{"curl", "wget"}, {"git", "httpie"}}, map[string][]string{
+			"git":    {"curl"},
+			"httpie": {"curl", "wget"},
+		}},
+		{"depth 5", 5, [][]string{{"curl", "wget"}, {"git", "httpie"}, {"git-lfs"}}, map[string][]string{
+			"git":     {"curl"},
+			"httpie":  {"curl", "wget"},
+			"git-lfs": {"git"},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := NewApkraneClient(false, "wolfi")
+			client.packages = consumerIndex
+
+			graph, err := client.GetConsumerGraph("openssl", tt.depth)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(graph.Levels, tt.expectedLevels) {
+				t.Errorf("Expected levels %v, got %v", tt.expectedLevels, graph.Levels)
+			}
+			if !reflect.DeepEqual(graph.Parents, tt.expectedParents) {
+				t.Errorf("Expected parents %v, got %v", tt.expectedParents, graph.Parents)
+			}
+		})
+	}
+}
+
+func TestRunTestsNearestFirst(t *testing.T) {
+	repoPath := t.TempDir()
+	for _, pkg := range []string{"openssl", "curl", "wget", "git", "httpie", "git-lfs"} {
+		if err := os.WriteFile(filepath.Join(repoPath, pkg+".yaml"), []byte("package:\n  name: "+pkg+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	runner := NewRegressionTestRunner("openssl", "https://example.com/repo", repoPath, "wolfi", 1, false, time.Minute, false)
+	runner.setLogDir(t.TempDir())
+	runner.SetDepth(3)
+	runner.apkrane.packages = consumerIndex
+
+	var mu sync.Mutex
+	var tested []string
+	runner.SetExecutor(TestExecutorFunc(func(ctx context.Context, pkg string, opts ExecuteOptions) TestResult {
+		mu.Lock()
+		if pkg != "openssl" {
+			tested = append(tested, pkg)
+		}
+		mu.Unlock()
+		return newTestResult(pkg, opts.WithRepo, time.Now(), "", nil)
+	}))
+
+	if err := runner.Run(); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"curl", "wget", "git", "httpie", "git-lfs"}
+	if !reflect.DeepEqual(tested, expected) {
+		t.Errorf("Expected %v to be tested in order, got %v", expected, tested)
+	}
+}
+
+func TestPruneConsumers(t *testing.T) {
+	client := NewApkraneClient(false, "wolfi")
+	client.packages = consumerIndex
+	graph, err := client.GetConsumerGraph("openssl", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name           string
+		prune          bool
+		regressions    []string
+		expectedPruned []string
+	}{
+		{"disabled", false, []string{"curl"}, nil},
+		{"one parent regressed", true, []string{"curl"}, []string{"git", "git-lfs"}},
+		{"every parent regressed", true, []string{"curl", "wget"}, []string{"git", "git-lfs", "httpie"}},
+		{"no regression", true, nil, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			packages := graph.Packages()
+			runner := NewRegressionTestRunner("openssl", "https://example.com/repo", "/tmp/os", "wolfi", 1, false, time.Minute, false)
+			runner.SetPruneOnRegression(tt.prune)
+			runner.setConsumerGraph(graph)
+			runner.queue = newPackageQueue(packages[2:])
+			runner.manifest = &RunManifest{Packages: packages}
+			runner.eta = newETAEstimator(packages, nil, 1)
+
+			statuses := make(map[string]string)
+			for _, pkg := range tt.regressions {
+				statuses[pkg] = StatusRegression
+				runner.pruneConsumers(pkg, statuses)
+			}
+			if !reflect.DeepEqual(runner.pruned, tt.expectedPruned) {
+				t.Errorf("Expected %v to be pruned, got %v", tt.expectedPruned, runner.pruned)
+			}
+			if expected := len(packages) - len(tt.expectedPruned); len(runner.manifest.Packages) != expected {
+				t.Errorf("Expected %d packages left in the manifest, got %v", expected, runner.manifest.Packages)
+			}
+		})
+	}
+}
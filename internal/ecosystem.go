@@ -0,0 +1,203 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Language ecosystems reverse dependencies are classified by, so that the
+// summary shows whether regressions are specific to one, e.g. only the
+// Python bindings broke
+const (
+	EcosystemPython = "python"
+	EcosystemRuby   = "ruby"
+	EcosystemPerl   = "perl"
+	EcosystemRust   = "rust"
+	EcosystemGo     = "go"
+	EcosystemNode   = "node"
+	EcosystemJava   = "java"
+	// EcosystemOther is every package not built for one of the above,
+	// typically C and C++ packages built with make, autoconf or meson
+	EcosystemOther = "other"
+)
+
+// ecosystemPrefixes classify packages by name, e.g. py3-requests or
+// py3.12-requests
+var ecosystemPrefixes = []struct {
+	pattern   *regexp.Regexp
+	ecosystem string
+}{
+	{regexp.MustCompile(`^(py3|python3)(\.\d+)?-`), EcosystemPython},
+	{regexp.MustCompile(`^ruby(\d+\.\d+)?-`), EcosystemRuby},
+	{regexp.MustCompile(`^perl-`), EcosystemPerl},
+}
+
+// ecosystemPipelines classify packages by the melange pipelines building
+// them, by the namespace of the pipeline, e.g. go for go/build
+var ecosystemPipelines = map[string]string{
+	"python": EcosystemPython,
+	"py":     EcosystemPython,
+	"ruby":   EcosystemRuby,
+	"perl":   EcosystemPerl,
+	"cargo":  EcosystemRust,
+	"rust":   EcosystemRust,
+	"go":     EcosystemGo,
+	"npm":    EcosystemNode,
+	"maven":  EcosystemJava,
+}
+
+// classifyEcosystem returns the ecosystem of a package by its name or else
+// by the first pipeline of its config (which may be nil) that belongs to
+// one
+func classifyEcosystem(name string, config *MelangeConfig) string {
+	for _, prefix := range ecosystemPrefixes {
+		if prefix.pattern.MatchString(name) {
+			return prefix.ecosystem
+		}
+	}
+	if config == nil {
+		return EcosystemOther
+	}
+
+	steps := config.Pipeline
+	for _, sub := range config.Subpackages {
+		steps = append(steps, sub.Pipeline...)
+	}
+	if ecosystem := pipelineEcosystem(steps); ecosystem != "" {
+		return ecosystem
+	}
+	return EcosystemOther
+}
+
+// pipelineEcosystem returns the ecosystem of the first step of steps, or of
+// the steps they nest, that uses a pipeline of one
+func pipelineEcosystem(steps []pipelineStep) string {
+	for _, step := range steps {
+		namespace, _, _ := strings.Cut(step.Uses, "/")
+		if ecosystem, ok := ecosystemPipelines[namespace]; ok {
+			return ecosystem
+		}
+		if ecosystem := pipelineEcosystem(step.Pipeline); ecosystem != "" {
+			return ecosystem
+		}
+	}
+	return ""
+}
+
+// packageEcosystem classifies a package, using its config in the package
+// repository if there is one
+func (r *RegressionTestRunner) packageEcosystem(pkg string) string {
+	var config *MelangeConfig
+	if r.melange != nil {
+		if path, err := r.melange.locator.Locate(pkg); err == nil {
+			config, _ = LoadMelangeConfig(path)
+		}
+	}
+	return classifyEcosystem(pkg, config)
+}
+
+// ecosystemStats summarizes the tested reverse dependencies of one ecosystem
+type ecosystemStats struct {
+	Ecosystem   string `json:"ecosystem"`
+	Tested      int    `json:"tested"`
+	Failed      int    `json:"failed"`
+	Regressions int    `json:"regressions"`
+	Hung        int    `json:"hung"`
+}
+
+// ecosystemBreakdown counts the outcomes of the tested packages by
+// ecosystem, those with the most regressions first. It is empty unless the
+// packages span more than one ecosystem.
+func (r *RegressionTestRunner) ecosystemBreakdown(statuses map[string]string) []ecosystemStats {
+	stats := make(map[string]*ecosystemStats)
+	for _, pkg := range sortedKeys(statuses) {
+		status := statuses[pkg]
+		switch status {
+		case StatusPass, StatusFail, StatusRegression, StatusHung:
+		default:
+			continue
+		}
+		ecosystem := r.packageEcosystem(pkg)
+		s, ok := stats[ecosystem]
+		if !ok {
+			s = &ecosystemStats{Ecosystem: ecosystem}
+			stats[ecosystem] = s
+		}
+		s.Tested++
+		switch status {
+		case StatusFail:
+			s.Failed++
+		case StatusRegression:
+			s.Regressions++
+		case StatusHung:
+			s.Hung++
+		}
+	}
+	if len(stats) < 2 {
+		return nil
+	}
+
+	breakdown := make([]ecosystemStats, 0, len(stats))
+	for _, s := range stats {
+		breakdown = append(breakdown, *s)
+	}
+	sort.Slice(breakdown, func(i, j int) bool {
+		if breakdown[i].Regressions != breakdown[j].Regressions {
+			return breakdown[i].Regressions > breakdown[j].Regressions
+		}
+		return breakdown[i].Ecosystem < breakdown[j].Ecosystem
+	})
+	return breakdown
+}
+
+// regressedEcosystem returns the ecosystem every regression is in, if there
+// are regressions and they are all in one ecosystem of several
+func regressedEcosystem(breakdown []ecosystemStats) string {
+	var ecosystem string
+	for _, s := range breakdown {
+		if s.Regressions == 0 {
+			continue
+		}
+		if ecosystem != "" {
+			return ""
+		}
+		ecosystem = s.Ecosystem
+	}
+	return ecosystem
+}
+
+// printEcosystems lists the outcomes by ecosystem in the text summary
+func printEcosystems(w io.Writer, breakdown []ecosystemStats) {
+	if len(breakdown) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\nResults by ecosystem:\n")
+	for _, s := range breakdown {
+		fmt.Fprintf(w, "  - %s: %d tested, %d regressions, %d failed, %d hung\n", s.Ecosystem, s.Tested, s.Regressions, s.Failed, s.Hung)
+	}
+	if ecosystem := regressedEcosystem(breakdown); ecosystem != "" {
+		fmt.Fprintf(w, "  Every regression is in a %s package\n", ecosystem)
+	}
+}
+
+// printEcosystemsMarkdown is printEcosystems for the markdown summary
+func printEcosystemsMarkdown(w io.Writer, breakdown []ecosystemStats) {
+	if len(breakdown) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\n### 🧩 Results by Ecosystem\n\n")
+	fmt.Fprintf(w, "| Ecosystem | Tested | Regressions | Failed | Hung |\n")
+	fmt.Fprintf(w, "|-----------|--------|-------------|--------|------|\n")
+	for _, s := range breakdown {
+		fmt.Fprintf(w, "| %s | %d | %d | %d | %d |\n", s.Ecosystem, s.Tested, s.Regressions, s.Failed, s.Hung)
+	}
+	if ecosystem := regressedEcosystem(breakdown); ecosystem != "" {
+		fmt.Fprintf(w, "\nEvery regression is in a %s package.\n", ecosystem)
+	}
+}
@@ -0,0 +1,91 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClassifyEcosystem(t *testing.T) {
+	tests := []struct {
+		name     string
+		pkg      string
+		config   string
+		expected string
+	}{
+		{"python by name", "py3-requests", "", EcosystemPython},
+		{"versioned python by name", "py3.12-cryptography", "", EcosystemPython},
+		{"ruby by name", "ruby3.2-nokogiri", "", EcosystemRuby},
+		{"perl by name", "perl-net-ssleay", "", EcosystemPerl},
+		{"go pipeline", "cosign", "pipeline:\n  - uses: git-checkout\n  - uses: go/build\n", EcosystemGo},
+		{"rust pipeline", "ripgrep", "pipeline:\n  - uses: cargo/build\n", EcosystemRust},
+		{"nested pipeline", "node-app", "pipeline:\n  - pipeline:\n      - uses: npm/install\n", EcosystemNode},
+		{"subpackage pipeline", "bindings", "subpackages:\n  - name: bindings-py\n    pipeline:\n      - uses: py/pip-build-install\n", EcosystemPython},
+		{"autoconf", "curl", "pipeline:\n  - uses: autoconf/configure\n  - uses: autoconf/make\n", EcosystemOther},
+		{"no config", "curl", "", EcosystemOther},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var config *MelangeConfig
+			if tt.config != "" {
+				path := filepath.Join(t.TempDir(), tt.pkg+".yaml")
+				if err := os.WriteFile(path, []byte("package:\n  name: "+tt.pkg+"\n"+tt.config), 0644); err != nil {
+					t.Fatal(err)
+				}
+				var err error
+				if config, err = LoadMelangeConfig(path); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := classifyEcosystem(tt.pkg, config); got != tt.expected {
+				t.Errorf("Expected %s, got %s", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestEcosystemBreakdown(t *testing.T) {
+	repoPath := t.TempDir()
+	if err := os.WriteFile(filepath.Join(repoPath, "cosign.yaml"), []byte("package:\n  name: cosign\npipeline:\n  - uses: go/build\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	runner := NewRegressionTestRunner("openssl", "https://example.com/repo", repoPath, "wolfi", 1, false, time.Minute, false)
+
+	statuses := map[string]string{
+		"py3-cryptography": StatusRegression,
+		"py3-pyopenssl":    StatusRegression,
+		"cosign":           StatusPass,
+		"curl":             StatusFail,
+		"git":              StatusPass,
+		"wget":             StatusSkipped,
+	}
+	breakdown := runner.ecosystemBreakdown(statuses)
+	expected := []ecosystemStats{
+		{Ecosystem: EcosystemPython, Tested: 2, Regressions: 2},
+		{Ecosystem: EcosystemGo, Tested: 1},
+		{Ecosystem: EcosystemOther, Tested: 2, Failed: 1},
+	}
+	if !reflect.DeepEqual(breakdown, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, breakdown)
+	}
+
+	var buf bytes.Buffer
+	printEcosystemsMarkdown(&buf, breakdown)
+	for _, want := range []string{"| python | 2 | 2 | 0 | 0 |", "Every regression is in a python package."} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("Expected %q in the markdown summary, got %q", want, buf.String())
+		}
+	}
+
+	if got := runner.ecosystemBreakdown(map[string]string{"curl": StatusPass, "git": StatusRegression}); got != nil {
+		t.Errorf("Expected no breakdown for a single ecosystem, got %+v", got)
+	}
+}
@@ -0,0 +1,81 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os/exec"
+	"strings"
+)
+
+// Errors returned by the clients, wrapped with details, so that callers can
+// tell the common causes of failed runs apart with errors.Is
+var (
+	// ErrAuth means no credentials could be obtained for a repository, or
+	// the repository rejected them
+	ErrAuth = errors.New("authentication failed")
+	// ErrIndexFetch means a package index couldn't be fetched or read
+	ErrIndexFetch = errors.New("failed to fetch package index")
+	// ErrIndexSignature means a package index isn't signed with one of the
+	// trusted keys
+	ErrIndexSignature = errors.New("index signature verification failed")
+	// ErrRepoUnreachable means a repository host couldn't be connected to
+	ErrRepoUnreachable = errors.New("repository unreachable")
+	// ErrMakeMissing and ErrMelangeMissing mean the tools package tests are
+	// run with aren't installed
+	ErrMakeMissing    = errors.New("make not found")
+	ErrMelangeMissing = errors.New("melange not found")
+	// ErrRepoLocked means another run holds the lock on a package
+	// repository (see LockRepoPath)
+	ErrRepoLocked = errors.New("repository path is locked")
+)
+
+// unreachableMarkers and authMarkers are what apkrane and Go's HTTP client
+// report for connection and authentication failures
+var (
+	unreachableMarkers = []string{"no such host", "connection refused", "network is unreachable", "i/o timeout", "tls handshake timeout", "dial tcp"}
+	authMarkers        = []string{"unauthorized", "forbidden", "status code 401", "status code 403"}
+)
+
+// fetchError wraps err, the failure to fetch an index, with ErrAuth,
+// ErrRepoUnreachable or else ErrIndexFetch, judging by the error and the
+// stderr of the command that failed
+func fetchError(err error) error {
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return fmt.Errorf("%w: %w", ErrRepoUnreachable, err)
+	}
+
+	text := err.Error()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		text += " " + string(exitErr.Stderr)
+	}
+	text = strings.ToLower(text)
+	for _, marker := range unreachableMarkers {
+		if strings.Contains(text, marker) {
+			return fmt.Errorf("%w: %w", ErrRepoUnreachable, commandError(err))
+		}
+	}
+	for _, marker := range authMarkers {
+		if strings.Contains(text, marker) {
+			return fmt.Errorf("%w: %w", ErrAuth, commandError(err))
+		}
+	}
+	return fmt.Errorf("%w: %w", ErrIndexFetch, commandError(err))
+}
+
+// CheckMelangeTools makes sure make and melange, which package tests are
+// run with, are installed
+func CheckMelangeTools() error {
+	if _, err := exec.LookPath("make"); err != nil {
+		return fmt.Errorf("%w: package tests run make in the package repository; install make", ErrMakeMissing)
+	}
+	if _, err := exec.LookPath("melange"); err != nil {
+		return fmt.Errorf("%w: package tests run melange; install melange", ErrMelangeMissing)
+	}
+	return nil
+}
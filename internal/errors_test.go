@@ -0,0 +1,77 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFetchError(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected error
+	}{
+		{
+			name:     "dns failure",
+			err:      &net.DNSError{Err: "no such host", Name: "apk.cgr.dev"},
+			expected: ErrRepoUnreachable,
+		},
+		{
+			name:     "connection refused",
+			err:      fmt.Errorf("Get \"https://apk.cgr.dev/x\": dial tcp 127.0.0.1:443: connect: connection refused"),
+			expected: ErrRepoUnreachable,
+		},
+		{
+			name:     "unauthorized",
+			err:      fmt.Errorf("GET https://apk.cgr.dev/x/APKINDEX.tar.gz: 401 Unauthorized"),
+			expected: ErrAuth,
+		},
+		{
+			name:     "other failure",
+			err:      fmt.Errorf("unexpected EOF"),
+			expected: ErrIndexFetch,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := fetchError(tt.err)
+			if !errors.Is(err, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, err)
+			}
+			if !errors.Is(err, tt.err) {
+				t.Errorf("Expected the original error to be wrapped, got %v", err)
+			}
+		})
+	}
+}
+
+func TestCheckMelangeTools(t *testing.T) {
+	bin := t.TempDir()
+	t.Setenv("PATH", bin)
+
+	install := func(name string) {
+		if err := os.WriteFile(filepath.Join(bin, name), []byte("#!/bin/sh\n"), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := CheckMelangeTools(); !errors.Is(err, ErrMakeMissing) {
+		t.Errorf("Expected ErrMakeMissing, got %v", err)
+	}
+	install("make")
+	if err := CheckMelangeTools(); !errors.Is(err, ErrMelangeMissing) {
+		t.Errorf("Expected ErrMelangeMissing, got %v", err)
+	}
+	install("melange")
+	if err := CheckMelangeTools(); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+}
@@ -0,0 +1,136 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"sync"
+	"time"
+)
+
+// etaEstimator predicts the remaining run time from per-package duration
+// estimates instead of a global average, so that a few huge packages still
+// waiting in the queue are reflected in the ETA.
+type etaEstimator struct {
+	mu          sync.Mutex
+	concurrency int
+	history     map[string]time.Duration
+	queued      map[string]bool
+	running     map[string]time.Time
+	doneTotal   time.Duration
+	doneCount   int
+}
+
+func newETAEstimator(packages []string, history map[string]time.Duration, concurrency int) *etaEstimator {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	queued := make(map[string]bool, len(packages))
+	for _, pkg := range packages {
+		queued[pkg] = true
+	}
+
+	return &etaEstimator{
+		concurrency: concurrency,
+		history:     history,
+		queued:      queued,
+		running:     make(map[string]time.Time),
+	}
+}
+
+// queue adds a package added to the run after it started
+func (e *etaEstimator) queue(pkg string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.queued[pkg] = true
+}
+
+// cancel removes a queued package that won't be tested
+func (e *etaEstimator) cancel(pkg string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	delete(e.queued, pkg)
+}
+
+// requeue moves a started package back to the queue, to be tested again
+func (e *etaEstimator) requeue(pkg string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	delete(e.running, pkg)
+	e.queued[pkg] = true
+}
+
+func (e *etaEstimator) started(pkg string, at time.Time) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	delete(e.queued, pkg)
+	e.running[pkg] = at
+}
+
+func (e *etaEstimator) finished(pkg string, duration time.Duration) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	delete(e.queued, pkg)
+	delete(e.running, pkg)
+	e.doneTotal += duration
+	e.doneCount++
+}
+
+// expected returns the expected duration of pkg: its historical average if
+// known, otherwise the average of packages completed so far in this run, or
+// of all historical packages before any has completed.
+func (e *etaEstimator) expected(pkg string) time.Duration {
+	if d, ok := e.history[pkg]; ok {
+		return d
+	}
+	if e.doneCount > 0 {
+		return e.doneTotal / time.Duration(e.doneCount)
+	}
+	if len(e.history) > 0 {
+		var total time.Duration
+		for _, d := range e.history {
+			total += d
+		}
+		return total / time.Duration(len(e.history))
+	}
+	return 0
+}
+
+// estimate returns the expected time until all packages are done. The
+// outstanding work is spread across the worker slots, but the result is never
+// shorter than the longest single package still to finish.
+func (e *etaEstimator) estimate(now time.Time) time.Duration {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var work, longest time.Duration
+	for pkg, startedAt := range e.running {
+		remaining := e.expected(pkg) - now.Sub(startedAt)
+		if remaining < 0 {
+			remaining = 0
+		}
+		work += remaining
+		if remaining > longest {
+			longest = remaining
+		}
+	}
+	for pkg := range e.queued {
+		d := e.expected(pkg)
+		work += d
+		if d > longest {
+			longest = d
+		}
+	}
+
+	eta := work / time.Duration(e.concurrency)
+	if longest > eta {
+		eta = longest
+	}
+	return eta
+}
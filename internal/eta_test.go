@@ -0,0 +1,59 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"testing"
+	"time"
+)
+
+func TestETAEstimatorUsesHistory(t *testing.T) {
+	history := map[string]time.Duration{
+		"llvm":  4 * time.Hour,
+		"curl":  time.Minute,
+		"nginx": time.Minute,
+	}
+	estimator := newETAEstimator([]string{"llvm", "curl", "nginx"}, history, 4)
+
+	// The huge package dominates even though the work is spread over 4 workers
+	if eta := estimator.estimate(time.Now()); eta != 4*time.Hour {
+		t.Errorf("Expected ETA of 4h, got %v", eta)
+	}
+
+	estimator.started("llvm", time.Now().Add(-time.Hour))
+	estimator.finished("curl", time.Minute)
+	estimator.finished("nginx", time.Minute)
+
+	eta := estimator.estimate(time.Now())
+	if eta < 2*time.Hour+59*time.Minute || eta > 3*time.Hour {
+		t.Errorf("Expected ETA of about 3h, got %v", eta)
+	}
+}
+
+func TestETAEstimatorWithoutHistory(t *testing.T) {
+	estimator := newETAEstimator([]string{"a", "b", "c", "d"}, nil, 2)
+
+	if eta := estimator.estimate(time.Now()); eta != 0 {
+		t.Errorf("Expected no ETA before any package completes, got %v", eta)
+	}
+
+	estimator.finished("a", 10*time.Minute)
+	estimator.finished("b", 20*time.Minute)
+
+	// Two packages left at the observed 15m average on two workers
+	if eta := estimator.estimate(time.Now()); eta != 15*time.Minute {
+		t.Errorf("Expected ETA of 15m, got %v", eta)
+	}
+}
+
+func TestETAEstimatorOverrunningPackage(t *testing.T) {
+	history := map[string]time.Duration{"slow": time.Minute}
+	estimator := newETAEstimator([]string{"slow"}, history, 1)
+
+	estimator.started("slow", time.Now().Add(-time.Hour))
+
+	if eta := estimator.estimate(time.Now()); eta != 0 {
+		t.Errorf("Expected ETA of 0 for a package past its estimate, got %v", eta)
+	}
+}
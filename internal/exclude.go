@@ -0,0 +1,99 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// IgnoreFile is the file in a package repository listing packages, by name
+// or glob, that are never tested from it, one per line. A # starts a
+// comment, e.g. the reason the package is ignored.
+const IgnoreFile = ".apkregressignore"
+
+// SetExclude leaves the packages matching patterns, package names or globs
+// such as llvm-*, out of runs, e.g. packages a team knows to be broken
+func (r *RegressionTestRunner) SetExclude(patterns []string) error {
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+	}
+	r.exclude = patterns
+	return nil
+}
+
+// LoadIgnoreFile adds the patterns of the package repository's IgnoreFile,
+// if it has one, to those of SetExclude, and returns them
+func (r *RegressionTestRunner) LoadIgnoreFile() ([]string, error) {
+	patterns, err := readIgnoreFile(filepath.Join(r.repoPath, IgnoreFile))
+	if err != nil {
+		return nil, err
+	}
+	r.exclude = append(r.exclude, patterns...)
+	return patterns, nil
+}
+
+// readIgnoreFile reads the patterns of an ignore file, or none if there is
+// no such file
+func readIgnoreFile(filename string) ([]string, error) {
+	file, err := os.Open(filename)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", filename, err)
+	}
+	defer file.Close()
+
+	var patterns []string
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		pattern, _, _ := strings.Cut(scanner.Text(), "#")
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("%s:%d: invalid pattern %q: %w", filename, line, pattern, err)
+		}
+		patterns = append(patterns, pattern)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", filename, err)
+	}
+	return patterns, nil
+}
+
+// excludePackages returns the packages that don't match an exclusion, and
+// those that do
+func (r *RegressionTestRunner) excludePackages(packages []string) ([]string, []string) {
+	if len(r.exclude) == 0 {
+		return packages, nil
+	}
+
+	var kept, excluded []string
+	for _, pkg := range packages {
+		if r.excluded(pkg) {
+			excluded = append(excluded, pkg)
+			continue
+		}
+		kept = append(kept, pkg)
+	}
+	return kept, excluded
+}
+
+func (r *RegressionTestRunner) excluded(pkg string) bool {
+	for _, pattern := range r.exclude {
+		if ok, _ := path.Match(pattern, pkg); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,119 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExcludePackages(t *testing.T) {
+	packages := []string{"curl", "gcc", "llvm-16", "llvm-17", "git"}
+	tests := []struct {
+		name             string
+		patterns         []string
+		expectedKept     []string
+		expectedExcluded []string
+	}{
+		{"none", nil, packages, nil},
+		{"name", []string{"gcc"}, []string{"curl", "llvm-16", "llvm-17", "git"}, []string{"gcc"}},
+		{"glob", []string{"llvm-*", "git"}, []string{"curl", "gcc"}, []string{"llvm-16", "llvm-17", "git"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runner := NewRegressionTestRunner("", "https://example.com/repo", t.TempDir(), "wolfi", 1, false, time.Minute, false)
+			if err := runner.SetExclude(tt.patterns); err != nil {
+				t.Fatal(err)
+			}
+			kept, excluded := runner.excludePackages(packages)
+			if !reflect.DeepEqual(kept, tt.expectedKept) {
+				t.Errorf("Expected to keep %v, got %v", tt.expectedKept, kept)
+			}
+			if !reflect.DeepEqual(excluded, tt.expectedExcluded) {
+				t.Errorf("Expected to exclude %v, got %v", tt.expectedExcluded, excluded)
+			}
+		})
+	}
+
+	runner := NewRegressionTestRunner("", "https://example.com/repo", t.TempDir(), "wolfi", 1, false, time.Minute, false)
+	if err := runner.SetExclude([]string{"llvm-["}); err == nil {
+		t.Error("Expected an invalid pattern to be rejected")
+	}
+}
+
+func TestRunnerExclude(t *testing.T) {
+	repoPath := t.TempDir()
+	packages := []string{"curl", "gcc"}
+	for _, pkg := range packages {
+		if err := os.WriteFile(filepath.Join(repoPath, pkg+".yaml"), []byte("package:\n  name: "+pkg+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	runner := NewRegressionTestRunner("", "https://example.com/repo", repoPath, "wolfi", 2, false, time.Minute, false)
+	runner.setLogDir(t.TempDir())
+	runner.SetExclude([]string{"gcc"})
+	runner.SetExecutor(TestExecutorFunc(func(ctx context.Context, pkg string, opts ExecuteOptions) TestResult {
+		if pkg == "gcc" {
+			t.Errorf("Expected gcc not to be tested")
+		}
+		return newTestResult(pkg, opts.WithRepo, time.Now(), "", nil)
+	}))
+
+	if err := runner.RunFromPackageList(packages); err != nil {
+		t.Fatal(err)
+	}
+	if runner.summary.Tested != 1 {
+		t.Errorf("Expected 1 tested package, got %d", runner.summary.Tested)
+	}
+}
+
+func TestLoadIgnoreFile(t *testing.T) {
+	tests := []struct {
+		name          string
+		content       string
+		expected      []string
+		expectedError string
+	}{
+		{"missing", "", nil, ""},
+		{"patterns", "# known broken\nllvm-*   # too slow\n\n  gcc-6\n", []string{"llvm-*", "gcc-6"}, ""},
+		{"invalid", "curl\nllvm-[\n", nil, ".apkregressignore:2: invalid pattern"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repoPath := t.TempDir()
+			if tt.content != "" {
+				if err := os.WriteFile(filepath.Join(repoPath, IgnoreFile), []byte(tt.content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			runner := NewRegressionTestRunner("", "https://example.com/repo", repoPath, "wolfi", 1, false, time.Minute, false)
+			runner.SetExclude([]string{"git"})
+			patterns, err := runner.LoadIgnoreFile()
+			if tt.expectedError != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.expectedError) {
+					t.Errorf("Expected an error containing %q, got %v", tt.expectedError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(patterns, tt.expected) {
+				t.Errorf("Expected patterns %v, got %v", tt.expected, patterns)
+			}
+			if expected := append([]string{"git"}, tt.expected...); !reflect.DeepEqual(runner.exclude, expected) {
+				t.Errorf("Expected exclusions %v, got %v", expected, runner.exclude)
+			}
+		})
+	}
+}
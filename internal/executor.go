@@ -0,0 +1,56 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"context"
+	"time"
+)
+
+// ExecuteOptions configure a single test run
+type ExecuteOptions struct {
+	// WithRepo appends APKRepo to the repositories the test resolves
+	// packages from
+	WithRepo bool
+	APKRepo  string
+	// Timeout after which the test is considered hung; zero selects the
+	// executor's default
+	Timeout time.Duration
+	// WorkDir is a scratch directory for temporary files, shared by the tests
+	// a worker runs and emptied before each of them. Executors create their
+	// own temporary directory when it is unset.
+	WorkDir string
+	// WorkspaceDir, when set, is where the test's workspace is kept after it
+	// finishes, for inspecting a failure. Executors that can't keep the
+	// workspace leave it empty.
+	WorkspaceDir string
+	// Smoke only resolves and installs the test environment, skipping the
+	// test pipelines, for a quick first pass (see SetTwoPhase). Executors
+	// that can't skip the pipelines run the full test.
+	Smoke bool
+	// Previous, when set, is a checkout of the package repository at the
+	// package's previous version, whose config is tested instead of the
+	// current one (see SetTestPrevious). Executors that can't test another
+	// checkout test the current config.
+	Previous string
+}
+
+// TestExecutor runs the test of a single package. MelangeClient, which runs
+// tests locally through the package repository's Makefile, is the default;
+// other backends (containers, remote builders, Kubernetes jobs) implement
+// this interface and are installed with RegressionTestRunner.SetExecutor.
+//
+// Execute must not return before the test has stopped. A cancelled ctx means
+// the test should be aborted.
+type TestExecutor interface {
+	Execute(ctx context.Context, packageName string, opts ExecuteOptions) TestResult
+}
+
+// TestExecutorFunc adapts a function to the TestExecutor interface
+type TestExecutorFunc func(ctx context.Context, packageName string, opts ExecuteOptions) TestResult
+
+// Execute calls f
+func (f TestExecutorFunc) Execute(ctx context.Context, packageName string, opts ExecuteOptions) TestResult {
+	return f(ctx, packageName, opts)
+}
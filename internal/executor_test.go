@@ -0,0 +1,187 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+var _ TestExecutor = (*MelangeClient)(nil)
+
+func TestRunnerUsesExecutor(t *testing.T) {
+	runner := NewRegressionTestRunnerFromPackageList([]string{"good", "regressed", "broken"}, "https://example.com/repo", "/tmp", "wolfi", 2, true, time.Minute, false)
+	runner.setLogDir(t.TempDir())
+	runner.SetPackageOptions(map[string]PackageOptions{"broken": {Timeout: 5 * time.Minute}})
+
+	var mu sync.Mutex
+	calls := make(map[string][]ExecuteOptions)
+	runner.SetExecutor(TestExecutorFunc(func(ctx context.Context, pkg string, opts ExecuteOptions) TestResult {
+		mu.Lock()
+		calls[pkg] = append(calls[pkg], opts)
+		mu.Unlock()
+
+		var err error
+		switch {
+		case pkg == "regressed" && opts.WithRepo, pkg == "broken":
+			err = errors.New("test failed")
+		}
+		return newTestResult(pkg, opts.WithRepo, time.Now(), "", err)
+	}))
+
+	err := runner.RunFromPackageList([]string{"good", "regressed", "broken"})
+	if err == nil || err.Error() != "found 1 regressions" {
+		t.Errorf("Expected 1 regression, got: %v", err)
+	}
+
+	if len(calls["good"]) != 1 || !calls["good"][0].WithRepo {
+		t.Errorf("Expected a single with-repo run for good, got %+v", calls["good"])
+	}
+	if len(calls["regressed"]) != 2 || calls["regressed"][1].WithRepo {
+		t.Errorf("Expected a with-repo and a without-repo run for regressed, got %+v", calls["regressed"])
+	}
+	if calls["regressed"][0].APKRepo != "https://example.com/repo" || calls["regressed"][0].Timeout != time.Minute {
+		t.Errorf("Unexpected options: %+v", calls["regressed"][0])
+	}
+	if calls["broken"][0].Timeout != 5*time.Minute {
+		t.Errorf("Expected per-package timeout to be passed to the executor, got %v", calls["broken"][0].Timeout)
+	}
+
+	if result := runner.packageResults["regressed"][true]; result.RepoType != "wolfi" {
+		t.Errorf("Expected results to be tagged with the repository type, got %q", result.RepoType)
+	}
+}
+
+func TestWaitWithTimeoutCancelled(t *testing.T) {
+	cmd := exec.Command("sleep", "60")
+	if err := startInProcessGroup(cmd); err != nil {
+		t.Fatalf("Failed to start command: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	start := time.Now()
+	err := waitWithTimeout(ctx, cmd, time.Hour)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+	if time.Since(start) > 10*time.Second {
+		t.Error("Expected the command to stop promptly")
+	}
+}
+
+func TestRunnerWorkerPool(t *testing.T) {
+	packages := []string{"a", "b", "c", "d", "e"}
+	runner := NewRegressionTestRunnerFromPackageList(packages, "https://example.com/repo", "/tmp", "wolfi", 2, true, time.Minute, false)
+	runner.setLogDir(t.TempDir())
+
+	var mu sync.Mutex
+	workDirs := make(map[string]bool)
+	runner.SetExecutor(TestExecutorFunc(func(ctx context.Context, pkg string, opts ExecuteOptions) TestResult {
+		entries, err := os.ReadDir(opts.WorkDir)
+		if err != nil {
+			t.Errorf("Expected a scratch directory for %s: %v", pkg, err)
+		} else if len(entries) != 0 {
+			t.Errorf("Expected an empty scratch directory for %s, found %d entries", pkg, len(entries))
+		}
+		// Leave something behind for the next test to not see
+		os.WriteFile(filepath.Join(opts.WorkDir, "leftover"), []byte(pkg), 0644)
+
+		mu.Lock()
+		workDirs[opts.WorkDir] = true
+		mu.Unlock()
+
+		// Fail with the repository so every package runs twice
+		var testErr error
+		if opts.WithRepo {
+			testErr = errors.New("test failed")
+		}
+		return newTestResult(pkg, opts.WithRepo, time.Now(), "", testErr)
+	}))
+
+	runner.RunFromPackageList(packages)
+
+	if len(workDirs) == 0 || len(workDirs) > 2 {
+		t.Errorf("Expected at most 2 scratch directories, got %d", len(workDirs))
+	}
+	for dir := range workDirs {
+		if _, err := os.Stat(dir); !os.IsNotExist(err) {
+			t.Errorf("Expected scratch directory %s to be removed", dir)
+		}
+	}
+	if len(runner.packageResults) != len(packages) {
+		t.Errorf("Expected results for %d packages, got %d", len(packages), len(runner.packageResults))
+	}
+}
+
+func TestRunnerWorkerPoolOrder(t *testing.T) {
+	packages := []string{"first", "second", "third"}
+	runner := NewRegressionTestRunnerFromPackageList(packages, "https://example.com/repo", "/tmp", "wolfi", 1, true, time.Minute, false)
+	runner.setLogDir(t.TempDir())
+
+	var order []string
+	runner.SetExecutor(TestExecutorFunc(func(ctx context.Context, pkg string, opts ExecuteOptions) TestResult {
+		order = append(order, pkg)
+		return newTestResult(pkg, opts.WithRepo, time.Now(), "", nil)
+	}))
+
+	runner.RunFromPackageList(packages)
+
+	if !reflect.DeepEqual(order, packages) {
+		t.Errorf("Expected packages to be tested in order %v, got %v", packages, order)
+	}
+}
+
+func TestRunnerSnapshotsRegressionWorkspace(t *testing.T) {
+	logDir := t.TempDir()
+	runner := NewRegressionTestRunnerFromPackageList([]string{"regressed", "broken"}, "https://example.com/repo", "/tmp", "wolfi", 1, true, time.Minute, false)
+	runner.setLogDir(logDir)
+	runner.SetWorkspaceSnapshots(true)
+
+	var calls []ExecuteOptions
+	runner.SetExecutor(TestExecutorFunc(func(ctx context.Context, pkg string, opts ExecuteOptions) TestResult {
+		if pkg == "regressed" {
+			calls = append(calls, opts)
+		}
+		if opts.WorkspaceDir != "" {
+			os.WriteFile(filepath.Join(opts.WorkspaceDir, "build.log"), []byte("build"), 0644)
+		}
+
+		var err error
+		if opts.WithRepo || pkg == "broken" {
+			err = errors.New("test failed")
+		}
+		return newTestResult(pkg, opts.WithRepo, time.Now(), "", err)
+	}))
+
+	if err := runner.RunFromPackageList([]string{"regressed", "broken"}); err == nil {
+		t.Fatalf("Expected a regression")
+	}
+
+	if len(calls) != 3 || !calls[2].WithRepo || calls[2].WorkspaceDir == "" {
+		t.Fatalf("Expected a with-repo re-run keeping the workspace, got %+v", calls)
+	}
+	if calls[0].WorkspaceDir != "" || calls[1].WorkspaceDir != "" {
+		t.Errorf("Expected only the re-run to keep the workspace, got %+v", calls)
+	}
+
+	expected := filepath.Join(logDir, "regressed_workspace.tar.gz")
+	if got := runner.packageResults["regressed"][true].WorkspaceSnapshot; got != expected {
+		t.Errorf("Expected workspace snapshot %s, got %q", expected, got)
+	}
+	if _, err := os.Stat(expected); err != nil {
+		t.Errorf("Expected workspace snapshot to be written: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(logDir, "broken_workspace.tar.gz")); err == nil {
+		t.Errorf("Expected no snapshot for a package failing without the repository")
+	}
+}
@@ -0,0 +1,299 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ErrUnknownFailureBudget indicates that the config file has no failure
+// budget of the name given
+var ErrUnknownFailureBudget = errors.New("unknown failure budget")
+
+// The exit statuses apkregress reports other outcomes than regressions with,
+// e.g. runs that found nothing to test or failed before testing, which budget
+// rules can't use so that CI can tell a breached budget from them
+const (
+	ReservedExitCodeMin = 3
+	ReservedExitCodeMax = 13
+)
+
+// ecosystems are the ecosystems budget rules can select
+var ecosystems = []string{EcosystemPython, EcosystemRuby, EcosystemPerl, EcosystemRust, EcosystemGo, EcosystemNode, EcosystemJava, EcosystemOther}
+
+// BudgetRule allows the packages of an ecosystem or an owner a number of
+// regressions before the run fails
+type BudgetRule struct {
+	// Ecosystem or Owner select the packages the rule counts; a rule with
+	// neither counts the packages no other rule does
+	Ecosystem string `yaml:"ecosystem"`
+	Owner     string `yaml:"owner"`
+	// MaxRegressions is how many regressions the packages may have
+	MaxRegressions int `yaml:"max-regressions"`
+	// ExitCode is the exit status of runs exceeding the rule, 1 if unset
+	ExitCode int `yaml:"exit-code"`
+}
+
+// UnmarshalYAML defaults the exit status of a rule to 1, so that an unset
+// exit-code can be told from 0
+func (r *BudgetRule) UnmarshalYAML(node *yaml.Node) error {
+	type plain BudgetRule
+	rule := plain{ExitCode: 1}
+	if err := node.Decode(&rule); err != nil {
+		return err
+	}
+	*r = BudgetRule(rule)
+	return nil
+}
+
+// FailureBudget is a release policy of the config file, e.g. "fail only if
+// more than 2 python packages regress". Regressions no rule counts fail the
+// run as usual.
+//
+//	owners:
+//	  db-team: [postgresql-*, mariadb-*]
+//	failure-budgets:
+//	  release:
+//	    - ecosystem: python
+//	      max-regressions: 2
+//	      exit-code: 20
+//	    - owner: db-team
+//	      max-regressions: 0
+//	      exit-code: 21
+type FailureBudget struct {
+	Name  string
+	Rules []BudgetRule
+	// owners maps the owners of the rules to the package names or globs
+	// they own
+	owners map[string][]string
+}
+
+// LoadFailureBudget reads the named failure budget from the config file at
+// path
+func LoadFailureBudget(path, name string) (*FailureBudget, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var file configFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	rules, ok := file.FailureBudgets[name]
+	if !ok {
+		names := sortedKeys(file.FailureBudgets)
+		return nil, fmt.Errorf("%w %q in %s, expected one of: %s", ErrUnknownFailureBudget, name, path, strings.Join(names, ", "))
+	}
+
+	budget := &FailureBudget{Name: name, Rules: rules, owners: file.Owners}
+	if err := budget.validate(); err != nil {
+		return nil, fmt.Errorf("failure budget %s in %s: %w", name, path, err)
+	}
+	return budget, nil
+}
+
+// validate checks the rules and the owners they refer to
+func (b *FailureBudget) validate() error {
+	if len(b.Rules) == 0 {
+		return errors.New("has no rules")
+	}
+	for i, rule := range b.Rules {
+		switch {
+		case rule.Ecosystem != "" && rule.Owner != "":
+			return fmt.Errorf("rule %d selects both an ecosystem and an owner", i+1)
+		case rule.Ecosystem != "" && !isOneOf(ecosystems, rule.Ecosystem):
+			return fmt.Errorf("rule %d: unknown ecosystem %s (must be %s)", i+1, rule.Ecosystem, strings.Join(ecosystems, ", "))
+		case rule.MaxRegressions < 0:
+			return fmt.Errorf("rule %d: max-regressions must not be negative", i+1)
+		case rule.ExitCode < 1 || rule.ExitCode > 125 || rule.ExitCode >= ReservedExitCodeMin && rule.ExitCode <= ReservedExitCodeMax:
+			return fmt.Errorf("rule %d: exit-code must be between 1 and 125 and not %d to %d, which apkregress exits with for other reasons", i+1, ReservedExitCodeMin, ReservedExitCodeMax)
+		}
+		if rule.Owner == "" {
+			continue
+		}
+		patterns, ok := b.owners[rule.Owner]
+		if !ok {
+			return fmt.Errorf("rule %d: unknown owner %s", i+1, rule.Owner)
+		}
+		for _, pattern := range patterns {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("owner %s: invalid pattern %q: %w", rule.Owner, pattern, err)
+			}
+		}
+	}
+	return nil
+}
+
+// SetFailureBudget fails the run only when its regressions exceed a rule of
+// budget, with the rule's exit status, or when a regression isn't counted
+// by any rule
+func (r *RegressionTestRunner) SetFailureBudget(budget *FailureBudget) {
+	r.failureBudget = budget
+}
+
+// BudgetExceededError is returned by runs whose regressions exceed a rule
+// of their failure budget
+type BudgetExceededError struct {
+	Rule           string
+	Regressions    int
+	MaxRegressions int
+	// ExitCode is the exit status the rule asks for
+	ExitCode int
+}
+
+func (e *BudgetExceededError) Error() string {
+	return fmt.Sprintf("failure budget exceeded: %d regressions in %s, %d allowed", e.Regressions, e.Rule, e.MaxRegressions)
+}
+
+// budgetResult is how the regressions of a run fit its failure budget
+type budgetResult struct {
+	Name  string            `json:"name"`
+	Rules []budgetRuleUsage `json:"rules"`
+	// Unbudgeted are the regressions no rule counts, which fail the run
+	Unbudgeted []string `json:"unbudgeted,omitempty"`
+}
+
+// budgetRuleUsage is how many regressions a rule of a failure budget counted
+type budgetRuleUsage struct {
+	// Rule describes the packages the rule counts, e.g. "python packages"
+	Rule           string   `json:"rule"`
+	MaxRegressions int      `json:"maxRegressions"`
+	Regressions    []string `json:"regressions"`
+	Exceeded       bool     `json:"exceeded"`
+	ExitCode       int      `json:"exitCode"`
+}
+
+// describe names the packages a rule counts
+func (rule BudgetRule) describe() string {
+	switch {
+	case rule.Ecosystem != "":
+		return rule.Ecosystem + " packages"
+	case rule.Owner != "":
+		return "packages owned by " + rule.Owner
+	default:
+		return "other packages"
+	}
+}
+
+// owns reports whether owner owns pkg
+func (b *FailureBudget) owns(owner, pkg string) bool {
+	for _, pattern := range b.owners[owner] {
+		if ok, _ := path.Match(pattern, pkg); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// evaluate counts regressions against the rules. ecosystemOf maps each
+// regression to its ecosystem; regressions may carry a note, e.g.
+// "curl (wolfi)" in a matrix.
+func (b *FailureBudget) evaluate(regressions []string, ecosystemOf map[string]string) budgetResult {
+	result := budgetResult{Name: b.Name}
+	counted := make(map[string]bool)
+	catchAll := -1
+	for i, rule := range b.Rules {
+		usage := budgetRuleUsage{Rule: rule.describe(), MaxRegressions: rule.MaxRegressions, Regressions: []string{}, ExitCode: max(rule.ExitCode, 1)}
+		for _, entry := range regressions {
+			switch {
+			case rule.Ecosystem != "" && ecosystemOf[entry] == rule.Ecosystem,
+				rule.Owner != "" && b.owns(rule.Owner, gatePackage(entry)):
+				usage.Regressions = append(usage.Regressions, entry)
+				counted[entry] = true
+			}
+		}
+		if rule.Ecosystem == "" && rule.Owner == "" {
+			catchAll = i
+		}
+		result.Rules = append(result.Rules, usage)
+	}
+
+	for _, entry := range regressions {
+		if counted[entry] {
+			continue
+		}
+		if catchAll >= 0 {
+			result.Rules[catchAll].Regressions = append(result.Rules[catchAll].Regressions, entry)
+		} else {
+			result.Unbudgeted = append(result.Unbudgeted, entry)
+		}
+	}
+	for i := range result.Rules {
+		result.Rules[i].Exceeded = len(result.Rules[i].Regressions) > result.Rules[i].MaxRegressions
+	}
+	return result
+}
+
+// err fails the run with the first rule it exceeds, or if a regression
+// isn't counted by any rule
+func (b budgetResult) err() error {
+	for _, usage := range b.Rules {
+		if usage.Exceeded {
+			return &BudgetExceededError{
+				Rule:           usage.Rule,
+				Regressions:    len(usage.Regressions),
+				MaxRegressions: usage.MaxRegressions,
+				ExitCode:       usage.ExitCode,
+			}
+		}
+	}
+	if len(b.Unbudgeted) > 0 {
+		return fmt.Errorf("found %d regressions outside failure budget %s", len(b.Unbudgeted), b.Name)
+	}
+	return nil
+}
+
+// print writes the usage of the failure budget to the text summary
+func (b budgetResult) print(w io.Writer) {
+	fmt.Fprintf(w, "\nFailure budget %s:\n", b.Name)
+	for _, usage := range b.Rules {
+		mark := "within budget"
+		if usage.Exceeded {
+			mark = fmt.Sprintf("EXCEEDED, exit status %d", usage.ExitCode)
+		}
+		fmt.Fprintf(w, "  - %s: %d regressions, %d allowed (%s)%s\n", usage.Rule, len(usage.Regressions), usage.MaxRegressions, mark, listNote(usage.Regressions))
+	}
+	if len(b.Unbudgeted) > 0 {
+		fmt.Fprintf(w, "  - Not covered by any rule: %s\n", strings.Join(b.Unbudgeted, ", "))
+	}
+}
+
+// printMarkdown writes the usage of the failure budget to the markdown
+// summary
+func (b budgetResult) printMarkdown(w io.Writer) {
+	icon := "✅"
+	if b.err() != nil {
+		icon = "❌"
+	}
+	fmt.Fprintf(w, "\n### %s Failure Budget `%s`\n\n", icon, b.Name)
+	fmt.Fprintf(w, "| Packages | Regressions | Allowed | Status |\n")
+	fmt.Fprintf(w, "|----------|-------------|---------|--------|\n")
+	for _, usage := range b.Rules {
+		status := "within budget"
+		if usage.Exceeded {
+			status = fmt.Sprintf("**exceeded** (exit status %d)", usage.ExitCode)
+		}
+		fmt.Fprintf(w, "| %s | %d | %d | %s |\n", usage.Rule, len(usage.Regressions), usage.MaxRegressions, status)
+	}
+	if len(b.Unbudgeted) > 0 {
+		fmt.Fprintf(w, "\nRegressions not covered by any rule: %s\n", strings.Join(b.Unbudgeted, ", "))
+	}
+}
+
+// listNote formats packages as a note, e.g. ": curl, wget", or returns ""
+// if there are none
+func listNote(packages []string) string {
+	if len(packages) == 0 {
+		return ""
+	}
+	return ": " + strings.Join(packages, ", ")
+}
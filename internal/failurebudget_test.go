@@ -0,0 +1,175 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFailureBudgetEvaluate(t *testing.T) {
+	owners := map[string][]string{"db-team": {"postgresql-*", "mariadb"}}
+	ecosystemOf := map[string]string{
+		"py3-requests":     EcosystemPython,
+		"py3-urllib3":      EcosystemPython,
+		"py3-idna":         EcosystemPython,
+		"postgresql-16":    EcosystemOther,
+		"curl":             EcosystemOther,
+		"curl (wolfi)":     EcosystemOther,
+		"py3-idna (wolfi)": EcosystemPython,
+	}
+
+	tests := []struct {
+		name          string
+		rules         []BudgetRule
+		regressions   []string
+		expectedUsage map[string][]string
+		unbudgeted    []string
+		exitCode      int
+		expectedError string
+	}{
+		{
+			name:          "within ecosystem budget",
+			rules:         []BudgetRule{{Ecosystem: EcosystemPython, MaxRegressions: 2}},
+			regressions:   []string{"py3-requests", "py3-urllib3"},
+			expectedUsage: map[string][]string{"python packages": {"py3-requests", "py3-urllib3"}},
+		},
+		{
+			name:          "ecosystem budget exceeded",
+			rules:         []BudgetRule{{Ecosystem: EcosystemPython, MaxRegressions: 2, ExitCode: 20}},
+			regressions:   []string{"py3-requests", "py3-urllib3", "py3-idna"},
+			expectedUsage: map[string][]string{"python packages": {"py3-requests", "py3-urllib3", "py3-idna"}},
+			exitCode:      20,
+		},
+		{
+			name:          "owner budget exceeded with default exit status",
+			rules:         []BudgetRule{{Ecosystem: EcosystemPython, MaxRegressions: 2}, {Owner: "db-team"}},
+			regressions:   []string{"py3-requests", "postgresql-16"},
+			expectedUsage: map[string][]string{"python packages": {"py3-requests"}, "packages owned by db-team": {"postgresql-16"}},
+			exitCode:      1,
+		},
+		{
+			name:          "catch-all rule",
+			rules:         []BudgetRule{{Ecosystem: EcosystemPython, MaxRegressions: 2}, {MaxRegressions: 1}},
+			regressions:   []string{"py3-requests", "curl"},
+			expectedUsage: map[string][]string{"python packages": {"py3-requests"}, "other packages": {"curl"}},
+		},
+		{
+			name:          "unbudgeted regressions",
+			rules:         []BudgetRule{{Ecosystem: EcosystemPython, MaxRegressions: 2}},
+			regressions:   []string{"py3-requests", "curl"},
+			expectedUsage: map[string][]string{"python packages": {"py3-requests"}},
+			unbudgeted:    []string{"curl"},
+			expectedError: "found 1 regressions outside failure budget release",
+		},
+		{
+			name:          "tagged matrix regressions",
+			rules:         []BudgetRule{{Ecosystem: EcosystemPython, MaxRegressions: 0, ExitCode: 20}, {MaxRegressions: 1}},
+			regressions:   []string{"py3-idna (wolfi)", "curl (wolfi)"},
+			expectedUsage: map[string][]string{"python packages": {"py3-idna (wolfi)"}, "other packages": {"curl (wolfi)"}},
+			exitCode:      20,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			budget := &FailureBudget{Name: "release", Rules: tt.rules, owners: owners}
+			result := budget.evaluate(tt.regressions, ecosystemOf)
+
+			for _, usage := range result.Rules {
+				expected := tt.expectedUsage[usage.Rule]
+				if expected == nil {
+					expected = []string{}
+				}
+				if !reflect.DeepEqual(usage.Regressions, expected) {
+					t.Errorf("Expected %v counted by %s, got %v", expected, usage.Rule, usage.Regressions)
+				}
+			}
+			if !reflect.DeepEqual(result.Unbudgeted, tt.unbudgeted) {
+				t.Errorf("Expected unbudgeted %v, got %v", tt.unbudgeted, result.Unbudgeted)
+			}
+
+			err := result.err()
+			var budgetErr *BudgetExceededError
+			switch {
+			case tt.exitCode != 0:
+				if !errors.As(err, &budgetErr) || budgetErr.ExitCode != tt.exitCode {
+					t.Errorf("Expected the budget to be exceeded with exit status %d, got %v", tt.exitCode, err)
+				}
+			case tt.expectedError != "":
+				if err == nil || err.Error() != tt.expectedError {
+					t.Errorf("Expected error %q, got %v", tt.expectedError, err)
+				}
+			case err != nil:
+				t.Errorf("Expected no error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestLoadFailureBudget(t *testing.T) {
+	config := `owners:
+  db-team: [postgresql-*]
+  broken: ["["]
+failure-budgets:
+  release:
+    - ecosystem: python
+      max-regressions: 2
+      exit-code: 20
+    - owner: db-team
+  unknown-owner:
+    - owner: web-team
+  unknown-ecosystem:
+    - ecosystem: cobol
+  invalid-pattern:
+    - owner: broken
+  invalid-exit-code:
+    - max-regressions: 1
+      exit-code: 300
+  zero-exit-code:
+    - max-regressions: 1
+      exit-code: 0
+  reserved-exit-code:
+    - max-regressions: 1
+      exit-code: 4
+`
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	budget, err := LoadFailureBudget(path, "release")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := []BudgetRule{{Ecosystem: EcosystemPython, MaxRegressions: 2, ExitCode: 20}, {Owner: "db-team", ExitCode: 1}}
+	if !reflect.DeepEqual(budget.Rules, expected) {
+		t.Errorf("Expected rules %+v, got %+v", expected, budget.Rules)
+	}
+
+	tests := []struct {
+		name          string
+		expectedError string
+	}{
+		{"unknown-owner", "unknown owner web-team"},
+		{"unknown-ecosystem", "unknown ecosystem cobol"},
+		{"invalid-pattern", "invalid pattern"},
+		{"invalid-exit-code", "exit-code must be between 1 and 125 and not 3 to 13"},
+		{"zero-exit-code", "exit-code must be between 1 and 125"},
+		{"reserved-exit-code", "exit-code must be between 1 and 125 and not 3 to 13"},
+		{"missing", "unknown failure budget"},
+	}
+	for _, tt := range tests {
+		if _, err := LoadFailureBudget(path, tt.name); err == nil || !strings.Contains(err.Error(), tt.expectedError) {
+			t.Errorf("Expected an error containing %q for %s, got %v", tt.expectedError, tt.name, err)
+		}
+	}
+	if _, err := LoadFailureBudget(path, "missing"); !errors.Is(err, ErrUnknownFailureBudget) {
+		t.Errorf("Expected ErrUnknownFailureBudget, got %v", err)
+	}
+}
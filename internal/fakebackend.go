@@ -0,0 +1,228 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// FakeBackendEnv enables the fake backend when set to 1: package indexes are
+// read from built-in fixtures and tests are simulated instead of run, so the
+// whole pipeline of discovery, scheduling, analysis and reports can be
+// exercised in CI, or a setup validated, without apkrane, make or melange.
+const FakeBackendEnv = "APKREGRESS_FAKE_BACKEND"
+
+// FakeOutcomesEnv sets the outcomes of fake tests by package, e.g.
+// "curl=regression,git=hang". Packages not listed pass.
+const FakeOutcomesEnv = "APKREGRESS_FAKE_OUTCOMES"
+
+// Outcomes of fake tests
+const (
+	FakeOutcomePass = "pass"
+	// FakeOutcomeFail fails with and without the candidate repository
+	FakeOutcomeFail = "fail"
+	// FakeOutcomeRegression only fails with the candidate repository
+	FakeOutcomeRegression = "regression"
+	// FakeOutcomeHang hangs with the candidate repository
+	FakeOutcomeHang = "hang"
+	// FakeOutcomeInfra fails as if the docker daemon was unreachable
+	FakeOutcomeInfra = "infra"
+	// FakeOutcomeOOM fails as if the kernel killed the test for lack of
+	// memory
+	FakeOutcomeOOM = "oom"
+)
+
+// fixtures are the package index the fake backend discovers reverse
+// dependencies in and the candidate repository index it serves
+//
+//go:embed fixtures
+var fixtures embed.FS
+
+// FakeBackend reports whether the fake backend is enabled
+func FakeBackend() bool {
+	return os.Getenv(FakeBackendEnv) == "1"
+}
+
+// fakeIndex returns the fixture index in dir as an APKINDEX.tar.gz
+func fakeIndex(dir string) ([]byte, error) {
+	contents, err := fixtures.ReadFile(dir + "/APKINDEX")
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(zw)
+	if err := tw.WriteHeader(&tar.Header{Name: "APKINDEX", Mode: 0644, Size: int64(len(contents))}); err != nil {
+		return nil, err
+	}
+	if _, err := tw.Write(contents); err != nil {
+		return nil, err
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// fakePackages returns the packages of the fixture index, as apkrane lists
+// them
+func fakePackages() ([]Package, error) {
+	data, err := fakeIndex("fixtures/index")
+	if err != nil {
+		return nil, err
+	}
+	index, err := parseAPKIndex(data)
+	if err != nil {
+		return nil, err
+	}
+
+	packages := make([]Package, 0, len(index.Packages))
+	for _, entry := range index.Packages {
+		packages = append(packages, Package{
+			Name:         entry.Name,
+			Version:      entry.Version,
+			Origin:       entry.Origin,
+			Dependencies: entry.Dependencies,
+		})
+	}
+	return packages, nil
+}
+
+// fakeExitError is the exit of a failed fake test
+type fakeExitError struct {
+	code int
+}
+
+func (e *fakeExitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.code)
+}
+
+// ExitCode returns the exit status, like *exec.ExitError
+func (e *fakeExitError) ExitCode() int {
+	return e.code
+}
+
+// fakeExecutor simulates tests: packages are located and skipped like with
+// melange, and the outcome of the others is set by FakeOutcomesEnv
+type fakeExecutor struct {
+	melange  *MelangeClient
+	outcomes map[string]string
+	err      error
+}
+
+func newFakeExecutor(melange *MelangeClient) *fakeExecutor {
+	outcomes, err := parseFakeOutcomes(os.Getenv(FakeOutcomesEnv))
+	return &fakeExecutor{melange: melange, outcomes: outcomes, err: err}
+}
+
+// parseFakeOutcomes parses outcomes such as "curl=regression,git=hang"
+func parseFakeOutcomes(spec string) (map[string]string, error) {
+	outcomes := make(map[string]string)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		pkg, outcome, ok := strings.Cut(entry, "=")
+		switch {
+		case !ok || pkg == "":
+			return nil, fmt.Errorf("invalid %s entry %q, expected <package>=<outcome>", FakeOutcomesEnv, entry)
+		case outcome != FakeOutcomePass && outcome != FakeOutcomeFail && outcome != FakeOutcomeRegression && outcome != FakeOutcomeHang && outcome != FakeOutcomeInfra && outcome != FakeOutcomeOOM:
+			return nil, fmt.Errorf("invalid %s outcome %q for %s, expected %s, %s, %s, %s, %s or %s", FakeOutcomesEnv, outcome, pkg, FakeOutcomePass, FakeOutcomeFail, FakeOutcomeRegression, FakeOutcomeHang, FakeOutcomeInfra, FakeOutcomeOOM)
+		}
+		outcomes[pkg] = outcome
+	}
+	return outcomes, nil
+}
+
+// CheckTools reports invalid outcomes before testing starts; the fake
+// backend needs no tools
+func (f *fakeExecutor) CheckTools() error {
+	return f.err
+}
+
+// Execute simulates the test of packageName, writing a log like melange
+func (f *fakeExecutor) Execute(ctx context.Context, packageName string, opts ExecuteOptions) TestResult {
+	startedAt := time.Now()
+	configPath, err := f.melange.locateConfig(packageName)
+	if err != nil {
+		return newTestResult(packageName, opts.WithRepo, startedAt, "", err)
+	}
+
+	outcome := f.outcomes[packageName]
+	if outcome == "" {
+		outcome = FakeOutcomePass
+	}
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = f.melange.timeoutFor(packageName)
+	}
+
+	var testErr error
+	step := "test/" + configTarget(configPath)
+	switch {
+	case ctx.Err() != nil:
+		testErr = ctx.Err()
+	case outcome == FakeOutcomeFail, outcome == FakeOutcomeRegression && opts.WithRepo:
+		testErr = &fakeExitError{code: 2}
+	case outcome == FakeOutcomeInfra:
+		testErr = &fakeExitError{code: 125}
+	case outcome == FakeOutcomeOOM:
+		testErr = &fakeExitError{code: 137}
+	case outcome == FakeOutcomeHang && opts.WithRepo:
+		testErr = ErrTestHung
+	}
+
+	repo := "without the candidate repository"
+	suffix := "without_repo"
+	if opts.WithRepo {
+		repo, suffix = "with the candidate repository "+opts.APKRepo, "with_repo"
+	}
+	if opts.Smoke {
+		suffix += "_smoke"
+	}
+	if opts.Previous != "" {
+		suffix += "_previous"
+	}
+	logPath := filepath.Join(f.melange.logDir, fmt.Sprintf("%s_%s.log", packageName, suffix))
+	var log strings.Builder
+	fmt.Fprintf(&log, "Fake test of %s (%s) %s\n", packageName, filepath.Base(configPath), repo)
+	fmt.Fprintf(&log, "INFO running step %q\n", step)
+	switch {
+	case testErr == nil:
+		fmt.Fprintf(&log, "Test passed\n")
+	case errors.Is(testErr, ErrTestHung):
+		fmt.Fprintf(&log, "\n\n=== TEST HUNG - KILLED AFTER %v ===\n", timeout)
+	case outcome == FakeOutcomeInfra:
+		fmt.Fprintf(&log, "docker: Cannot connect to the Docker daemon at unix:///var/run/docker.sock. Is the docker daemon running?\n")
+	case outcome == FakeOutcomeOOM:
+		fmt.Fprintf(&log, "make: *** [Makefile:42: test/%s] Killed\n", configTarget(configPath))
+	default:
+		fmt.Fprintf(&log, "ERROR step %q failed: %v\n", step, testErr)
+	}
+	if err := os.WriteFile(logPath, []byte(log.String()), 0644); err != nil {
+		return newTestResult(packageName, opts.WithRepo, startedAt, "", fmt.Errorf("failed to write log: %w", err))
+	}
+
+	result := newTestResult(packageName, opts.WithRepo, startedAt, logPath, testErr)
+	result.OOMKilled = outcome == FakeOutcomeOOM
+	if !result.Success && !result.Hung {
+		result.FailingStep = FailingStep(logPath)
+	}
+	return result
+}
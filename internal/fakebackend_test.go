@@ -0,0 +1,113 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseFakeOutcomes(t *testing.T) {
+	tests := []struct {
+		spec     string
+		expected map[string]string
+		wantErr  bool
+	}{
+		{"", map[string]string{}, false},
+		{"curl=regression, git=hang", map[string]string{"curl": "regression", "git": "hang"}, false},
+		{"curl", nil, true},
+		{"curl=broken", nil, true},
+	}
+
+	for _, tt := range tests {
+		outcomes, err := parseFakeOutcomes(tt.spec)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Expected error %v for %q, got %v", tt.wantErr, tt.spec, err)
+		}
+		if !tt.wantErr && !reflect.DeepEqual(outcomes, tt.expected) {
+			t.Errorf("Expected %v for %q, got %v", tt.expected, tt.spec, outcomes)
+		}
+	}
+}
+
+func TestFakeIndexes(t *testing.T) {
+	t.Setenv(FakeBackendEnv, "1")
+
+	apkrane := NewApkraneClient(false, "wolfi")
+	deps, err := apkrane.GetReverseDependencies("openssl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(deps, []string{"curl", "git", "python-3.12", "wget"}) {
+		t.Errorf("Expected the reverse dependencies in the fixture index, got %v", deps)
+	}
+
+	if err := ValidateCandidateRepo("https://example.com/candidate", nil, "openssl", "3.3.3", false); err != nil {
+		t.Errorf("Expected the fixture candidate index to have openssl 3.3.3, got %v", err)
+	}
+}
+
+// TestFakeBackendRun runs the whole pipeline, from discovering the reverse
+// dependencies of openssl to the reports, on the fake backend
+func TestFakeBackendRun(t *testing.T) {
+	t.Setenv(FakeBackendEnv, "1")
+	t.Setenv(FakeOutcomesEnv, "curl=regression,git=hang,wget=fail")
+
+	repoPath := t.TempDir()
+	// python-3.12 has no config
+	for _, pkg := range []string{"curl", "git", "wget"} {
+		if err := os.WriteFile(filepath.Join(repoPath, pkg+".yaml"), []byte("package:\n  name: "+pkg+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	runner := NewRegressionTestRunner("openssl", "https://example.com/candidate", repoPath, "wolfi", 2, false, time.Minute, false)
+	runner.setLogDir(t.TempDir())
+	runner.SetSummaryJSON(true)
+	runner.SetFailOnEmpty(true)
+	if err := runner.Run(); err == nil {
+		t.Fatal("Expected the regression to fail the run")
+	}
+
+	if !reflect.DeepEqual(runner.summary.Regressions, []string{"curl"}) {
+		t.Errorf("Expected curl to regress, got %v", runner.summary.Regressions)
+	}
+	if !reflect.DeepEqual(runner.summary.Hung, []string{"git (with repo)"}) {
+		t.Errorf("Expected git to hang, got %v", runner.summary.Hung)
+	}
+	if !reflect.DeepEqual(runner.summary.Failed, []string{"wget"}) {
+		t.Errorf("Expected wget to fail, got %v", runner.summary.Failed)
+	}
+	if !reflect.DeepEqual(runner.summary.Skipped, []string{"python-3.12"}) {
+		t.Errorf("Expected python-3.12 to be skipped, got %v", runner.summary.Skipped)
+	}
+	if runner.versionChange == nil || runner.versionChange.FromVersion != "3.3.2-r0" || runner.versionChange.ToVersion != "3.3.3-r0" {
+		t.Errorf("Expected the version change from the fixtures, got %v", runner.versionChange)
+	}
+
+	data, err := os.ReadFile(filepath.Join(runner.logDir, "summary.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var report SummaryReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatal(err)
+	}
+	if report.Tested != 3 {
+		t.Errorf("Expected 3 tested packages in summary.json, got %d", report.Tested)
+	}
+
+	log, err := os.ReadFile(filepath.Join(runner.logDir, "curl_with_repo.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(log), `running step "test/curl"`) {
+		t.Errorf("Expected a melange-like log, got %s", log)
+	}
+}
@@ -0,0 +1,166 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// follower streams the output of the tests of followed packages to the
+// terminal while the run continues, each line prefixed with the package
+type follower struct {
+	mu       sync.Mutex
+	packages map[string]bool
+	out      io.Writer
+	// clearLine clears the progress bar before each line
+	clearLine bool
+}
+
+func newFollower(clearLine bool) *follower {
+	return &follower{packages: make(map[string]bool), out: os.Stdout, clearLine: clearLine}
+}
+
+// set starts or stops following packages
+func (f *follower) set(packages []string, follow bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, pkg := range packages {
+		if follow {
+			f.packages[pkg] = true
+		} else {
+			delete(f.packages, pkg)
+		}
+	}
+}
+
+func (f *follower) following(pkg string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.packages[pkg]
+}
+
+// printLine writes a line of followed output; lines of concurrent tests
+// don't interleave
+func (f *follower) printLine(prefix string, line []byte) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.clearLine {
+		fmt.Fprint(f.out, "\r\033[K")
+	}
+	fmt.Fprintf(f.out, "%s%s\n", prefix, line)
+}
+
+// writer returns the writer a test of pkg writes its output to, which
+// passes it on while pkg is followed, so that a package followed in the
+// middle of its test streams from then on
+func (f *follower) writer(pkg string, withRepo bool) *followWriter {
+	prefix := fmt.Sprintf("[%s] ", pkg)
+	if !withRepo {
+		prefix = fmt.Sprintf("[%s, without repo] ", pkg)
+	}
+	return &followWriter{follower: f, pkg: pkg, prefix: prefix}
+}
+
+// followWriter splits the output of one test into lines for the follower
+type followWriter struct {
+	follower *follower
+	pkg      string
+	prefix   string
+	buf      []byte
+}
+
+func (w *followWriter) Write(p []byte) (int, error) {
+	if !w.follower.following(w.pkg) {
+		w.buf = w.buf[:0]
+		return len(p), nil
+	}
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		w.follower.printLine(w.prefix, bytes.TrimSuffix(w.buf[:i], []byte("\r")))
+		w.buf = w.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+// flush prints the last line of the output if it has no newline
+func (w *followWriter) flush() {
+	if len(w.buf) > 0 && w.follower.following(w.pkg) {
+		w.follower.printLine(w.prefix, w.buf)
+	}
+	w.buf = w.buf[:0]
+}
+
+// SetFollow streams the test output of packages to the terminal, e.g. to
+// watch a known-problematic consumer while the rest of the run continues.
+// Their logs are written as usual.
+func (m *MelangeClient) SetFollow(packages []string) {
+	m.follower.set(packages, true)
+}
+
+// SetFollow follows the tests of packages (see MelangeClient.SetFollow)
+func (r *RegressionTestRunner) SetFollow(packages []string) {
+	r.follow = packages
+	if r.melange != nil && len(packages) > 0 {
+		r.melange.SetFollow(packages)
+	}
+}
+
+// warnUnfollowed warns about followed packages that aren't tested in the run
+func (r *RegressionTestRunner) warnUnfollowed(packages []string) {
+	tested := make(map[string]bool, len(packages))
+	for _, pkg := range packages {
+		tested[pkg] = true
+	}
+	for _, pkg := range r.follow {
+		if !tested[pkg] {
+			fmt.Printf("Warning: not following %s, which this run doesn't test\n", pkg)
+		}
+	}
+}
+
+// Follow starts streaming the test output of packages of the run in
+// progress to the terminal, from the next line of tests already running on.
+// It returns the packages that are part of the run and now followed.
+func (r *RegressionTestRunner) Follow(packages []string) ([]string, error) {
+	return r.setFollowing(packages, true)
+}
+
+// Unfollow stops streaming the test output of packages of the run in
+// progress. It returns the packages that are part of the run.
+func (r *RegressionTestRunner) Unfollow(packages []string) ([]string, error) {
+	return r.setFollowing(packages, false)
+}
+
+func (r *RegressionTestRunner) setFollowing(packages []string, follow bool) ([]string, error) {
+	if r.activeQueue() == nil {
+		return nil, ErrNoRunInProgress
+	}
+	if r.melange == nil {
+		return nil, fmt.Errorf("only melange tests can be followed")
+	}
+
+	r.queueMu.Lock()
+	inRun := make(map[string]bool, len(r.manifest.Packages))
+	for _, pkg := range r.manifest.Packages {
+		inRun[pkg] = true
+	}
+	r.queueMu.Unlock()
+
+	var changed []string
+	for _, pkg := range packages {
+		if inRun[pkg] {
+			changed = append(changed, pkg)
+		}
+	}
+	r.melange.follower.set(changed, follow)
+	return changed, nil
+}
@@ -0,0 +1,110 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFollowWriter(t *testing.T) {
+	var out bytes.Buffer
+	f := newFollower(false)
+	f.out = &out
+	f.set([]string{"curl"}, true)
+
+	followed := f.writer("curl", true)
+	followed.Write([]byte("building\nrunning te"))
+	followed.Write([]byte("sts\r\nok"))
+	followed.flush()
+
+	other := f.writer("wget", false)
+	other.Write([]byte("not followed\n"))
+
+	without := f.writer("curl", false)
+	without.Write([]byte("without\n"))
+
+	expected := "[curl] building\n[curl] running tests\n[curl] ok\n[curl, without repo] without\n"
+	if out.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, out.String())
+	}
+
+	// Unfollowing stops the stream in the middle of a test
+	out.Reset()
+	f.set([]string{"curl"}, false)
+	followed.Write([]byte("more\n"))
+	if out.Len() != 0 {
+		t.Errorf("Expected no output once unfollowed, got %q", out.String())
+	}
+}
+
+func TestFollowTest(t *testing.T) {
+	if _, err := exec.LookPath("make"); err != nil {
+		t.Skip("make not available")
+	}
+
+	tmpDir := t.TempDir()
+	logDir := filepath.Join(tmpDir, "logs")
+	os.MkdirAll(logDir, 0755)
+	for _, pkg := range []string{"curl", "wget"} {
+		os.WriteFile(filepath.Join(tmpDir, pkg+".yaml"), []byte("test"), 0644)
+	}
+	os.WriteFile(filepath.Join(tmpDir, "Makefile"), []byte("test/%:\n\t@echo testing $*\n"), 0644)
+
+	var out bytes.Buffer
+	client := NewMelangeClient(tmpDir, true, logDir, time.Minute)
+	client.follower.out = &out
+	client.SetFollow([]string{"curl"})
+
+	for _, pkg := range []string{"curl", "wget"} {
+		if result := client.Execute(context.Background(), pkg, ExecuteOptions{WithRepo: true}); !result.Success {
+			t.Fatalf("Expected the test of %s to pass, got %v", pkg, result.Error)
+		}
+	}
+	if out.String() != "[curl] testing curl\n" {
+		t.Errorf("Expected only the output of curl, got %q", out.String())
+	}
+	content, _ := os.ReadFile(filepath.Join(logDir, "curl_with_repo.log"))
+	if !bytes.Contains(content, []byte("testing curl")) {
+		t.Errorf("Expected the output of a followed test in its log, got %q", content)
+	}
+}
+
+func TestRunnerFollow(t *testing.T) {
+	runner := NewRegressionTestRunnerFromPackageList([]string{"a", "b"}, "https://example.com/repo", "/tmp", "wolfi", 1, true, time.Minute, false)
+	runner.setLogDir(t.TempDir())
+
+	if _, err := runner.Follow([]string{"a"}); !errors.Is(err, ErrNoRunInProgress) {
+		t.Errorf("Expected ErrNoRunInProgress before the run, got %v", err)
+	}
+
+	runner.SetExecutor(TestExecutorFunc(func(ctx context.Context, pkg string, opts ExecuteOptions) TestResult {
+		if pkg == "a" {
+			if followed, err := runner.Follow([]string{"b", "c"}); err != nil || !reflect.DeepEqual(followed, []string{"b"}) {
+				t.Errorf("Expected b to be followed, got %v (%v)", followed, err)
+			}
+			if !runner.melange.follower.following("b") {
+				t.Errorf("Expected melange to follow b")
+			}
+			if unfollowed, err := runner.Unfollow([]string{"b"}); err != nil || !reflect.DeepEqual(unfollowed, []string{"b"}) {
+				t.Errorf("Expected b to be unfollowed, got %v (%v)", unfollowed, err)
+			}
+		}
+		return newTestResult(pkg, opts.WithRepo, time.Now(), "", nil)
+	}))
+
+	if err := runner.RunFromPackageList([]string{"a", "b"}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if runner.melange.follower.following("b") {
+		t.Errorf("Expected b to be unfollowed")
+	}
+}
@@ -0,0 +1,147 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// gateResult is how a run deviates from the expected results of a CI gate
+type gateResult struct {
+	// Source is the file the expected results were read from
+	Source string `json:"source"`
+	// NewRegressions and NewlyHung are the deviations that fail the gate
+	NewRegressions []string `json:"newRegressions"`
+	NewlyHung      []string `json:"newlyHung"`
+	// Known are the regressions and hung tests that were expected
+	Known []string `json:"known,omitempty"`
+}
+
+// SetGate compares the run with the expected results in path when it
+// finishes and fails it only on deviations: new regressions and newly hung
+// tests. Failures the expected results already had are ignored, so that a
+// CI gate is strict without failing on known breakage. path is a
+// results.json, a log directory or a file listing the expected
+// "regressions" and "hung" packages, such as summary.json.
+func (r *RegressionTestRunner) SetGate(path string) {
+	r.gatePath = path
+}
+
+// loadGate reads the expected results of the gate at path for repoType
+func loadGate(path, repoType string) (*runBaseline, error) {
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		path = filepath.Join(path, "results.json")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read gate: %w", err)
+	}
+	if !bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		return loadBaselineResults(path, repoType)
+	}
+
+	var expected struct {
+		Regressions []string `json:"regressions"`
+		Hung        []string `json:"hung"`
+	}
+	if err := json.Unmarshal(data, &expected); err != nil {
+		return nil, fmt.Errorf("invalid gate %s: %w", path, err)
+	}
+
+	baseline := &runBaseline{Source: path, Statuses: make(map[string]string)}
+	for _, pkg := range expected.Hung {
+		baseline.Statuses[gatePackage(pkg)] = StatusHung
+	}
+	for _, pkg := range expected.Regressions {
+		baseline.Statuses[gatePackage(pkg)] = StatusRegression
+	}
+	return baseline, nil
+}
+
+// gatePackage strips the notes summaries add to package names, e.g.
+// "curl (with repo)" or "curl (wolfi)"
+func gatePackage(entry string) string {
+	pkg, _, _ := strings.Cut(entry, " (")
+	return pkg
+}
+
+// evaluateGate compares the statuses of a run with the expected ones
+func evaluateGate(expected *runBaseline, statuses map[string]string) gateResult {
+	g := gateResult{Source: expected.Source, NewRegressions: []string{}, NewlyHung: []string{}}
+	for _, pkg := range sortedKeys(statuses) {
+		status := statuses[pkg]
+		if status != StatusRegression && status != StatusHung {
+			continue
+		}
+		switch {
+		case expected.Statuses[pkg] == status:
+			g.Known = append(g.Known, pkg)
+		case status == StatusRegression:
+			g.NewRegressions = append(g.NewRegressions, pkg)
+		default:
+			g.NewlyHung = append(g.NewlyHung, pkg)
+		}
+	}
+	return g
+}
+
+// merge adds the deviations of another run, e.g. of another repository type
+func (g gateResult) merge(other gateResult) gateResult {
+	return gateResult{
+		Source:         g.Source,
+		NewRegressions: append(append([]string{}, g.NewRegressions...), other.NewRegressions...),
+		NewlyHung:      append(append([]string{}, g.NewlyHung...), other.NewlyHung...),
+		Known:          append(append([]string(nil), g.Known...), other.Known...),
+	}
+}
+
+// err fails the run if it deviates from the expected results
+func (g gateResult) err() error {
+	if len(g.NewRegressions) == 0 && len(g.NewlyHung) == 0 {
+		return nil
+	}
+	return fmt.Errorf("gate failed: %d new regressions and %d newly hung tests compared to %s", len(g.NewRegressions), len(g.NewlyHung), g.Source)
+}
+
+// print writes the outcome of the gate to the text summary
+func (g gateResult) print(w io.Writer) {
+	fmt.Fprintf(w, "\nGate (%s):\n", g.Source)
+	for _, pkg := range g.NewRegressions {
+		fmt.Fprintf(w, "  - %s: new regression\n", pkg)
+	}
+	for _, pkg := range g.NewlyHung {
+		fmt.Fprintf(w, "  - %s: newly hung\n", pkg)
+	}
+	if len(g.Known) > 0 {
+		fmt.Fprintf(w, "  Ignored as expected: %s\n", strings.Join(g.Known, ", "))
+	}
+	if g.err() == nil {
+		fmt.Fprintf(w, "  Passed: no deviations from the expected results\n")
+	}
+}
+
+// printMarkdown writes the outcome of the gate to the markdown summary
+func (g gateResult) printMarkdown(w io.Writer) {
+	if g.err() == nil {
+		fmt.Fprintf(w, "\n### ✅ Gate Passed\n\nNo deviations from the expected results in `%s`", g.Source)
+		if len(g.Known) > 0 {
+			fmt.Fprintf(w, "; %d known failures ignored", len(g.Known))
+		}
+		fmt.Fprintf(w, ".\n")
+		return
+	}
+	fmt.Fprintf(w, "\n### ❌ Gate Failed\n\nDeviations from the expected results in `%s`:\n\n", g.Source)
+	for _, pkg := range g.NewRegressions {
+		fmt.Fprintf(w, "- `%s`: new regression\n", pkg)
+	}
+	for _, pkg := range g.NewlyHung {
+		fmt.Fprintf(w, "- `%s`: newly hung\n", pkg)
+	}
+}
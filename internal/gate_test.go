@@ -0,0 +1,116 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLoadGate(t *testing.T) {
+	dir := t.TempDir()
+
+	summary := filepath.Join(dir, "summary.json")
+	if err := os.WriteFile(summary, []byte(`{"regressions": ["curl"], "hung": ["git (with repo)"], "failed": ["wget"]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	gate, err := loadGate(summary, "wolfi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{"curl": StatusRegression, "git": StatusHung}
+	if !reflect.DeepEqual(gate.Statuses, expected) {
+		t.Errorf("Expected %v, got %v", expected, gate.Statuses)
+	}
+
+	results := `[
+		{"package": "curl", "withRepo": true, "success": false},
+		{"package": "curl", "withRepo": false, "success": true}
+	]`
+	if err := os.WriteFile(filepath.Join(dir, "results.json"), []byte(results), 0644); err != nil {
+		t.Fatal(err)
+	}
+	gate, err = loadGate(dir, "wolfi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gate.Statuses["curl"] != StatusRegression {
+		t.Errorf("Expected curl to be an expected regression, got %v", gate.Statuses)
+	}
+
+	if _, err := loadGate(filepath.Join(dir, "missing.json"), "wolfi"); err == nil {
+		t.Error("Expected an error for a missing gate")
+	}
+}
+
+func TestEvaluateGate(t *testing.T) {
+	expected := &runBaseline{Source: "baseline.json", Statuses: map[string]string{"curl": StatusRegression, "git": StatusHung, "wget": StatusRegression}}
+	statuses := map[string]string{
+		"curl":  StatusRegression,
+		"git":   StatusRegression,
+		"jq":    StatusHung,
+		"wget":  StatusPass,
+		"bison": StatusFail,
+	}
+
+	g := evaluateGate(expected, statuses)
+	if !reflect.DeepEqual(g.NewRegressions, []string{"git"}) {
+		t.Errorf("Expected git to be a new regression, got %v", g.NewRegressions)
+	}
+	if !reflect.DeepEqual(g.NewlyHung, []string{"jq"}) {
+		t.Errorf("Expected jq to be newly hung, got %v", g.NewlyHung)
+	}
+	if !reflect.DeepEqual(g.Known, []string{"curl"}) {
+		t.Errorf("Expected curl to be known, got %v", g.Known)
+	}
+	if g.err() == nil {
+		t.Error("Expected the gate to fail")
+	}
+
+	if err := evaluateGate(expected, map[string]string{"curl": StatusRegression, "bison": StatusFail}).err(); err != nil {
+		t.Errorf("Expected known regressions and failures to pass the gate, got %v", err)
+	}
+}
+
+func TestRunnerGate(t *testing.T) {
+	gatePath := filepath.Join(t.TempDir(), "baseline.json")
+	if err := os.WriteFile(gatePath, []byte(`{"regressions": ["curl"]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		regress   map[string]bool
+		expectErr bool
+	}{
+		{name: "known regression", regress: map[string]bool{"curl": true}},
+		{name: "new regression", regress: map[string]bool{"curl": true, "git": true}, expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			packages := []string{"curl", "git"}
+			runner := NewRegressionTestRunnerFromPackageList(packages, "https://example.com/repo", "/tmp", "wolfi", 2, false, time.Minute, false)
+			runner.setLogDir(t.TempDir())
+			runner.SetGate(gatePath)
+			runner.SetExecutor(TestExecutorFunc(func(ctx context.Context, pkg string, opts ExecuteOptions) TestResult {
+				var testErr error
+				if opts.WithRepo && tt.regress[pkg] {
+					testErr = errors.New("test failed")
+				}
+				return newTestResult(pkg, opts.WithRepo, time.Now(), "", testErr)
+			}))
+
+			err := runner.RunFromPackageList(packages)
+			if tt.expectErr != (err != nil) {
+				t.Errorf("Expected error %v, got %v", tt.expectErr, err)
+			}
+		})
+	}
+}
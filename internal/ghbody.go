@@ -0,0 +1,258 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// MaxGitHubBodySize is the most characters GitHub accepts in the body of an
+// issue or comment
+const MaxGitHubBodySize = 65536
+
+// Blocks of a body that doesn't fit are shortened to this many lines
+const (
+	overflowListLines = 10
+	overflowCodeLines = 20
+)
+
+// BodyOverflow stores the parts of an issue or comment body that don't fit
+// and returns where they can be read
+type BodyOverflow interface {
+	Store(name, content string) (string, error)
+}
+
+// GistOverflow stores overflow in secret gists with the gh CLI
+type GistOverflow struct{}
+
+// Store creates a gist of content and returns its URL
+func (GistOverflow) Store(name, content string) (string, error) {
+	cmd := exec.Command("gh", "gist", "create", "--filename", name, "-")
+	cmd.Stdin = strings.NewReader(content)
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to create gist: %w", commandError(err))
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// DirOverflow writes overflow to files in Dir, e.g. a log directory that CI
+// uploads as an artifact, linked at URL if the directory is published there
+type DirOverflow struct {
+	Dir string
+	URL string
+}
+
+// Store writes content to name in the directory and returns its URL, or its
+// path if the directory isn't published
+func (d DirOverflow) Store(name, content string) (string, error) {
+	path := filepath.Join(d.Dir, name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		return "", fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if d.URL == "" {
+		return path, nil
+	}
+	return strings.TrimSuffix(d.URL, "/") + "/" + url.PathEscape(name), nil
+}
+
+// NewLogDirOverflow stores overflow in logDir, linked below logURL, where the
+// directory containing logDir is published (see SetLogURL), if set
+func NewLogDirOverflow(logDir, logURL string) DirOverflow {
+	overflow := DirOverflow{Dir: logDir}
+	if logURL != "" {
+		overflow.URL = strings.TrimSuffix(logURL, "/") + "/" + url.PathEscape(filepath.Base(logDir))
+	}
+	return overflow
+}
+
+// LogDirOverflow stores overflow in the log directory of the run
+func (r *RegressionTestRunner) LogDirOverflow() DirOverflow {
+	return NewLogDirOverflow(r.logDir, r.logURL)
+}
+
+// blockKind is the kind of a markdown block, which decides how it is
+// shortened
+type blockKind int
+
+const (
+	blockText blockKind = iota
+	blockList
+	blockTable
+	blockCode
+)
+
+// bodyBlock is a run of lines of a markdown body: a fenced code block, a
+// list, a table or a single line of text
+type bodyBlock struct {
+	kind  blockKind
+	lines []string
+}
+
+var listItem = regexp.MustCompile(`^\s*([-*+]|\d+\.)\s`)
+
+// parseBodyBlocks splits a markdown body into blocks
+func parseBodyBlocks(body string) []bodyBlock {
+	lines := strings.Split(body, "\n")
+	var blocks []bodyBlock
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		switch {
+		case strings.HasPrefix(line, "```"):
+			end := i + 1
+			for end < len(lines) && !strings.HasPrefix(lines[end], "```") {
+				end++
+			}
+			if end < len(lines) {
+				end++
+			}
+			blocks = append(blocks, bodyBlock{kind: blockCode, lines: lines[i:end]})
+			i = end
+		case listItem.MatchString(line):
+			end := i + 1
+			for end < len(lines) && (listItem.MatchString(lines[end]) || strings.HasPrefix(lines[end], "  ") && strings.TrimSpace(lines[end]) != "") {
+				end++
+			}
+			blocks = append(blocks, bodyBlock{kind: blockList, lines: lines[i:end]})
+			i = end
+		case strings.HasPrefix(line, "|"):
+			end := i + 1
+			for end < len(lines) && strings.HasPrefix(lines[end], "|") {
+				end++
+			}
+			blocks = append(blocks, bodyBlock{kind: blockTable, lines: lines[i:end]})
+			i = end
+		default:
+			blocks = append(blocks, bodyBlock{kind: blockText, lines: []string{line}})
+			i++
+		}
+	}
+	return blocks
+}
+
+func (b bodyBlock) String() string {
+	return strings.Join(b.lines, "\n")
+}
+
+// shorten returns the block cut to its first lines, with a note linking to
+// the whole block, and whether it was long enough to cut
+func (b bodyBlock) shorten(link func(content string) string) (bodyBlock, bool) {
+	switch b.kind {
+	case blockList:
+		if len(b.lines) <= overflowListLines {
+			return b, false
+		}
+		rest := len(b.lines) - overflowListLines
+		lines := append([]string(nil), b.lines[:overflowListLines]...)
+		lines = append(lines, fmt.Sprintf("- … %d more %s", rest, link(b.String())))
+		return bodyBlock{kind: b.kind, lines: lines}, true
+	case blockTable:
+		// The header and delimiter rows stay
+		if len(b.lines) <= overflowListLines+2 {
+			return b, false
+		}
+		rest := len(b.lines) - overflowListLines - 2
+		lines := append([]string(nil), b.lines[:overflowListLines+2]...)
+		lines = append(lines, "", fmt.Sprintf("_… %d more rows %s_", rest, link(b.String())))
+		return bodyBlock{kind: b.kind, lines: lines}, true
+	case blockCode:
+		// The fences stay
+		if len(b.lines) <= overflowCodeLines+2 {
+			return b, false
+		}
+		content := b.lines[1 : len(b.lines)-1]
+		rest := len(content) - overflowCodeLines
+		lines := append([]string(nil), b.lines[0])
+		lines = append(lines, content[:overflowCodeLines]...)
+		lines = append(lines, b.lines[len(b.lines)-1], "", fmt.Sprintf("_… %d more lines %s_", rest, link(strings.Join(content, "\n")+"\n")))
+		return bodyBlock{kind: b.kind, lines: lines}, true
+	}
+	return b, false
+}
+
+// FitGitHubBody returns body shortened to fit into an issue or comment, if
+// it doesn't. The longest lists, tables and code blocks are cut to their
+// first lines until it fits, and the rest of each is stored with overflow
+// (if not nil) and linked in its place. A body that still doesn't fit is
+// truncated, linking to the whole of it. name names the stored files, e.g.
+// "openssl-bumps" for openssl-bumps-1.md.
+func FitGitHubBody(body, name string, overflow BodyOverflow) string {
+	return fitBody(body, name, overflow, MaxGitHubBodySize)
+}
+
+func fitBody(body, name string, overflow BodyOverflow, limit int) string {
+	if utf8.RuneCountInString(body) <= limit {
+		return body
+	}
+
+	stored := 0
+	link := func(content, ext string) string {
+		if overflow == nil {
+			return "not shown"
+		}
+		stored++
+		location, err := overflow.Store(fmt.Sprintf("%s-%d%s", name, stored, ext), content)
+		if err != nil {
+			fmt.Printf("Warning: failed to store what doesn't fit into the GitHub body: %v\n", err)
+			return "not shown"
+		}
+		return fmt.Sprintf("in [%s](%s)", filepath.Base(location), location)
+	}
+
+	blocks := parseBodyBlocks(body)
+	order := make([]int, len(blocks))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return len(blocks[order[i]].String()) > len(blocks[order[j]].String())
+	})
+
+	size := utf8.RuneCountInString(body)
+	for _, i := range order {
+		if size <= limit {
+			break
+		}
+		ext := ".md"
+		if blocks[i].kind == blockCode {
+			ext = ".txt"
+		}
+		before := utf8.RuneCountInString(blocks[i].String())
+		shortened, ok := blocks[i].shorten(func(content string) string { return link(content, ext) })
+		if !ok {
+			continue
+		}
+		blocks[i] = shortened
+		size += utf8.RuneCountInString(shortened.String()) - before
+	}
+
+	lines := make([]string, 0, len(blocks))
+	for _, block := range blocks {
+		lines = append(lines, block.String())
+	}
+	fitted := strings.Join(lines, "\n")
+	if size <= limit {
+		return fitted
+	}
+
+	note := fmt.Sprintf("\n\n_… truncated, the full text is %s_\n", link(body, ".md"))
+	keep := limit - utf8.RuneCountInString(note) - len("\n```")
+	if keep < 0 {
+		keep = 0
+	}
+	truncated := string([]rune(fitted)[:keep])
+	// Close a code block cut in the middle
+	if strings.Count("\n"+truncated, "\n```")%2 == 1 {
+		truncated += "\n```"
+	}
+	return truncated + note
+}
@@ -0,0 +1,139 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// memoryOverflow keeps what it stores
+type memoryOverflow struct {
+	stored map[string]string
+	err    error
+}
+
+func (m *memoryOverflow) Store(name, content string) (string, error) {
+	if m.err != nil {
+		return "", m.err
+	}
+	if m.stored == nil {
+		m.stored = make(map[string]string)
+	}
+	m.stored[name] = content
+	return "https://example.com/" + name, nil
+}
+
+func numberedLines(prefix string, n int) string {
+	lines := make([]string, n)
+	for i := range lines {
+		lines[i] = fmt.Sprintf("%s%d", prefix, i)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestFitBody(t *testing.T) {
+	list := numberedLines("- package-", 50)
+	code := "```\n" + numberedLines("log line ", 100) + "\n```"
+	body := "## Results\n\n" + list + "\n\n### Log\n\n" + code + "\n"
+
+	t.Run("fits", func(t *testing.T) {
+		overflow := &memoryOverflow{}
+		if got := fitBody(body, "run", overflow, len(body)); got != body {
+			t.Errorf("Expected a body that fits to be kept, got %q", got)
+		}
+		if len(overflow.stored) != 0 {
+			t.Errorf("Expected nothing to be stored, got %v", overflow.stored)
+		}
+	})
+
+	t.Run("longest block first", func(t *testing.T) {
+		overflow := &memoryOverflow{}
+		got := fitBody(body, "run", overflow, len(body)-100)
+		if utf8.RuneCountInString(got) > len(body)-100 {
+			t.Errorf("Expected the body to fit, got %d characters", utf8.RuneCountInString(got))
+		}
+		if !strings.Contains(got, "- package-49") {
+			t.Errorf("Expected the shorter list to be kept, got %q", got)
+		}
+		if strings.Contains(got, "log line 20") || !strings.Contains(got, "log line 19\n```") {
+			t.Errorf("Expected the log to be cut to 20 lines, got %q", got)
+		}
+		if !strings.Contains(got, "_… 80 more lines in [run-1.txt](https://example.com/run-1.txt)_") {
+			t.Errorf("Expected a link to the whole log, got %q", got)
+		}
+		if overflow.stored["run-1.txt"] != numberedLines("log line ", 100)+"\n" {
+			t.Errorf("Expected the whole log to be stored, got %q", overflow.stored["run-1.txt"])
+		}
+	})
+
+	t.Run("every block", func(t *testing.T) {
+		overflow := &memoryOverflow{}
+		got := fitBody(body, "run", overflow, 500)
+		if !strings.Contains(got, "- … 40 more in [run-2.md](https://example.com/run-2.md)") {
+			t.Errorf("Expected the list to be cut with a link, got %q", got)
+		}
+		if overflow.stored["run-2.md"] != list {
+			t.Errorf("Expected the whole list to be stored, got %q", overflow.stored["run-2.md"])
+		}
+	})
+
+	t.Run("truncated", func(t *testing.T) {
+		overflow := &memoryOverflow{}
+		got := fitBody(body, "run", overflow, 200)
+		if utf8.RuneCountInString(got) > 200 {
+			t.Errorf("Expected at most 200 characters, got %d", utf8.RuneCountInString(got))
+		}
+		if !strings.HasSuffix(got, "_… truncated, the full text is in [run-3.md](https://example.com/run-3.md)_\n") {
+			t.Errorf("Expected a link to the full text, got %q", got)
+		}
+		if overflow.stored["run-3.md"] != body {
+			t.Errorf("Expected the full text to be stored")
+		}
+	})
+
+	t.Run("storing fails", func(t *testing.T) {
+		got := fitBody(body, "run", &memoryOverflow{err: errors.New("gh not found")}, len(body)-100)
+		if !strings.Contains(got, "_… 80 more lines not shown_") {
+			t.Errorf("Expected the log to be cut without a link, got %q", got)
+		}
+	})
+
+	t.Run("unclosed code block", func(t *testing.T) {
+		long := "```\n" + strings.Repeat("x", 500) + "\n```\n"
+		got := fitBody(long, "run", nil, 200)
+		if strings.Count(got, "```")%2 != 0 {
+			t.Errorf("Expected the cut code block to be closed, got %q", got)
+		}
+	})
+}
+
+func TestDirOverflow(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "regression-test-openssl-20250101-120000")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	overflow := NewLogDirOverflow(dir, "https://logs.example.com/ci/")
+	location, err := overflow.Store("curl-regression-1.txt", "log\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "https://logs.example.com/ci/regression-test-openssl-20250101-120000/curl-regression-1.txt"; location != expected {
+		t.Errorf("Expected %s, got %s", expected, location)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "curl-regression-1.txt")); string(data) != "log\n" {
+		t.Errorf("Expected the content to be written, got %q", data)
+	}
+
+	location, err = NewLogDirOverflow(dir, "").Store("a.md", "a")
+	if err != nil || location != filepath.Join(dir, "a.md") {
+		t.Errorf("Expected the path without a log URL, got %s, %v", location, err)
+	}
+}
@@ -0,0 +1,76 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ChangedConfig is a melange config that changed between two git refs
+type ChangedConfig struct {
+	Path string
+	// Packages are the names of the main package and subpackages the config
+	// produces at the newer ref
+	Packages []string
+}
+
+// ChangedConfigs returns the melange configs in the git repository at
+// repoPath that were added or modified between the refs from and to.
+// Deleted configs and YAML files that aren't melange configs, such as
+// pipelines or CI workflows, are left out.
+func ChangedConfigs(repoPath, from, to string) ([]ChangedConfig, error) {
+	cmd := exec.Command("git", "diff", "--name-only", "--diff-filter=d", from, to, "--", "*.yaml", "*.yml")
+	cmd.Dir = repoPath
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to diff %s..%s: %w", from, to, commandError(err))
+	}
+
+	var changed []ChangedConfig
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		path := strings.TrimSpace(scanner.Text())
+		if path == "" {
+			continue
+		}
+
+		show := exec.Command("git", "show", fmt.Sprintf("%s:%s", to, path))
+		show.Dir = repoPath
+		data, err := show.Output()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s at %s: %w", path, to, commandError(err))
+		}
+
+		var config MelangeConfig
+		if err := yaml.Unmarshal(data, &config); err != nil || config.Package.Name == "" {
+			continue
+		}
+		changed = append(changed, ChangedConfig{
+			Path:     filepath.FromSlash(path),
+			Packages: config.PackageNames(),
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read git diff output: %w", err)
+	}
+
+	sort.Slice(changed, func(i, j int) bool { return changed[i].Path < changed[j].Path })
+	return changed, nil
+}
+
+// commandError adds the error output of a failed command to its error
+func commandError(err error) error {
+	if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(string(exitErr.Stderr)))
+	}
+	return err
+}
@@ -0,0 +1,75 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestChangedConfigs(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+
+	repo := t.TempDir()
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		cmd.Dir = repo
+		if output, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %v\n%s", args, err, output)
+		}
+	}
+	write := func(name, content string) {
+		t.Helper()
+		path := filepath.Join(repo, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	git("init", "-q")
+	write("openssl.yaml", "package:\n  name: openssl\n  version: 3.3.1\n")
+	write("curl.yaml", "package:\n  name: curl\n  version: 8.9.0\n")
+	write("zlib.yaml", "package:\n  name: zlib\n  version: 1.3.1\n")
+	git("add", ".")
+	git("commit", "-q", "-m", "initial")
+	git("tag", "v1")
+
+	write("openssl.yaml", "package:\n  name: openssl\n  version: 3.3.2\nsubpackages:\n  - name: libssl3\n")
+	write("nginx.yaml", "package:\n  name: nginx\n  version: 1.27.0\n")
+	write("pipelines/build.yaml", "name: build\npipeline: []\n")
+	write(".github/workflows/ci.yaml", "on: push\n")
+	write("README.md", "changed\n")
+	if err := os.Remove(filepath.Join(repo, "zlib.yaml")); err != nil {
+		t.Fatal(err)
+	}
+	git("add", "-A")
+	git("commit", "-q", "-m", "rebuild")
+	git("tag", "v2")
+
+	changed, err := ChangedConfigs(repo, "v1", "v2")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := []ChangedConfig{
+		{Path: "nginx.yaml", Packages: []string{"nginx"}},
+		{Path: "openssl.yaml", Packages: []string{"openssl", "libssl3"}},
+	}
+	if !reflect.DeepEqual(changed, expected) {
+		t.Errorf("Expected %v, got %v", expected, changed)
+	}
+
+	if _, err := ChangedConfigs(repo, "v1", "missing"); err == nil {
+		t.Error("Expected error for unknown ref")
+	}
+}
@@ -0,0 +1,166 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Health states reported by the readiness endpoint
+const (
+	HealthStarting = "starting"
+	HealthReady    = "ready"
+	HealthStopping = "stopping"
+)
+
+// Health is the state container orchestrators probe to manage a run or the
+// daemon: it is live as long as it answers, and ready from when it does its
+// work (tests are running, or jobs are being processed) until it stops. A
+// nil Health ignores updates.
+type Health struct {
+	mu      sync.Mutex
+	state   string
+	started time.Time
+}
+
+// NewHealth returns the health of a process that is starting
+func NewHealth() *Health {
+	return &Health{state: HealthStarting, started: time.Now()}
+}
+
+// SetReady marks the process ready, unless it is stopping
+func (h *Health) SetReady() {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.state != HealthStopping {
+		h.state = HealthReady
+	}
+}
+
+// SetStopping marks the process as stopping, e.g. on a stop signal, so that
+// it no longer reports ready
+func (h *Health) SetStopping() {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.state = HealthStopping
+}
+
+// State returns the state of the process, see HealthReady
+func (h *Health) State() string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.state
+}
+
+// Handler returns the probe endpoints:
+//
+//	GET /healthz   200 while the process answers
+//	GET /readyz    200 while it is ready, 503 while starting or stopping
+func (h *Health) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		state := h.State()
+		status := map[string]interface{}{
+			"state":  state,
+			"uptime": time.Since(h.started).Round(time.Second).String(),
+		}
+		switch strings.Trim(r.URL.Path, "/") {
+		case "healthz":
+			writeJSON(w, http.StatusOK, status)
+		case "readyz":
+			code := http.StatusOK
+			if state != HealthReady {
+				code = http.StatusServiceUnavailable
+			}
+			writeJSON(w, code, status)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+}
+
+// SetHealth marks health ready once the run starts testing, for the
+// readiness probe of a container running it
+func (r *RegressionTestRunner) SetHealth(health *Health) {
+	r.health = health
+}
+
+// isHealthPath reports whether an HTTP request is for a probe endpoint
+func isHealthPath(path string) bool {
+	path = strings.Trim(path, "/")
+	return path == "healthz" || path == "readyz"
+}
+
+// ServeHealth serves the probe endpoints of health on addr, e.g. :8081,
+// until the returned function is called
+func ServeHealth(addr string, health *Health) (func(), error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen for health checks on %s: %w", addr, err)
+	}
+
+	server := &http.Server{Handler: health.Handler(), ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fmt.Printf("Warning: health check server failed: %v\n", err)
+		}
+	}()
+
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}, nil
+}
+
+// runningTests are the commands of the tests, builds and hooks in progress,
+// so that they can be stopped with the process
+var runningTests = struct {
+	sync.Mutex
+	cmds map[*exec.Cmd]bool
+}{cmds: make(map[*exec.Cmd]bool)}
+
+// trackTest records the started command cmd of a test, build or hook as
+// running until the returned function is called
+func trackTest(cmd *exec.Cmd) func() {
+	runningTests.Lock()
+	runningTests.cmds[cmd] = true
+	runningTests.Unlock()
+	return func() {
+		runningTests.Lock()
+		delete(runningTests.cmds, cmd)
+		runningTests.Unlock()
+	}
+}
+
+// KillRunningTests kills the tests, builds and hooks in progress and their
+// child processes, for when the process exits before its runs stopped them,
+// so that none of them outlives it. It returns how many it killed.
+func KillRunningTests() int {
+	runningTests.Lock()
+	defer runningTests.Unlock()
+	for cmd := range runningTests.cmds {
+		killProcessGroup(cmd)
+	}
+	killed := len(runningTests.cmds)
+	runningTests.cmds = make(map[*exec.Cmd]bool)
+	return killed
+}
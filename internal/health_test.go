@@ -0,0 +1,134 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"testing"
+	"time"
+)
+
+func TestHealthHandler(t *testing.T) {
+	tests := []struct {
+		name            string
+		update          func(h *Health)
+		expectedState   string
+		expectedHealthz int
+		expectedReadyz  int
+	}{
+		{"starting", func(h *Health) {}, HealthStarting, http.StatusOK, http.StatusServiceUnavailable},
+		{"ready", func(h *Health) { h.SetReady() }, HealthReady, http.StatusOK, http.StatusOK},
+		{"stopping", func(h *Health) { h.SetReady(); h.SetStopping() }, HealthStopping, http.StatusOK, http.StatusServiceUnavailable},
+		{"ready after stopping", func(h *Health) { h.SetStopping(); h.SetReady() }, HealthStopping, http.StatusOK, http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			health := NewHealth()
+			tt.update(health)
+			handler := health.Handler()
+
+			for path, expected := range map[string]int{"/healthz": tt.expectedHealthz, "/readyz": tt.expectedReadyz} {
+				rec := httptest.NewRecorder()
+				handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+				if rec.Code != expected {
+					t.Errorf("Expected %s to return %d, got %d", path, expected, rec.Code)
+				}
+				var status struct {
+					State string `json:"state"`
+				}
+				if err := json.Unmarshal(rec.Body.Bytes(), &status); err != nil || status.State != tt.expectedState {
+					t.Errorf("Expected %s to report %s, got %q (%v)", path, tt.expectedState, rec.Body.String(), err)
+				}
+			}
+		})
+	}
+}
+
+func TestNilHealthIgnoresUpdates(t *testing.T) {
+	var health *Health
+	health.SetReady()
+	health.SetStopping()
+}
+
+func TestDaemonHandlerServesHealth(t *testing.T) {
+	daemon := newTestDaemon(t, "/bin/true", 1)
+	handler := daemon.Handler()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected the daemon not to be ready before it runs, got %d", rec.Code)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- daemon.Run(ctx) }()
+	deadline := time.Now().Add(5 * time.Second)
+	for daemon.Health().State() != HealthReady && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected the running daemon to be ready, got %d", rec.Code)
+	}
+
+	cancel()
+	<-done
+	if state := daemon.Health().State(); state != HealthStopping {
+		t.Errorf("Expected the stopped daemon to be %s, got %s", HealthStopping, state)
+	}
+}
+
+func TestKillRunningTests(t *testing.T) {
+	cmd := exec.Command("/bin/sh", "-c", "sleep 60 & wait")
+	if err := startInProcessGroup(cmd); err != nil {
+		t.Fatalf("Failed to start: %v", err)
+	}
+	untrack := trackTest(cmd)
+	defer untrack()
+
+	if killed := KillRunningTests(); killed != 1 {
+		t.Errorf("Expected 1 test to be killed, got %d", killed)
+	}
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		killProcessGroup(cmd)
+		t.Fatalf("Expected the test to be killed")
+	}
+	if killed := KillRunningTests(); killed != 0 {
+		t.Errorf("Expected no tests left to kill, got %d", killed)
+	}
+}
+
+func TestWaitTerminating(t *testing.T) {
+	// The shell exits on SIGTERM after cleaning up, within the grace period
+	cmd := exec.Command("/bin/sh", "-c", "trap 'exit 0' TERM; sleep 60 & wait")
+	if err := startInProcessGroup(cmd); err != nil {
+		t.Fatalf("Failed to start: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := waitTerminating(ctx, cmd, 10*time.Second)
+	if err != context.DeadlineExceeded {
+		t.Errorf("Expected %v, got %v", context.DeadlineExceeded, err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected the command to stop on SIGTERM, took %v", elapsed)
+	}
+	if cmd.ProcessState == nil || cmd.ProcessState.ExitCode() != 0 {
+		t.Errorf("Expected the command to exit cleanly, got %v", cmd.ProcessState)
+	}
+}
@@ -0,0 +1,115 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Heartbeat is the progress report POSTed to the heartbeat URL
+type Heartbeat struct {
+	RunID       string        `json:"runId"`
+	Target      string        `json:"target"`
+	RepoType    string        `json:"repoType,omitempty"`
+	Status      string        `json:"status"`
+	Completed   int64         `json:"completed"`
+	Total       int64         `json:"total"`
+	Regressions int64         `json:"regressions"`
+	Hung        int64         `json:"hung"`
+	Elapsed     time.Duration `json:"elapsed"`
+	ETA         time.Duration `json:"eta"`
+	SentAt      time.Time     `json:"sentAt"`
+	// Notes are the operator's notes about the run (see SetNotes)
+	Notes []string `json:"notes,omitempty"`
+}
+
+// Heartbeat statuses
+const (
+	HeartbeatRunning  = "running"
+	HeartbeatPaused   = "paused"
+	HeartbeatFinished = "finished"
+)
+
+// heartbeatSender periodically POSTs progress so that orchestration systems
+// can show it and detect stalled runs without scraping logs
+type heartbeatSender struct {
+	url      string
+	interval time.Duration
+	client   *http.Client
+	verbose  bool
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+func newHeartbeatSender(url string, interval time.Duration, verbose bool) *heartbeatSender {
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+	return &heartbeatSender{
+		url:      url,
+		interval: interval,
+		client:   &http.Client{Timeout: 10 * time.Second},
+		verbose:  verbose,
+	}
+}
+
+// start sends a heartbeat built by snapshot right away and then every
+// interval until finish is called
+func (h *heartbeatSender) start(snapshot func() Heartbeat) {
+	h.stop = make(chan struct{})
+	h.done = make(chan struct{})
+
+	go func() {
+		defer close(h.done)
+
+		ticker := time.NewTicker(h.interval)
+		defer ticker.Stop()
+
+		h.send(snapshot())
+		for {
+			select {
+			case <-ticker.C:
+				h.send(snapshot())
+			case <-h.stop:
+				return
+			}
+		}
+	}()
+}
+
+// finish stops the periodic heartbeats and sends the final one
+func (h *heartbeatSender) finish(final Heartbeat) {
+	if h.stop != nil {
+		close(h.stop)
+		<-h.done
+	}
+	h.send(final)
+}
+
+// send POSTs a heartbeat. Failures are only reported, a broken endpoint must
+// never fail the run.
+func (h *heartbeatSender) send(beat Heartbeat) {
+	beat.SentAt = time.Now()
+	data, err := json.Marshal(beat)
+	if err != nil {
+		return
+	}
+
+	resp, err := h.client.Post(h.url, "application/json", bytes.NewReader(data))
+	if err != nil {
+		if h.verbose {
+			fmt.Printf("Warning: failed to send heartbeat: %v\n", err)
+		}
+		return
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= 300 && h.verbose {
+		fmt.Printf("Warning: heartbeat endpoint returned %s\n", resp.Status)
+	}
+}
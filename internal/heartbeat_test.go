@@ -0,0 +1,94 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHeartbeatSender(t *testing.T) {
+	var mu sync.Mutex
+	var beats []Heartbeat
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("Unexpected request: %s %s", r.Method, r.Header.Get("Content-Type"))
+		}
+		var beat Heartbeat
+		if err := json.NewDecoder(r.Body).Decode(&beat); err != nil {
+			t.Errorf("Failed to decode heartbeat: %v", err)
+		}
+		mu.Lock()
+		beats = append(beats, beat)
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	sender := newHeartbeatSender(server.URL, 10*time.Millisecond, false)
+	sender.start(func() Heartbeat {
+		return Heartbeat{RunID: "run", Status: HeartbeatRunning, Completed: 1, Total: 3}
+	})
+	time.Sleep(50 * time.Millisecond)
+	sender.finish(Heartbeat{RunID: "run", Status: HeartbeatFinished, Completed: 3, Total: 3, Regressions: 1})
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if len(beats) < 2 {
+		t.Fatalf("Expected at least 2 heartbeats, got %d", len(beats))
+	}
+	if beats[0].Status != HeartbeatRunning || beats[0].Completed != 1 {
+		t.Errorf("Unexpected first heartbeat: %+v", beats[0])
+	}
+	last := beats[len(beats)-1]
+	if last.Status != HeartbeatFinished || last.Regressions != 1 || last.SentAt.IsZero() {
+		t.Errorf("Unexpected final heartbeat: %+v", last)
+	}
+}
+
+func TestHeartbeatSenderUnreachable(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	url := server.URL
+	server.Close()
+
+	// Must not panic or block when the endpoint is gone
+	sender := newHeartbeatSender(url, time.Hour, false)
+	sender.finish(Heartbeat{Status: HeartbeatFinished})
+}
+
+func TestCountOutcome(t *testing.T) {
+	runner := &RegressionTestRunner{}
+
+	runner.progress.Count(TestResult{Success: true}, nil)
+	runner.progress.Count(TestResult{Success: false}, &TestResult{Success: true})
+	runner.progress.Count(TestResult{Success: false}, &TestResult{Success: false})
+	runner.progress.Count(TestResult{Hung: true}, nil)
+	runner.progress.Count(TestResult{Success: false}, &TestResult{Hung: true})
+	runner.progress.Count(TestResult{Skipped: true}, nil)
+	runner.progress.Count(TestResult{Success: false}, nil)
+
+	progress := runner.progress.Snapshot()
+	if progress.Regressed != 1 {
+		t.Errorf("Expected 1 regression, got %d", progress.Regressed)
+	}
+	if progress.Hung != 2 {
+		t.Errorf("Expected 2 hung, got %d", progress.Hung)
+	}
+
+	if progress.Passed != 1 || progress.Failed != 2 || progress.Skipped != 1 {
+		t.Errorf("Expected 1 passed, 2 failed and 1 skipped, got %d, %d and %d", progress.Passed, progress.Failed, progress.Skipped)
+	}
+	if counts := progress.Counts(); counts != "✅ 1 ❌ 2 🔴 1 ⏰ 2 ⏭️ 1" {
+		t.Errorf("Unexpected progress counts %q", counts)
+	}
+
+	beat := runner.heartbeatSnapshot(HeartbeatFinished)
+	if beat.Regressions != 1 || beat.Hung != 2 || beat.Status != HeartbeatFinished {
+		t.Errorf("Unexpected heartbeat: %+v", beat)
+	}
+}
@@ -0,0 +1,184 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// Hooks are shell commands run around a run and around the tests of each
+// package, e.g. to warm caches, send notifications or clean up. Empty
+// commands aren't run.
+type Hooks struct {
+	// PreRun runs once the packages to test are known, before the first
+	// test; the run fails if it does
+	PreRun string
+	// PostRun runs once the run finished and its results are written
+	PostRun string
+	// PreTest and PostTest run before and after the tests of each package
+	PreTest  string
+	PostTest string
+}
+
+// Hook names, passed to hooks as APKREGRESS_HOOK
+const (
+	HookPreRun   = "pre-run"
+	HookPostRun  = "post-run"
+	HookPreTest  = "pre-test"
+	HookPostTest = "post-test"
+)
+
+// hookTimeout is how long a hook may run before it is killed
+const hookTimeout = 30 * time.Minute
+
+// hookLogFile logs the output of the run hooks in the log directory; the
+// hooks of each package log to <package>_hooks.log
+const hookLogFile = "hooks.log"
+
+// hookTest is the JSON the test hooks of a package get on stdin
+type hookTest struct {
+	RunID   string `json:"runId"`
+	Package string `json:"package"`
+	// Status is the outcome of the package's tests, for post-test hooks
+	Status  string       `json:"status,omitempty"`
+	Results []TestResult `json:"results,omitempty"`
+}
+
+// SetHooks runs hooks around the run and around the tests of each package.
+// Hooks run with sh -c and get the run's metadata in APKREGRESS_*
+// environment variables and as JSON on stdin: the run manifest before the
+// run, the summary after it, and the package and its results around tests.
+// Their output is logged to hooks.log and <package>_hooks.log in the log
+// directory.
+func (r *RegressionTestRunner) SetHooks(hooks Hooks) {
+	r.hooks = hooks
+}
+
+// hookEnv returns the environment variables describing the run to hooks
+func (r *RegressionTestRunner) hookEnv(hook string) []string {
+	return []string{
+		"APKREGRESS_HOOK=" + hook,
+		"APKREGRESS_RUN_ID=" + filepath.Base(r.logDir),
+		"APKREGRESS_LOG_DIR=" + r.logDir,
+		"APKREGRESS_TARGET=" + r.packageName,
+		"APKREGRESS_REPO=" + r.apkRepo,
+		"APKREGRESS_REPO_TYPE=" + r.repoType,
+		"APKREGRESS_REPO_PATH=" + r.repoPath,
+	}
+}
+
+// runPreRunHook runs the pre-run hook with the run manifest
+func (r *RegressionTestRunner) runPreRunHook() error {
+	if r.hooks.PreRun == "" {
+		return nil
+	}
+	if err := r.runHook(HookPreRun, r.hooks.PreRun, hookLogFile, r.hookEnv(HookPreRun), r.manifest); err != nil {
+		return fmt.Errorf("pre-run hook failed: %w", err)
+	}
+	return nil
+}
+
+// runPostRunHook runs the post-run hook with the summary of the run, which
+// ended with runErr
+func (r *RegressionTestRunner) runPostRunHook(runErr error) {
+	if r.hooks.PostRun == "" {
+		return
+	}
+	summary := r.summaryReport()
+	env := append(r.hookEnv(HookPostRun),
+		"APKREGRESS_REGRESSIONS="+strconv.Itoa(len(summary.Regressions)),
+		"APKREGRESS_HUNG="+strconv.Itoa(len(summary.Hung)),
+		"APKREGRESS_FAILED="+strconv.Itoa(len(summary.Failed)),
+	)
+	if runErr != nil {
+		env = append(env, "APKREGRESS_RUN_ERROR="+runErr.Error())
+	}
+	if err := r.runHook(HookPostRun, r.hooks.PostRun, hookLogFile, env, summary); err != nil {
+		fmt.Printf("Warning: post-run hook failed: %v\n", err)
+	}
+}
+
+// runTestHook runs the pre-test or post-test hook of pkg, the latter with
+// the results of its tests
+func (r *RegressionTestRunner) runTestHook(hook, pkg string, results []TestResult) {
+	command := r.hooks.PreTest
+	if hook == HookPostTest {
+		command = r.hooks.PostTest
+	}
+	if command == "" {
+		return
+	}
+
+	data := hookTest{RunID: filepath.Base(r.logDir), Package: pkg, Results: results}
+	env := append(r.hookEnv(hook), "APKREGRESS_TEST_PACKAGE="+pkg)
+	if hook == HookPostTest {
+		data.Status = testedStatus(results)
+		env = append(env, "APKREGRESS_TEST_STATUS="+data.Status)
+	}
+	if err := r.runHook(hook, command, pkg+"_hooks.log", env, data); err != nil {
+		r.printResult("Warning: %s hook of %s failed: %v\n", hook, pkg, err)
+	}
+}
+
+// testedStatus returns the outcome of the tests of a package, see
+// packageStatus
+func testedStatus(results []TestResult) string {
+	var withRepo, withoutRepo *TestResult
+	for i := range results {
+		switch {
+		case results[i].WithRepo && withRepo == nil:
+			withRepo = &results[i]
+		case !results[i].WithRepo && withoutRepo == nil:
+			withoutRepo = &results[i]
+		}
+	}
+	if withRepo == nil {
+		return ""
+	}
+	return packageStatus(*withRepo, withoutRepo)
+}
+
+// runHook runs the command of hook with env added to the environment and
+// input encoded as JSON on stdin, logging its output to logFile in the log
+// directory
+func (r *RegressionTestRunner) runHook(hook, command, logFile string, env []string, input interface{}) error {
+	data, err := json.Marshal(input)
+	if err != nil {
+		return fmt.Errorf("failed to encode hook input: %w", err)
+	}
+
+	logPath := filepath.Join(r.logDir, logFile)
+	log, err := os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open hook log: %w", err)
+	}
+	defer log.Close()
+	fmt.Fprintf(log, "=== %s: %s ===\n", hook, command)
+
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdin = bytes.NewReader(data)
+	cmd.Stdout = log
+	cmd.Stderr = log
+	if err := startInProcessGroup(cmd); err != nil {
+		return fmt.Errorf("failed to start %q: %w", command, err)
+	}
+	defer trackTest(cmd)()
+	if err := waitWithTimeout(context.Background(), cmd, hookTimeout); err != nil {
+		if errors.Is(err, ErrTestHung) {
+			return fmt.Errorf("%q killed after %v, see %s", command, hookTimeout, logPath)
+		}
+		return fmt.Errorf("%q: %w, see %s", command, err, logPath)
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunnerHooks(t *testing.T) {
+	repoPath := t.TempDir()
+	for _, pkg := range []string{"a", "b"} {
+		if err := os.WriteFile(filepath.Join(repoPath, pkg+".yaml"), []byte("package:\n  name: "+pkg+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	out := t.TempDir()
+
+	runner := NewRegressionTestRunner("", "https://example.com/repo", repoPath, "wolfi", 1, false, time.Minute, false)
+	runner.setLogDir(t.TempDir())
+	runner.SetHooks(Hooks{
+		PreRun:   "cat > " + out + "/pre-run.json",
+		PostRun:  `echo "$APKREGRESS_REGRESSIONS" > ` + out + "/post-run",
+		PreTest:  `echo "$APKREGRESS_HOOK" > ` + out + `/"$APKREGRESS_TEST_PACKAGE".pre`,
+		PostTest: `echo "$APKREGRESS_TEST_STATUS" > ` + out + `/"$APKREGRESS_TEST_PACKAGE".post; cat > ` + out + `/"$APKREGRESS_TEST_PACKAGE".json`,
+	})
+	runner.SetExecutor(TestExecutorFunc(func(ctx context.Context, pkg string, opts ExecuteOptions) TestResult {
+		var err error
+		if pkg == "b" && opts.WithRepo {
+			err = errors.New("test failed")
+		}
+		return newTestResult(pkg, opts.WithRepo, time.Now(), "", err)
+	}))
+
+	if err := runner.RunFromPackageList([]string{"a", "b"}); err == nil {
+		t.Fatalf("Expected the regression of b to fail the run")
+	}
+
+	read := func(name string) string {
+		data, err := os.ReadFile(filepath.Join(out, name))
+		if err != nil {
+			t.Errorf("Expected the hook to write %s: %v", name, err)
+		}
+		return strings.TrimSpace(string(data))
+	}
+
+	var manifest RunManifest
+	if err := json.Unmarshal([]byte(read("pre-run.json")), &manifest); err != nil || len(manifest.Packages) != 2 {
+		t.Errorf("Expected the pre-run hook to get the manifest, got %+v (%v)", manifest, err)
+	}
+	if got := read("post-run"); got != "1" {
+		t.Errorf("Expected 1 regression in the post-run hook, got %q", got)
+	}
+	if got := read("a.pre"); got != HookPreTest {
+		t.Errorf("Expected %s, got %q", HookPreTest, got)
+	}
+	for pkg, status := range map[string]string{"a": StatusPass, "b": StatusRegression} {
+		if got := read(pkg + ".post"); got != status {
+			t.Errorf("Expected status %s of %s, got %q", status, pkg, got)
+		}
+	}
+
+	var test struct {
+		Package string
+		Results []json.RawMessage
+	}
+	if err := json.Unmarshal([]byte(read("b.json")), &test); err != nil || test.Package != "b" || len(test.Results) != 2 {
+		t.Errorf("Expected the post-test hook to get both results of b, got %+v (%v)", test, err)
+	}
+	if _, err := os.Stat(filepath.Join(runner.logDir, hookLogFile)); err != nil {
+		t.Errorf("Expected the run hooks to log to %s: %v", hookLogFile, err)
+	}
+}
+
+func TestRunnerPreRunHookFails(t *testing.T) {
+	repoPath := t.TempDir()
+	if err := os.WriteFile(filepath.Join(repoPath, "a.yaml"), []byte("package:\n  name: a\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	runner := NewRegressionTestRunner("", "https://example.com/repo", repoPath, "wolfi", 1, false, time.Minute, false)
+	runner.setLogDir(t.TempDir())
+	runner.SetHooks(Hooks{PreRun: "echo no cache; exit 3"})
+	tested := false
+	runner.SetExecutor(TestExecutorFunc(func(ctx context.Context, pkg string, opts ExecuteOptions) TestResult {
+		tested = true
+		return newTestResult(pkg, opts.WithRepo, time.Now(), "", nil)
+	}))
+
+	err := runner.RunFromPackageList([]string{"a"})
+	if err == nil || !strings.Contains(err.Error(), "pre-run hook failed") {
+		t.Fatalf("Expected the pre-run hook to fail the run, got %v", err)
+	}
+	if tested {
+		t.Errorf("Expected nothing to be tested after the pre-run hook failed")
+	}
+	log, _ := os.ReadFile(filepath.Join(runner.logDir, hookLogFile))
+	if !strings.Contains(string(log), "no cache") {
+		t.Errorf("Expected the hook's output in %s, got %q", hookLogFile, log)
+	}
+}
@@ -0,0 +1,135 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"fmt"
+	"strings"
+)
+
+// indexBuilder collects the packages of an index as they are read. Only the
+// newest version of each package is kept, like apkrane ls --latest, and the
+// strings repeated across packages, such as dependencies and origins, are
+// stored once, so that memory grows with the number of distinct packages
+// rather than with the size of the index, which lists every version.
+type indexBuilder struct {
+	packages []Package
+	// positions are the positions of the packages in packages, by name
+	positions map[string]int
+	interned  map[string]string
+}
+
+func newIndexBuilder() *indexBuilder {
+	return &indexBuilder{positions: make(map[string]int), interned: make(map[string]string)}
+}
+
+// intern returns the stored copy of s
+func (b *indexBuilder) intern(s string) string {
+	if interned, ok := b.interned[s]; ok {
+		return interned
+	}
+	b.interned[s] = s
+	return s
+}
+
+// add adds pkg, unless a newer version of it was added already
+func (b *indexBuilder) add(pkg Package) {
+	i, ok := b.positions[pkg.Name]
+	if ok && compareAPKVersions(pkg.Version, b.packages[i].Version) <= 0 {
+		return
+	}
+
+	pkg.Origin = b.intern(pkg.Origin)
+	if len(pkg.Dependencies) > 0 {
+		deps := make([]string, len(pkg.Dependencies))
+		for j, dep := range pkg.Dependencies {
+			deps[j] = b.intern(dep)
+		}
+		pkg.Dependencies = deps
+	}
+	if ok {
+		b.packages[i] = pkg
+		return
+	}
+	b.positions[pkg.Name] = len(b.packages)
+	b.packages = append(b.packages, pkg)
+}
+
+// addEntry adds a package of an APKINDEX (see add)
+func (b *indexBuilder) addEntry(entry apkIndexEntry) {
+	b.add(Package{
+		Name:         entry.Name,
+		Version:      entry.Version,
+		Origin:       entry.Origin,
+		Dependencies: entry.Dependencies,
+	})
+}
+
+// latestPackages returns the newest version of each package of an index,
+// like apkrane ls --latest
+func latestPackages(entries []apkIndexEntry) []Package {
+	b := newIndexBuilder()
+	for _, entry := range entries {
+		b.addEntry(entry)
+	}
+	return b.packages
+}
+
+// reverseDependencies maps each dependency in the index to the origins of
+// the packages depending on it. It is built once per index, and stored in
+// the index cache by index digest, so that finding the consumers of a
+// package scans the distinct dependencies rather than those of every
+// package.
+func (a *ApkraneClient) reverseDependencies() (map[string][]string, error) {
+	packages, err := a.loadIndex()
+	if err != nil {
+		return nil, err
+	}
+	if a.consumers != nil {
+		return a.consumers, nil
+	}
+	if a.cache != nil {
+		if consumers, ok := a.cache.LoadReverseDependencies(a.indexDigest); ok {
+			if a.verbose {
+				fmt.Printf("Using cached reverse dependencies for %s\n", a.indexDigest)
+			}
+			a.consumers = consumers
+			return consumers, nil
+		}
+	}
+
+	consumers := make(map[string][]string)
+	for _, pkg := range packages {
+		if pkg.Origin == "" {
+			continue
+		}
+		for _, dep := range pkg.Dependencies {
+			origins := consumers[dep]
+			// The subpackages of an origin are listed together
+			if len(origins) > 0 && origins[len(origins)-1] == pkg.Origin {
+				continue
+			}
+			consumers[dep] = append(origins, pkg.Origin)
+		}
+	}
+	if a.cache != nil {
+		if err := a.cache.StoreReverseDependencies(a.indexDigest, consumers); err != nil && a.verbose {
+			fmt.Printf("Warning: failed to cache reverse dependencies: %v\n", err)
+		}
+	}
+	a.consumers = consumers
+	return consumers, nil
+}
+
+// consumersOf returns the origins of the packages with a dependency
+// containing packageName, unsorted and possibly repeated
+func consumersOf(consumers map[string][]string, packageName string) []string {
+	var origins []string
+	for dep, depOrigins := range consumers {
+		if strings.Contains(dep, packageName) {
+			origins = append(origins, depOrigins...)
+		}
+	}
+	return origins
+}
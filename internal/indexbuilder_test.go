@@ -0,0 +1,95 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"os/exec"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"unsafe"
+)
+
+func TestIndexBuilderInterns(t *testing.T) {
+	b := newIndexBuilder()
+	b.add(Package{Name: "curl", Version: "8.10.1-r0", Origin: "curl", Dependencies: []string{string([]byte("so:libc.so.6"))}})
+	b.add(Package{Name: "wget", Version: "1.25.0-r0", Origin: "wget", Dependencies: []string{string([]byte("so:libc.so.6"))}})
+
+	curl, wget := b.packages[0].Dependencies[0], b.packages[1].Dependencies[0]
+	if unsafe.StringData(curl) != unsafe.StringData(wget) {
+		t.Errorf("Expected the dependency shared by curl and wget to be stored once")
+	}
+}
+
+func TestScanAPKIndex(t *testing.T) {
+	index := "P:curl\nV:8.9.0-r0\no:curl\nD:so:libc.so.6\n\nP:curl\nV:8.10.1-r0\no:curl\nD:so:libc.so.6 so:libssl.so.3\n\nP:libcurl-openssl4\nV:8.10.1-r0\no:curl\n"
+
+	b := newIndexBuilder()
+	sig, signed, err := scanAPKIndex(gzipTar(t, "APKINDEX", []byte(index)), b.addEntry)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if sig != nil || signed != nil {
+		t.Errorf("Expected an unsigned index, got %v", sig)
+	}
+	expected := []Package{
+		{Name: "curl", Version: "8.10.1-r0", Origin: "curl", Dependencies: []string{"so:libc.so.6", "so:libssl.so.3"}},
+		{Name: "libcurl-openssl4", Version: "8.10.1-r0", Origin: "curl"},
+	}
+	if !reflect.DeepEqual(b.packages, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, b.packages)
+	}
+}
+
+func TestReverseDependencies(t *testing.T) {
+	client := NewApkraneClient(false, "wolfi")
+	client.packages = []Package{
+		{Name: "curl", Origin: "curl", Dependencies: []string{"so:libssl.so.3", "so:libc.so.6"}},
+		{Name: "curl-dev", Origin: "curl", Dependencies: []string{"so:libssl.so.3"}},
+		{Name: "wget", Origin: "wget", Dependencies: []string{"openssl>3"}},
+		{Name: "bash", Origin: "bash", Dependencies: []string{"so:libc.so.6"}},
+	}
+
+	consumers, err := client.reverseDependencies()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(consumers["so:libssl.so.3"], []string{"curl"}) {
+		t.Errorf("Expected curl once for so:libssl.so.3, got %v", consumers["so:libssl.so.3"])
+	}
+
+	origins, err := client.GetReverseDependencies("ssl")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	sort.Strings(origins)
+	if !reflect.DeepEqual(origins, []string{"curl", "wget"}) {
+		t.Errorf("Expected curl and wget, got %v", origins)
+	}
+}
+
+func TestReadApkraneOutput(t *testing.T) {
+	client := NewApkraneClient(false, "wolfi")
+	output := `{"Name":"curl","Version":"8.10.1-r0","Origin":"curl","Dependencies":["so:libc.so.6"]}\n\nnot json\n{"Name":"wget","Version":"1.25.0-r0","Origin":"wget"}\n`
+
+	packages, digest, err := client.readApkraneOutput(exec.Command("printf", output))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.HasPrefix(digest, "sha256:") {
+		t.Errorf("Expected a sha256 digest of the output, got %q", digest)
+	}
+	expected := []Package{
+		{Name: "curl", Version: "8.10.1-r0", Origin: "curl", Dependencies: []string{"so:libc.so.6"}},
+		{Name: "wget", Version: "1.25.0-r0", Origin: "wget"},
+	}
+	if !reflect.DeepEqual(packages, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, packages)
+	}
+
+	if _, _, err := client.readApkraneOutput(exec.Command("sh", "-c", "echo unauthorized >&2; exit 1")); err == nil {
+		t.Errorf("Expected a failing apkrane to fail")
+	}
+}
@@ -0,0 +1,212 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// IndexCache stores parsed package indexes on disk, keyed by index URL and
+// validated against the server's ETag/Last-Modified headers, so unchanged
+// indexes aren't downloaded and parsed again on every run. The
+// reverse-dependency maps computed from them are stored by index digest.
+type IndexCache struct {
+	dir string
+	// rdepsDir holds the reverse-dependency maps
+	rdepsDir string
+}
+
+// indexValidators identifies a specific revision of a remote index
+type indexValidators struct {
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"lastModified,omitempty"`
+}
+
+func (v indexValidators) empty() bool {
+	return v.ETag == "" && v.LastModified == ""
+}
+
+type cachedIndex struct {
+	URL        string          `json:"url"`
+	Validators indexValidators `json:"validators"`
+	FetchedAt  time.Time       `json:"fetchedAt"`
+	Packages   []Package       `json:"packages"`
+	// Digest is the digest of the index, which keys its reverse-dependency
+	// map, if it is known
+	Digest string `json:"digest,omitempty"`
+}
+
+// cachedReverseDependencies is a reverse-dependency map stored by the
+// digest of its index
+type cachedReverseDependencies struct {
+	Digest    string              `json:"digest"`
+	Consumers map[string][]string `json:"consumers"`
+}
+
+// NewIndexCache returns a cache rooted at dir. An empty dir selects the
+// user's cache directory.
+func NewIndexCache(dir string) (*IndexCache, error) {
+	if dir == "" {
+		userCacheDir, err := os.UserCacheDir()
+		if err != nil {
+			return nil, fmt.Errorf("failed to determine cache directory: %w", err)
+		}
+		dir = filepath.Join(userCacheDir, "apkregress")
+	}
+
+	return &IndexCache{dir: filepath.Join(dir, "index"), rdepsDir: filepath.Join(dir, "rdeps")}, nil
+}
+
+func (c *IndexCache) path(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:])+".json")
+}
+
+// Load returns the cached packages for url if they were fetched at the given
+// revision. Indexes without validators are never served from the cache.
+func (c *IndexCache) Load(url string, validators indexValidators) ([]Package, bool) {
+	cached, ok := c.load(url, validators)
+	if !ok {
+		return nil, false
+	}
+	return cached.Packages, true
+}
+
+func (c *IndexCache) load(url string, validators indexValidators) (*cachedIndex, bool) {
+	if validators.empty() {
+		return nil, false
+	}
+
+	data, err := os.ReadFile(c.path(url))
+	if err != nil {
+		return nil, false
+	}
+
+	var cached cachedIndex
+	if err := json.Unmarshal(data, &cached); err != nil {
+		return nil, false
+	}
+
+	if cached.URL != url || cached.Validators != validators {
+		return nil, false
+	}
+
+	return &cached, true
+}
+
+// Store records the packages parsed from url at the given revision
+func (c *IndexCache) Store(url string, validators indexValidators, packages []Package) error {
+	return c.store(url, validators, "", packages)
+}
+
+func (c *IndexCache) store(url string, validators indexValidators, digest string, packages []Package) error {
+	if validators.empty() {
+		return nil
+	}
+
+	data, err := json.Marshal(cachedIndex{
+		URL:        url,
+		Validators: validators,
+		FetchedAt:  time.Now(),
+		Packages:   packages,
+		Digest:     digest,
+	})
+	if err != nil {
+		return err
+	}
+	return writeCacheFile(c.dir, c.path(url), data)
+}
+
+func (c *IndexCache) rdepsPath(digest string) string {
+	return filepath.Join(c.rdepsDir, strings.ReplaceAll(digest, ":", "-")+".json")
+}
+
+// LoadReverseDependencies returns the reverse-dependency map of the index
+// with the given digest, if it was stored
+func (c *IndexCache) LoadReverseDependencies(digest string) (map[string][]string, bool) {
+	if digest == "" {
+		return nil, false
+	}
+	data, err := os.ReadFile(c.rdepsPath(digest))
+	if err != nil {
+		return nil, false
+	}
+
+	var cached cachedReverseDependencies
+	if err := json.Unmarshal(data, &cached); err != nil || cached.Digest != digest || cached.Consumers == nil {
+		return nil, false
+	}
+	return cached.Consumers, true
+}
+
+// StoreReverseDependencies records the reverse-dependency map of the index
+// with the given digest
+func (c *IndexCache) StoreReverseDependencies(digest string, consumers map[string][]string) error {
+	if digest == "" {
+		return nil
+	}
+	data, err := json.Marshal(cachedReverseDependencies{Digest: digest, Consumers: consumers})
+	if err != nil {
+		return err
+	}
+	return writeCacheFile(c.rdepsDir, c.rdepsPath(digest), data)
+}
+
+// writeCacheFile writes a file of the cache in dir, through a temporary file
+// so concurrent runs never read a partial one
+func writeCacheFile(dir, path string, data []byte) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create index cache directory: %w", err)
+	}
+
+	tmpFile, err := os.CreateTemp(dir, "index-*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write index cache: %w", err)
+	}
+	defer os.Remove(tmpFile.Name())
+
+	if _, err := tmpFile.Write(data); err != nil {
+		tmpFile.Close()
+		return fmt.Errorf("failed to write index cache: %w", err)
+	}
+	if err := tmpFile.Close(); err != nil {
+		return fmt.Errorf("failed to write index cache: %w", err)
+	}
+
+	return os.Rename(tmpFile.Name(), path)
+}
+
+// fetchIndexValidators issues a HEAD request for url and returns the
+// revision identifiers the server reports for it.
+func fetchIndexValidators(url string, auth basicAuth) (indexValidators, error) {
+	req, err := http.NewRequest(http.MethodHead, url, nil)
+	if err != nil {
+		return indexValidators{}, err
+	}
+	auth.apply(req)
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return indexValidators{}, err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return indexValidators{}, fmt.Errorf("HEAD %s returned %s", url, resp.Status)
+	}
+
+	return indexValidators{
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+	}, nil
+}
@@ -0,0 +1,142 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestIndexCacheRoundTrip(t *testing.T) {
+	cache, err := NewIndexCache(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+
+	url := "https://packages.wolfi.dev/os/x86_64/APKINDEX.tar.gz"
+	validators := indexValidators{ETag: `"abc123"`}
+	packages := []Package{
+		{Name: "curl", Origin: "curl", Dependencies: []string{"so:libcurl.so.4"}},
+	}
+
+	if _, ok := cache.Load(url, validators); ok {
+		t.Error("Expected cache miss before storing")
+	}
+
+	if err := cache.Store(url, validators, packages); err != nil {
+		t.Fatalf("Failed to store index: %v", err)
+	}
+
+	cached, ok := cache.Load(url, validators)
+	if !ok {
+		t.Fatal("Expected cache hit after storing")
+	}
+	if !reflect.DeepEqual(cached, packages) {
+		t.Errorf("Expected %v, got %v", packages, cached)
+	}
+
+	if _, ok := cache.Load(url, indexValidators{ETag: `"def456"`}); ok {
+		t.Error("Expected cache miss for a changed ETag")
+	}
+
+	if _, ok := cache.Load(url, indexValidators{}); ok {
+		t.Error("Expected cache miss without validators")
+	}
+
+	if _, ok := cache.Load("https://example.com/APKINDEX.tar.gz", validators); ok {
+		t.Error("Expected cache miss for a different URL")
+	}
+}
+
+func TestIndexCacheStoreWithoutValidators(t *testing.T) {
+	cache, err := NewIndexCache(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+
+	if err := cache.Store("https://example.com/APKINDEX.tar.gz", indexValidators{}, []Package{{Name: "foo"}}); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+}
+
+func TestReverseDependencyCache(t *testing.T) {
+	cache, err := NewIndexCache(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	digest := "sha256:0123abcd"
+	packages := []Package{{Name: "curl", Origin: "curl", Dependencies: []string{"so:libssl.so.3"}}}
+
+	client := NewApkraneClient(false, "wolfi")
+	client.SetIndexCache(cache)
+	client.packages, client.indexDigest = packages, digest
+	if _, err := client.reverseDependencies(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	cached, ok := cache.LoadReverseDependencies(digest)
+	if !ok || !reflect.DeepEqual(cached, map[string][]string{"so:libssl.so.3": {"curl"}}) {
+		t.Fatalf("Expected the map to be cached by digest, got %v", cached)
+	}
+
+	// A later run against the same index uses the cached map
+	cache.StoreReverseDependencies(digest, map[string][]string{"so:libssl.so.3": {"cached"}})
+	client = NewApkraneClient(false, "wolfi")
+	client.SetIndexCache(cache)
+	client.packages, client.indexDigest = packages, digest
+	if origins, err := client.GetReverseDependencies("libssl"); err != nil || !reflect.DeepEqual(origins, []string{"cached"}) {
+		t.Errorf("Expected the cached consumers, got %v (%v)", origins, err)
+	}
+
+	if _, ok := cache.LoadReverseDependencies("sha256:ffff"); ok {
+		t.Error("Expected cache miss for a different digest")
+	}
+	if _, ok := cache.LoadReverseDependencies(""); ok {
+		t.Error("Expected cache miss without a digest")
+	}
+}
+
+func TestIndexCacheDigest(t *testing.T) {
+	cache, err := NewIndexCache(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	url := "https://packages.wolfi.dev/os/x86_64/APKINDEX.tar.gz"
+	validators := indexValidators{ETag: `"abc123"`}
+	if err := cache.store(url, validators, "sha256:0123abcd", []Package{{Name: "curl"}}); err != nil {
+		t.Fatalf("Failed to store index: %v", err)
+	}
+	cached, ok := cache.load(url, validators)
+	if !ok || cached.Digest != "sha256:0123abcd" {
+		t.Errorf("Expected the digest to be cached with the index, got %+v", cached)
+	}
+}
+
+func TestFetchIndexValidators(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodHead {
+			t.Errorf("Expected HEAD request, got %s", r.Method)
+		}
+		if user, pass, ok := r.BasicAuth(); !ok || user != "user" || pass != "secret" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Last-Modified", "Mon, 02 Jan 2006 15:04:05 GMT")
+	}))
+	defer server.Close()
+
+	validators, err := fetchIndexValidators(server.URL, basicAuth{Username: "user", Password: "secret"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if validators.ETag != `"v1"` || validators.LastModified != "Mon, 02 Jan 2006 15:04:05 GMT" {
+		t.Errorf("Unexpected validators: %+v", validators)
+	}
+
+	if _, err := fetchIndexValidators(server.URL, basicAuth{}); err == nil {
+		t.Error("Expected error for unauthorized request")
+	}
+}
@@ -0,0 +1,89 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"fmt"
+	"sync"
+)
+
+// candidateIndexes are the candidate repository indexes parsed by this
+// process, by location, so that validating the repository and correlating
+// advisories share one download. Failures aren't kept, so a later stage
+// tries again.
+var candidateIndexes = struct {
+	sync.Mutex
+	entries map[string]*candidateIndexEntry
+}{entries: make(map[string]*candidateIndexEntry)}
+
+type candidateIndexEntry struct {
+	mu    sync.Mutex
+	index *apkIndex
+}
+
+// loadCandidateIndex fetches and parses the index at location once.
+// Concurrent callers for the same location wait for a single fetch.
+func loadCandidateIndex(location string) (*apkIndex, error) {
+	candidateIndexes.Lock()
+	entry, ok := candidateIndexes.entries[location]
+	if !ok {
+		entry = &candidateIndexEntry{}
+		candidateIndexes.entries[location] = entry
+	}
+	candidateIndexes.Unlock()
+
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
+	if entry.index != nil {
+		return entry.index, nil
+	}
+
+	data, err := fetchCandidateIndex(location)
+	if err != nil {
+		return nil, err
+	}
+	index, err := parseAPKIndex(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid index %s: %w", location, err)
+	}
+	entry.index = index
+	return index, nil
+}
+
+// PrefetchIndexes loads the package index of each runner's repository type
+// and the candidate repository indexes concurrently, so that startup time
+// stays flat as the number of repository types grows. Errors are left to the
+// stage that needs the index, which loads it again and reports them.
+func PrefetchIndexes(runners []*RegressionTestRunner) {
+	var wg sync.WaitGroup
+	clients := make(map[*ApkraneClient]bool)
+	candidates := make(map[string]bool)
+
+	for _, runner := range runners {
+		if client := runner.apkrane; client != nil && !clients[client] {
+			clients[client] = true
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				client.loadIndex()
+			}()
+		}
+
+		if runner.apkRepo == "" {
+			continue
+		}
+		location := candidateIndexURL(runner.apkRepo, hostArch())
+		if candidates[location] {
+			continue
+		}
+		candidates[location] = true
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			loadCandidateIndex(location)
+		}()
+	}
+
+	wg.Wait()
+}
@@ -0,0 +1,82 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestLoadCandidateIndex(t *testing.T) {
+	data := gzipTar(t, "APKINDEX", []byte(testAPKIndex))
+
+	var requests int64
+	available := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&requests, 1)
+		if !available {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(data)
+	}))
+	defer server.Close()
+
+	location := server.URL + "/os/" + hostArch() + "/APKINDEX.tar.gz"
+
+	// Failures aren't remembered
+	if _, err := loadCandidateIndex(location); err == nil {
+		t.Error("Expected an error for a missing index")
+	}
+	available = true
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			index, err := loadCandidateIndex(location)
+			if err != nil || len(index.Packages) != 2 {
+				t.Errorf("Unexpected index %v (%v)", index, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := atomic.LoadInt64(&requests); n != 2 {
+		t.Errorf("Expected 2 requests, got %d", n)
+	}
+}
+
+func TestPrefetchIndexes(t *testing.T) {
+	data := gzipTar(t, "APKINDEX", []byte(testAPKIndex))
+
+	var requests int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&requests, 1)
+		w.Write(data)
+	}))
+	defer server.Close()
+
+	// Runners of different repository types share the candidate repository
+	runners := []*RegressionTestRunner{
+		{apkRepo: server.URL + "/prefetch", repoType: "wolfi"},
+		{apkRepo: server.URL + "/prefetch", repoType: "enterprise"},
+	}
+	PrefetchIndexes(runners)
+
+	if n := atomic.LoadInt64(&requests); n != 1 {
+		t.Errorf("Expected 1 request, got %d", n)
+	}
+
+	if err := ValidateCandidateRepo(server.URL+"/prefetch", nil, "libcrypto3", "", false); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if n := atomic.LoadInt64(&requests); n != 1 {
+		t.Errorf("Expected validation to use the prefetched index, got %d requests", n)
+	}
+}
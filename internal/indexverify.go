@@ -0,0 +1,102 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// DefaultIndexKeys returns the keys the package index of repoType is signed
+// with, or nil if they aren't known
+func DefaultIndexKeys(repoType string) []string {
+	t, _ := LookupRepoType(repoType)
+	return t.Keys
+}
+
+// SetIndexVerification makes the client check the signature of the package
+// index against keys (files or URLs), or the known keys of its repository
+// type if none are given, and fail if it doesn't verify
+func (a *ApkraneClient) SetIndexVerification(keys []string) {
+	a.verifyIndex = true
+	a.indexKeys = keys
+}
+
+// SetIndexVerification verifies the signature of the package index the
+// reverse dependencies are found in (see ApkraneClient.SetIndexVerification)
+func (r *RegressionTestRunner) SetIndexVerification(keys []string) {
+	if r.apkrane != nil {
+		r.apkrane.SetIndexVerification(keys)
+	}
+}
+
+// loadVerifiedIndex downloads the index and reads the packages from it
+// rather than with apkrane, so that the packages are exactly those whose
+// signature verified. The index cache is bypassed, since it keeps the
+// packages but not the signature, but the reverse-dependency map of an
+// index with the same digest is reused.
+func (a *ApkraneClient) loadVerifiedIndex(indexURL string, auth basicAuth) ([]Package, error) {
+	keys := a.indexKeys
+	if len(keys) == 0 {
+		keys = DefaultIndexKeys(a.repoType)
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("%w: no signing key is known for the %s index; pass --index-key", ErrIndexSignature, a.repoTypeName())
+	}
+
+	data, err := fetchIndex(indexURL, auth)
+	if err != nil {
+		return nil, err
+	}
+	builder := newIndexBuilder()
+	sig, signed, err := scanAPKIndex(data, builder.addEntry)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid index %s: %w", ErrIndexFetch, indexURL, err)
+	}
+	if err := verifyAPKIndex(&apkIndex{Signature: sig, Signed: signed}, keys); err != nil {
+		return nil, fmt.Errorf("%w: index %s: %w", ErrIndexSignature, indexURL, err)
+	}
+	if a.verbose {
+		fmt.Printf("Index %s is signed with %s\n", indexURL, sig.KeyName)
+	}
+
+	packages := builder.packages
+	a.indexURL = indexURL
+	a.indexDigest = indexDigest(data)
+	a.packages = packages
+	return packages, nil
+}
+
+// fetchIndex downloads the index at indexURL
+func fetchIndex(indexURL string, auth basicAuth) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, indexURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	auth.apply(req)
+
+	client := &http.Client{Timeout: 5 * time.Minute}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch index %s: %w", indexURL, fetchError(err))
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return nil, fmt.Errorf("%w: index %s: %s", ErrAuth, indexURL, resp.Status)
+	default:
+		return nil, fmt.Errorf("%w: index %s: %s", ErrIndexFetch, indexURL, resp.Status)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to read index %s: %w", ErrIndexFetch, indexURL, err)
+	}
+	recordIndexDigest(indexURL, data)
+	return data, nil
+}
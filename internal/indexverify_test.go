@@ -0,0 +1,95 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLoadVerifiedIndex(t *testing.T) {
+	dir := t.TempDir()
+	data := signedIndex(t, dir, "test.rsa.pub")
+	key, err := os.ReadFile(filepath.Join(dir, "test.rsa.pub"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/os/APKINDEX.tar.gz":
+			w.Write(data)
+		case "/keys/test.rsa.pub":
+			w.Write(key)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	other := t.TempDir()
+	signedIndex(t, other, "test.rsa.pub")
+
+	tests := []struct {
+		name        string
+		repoType    string
+		keys        []string
+		expectedErr error
+	}{
+		{"key file", "wolfi", []string{filepath.Join(dir, "test.rsa.pub")}, nil},
+		{"key URL", "wolfi", []string{server.URL + "/keys/test.rsa.pub"}, nil},
+		{"different key material", "wolfi", []string{filepath.Join(other, "test.rsa.pub")}, ErrIndexSignature},
+		{"untrusted key", "wolfi", []string{filepath.Join(dir, "other.rsa.pub")}, ErrIndexSignature},
+		{"no known key", "enterprise", nil, ErrIndexSignature},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := NewApkraneClient(false, tt.repoType)
+			client.SetIndexVerification(tt.keys)
+
+			packages, err := client.loadVerifiedIndex(server.URL+"/os/APKINDEX.tar.gz", basicAuth{})
+			if tt.expectedErr != nil {
+				if !errors.Is(err, tt.expectedErr) {
+					t.Errorf("Expected %v, got %v", tt.expectedErr, err)
+				}
+				if client.packages != nil {
+					t.Errorf("Expected no packages from an index that doesn't verify, got %v", client.packages)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected the index to verify, got %v", err)
+			}
+			expected := []Package{
+				{Name: "openssl", Version: "3.3.2-r1", Origin: "openssl"},
+				{Name: "libcrypto3", Version: "3.3.2-r1", Origin: "openssl"},
+			}
+			if !reflect.DeepEqual(packages, expected) {
+				t.Errorf("Expected %+v, got %+v", expected, packages)
+			}
+		})
+	}
+}
+
+func TestLatestPackages(t *testing.T) {
+	entries := []apkIndexEntry{
+		{Name: "curl", Version: "8.9.0-r0", Origin: "curl"},
+		{Name: "openssl", Version: "3.3.2-r1", Origin: "openssl"},
+		{Name: "curl", Version: "8.10.1-r0", Origin: "curl", Dependencies: []string{"openssl>3"}},
+		{Name: "curl", Version: "8.9.1-r2", Origin: "curl"},
+	}
+	expected := []Package{
+		{Name: "curl", Version: "8.10.1-r0", Origin: "curl", Dependencies: []string{"openssl>3"}},
+		{Name: "openssl", Version: "3.3.2-r1", Origin: "openssl"},
+	}
+	if got := latestPackages(entries); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, got)
+	}
+}
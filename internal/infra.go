@@ -0,0 +1,319 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// infraPatterns recognize failures of the machine running the tests rather
+// than of the tests, most specific first. Messages a test may print itself,
+// e.g. when it checks how a program handles a failed DNS lookup, only count
+// in the output of docker or of fetching the repositories.
+var infraPatterns = []struct {
+	signature string
+	pattern   *regexp.Regexp
+}{
+	{"docker daemon unreachable", regexp.MustCompile(`(?i)cannot connect to the docker daemon|is the docker daemon running|(^|docker: )error during connect: `)},
+	{"docker daemon error", regexp.MustCompile(`(?i)error response from daemon`)},
+	{"runner crash", regexp.MustCompile(`(?i)oci runtime (create|exec|start) failed|failed to create shim task|bwrap: .*(creating new namespace|no permissions)`)},
+	{oomSignature, regexp.MustCompile(`(?i)out of memory: kill(ed)? process|oom-kill|memory cgroup out of memory`)},
+	{"network down", regexp.MustCompile(`(?i)network is unreachable|no route to host|(apkindex|error response from daemon|error pulling image|failed to (fetch|pull)).*temporary failure in name resolution`)},
+	{"disk full", regexp.MustCompile(`(?i)no space left on device|\bENOSPC\b`)},
+	{"qemu launch failure", regexp.MustCompile(`(?i)qemu.*(failed to|could not|cannot) (start|launch|initiali[sz]e|allocate)`)},
+}
+
+// Infrastructure failures within infraBurstWindow that make a run halve its
+// concurrency, and how often a package is re-queued after infrastructure
+// failures before it is left for the end of the run
+const (
+	infraBurst       = 3
+	infraBurstWindow = 10 * time.Minute
+	maxInfraRetries  = 2
+)
+
+// infraSignature returns the infrastructure failure a failed test ran into,
+// e.g. "disk full", or "" if it failed on its own. Exit codes alone don't
+// tell: docker run exits with 125 when the container can't be created, but
+// so may a test, and 137 is any SIGKILL, not only the kernel's when it runs
+// out of memory, which markInfra tells from OOMKilled.
+func infraSignature(result TestResult) string {
+	if result.Success || result.Skipped || result.Hung {
+		return ""
+	}
+	if result.Error != nil {
+		if signature := matchInfraPattern(result.Error.Error()); signature != "" {
+			return signature
+		}
+	}
+	if result.LogPath == "" {
+		return ""
+	}
+
+	file, err := os.Open(result.LogPath)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+	// Infrastructure failures end the log, so its tail tells
+	if info, err := file.Stat(); err == nil && info.Size() > 64*1024 {
+		file.Seek(-64*1024, io.SeekEnd)
+	}
+
+	var signature string
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if match := matchInfraPattern(scanner.Text()); match != "" {
+			signature = match
+		}
+	}
+	return signature
+}
+
+// markInfra records the infrastructure failure a test ran into, if any, so
+// that it isn't counted as a failure or regression. Tests of which the
+// kernel killed a process for lack of memory failed on the infrastructure
+// even if they hung rather than failed.
+func markInfra(result TestResult) TestResult {
+	signature := infraSignature(result)
+	if result.OOMKilled {
+		signature = oomSignature
+	}
+	if signature == "" {
+		return result
+	}
+	result.Infra = signature
+	result.OOMKilled = signature == oomSignature
+	result.Classification = classify(result)
+	return result
+}
+
+func matchInfraPattern(line string) string {
+	for _, p := range infraPatterns {
+		if p.pattern.MatchString(line) {
+			return p.signature
+		}
+	}
+	return ""
+}
+
+// infraResult returns the test of a package that failed on the
+// infrastructure, or nil
+func infraResult(withRepo, withoutRepo TestResult, hasWithoutRepo bool) *TestResult {
+	if withRepo.Infra != "" {
+		return &withRepo
+	}
+	if hasWithoutRepo && withoutRepo.Infra != "" {
+		return &withoutRepo
+	}
+	return nil
+}
+
+// concurrencyBackoff is a reduction of the concurrency of a run after a
+// burst of infrastructure failures
+type concurrencyBackoff struct {
+	At        time.Time `json:"at"`
+	Signature string    `json:"signature"`
+	From      int       `json:"from"`
+	To        int       `json:"to"`
+}
+
+// infraBackoff re-queues the packages whose tests failed on the
+// infrastructure and halves the concurrency of the run when such failures
+// come in bursts, since they typically stem from overloading the machine.
+// Packages that keep failing on the infrastructure are tested once more at
+// the end of the run.
+type infraBackoff struct {
+	mu          sync.Mutex
+	concurrency int
+	recent      []time.Time
+	retries     map[string]int
+	backoffs    []concurrencyBackoff
+	// withRepo are the with-repo results of the packages retried because
+	// their without-repo test failed on the infrastructure, which stand
+	withRepo map[string]TestResult
+	// deferred are the packages left for the end of the run, and final is
+	// set once they are tested, after which their results are reported
+	deferred []string
+	final    bool
+}
+
+func newInfraBackoff(concurrency int) *infraBackoff {
+	return &infraBackoff{concurrency: concurrency, retries: make(map[string]int), withRepo: make(map[string]TestResult)}
+}
+
+// takeWithRepo returns the with-repo result kept for pkg when its
+// without-repo test is retried, if any
+func (b *infraBackoff) takeWithRepo(pkg string) (TestResult, bool) {
+	if b == nil {
+		return TestResult{}, false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	result, ok := b.withRepo[pkg]
+	delete(b.withRepo, pkg)
+	return result, ok
+}
+
+// SetInfraBackoff re-queues packages whose tests fail with the signature of
+// an infrastructure failure, such as an unreachable docker daemon, a full
+// disk or qemu failing to launch, rather than recording them, halves the
+// concurrency after a burst of such failures and tests packages that keep
+// failing on the infrastructure once more at the end of the run. It is
+// enabled by default; disabled, such failures are reported as they are.
+func (r *RegressionTestRunner) SetInfraBackoff(enabled bool) {
+	r.noInfraBackoff = !enabled
+}
+
+// retryInfraFailure re-queues pkg if result failed on the infrastructure,
+// or leaves it for the end of the run once it was re-queued
+// maxInfraRetries times, reducing the concurrency of the run after a burst
+// of such failures. It reports whether the package will be tested again, in
+// which case result must not be reported. withRepo is the result of the
+// with-repo test when result is the without-repo one; the retry reuses it
+// rather than testing with the repository again.
+func (r *RegressionTestRunner) retryInfraFailure(pkg string, result TestResult, withRepo *TestResult) bool {
+	queue := r.activeQueue()
+	if r.infra == nil || queue == nil || result.Infra == "" {
+		return false
+	}
+
+	b := r.infra
+	b.mu.Lock()
+	if b.final {
+		b.mu.Unlock()
+		return false
+	}
+	if withRepo != nil {
+		b.withRepo[pkg] = *withRepo
+	}
+	requeue := b.retries[pkg] < maxInfraRetries
+	if requeue {
+		b.retries[pkg]++
+	} else {
+		b.deferred = append(b.deferred, pkg)
+	}
+
+	now := time.Now()
+	b.recent = append(b.recent, now)
+	for len(b.recent) > 0 && now.Sub(b.recent[0]) > infraBurstWindow {
+		b.recent = b.recent[1:]
+	}
+	var backoff *concurrencyBackoff
+	if len(b.recent) >= infraBurst && b.concurrency > 1 {
+		backoff = &concurrencyBackoff{At: now, Signature: result.Infra, From: b.concurrency, To: b.concurrency / 2}
+		b.concurrency = backoff.To
+		b.backoffs = append(b.backoffs, *backoff)
+		b.recent = nil
+	}
+	b.mu.Unlock()
+
+	if backoff != nil {
+		queue.setLimit(backoff.To)
+		r.printResult("⚠️  %d infrastructure failures (%s) within %v: reducing concurrency from %d to %d\n", infraBurst, result.Infra, infraBurstWindow, backoff.From, backoff.To)
+	}
+	r.eta.requeue(pkg)
+	if !requeue {
+		r.printResult("🔁 %s: infrastructure failure (%s), testing again at the end of the run\n", pkg, result.Infra)
+		return true
+	}
+	r.printResult("🔁 %s: infrastructure failure (%s), re-queued\n", pkg, result.Infra)
+	queue.requeue(pkg)
+	return true
+}
+
+// finalPass returns the packages left for the end of the run and the
+// concurrency to test them at. Their results are reported from then on.
+func (b *infraBackoff) finalPass() ([]string, int) {
+	if b == nil {
+		return nil, 0
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.final = true
+	return b.deferred, b.concurrency
+}
+
+// rerunInfraFailures tests the packages that kept failing on the
+// infrastructure once more, after all other tests finished. Whatever they
+// end with is reported.
+func (r *RegressionTestRunner) rerunInfraFailures(ctx context.Context, executor TestExecutor, results chan<- TestResult) {
+	packages, concurrency := r.infra.finalPass()
+	if len(packages) == 0 || ctx.Err() != nil {
+		return
+	}
+	r.printResult("🔁 Testing %d packages again that failed on the infrastructure: %s\n", len(packages), strings.Join(packages, ", "))
+	if concurrency > len(packages) {
+		concurrency = len(packages)
+	}
+	r.startWorkers(ctx, newPackageQueue(packages), concurrency, executor, results).Wait()
+}
+
+// results returns the concurrency reductions of the run
+func (b *infraBackoff) results() []concurrencyBackoff {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.backoffs
+}
+
+// printInfra writes the packages that failed on the infrastructure to the
+// text summary
+func printInfra(w io.Writer, infra []string) {
+	if len(infra) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\nFailed on the infrastructure (not counted as failures or regressions):\n")
+	for _, pkg := range infra {
+		fmt.Fprintf(w, "  - %s\n", pkg)
+	}
+}
+
+// printInfraMarkdown writes the packages that failed on the infrastructure
+// to the markdown summary
+func printInfraMarkdown(w io.Writer, infra []string) {
+	if len(infra) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\n### 🛠️ Infrastructure Failures\n\n")
+	fmt.Fprintf(w, "These packages failed on the infrastructure rather than on their own, so they are counted neither as failures nor as regressions:\n\n")
+	for _, pkg := range infra {
+		fmt.Fprintf(w, "- `%s`\n", pkg)
+	}
+}
+
+// printBackoffs writes the concurrency reductions to the text summary
+func printBackoffs(w io.Writer, backoffs []concurrencyBackoff) {
+	if len(backoffs) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\nConcurrency reduced after infrastructure failures:\n")
+	for _, b := range backoffs {
+		fmt.Fprintf(w, "  - %s: %d → %d (%s)\n", b.At.Format(time.TimeOnly), b.From, b.To, b.Signature)
+	}
+}
+
+// printBackoffsMarkdown writes the concurrency reductions to the markdown
+// summary
+func printBackoffsMarkdown(w io.Writer, backoffs []concurrencyBackoff) {
+	if len(backoffs) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\n### ⚠️ Concurrency Reduced\n\n")
+	fmt.Fprintf(w, "Tests failed on the infrastructure rather than on their own, so the affected packages were tested again at a lower concurrency:\n\n")
+	for _, b := range backoffs {
+		fmt.Fprintf(w, "- %s: %d → %d (%s)\n", b.At.Format(time.TimeOnly), b.From, b.To, b.Signature)
+	}
+}
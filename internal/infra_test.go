@@ -0,0 +1,225 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestInfraSignature(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name     string
+		result   TestResult
+		log      string
+		expected string
+	}{
+		{"docker daemon", TestResult{}, "Cannot connect to the Docker daemon at unix:///var/run/docker.sock. Is the docker daemon running?\n", "docker daemon unreachable"},
+		{"disk full", TestResult{}, "step 1\nwrite /tmp/x: no space left on device\n", "disk full"},
+		{"qemu", TestResult{}, "qemu-system-x86_64: failed to initialize kvm: Permission denied\n", "qemu launch failure"},
+		{"error", TestResult{Error: errors.New("failed to start make: Error response from daemon: conflict")}, "", "docker daemon error"},
+		{"network", TestResult{}, "fetch https://dl-cdn.example.com/APKINDEX.tar.gz: temporary failure in name resolution\n", "network down"},
+		{"test lookup", TestResult{}, "curl: (6) Could not resolve host: example.com: Temporary failure in name resolution\n", ""},
+		{"docker connect", TestResult{}, "error during connect: Get \"http://docker:2375/v1.45/containers/json\": EOF\n", "docker daemon unreachable"},
+		{"test connect", TestResult{}, "--- FAIL: TestClient: expected error during connect: got nil\n", ""},
+		{"oom", TestResult{}, "Out of memory: Killed process 1234 (python3)\n", "OOM-killed"},
+		{"runner", TestResult{}, "OCI runtime create failed: runc create failed\n", "runner crash"},
+		{"killed", TestResult{ExitCode: 137}, "step 1\n", ""},
+		{"killed for memory", TestResult{ExitCode: 137}, "step 1\nOut of memory: Killed process 1234 (python3)\n", "OOM-killed"},
+		{"container", TestResult{ExitCode: 125}, "", ""},
+		{"test failure", TestResult{}, "FAIL: TestSomething\n", ""},
+		{"passed", TestResult{Success: true}, "no space left on device\n", ""},
+		{"hung", TestResult{Hung: true}, "no space left on device\n", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.log != "" {
+				tt.result.LogPath = filepath.Join(dir, tt.name+".log")
+				os.WriteFile(tt.result.LogPath, []byte(tt.log), 0644)
+			}
+			if got := infraSignature(tt.result); got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestRunnerInfraBackoff(t *testing.T) {
+	repoPath := t.TempDir()
+	logDir := t.TempDir()
+	packages := []string{"a", "b", "c", "d", "broken"}
+	for _, pkg := range packages {
+		if err := os.WriteFile(filepath.Join(repoPath, pkg+".yaml"), []byte("package:\n  name: "+pkg+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The first test of every package runs out of disk, and broken always
+	// does
+	var mu sync.Mutex
+	attempts := make(map[string]int)
+	runner := NewRegressionTestRunner("", "https://example.com/repo", repoPath, "wolfi", 4, false, time.Minute, false)
+	runner.setLogDir(logDir)
+	runner.SetExecutor(TestExecutorFunc(func(ctx context.Context, pkg string, opts ExecuteOptions) TestResult {
+		mu.Lock()
+		attempts[pkg]++
+		attempt := attempts[pkg]
+		mu.Unlock()
+
+		if attempt > 1 && pkg != "broken" {
+			return newTestResult(pkg, opts.WithRepo, time.Now(), "", nil)
+		}
+		logPath := filepath.Join(logDir, pkg+".log")
+		os.WriteFile(logPath, []byte("write /home/build/out: no space left on device\n"), 0644)
+		return newTestResult(pkg, opts.WithRepo, time.Now(), logPath, errors.New("exit status 1"))
+	}))
+
+	if err := runner.RunFromPackageList(packages); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(runner.summary.Successful, []string{"a", "b", "c", "d"}) {
+		t.Errorf("Expected the re-queued packages to pass, got %v", runner.summary.Successful)
+	}
+	if len(runner.summary.Failed) != 0 || len(runner.summary.Regressions) != 0 {
+		t.Errorf("Expected no failures or regressions, got %v and %v", runner.summary.Failed, runner.summary.Regressions)
+	}
+	if !reflect.DeepEqual(runner.summary.Infra, []string{"broken (disk full)"}) {
+		t.Errorf("Expected broken to be reported as an infrastructure failure, got %v", runner.summary.Infra)
+	}
+	// broken is tested once more at the end of the run after its retries,
+	// never without the repository
+	if attempts["broken"] != maxInfraRetries+2 {
+		t.Errorf("Expected %d tests of broken, got %d", maxInfraRetries+2, attempts["broken"])
+	}
+	if len(runner.summary.Backoffs) == 0 || runner.summary.Backoffs[0].From != 4 || runner.summary.Backoffs[0].To != 2 {
+		t.Errorf("Expected the concurrency to be halved, got %v", runner.summary.Backoffs)
+	}
+}
+
+func TestRunnerInfraBackoffRetriesWithoutRepo(t *testing.T) {
+	repoPath := t.TempDir()
+	logDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(repoPath, "a.yaml"), []byte("package:\n  name: a\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// a fails with the repository, and its first test without it runs out
+	// of disk
+	attempts := make(map[bool]int)
+	runner := NewRegressionTestRunner("", "https://example.com/repo", repoPath, "wolfi", 1, false, time.Minute, false)
+	runner.setLogDir(logDir)
+	runner.SetExecutor(TestExecutorFunc(func(ctx context.Context, pkg string, opts ExecuteOptions) TestResult {
+		attempts[opts.WithRepo]++
+		if opts.WithRepo {
+			return newTestResult(pkg, true, time.Now(), "", errors.New("exit status 1"))
+		}
+		if attempts[false] > 1 {
+			return newTestResult(pkg, false, time.Now(), "", nil)
+		}
+		logPath := filepath.Join(logDir, pkg+".log")
+		os.WriteFile(logPath, []byte("write /home/build/out: no space left on device\n"), 0644)
+		return newTestResult(pkg, false, time.Now(), logPath, errors.New("exit status 1"))
+	}))
+
+	err := runner.RunFromPackageList([]string{"a"})
+	if err == nil {
+		t.Fatalf("Expected the regression to fail the run")
+	}
+	if attempts[true] != 1 || attempts[false] != 2 {
+		t.Errorf("Expected only the without-repo test to be retried, got %d with-repo and %d without-repo tests", attempts[true], attempts[false])
+	}
+	if !reflect.DeepEqual(runner.summary.Regressions, []string{"a"}) {
+		t.Errorf("Expected a to regress, got %v", runner.summary.Regressions)
+	}
+}
+
+func TestRunnerInfraBackoffDisabled(t *testing.T) {
+	repoPath := t.TempDir()
+	logDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(repoPath, "a.yaml"), []byte("package:\n  name: a\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	runner := NewRegressionTestRunner("", "https://example.com/repo", repoPath, "wolfi", 1, false, time.Minute, false)
+	runner.setLogDir(logDir)
+	runner.SetInfraBackoff(false)
+	attempts := 0
+	runner.SetExecutor(TestExecutorFunc(func(ctx context.Context, pkg string, opts ExecuteOptions) TestResult {
+		attempts++
+		return newTestResult(pkg, opts.WithRepo, time.Now(), "", errors.New("docker: Cannot connect to the Docker daemon"))
+	}))
+
+	if err := runner.RunFromPackageList([]string{"a"}); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(runner.summary.Infra, []string{"a (docker daemon unreachable)"}) {
+		t.Errorf("Expected a to be reported as an infrastructure failure without retries, got %v", runner.summary.Infra)
+	}
+	if attempts != 1 {
+		t.Errorf("Expected 1 test of a, got %d", attempts)
+	}
+}
+
+func TestRunnerInfraFinalPass(t *testing.T) {
+	repoPath := t.TempDir()
+	logDir := t.TempDir()
+	for _, pkg := range []string{"a", "b"} {
+		if err := os.WriteFile(filepath.Join(repoPath, pkg+".yaml"), []byte("package:\n  name: "+pkg+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// a can't reach the network until all other tests finished
+	var mu sync.Mutex
+	var order []string
+	attempts := make(map[string]int)
+	runner := NewRegressionTestRunner("", "https://example.com/repo", repoPath, "wolfi", 1, false, time.Minute, false)
+	runner.setLogDir(logDir)
+	runner.SetExecutor(TestExecutorFunc(func(ctx context.Context, pkg string, opts ExecuteOptions) TestResult {
+		mu.Lock()
+		order = append(order, pkg)
+		attempts[pkg]++
+		attempt := attempts[pkg]
+		mu.Unlock()
+
+		if pkg == "a" && attempt <= maxInfraRetries+1 {
+			return newTestResult(pkg, opts.WithRepo, time.Now(), "", errors.New("dial tcp: connect: network is unreachable"))
+		}
+		return newTestResult(pkg, opts.WithRepo, time.Now(), "", nil)
+	}))
+
+	if err := runner.RunFromPackageList([]string{"a", "b"}); err != nil {
+		t.Fatal(err)
+	}
+	if attempts["a"] != maxInfraRetries+2 || order[len(order)-1] != "a" {
+		t.Errorf("Expected a to be tested again last, got %v", order)
+	}
+	if !reflect.DeepEqual(runner.summary.Successful, []string{"b", "a"}) {
+		t.Errorf("Expected a to pass at the end of the run, got %v", runner.summary.Successful)
+	}
+	if len(runner.summary.Infra) != 0 {
+		t.Errorf("Expected no infrastructure failures, got %v", runner.summary.Infra)
+	}
+}
+
+func TestMarkInfra(t *testing.T) {
+	result := markInfra(newTestResult("curl", true, time.Now(), "", errors.New("no space left on device")))
+	if result.Infra != "disk full" || result.Classification != ClassificationInfra {
+		t.Errorf("Expected an infrastructure failure, got %q classified %s", result.Infra, result.Classification)
+	}
+
+	result = markInfra(newTestResult("curl", true, time.Now(), "", &fakeExitError{code: 1}))
+	if result.Infra != "" || result.Classification != ClassificationFail {
+		t.Errorf("Expected a test failure, got %q classified %s", result.Infra, result.Classification)
+	}
+}
@@ -0,0 +1,136 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// tempLeak is a temporary directory a run left behind, e.g. because a
+// killed test left read-only files in its workspace
+type tempLeak struct {
+	Path string
+	Size uint64
+}
+
+// SetCleanLeaks removes the temporary directories a run left behind when it
+// finishes, making read-only directories writable first, instead of only
+// reporting them
+func (r *RegressionTestRunner) SetCleanLeaks(enabled bool) {
+	r.cleanLeaks = enabled
+}
+
+// auditTempLeaks reports the temporary directories of this process left in
+// the temp root after the run removed its own, and removes them with
+// --clean-leaks
+func (r *RegressionTestRunner) auditTempLeaks() {
+	root := r.tempRoot
+	if root == "" {
+		root = os.TempDir()
+	}
+	leaks, err := findTempLeaks(root, fmt.Sprintf("%s%d-", tempPrefix, os.Getpid()))
+	if err != nil {
+		fmt.Printf("Warning: failed to check %s for leftover temporary files: %v\n", root, err)
+		return
+	}
+	if len(leaks) == 0 {
+		return
+	}
+
+	var total uint64
+	for _, leak := range leaks {
+		total += leak.Size
+	}
+
+	if r.cleanLeaks {
+		removed := 0
+		for _, leak := range leaks {
+			if err := forceRemoveAll(leak.Path); err != nil {
+				fmt.Printf("Warning: failed to remove %s: %v\n", leak.Path, err)
+				continue
+			}
+			removed++
+		}
+		fmt.Printf("\nRemoved %d leftover temporary directories (%s)\n", removed, formatBytes(total))
+		return
+	}
+
+	fmt.Printf("\n⚠️  Leftover temporary files of this run (%s):\n", formatBytes(total))
+	for _, leak := range leaks {
+		fmt.Printf("  - %s (%s)\n", leak.Path, formatBytes(leak.Size))
+	}
+	fmt.Printf("Remove them with --clean-leaks\n")
+}
+
+// findTempLeaks returns the contents of the directories in root whose name
+// starts with prefix, largest first, or the directories themselves if they
+// are empty
+func findTempLeaks(root, prefix string) ([]tempLeak, error) {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return nil, err
+	}
+
+	var leaks []tempLeak
+	for _, entry := range entries {
+		if !entry.IsDir() || !strings.HasPrefix(entry.Name(), prefix) {
+			continue
+		}
+		runDir := filepath.Join(root, entry.Name())
+		children, err := os.ReadDir(runDir)
+		if err != nil || len(children) == 0 {
+			leaks = append(leaks, tempLeak{Path: runDir})
+			continue
+		}
+		for _, child := range children {
+			path := filepath.Join(runDir, child.Name())
+			leaks = append(leaks, tempLeak{Path: path, Size: diskUsage(path)})
+		}
+	}
+
+	sort.SliceStable(leaks, func(i, j int) bool { return leaks[i].Size > leaks[j].Size })
+	return leaks, nil
+}
+
+// diskUsage returns the size of the files in path, skipping what can't be
+// read
+func diskUsage(path string) uint64 {
+	var size uint64
+	filepath.WalkDir(path, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info, err := d.Info(); err == nil && info.Mode().IsRegular() {
+			size += uint64(info.Size())
+		}
+		return nil
+	})
+	return size
+}
+
+// forceRemoveAll removes path like os.RemoveAll, first making the
+// directories in it writable, since e.g. Go's module cache is read-only
+func forceRemoveAll(path string) error {
+	filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err == nil && d.IsDir() {
+			if info, err := d.Info(); err == nil {
+				os.Chmod(p, info.Mode().Perm()|0700)
+			}
+		}
+		return nil
+	})
+	if err := os.RemoveAll(path); err != nil {
+		return err
+	}
+	// The directory of the run is left empty once its contents are gone
+	if parent := filepath.Dir(path); strings.HasPrefix(filepath.Base(parent), tempPrefix) {
+		os.Remove(parent)
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFindTempLeaks(t *testing.T) {
+	root := t.TempDir()
+	worker := filepath.Join(root, "apkregress-42-abc", "worker-0-def")
+	cache := filepath.Join(worker, "gomodcache")
+	if err := os.MkdirAll(cache, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(cache, "mod.zip"), make([]byte, 2048), 0444); err != nil {
+		t.Fatal(err)
+	}
+	// Go's module cache is read-only
+	if err := os.Chmod(cache, 0555); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(root, "apkregress-420-xyz", "worker-0-ghi"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(root, "apkregress-42-empty"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	leaks, err := findTempLeaks(root, "apkregress-42-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(leaks) != 2 {
+		t.Fatalf("Expected 2 leaks, got %v", leaks)
+	}
+	if leaks[0].Path != worker || leaks[0].Size != 2048 {
+		t.Errorf("Expected %s with 2048 bytes first, got %v", worker, leaks[0])
+	}
+	if leaks[1].Path != filepath.Join(root, "apkregress-42-empty") {
+		t.Errorf("Expected the empty run directory, got %v", leaks[1])
+	}
+
+	for _, leak := range leaks {
+		if err := forceRemoveAll(leak.Path); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(root, "apkregress-42-abc")); !os.IsNotExist(err) {
+		t.Errorf("Expected the run directory to be removed with its contents")
+	}
+	if _, err := os.Stat(filepath.Join(root, "apkregress-420-xyz")); err != nil {
+		t.Errorf("Expected the directory of another process to be kept: %v", err)
+	}
+}
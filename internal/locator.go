@@ -0,0 +1,193 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ConfigLocator finds the melange config of a package in a package repository
+type ConfigLocator interface {
+	// Locate returns the path of the config for packageName, or
+	// ErrPackageYAMLNotFound if there is none
+	Locate(packageName string) (string, error)
+	// Packages returns the sorted names of all packages that have a config
+	Packages() ([]string, error)
+}
+
+// NewConfigLocator returns the locator for a repository layout:
+//
+//   - "flat": configs live at <repoPath>/<name>.yaml (wolfi-dev/os)
+//   - "recursive": configs named <name>.yaml anywhere below repoPath
+//   - a path pattern relative to repoPath containing {name}, e.g.
+//     "packages/{name}/{name}.yaml"
+//
+// Packages that aren't found at their expected location are looked up by
+// parsing the configs, so origins and subpackages defined in differently
+// named files are still found.
+func NewConfigLocator(repoPath, layout string) (ConfigLocator, error) {
+	var base ConfigLocator
+	switch {
+	case layout == "" || layout == "flat":
+		base = &flatLocator{repoPath: repoPath}
+	case layout == "recursive":
+		base = &recursiveLocator{repoPath: repoPath}
+	case strings.Contains(layout, "{name}"):
+		pattern, err := newPatternLocator(repoPath, layout)
+		if err != nil {
+			return nil, err
+		}
+		base = pattern
+	default:
+		return nil, fmt.Errorf("invalid YAML layout: %s (must be flat, recursive, or a pattern containing {name})", layout)
+	}
+	return newOriginLocator(base), nil
+}
+
+type flatLocator struct {
+	repoPath string
+}
+
+func (l *flatLocator) Locate(packageName string) (string, error) {
+	path := filepath.Join(l.repoPath, fmt.Sprintf("%s.yaml", packageName))
+	if _, err := os.Stat(path); err != nil {
+		return "", ErrPackageYAMLNotFound
+	}
+	return path, nil
+}
+
+func (l *flatLocator) Packages() ([]string, error) {
+	return ListPackageYAMLs(l.repoPath)
+}
+
+type patternLocator struct {
+	repoPath string
+	pattern  string
+	matcher  *regexp.Regexp
+}
+
+func newPatternLocator(repoPath, pattern string) (*patternLocator, error) {
+	pattern = filepath.ToSlash(pattern)
+
+	// Turn e.g. "packages/{name}/{name}.yaml" into ^packages/([^/]+)/([^/]+)\.yaml$
+	parts := strings.Split(pattern, "{name}")
+	for i, part := range parts {
+		parts[i] = regexp.QuoteMeta(part)
+	}
+	matcher, err := regexp.Compile("^" + strings.Join(parts, "([^/]+)") + "$")
+	if err != nil {
+		return nil, fmt.Errorf("invalid YAML layout pattern %s: %w", pattern, err)
+	}
+
+	return &patternLocator{repoPath: repoPath, pattern: pattern, matcher: matcher}, nil
+}
+
+func (l *patternLocator) Locate(packageName string) (string, error) {
+	rel := strings.ReplaceAll(l.pattern, "{name}", packageName)
+	path := filepath.Join(l.repoPath, filepath.FromSlash(rel))
+	if _, err := os.Stat(path); err != nil {
+		return "", ErrPackageYAMLNotFound
+	}
+	return path, nil
+}
+
+func (l *patternLocator) Packages() ([]string, error) {
+	glob := filepath.Join(l.repoPath, filepath.FromSlash(strings.ReplaceAll(l.pattern, "{name}", "*")))
+	matches, err := filepath.Glob(glob)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, match := range matches {
+		rel, err := filepath.Rel(l.repoPath, match)
+		if err != nil {
+			continue
+		}
+		captures := l.matcher.FindStringSubmatch(filepath.ToSlash(rel))
+		if captures == nil {
+			continue
+		}
+		// Every occurrence of {name} must agree, e.g. foo/foo.yaml but not foo/bar.yaml
+		name := captures[1]
+		consistent := true
+		for _, capture := range captures[2:] {
+			if capture != name {
+				consistent = false
+			}
+		}
+		if consistent {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// recursiveLocator indexes every YAML file below repoPath by base name the
+// first time it's used. If several files share a name, the first one in
+// lexical walk order wins.
+type recursiveLocator struct {
+	repoPath string
+	once     sync.Once
+	configs  map[string]string
+	err      error
+}
+
+func (l *recursiveLocator) index() (map[string]string, error) {
+	l.once.Do(func() {
+		l.configs = make(map[string]string)
+		l.err = filepath.WalkDir(l.repoPath, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				// Skip .git and other hidden directories
+				if path != l.repoPath && strings.HasPrefix(d.Name(), ".") {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if filepath.Ext(path) != ".yaml" || strings.HasPrefix(d.Name(), ".") {
+				return nil
+			}
+			name := strings.TrimSuffix(d.Name(), ".yaml")
+			if _, ok := l.configs[name]; !ok {
+				l.configs[name] = path
+			}
+			return nil
+		})
+		if l.err != nil {
+			l.err = fmt.Errorf("failed to index YAML files in %s: %w", l.repoPath, l.err)
+		}
+	})
+	return l.configs, l.err
+}
+
+func (l *recursiveLocator) Locate(packageName string) (string, error) {
+	configs, err := l.index()
+	if err != nil {
+		return "", err
+	}
+	path, ok := configs[packageName]
+	if !ok {
+		return "", ErrPackageYAMLNotFound
+	}
+	return path, nil
+}
+
+func (l *recursiveLocator) Packages() ([]string, error) {
+	configs, err := l.index()
+	if err != nil {
+		return nil, err
+	}
+	return sortedKeys(configs), nil
+}
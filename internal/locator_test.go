@@ -0,0 +1,128 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// writeRepoFiles creates empty files at the given paths below dir
+func writeRepoFiles(t *testing.T, dir string, paths ...string) {
+	t.Helper()
+	for _, path := range paths {
+		full := filepath.Join(dir, path)
+		if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(full, []byte("package: {}\n"), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+	}
+}
+
+func TestNewConfigLocator(t *testing.T) {
+	tests := []struct {
+		name          string
+		layout        string
+		expectedError bool
+	}{
+		{name: "default", layout: ""},
+		{name: "flat", layout: "flat"},
+		{name: "recursive", layout: "recursive"},
+		{name: "pattern", layout: "packages/{name}/{name}.yaml"},
+		{name: "invalid", layout: "nested", expectedError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			locator, err := NewConfigLocator("/tmp", tt.layout)
+			if tt.expectedError {
+				if err == nil {
+					t.Error("Expected error but got none")
+				}
+				return
+			}
+			if err != nil || locator == nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestFlatLocator(t *testing.T) {
+	repo := t.TempDir()
+	writeRepoFiles(t, repo, "curl.yaml", "git.yaml", "nested/foo.yaml")
+
+	locator, _ := NewConfigLocator(repo, "flat")
+
+	path, err := locator.Locate("curl")
+	if err != nil || path != filepath.Join(repo, "curl.yaml") {
+		t.Errorf("Unexpected result: %s, %v", path, err)
+	}
+	if _, err := locator.Locate("foo"); !errors.Is(err, ErrPackageYAMLNotFound) {
+		t.Errorf("Expected ErrPackageYAMLNotFound, got %v", err)
+	}
+
+	packages, err := locator.Packages()
+	if err != nil || !reflect.DeepEqual(packages, []string{"curl", "git"}) {
+		t.Errorf("Unexpected packages: %v, %v", packages, err)
+	}
+}
+
+func TestPatternLocator(t *testing.T) {
+	repo := t.TempDir()
+	writeRepoFiles(t, repo,
+		"packages/curl/curl.yaml",
+		"packages/git/git.yaml",
+		"packages/git/pipelines.yaml",
+		"curl.yaml",
+	)
+
+	locator, err := NewConfigLocator(repo, "packages/{name}/{name}.yaml")
+	if err != nil {
+		t.Fatalf("Failed to create locator: %v", err)
+	}
+
+	path, err := locator.Locate("git")
+	if err != nil || path != filepath.Join(repo, "packages", "git", "git.yaml") {
+		t.Errorf("Unexpected result: %s, %v", path, err)
+	}
+	if _, err := locator.Locate("pipelines"); !errors.Is(err, ErrPackageYAMLNotFound) {
+		t.Errorf("Expected ErrPackageYAMLNotFound, got %v", err)
+	}
+
+	packages, err := locator.Packages()
+	if err != nil || !reflect.DeepEqual(packages, []string{"curl", "git"}) {
+		t.Errorf("Unexpected packages: %v, %v", packages, err)
+	}
+}
+
+func TestRecursiveLocator(t *testing.T) {
+	repo := t.TempDir()
+	writeRepoFiles(t, repo,
+		"a/curl.yaml",
+		"b/c/git.yaml",
+		"z/curl.yaml",
+		".git/hidden.yaml",
+	)
+
+	locator, _ := NewConfigLocator(repo, "recursive")
+
+	path, err := locator.Locate("curl")
+	if err != nil || path != filepath.Join(repo, "a", "curl.yaml") {
+		t.Errorf("Unexpected result: %s, %v", path, err)
+	}
+	if _, err := locator.Locate("hidden"); !errors.Is(err, ErrPackageYAMLNotFound) {
+		t.Errorf("Expected hidden directories to be skipped, got %v", err)
+	}
+
+	packages, err := locator.Packages()
+	if err != nil || !reflect.DeepEqual(packages, []string{"curl", "git"}) {
+		t.Errorf("Unexpected packages: %v, %v", packages, err)
+	}
+}
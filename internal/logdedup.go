@@ -0,0 +1,72 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// dedupeLogs replaces byte-identical test logs, e.g. of every package failing
+// because a repository is unreachable, with symlinks to a single copy
+func dedupeLogs(packageResults map[string]map[bool]TestResult) {
+	byDigest := make(map[string][]string)
+	for _, pkg := range sortedKeys(packageResults) {
+		for _, withRepo := range []bool{true, false} {
+			result, ok := packageResults[pkg][withRepo]
+			if !ok || result.LogPath == "" {
+				continue
+			}
+			digest, err := fileDigest(result.LogPath)
+			if err != nil {
+				continue
+			}
+			byDigest[digest] = append(byDigest[digest], result.LogPath)
+		}
+	}
+
+	for _, paths := range byDigest {
+		for _, path := range paths[1:] {
+			if err := linkLog(path, paths[0]); err != nil {
+				fmt.Printf("Warning: failed to deduplicate log %s: %v\n", path, err)
+			}
+		}
+	}
+}
+
+func fileDigest(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// linkLog replaces path with a relative symlink to target
+func linkLog(path, target string) error {
+	rel, err := filepath.Rel(filepath.Dir(path), target)
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".link"
+	if err := os.Symlink(rel, tmp); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
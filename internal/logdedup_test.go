@@ -0,0 +1,65 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDedupeLogs(t *testing.T) {
+	dir := t.TempDir()
+	unreachable := "fetching index\nERROR: unable to fetch https://apk.example/os: connection refused\n"
+	logs := map[string]string{
+		"curl_with_repo.log":    unreachable,
+		"git_with_repo.log":     unreachable,
+		"jq_with_repo.log":      unreachable,
+		"jq_without_repo.log":   "ok\n",
+		"wget_with_repo.log":    "--- FAIL: TestGet\n",
+		"zlib_with_repo.log":    "ok\n",
+		"libxml2_with_repo.log": unreachable,
+	}
+	for name, content := range logs {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	log := func(name string) string { return filepath.Join(dir, name) }
+
+	dedupeLogs(map[string]map[bool]TestResult{
+		"curl": {true: {LogPath: log("curl_with_repo.log")}},
+		"git":  {true: {LogPath: log("git_with_repo.log")}},
+		"jq": {
+			true:  {LogPath: log("jq_with_repo.log")},
+			false: {Success: true, LogPath: log("jq_without_repo.log")},
+		},
+		"wget":    {true: {LogPath: log("wget_with_repo.log")}},
+		"zlib":    {true: {Success: true, LogPath: log("zlib_with_repo.log")}},
+		"libxml2": {true: {Success: true, LogPath: log("libxml2_with_repo.log")}},
+	})
+
+	// One copy is kept; the others link to it and still read the same
+	for _, name := range []string{"git_with_repo.log", "jq_with_repo.log", "libxml2_with_repo.log"} {
+		info, err := os.Lstat(log(name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode()&os.ModeSymlink == 0 {
+			t.Errorf("Expected %s to be a symlink", name)
+		}
+		data, err := os.ReadFile(log(name))
+		if err != nil || string(data) != unreachable {
+			t.Errorf("Expected %s to read the shared log, got %q (%v)", name, data, err)
+		}
+	}
+	for _, name := range []string{"curl_with_repo.log", "wget_with_repo.log", "jq_without_repo.log"} {
+		if info, err := os.Lstat(log(name)); err != nil || info.Mode()&os.ModeSymlink != 0 {
+			t.Errorf("Expected %s to stay a regular file", name)
+		}
+	}
+	if info, err := os.Lstat(log("zlib_with_repo.log")); err != nil || info.Mode()&os.ModeSymlink == 0 {
+		t.Error("Expected zlib_with_repo.log to link to jq_without_repo.log")
+	}
+}
@@ -0,0 +1,109 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// LogExcerpt is a block of a test log around a line that indicates why the
+// test failed
+type LogExcerpt struct {
+	Category string
+	// Line is the 1-based number of the matching line
+	Line  int
+	Lines []string
+}
+
+// logPatterns classify failure lines, most specific first
+var logPatterns = []struct {
+	category string
+	pattern  *regexp.Regexp
+}{
+	{"hung", regexp.MustCompile(`=== TEST HUNG`)},
+	{"unsatisfiable dependency", regexp.MustCompile(`(?i)unsatisfiable|solving .* failed|no such package|could not find package`)},
+	{"missing file", regexp.MustCompile(`(?i)no such file or directory|cannot open shared object|not found in \$PATH|command not found`)},
+	{"panic", regexp.MustCompile(`panic:|(?i)segmentation fault|core dumped`)},
+	{"link error", regexp.MustCompile(`(?i)undefined reference|undefined symbol|symbol lookup error|ld: cannot find`)},
+	{"compile error", regexp.MustCompile(`\berror:|fatal error|compilation terminated`)},
+	{"test failure", regexp.MustCompile(`^\s*(--- )?FAIL\b|\bFAILED\b|[Aa]ssertion|Traceback \(most recent call last\)`)},
+	{"error", regexp.MustCompile(`(?i)\berror\b`)},
+}
+
+// excerptContext is the number of lines shown before and after a match
+const excerptContext = 3
+
+// ExcerptLog returns up to max excerpts of the lines in a log that explain a
+// failure, in log order. Overlapping excerpts are merged.
+func ExcerptLog(path string, max int) ([]LogExcerpt, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open log: %w", err)
+	}
+	defer file.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read log: %w", err)
+	}
+
+	var excerpts []LogExcerpt
+	end := -1
+	for i, line := range lines {
+		category := classifyLogLine(line)
+		if category == "" || i <= end {
+			continue
+		}
+		if len(excerpts) == max {
+			break
+		}
+
+		start := i - excerptContext
+		if start <= end {
+			start = end + 1
+		}
+		if start < 0 {
+			start = 0
+		}
+		end = i + excerptContext
+		if end >= len(lines) {
+			end = len(lines) - 1
+		}
+		excerpts = append(excerpts, LogExcerpt{
+			Category: category,
+			Line:     i + 1,
+			Lines:    lines[start : end+1],
+		})
+	}
+	return excerpts, nil
+}
+
+// classifyLogLine returns the failure category of a log line, or ""
+func classifyLogLine(line string) string {
+	for _, p := range logPatterns {
+		if p.pattern.MatchString(line) {
+			return p.category
+		}
+	}
+	return ""
+}
+
+// String formats the excerpt for the terminal
+func (e LogExcerpt) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "[%s] line %d:\n", e.Category, e.Line)
+	for _, line := range e.Lines {
+		fmt.Fprintf(&b, "  | %s\n", line)
+	}
+	return b.String()
+}
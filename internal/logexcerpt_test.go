@@ -0,0 +1,72 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestClassifyLogLine(t *testing.T) {
+	tests := []struct {
+		line     string
+		expected string
+	}{
+		{"=== TEST HUNG: no output for 30m0s ===", "hung"},
+		{"ERROR: unable to select packages: so:libssl.so.3 (unsatisfiable)", "unsatisfiable dependency"},
+		{"/usr/bin/curl: error while loading shared libraries: libssl.so.3: cannot open shared object file", "missing file"},
+		{"ld: undefined reference to `SSL_new'", "link error"},
+		{"main.c:3:10: fatal error: openssl/ssl.h: No such file or directory", "missing file"},
+		{"main.c:12: error: expected ';'", "compile error"},
+		{"--- FAIL: TestHandshake (0.01s)", "test failure"},
+		{"panic: runtime error: index out of range", "panic"},
+		{"level=error msg=\"step failed\"", "error"},
+		{"ok  \tgithub.com/example/pkg\t0.01s", ""},
+		{"building package curl", ""},
+	}
+
+	for _, tt := range tests {
+		if category := classifyLogLine(tt.line); category != tt.expected {
+			t.Errorf("Expected %q for %q, got %q", tt.expected, tt.line, category)
+		}
+	}
+}
+
+func TestExcerptLog(t *testing.T) {
+	var lines []string
+	for i := 1; i <= 30; i++ {
+		lines = append(lines, "step")
+	}
+	lines[4] = "--- FAIL: TestA"
+	lines[6] = "--- FAIL: TestB"
+	lines[20] = "panic: boom"
+	lines[27] = "error: late"
+
+	path := filepath.Join(t.TempDir(), "curl_with_repo.log")
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	excerpts, err := ExcerptLog(path, 2)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(excerpts) != 2 {
+		t.Fatalf("Expected 2 excerpts, got %d", len(excerpts))
+	}
+
+	// The second failure lies inside the first excerpt's context
+	if excerpts[0].Category != "test failure" || excerpts[0].Line != 5 || len(excerpts[0].Lines) != 7 {
+		t.Errorf("Unexpected first excerpt: %+v", excerpts[0])
+	}
+	if excerpts[1].Category != "panic" || excerpts[1].Line != 21 || excerpts[1].Lines[3] != "panic: boom" {
+		t.Errorf("Unexpected second excerpt: %+v", excerpts[1])
+	}
+
+	if _, err := ExcerptLog(filepath.Join(t.TempDir(), "missing.log"), 1); err == nil {
+		t.Error("Expected an error for a missing log")
+	}
+}
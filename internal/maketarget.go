@@ -0,0 +1,125 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// ErrUntestableTarget indicates that the Makefile of the package repository
+// has no rule for the test target of a package, so it can't be tested the
+// standard way, as opposed to failing its test
+var ErrUntestableTarget = errors.New("no make target to test the package")
+
+// noRuleMessage is what make prints, exiting with status 2, for targets it
+// has no rule for
+const noRuleMessage = "No rule to make target"
+
+// makefileNames are the names GNU make reads a Makefile from, in order
+var makefileNames = []string{"GNUmakefile", "makefile", "Makefile"}
+
+// makeRule matches the targets of a rule, e.g. "test/%:" or "check test:",
+// leaving out variable assignments such as "FOO := bar"
+var makeRule = regexp.MustCompile(`^([^\s:=#][^:=#]*):([^=]|$)`)
+
+// untestableTarget returns an ErrUntestableTarget error if the log of a make
+// test that exited with status 2 shows that the Makefile in checkout has no
+// rule for target, suggesting the test targets it has instead, and nil
+// otherwise
+func untestableTarget(logPath, checkout, target string) error {
+	data, err := os.ReadFile(logPath)
+	if err != nil || !bytes.Contains(data, []byte(noRuleMessage)) {
+		return nil
+	}
+	return fmt.Errorf("%w: make has no rule for %s in %s%s", ErrUntestableTarget, target, checkout, targetSuggestion(checkout, target))
+}
+
+// targetSuggestion suggests how to test target, named test/<config>, in a
+// repository whose Makefile doesn't have it: the test targets it has, as a
+// --test-command if one is a pattern rule, or the test command itself
+func targetSuggestion(checkout, target string) string {
+	// The standard pattern rule, if the Makefile has it, failed on a
+	// prerequisite
+	var targets []string
+	for _, t := range testTargets(checkout) {
+		if t != "test/%" && t != target {
+			targets = append(targets, t)
+		}
+	}
+	config := strings.TrimPrefix(target, "test/")
+	for _, t := range targets {
+		if strings.Contains(t, "%") {
+			return fmt.Sprintf(" (the Makefile has %s, try --test-command 'make %s')", t, strings.ReplaceAll(t, "%", "{{.Target}}"))
+		}
+	}
+	for _, t := range targets {
+		if strings.Contains(t, config) {
+			return fmt.Sprintf(" (the Makefile has %s, try --test-command 'make %s')", t, strings.ReplaceAll(t, config, "{{.Target}}"))
+		}
+	}
+	if len(targets) > 0 {
+		return fmt.Sprintf(" (its test targets are %s; pass --test-command to test another way)", strings.Join(targets, ", "))
+	}
+	return " (pass --test-command to test without make, e.g. 'melange test {{.Config}} {{.ExtraRepos}} {{.ExtraOpts}}')"
+}
+
+// testTargets returns the sorted targets of the Makefile in dir whose name
+// contains "test" or "check", or nil if there is no Makefile
+func testTargets(dir string) []string {
+	for _, name := range makefileNames {
+		f, err := os.Open(filepath.Join(dir, name))
+		if err != nil {
+			continue
+		}
+		defer f.Close()
+
+		seen := make(map[string]bool)
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			match := makeRule.FindStringSubmatch(scanner.Text())
+			if match == nil {
+				continue
+			}
+			for _, t := range strings.Fields(match[1]) {
+				if !strings.HasPrefix(t, ".") && (strings.Contains(t, "test") || strings.Contains(t, "check")) {
+					seen[t] = true
+				}
+			}
+		}
+		return sortedKeys(seen)
+	}
+	return nil
+}
+
+// printUntestable lists the packages without a make target in the text
+// summary, with how to test them
+func (r *RegressionTestRunner) printUntestable(w io.Writer) {
+	if len(r.summary.Untestable) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\nPackages without a make target (not counted as failures):\n")
+	for _, pkg := range r.summary.Untestable {
+		fmt.Fprintf(w, "  - %s: %v\n", pkg, r.packageResults[pkg][true].Error)
+	}
+}
+
+// printUntestableMarkdown is printUntestable for the markdown summary
+func (r *RegressionTestRunner) printUntestableMarkdown(w io.Writer) {
+	if len(r.summary.Untestable) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\n### ⏭️ Packages Without a Make Target\n\n")
+	fmt.Fprintf(w, "The Makefile has no test target for these packages, so they weren't tested:\n\n")
+	for _, pkg := range r.summary.Untestable {
+		fmt.Fprintf(w, "- `%s`: %v\n", pkg, r.packageResults[pkg][true].Error)
+	}
+}
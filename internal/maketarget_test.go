@@ -0,0 +1,69 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunTestUntestableTarget(t *testing.T) {
+	if _, err := exec.LookPath("make"); err != nil {
+		t.Skip("make is not installed")
+	}
+
+	tests := []struct {
+		name               string
+		makefile           string
+		expectedUntestable bool
+		expectedSuggestion string
+	}{
+		{"no Makefile", "", true, "pass --test-command to test without make"},
+		{"other pattern", "tests/%:\n\t@echo testing $*\n", true, "try --test-command 'make tests/{{.Target}}'"},
+		{"named targets", "check-curl:\n\t@true\ntest-all:\n\t@true\n", true, "try --test-command 'make check-{{.Target}}'"},
+		{"unrelated targets", "build:\n\t@true\nintegration-test:\n\t@true\n", true, "its test targets are integration-test"},
+		{"failing test", "test/%:\n\t@echo failed; exit 1\n", false, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repoPath := t.TempDir()
+			logDir := filepath.Join(repoPath, "logs")
+			if err := os.MkdirAll(logDir, 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(filepath.Join(repoPath, "curl.yaml"), []byte("package:\n  name: curl\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if tt.makefile != "" {
+				if err := os.WriteFile(filepath.Join(repoPath, "Makefile"), []byte(tt.makefile), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			client := NewMelangeClient(repoPath, false, logDir, time.Minute)
+			result := client.RunTest("curl", true, "http://example.com/repo")
+			if result.Untestable != tt.expectedUntestable {
+				t.Fatalf("Expected untestable %v, got %+v", tt.expectedUntestable, result)
+			}
+			if !tt.expectedUntestable {
+				if result.Classification != ClassificationFail {
+					t.Errorf("Expected a failure, got %s", result.Classification)
+				}
+				return
+			}
+			if !result.Skipped || result.Classification != ClassificationUntestable || !errors.Is(result.Error, ErrUntestableTarget) {
+				t.Errorf("Expected an untestable result, got %+v", result)
+			}
+			if !strings.Contains(result.Error.Error(), tt.expectedSuggestion) {
+				t.Errorf("Expected %q in the error, got %v", tt.expectedSuggestion, result.Error)
+			}
+		})
+	}
+}
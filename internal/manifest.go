@@ -0,0 +1,292 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// runManifestFile is the name of the manifest in each log directory
+const runManifestFile = "run.json"
+
+// RunManifest records everything needed to reproduce a run. It is written to
+// run.json in the log directory when testing starts and updated when it
+// finishes.
+type RunManifest struct {
+	RunID string `json:"runId"`
+	// Args are the command line arguments apkregress was invoked with, and
+	// WorkDir the directory it was invoked in
+	Args    []string `json:"args,omitempty"`
+	WorkDir string   `json:"workDir,omitempty"`
+	// Flags are the values of all flags, including defaults
+	Flags    map[string]string `json:"flags,omitempty"`
+	Target   string            `json:"target"`
+	RepoType string            `json:"repoType,omitempty"`
+	RepoPath string            `json:"repoPath,omitempty"`
+	// TargetVersion is the version of Target the run was tied to with
+	// package@version
+	TargetVersion string `json:"targetVersion,omitempty"`
+	// Variant is the variant of a test matrix the run tested
+	Variant *Variant `json:"variant,omitempty"`
+	// RepoCommit is the git commit checked out in RepoPath
+	RepoCommit string `json:"repoCommit,omitempty"`
+	APKRepo    string `json:"apkRepo"`
+	// ResolvedAPKRepo is APKRepo after mirror rewriting, as passed to the
+	// tests
+	ResolvedAPKRepo string `json:"resolvedApkRepo"`
+	// Pins are the package versions with-repo tests were pinned to
+	Pins []Pin `json:"pins,omitempty"`
+	// Notes are the operator's notes about the run (--note)
+	Notes []string `json:"notes,omitempty"`
+	// ResultsDir is where the result files were written, if not to the log
+	// directory
+	ResultsDir string `json:"resultsDir,omitempty"`
+	// Indexes maps the URL of each index used to its digest
+	// ("sha256:...") or revision ("etag:...", "last-modified:...")
+	Indexes map[string]string `json:"indexes,omitempty"`
+	Tools   map[string]string `json:"tools,omitempty"`
+	// Toolchain is the tooling tests started with; each test records its
+	// own in results.json
+	Toolchain  Toolchain `json:"toolchain"`
+	Host       HostInfo  `json:"host"`
+	Packages   []string  `json:"packages"`
+	StartedAt  time.Time `json:"startedAt"`
+	FinishedAt time.Time `json:"finishedAt"`
+	// Commands are the command lines of the tests by log file, e.g.
+	// curl_with_repo.log, to reproduce them outside apkregress
+	Commands map[string]*CommandLine `json:"commands,omitempty"`
+	// DependencyKinds maps the consumers to how they depend on the target:
+	// "runtime", "build" or "both"
+	DependencyKinds map[string]string `json:"dependencyKinds,omitempty"`
+	// NoReverseDependencies is set when there was nothing to test, to tell
+	// such runs apart from runs that tested packages without regressions
+	NoReverseDependencies bool `json:"noReverseDependencies,omitempty"`
+	// Stopped is why the run stopped before testing all packages, e.g.
+	// "received signal terminated"
+	Stopped string `json:"stopped,omitempty"`
+}
+
+// HostInfo describes the machine a run executed on
+type HostInfo struct {
+	Hostname string   `json:"hostname,omitempty"`
+	OS       string   `json:"os"`
+	Arch     string   `json:"arch"`
+	CPUs     int      `json:"cpus"`
+	Platform Platform `json:"platform"`
+	// MelangeRunner is the melange runner tests used, "" for the default
+	MelangeRunner string `json:"melangeRunner,omitempty"`
+}
+
+// Invocation is how apkregress was started, recorded in run manifests
+type Invocation struct {
+	Args    []string
+	WorkDir string
+	Flags   map[string]string
+}
+
+// manifestTools are the external tools whose versions are recorded, with the
+// arguments printing their version
+var manifestTools = []struct {
+	name string
+	args []string
+}{
+	{"melange", []string{"version"}},
+	{"apko", []string{"version"}},
+	{"apkrane", []string{"version"}},
+	{"make", []string{"--version"}},
+}
+
+// LoadRunManifest reads the run.json in a log directory
+func LoadRunManifest(logDir string) (*RunManifest, error) {
+	data, err := os.ReadFile(filepath.Join(logDir, runManifestFile))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read run manifest: %w", err)
+	}
+
+	var manifest RunManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("invalid run manifest in %s: %w", logDir, err)
+	}
+	return &manifest, nil
+}
+
+// writeRunManifest writes the manifest to logDir atomically
+func writeRunManifest(logDir string, manifest *RunManifest) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := filepath.Join(logDir, runManifestFile+".tmp")
+	if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write run manifest: %w", err)
+	}
+	return os.Rename(tmp, filepath.Join(logDir, runManifestFile))
+}
+
+// newRunManifest collects the manifest of a run that is about to test
+// packages
+func (r *RegressionTestRunner) newRunManifest(packages []string) *RunManifest {
+	manifest := &RunManifest{
+		RunID:           filepath.Base(r.logDir),
+		Target:          r.packageName,
+		TargetVersion:   r.targetVersion,
+		RepoType:        r.repoType,
+		RepoPath:        r.repoPath,
+		Variant:         r.variant,
+		APKRepo:         r.apkRepo,
+		ResolvedAPKRepo: mirrorURL(r.apkRepo),
+		Pins:            r.pins,
+		Notes:           r.notes,
+		ResultsDir:      r.resultFiles.Dir,
+		Indexes:         make(map[string]string),
+		Tools:           toolVersions(),
+		Toolchain:       probeToolchain(r.repoPath),
+		Host:            hostInfo(),
+		Packages:        packages,
+		StartedAt:       r.startTime,
+		DependencyKinds: r.dependencyKinds(packages),
+	}
+
+	if r.invocation != nil {
+		manifest.Args = r.invocation.Args
+		manifest.WorkDir = r.invocation.WorkDir
+		manifest.Flags = r.invocation.Flags
+	}
+
+	if r.repoPath != "" {
+		manifest.RepoCommit = GitCommit(r.repoPath)
+	}
+	if r.melange != nil {
+		manifest.Host.MelangeRunner = r.melange.runner
+	}
+
+	if r.apkrane != nil && r.apkrane.indexURL != "" {
+		if revision := r.apkrane.validators.String(); revision != "" {
+			manifest.Indexes[r.apkrane.indexURL] = revision
+		}
+	}
+
+	indexDigestsMu.Lock()
+	for url, digest := range indexDigests {
+		manifest.Indexes[url] = digest
+	}
+	indexDigestsMu.Unlock()
+
+	return manifest
+}
+
+// testCommands maps the log file of each test that ran a command to its
+// command line
+func testCommands(packageResults map[string]map[bool]TestResult) map[string]*CommandLine {
+	commands := make(map[string]*CommandLine)
+	for _, results := range packageResults {
+		for _, result := range results {
+			if result.Command != nil && result.LogPath != "" {
+				commands[filepath.Base(result.LogPath)] = result.Command
+			}
+		}
+	}
+	if len(commands) == 0 {
+		return nil
+	}
+	return commands
+}
+
+// indexDigests are the digests of the candidate indexes fetched by this
+// process, by URL, so manifests can record them without fetching again
+var (
+	indexDigestsMu sync.Mutex
+	indexDigests   = make(map[string]string)
+)
+
+func recordIndexDigest(location string, data []byte) {
+	digest := indexDigest(data)
+	indexDigestsMu.Lock()
+	defer indexDigestsMu.Unlock()
+	indexDigests[location] = digest
+}
+
+// indexDigest returns the digest of an index, e.g. sha256:0123...
+func indexDigest(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// String identifies the revision, preferring the ETag
+func (v indexValidators) String() string {
+	switch {
+	case v.ETag != "":
+		return "etag:" + v.ETag
+	case v.LastModified != "":
+		return "last-modified:" + v.LastModified
+	}
+	return ""
+}
+
+// toolVersions returns the version of apkregress and the first line of each
+// installed tool's version output
+func toolVersions() map[string]string {
+	versions := map[string]string{"apkregress": CurrentBuild().String()}
+	for _, tool := range manifestTools {
+		if _, err := exec.LookPath(tool.name); err != nil {
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		output, err := exec.CommandContext(ctx, tool.name, tool.args...).CombinedOutput()
+		cancel()
+		if err != nil {
+			continue
+		}
+		versions[tool.name] = versionLine(string(output))
+	}
+	return versions
+}
+
+// versionLine picks the line of a tool's version output naming its version,
+// e.g. "GitVersion:    v0.11.3" from melange's build info
+func versionLine(output string) string {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	for _, line := range lines {
+		if strings.HasPrefix(line, "GitVersion:") {
+			return strings.TrimSpace(strings.TrimPrefix(line, "GitVersion:"))
+		}
+	}
+	return strings.TrimSpace(lines[0])
+}
+
+func hostInfo() HostInfo {
+	hostname, _ := os.Hostname()
+	return HostInfo{
+		Hostname: hostname,
+		OS:       runtime.GOOS,
+		Arch:     hostArch(),
+		CPUs:     runtime.NumCPU(),
+		Platform: DetectPlatform(),
+	}
+}
+
+// GitCommit returns the commit checked out in dir, or "" if it isn't a git
+// repository
+func GitCommit(dir string) string {
+	cmd := exec.Command("git", "rev-parse", "HEAD")
+	cmd.Dir = dir
+	output, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(output))
+}
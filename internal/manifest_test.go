@@ -0,0 +1,82 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRunManifest(t *testing.T) {
+	logDir := t.TempDir()
+	repoDir := t.TempDir()
+	if err := os.WriteFile(repoDir+"/APKINDEX.tar.gz", []byte("index"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fetchCandidateIndex(repoDir + "/APKINDEX.tar.gz"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	runner := NewRegressionTestRunnerFromPackageList([]string{"curl", "git"}, repoDir, t.TempDir(), "wolfi", 2, false, time.Minute, false)
+	runner.logDir = logDir
+	runner.SetInvocation(Invocation{
+		Args:    []string{"--package-file", "packages.txt"},
+		WorkDir: "/src",
+		Flags:   map[string]string{"concurrency": "2"},
+	})
+	runner.SetExecutor(TestExecutorFunc(func(ctx context.Context, pkg string, opts ExecuteOptions) TestResult {
+		return TestResult{Package: pkg, WithRepo: opts.WithRepo, Success: true}
+	}))
+
+	if err := runner.RunFromPackageList([]string{"curl", "git"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	manifest, err := LoadRunManifest(logDir)
+	if err != nil {
+		t.Fatalf("Failed to load manifest: %v", err)
+	}
+
+	if !reflect.DeepEqual(manifest.Args, []string{"--package-file", "packages.txt"}) || manifest.WorkDir != "/src" || manifest.Flags["concurrency"] != "2" {
+		t.Errorf("Unexpected invocation: %+v", manifest)
+	}
+	if !reflect.DeepEqual(manifest.Packages, []string{"curl", "git"}) {
+		t.Errorf("Expected packages [curl git], got %v", manifest.Packages)
+	}
+	if manifest.APKRepo != repoDir || manifest.ResolvedAPKRepo != repoDir || manifest.RepoType != "wolfi" {
+		t.Errorf("Unexpected repositories: %+v", manifest)
+	}
+	sum := sha256.Sum256([]byte("index"))
+	expectedDigest := "sha256:" + hex.EncodeToString(sum[:])
+	if digest := manifest.Indexes[repoDir+"/APKINDEX.tar.gz"]; digest != expectedDigest {
+		t.Errorf("Expected candidate index digest %s, got %q", expectedDigest, digest)
+	}
+	if manifest.Host.Arch != hostArch() || manifest.Host.CPUs == 0 {
+		t.Errorf("Unexpected host info: %+v", manifest.Host)
+	}
+	if manifest.StartedAt.IsZero() || manifest.FinishedAt.Before(manifest.StartedAt) {
+		t.Errorf("Unexpected run times: %v - %v", manifest.StartedAt, manifest.FinishedAt)
+	}
+}
+
+func TestVersionLine(t *testing.T) {
+	tests := []struct {
+		output   string
+		expected string
+	}{
+		{"  __  __  _____\nGitVersion:    v0.11.3\nGitCommit:     abc\n", "v0.11.3"},
+		{"GNU Make 4.4.1\nBuilt for x86_64-pc-linux-gnu\n", "GNU Make 4.4.1"},
+	}
+
+	for _, tt := range tests {
+		if got := versionLine(tt.output); got != tt.expected {
+			t.Errorf("Expected %q, got %q", tt.expected, got)
+		}
+	}
+}
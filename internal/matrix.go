@@ -0,0 +1,576 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// MatrixRunner tests the reverse dependencies of a package in several
+// repository types in one invocation, since a library change can break
+// consumers in more than one catalog, and in several variants of a test
+// matrix (see SetVariant), e.g. to compare alternative fixes. Each
+// repository type and variant is run by its own RegressionTestRunner,
+// logging to a subdirectory of a shared log directory, and the results are
+// merged into a single report.
+type MatrixRunner struct {
+	packageName    string
+	apkRepo        string
+	logDir         string
+	markdownOutput bool
+	hangTimeout    time.Duration
+	runners        []*RegressionTestRunner
+	startTime      time.Time
+	// variants is set when the runners test variants of a test matrix
+	variants bool
+	// resultFiles selects the merged result files, as for the runners
+	resultFiles ResultFiles
+}
+
+// NewMatrixRunner combines runners created with NewRegressionTestRunner for
+// the same package, one per repository type
+func NewMatrixRunner(packageName, apkRepo string, runners []*RegressionTestRunner, markdownOutput bool) *MatrixRunner {
+	timestamp := time.Now().Format("20060102-150405")
+	logDir := filepath.Join("logs", fmt.Sprintf("regression-test-%s-%s", packageName, timestamp))
+	if len(runners) > 0 && runners[0].artifactLogDir {
+		logDir = artifactLogDir(logDir)
+	}
+
+	repoTypes := make(map[string]bool)
+	for _, runner := range runners {
+		repoTypes[runner.repoType] = true
+	}
+
+	m := &MatrixRunner{
+		packageName:    packageName,
+		apkRepo:        apkRepo,
+		logDir:         logDir,
+		markdownOutput: markdownOutput,
+		runners:        runners,
+	}
+	if len(runners) > 0 {
+		m.resultFiles = runners[0].resultFiles
+	}
+	for _, runner := range runners {
+		runner.inMatrix = true
+		dir := filepath.Join(logDir, runner.repoType)
+		if runner.variant != nil {
+			m.variants = true
+			runner.matrixLabel = runner.variant.Name
+			if len(repoTypes) > 1 {
+				runner.matrixLabel = runner.repoType + " " + runner.variant.Name
+			}
+			dir = filepath.Join(dir, runner.variant.dirName())
+		}
+		runner.setLogDir(dir)
+		// Each runner's result files go to a subdirectory of a result
+		// directory too
+		if m.resultFiles.Dir != "" {
+			rel, _ := filepath.Rel(logDir, dir)
+			runner.resultFiles.Dir = filepath.Join(m.resultFiles.Dir, rel)
+		}
+		if runner.hangTimeout > m.hangTimeout {
+			m.hangTimeout = runner.hangTimeout
+		}
+	}
+	return m
+}
+
+// setLogDir moves the logs of the runner and its clients to dir
+func (r *RegressionTestRunner) setLogDir(dir string) {
+	r.logDir = dir
+	if r.melange != nil {
+		r.melange.logDir = dir
+	}
+	if r.apko != nil {
+		r.apko.logDir = dir
+	}
+}
+
+// validatePackage checks that the package is in the index of at least one
+// repository type, since consumers of e.g. a Wolfi library are also found in
+// the indexes of other repository types
+func (m *MatrixRunner) validatePackage() error {
+	var first error
+	for _, runner := range m.runners {
+		err := runner.apkrane.ValidatePackage(m.packageName)
+		if err == nil {
+			return nil
+		}
+		if !errors.Is(err, ErrUnknownPackage) {
+			return fmt.Errorf("%s: %w", runner.label(), err)
+		}
+		if first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// Run tests every repository type in turn and prints the merged report
+func (m *MatrixRunner) Run() error {
+	if err := os.MkdirAll(m.logDir, 0755); err != nil {
+		return fmt.Errorf("failed to create log directory %s: %w", m.logDir, err)
+	}
+	m.startTime = time.Now()
+
+	if err := m.validatePackage(); err != nil {
+		return err
+	}
+
+	empty := true
+	for _, runner := range m.runners {
+		if runner.variant != nil {
+			fmt.Printf("\n=== Repository: %s, variant: %s ===\n", runner.repoType, runner.variant)
+		} else {
+			fmt.Printf("\n=== Repository: %s ===\n", runner.repoType)
+		}
+		if err := runner.Run(); err != nil {
+			return fmt.Errorf("%s: %w", runner.label(), err)
+		}
+		empty = empty && runner.empty
+	}
+	if empty && len(m.runners) > 0 && m.runners[0].failOnEmpty {
+		return ErrNoReverseDependencies
+	}
+
+	m.writeResults()
+	m.reportCI()
+
+	if m.markdownOutput {
+		m.printMarkdownSummary(os.Stdout)
+	} else {
+		m.printSummary()
+	}
+
+	if gate := m.gate(); gate != nil {
+		if !m.markdownOutput {
+			gate.print(os.Stdout)
+		}
+		return gate.err()
+	}
+
+	regressions := m.tagged(func(s runSummary) []string { return s.Regressions })
+	hung := m.tagged(func(s runSummary) []string { return s.Hung })
+	if budget := m.budget(); budget != nil {
+		if !m.markdownOutput {
+			budget.print(os.Stdout)
+		}
+		if err := budget.err(); err != nil {
+			return err
+		}
+	} else if len(regressions) > 0 {
+		return fmt.Errorf("found %d regressions", len(regressions))
+	}
+	if len(hung) > 0 {
+		return fmt.Errorf("found %d hung tests", len(hung))
+	}
+	return nil
+}
+
+// gate merges the outcomes of the runners' gates, with packages tagged with
+// their repository type, or returns nil without --gate
+func (m *MatrixRunner) gate() *gateResult {
+	var merged *gateResult
+	for _, runner := range m.runners {
+		g := runner.summary.Gate
+		if g == nil {
+			continue
+		}
+		tagged := gateResult{
+			Source:         g.Source,
+			NewRegressions: tagPackages(g.NewRegressions, runner.label()),
+			NewlyHung:      tagPackages(g.NewlyHung, runner.label()),
+			Known:          tagPackages(g.Known, runner.label()),
+		}
+		if merged == nil {
+			merged = &tagged
+		} else {
+			*merged = merged.merge(tagged)
+		}
+	}
+	return merged
+}
+
+// budget evaluates the failure budget over the regressions of all runners,
+// tagged with their repository type, or returns nil without one
+func (m *MatrixRunner) budget() *budgetResult {
+	if len(m.runners) == 0 || m.runners[0].failureBudget == nil {
+		return nil
+	}
+	var regressions []string
+	ecosystemOf := make(map[string]string)
+	for _, runner := range m.runners {
+		tagged := tagPackages(runner.summary.Regressions, runner.label())
+		for i, pkg := range runner.summary.Regressions {
+			ecosystemOf[tagged[i]] = runner.packageEcosystem(pkg)
+		}
+		regressions = append(regressions, tagged...)
+	}
+	budget := m.runners[0].failureBudget.evaluate(regressions, ecosystemOf)
+	return &budget
+}
+
+// backoffs merges the concurrency reductions of the runners
+func (m *MatrixRunner) backoffs() []concurrencyBackoff {
+	var backoffs []concurrencyBackoff
+	for _, runner := range m.runners {
+		backoffs = append(backoffs, runner.summary.Backoffs...)
+	}
+	return backoffs
+}
+
+// candidateChanges merges the changes of the candidate repository the
+// runners saw, with packages tagged with their repository type
+func (m *MatrixRunner) candidateChanges() []candidateChange {
+	var changes []candidateChange
+	for _, runner := range m.runners {
+		for _, change := range runner.summary.CandidateChanges {
+			change.Stale = tagPackages(change.Stale, runner.label())
+			changes = append(changes, change)
+		}
+	}
+	return changes
+}
+
+// label tags the packages of a runner in the merged report of a matrix: its
+// repository type, its variant or both
+func (r *RegressionTestRunner) label() string {
+	if r.matrixLabel != "" {
+		return r.matrixLabel
+	}
+	return r.repoType
+}
+
+// tagPackages tags packages with their repository type or variant, e.g.
+// "curl (wolfi)"
+func tagPackages(packages []string, label string) []string {
+	tagged := make([]string, 0, len(packages))
+	for _, pkg := range packages {
+		tagged = append(tagged, fmt.Sprintf("%s (%s)", pkg, label))
+	}
+	return tagged
+}
+
+// tagged returns the packages selected from each runner's summary, tagged
+// with their repository type, e.g. "curl (wolfi)"
+func (m *MatrixRunner) tagged(selectPackages func(runSummary) []string) []string {
+	var packages []string
+	for _, runner := range m.runners {
+		packages = append(packages, tagPackages(selectPackages(runner.summary), runner.label())...)
+	}
+	return packages
+}
+
+func (m *MatrixRunner) printSummary() {
+	fmt.Printf("\n=== Summary ===\n")
+	for _, runner := range m.runners {
+		s := runner.summary
+		fmt.Printf("%s: %d found, %d skipped, %d tested, %d regressions, %d hung, %d successful, %d failed\n",
+			runner.label(), s.Total, len(s.Skipped), s.Tested, len(s.Regressions), len(s.Hung), len(s.Successful), len(s.Failed))
+	}
+	for _, runner := range m.runners {
+		if runner.versionChange != nil {
+			fmt.Printf("Version change (%s): %s\n", runner.label(), runner.versionChange)
+		}
+	}
+	for _, runner := range m.runners {
+		if runner.advisories != nil {
+			fmt.Printf("Security fixes (%s): %s\n", runner.label(), runner.advisories)
+		}
+	}
+
+	if hung := m.tagged(func(s runSummary) []string { return s.Hung }); len(hung) > 0 {
+		fmt.Printf("\nTests that hung:\n")
+		for _, test := range hung {
+			fmt.Printf("  - %s\n", test)
+		}
+	}
+
+	var regressions []string
+	for _, runner := range m.runners {
+		for _, pkg := range runner.summary.Regressions {
+			regressions = append(regressions, fmt.Sprintf("%s (%s)%s%s", pkg, runner.label(), runner.regressionNote(pkg, " (%s)"), runner.previousNote(pkg, " (%s)")))
+		}
+	}
+	if len(regressions) > 0 {
+		fmt.Printf("\nPackages with regressions:\n")
+		for _, pkg := range regressions {
+			fmt.Printf("  - %s\n", pkg)
+		}
+	}
+
+	if fixed := m.tagged(func(s runSummary) []string { return s.Fixed }); len(fixed) > 0 {
+		fmt.Printf("\nFixed since last run:\n")
+		for _, pkg := range fixed {
+			fmt.Printf("  - %s\n", pkg)
+		}
+	}
+
+	if m.hasFailureClusters() {
+		fmt.Printf("\nFailure clusters:\n")
+		for _, runner := range m.runners {
+			for _, cluster := range runner.summary.FailureClusters {
+				fmt.Printf("  - %s: %s\n", runner.label(), cluster)
+			}
+		}
+	}
+	m.printVariants(os.Stdout)
+	printCandidateChanges(os.Stdout, m.candidateChanges())
+	printInfra(os.Stdout, m.tagged(func(s runSummary) []string { return s.Infra }))
+	printOOMKills(os.Stdout, m.oomKilled(), m.runners[0].concurrency)
+	printBackoffs(os.Stdout, m.backoffs())
+	printMemory(os.Stdout, m.memory(), m.runners[0].concurrency)
+	m.utilization().print(os.Stdout)
+}
+
+// utilization adds up the utilization of the runners, which ran one after
+// the other
+func (m *MatrixRunner) utilization() utilization {
+	var u utilization
+	for _, runner := range m.runners {
+		u = u.merge(runner.summary.Utilization)
+	}
+	return u
+}
+
+// memory returns the most memory-hungry packages of all repository types,
+// tagged with their repository type
+func (m *MatrixRunner) memory() []packageMemory {
+	var usage []packageMemory
+	for _, runner := range m.runners {
+		for _, u := range runner.summary.Memory {
+			usage = append(usage, packageMemory{Package: fmt.Sprintf("%s (%s)", u.Package, runner.label()), Peak: u.Peak})
+		}
+	}
+	return sortMemory(usage, memoryTopN)
+}
+
+// oomKilled returns the packages of all repository types killed for lack of
+// memory, tagged with their repository type
+func (m *MatrixRunner) oomKilled() []oomKill {
+	var kills []oomKill
+	for _, runner := range m.runners {
+		for _, kill := range runner.summary.OOMKilled {
+			kills = append(kills, oomKill{Package: fmt.Sprintf("%s (%s)", kill.Package, runner.label()), Peak: kill.Peak})
+		}
+	}
+	return kills
+}
+
+// hasFailureClusters reports whether several packages of any repository
+// type failed with the same error
+func (m *MatrixRunner) hasFailureClusters() bool {
+	for _, runner := range m.runners {
+		if len(runner.summary.FailureClusters) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *MatrixRunner) printMarkdownSummary(w io.Writer) {
+	fmt.Fprintf(w, "\n## APK Regression Test Summary\n\n")
+	fmt.Fprintf(w, "**Package:** %s  \n", m.targetSpec())
+	fmt.Fprintf(w, "**APK Repository:** %s  \n", m.apkRepo)
+	fmt.Fprintf(w, "**Test Duration:** %v  \n\n", time.Since(m.startTime).Round(time.Second))
+
+	fmt.Fprintf(w, "### Test Results\n\n")
+	column := "Repository"
+	if m.variants {
+		column = "Variant"
+	}
+	fmt.Fprintf(w, "| %s | Found | Skipped (no YAML) | Tested | **Regressions** | Hung | Successful | Failed |\n", column)
+	fmt.Fprintf(w, "|------------|-------|-------------------|--------|-----------------|------|------------|--------|\n")
+	for _, runner := range m.runners {
+		s := runner.summary
+		fmt.Fprintf(w, "| %s | %d | %d | %d | **%d** | %d | %d | %d |\n",
+			runner.label(), s.Total, len(s.Skipped), s.Tested, len(s.Regressions), len(s.Hung), len(s.Successful), len(s.Failed))
+	}
+
+	// The package usually lives in only one of the repositories
+	for _, runner := range m.runners {
+		if runner.versionChange != nil {
+			runner.versionChange.printMarkdown(w)
+		}
+	}
+	for _, runner := range m.runners {
+		if runner.advisories != nil {
+			runner.advisories.printMarkdown(w)
+		}
+	}
+	if gate := m.gate(); gate != nil {
+		gate.printMarkdown(w)
+	}
+	if budget := m.budget(); budget != nil {
+		budget.printMarkdown(w)
+	}
+
+	var regressionCount, hungCount int
+	for _, runner := range m.runners {
+		regressionCount += len(runner.summary.Regressions)
+		hungCount += len(runner.summary.Hung)
+	}
+
+	if regressionCount > 0 {
+		fmt.Fprintf(w, "\n### 🔴 Packages with Regressions\n\n")
+		fmt.Fprintf(w, "The following packages **fail with the new APK repository** but **pass without it**, indicating potential regressions:\n\n")
+		for _, runner := range m.runners {
+			for _, pkg := range runner.summary.Regressions {
+				fmt.Fprintf(w, "- `%s` (%s)%s%s%s\n", pkg, runner.label(), runner.regressionNote(pkg, " **(%s)**"), runner.previousNote(pkg, " (%s)"), runner.markdownResultDetails(pkg))
+			}
+		}
+	}
+
+	if fixed := m.tagged(func(s runSummary) []string { return s.Fixed }); len(fixed) > 0 {
+		fmt.Fprintf(w, "\n### 🟢 Fixed Since Last Run\n\n")
+		fmt.Fprintf(w, "The following packages regressed in the previous run but pass now:\n\n")
+		for _, pkg := range fixed {
+			fmt.Fprintf(w, "- `%s`\n", pkg)
+		}
+	}
+
+	if hungCount > 0 {
+		fmt.Fprintf(w, "\n### ⏰ Tests That Hung\n\n")
+		fmt.Fprintf(w, "The following tests were killed after their timeout:\n\n")
+		for _, runner := range m.runners {
+			for _, test := range runner.summary.Hung {
+				fmt.Fprintf(w, "- `%s (%s)` (after %v)\n", test, runner.label(), runner.hungTimeout(test))
+			}
+		}
+	}
+
+	if m.hasFailureClusters() {
+		fmt.Fprintf(w, "\n### 🔁 Failure Clusters\n\n")
+		fmt.Fprintf(w, "These packages failed with the same error, which usually points at a shared cause rather than each package:\n\n")
+		for _, runner := range m.runners {
+			for _, cluster := range runner.summary.FailureClusters {
+				fmt.Fprintf(w, "- (%s) %s\n", runner.label(), cluster.markdown(runner.logLink(cluster.LogPath)))
+			}
+		}
+	}
+	m.printVariantsMarkdown(w)
+	printCandidateChangesMarkdown(w, m.candidateChanges())
+	printInfraMarkdown(w, m.tagged(func(s runSummary) []string { return s.Infra }))
+	printOOMKillsMarkdown(w, m.oomKilled(), m.runners[0].concurrency)
+	printBackoffsMarkdown(w, m.backoffs())
+	printMemoryMarkdown(w, m.memory())
+	m.utilization().printMarkdown(w)
+
+	if regressionCount == 0 && hungCount == 0 {
+		fmt.Fprintf(w, "\n### ✅ All Tests Passed\n\n")
+		fmt.Fprintf(w, "No regressions were detected in any repository. All packages either passed with the new repository or failed consistently in both scenarios.\n")
+	}
+
+	fmt.Fprintf(w, "\n---\n")
+	fmt.Fprintf(w, "*Generated by apk-regression-test-runner*\n")
+}
+
+// writeResults writes the merged result files to the shared log directory.
+// Entries are prefixed with their repository type, e.g. "wolfi/curl".
+func (m *MatrixRunner) writeResults() {
+	lists := map[string]func(runSummary) []string{
+		"successful":  func(s runSummary) []string { return s.Successful },
+		"failed":      func(s runSummary) []string { return s.Failed },
+		"regressions": func(s runSummary) []string { return s.Regressions },
+		"hung":        func(s runSummary) []string { return s.Hung },
+		"skipped":     func(s runSummary) []string { return s.Skipped },
+	}
+	entries := make(map[string][]resultEntry, len(lists))
+	for list, selectPackages := range lists {
+		for _, runner := range m.runners {
+			entries[list] = append(entries[list], resultEntries(runner.label(), selectPackages(runner.summary))...)
+		}
+	}
+	m.resultFiles.write(m.logDir, true, entries)
+
+	var all []TestResult
+	for _, runner := range m.runners {
+		for _, pkg := range sortedKeys(runner.packageResults) {
+			for _, withRepo := range []bool{true, false} {
+				if result, ok := runner.packageResults[pkg][withRepo]; ok {
+					all = append(all, result)
+				}
+			}
+		}
+	}
+
+	data, err := json.MarshalIndent(all, "", "  ")
+	if err != nil {
+		fmt.Printf("Warning: failed to encode results.json: %v\n", err)
+		return
+	}
+	if err := os.WriteFile(filepath.Join(m.logDir, "results.json"), append(data, '\n'), 0644); err != nil {
+		fmt.Printf("Warning: failed to write results.json: %v\n", err)
+	}
+}
+
+// summaryReport merges the summaries of the runners, tagging packages with
+// their repository type
+func (m *MatrixRunner) summaryReport() SummaryReport {
+	summary := SummaryReport{
+		Package:     m.packageName,
+		APKRepo:     m.apkRepo,
+		LogDir:      m.logDir,
+		Duration:    time.Since(m.startTime).Round(time.Second).String(),
+		Skipped:     nonNil(m.tagged(func(s runSummary) []string { return s.Skipped })),
+		Successful:  nonNil(m.tagged(func(s runSummary) []string { return s.Successful })),
+		Failed:      nonNil(m.tagged(func(s runSummary) []string { return s.Failed })),
+		Regressions: nonNil(m.tagged(func(s runSummary) []string { return s.Regressions })),
+		Hung:        nonNil(m.tagged(func(s runSummary) []string { return s.Hung })),
+		Fixed:       m.tagged(func(s runSummary) []string { return s.Fixed }),
+		Memory:      m.memory(),
+		Utilization: m.utilization(),
+		Gate:        m.gate(),
+		Budget:      m.budget(),
+
+		ArchExcluded:     m.tagged(func(s runSummary) []string { return s.ArchExcluded }),
+		Untestable:       m.tagged(func(s runSummary) []string { return s.Untestable }),
+		CandidateChanges: m.candidateChanges(),
+		Backoffs:         m.backoffs(),
+		Infra:            m.tagged(func(s runSummary) []string { return s.Infra }),
+		OOMKilled:        m.oomKilled(),
+		Variants:         m.variantResults(),
+	}
+	for _, runner := range m.runners {
+		summary.Total += runner.summary.Total
+		summary.Tested += runner.summary.Tested
+		summary.Unchanged += len(runner.unchanged)
+		summary.Unaffected += len(runner.unaffected)
+		if summary.VersionChange == nil {
+			summary.VersionChange = runner.versionChange
+		}
+		if summary.PackageVersion == "" {
+			summary.PackageVersion = runner.targetVersion
+		}
+	}
+	return summary
+}
+
+// reportCI writes the CI outputs of the matrix, configured like its runners
+func (m *MatrixRunner) reportCI() {
+	if len(m.runners) == 0 {
+		return
+	}
+	summary := m.summaryReport()
+	if m.runners[0].summaryJSON {
+		writeSummaryJSON(m.logDir, summary)
+	}
+	github := m.runners[0].github
+	if github == nil {
+		return
+	}
+	if err := github.appendSummary(m.printMarkdownSummary); err != nil {
+		fmt.Printf("Warning: %v\n", err)
+	}
+	if err := github.setOutputs(githubOutputs(m.logDir, summary)); err != nil {
+		fmt.Printf("Warning: %v\n", err)
+	}
+}
@@ -0,0 +1,110 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewMatrixRunner(t *testing.T) {
+	wolfi := NewRegressionTestRunner("openssl", "https://example.com/repo", "/tmp/os", "wolfi", 4, false, 30*time.Minute, false)
+	enterprise := NewRegressionTestRunner("openssl", "https://example.com/repo", "/tmp/enterprise", "enterprise", 4, false, time.Hour, false)
+
+	matrix := NewMatrixRunner("openssl", "https://example.com/repo", []*RegressionTestRunner{wolfi, enterprise}, false)
+
+	if !strings.HasPrefix(matrix.logDir, filepath.Join("logs", "regression-test-openssl-")) {
+		t.Errorf("Unexpected matrix log dir: %s", matrix.logDir)
+	}
+
+	for _, runner := range []*RegressionTestRunner{wolfi, enterprise} {
+		expected := filepath.Join(matrix.logDir, runner.repoType)
+		if runner.logDir != expected || runner.melange.logDir != expected {
+			t.Errorf("Expected %s runner to log to %s, got %s (melange: %s)", runner.repoType, expected, runner.logDir, runner.melange.logDir)
+		}
+		if !runner.inMatrix {
+			t.Errorf("Expected %s runner to be part of the matrix", runner.repoType)
+		}
+	}
+
+	if matrix.hangTimeout != time.Hour {
+		t.Errorf("Expected hang timeout 1h, got %v", matrix.hangTimeout)
+	}
+}
+
+func TestMatrixWriteResults(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	wolfi := &RegressionTestRunner{
+		repoType: "wolfi",
+		summary:  runSummary{Regressions: []string{"curl"}, Successful: []string{"git"}},
+		packageResults: map[string]map[bool]TestResult{
+			"curl": {
+				true:  {Package: "curl", WithRepo: true, RepoType: "wolfi", Classification: ClassificationFail},
+				false: {Package: "curl", WithRepo: false, Success: true, RepoType: "wolfi", Classification: ClassificationPass},
+			},
+		},
+	}
+	enterprise := &RegressionTestRunner{
+		repoType: "enterprise",
+		summary:  runSummary{Regressions: []string{"curl-fips"}},
+		packageResults: map[string]map[bool]TestResult{
+			"curl-fips": {
+				true: {Package: "curl-fips", WithRepo: true, RepoType: "enterprise", Classification: ClassificationFail},
+			},
+		},
+	}
+
+	matrix := &MatrixRunner{logDir: tmpDir, runners: []*RegressionTestRunner{wolfi, enterprise}}
+	matrix.writeResults()
+
+	content, err := os.ReadFile(filepath.Join(tmpDir, "regressions.txt"))
+	if err != nil {
+		t.Fatalf("Failed to read regressions.txt: %v", err)
+	}
+	if string(content) != "wolfi/curl\nenterprise/curl-fips\n" {
+		t.Errorf("Unexpected regressions.txt content: %q", content)
+	}
+
+	data, err := os.ReadFile(filepath.Join(tmpDir, "results.json"))
+	if err != nil {
+		t.Fatalf("Failed to read results.json: %v", err)
+	}
+	var results []map[string]interface{}
+	if err := json.Unmarshal(data, &results); err != nil {
+		t.Fatalf("Failed to parse results.json: %v", err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("Expected 3 results, got %d", len(results))
+	}
+	if results[2]["repoType"] != "enterprise" {
+		t.Errorf("Expected last result to be tagged enterprise, got %v", results[2]["repoType"])
+	}
+
+	tagged := matrix.tagged(func(s runSummary) []string { return s.Regressions })
+	if strings.Join(tagged, ",") != "curl (wolfi),curl-fips (enterprise)" {
+		t.Errorf("Unexpected tagged regressions: %v", tagged)
+	}
+}
+
+func TestMatrixValidatePackage(t *testing.T) {
+	wolfi := NewRegressionTestRunner("openssl", "https://example.com/repo", "/tmp/os", "wolfi", 4, false, 30*time.Minute, false)
+	enterprise := NewRegressionTestRunner("openssl", "https://example.com/repo", "/tmp/enterprise", "enterprise", 4, false, time.Hour, false)
+	wolfi.apkrane.packages = []Package{{Name: "openssl", Origin: "openssl"}}
+	enterprise.apkrane.packages = []Package{{Name: "nginx-fips", Origin: "nginx-fips"}}
+
+	matrix := NewMatrixRunner("openssl", "https://example.com/repo", []*RegressionTestRunner{wolfi, enterprise}, false)
+	if err := matrix.validatePackage(); err != nil {
+		t.Errorf("Expected a package in one of the indexes to be valid, got %v", err)
+	}
+
+	matrix.packageName = "opensll"
+	if err := matrix.validatePackage(); err == nil || !strings.Contains(err.Error(), "did you mean openssl?") {
+		t.Errorf("Expected an unknown package error with a suggestion, got %v", err)
+	}
+}
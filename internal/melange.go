@@ -7,10 +7,13 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
-	"syscall"
+	"regexp"
+	"strings"
+	"text/template"
 	"time"
 )
 
@@ -19,11 +22,45 @@ type MelangeClient struct {
 	verbose     bool
 	logDir      string
 	hangTimeout time.Duration
+	timeouts    map[string]time.Duration
+	locator     ConfigLocator
+	// cacheDir is shared between tests and mounted by melange, with
+	// cacheEnvFile pointing the build tools at it
+	cacheDir     string
+	cacheEnvFile string
+	apkCacheDir  string
+	// runner is the melange runner tests use, or "" for melange's default
+	runner string
+	// output also receives the test output when set
+	output io.Writer
+	// collectArtifacts enables collecting test artifacts (see
+	// collectArtifacts) below logDir
+	collectArtifacts bool
+	// auth returns the HTTP_AUTH value tests fetch packages with, if set
+	auth func() (string, error)
+	// pipelineOnly and pipelineSkip restrict the test pipelines melange
+	// runs (see SetPipelineFilter)
+	pipelineOnly []string
+	pipelineSkip []string
+	// testCommand replaces make as the command running tests, rendered
+	// from testCommandText (see SetTestCommand)
+	testCommand     *template.Template
+	testCommandText string
+	// env is added to the environment of tests (see SetTestEnv)
+	env map[string]string
+	// pins are added to the environments of with-repo tests (see SetPins)
+	pins []Pin
+	// follower streams the output of followed packages (see SetFollow)
+	follower *follower
 }
 
 // ErrPackageYAMLNotFound indicates that the package YAML file doesn't exist
 var ErrPackageYAMLNotFound = errors.New("package YAML file not found")
 
+// ErrArchExcluded indicates that the package isn't built for the
+// architecture tests run on, so it can't be tested there
+var ErrArchExcluded = errors.New("package is not built for this architecture")
+
 // ErrTestHung indicates that a test exceeded the timeout and was killed
 var ErrTestHung = errors.New("test hung and was killed after timeout")
 
@@ -33,108 +70,443 @@ func NewMelangeClient(repoPath string, verbose bool, logDir string, hangTimeout
 		verbose:     verbose,
 		logDir:      logDir,
 		hangTimeout: hangTimeout,
+		locator:     newOriginLocator(&flatLocator{repoPath: repoPath}),
+		follower:    newFollower(!verbose),
+	}
+}
+
+// SetConfigLocator changes how package YAML files are found in the repository
+func (m *MelangeClient) SetConfigLocator(locator ConfigLocator) {
+	m.locator = locator
+}
+
+// SetPackageTimeout overrides the hang timeout for a single package
+func (m *MelangeClient) SetPackageTimeout(packageName string, timeout time.Duration) {
+	if m.timeouts == nil {
+		m.timeouts = make(map[string]time.Duration)
+	}
+	m.timeouts[packageName] = timeout
+}
+
+func (m *MelangeClient) timeoutFor(packageName string) time.Duration {
+	if timeout, ok := m.timeouts[packageName]; ok {
+		return timeout
+	}
+	return m.hangTimeout
+}
+
+// SetBuildCache shares the build caches in dir (see PrepareBuildCache)
+// between all tests, so similar reverse dependencies don't each rebuild or
+// redownload the same objects and modules
+func (m *MelangeClient) SetBuildCache(dir string) error {
+	envFile, err := PrepareBuildCache(dir)
+	if err != nil {
+		return err
+	}
+	m.cacheDir = filepath.Dir(envFile)
+	m.cacheEnvFile = envFile
+	return nil
+}
+
+// SetAPKCache makes all tests share one APK download cache, so concurrent
+// tests don't each download the same base packages
+func (m *MelangeClient) SetAPKCache(dir string) {
+	m.apkCacheDir = dir
+}
+
+// SetRunner selects the melange runner tests use, e.g. docker on hosts
+// where melange can't sandbox tests itself (see CheckPlatform)
+func (m *MelangeClient) SetRunner(runner string) {
+	m.runner = runner
+}
+
+// SetArtifactCollection collects core dumps, a snapshot of the scratch
+// directory of failed tests and whatever tests deposit in the directory
+// named by ArtifactsEnv
+func (m *MelangeClient) SetArtifactCollection(enabled bool) {
+	m.collectArtifacts = enabled
+}
+
+// SetPipelineFilter restricts the test pipelines melange runs to those
+// matching only, if given, and leaves out those matching skip. Filtered
+// tests give a faster but partial signal, e.g. import checks only.
+func (m *MelangeClient) SetPipelineFilter(only, skip []string) {
+	m.pipelineOnly = only
+	m.pipelineSkip = skip
+}
+
+// pipelineFilter describes the pipeline filter, or returns "" without one
+func (m *MelangeClient) pipelineFilter() string {
+	if len(m.pipelineOnly) == 0 && len(m.pipelineSkip) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("only=%s skip=%s", strings.Join(m.pipelineOnly, ","), strings.Join(m.pipelineSkip, ","))
+}
+
+// MelangeTestSupports tells whether the installed melange's test command
+// has the option flag, e.g. "--test-pipeline-only"
+func MelangeTestSupports(flag string) (bool, error) {
+	if FakeBackend() {
+		return true, nil
+	}
+	output, err := exec.Command("melange", "test", "--help").Output()
+	if err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return false, fmt.Errorf("%w: %w", ErrMelangeMissing, err)
+		}
+		return false, fmt.Errorf("failed to run melange test --help: %w", commandError(err))
+	}
+	return regexp.MustCompile(regexp.QuoteMeta(flag) + `(\s|=|$)`).Match(output), nil
+}
+
+// SetAuth makes tests authenticate to package repositories with the
+// HTTP_AUTH value auth returns (see RepositoryAuth). It is called for every
+// test, so that tokens expiring during long runs are refreshed.
+func (m *MelangeClient) SetAuth(auth func() (string, error)) {
+	m.auth = auth
+}
+
+// RepositoryAuth returns the HTTP_AUTH value for tests of repoType against
+// apkRepo, or nil if they need none. HTTP_AUTH holds the credentials of a
+// single host: those in the credentials file for the candidate repository's
+// host if there are any, or else the chainctl credentials of the repository
+// type's host, which tests need when it authenticates with chainctl or the
+// candidate repository is served from apk.cgr.dev. HTTP_AUTH set in the
+// environment takes precedence.
+func RepositoryAuth(repoType, apkRepo string) func() (string, error) {
+	if os.Getenv("HTTP_AUTH") != "" {
+		return nil
+	}
+
+	// Credentials are sent to the mirror when a repository is mirrored
+	candidateHost := urlHost(mirrorURL(apkRepo))
+	if _, ok := credentials[candidateHost]; ok && candidateHost != "" {
+		return func() (string, error) {
+			auth, _, err := hostCredentials(candidateHost)
+			return auth.httpAuth(candidateHost), err
+		}
+	}
+
+	var host, audience string
+	switch {
+	case repoTypeRequiresAuth(repoType):
+		t, _ := LookupRepoType(repoType)
+		host, audience = urlHost(mirrorURL(t.Index)), t.audience()
+	case urlHost(apkRepo) == "apk.cgr.dev":
+		host, audience = urlHost(mirrorURL("https://apk.cgr.dev")), "apk.cgr.dev"
+	default:
+		return nil
+	}
+	return func() (string, error) {
+		auth, err := chainctlCredentials(host, audience)
+		return auth.httpAuth(host), err
+	}
+}
+
+// SetTestEnv adds env to the environment of every test, both to that of the
+// test command and, through an environment file, to melange's test
+// environment, e.g. to test with a feature flag set
+func (m *MelangeClient) SetTestEnv(env map[string]string) {
+	m.env = env
+}
+
+// writeTestEnv writes the environment file of a test to dir, adding the
+// test environment to the build cache environment
+func (m *MelangeClient) writeTestEnv(dir string) (string, error) {
+	var env []byte
+	if m.cacheEnvFile != "" {
+		data, err := os.ReadFile(m.cacheEnvFile)
+		if err != nil {
+			return "", fmt.Errorf("failed to read build cache environment: %w", err)
+		}
+		env = data
+	}
+	for _, name := range sortedKeys(m.env) {
+		env = append(env, fmt.Sprintf("%s=%s\n", name, m.env[name])...)
 	}
+	path := filepath.Join(dir, testEnvFile)
+	if err := os.WriteFile(path, env, 0644); err != nil {
+		return "", fmt.Errorf("failed to write test environment: %w", err)
+	}
+	return path, nil
+}
+
+// SetOutput streams the output of every test to w in addition to its log
+func (m *MelangeClient) SetOutput(w io.Writer) {
+	m.output = w
+}
+
+// repoOpts returns the melange options adding the candidate repository
+func repoOpts(apkRepo string) []string {
+	return []string{"--repository-append", mirrorURL(apkRepo)}
 }
 
+// extraOpts returns the options passed to melange through MELANGE_EXTRA_OPTS
+func (m *MelangeClient) extraOpts(withRepo bool, apkRepo string) []string {
+	var opts []string
+	if withRepo {
+		opts = append(opts, repoOpts(apkRepo)...)
+	}
+	if m.cacheDir != "" {
+		opts = append(opts, "--cache-dir", m.cacheDir)
+		// With a test environment, the build cache environment is part of
+		// the environment file of each test
+		if len(m.env) == 0 {
+			opts = append(opts, "--env-file", m.cacheEnvFile)
+		}
+	}
+	if m.apkCacheDir != "" {
+		opts = append(opts, "--apk-cache-dir", m.apkCacheDir)
+	}
+	if m.runner != "" {
+		opts = append(opts, "--runner", m.runner)
+	}
+	return opts
+}
+
+// pipelineOpts returns the melange options filtering the test pipelines of
+// a test. Smoke tests skip all of them.
+func (m *MelangeClient) pipelineOpts(smoke bool) []string {
+	if smoke {
+		return []string{"--test-pipeline-skip=*"}
+	}
+	var opts []string
+	for _, pipeline := range m.pipelineOnly {
+		opts = append(opts, "--test-pipeline-only="+pipeline)
+	}
+	for _, pipeline := range m.pipelineSkip {
+		opts = append(opts, "--test-pipeline-skip="+pipeline)
+	}
+	return opts
+}
+
+// TestPackage runs the test of a package and returns why it failed, if it did
 func (m *MelangeClient) TestPackage(packageName string, withRepo bool, apkRepo string) error {
-	// Check if the package YAML file exists
-	yamlFilePath := filepath.Join(m.repoPath, fmt.Sprintf("%s.yaml", packageName))
-	if _, err := os.Stat(yamlFilePath); os.IsNotExist(err) {
+	return m.RunTest(packageName, withRepo, apkRepo).Error
+}
+
+// RunTest runs the test of a package and returns its detailed result
+func (m *MelangeClient) RunTest(packageName string, withRepo bool, apkRepo string) TestResult {
+	return m.Execute(context.Background(), packageName, ExecuteOptions{WithRepo: withRepo, APKRepo: apkRepo})
+}
+
+// Execute implements TestExecutor by running the package's melange test
+// through the repository's Makefile
+func (m *MelangeClient) Execute(ctx context.Context, packageName string, opts ExecuteOptions) TestResult {
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = m.timeoutFor(packageName)
+	}
+
+	var artifacts string
+	if m.collectArtifacts {
+		artifacts = artifactDir(m.logDir, packageName, opts.WithRepo)
+	}
+
+	toolchain := probeToolchain(m.repoPath)
+	startedAt := time.Now()
+	var usage testUsage
+	var command *CommandLine
+	logPath, err := m.runTest(ctx, packageName, opts, timeout, artifacts, &usage, &command)
+	result := newTestResult(packageName, opts.WithRepo, startedAt, logPath, err)
+	result.Command = command
+	result.Toolchain = toolchain
+	result.PeakMemory, result.CPUTime = usage.PeakMemory, usage.CPUTime
+	result.OOMKilled = usage.OOMKilled && !result.Success
+	if artifacts != "" && hasArtifacts(artifacts) {
+		result.ArtifactsDir = artifacts
+	}
+	if !result.Success && !result.Skipped && logPath != "" {
+		result.FailingStep = FailingStep(logPath)
+	}
+	return result
+}
+
+// locateConfig returns the melange config of packageName, or an error if
+// it has none or the package isn't built for the host architecture
+func (m *MelangeClient) locateConfig(packageName string) (string, error) {
+	configPath, err := m.locator.Locate(packageName)
+	if err != nil {
+		if m.verbose && errors.Is(err, ErrPackageYAMLNotFound) {
+			fmt.Printf("Skipping %s: YAML file not found in %s\n", packageName, m.repoPath)
+		}
+		return "", err
+	}
+
+	// Packages restricted to other architectures would only fail. Configs
+	// that can't be parsed are left for the test to report.
+	if config, err := LoadMelangeConfig(configPath); err == nil && !config.BuildsFor(hostArch()) {
 		if m.verbose {
-			fmt.Printf("Skipping %s: YAML file not found at %s\n", packageName, yamlFilePath)
+			fmt.Printf("Skipping %s: only built for %s\n", packageName, strings.Join(config.Package.TargetArchitecture, ", "))
 		}
-		return ErrPackageYAMLNotFound
+		return "", fmt.Errorf("%w: %s is only built for %s", ErrArchExcluded, packageName, strings.Join(config.Package.TargetArchitecture, ", "))
 	}
+	return configPath, nil
+}
+
+// runTest runs `make test/<package>`, or the test command if set, and returns
+// the path of its log file. Artifacts are collected in artifacts unless it is
+// empty. The resources the test used are recorded in usage, and the command
+// line it ran in command.
+func (m *MelangeClient) runTest(ctx context.Context, packageName string, opts ExecuteOptions, timeout time.Duration, artifacts string, usage *testUsage, command **CommandLine) (string, error) {
+	withRepo, apkRepo, tempDir := opts.WithRepo, opts.APKRepo, opts.WorkDir
 
-	// Create temporary directory for build
-	tempDir, err := os.MkdirTemp("/tmp", fmt.Sprintf("melange-build-%s-", packageName))
+	configPath, err := m.locateConfig(packageName)
 	if err != nil {
-		return fmt.Errorf("failed to create temp directory: %w", err)
+		return "", err
 	}
-	defer os.RemoveAll(tempDir)
 
-	var cmd *exec.Cmd
-	target := fmt.Sprintf("test/%s", packageName)
+	// Create temporary directory for build unless the caller provided one
+	if tempDir == "" {
+		tempDir, err = os.MkdirTemp("", fmt.Sprintf("melange-build-%s-", packageName))
+		if err != nil {
+			return "", fmt.Errorf("failed to create temp directory: %w", err)
+		}
+		defer os.RemoveAll(tempDir)
+	}
 
-	// Create log file name
+	// The previous version's config is at the same path in its checkout
+	checkout := m.repoPath
+	if opts.Previous != "" {
+		rel, err := filepath.Rel(m.repoPath, configPath)
+		if err != nil {
+			return "", err
+		}
+		checkout, configPath = opts.Previous, filepath.Join(opts.Previous, rel)
+		if _, err := os.Stat(configPath); err != nil {
+			return "", fmt.Errorf("%w: %s at its previous version", ErrPackageYAMLNotFound, rel)
+		}
+	}
+
+	if m.verbose && configTarget(configPath) != packageName {
+		fmt.Printf("Testing %s using config %s\n", packageName, configPath)
+	}
+
+	// Create log file name; re-runs keeping the workspace and smoke tests
+	// log separately
 	logFileName := fmt.Sprintf("%s_%s.log", packageName, map[bool]string{true: "with_repo", false: "without_repo"}[withRepo])
+	if opts.WorkspaceDir != "" {
+		logFileName = strings.TrimSuffix(logFileName, ".log") + "_workspace.log"
+	}
+	if opts.Smoke {
+		logFileName = strings.TrimSuffix(logFileName, ".log") + "_smoke.log"
+	}
+	if opts.Previous != "" {
+		logFileName = strings.TrimSuffix(logFileName, ".log") + "_previous.log"
+	}
 	logFilePath := filepath.Join(m.logDir, logFileName)
 
 	// Create and open log file
 	logFile, err := os.Create(logFilePath)
 	if err != nil {
-		return fmt.Errorf("failed to create log file %s: %w", logFilePath, err)
+		return "", fmt.Errorf("failed to create log file %s: %w", logFilePath, err)
 	}
 	defer logFile.Close()
 
-	if withRepo {
-		if m.verbose {
+	if m.verbose {
+		if withRepo {
 			fmt.Printf("Testing %s with APK repository: %s (temp: %s, log: %s)\n", packageName, apkRepo, tempDir, logFilePath)
-		}
-		cmd = exec.Command("make", target)
-		extraOpts := fmt.Sprintf("--repository-append %s", apkRepo)
-		cmd.Env = append(os.Environ(),
-			fmt.Sprintf("MELANGE_EXTRA_OPTS=%s", extraOpts),
-			fmt.Sprintf("TMPDIR=%s", tempDir))
-	} else {
-		if m.verbose {
+		} else {
 			fmt.Printf("Testing %s without APK repository (temp: %s, log: %s)\n", packageName, tempDir, logFilePath)
 		}
-		cmd = exec.Command("make", target)
-		cmd.Env = append(os.Environ(), fmt.Sprintf("TMPDIR=%s", tempDir))
+	}
+	extraOpts := append(m.extraOpts(false, ""), m.pipelineOpts(opts.Smoke)...)
+	if opts.WorkspaceDir != "" {
+		extraOpts = append(extraOpts, "--workspace-dir", opts.WorkspaceDir)
+	}
+	if len(m.env) > 0 {
+		envFile, err := m.writeTestEnv(tempDir)
+		if err != nil {
+			fmt.Fprintf(logFile, "=== FAILED TO PREPARE TEST ENVIRONMENT: %v ===\n", err)
+			return logFilePath, err
+		}
+		extraOpts = append(extraOpts, "--env-file", envFile)
+	}
+	cmd, description, err := m.command(packageName, configPath, opts, tempDir, extraOpts)
+	if err != nil {
+		fmt.Fprintf(logFile, "=== FAILED TO PREPARE TEST COMMAND: %v ===\n", err)
+		return logFilePath, err
+	}
+	environ := os.Environ()
+	cmd.Env = append(environ, fmt.Sprintf("TMPDIR=%s", tempDir))
+	for _, name := range sortedKeys(m.env) {
+		cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", name, m.env[name]))
+	}
+	if withRepo {
+		extraOpts = append(append(repoOpts(apkRepo), m.pinOpts()...), extraOpts...)
+	}
+	if len(extraOpts) > 0 {
+		cmd.Env = append(cmd.Env, fmt.Sprintf("MELANGE_EXTRA_OPTS=%s", strings.Join(extraOpts, " ")))
+	}
+	if m.auth != nil {
+		httpAuth, err := m.auth()
+		if err != nil {
+			fmt.Fprintf(logFile, "=== FAILED TO SET UP AUTHENTICATION: %v ===\n", err)
+			return logFilePath, fmt.Errorf("failed to setup authentication: %w", err)
+		}
+		cmd.Env = append(cmd.Env, fmt.Sprintf("HTTP_AUTH=%s", httpAuth))
+	}
+	// testErr is why the test failed, for collecting artifacts
+	var testErr error
+	if artifacts != "" {
+		absArtifacts, err := filepath.Abs(artifacts)
+		if err == nil {
+			err = os.MkdirAll(absArtifacts, 0755)
+		}
+		if err != nil {
+			return logFilePath, fmt.Errorf("failed to create artifact directory: %w", err)
+		}
+		cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", ArtifactsEnv, absArtifacts))
+		defer func() {
+			if err := collectArtifacts(tempDir, artifacts, testErr != nil); err != nil {
+				fmt.Fprintf(logFile, "\n=== FAILED TO COLLECT ARTIFACTS: %v ===\n", err)
+			}
+		}()
 	}
 
-	cmd.Dir = m.repoPath
-	cmd.Stdout = logFile
-	cmd.Stderr = logFile
-	// Set up process group so we can kill all child processes on timeout
-	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
-
-	// Create context with configurable timeout
-	ctx, cancel := context.WithTimeout(context.Background(), m.hangTimeout)
-	defer cancel()
+	cmd.Dir = checkout
+	*command = newCommandLine(cmd, len(environ))
+	if m.verbose {
+		fmt.Printf("Running %s\n", *command)
+	}
+	follow := m.follower.writer(packageName, withRepo)
+	defer follow.flush()
+	writers := []io.Writer{logFile, follow}
+	if m.output != nil {
+		writers = append(writers, m.output)
+	}
+	output := io.MultiWriter(writers...)
+	cmd.Stdout = output
+	cmd.Stderr = output
 
 	// Start the command
-	if err := cmd.Start(); err != nil {
-		return fmt.Errorf("failed to start make test/%s: %w", packageName, err)
+	if err := startInProcessGroup(cmd); err != nil {
+		if errors.Is(err, exec.ErrNotFound) && m.testCommand == nil {
+			err = fmt.Errorf("%w: %w", ErrMakeMissing, err)
+		}
+		return logFilePath, fmt.Errorf("failed to start %s: %w", description, err)
 	}
+	defer trackTest(cmd)()
 
-	// Channel to capture the result of cmd.Wait()
-	done := make(chan error, 1)
-	go func() {
-		done <- cmd.Wait()
-	}()
+	testErr = waitMeasured(ctx, cmd, timeout, usage)
+	if err := testErr; err != nil {
+		if errors.Is(err, ErrTestHung) {
+			// Write timeout message to log
+			fmt.Fprintf(logFile, "\n\n=== TEST HUNG - KILLED AFTER %v ===\n", timeout)
 
-	// Wait for either completion or timeout
-	select {
-	case err := <-done:
-		if err != nil {
-			return fmt.Errorf("make test/%s failed: %w", packageName, err)
-		}
-		return nil
-	case <-ctx.Done():
-		// Timeout occurred, kill the entire process group
-		if cmd.Process != nil {
-			// Kill the entire process group to ensure all child processes are terminated
-			pgid, err := syscall.Getpgid(cmd.Process.Pid)
-			if err == nil {
-				// Kill the process group (negative PID kills the group)
-				syscall.Kill(-pgid, syscall.SIGKILL)
-			} else {
-				// Fallback to killing just the main process
-				cmd.Process.Kill()
+			if m.verbose {
+				fmt.Printf("Test %s hung and was killed after %v\n", packageName, timeout)
 			}
-		}
-		// Wait for the process to actually exit
-		<-done
-
-		// Write timeout message to log
-		fmt.Fprintf(logFile, "\n\n=== TEST HUNG - KILLED AFTER %v ===\n", m.hangTimeout)
 
-		if m.verbose {
-			fmt.Printf("Test %s hung and was killed after %v\n", packageName, m.hangTimeout)
+			return logFilePath, ErrTestHung
 		}
-
-		return ErrTestHung
+		// make exits with status 2 when it has no rule for the target
+		if m.testCommand == nil && exitCode(err) == 2 {
+			if untestable := untestableTarget(logFilePath, checkout, "test/"+configTarget(configPath)); untestable != nil {
+				return logFilePath, untestable
+			}
+		}
+		return logFilePath, fmt.Errorf("%s failed: %w", description, err)
 	}
+	return logFilePath, nil
 }
@@ -4,8 +4,11 @@
 package internal
 
 import (
+	"bytes"
+	"context"
 	"errors"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"strings"
 	"testing"
@@ -126,6 +129,41 @@ func TestTestPackageYAMLExists(t *testing.T) {
 	}
 }
 
+func TestRunTestResultDetails(t *testing.T) {
+	tmpDir := t.TempDir()
+	logDir := filepath.Join(tmpDir, "logs")
+	if err := os.MkdirAll(logDir, 0755); err != nil {
+		t.Fatalf("Failed to create log dir: %v", err)
+	}
+
+	client := NewMelangeClient(tmpDir, false, logDir, time.Second)
+
+	skipped := client.RunTest("missing-package", true, "http://example.com/repo")
+	if !skipped.Skipped || skipped.Classification != ClassificationSkipped || skipped.LogPath != "" {
+		t.Errorf("Unexpected result for missing YAML: %+v", skipped)
+	}
+
+	if err := os.WriteFile(filepath.Join(tmpDir, "test-package.yaml"), []byte("test"), 0644); err != nil {
+		t.Fatalf("Failed to create YAML file: %v", err)
+	}
+
+	result := client.RunTest("test-package", false, "http://example.com/repo")
+	if result.Package != "test-package" || result.WithRepo {
+		t.Errorf("Unexpected package details: %+v", result)
+	}
+	if result.LogPath != filepath.Join(logDir, "test-package_without_repo.log") {
+		t.Errorf("Unexpected log path: %s", result.LogPath)
+	}
+	if result.StartedAt.IsZero() {
+		t.Error("Expected StartedAt to be set")
+	}
+	if result.Command == nil || result.Command.Dir != tmpDir || strings.Join(result.Command.Args, " ") != "make test/test-package" {
+		t.Errorf("Unexpected command line: %+v", result.Command)
+	} else if !strings.HasPrefix(result.Command.Env[0], "TMPDIR=") {
+		t.Errorf("Expected TMPDIR to be recorded, got %v", result.Command.Env)
+	}
+}
+
 func TestLogFileCreation(t *testing.T) {
 	// Create temporary directory structure
 	tmpDir, err := os.MkdirTemp("", "melange_test_")
@@ -313,4 +351,267 @@ func TestRepoPathHandling(t *testing.T) {
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+func TestTestPackageWithConfigLocator(t *testing.T) {
+	tmpDir := t.TempDir()
+	logDir := filepath.Join(tmpDir, "logs")
+	if err := os.MkdirAll(logDir, 0755); err != nil {
+		t.Fatalf("Failed to create log dir: %v", err)
+	}
+	writeRepoFiles(t, tmpDir, "packages/nested-package/nested-package.yaml")
+
+	client := NewMelangeClient(tmpDir, false, logDir, time.Second)
+
+	// The default flat layout doesn't find nested configs
+	if err := client.TestPackage("nested-package", true, "http://example.com/repo"); !errors.Is(err, ErrPackageYAMLNotFound) {
+		t.Errorf("Expected ErrPackageYAMLNotFound with flat layout, got %v", err)
+	}
+
+	locator, err := NewConfigLocator(tmpDir, "packages/{name}/{name}.yaml")
+	if err != nil {
+		t.Fatalf("Failed to create locator: %v", err)
+	}
+	client.SetConfigLocator(locator)
+
+	if err := client.TestPackage("nested-package", true, "http://example.com/repo"); errors.Is(err, ErrPackageYAMLNotFound) {
+		t.Error("Should find nested config with pattern layout")
+	}
+}
+
+func TestPackageTimeoutOverride(t *testing.T) {
+	client := NewMelangeClient("/tmp", false, "/tmp/logs", 30*time.Minute)
+	client.SetPackageTimeout("llvm", 2*time.Hour)
+
+	if timeout := client.timeoutFor("llvm"); timeout != 2*time.Hour {
+		t.Errorf("Expected llvm timeout of 2h, got %v", timeout)
+	}
+	if timeout := client.timeoutFor("curl"); timeout != 30*time.Minute {
+		t.Errorf("Expected default timeout of 30m, got %v", timeout)
+	}
+}
+
+func TestSetOutput(t *testing.T) {
+	if _, err := exec.LookPath("make"); err != nil {
+		t.Skip("make not available")
+	}
+
+	tmpDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tmpDir, "test-package.yaml"), []byte("test"), 0644); err != nil {
+		t.Fatalf("Failed to create YAML file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "Makefile"), []byte("test/test-package:\n\t@echo testing $@\n"), 0644); err != nil {
+		t.Fatalf("Failed to create Makefile: %v", err)
+	}
+
+	var output bytes.Buffer
+	client := NewMelangeClient(tmpDir, false, tmpDir, time.Minute)
+	client.SetOutput(&output)
+
+	result := client.RunTest("test-package", false, "")
+	if !result.Success {
+		t.Fatalf("Expected test to pass, got %v", result.Error)
+	}
+
+	log, err := os.ReadFile(result.LogPath)
+	if err != nil {
+		t.Fatalf("Failed to read log: %v", err)
+	}
+	if output.String() != "testing test/test-package\n" || string(log) != output.String() {
+		t.Errorf("Expected output in log and stream, got %q and %q", log, output.String())
+	}
+}
+
+func TestArtifactCollection(t *testing.T) {
+	if _, err := exec.LookPath("make"); err != nil {
+		t.Skip("make not available")
+	}
+
+	tmpDir := t.TempDir()
+	logDir := filepath.Join(tmpDir, "logs")
+	os.MkdirAll(logDir, 0755)
+	os.WriteFile(filepath.Join(tmpDir, "test-package.yaml"), []byte("test"), 0644)
+	makefile := "test/test-package:\n\t@echo report > $$APKREGRESS_ARTIFACTS_DIR/report.txt\n\t@echo dump > $$TMPDIR/core\n\t@exit 1\n"
+	os.WriteFile(filepath.Join(tmpDir, "Makefile"), []byte(makefile), 0644)
+
+	client := NewMelangeClient(tmpDir, false, logDir, time.Minute)
+	client.SetArtifactCollection(true)
+
+	result := client.Execute(context.Background(), "test-package", ExecuteOptions{WithRepo: true, WorkDir: t.TempDir()})
+	if result.Success {
+		t.Fatalf("Expected test to fail")
+	}
+	if result.ArtifactsDir != artifactDir(logDir, "test-package", true) {
+		t.Fatalf("Unexpected artifact directory %q", result.ArtifactsDir)
+	}
+	for _, name := range []string{"report.txt", "core", workspaceArchive} {
+		if _, err := os.Stat(filepath.Join(result.ArtifactsDir, name)); err != nil {
+			t.Errorf("Expected %s to be collected: %v", name, err)
+		}
+	}
+}
+
+func TestWorkspacePreservation(t *testing.T) {
+	if _, err := exec.LookPath("make"); err != nil {
+		t.Skip("make not available")
+	}
+
+	tmpDir := t.TempDir()
+	logDir := filepath.Join(tmpDir, "logs")
+	os.MkdirAll(logDir, 0755)
+	os.WriteFile(filepath.Join(tmpDir, "test-package.yaml"), []byte("test"), 0644)
+	makefile := "test/test-package:\n\t@echo $$MELANGE_EXTRA_OPTS\n\t@exit 1\n"
+	os.WriteFile(filepath.Join(tmpDir, "Makefile"), []byte(makefile), 0644)
+
+	client := NewMelangeClient(tmpDir, false, logDir, time.Minute)
+	workspace := t.TempDir()
+	result := client.Execute(context.Background(), "test-package", ExecuteOptions{WithRepo: true, WorkspaceDir: workspace})
+	if result.Success {
+		t.Fatalf("Expected test to fail")
+	}
+
+	expectedLog := filepath.Join(logDir, "test-package_with_repo_workspace.log")
+	if result.LogPath != expectedLog {
+		t.Errorf("Expected log %s, got %s", expectedLog, result.LogPath)
+	}
+	content, err := os.ReadFile(expectedLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(content), "--workspace-dir "+workspace) {
+		t.Errorf("Expected --workspace-dir %s in MELANGE_EXTRA_OPTS, got %q", workspace, content)
+	}
+}
+
+func TestMelangeAuth(t *testing.T) {
+	if _, err := exec.LookPath("make"); err != nil {
+		t.Skip("make not available")
+	}
+
+	tmpDir := t.TempDir()
+	logDir := filepath.Join(tmpDir, "logs")
+	os.MkdirAll(logDir, 0755)
+	os.WriteFile(filepath.Join(tmpDir, "test-package.yaml"), []byte("test"), 0644)
+	os.WriteFile(filepath.Join(tmpDir, "Makefile"), []byte("test/test-package:\n\t@echo auth=$$HTTP_AUTH\n"), 0644)
+
+	client := NewMelangeClient(tmpDir, false, logDir, time.Minute)
+	client.SetAuth(func() (string, error) { return "basic:apk.cgr.dev:user:token", nil })
+	result := client.Execute(context.Background(), "test-package", ExecuteOptions{WithRepo: true})
+	if !result.Success {
+		t.Fatalf("Expected test to pass, got %v", result.Error)
+	}
+	content, _ := os.ReadFile(result.LogPath)
+	if !strings.Contains(string(content), "auth=basic:apk.cgr.dev:user:token") {
+		t.Errorf("Expected HTTP_AUTH to be passed to the test, got %q", content)
+	}
+
+	client.SetAuth(func() (string, error) { return "", errors.New("not logged in") })
+	result = client.Execute(context.Background(), "test-package", ExecuteOptions{WithRepo: true})
+	if result.Success || !strings.Contains(result.Error.Error(), "not logged in") {
+		t.Errorf("Expected the authentication error, got %v", result.Error)
+	}
+}
+
+func TestRepositoryAuth(t *testing.T) {
+	t.Setenv("HTTP_AUTH", "")
+
+	tests := []struct {
+		repoType string
+		apkRepo  string
+		expected bool
+	}{
+		{"wolfi", "https://packages.wolfi.dev/os", false},
+		{"wolfi", "/tmp/packages", false},
+		{"wolfi", "https://apk.cgr.dev/chainguard-private", true},
+		{"enterprise", "/tmp/packages", true},
+		{"extras", "https://example.com/repo", true},
+	}
+	for _, tt := range tests {
+		if got := RepositoryAuth(tt.repoType, tt.apkRepo) != nil; got != tt.expected {
+			t.Errorf("Expected authentication %v for %s with %s, got %v", tt.expected, tt.repoType, tt.apkRepo, got)
+		}
+	}
+
+	t.Setenv("HTTP_AUTH", "basic:apk.cgr.dev:user:mine")
+	if RepositoryAuth("enterprise", "") != nil {
+		t.Errorf("Expected HTTP_AUTH from the environment to take precedence")
+	}
+}
+
+func TestPipelineFilter(t *testing.T) {
+	if _, err := exec.LookPath("make"); err != nil {
+		t.Skip("make not available")
+	}
+
+	tmpDir := t.TempDir()
+	logDir := filepath.Join(tmpDir, "logs")
+	os.MkdirAll(logDir, 0755)
+	os.WriteFile(filepath.Join(tmpDir, "test-package.yaml"), []byte("test"), 0644)
+	os.WriteFile(filepath.Join(tmpDir, "Makefile"), []byte("test/test-package:\n\t@echo $$MELANGE_EXTRA_OPTS\n"), 0644)
+
+	client := NewMelangeClient(tmpDir, false, logDir, time.Minute)
+	if client.pipelineFilter() != "" {
+		t.Errorf("Expected no pipeline filter by default")
+	}
+	client.SetPipelineFilter([]string{"python/import", "test/tw/ldd-check"}, []string{"test/emptypackage"})
+	result := client.Execute(context.Background(), "test-package", ExecuteOptions{WithRepo: true})
+	if !result.Success {
+		t.Fatalf("Expected test to pass, got %v", result.Error)
+	}
+	content, _ := os.ReadFile(result.LogPath)
+	expected := "--test-pipeline-only=python/import --test-pipeline-only=test/tw/ldd-check --test-pipeline-skip=test/emptypackage"
+	if !strings.Contains(string(content), expected) {
+		t.Errorf("Expected %q in MELANGE_EXTRA_OPTS, got %q", expected, content)
+	}
+
+	// Smoke tests skip every pipeline regardless of the filter
+	result = client.Execute(context.Background(), "test-package", ExecuteOptions{WithRepo: true, Smoke: true})
+	if result.LogPath != filepath.Join(logDir, "test-package_with_repo_smoke.log") {
+		t.Errorf("Expected the smoke test to log separately, got %s", result.LogPath)
+	}
+	content, _ = os.ReadFile(result.LogPath)
+	if !strings.Contains(string(content), "--test-pipeline-skip=*") || strings.Contains(string(content), "--test-pipeline-only") {
+		t.Errorf("Expected only --test-pipeline-skip=* in MELANGE_EXTRA_OPTS, got %q", content)
+	}
+}
+
+func TestMelangeTestSupports(t *testing.T) {
+	bin := t.TempDir()
+	help := "Usage:\n  melange test [flags]\n\nFlags:\n      --test-pipeline-only strings   only run these pipelines\n"
+	script := "#!/bin/sh\ncat <<'HELP'\n" + help + "HELP\n"
+	if err := os.WriteFile(filepath.Join(bin, "melange"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	if ok, err := MelangeTestSupports("--test-pipeline-only"); err != nil || !ok {
+		t.Errorf("Expected --test-pipeline-only to be supported, got %v (%v)", ok, err)
+	}
+	if ok, err := MelangeTestSupports("--test-pipeline-skip"); err != nil || ok {
+		t.Errorf("Expected --test-pipeline-skip to be unsupported, got %v (%v)", ok, err)
+	}
+}
+
+func TestArchExcluded(t *testing.T) {
+	tmpDir := t.TempDir()
+	logDir := filepath.Join(tmpDir, "logs")
+	os.MkdirAll(logDir, 0755)
+	other := map[string]string{"x86_64": "aarch64", "aarch64": "x86_64"}[hostArch()]
+	if other == "" {
+		t.Skipf("no other architecture known for %s", hostArch())
+	}
+	os.WriteFile(filepath.Join(tmpDir, "test-package.yaml"), []byte("package:\n  name: test-package\n  target-architecture:\n    - "+other+"\n"), 0644)
+
+	client := NewMelangeClient(tmpDir, false, logDir, time.Minute)
+	client.SetTestCommand("false")
+	result := client.Execute(context.Background(), "test-package", ExecuteOptions{WithRepo: true})
+
+	if !errors.Is(result.Error, ErrArchExcluded) {
+		t.Errorf("Expected ErrArchExcluded, got %v", result.Error)
+	}
+	if !result.Skipped || !result.ArchExcluded || result.Classification != ClassificationArchExcluded {
+		t.Errorf("Expected an arch-excluded skip, got %+v", result)
+	}
+	if result.LogPath != "" {
+		t.Errorf("Expected no test to run, got log %s", result.LogPath)
+	}
+}
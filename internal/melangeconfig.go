@@ -0,0 +1,176 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// MelangeConfig is the subset of a melange build configuration apkregress
+// needs to reason about packages
+type MelangeConfig struct {
+	Package struct {
+		Name    string `yaml:"name"`
+		Version string `yaml:"version"`
+		Epoch   int    `yaml:"epoch"`
+		// TargetArchitecture lists the architectures the package is built
+		// for, e.g. [x86_64], or [all]; empty means all
+		TargetArchitecture []string `yaml:"target-architecture"`
+	} `yaml:"package"`
+	Subpackages []struct {
+		Name     string         `yaml:"name"`
+		Pipeline []pipelineStep `yaml:"pipeline"`
+	} `yaml:"subpackages"`
+	Environment environmentConfig `yaml:"environment"`
+	Pipeline    []pipelineStep    `yaml:"pipeline"`
+	Test        struct {
+		Environment environmentConfig `yaml:"environment"`
+	} `yaml:"test"`
+}
+
+// environmentConfig lists the packages installed in a build or test
+// environment
+type environmentConfig struct {
+	Contents struct {
+		Packages []string `yaml:"packages"`
+	} `yaml:"contents"`
+}
+
+// pipelineStep is a step of a build pipeline, running a built-in pipeline
+// such as go/build or steps of its own
+type pipelineStep struct {
+	Uses     string         `yaml:"uses"`
+	Pipeline []pipelineStep `yaml:"pipeline"`
+}
+
+// LoadMelangeConfig parses the melange config at path
+func LoadMelangeConfig(path string) (*MelangeConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var config MelangeConfig
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse melange config %s: %w", path, err)
+	}
+	return &config, nil
+}
+
+// PackageNames returns the name of the main package and of every subpackage
+// the config produces. Subpackage names using ${{package.name}} are expanded.
+func (c *MelangeConfig) PackageNames() []string {
+	var names []string
+	if c.Package.Name != "" {
+		names = append(names, c.Package.Name)
+	}
+	for _, sub := range c.Subpackages {
+		name := strings.ReplaceAll(sub.Name, "${{package.name}}", c.Package.Name)
+		if name != "" && !strings.Contains(name, "${{") {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// BuildsFor tells whether the package is built for arch, e.g. "x86_64"
+func (c *MelangeConfig) BuildsFor(arch string) bool {
+	if len(c.Package.TargetArchitecture) == 0 {
+		return true
+	}
+	for _, target := range c.Package.TargetArchitecture {
+		if target == "all" || normalizeArch(target) == normalizeArch(arch) {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeArch maps Go's architecture names to the APK ones melange uses
+func normalizeArch(arch string) string {
+	switch arch {
+	case "amd64":
+		return "x86_64"
+	case "arm64":
+		return "aarch64"
+	}
+	return arch
+}
+
+// originLocator resolves packages whose config file isn't named after them,
+// e.g. renamed files or subpackages split out of another config, by parsing
+// every config the base locator knows about. Direct lookups are tried first
+// so the configs are only parsed when needed.
+type originLocator struct {
+	base    ConfigLocator
+	once    sync.Once
+	configs map[string]string
+	err     error
+}
+
+func newOriginLocator(base ConfigLocator) *originLocator {
+	return &originLocator{base: base}
+}
+
+func (l *originLocator) index() (map[string]string, error) {
+	l.once.Do(func() {
+		l.configs = make(map[string]string)
+
+		names, err := l.base.Packages()
+		if err != nil {
+			l.err = err
+			return
+		}
+
+		for _, name := range names {
+			path, err := l.base.Locate(name)
+			if err != nil {
+				continue
+			}
+			config, err := LoadMelangeConfig(path)
+			if err != nil {
+				// Not every YAML file in a package repository is a valid melange config
+				continue
+			}
+			for _, pkg := range config.PackageNames() {
+				if _, ok := l.configs[pkg]; !ok {
+					l.configs[pkg] = path
+				}
+			}
+		}
+	})
+	return l.configs, l.err
+}
+
+func (l *originLocator) Locate(packageName string) (string, error) {
+	path, err := l.base.Locate(packageName)
+	if err == nil {
+		return path, nil
+	}
+
+	configs, indexErr := l.index()
+	if indexErr != nil {
+		return "", indexErr
+	}
+	if path, ok := configs[packageName]; ok {
+		return path, nil
+	}
+	return "", err
+}
+
+func (l *originLocator) Packages() ([]string, error) {
+	return l.base.Packages()
+}
+
+// configTarget returns the make target name for a config, which is named
+// after the config file rather than the package being tested
+func configTarget(configPath string) string {
+	return strings.TrimSuffix(filepath.Base(configPath), filepath.Ext(configPath))
+}
@@ -0,0 +1,130 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLoadMelangeConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "openssl.yaml")
+	content := `package:
+  name: openssl
+  version: 3.3.2
+  epoch: 1
+subpackages:
+  - name: libcrypto3
+  - name: ${{package.name}}-dev
+  - name: ${{vars.unknown}}-doc
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	config, err := LoadMelangeConfig(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if config.Package.Version != "3.3.2" || config.Package.Epoch != 1 {
+		t.Errorf("Unexpected package metadata: %+v", config.Package)
+	}
+
+	expected := []string{"openssl", "libcrypto3", "openssl-dev"}
+	if !reflect.DeepEqual(config.PackageNames(), expected) {
+		t.Errorf("Expected %v, got %v", expected, config.PackageNames())
+	}
+}
+
+func TestLoadMelangeConfigInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "broken.yaml")
+	if err := os.WriteFile(path, []byte("package: [unterminated"), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	if _, err := LoadMelangeConfig(path); err == nil {
+		t.Error("Expected error for invalid YAML")
+	}
+}
+
+func TestOriginLocator(t *testing.T) {
+	repo := t.TempDir()
+	configs := map[string]string{
+		"openssl.yaml": "package:\n  name: openssl\nsubpackages:\n  - name: libcrypto3\n",
+		// A config renamed away from its package name
+		"python-3.12.yaml": "package:\n  name: python3.12\n",
+		"not-melange.yaml": "- just\n- a list\n",
+	}
+	for name, content := range configs {
+		if err := os.WriteFile(filepath.Join(repo, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	locator, err := NewConfigLocator(repo, "flat")
+	if err != nil {
+		t.Fatalf("Failed to create locator: %v", err)
+	}
+
+	tests := []struct {
+		pkg            string
+		expectedConfig string
+	}{
+		{pkg: "openssl", expectedConfig: "openssl.yaml"},
+		{pkg: "libcrypto3", expectedConfig: "openssl.yaml"},
+		{pkg: "python3.12", expectedConfig: "python-3.12.yaml"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pkg, func(t *testing.T) {
+			path, err := locator.Locate(tt.pkg)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if path != filepath.Join(repo, tt.expectedConfig) {
+				t.Errorf("Expected %s, got %s", tt.expectedConfig, path)
+			}
+		})
+	}
+
+	if _, err := locator.Locate("missing"); !errors.Is(err, ErrPackageYAMLNotFound) {
+		t.Errorf("Expected ErrPackageYAMLNotFound, got %v", err)
+	}
+}
+
+func TestConfigTarget(t *testing.T) {
+	if target := configTarget("/repo/packages/python-3.12.yaml"); target != "python-3.12" {
+		t.Errorf("Expected python-3.12, got %s", target)
+	}
+}
+
+func TestBuildsFor(t *testing.T) {
+	tests := []struct {
+		name    string
+		targets []string
+		arch    string
+		want    bool
+	}{
+		{"no restriction", nil, "x86_64", true},
+		{"all", []string{"all"}, "aarch64", true},
+		{"listed", []string{"x86_64"}, "x86_64", true},
+		{"not listed", []string{"x86_64"}, "aarch64", false},
+		{"go arch name", []string{"aarch64"}, "arm64", true},
+		{"go arch name not listed", []string{"aarch64"}, "amd64", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var config MelangeConfig
+			config.Package.TargetArchitecture = tt.targets
+			if got := config.BuildsFor(tt.arch); got != tt.want {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
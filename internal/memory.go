@@ -0,0 +1,240 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// memorySampleInterval is how often the memory of a running test is
+// sampled; spikes shorter than that may be missed
+const memorySampleInterval = time.Second
+
+// memoryTopN is the number of most memory-hungry packages in the summary
+const memoryTopN = 5
+
+// memorySampler tracks the peak resident memory of a process and all of its
+// descendants, e.g. a make invocation and the melange and test processes it
+// starts
+type memorySampler struct {
+	mu   sync.Mutex
+	peak uint64
+	// pids are the processes seen in the tree
+	pids map[int]bool
+	stop chan struct{}
+	done chan struct{}
+}
+
+// startMemorySampler samples the memory of the process tree of pid every
+// interval until stopped
+func startMemorySampler(pid int, interval time.Duration) *memorySampler {
+	s := &memorySampler{pids: make(map[int]bool), stop: make(chan struct{}), done: make(chan struct{})}
+	go func() {
+		defer close(s.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			s.sample(pid)
+			select {
+			case <-s.stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return s
+}
+
+func (s *memorySampler) sample(pid int) {
+	var total uint64
+	tree := processTree("/proc", pid)
+	s.mu.Lock()
+	for pid, rss := range tree {
+		total += rss
+		s.pids[pid] = true
+	}
+	s.peak = max(s.peak, total)
+	s.mu.Unlock()
+}
+
+// Stop stops sampling and returns the peak memory in bytes, 0 where
+// process memory can't be read
+func (s *memorySampler) Stop() uint64 {
+	close(s.stop)
+	<-s.done
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.peak
+}
+
+// processes returns the processes seen in the tree while sampling
+func (s *memorySampler) processes() map[int]bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.pids
+}
+
+// processTreeRSS returns the resident memory in bytes of root and its
+// descendants, read from the proc filesystem at procDir
+func processTreeRSS(procDir string, root int) uint64 {
+	var total uint64
+	for _, rss := range processTree(procDir, root) {
+		total += rss
+	}
+	return total
+}
+
+// processTree returns the resident memory in bytes of root and each of its
+// descendants by pid, read from the proc filesystem at procDir
+func processTree(procDir string, root int) map[int]uint64 {
+	entries, err := os.ReadDir(procDir)
+	if err != nil {
+		return nil
+	}
+
+	children := make(map[int][]int)
+	rss := make(map[int]uint64)
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		ppid, pages, ok := readProcStat(filepath.Join(procDir, entry.Name(), "stat"))
+		if !ok {
+			// The process exited while the tree was read
+			continue
+		}
+		children[ppid] = append(children[ppid], pid)
+		rss[pid] = pages * uint64(os.Getpagesize())
+	}
+
+	tree := make(map[int]uint64)
+	pending := []int{root}
+	for len(pending) > 0 {
+		pid := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		if _, seen := tree[pid]; seen {
+			continue
+		}
+		tree[pid] = rss[pid]
+		pending = append(pending, children[pid]...)
+	}
+	return tree
+}
+
+// readProcStat returns the parent and the resident pages of the process
+// whose /proc/<pid>/stat is at path
+func readProcStat(path string) (int, uint64, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, 0, false
+	}
+	// The command name in parentheses may contain spaces and parentheses;
+	// the fields after it start with the state
+	stat := string(data)
+	end := strings.LastIndexByte(stat, ')')
+	if end < 0 {
+		return 0, 0, false
+	}
+	fields := strings.Fields(stat[end+1:])
+	// Fields 4 (ppid) and 24 (rss) of proc(5), counted from the state
+	if len(fields) < 22 {
+		return 0, 0, false
+	}
+	ppid, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return 0, 0, false
+	}
+	pages, err := strconv.ParseUint(fields[21], 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	return ppid, pages, true
+}
+
+// formatBytes formats a memory size in binary units, e.g. "1.5 GiB"
+func formatBytes(n uint64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := uint64(unit), 0
+	for m := n / unit; m >= unit && exp < 3; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGT"[exp])
+}
+
+// packageMemory is the peak memory of the tests of a package
+type packageMemory struct {
+	Package string `json:"package"`
+	Peak    uint64 `json:"peakMemory"`
+}
+
+// topMemory returns the n packages whose tests used the most memory, with
+// the peak of their tests with and without the candidate repository
+func topMemory(packageResults map[string]map[bool]TestResult, n int) []packageMemory {
+	var usage []packageMemory
+	for pkg, results := range packageResults {
+		var peak uint64
+		for _, result := range results {
+			peak = max(peak, result.PeakMemory)
+		}
+		if peak > 0 {
+			usage = append(usage, packageMemory{Package: pkg, Peak: peak})
+		}
+	}
+	return sortMemory(usage, n)
+}
+
+// sortMemory orders usage by descending peak memory and keeps the first n
+func sortMemory(usage []packageMemory, n int) []packageMemory {
+	sort.Slice(usage, func(i, j int) bool {
+		if usage[i].Peak != usage[j].Peak {
+			return usage[i].Peak > usage[j].Peak
+		}
+		return usage[i].Package < usage[j].Package
+	})
+	if len(usage) > n {
+		usage = usage[:n]
+	}
+	return usage
+}
+
+// printMemory lists the most memory-hungry packages, with what running the
+// hungriest concurrency times at once would take
+func printMemory(w io.Writer, usage []packageMemory, concurrency int) {
+	if len(usage) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\nMost memory-hungry tests:\n")
+	for _, u := range usage {
+		fmt.Fprintf(w, "  - %s: %s\n", u.Package, formatBytes(u.Peak))
+	}
+	if concurrency > 1 {
+		fmt.Fprintf(w, "At concurrency %d, tests like these may need up to %s at once\n", concurrency, formatBytes(usage[0].Peak*uint64(concurrency)))
+	}
+}
+
+// printMemoryMarkdown is printMemory for the markdown summary
+func printMemoryMarkdown(w io.Writer, usage []packageMemory) {
+	if len(usage) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\n### 🧠 Most Memory-Hungry Tests\n\n")
+	fmt.Fprintf(w, "| Package | Peak memory |\n")
+	fmt.Fprintf(w, "|---------|-------------|\n")
+	for _, u := range usage {
+		fmt.Fprintf(w, "| `%s` | %s |\n", u.Package, formatBytes(u.Peak))
+	}
+}
@@ -0,0 +1,119 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// procStat returns a /proc/<pid>/stat line with the given parent and
+// resident pages
+func procStat(pid, ppid int, comm string, pages uint64) string {
+	fields := make([]string, 49)
+	for i := range fields {
+		fields[i] = "0"
+	}
+	fields[0] = "S"
+	fields[1] = fmt.Sprint(ppid)
+	fields[21] = fmt.Sprint(pages)
+	return fmt.Sprintf("%d (%s) %s\n", pid, comm, strings.Join(fields, " "))
+}
+
+func TestProcessTreeRSS(t *testing.T) {
+	procDir := t.TempDir()
+	write := func(pid, ppid int, comm string, pages uint64) {
+		t.Helper()
+		dir := filepath.Join(procDir, fmt.Sprint(pid))
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "stat"), []byte(procStat(pid, ppid, comm, pages)), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(1, 0, "init", 1000)
+	write(100, 1, "make", 10)
+	write(101, 100, "melange", 200)
+	write(102, 101, "python3 (test) )", 300)
+	write(200, 1, "unrelated", 5000)
+	if err := os.MkdirAll(filepath.Join(procDir, "self"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	page := uint64(os.Getpagesize())
+	if got := processTreeRSS(procDir, 100); got != 510*page {
+		t.Errorf("Expected %d bytes for the tree of make, got %d", 510*page, got)
+	}
+	if got := processTreeRSS(procDir, 102); got != 300*page {
+		t.Errorf("Expected %d bytes for a leaf, got %d", 300*page, got)
+	}
+	if got := processTreeRSS(procDir, 999); got != 0 {
+		t.Errorf("Expected 0 bytes for an exited process, got %d", got)
+	}
+}
+
+func TestMemorySampler(t *testing.T) {
+	if _, err := os.Stat("/proc/self/stat"); err != nil {
+		t.Skip("no proc filesystem")
+	}
+	cmd := exec.Command("sleep", "1")
+	if err := startInProcessGroup(cmd); err != nil {
+		t.Skipf("sleep unavailable: %v", err)
+	}
+
+	var usage testUsage
+	if err := waitMeasured(context.Background(), cmd, time.Minute, &usage); err != nil {
+		t.Fatal(err)
+	}
+	if usage.PeakMemory == 0 {
+		t.Error("Expected the memory of sleep to be measured")
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	tests := []struct {
+		bytes    uint64
+		expected string
+	}{
+		{512, "512 B"},
+		{1536, "1.5 KiB"},
+		{300 << 20, "300.0 MiB"},
+		{3 << 29, "1.5 GiB"},
+	}
+
+	for _, tt := range tests {
+		if got := formatBytes(tt.bytes); got != tt.expected {
+			t.Errorf("Expected %s, got %s", tt.expected, got)
+		}
+	}
+}
+
+func TestTopMemory(t *testing.T) {
+	packageResults := map[string]map[bool]TestResult{
+		"curl":  {true: {PeakMemory: 100}, false: {PeakMemory: 300}},
+		"git":   {true: {PeakMemory: 200}},
+		"nginx": {true: {PeakMemory: 200}},
+		"jq":    {true: {Skipped: true}},
+	}
+
+	expected := []packageMemory{{"curl", 300}, {"git", 200}}
+	if got := topMemory(packageResults, 2); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+
+	var out bytes.Buffer
+	printMemory(&out, expected, 4)
+	if !strings.Contains(out.String(), "  - curl: 300 B\n") || !strings.Contains(out.String(), "up to 1.2 KiB at once") {
+		t.Errorf("Unexpected memory report: %q", out.String())
+	}
+}
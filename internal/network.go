@@ -0,0 +1,109 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Mirror replaces the URL prefix From with To, e.g. to fetch
+// https://packages.wolfi.dev/os from an internal Artifactory
+type Mirror struct {
+	From string
+	To   string
+}
+
+// NetworkConfig configures how apkregress reaches package repositories from
+// restricted or air-gapped networks
+type NetworkConfig struct {
+	HTTPProxy  string
+	HTTPSProxy string
+	NoProxy    string
+	Mirrors    []Mirror
+	// Credentials authenticate requests by host (see LoadCredentials)
+	Credentials map[string]Credential
+}
+
+// mirrors are the configured mirrors, longest prefix first
+var mirrors []Mirror
+
+// ConfigureNetwork applies cfg to all network access of this process and
+// the tools it runs. Proxies are exported as HTTP_PROXY, HTTPS_PROXY and
+// NO_PROXY, which Go's HTTP clients, apkrane, chainctl, melange and apko all
+// honour, so it must be called before the first request. Mirrors rewrite the
+// package index URLs, the candidate repository passed to melange and apko,
+// and the hosts credentials are sent to. Credentials apply to index
+// discovery and tests alike.
+func ConfigureNetwork(cfg NetworkConfig) error {
+	proxies := []struct {
+		name  string
+		value string
+	}{
+		{"HTTP_PROXY", cfg.HTTPProxy},
+		{"HTTPS_PROXY", cfg.HTTPSProxy},
+		{"NO_PROXY", cfg.NoProxy},
+	}
+	for _, proxy := range proxies {
+		if proxy.value == "" {
+			continue
+		}
+		if proxy.name != "NO_PROXY" {
+			if _, err := url.Parse(proxy.value); err != nil {
+				return fmt.Errorf("invalid %s %q: %w", strings.ToLower(proxy.name), proxy.value, err)
+			}
+		}
+		// Tools differ in which spelling they read
+		for _, name := range []string{proxy.name, strings.ToLower(proxy.name)} {
+			if err := os.Setenv(name, proxy.value); err != nil {
+				return fmt.Errorf("failed to set %s: %w", name, err)
+			}
+		}
+	}
+
+	mirrors = append([]Mirror(nil), cfg.Mirrors...)
+	sort.SliceStable(mirrors, func(i, j int) bool {
+		return len(mirrors[i].From) > len(mirrors[j].From)
+	})
+	credentials = cfg.Credentials
+	return nil
+}
+
+// ParseMirror parses a mirror given as FROM=TO
+func ParseMirror(s string) (Mirror, error) {
+	from, to, ok := strings.Cut(s, "=")
+	if !ok || from == "" || to == "" {
+		return Mirror{}, fmt.Errorf("invalid mirror %q, expected FROM=TO", s)
+	}
+	return Mirror{
+		From: strings.TrimSuffix(from, "/"),
+		To:   strings.TrimSuffix(to, "/"),
+	}, nil
+}
+
+// mirrorURL returns location with the longest matching mirror prefix
+// replaced. Prefixes only match at path boundaries.
+func mirrorURL(location string) string {
+	for _, m := range mirrors {
+		if location == m.From {
+			return m.To
+		}
+		if strings.HasPrefix(location, m.From+"/") {
+			return m.To + strings.TrimPrefix(location, m.From)
+		}
+	}
+	return location
+}
+
+// urlHost returns the host of location, or "" if it isn't a URL
+func urlHost(location string) string {
+	u, err := url.Parse(location)
+	if err != nil {
+		return ""
+	}
+	return u.Host
+}
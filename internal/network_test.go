@@ -0,0 +1,93 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"os"
+	"testing"
+)
+
+func TestParseMirror(t *testing.T) {
+	tests := []struct {
+		input       string
+		expected    Mirror
+		expectError bool
+	}{
+		{
+			input:    "https://packages.wolfi.dev/os=https://mirror.example/wolfi/",
+			expected: Mirror{From: "https://packages.wolfi.dev/os", To: "https://mirror.example/wolfi"},
+		},
+		{input: "https://packages.wolfi.dev/os", expectError: true},
+		{input: "=https://mirror.example", expectError: true},
+		{input: "https://packages.wolfi.dev/os=", expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			mirror, err := ParseMirror(tt.input)
+			if tt.expectError {
+				if err == nil {
+					t.Errorf("Expected error, got %v", mirror)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if mirror != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, mirror)
+			}
+		})
+	}
+}
+
+func TestMirrorURL(t *testing.T) {
+	defer ConfigureNetwork(NetworkConfig{})
+	if err := ConfigureNetwork(NetworkConfig{Mirrors: []Mirror{
+		{From: "https://apk.cgr.dev", To: "https://mirror.example/cgr"},
+		{From: "https://apk.cgr.dev/extra-packages", To: "https://extras.example"},
+		{From: "https://packages.wolfi.dev/os", To: "https://mirror.example/wolfi"},
+	}}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"https://packages.wolfi.dev/os/x86_64/APKINDEX.tar.gz", "https://mirror.example/wolfi/x86_64/APKINDEX.tar.gz"},
+		{"https://packages.wolfi.dev/os", "https://mirror.example/wolfi"},
+		{"https://packages.wolfi.dev/os-extra/x86_64", "https://packages.wolfi.dev/os-extra/x86_64"},
+		{"https://apk.cgr.dev/chainguard-private/x86_64", "https://mirror.example/cgr/chainguard-private/x86_64"},
+		{"https://apk.cgr.dev/extra-packages/x86_64", "https://extras.example/x86_64"},
+		{"/local/repo", "/local/repo"},
+	}
+
+	for _, tt := range tests {
+		if got := mirrorURL(tt.input); got != tt.expected {
+			t.Errorf("mirrorURL(%s): expected %s, got %s", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestConfigureNetworkProxies(t *testing.T) {
+	for _, name := range []string{"HTTPS_PROXY", "https_proxy", "NO_PROXY", "no_proxy"} {
+		t.Setenv(name, "")
+	}
+
+	if err := ConfigureNetwork(NetworkConfig{HTTPSProxy: "http://proxy.example:3128", NoProxy: ".corp.example"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	for name, expected := range map[string]string{
+		"HTTPS_PROXY": "http://proxy.example:3128",
+		"https_proxy": "http://proxy.example:3128",
+		"NO_PROXY":    ".corp.example",
+		"no_proxy":    ".corp.example",
+	} {
+		if got := os.Getenv(name); got != expected {
+			t.Errorf("Expected %s=%s, got %s", name, expected, got)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// SetNotes attaches free-form operator notes to the run, e.g. "validating
+// openssl 3.3 bump". They are recorded in the run manifest, the summaries
+// and the results database.
+func (r *RegressionTestRunner) SetNotes(notes []string) {
+	r.notes = notes
+}
+
+// printNotes lists the notes of the run in the text summary
+func printNotes(w io.Writer, notes []string) {
+	for _, note := range notes {
+		fmt.Fprintf(w, "Note: %s\n", indentNote(note, "      "))
+	}
+}
+
+// printNotesMarkdown quotes the notes of the run in the markdown summary
+func printNotesMarkdown(w io.Writer, notes []string) {
+	for _, note := range notes {
+		fmt.Fprintf(w, "> 📝 %s\n\n", indentNote(note, "> "))
+	}
+}
+
+// indentNote indents the lines of a multi-line note after the first
+func indentNote(note, indent string) string {
+	return strings.ReplaceAll(note, "\n", "\n"+indent)
+}
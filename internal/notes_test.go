@@ -0,0 +1,75 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPrintNotes(t *testing.T) {
+	notes := []string{"validating openssl 3.3 bump", "rerun after\nthe mirror outage"}
+
+	var text bytes.Buffer
+	printNotes(&text, notes)
+	expected := "Note: validating openssl 3.3 bump\nNote: rerun after\n      the mirror outage\n"
+	if text.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, text.String())
+	}
+
+	var markdown bytes.Buffer
+	printNotesMarkdown(&markdown, notes)
+	expected = "> 📝 validating openssl 3.3 bump\n\n> 📝 rerun after\n> the mirror outage\n\n"
+	if markdown.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, markdown.String())
+	}
+}
+
+func TestRunNotes(t *testing.T) {
+	logDir := t.TempDir()
+	dbPath := filepath.Join(t.TempDir(), "results.jsonl")
+	notes := []string{"validating openssl 3.3 bump"}
+
+	runner := NewRegressionTestRunnerFromPackageList([]string{"curl"}, "https://example.com/repo", "/tmp", "wolfi", 1, true, time.Minute, false)
+	runner.setLogDir(logDir)
+	runner.SetNotes(notes)
+	db, err := NewResultsDB(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	runner.SetResultsDB(db)
+	runner.SetExecutor(TestExecutorFunc(func(ctx context.Context, pkg string, opts ExecuteOptions) TestResult {
+		return newTestResult(pkg, opts.WithRepo, time.Now(), "", nil)
+	}))
+
+	if err := runner.RunFromPackageList([]string{"curl"}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	manifest, err := LoadRunManifest(logDir)
+	if err != nil {
+		t.Fatalf("Failed to load manifest: %v", err)
+	}
+	if !reflect.DeepEqual(manifest.Notes, notes) {
+		t.Errorf("Expected the notes in the manifest, got %v", manifest.Notes)
+	}
+	if report := runner.summaryReport(); !reflect.DeepEqual(report.Notes, notes) {
+		t.Errorf("Expected the notes in the summary, got %v", report.Notes)
+	}
+	runs, err := db.Runs()
+	if err != nil || len(runs) != 1 || !reflect.DeepEqual(runs[0].Notes, notes) {
+		t.Errorf("Expected the notes in the results database, got %v (%v)", runs, err)
+	}
+
+	var markdown bytes.Buffer
+	runner.printMarkdownSummary(&markdown, 1, 0, 1, 0, 0, 1, 0, nil, nil)
+	if !strings.Contains(markdown.String(), "> 📝 validating openssl 3.3 bump") {
+		t.Errorf("Expected the notes in the markdown summary, got:\n%s", markdown.String())
+	}
+}
@@ -0,0 +1,183 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// oomSignature is the infrastructure failure of tests killed for lack of
+// memory
+const oomSignature = "OOM-killed"
+
+// kernelOOMPatterns match the kernel log messages naming a process killed
+// for lack of memory, capturing its pid
+var kernelOOMPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)out of memory: killed process (\d+)`),
+	regexp.MustCompile(`(?i)oom-kill:.*\bpid=(\d+)`),
+}
+
+// oomWatch notices processes the kernel kills for lack of memory while a
+// test runs, from the kernel log where it can be read and from the OOM kill
+// count of apkregress' memory cgroup
+type oomWatch struct {
+	kernel *kernelLog
+	// cgroupEvents is the memory.events file of the cgroup, and cgroupKills
+	// its OOM kill count when the test started
+	cgroupEvents string
+	cgroupKills  uint64
+}
+
+// startOOMWatch starts watching for OOM kills
+func startOOMWatch() *oomWatch {
+	w := &oomWatch{kernel: openKernelLog(), cgroupEvents: cgroupMemoryEvents("/proc/self/cgroup", "/sys/fs/cgroup")}
+	if kills, ok := readOOMKills(w.cgroupEvents); ok {
+		w.cgroupKills = kills
+	} else {
+		w.cgroupEvents = ""
+	}
+	return w
+}
+
+// killed stops watching and reports whether the test whose processes were
+// pids and which ended with err was killed for lack of memory: the kernel
+// log names one of its processes, or the cgroup counted an OOM kill and the
+// test died of SIGKILL. The cgroup is shared by concurrent tests, so its
+// count alone doesn't tell which test was hit.
+func (w *oomWatch) killed(pids map[int]bool, err error) bool {
+	for _, pid := range w.kernel.oomKills() {
+		if pids[pid] {
+			return true
+		}
+	}
+	if w.cgroupEvents == "" || !killedBySIGKILL(err) {
+		return false
+	}
+	kills, ok := readOOMKills(w.cgroupEvents)
+	return ok && kills > w.cgroupKills
+}
+
+// killedBySIGKILL tells whether a test command died of SIGKILL, itself or,
+// with an exit status of 137, its child
+func killedBySIGKILL(err error) bool {
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return false
+	}
+	return exitErr.ExitCode() == 137 || strings.Contains(exitErr.Error(), "signal: killed")
+}
+
+// parseKernelOOMKills returns the pids of the processes the kernel log
+// messages name as killed for lack of memory
+func parseKernelOOMKills(messages []string) []int {
+	var pids []int
+	for _, message := range messages {
+		for _, pattern := range kernelOOMPatterns {
+			match := pattern.FindStringSubmatch(message)
+			if match == nil {
+				continue
+			}
+			if pid, err := strconv.Atoi(match[1]); err == nil {
+				pids = append(pids, pid)
+			}
+			break
+		}
+	}
+	return pids
+}
+
+// cgroupMemoryEvents returns the memory.events file of the cgroup v2 the
+// process described by procCgroup (/proc/self/cgroup) is in, with the
+// cgroup filesystem mounted at root, or "" on cgroup v1
+func cgroupMemoryEvents(procCgroup, root string) string {
+	data, err := os.ReadFile(procCgroup)
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if path, ok := strings.CutPrefix(line, "0::"); ok {
+			return filepath.Join(root, path, "memory.events")
+		}
+	}
+	return ""
+}
+
+// readOOMKills returns the oom_kill count of a memory.events file
+func readOOMKills(path string) (uint64, bool) {
+	if path == "" {
+		return 0, false
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, false
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if value, ok := strings.CutPrefix(scanner.Text(), "oom_kill "); ok {
+			kills, err := strconv.ParseUint(value, 10, 64)
+			return kills, err == nil
+		}
+	}
+	return 0, false
+}
+
+// oomKill is a package whose test was killed for lack of memory, with the
+// peak memory measured
+type oomKill struct {
+	Package string `json:"package"`
+	Peak    uint64 `json:"peakMemory,omitempty"`
+}
+
+func (k oomKill) String() string {
+	if k.Peak == 0 {
+		return k.Package
+	}
+	return fmt.Sprintf("%s (peak %s)", k.Package, formatBytes(k.Peak))
+}
+
+// printOOMKills writes the packages killed for lack of memory to the text
+// summary, with what to do about it
+func printOOMKills(w io.Writer, kills []oomKill, concurrency int) {
+	if len(kills) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\n💥 Killed for lack of memory (not counted as failures or regressions):\n")
+	for _, kill := range kills {
+		fmt.Fprintf(w, "  - %s\n", kill)
+	}
+	fmt.Fprintf(w, "%s\n", oomGuidance(concurrency))
+}
+
+// printOOMKillsMarkdown is printOOMKills for the markdown summary
+func printOOMKillsMarkdown(w io.Writer, kills []oomKill, concurrency int) {
+	if len(kills) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\n### 💥 Killed for Lack of Memory\n\n")
+	fmt.Fprintf(w, "The kernel killed these tests because the machine ran out of memory, so they are counted neither as failures nor as regressions:\n\n")
+	for _, kill := range kills {
+		fmt.Fprintf(w, "- `%s`\n", kill)
+	}
+	fmt.Fprintf(w, "\n%s\n", oomGuidance(concurrency))
+}
+
+// oomGuidance tells how to avoid OOM kills, which at a high concurrency
+// show up as failures unrelated to the candidate repository
+func oomGuidance(concurrency int) string {
+	if concurrency > 1 {
+		return fmt.Sprintf("Tests running at once share the machine's memory: lower --concurrency (%d in this run) or test on a machine with more memory, so that these packages get a verdict.", concurrency)
+	}
+	return "Even on their own these tests need more memory than the machine has: test them on a machine with more memory."
+}
@@ -0,0 +1,62 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+//go:build linux
+
+package internal
+
+import (
+	"io"
+	"strings"
+	"syscall"
+)
+
+// kernelLog reads the kernel messages logged after it was opened from
+// /dev/kmsg, which usually requires root or CAP_SYSLOG
+type kernelLog struct {
+	fd int
+}
+
+// openKernelLog opens the kernel log at its end, or returns nil if it can't
+// be read
+func openKernelLog() *kernelLog {
+	// Non-blocking, so that reading stops at the end of the log
+	fd, err := syscall.Open("/dev/kmsg", syscall.O_RDONLY|syscall.O_NONBLOCK|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return nil
+	}
+	if _, err := syscall.Seek(fd, 0, io.SeekEnd); err != nil {
+		syscall.Close(fd)
+		return nil
+	}
+	return &kernelLog{fd: fd}
+}
+
+// oomKills closes the log and returns the pids of the processes it names as
+// killed for lack of memory since it was opened
+func (k *kernelLog) oomKills() []int {
+	if k == nil {
+		return nil
+	}
+	defer syscall.Close(k.fd)
+
+	// Each read returns one record, "<prio>,<seq>,<time>,<flags>;<message>"
+	// followed by continuation lines
+	var messages []string
+	buf := make([]byte, 8192)
+	for {
+		n, err := syscall.Read(k.fd, buf)
+		if err == syscall.EPIPE {
+			// Records were overwritten before they were read
+			continue
+		}
+		if err != nil || n <= 0 {
+			break
+		}
+		if _, message, ok := strings.Cut(string(buf[:n]), ";"); ok {
+			message, _, _ = strings.Cut(message, "\n")
+			messages = append(messages, message)
+		}
+	}
+	return parseKernelOOMKills(messages)
+}
@@ -0,0 +1,19 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+//go:build !linux
+
+package internal
+
+// kernelLog is only read on Linux
+type kernelLog struct{}
+
+// openKernelLog returns nil where there is no /dev/kmsg
+func openKernelLog() *kernelLog {
+	return nil
+}
+
+// oomKills returns nothing where the kernel log can't be read
+func (k *kernelLog) oomKills() []int {
+	return nil
+}
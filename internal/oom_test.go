@@ -0,0 +1,163 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseKernelOOMKills(t *testing.T) {
+	messages := []string{
+		"eth0: link up",
+		"Out of memory: Killed process 4321 (python3) total-vm:8123456kB, anon-rss:7654321kB",
+		"oom-kill:constraint=CONSTRAINT_MEMCG,nodemask=(null),cpuset=/,mems_allowed=0,oom_memcg=/user.slice,task=cc1plus,pid=987,uid=1000",
+		"Memory cgroup out of memory: Killed process 55 (ld) total-vm:1kB",
+	}
+	if got := parseKernelOOMKills(messages); !reflect.DeepEqual(got, []int{4321, 987, 55}) {
+		t.Errorf("Expected the killed pids, got %v", got)
+	}
+}
+
+func TestCgroupMemoryEvents(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name     string
+		cgroup   string
+		expected string
+	}{
+		{"v2", "0::/user.slice/user-1000.slice/session-2.scope\n", filepath.Join(dir, "user.slice/user-1000.slice/session-2.scope/memory.events")},
+		{"v1", "12:memory:/user.slice\n11:cpu:/user.slice\n", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, tt.name)
+			os.WriteFile(path, []byte(tt.cgroup), 0644)
+			if got := cgroupMemoryEvents(path, dir); got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestOOMWatchCgroup(t *testing.T) {
+	events := filepath.Join(t.TempDir(), "memory.events")
+	os.WriteFile(events, []byte("low 0\nhigh 0\nmax 3\noom 1\noom_kill 1\n"), 0644)
+	if kills, ok := readOOMKills(events); !ok || kills != 1 {
+		t.Fatalf("Expected 1 OOM kill, got %d (%v)", kills, ok)
+	}
+
+	killed := exec.Command("sh", "-c", "kill -9 $$").Run()
+	failed := exec.Command("sh", "-c", "exit 2").Run()
+	if killed == nil || failed == nil {
+		t.Skip("sh unavailable")
+	}
+
+	w := &oomWatch{cgroupEvents: events, cgroupKills: 1}
+	if w.killed(nil, killed) {
+		t.Errorf("Expected no OOM kill while the count is unchanged")
+	}
+	os.WriteFile(events, []byte("oom 2\noom_kill 2\n"), 0644)
+	if !w.killed(nil, killed) {
+		t.Errorf("Expected an OOM kill of a test killed by SIGKILL")
+	}
+	// Another test may have been killed
+	if w.killed(nil, failed) {
+		t.Errorf("Expected no OOM kill of a test that failed on its own")
+	}
+}
+
+func TestMarkInfraOOM(t *testing.T) {
+	hung := newTestResult("curl", true, time.Now(), "", ErrTestHung)
+	hung.OOMKilled = true
+	result := markInfra(hung)
+	if result.Infra != oomSignature || result.Classification != ClassificationInfra {
+		t.Errorf("Expected a hung test killed for lack of memory to fail on the infrastructure, got %q classified %s", result.Infra, result.Classification)
+	}
+
+	// Tests kill processes themselves, so exit code 137 alone is a failure
+	result = markInfra(newTestResult("curl", true, time.Now(), "", &fakeExitError{code: 137}))
+	if result.OOMKilled || result.Infra != "" {
+		t.Errorf("Expected exit code 137 alone not to mark the test as killed for lack of memory, got %q", result.Infra)
+	}
+	killed := newTestResult("curl", true, time.Now(), "", &fakeExitError{code: 137})
+	killed.OOMKilled = true
+	if result := markInfra(killed); result.Infra != oomSignature {
+		t.Errorf("Expected exit code 137 with an OOM kill to fail on the infrastructure, got %q", result.Infra)
+	}
+}
+
+func TestRunnerOOMKilled(t *testing.T) {
+	repoPath := t.TempDir()
+	for _, pkg := range []string{"a", "b"} {
+		if err := os.WriteFile(filepath.Join(repoPath, pkg+".yaml"), []byte("package:\n  name: "+pkg+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	runner := NewRegressionTestRunner("", "https://example.com/repo", repoPath, "wolfi", 2, false, time.Minute, false)
+	runner.setLogDir(t.TempDir())
+	runner.SetInfraBackoff(false)
+	runner.SetExecutor(TestExecutorFunc(func(ctx context.Context, pkg string, opts ExecuteOptions) TestResult {
+		if pkg == "b" {
+			return newTestResult(pkg, opts.WithRepo, time.Now(), "", nil)
+		}
+		result := newTestResult(pkg, opts.WithRepo, time.Now(), "", ErrTestHung)
+		result.OOMKilled = true
+		result.PeakMemory = 3 << 30
+		return result
+	}))
+
+	if err := runner.RunFromPackageList([]string{"a", "b"}); err != nil {
+		t.Fatalf("Expected a test killed for lack of memory not to fail the run, got %v", err)
+	}
+	if !reflect.DeepEqual(runner.summary.OOMKilled, []oomKill{{Package: "a", Peak: 3 << 30}}) {
+		t.Errorf("Expected a to be killed for lack of memory, got %v", runner.summary.OOMKilled)
+	}
+	if len(runner.summary.Hung) != 0 || len(runner.summary.Infra) != 0 {
+		t.Errorf("Expected a to be neither hung nor another infrastructure failure, got %v and %v", runner.summary.Hung, runner.summary.Infra)
+	}
+
+	var buf bytes.Buffer
+	printOOMKills(&buf, runner.summary.OOMKilled, runner.concurrency)
+	if !strings.Contains(buf.String(), "a (peak 3.0 GiB)") || !strings.Contains(buf.String(), "lower --concurrency (2 in this run)") {
+		t.Errorf("Expected the package and guidance, got %q", buf.String())
+	}
+}
+
+func TestRunnerKilledWithRepoOnlyRegresses(t *testing.T) {
+	repoPath := t.TempDir()
+	if err := os.WriteFile(filepath.Join(repoPath, "a.yaml"), []byte("package:\n  name: a\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// a is killed with the candidate repository, without a sign of the
+	// kernel running out of memory, and passes without it
+	runner := NewRegressionTestRunner("", "https://example.com/repo", repoPath, "wolfi", 1, false, time.Minute, false)
+	runner.setLogDir(t.TempDir())
+	runner.SetExecutor(TestExecutorFunc(func(ctx context.Context, pkg string, opts ExecuteOptions) TestResult {
+		if opts.WithRepo {
+			return newTestResult(pkg, true, time.Now(), "", &fakeExitError{code: 137})
+		}
+		return newTestResult(pkg, false, time.Now(), "", nil)
+	}))
+
+	if err := runner.RunFromPackageList([]string{"a"}); err == nil {
+		t.Fatalf("Expected the regression to fail the run")
+	}
+	if !reflect.DeepEqual(runner.summary.Regressions, []string{"a"}) {
+		t.Errorf("Expected a to regress, got %v", runner.summary.Regressions)
+	}
+	if len(runner.summary.Infra) != 0 || len(runner.summary.OOMKilled) != 0 {
+		t.Errorf("Expected no infrastructure failure, got %v and %v", runner.summary.Infra, runner.summary.OOMKilled)
+	}
+}
@@ -0,0 +1,261 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"container/heap"
+	"sync"
+)
+
+// packageQueue hands the packages of a run to the workers, highest priority
+// first and in the order they were added within a priority. Packages can be
+// added and cancelled while the run is in progress. The queue is drained
+// once it is empty and no test is running, since the outcome of a running
+// test is what usually prompts adding more packages.
+type packageQueue struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	items   packageHeap
+	seq     int
+	seen    map[string]bool
+	running int
+	drained bool
+	// paused stops handing out packages until resumed
+	paused bool
+	// total is the number of packages added and not cancelled
+	total int
+	// chunkSize limits how many packages are handed out until nextChunk is
+	// called; chunkLeft of them are left in the current chunk, and
+	// chunkDone receives once all tests of a chunk finished
+	chunkSize int
+	chunkLeft int
+	chunkDone chan struct{}
+	// limit caps the packages handed out at once below the number of
+	// workers, e.g. after infrastructure failures; 0 doesn't
+	limit int
+}
+
+type queuedPackage struct {
+	name     string
+	priority int
+	seq      int
+}
+
+func newPackageQueue(packages []string) *packageQueue {
+	q := &packageQueue{seen: make(map[string]bool)}
+	q.cond = sync.NewCond(&q.mu)
+	for _, pkg := range packages {
+		q.push(pkg, 0)
+	}
+	return q
+}
+
+// setChunkSize hands out packages in chunks of size: once a chunk's tests
+// all finished, chunks receives and no package is handed out until
+// nextChunk is called
+func (q *packageQueue) setChunkSize(size int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.chunkSize = size
+	q.chunkLeft = size
+	q.chunkDone = make(chan struct{}, 1)
+}
+
+// chunks receives whenever a chunk finished with packages left, nil without
+// chunks
+func (q *packageQueue) chunks() <-chan struct{} {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.chunkDone
+}
+
+// nextChunk starts handing out the next chunk
+func (q *packageQueue) nextChunk() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.chunkLeft = q.chunkSize
+	q.cond.Broadcast()
+}
+
+// chunkFull reports whether the current chunk was handed out completely
+func (q *packageQueue) chunkFull() bool {
+	return q.chunkSize > 0 && q.chunkLeft == 0
+}
+
+// push queues pkg unless it is already part of the run. It returns false if
+// the package was ignored or the queue is drained.
+func (q *packageQueue) push(pkg string, priority int) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.drained || q.seen[pkg] {
+		return false
+	}
+	q.seen[pkg] = true
+	q.seq++
+	heap.Push(&q.items, queuedPackage{name: pkg, priority: priority, seq: q.seq})
+	q.total++
+	q.cond.Signal()
+	return true
+}
+
+// requeue queues pkg, which is part of the run, again to be tested anew
+func (q *packageQueue) requeue(pkg string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.seq++
+	heap.Push(&q.items, queuedPackage{name: pkg, seq: q.seq})
+	q.cond.Signal()
+}
+
+// setLimit hands out at most limit packages at once; 0 removes the limit
+func (q *packageQueue) setLimit(limit int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.limit = limit
+	q.cond.Broadcast()
+}
+
+// atLimit reports whether as many packages as the limit allows are running
+func (q *packageQueue) atLimit() bool {
+	return q.limit > 0 && q.running >= q.limit
+}
+
+// cancel removes pkg if it hasn't started yet
+func (q *packageQueue) cancel(pkg string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for i, item := range q.items {
+		if item.name == pkg {
+			heap.Remove(&q.items, i)
+			delete(q.seen, pkg)
+			q.total--
+			q.cond.Broadcast()
+			return true
+		}
+	}
+	return false
+}
+
+// pop waits for the next package. It returns false once the queue is
+// drained. Every package returned must be marked done.
+func (q *packageQueue) pop() (string, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for (len(q.items) == 0 || q.paused || q.chunkFull() || q.atLimit()) && !q.drained {
+		if len(q.items) == 0 && q.running == 0 {
+			q.drained = true
+			q.cond.Broadcast()
+			break
+		}
+		q.cond.Wait()
+	}
+	if q.drained {
+		return "", false
+	}
+
+	item := heap.Pop(&q.items).(queuedPackage)
+	q.running++
+	if q.chunkSize > 0 {
+		q.chunkLeft--
+	}
+	return item.name, true
+}
+
+// setPaused stops or resumes handing out packages. It returns whether the
+// queue was paused before.
+func (q *packageQueue) setPaused(paused bool) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	was := q.paused
+	q.paused = paused
+	q.cond.Broadcast()
+	return was
+}
+
+// isPaused returns whether the queue is paused
+func (q *packageQueue) isPaused() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.paused
+}
+
+// done marks a package returned by pop as finished
+func (q *packageQueue) done() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.running--
+	if q.chunkFull() && q.running == 0 && len(q.items) > 0 {
+		q.chunkDone <- struct{}{}
+	}
+	q.cond.Broadcast()
+}
+
+// stop drains the queue, dropping the packages waiting to start, which it
+// returns with the number of packages still running. Those are marked done
+// as usual.
+func (q *packageQueue) stop() ([]string, int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	dropped := make([]string, 0, len(q.items))
+	for _, item := range q.items {
+		dropped = append(dropped, item.name)
+	}
+	q.items = nil
+	q.drained = true
+	q.cond.Broadcast()
+	return dropped, q.running
+}
+
+// queued returns the packages waiting to start, in the order they will
+func (q *packageQueue) queued() []string {
+	q.mu.Lock()
+	items := append(packageHeap(nil), q.items...)
+	q.mu.Unlock()
+
+	names := make([]string, 0, len(items))
+	for len(items) > 0 {
+		names = append(names, heap.Pop(&items).(queuedPackage).name)
+	}
+	return names
+}
+
+// size returns the number of packages added and not cancelled
+func (q *packageQueue) size() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.total
+}
+
+// packageHeap implements heap.Interface for queued packages
+type packageHeap []queuedPackage
+
+func (h packageHeap) Len() int { return len(h) }
+
+func (h packageHeap) Less(i, j int) bool {
+	if h[i].priority != h[j].priority {
+		return h[i].priority > h[j].priority
+	}
+	return h[i].seq < h[j].seq
+}
+
+func (h packageHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *packageHeap) Push(x interface{}) { *h = append(*h, x.(queuedPackage)) }
+
+func (h *packageHeap) Pop() interface{} {
+	old := *h
+	item := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return item
+}
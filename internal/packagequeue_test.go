@@ -0,0 +1,195 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPackageQueueOrder(t *testing.T) {
+	q := newPackageQueue([]string{"a", "b", "c"})
+
+	if q.push("b", 5) {
+		t.Errorf("Expected a package already in the queue to be ignored")
+	}
+	q.push("urgent", 10)
+	q.push("d", 0)
+	q.push("e", 10)
+
+	if !q.cancel("c") {
+		t.Errorf("Expected c to be cancelled")
+	}
+	if q.cancel("missing") {
+		t.Errorf("Expected cancelling an unknown package to fail")
+	}
+
+	expected := []string{"urgent", "e", "a", "b", "d"}
+	if got := q.queued(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected queued %v, got %v", expected, got)
+	}
+	if q.size() != 5 {
+		t.Errorf("Expected 5 packages, got %d", q.size())
+	}
+
+	var popped []string
+	for {
+		pkg, ok := q.pop()
+		if !ok {
+			break
+		}
+		popped = append(popped, pkg)
+		q.done()
+	}
+	if !reflect.DeepEqual(popped, expected) {
+		t.Errorf("Expected %v, got %v", expected, popped)
+	}
+
+	if q.push("late", 0) {
+		t.Errorf("Expected a drained queue to refuse packages")
+	}
+}
+
+func TestPackageQueueWaitsForRunningTests(t *testing.T) {
+	q := newPackageQueue([]string{"a"})
+	if pkg, ok := q.pop(); !ok || pkg != "a" {
+		t.Fatalf("Expected a, got %q", pkg)
+	}
+
+	// Another worker waits while a is running, since a may add packages
+	next := make(chan string)
+	go func() {
+		pkg, _ := q.pop()
+		next <- pkg
+	}()
+
+	select {
+	case pkg := <-next:
+		t.Fatalf("Expected pop to wait for the running test, got %q", pkg)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	q.push("b", 0)
+	if pkg := <-next; pkg != "b" {
+		t.Errorf("Expected b, got %q", pkg)
+	}
+	q.done()
+	q.done()
+
+	if _, ok := q.pop(); ok {
+		t.Errorf("Expected the queue to be drained")
+	}
+}
+
+func TestPackageQueuePause(t *testing.T) {
+	q := newPackageQueue([]string{"a", "b"})
+	if q.setPaused(true) {
+		t.Errorf("Expected the queue not to be paused initially")
+	}
+
+	next := make(chan string)
+	go func() {
+		pkg, _ := q.pop()
+		next <- pkg
+	}()
+
+	select {
+	case pkg := <-next:
+		t.Fatalf("Expected pop to wait while paused, got %q", pkg)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if !q.setPaused(false) {
+		t.Errorf("Expected the queue to have been paused")
+	}
+	if pkg := <-next; pkg != "a" {
+		t.Errorf("Expected a after resuming, got %q", pkg)
+	}
+}
+
+func TestPackageQueueChunks(t *testing.T) {
+	q := newPackageQueue([]string{"a", "b", "c"})
+	q.setChunkSize(2)
+
+	for _, expected := range []string{"a", "b"} {
+		if pkg, ok := q.pop(); !ok || pkg != expected {
+			t.Fatalf("Expected %s, got %q", expected, pkg)
+		}
+	}
+
+	next := make(chan string)
+	go func() {
+		pkg, _ := q.pop()
+		next <- pkg
+	}()
+
+	q.done()
+	select {
+	case <-q.chunks():
+		t.Fatal("Expected the chunk to finish only after all of its tests")
+	default:
+	}
+	q.done()
+
+	select {
+	case <-q.chunks():
+	case <-time.After(time.Second):
+		t.Fatal("Expected the chunk to finish")
+	}
+	select {
+	case pkg := <-next:
+		t.Fatalf("Expected pop to wait for the next chunk, got %q", pkg)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	q.nextChunk()
+	if pkg := <-next; pkg != "c" {
+		t.Errorf("Expected c in the next chunk, got %q", pkg)
+	}
+	q.done()
+	if _, ok := q.pop(); ok {
+		t.Error("Expected the queue to be drained")
+	}
+}
+
+func TestPackageQueueLimit(t *testing.T) {
+	q := newPackageQueue([]string{"a", "b", "c"})
+	q.setLimit(1)
+	if pkg, ok := q.pop(); !ok || pkg != "a" {
+		t.Fatalf("Expected a, got %q", pkg)
+	}
+
+	popped := make(chan string)
+	go func() {
+		pkg, _ := q.pop()
+		popped <- pkg
+	}()
+	select {
+	case pkg := <-popped:
+		t.Fatalf("Expected the limit to hold %s back", pkg)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// A re-queued package is tested again after the others
+	q.requeue("a")
+	q.done()
+	if pkg := <-popped; pkg != "b" {
+		t.Errorf("Expected b, got %s", pkg)
+	}
+	q.done()
+
+	var rest []string
+	for {
+		pkg, ok := q.pop()
+		if !ok {
+			break
+		}
+		rest = append(rest, pkg)
+		q.done()
+	}
+	if !reflect.DeepEqual(rest, []string{"c", "a"}) {
+		t.Errorf("Expected [c a], got %v", rest)
+	}
+}
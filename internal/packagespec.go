@@ -0,0 +1,192 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// PackageOptions are per-package overrides that can be given inline in a
+// package file, e.g. "llvm timeout=2h skip-without-repo"
+type PackageOptions struct {
+	// Timeout overrides the hang timeout for this package's tests
+	Timeout time.Duration
+	// SkipWithoutRepo disables the retry without the candidate repository
+	// when the test with it fails
+	SkipWithoutRepo bool
+}
+
+// PackageSpec is a package to test together with its options
+type PackageSpec struct {
+	Name    string
+	Options PackageOptions
+	// Line is the line of the package file the spec was read from, if any
+	Line int
+}
+
+// ValidatePackageName checks a package name given to test. Names end up in
+// the paths of configs and logs, so they can't be empty or contain / or ..
+func ValidatePackageName(name string) error {
+	if name == "" {
+		return fmt.Errorf("empty package name")
+	}
+	if strings.Contains(name, "/") || strings.Contains(name, "..") {
+		return fmt.Errorf("invalid package name %q: it must not contain / or ..", name)
+	}
+	return nil
+}
+
+// ParsePackageSpec parses a package file entry: a package name followed by
+// whitespace-separated options. Text after " #" is treated as a comment.
+func ParsePackageSpec(line string) (PackageSpec, error) {
+	if i := strings.Index(line, " #"); i >= 0 {
+		line = line[:i]
+	}
+
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return PackageSpec{}, fmt.Errorf("empty package entry")
+	}
+
+	if err := ValidatePackageName(fields[0]); err != nil {
+		return PackageSpec{}, err
+	}
+	spec := PackageSpec{Name: fields[0]}
+	for _, field := range fields[1:] {
+		key, value, hasValue := strings.Cut(field, "=")
+		switch {
+		case key == "timeout" && hasValue:
+			timeout, err := time.ParseDuration(value)
+			if err != nil || timeout <= 0 {
+				return PackageSpec{}, fmt.Errorf("invalid timeout %q for package %s", value, spec.Name)
+			}
+			spec.Options.Timeout = timeout
+		case key == "skip-without-repo" && !hasValue:
+			spec.Options.SkipWithoutRepo = true
+		default:
+			return PackageSpec{}, fmt.Errorf("unknown option %q for package %s", field, spec.Name)
+		}
+	}
+
+	return spec, nil
+}
+
+// DedupePackageSpecs removes repeated entries for the same package, keeping
+// the first one. Repeated entries with identical options are returned as
+// duplicates so callers can warn about them; entries whose options disagree
+// are an error because it's unclear which one was intended.
+func DedupePackageSpecs(specs []PackageSpec) ([]PackageSpec, []PackageSpec, error) {
+	seen := make(map[string]PackageSpec, len(specs))
+	var unique, duplicates []PackageSpec
+
+	for _, spec := range specs {
+		first, ok := seen[spec.Name]
+		if !ok {
+			seen[spec.Name] = spec
+			unique = append(unique, spec)
+			continue
+		}
+
+		if first.Options != spec.Options {
+			return nil, nil, fmt.Errorf("conflicting entries for package %s (lines %d and %d)", spec.Name, first.Line, spec.Line)
+		}
+		duplicates = append(duplicates, spec)
+	}
+
+	return unique, duplicates, nil
+}
+
+// dedupePackages removes repeated package names, keeping the first
+// occurrence, and returns the names that were dropped
+func dedupePackages(packages []string) ([]string, []string) {
+	seen := make(map[string]bool, len(packages))
+	var unique, duplicates []string
+
+	for _, pkg := range packages {
+		if seen[pkg] {
+			duplicates = append(duplicates, pkg)
+			continue
+		}
+		seen[pkg] = true
+		unique = append(unique, pkg)
+	}
+
+	return unique, duplicates
+}
+
+// isGlob reports whether a package entry is a glob pattern
+func isGlob(name string) bool {
+	return strings.ContainsAny(name, "*?[")
+}
+
+// ExpandPackageGlobs replaces entries that are glob patterns (e.g. "py3-*")
+// with the matching names from available, which is typically the list of
+// packages defined in the package repository. Matches inherit the options of
+// their pattern, but explicitly listed packages take precedence. Patterns
+// without matches are returned so callers can warn about them.
+func ExpandPackageGlobs(specs []PackageSpec, available []string) ([]PackageSpec, []string, error) {
+	explicit := make(map[string]bool)
+	for _, spec := range specs {
+		if !isGlob(spec.Name) {
+			explicit[spec.Name] = true
+		}
+	}
+
+	var expanded []PackageSpec
+	var unmatched []string
+	added := make(map[string]bool)
+	for _, spec := range specs {
+		if !isGlob(spec.Name) {
+			expanded = append(expanded, spec)
+			continue
+		}
+
+		matched := false
+		for _, name := range available {
+			ok, err := path.Match(spec.Name, name)
+			if err != nil {
+				return nil, nil, fmt.Errorf("invalid package pattern %q: %w", spec.Name, err)
+			}
+			if !ok {
+				continue
+			}
+			matched = true
+			if explicit[name] || added[name] {
+				continue
+			}
+			added[name] = true
+			expanded = append(expanded, PackageSpec{Name: name, Options: spec.Options, Line: spec.Line})
+		}
+		if !matched {
+			unmatched = append(unmatched, spec.Name)
+		}
+	}
+
+	return expanded, unmatched, nil
+}
+
+// ListPackageYAMLs returns the names of the packages defined in dir, i.e.
+// the base names of its melange YAML files, sorted
+func ListPackageYAMLs(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list packages in %s: %w", dir, err)
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".yaml" || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		names = append(names, strings.TrimSuffix(entry.Name(), ".yaml"))
+	}
+	sort.Strings(names)
+	return names, nil
+}
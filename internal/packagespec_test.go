@@ -0,0 +1,180 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParsePackageSpec(t *testing.T) {
+	tests := []struct {
+		name          string
+		line          string
+		expected      PackageSpec
+		expectedError string
+	}{
+		{
+			name:     "plain package",
+			line:     "curl",
+			expected: PackageSpec{Name: "curl"},
+		},
+		{
+			name: "timeout and skip-without-repo",
+			line: "llvm timeout=2h skip-without-repo",
+			expected: PackageSpec{Name: "llvm", Options: PackageOptions{
+				Timeout:         2 * time.Hour,
+				SkipWithoutRepo: true,
+			}},
+		},
+		{
+			name:     "trailing comment",
+			line:     "curl # flaky upstream",
+			expected: PackageSpec{Name: "curl"},
+		},
+		{
+			name:          "invalid timeout",
+			line:          "llvm timeout=forever",
+			expectedError: "invalid timeout",
+		},
+		{
+			name:          "unknown option",
+			line:          "llvm retries=2",
+			expectedError: "unknown option",
+		},
+		{
+			name:          "flag with value",
+			line:          "llvm skip-without-repo=true",
+			expectedError: "unknown option",
+		},
+		{
+			name:          "path in name",
+			line:          "../curl timeout=1h",
+			expectedError: "invalid package name",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec, err := ParsePackageSpec(tt.line)
+			if tt.expectedError != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.expectedError) {
+					t.Errorf("Expected error containing '%s', got: %v", tt.expectedError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(spec, tt.expected) {
+				t.Errorf("Expected %+v, got %+v", tt.expected, spec)
+			}
+		})
+	}
+}
+
+func TestValidatePackageName(t *testing.T) {
+	for _, name := range []string{"curl", "py3-*", "libstdc++", "go-1.23", "foo.bar"} {
+		if err := ValidatePackageName(name); err != nil {
+			t.Errorf("Expected %q to be valid, got %v", name, err)
+		}
+	}
+	for _, name := range []string{"", "/", "sub/curl", "..", "../curl", "curl..", "a..b"} {
+		if err := ValidatePackageName(name); err == nil {
+			t.Errorf("Expected %q to be invalid", name)
+		}
+	}
+}
+
+func TestDedupePackageSpecs(t *testing.T) {
+	specs := []PackageSpec{
+		{Name: "curl", Line: 1},
+		{Name: "git", Line: 2},
+		{Name: "curl", Line: 3},
+	}
+
+	unique, duplicates, err := DedupePackageSpecs(specs)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(unique) != 2 || unique[0].Name != "curl" || unique[1].Name != "git" {
+		t.Errorf("Unexpected unique specs: %+v", unique)
+	}
+	if len(duplicates) != 1 || duplicates[0].Line != 3 {
+		t.Errorf("Unexpected duplicates: %+v", duplicates)
+	}
+
+	conflicting := []PackageSpec{
+		{Name: "llvm", Line: 1, Options: PackageOptions{Timeout: time.Hour}},
+		{Name: "llvm", Line: 5},
+	}
+	if _, _, err := DedupePackageSpecs(conflicting); err == nil {
+		t.Error("Expected error for conflicting entries")
+	}
+}
+
+func TestDedupePackages(t *testing.T) {
+	unique, duplicates := dedupePackages([]string{"a", "b", "a", "c", "b"})
+
+	if !reflect.DeepEqual(unique, []string{"a", "b", "c"}) {
+		t.Errorf("Unexpected unique packages: %v", unique)
+	}
+	if !reflect.DeepEqual(duplicates, []string{"a", "b"}) {
+		t.Errorf("Unexpected duplicates: %v", duplicates)
+	}
+}
+
+func TestExpandPackageGlobs(t *testing.T) {
+	available := []string{"curl", "py3-cryptography", "py3-requests", "py3-urllib3"}
+	specs := []PackageSpec{
+		{Name: "py3-requests", Line: 1},
+		{Name: "py3-*", Line: 2, Options: PackageOptions{SkipWithoutRepo: true}},
+		{Name: "ruby-*", Line: 3},
+	}
+
+	expanded, unmatched, err := ExpandPackageGlobs(specs, available)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := []PackageSpec{
+		{Name: "py3-requests", Line: 1},
+		{Name: "py3-cryptography", Line: 2, Options: PackageOptions{SkipWithoutRepo: true}},
+		{Name: "py3-urllib3", Line: 2, Options: PackageOptions{SkipWithoutRepo: true}},
+	}
+	if !reflect.DeepEqual(expanded, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, expanded)
+	}
+	if !reflect.DeepEqual(unmatched, []string{"ruby-*"}) {
+		t.Errorf("Expected unmatched [ruby-*], got %v", unmatched)
+	}
+
+	if _, _, err := ExpandPackageGlobs([]PackageSpec{{Name: "py3-["}}, available); err == nil {
+		t.Error("Expected error for invalid pattern")
+	}
+}
+
+func TestListPackageYAMLs(t *testing.T) {
+	tmpDir := t.TempDir()
+	for _, name := range []string{"curl.yaml", "git.yaml", ".yamllint.yaml", "README.md"} {
+		if err := os.WriteFile(filepath.Join(tmpDir, name), []byte("test"), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(tmpDir, "subdir.yaml"), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+
+	names, err := ListPackageYAMLs(tmpDir)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(names, []string{"curl", "git"}) {
+		t.Errorf("Expected [curl git], got %v", names)
+	}
+}
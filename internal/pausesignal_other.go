@@ -0,0 +1,12 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+//go:build !unix
+
+package internal
+
+// WatchPauseSignals does nothing where SIGUSR1 and SIGUSR2 don't exist; runs
+// can still be paused through the control socket
+func WatchPauseSignals(runners []*RegressionTestRunner) func() {
+	return func() {}
+}
@@ -0,0 +1,42 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+//go:build unix
+
+package internal
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// WatchPauseSignals pauses the run in progress of runners on SIGUSR1 and
+// resumes it on SIGUSR2, until the returned function is called
+func WatchPauseSignals(runners []*RegressionTestRunner) func() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGUSR1, syscall.SIGUSR2)
+	done := make(chan struct{})
+
+	go func() {
+		for {
+			select {
+			case sig := <-signals:
+				for _, runner := range runners {
+					if sig == syscall.SIGUSR1 {
+						runner.Pause()
+					} else {
+						runner.Resume()
+					}
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(signals)
+		close(done)
+	}
+}
@@ -0,0 +1,33 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+//go:build unix
+
+package internal
+
+import (
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestWatchPauseSignals(t *testing.T) {
+	runner := &RegressionTestRunner{verbose: true, queue: newPackageQueue([]string{"a"})}
+	stop := WatchPauseSignals([]*RegressionTestRunner{runner})
+	defer stop()
+
+	waitFor := func(paused bool) {
+		deadline := time.Now().Add(5 * time.Second)
+		for runner.queue.isPaused() != paused {
+			if time.Now().After(deadline) {
+				t.Fatalf("Expected paused to become %v", paused)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	syscall.Kill(syscall.Getpid(), syscall.SIGUSR1)
+	waitFor(true)
+	syscall.Kill(syscall.Getpid(), syscall.SIGUSR2)
+	waitFor(false)
+}
@@ -0,0 +1,177 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Pin constrains a package installed into with-repo tests to one version, so
+// that a higher version in the baseline repository can't be installed
+// instead of the candidate under test
+type Pin struct {
+	Name string `json:"name"`
+	// Version is "" until resolved to the candidate repository's version
+	Version string `json:"version,omitempty"`
+}
+
+func (p Pin) String() string {
+	if p.Version == "" {
+		return p.Name
+	}
+	return p.Name + "=" + p.Version
+}
+
+// LoadPins reads a pins file: one package per line, either name=version or a
+// bare name, which pins the version in the candidate repository. Empty lines
+// and lines starting with # are ignored, and text after " #" is a comment.
+func LoadPins(path string) ([]Pin, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pins: %w", err)
+	}
+	defer file.Close()
+
+	var pins []Pin
+	seen := make(map[string]int)
+	scanner := bufio.NewScanner(file)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := scanner.Text()
+		if i := strings.Index(line, " #"); i >= 0 {
+			line = line[:i]
+		}
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		pin, err := parsePin(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, lineNumber, err)
+		}
+		if first, ok := seen[pin.Name]; ok {
+			return nil, fmt.Errorf("%s:%d: %s is already pinned on line %d", path, lineNumber, pin.Name, first)
+		}
+		seen[pin.Name] = lineNumber
+		pins = append(pins, pin)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read pins: %w", err)
+	}
+	return pins, nil
+}
+
+// parsePin parses a pins file entry
+func parsePin(entry string) (Pin, error) {
+	if strings.ContainsAny(entry, " \t") {
+		return Pin{}, fmt.Errorf("invalid pin %q: expected name or name=version", entry)
+	}
+	if strings.ContainsAny(entry, "<>~") {
+		return Pin{}, fmt.Errorf("invalid pin %q: only exact versions can be pinned", entry)
+	}
+	name, version, hasVersion := strings.Cut(entry, "=")
+	if name == "" || hasVersion && version == "" {
+		return Pin{}, fmt.Errorf("invalid pin %q: expected name or name=version", entry)
+	}
+	return Pin{Name: name, Version: version}, nil
+}
+
+// resolvePins checks the pins against the packages of the candidate index
+// and fills in the versions of bare names. Pinning a version the candidate
+// repository doesn't have is an error, since every with-repo test would fail
+// to install it and report a regression.
+func resolvePins(pins []Pin, candidates []apkIndexEntry) ([]Pin, error) {
+	versions := make(map[string][]string)
+	for _, entry := range candidates {
+		versions[entry.Name] = append(versions[entry.Name], entry.Version)
+	}
+
+	resolved := make([]Pin, 0, len(pins))
+	for _, pin := range pins {
+		available := versions[pin.Name]
+		if len(available) == 0 {
+			return nil, fmt.Errorf("pinned package %s is not in the candidate repository", pin.Name)
+		}
+		sort.Slice(available, func(i, j int) bool {
+			return compareAPKVersions(available[i], available[j]) > 0
+		})
+		if pin.Version == "" {
+			pin.Version = available[0]
+		}
+		found := false
+		for _, version := range available {
+			if version == pin.Version {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("candidate repository has %s %s, not the pinned %s", pin.Name, strings.Join(available, ", "), pin.Version)
+		}
+		resolved = append(resolved, pin)
+	}
+	return resolved, nil
+}
+
+// SetPins constrains the packages installed into with-repo tests (see Pin)
+func (m *MelangeClient) SetPins(pins []Pin) {
+	m.pins = pins
+}
+
+// pinOpts returns the melange options adding the pins to the test
+// environments
+func (m *MelangeClient) pinOpts() []string {
+	var opts []string
+	for _, pin := range m.pins {
+		opts = append(opts, "--test-package-append", pin.String())
+	}
+	return opts
+}
+
+// SetPins pins packages of the candidate repository in with-repo tests. Bare
+// names are resolved to their version in the candidate repository before
+// testing starts.
+func (r *RegressionTestRunner) SetPins(pins []Pin) {
+	r.pins = pins
+}
+
+// resolveRunPins resolves the pins against the candidate repository, adding
+// the one of the package under test with package@version, and hands them to
+// melange
+func (r *RegressionTestRunner) resolveRunPins() error {
+	if len(r.pins) == 0 && r.targetVersion == "" {
+		return nil
+	}
+	index, err := loadCandidateIndex(candidateIndexURL(r.apkRepo, hostArch()))
+	if err != nil {
+		return fmt.Errorf("failed to resolve pins: %w", err)
+	}
+	if err := r.pinTargetVersion(index.Packages); err != nil {
+		return err
+	}
+	if len(r.pins) == 0 {
+		return nil
+	}
+	pins, err := resolvePins(r.pins, index.Packages)
+	if err != nil {
+		return err
+	}
+	r.pins = pins
+	if r.melange != nil {
+		r.melange.SetPins(pins)
+	}
+
+	names := make([]string, len(pins))
+	for i, pin := range pins {
+		names[i] = pin.String()
+	}
+	fmt.Printf("Pinning %s in tests with the candidate repository\n", strings.Join(names, ", "))
+	return nil
+}
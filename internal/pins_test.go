@@ -0,0 +1,134 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadPins(t *testing.T) {
+	tests := []struct {
+		name        string
+		content     string
+		expected    []Pin
+		expectedErr string
+	}{
+		{
+			name:     "names and versions",
+			content:  "# pins\nopenssl  # candidate version\n\nlibcrypto3=3.3.2-r1\n",
+			expected: []Pin{{Name: "openssl"}, {Name: "libcrypto3", Version: "3.3.2-r1"}},
+		},
+		{name: "range", content: "openssl>=3.3\n", expectedErr: "only exact versions"},
+		{name: "empty version", content: "openssl=\n", expectedErr: "expected name or name=version"},
+		{name: "options", content: "openssl timeout=1h\n", expectedErr: "expected name or name=version"},
+		{name: "duplicate", content: "openssl\nopenssl=3.3.2-r1\n", expectedErr: "already pinned on line 1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "pins.txt")
+			if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			pins, err := LoadPins(path)
+			if tt.expectedErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.expectedErr) {
+					t.Errorf("Expected error containing %q, got %v", tt.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(pins, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, pins)
+			}
+		})
+	}
+}
+
+func TestResolvePins(t *testing.T) {
+	candidates := []apkIndexEntry{
+		{Name: "openssl", Version: "3.3.2-r1"},
+		{Name: "openssl", Version: "3.3.10-r0"},
+		{Name: "libcrypto3", Version: "3.3.2-r1"},
+	}
+
+	tests := []struct {
+		name        string
+		pins        []Pin
+		expected    []Pin
+		expectedErr string
+	}{
+		{
+			name:     "bare names get the highest candidate version",
+			pins:     []Pin{{Name: "openssl"}, {Name: "libcrypto3"}},
+			expected: []Pin{{Name: "openssl", Version: "3.3.10-r0"}, {Name: "libcrypto3", Version: "3.3.2-r1"}},
+		},
+		{
+			name:     "explicit version",
+			pins:     []Pin{{Name: "openssl", Version: "3.3.2-r1"}},
+			expected: []Pin{{Name: "openssl", Version: "3.3.2-r1"}},
+		},
+		{name: "missing package", pins: []Pin{{Name: "curl"}}, expectedErr: "curl is not in the candidate repository"},
+		{name: "missing version", pins: []Pin{{Name: "openssl", Version: "3.4.0-r0"}}, expectedErr: "not the pinned 3.4.0-r0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pins, err := resolvePins(tt.pins, candidates)
+			if tt.expectedErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.expectedErr) {
+					t.Errorf("Expected error containing %q, got %v", tt.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(pins, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, pins)
+			}
+		})
+	}
+}
+
+func TestPinOpts(t *testing.T) {
+	if _, err := exec.LookPath("make"); err != nil {
+		t.Skip("make not available")
+	}
+
+	tmpDir := t.TempDir()
+	logDir := filepath.Join(tmpDir, "logs")
+	os.MkdirAll(logDir, 0755)
+	os.WriteFile(filepath.Join(tmpDir, "test-package.yaml"), []byte("test"), 0644)
+	os.WriteFile(filepath.Join(tmpDir, "Makefile"), []byte("test/test-package:\n\t@echo $$MELANGE_EXTRA_OPTS\n"), 0644)
+
+	client := NewMelangeClient(tmpDir, false, logDir, time.Minute)
+	client.SetPins([]Pin{{Name: "openssl", Version: "3.3.2-r1"}})
+
+	result := client.Execute(context.Background(), "test-package", ExecuteOptions{WithRepo: true, APKRepo: "https://example.com/repo"})
+	if !result.Success {
+		t.Fatalf("Expected test to pass, got %v", result.Error)
+	}
+	content, _ := os.ReadFile(result.LogPath)
+	expected := "--repository-append https://example.com/repo --test-package-append openssl=3.3.2-r1"
+	if !strings.Contains(string(content), expected) {
+		t.Errorf("Expected %q in MELANGE_EXTRA_OPTS, got %q", expected, content)
+	}
+
+	// Tests without the candidate repository can't install candidate versions
+	result = client.Execute(context.Background(), "test-package", ExecuteOptions{})
+	content, _ = os.ReadFile(result.LogPath)
+	if strings.Contains(string(content), "--test-package-append") {
+		t.Errorf("Expected no pins without the candidate repository, got %q", content)
+	}
+}
@@ -0,0 +1,81 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// Platform describes the operating system apkregress runs on
+type Platform struct {
+	OS string `json:"os"`
+	// Kernel is the kernel release, e.g. 6.6.87.2-microsoft-standard-WSL2
+	Kernel string `json:"kernel,omitempty"`
+	// WSL is the version of the Windows Subsystem for Linux, 0 outside it
+	WSL int `json:"wsl,omitempty"`
+}
+
+// MelangeRunnerDocker runs melange tests in docker containers
+const MelangeRunnerDocker = "docker"
+
+// DetectPlatform describes the host
+func DetectPlatform() Platform {
+	var kernel string
+	if data, err := os.ReadFile("/proc/sys/kernel/osrelease"); err == nil {
+		kernel = strings.TrimSpace(string(data))
+	}
+	return platformFor(runtime.GOOS, kernel)
+}
+
+func platformFor(goos, kernel string) Platform {
+	p := Platform{OS: goos, Kernel: kernel}
+	if goos == "linux" && strings.Contains(strings.ToLower(kernel), "microsoft") {
+		// WSL 1 kernels end in -Microsoft, WSL 2 kernels in
+		// -microsoft-standard or -microsoft-standard-WSL2
+		p.WSL = 1
+		if strings.Contains(kernel, "microsoft-standard") || strings.Contains(kernel, "WSL2") {
+			p.WSL = 2
+		}
+	}
+	return p
+}
+
+// String names the platform, e.g. "linux", "wsl2" or "darwin"
+func (p Platform) String() string {
+	if p.WSL > 0 {
+		return fmt.Sprintf("wsl%d", p.WSL)
+	}
+	return p.OS
+}
+
+// CheckPlatform returns the melange runner tests need on this host: "" for
+// melange's default, or MelangeRunnerDocker where melange can't sandbox
+// tests itself. Hosts that can't run tests at all get an error explaining
+// where to run apkregress instead. The fake backend runs anywhere.
+func CheckPlatform() (string, error) {
+	if FakeBackend() {
+		return "", nil
+	}
+	_, err := exec.LookPath("docker")
+	return checkPlatform(DetectPlatform(), err == nil)
+}
+
+func checkPlatform(p Platform, haveDocker bool) (string, error) {
+	switch {
+	case p.OS == "windows":
+		return "", fmt.Errorf("apkregress can't run on Windows directly, since melange tests need Linux; run it inside WSL 2 (wsl --install) or a Linux container")
+	case p.WSL == 1:
+		return "", fmt.Errorf("WSL 1 (kernel %s) lacks the namespaces melange sandboxes tests with; convert the distribution to WSL 2 with `wsl --set-version <distro> 2`", p.Kernel)
+	case p.OS == "linux":
+		return "", nil
+	case haveDocker:
+		return MelangeRunnerDocker, nil
+	default:
+		return "", fmt.Errorf("melange can't sandbox tests on %s without docker; install Docker Desktop (or another docker-compatible runtime) or run apkregress on Linux", p.OS)
+	}
+}
@@ -0,0 +1,75 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPlatformFor(t *testing.T) {
+	tests := []struct {
+		goos     string
+		kernel   string
+		expected string
+	}{
+		{"linux", "6.8.0-51-generic", "linux"},
+		{"linux", "4.4.0-19041-Microsoft", "wsl1"},
+		{"linux", "5.15.167.4-microsoft-standard-WSL2", "wsl2"},
+		{"darwin", "", "darwin"},
+		{"windows", "", "windows"},
+	}
+
+	for _, tt := range tests {
+		if platform := platformFor(tt.goos, tt.kernel); platform.String() != tt.expected {
+			t.Errorf("Expected %s for %s %s, got %s", tt.expected, tt.goos, tt.kernel, platform)
+		}
+	}
+}
+
+func TestCheckPlatform(t *testing.T) {
+	tests := []struct {
+		name           string
+		platform       Platform
+		haveDocker     bool
+		expectedRunner string
+		expectedError  string
+	}{
+		{name: "linux", platform: platformFor("linux", "6.8.0-51-generic")},
+		{name: "wsl2", platform: platformFor("linux", "5.15.167.4-microsoft-standard-WSL2")},
+		{name: "wsl1", platform: platformFor("linux", "4.4.0-19041-Microsoft"), expectedError: "wsl --set-version"},
+		{name: "windows", platform: platformFor("windows", ""), haveDocker: true, expectedError: "inside WSL 2"},
+		{name: "macOS with docker", platform: platformFor("darwin", ""), haveDocker: true, expectedRunner: MelangeRunnerDocker},
+		{name: "macOS without docker", platform: platformFor("darwin", ""), expectedError: "without docker"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runner, err := checkPlatform(tt.platform, tt.haveDocker)
+			if tt.expectedError != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.expectedError) {
+					t.Errorf("Expected error to contain '%s', got: %v", tt.expectedError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if runner != tt.expectedRunner {
+				t.Errorf("Expected runner %q, got %q", tt.expectedRunner, runner)
+			}
+		})
+	}
+}
+
+func TestSetMelangeRunner(t *testing.T) {
+	runner := NewRegressionTestRunnerFromPackageList([]string{"curl"}, "https://example.com/repo", t.TempDir(), "wolfi", 1, false, 0, false)
+	runner.SetMelangeRunner(MelangeRunnerDocker)
+
+	expected := []string{"--repository-append", "https://example.com/repo", "--runner", "docker"}
+	if opts := runner.melange.extraOpts(true, "https://example.com/repo"); !reflect.DeepEqual(opts, expected) {
+		t.Errorf("Expected %v, got %v", expected, opts)
+	}
+}
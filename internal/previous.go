@@ -0,0 +1,201 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// maxPreviousCommits is how far back the history of a config is searched
+// for its previous version
+const maxPreviousCommits = 100
+
+// Outcomes of testing the previous version of a consumer
+const (
+	// PreviousPasses means the previous version passes with the candidate
+	// repository, so that only the current version is broken by it
+	PreviousPasses = "passes"
+	// PreviousRegressed means the previous version fails with the candidate
+	// repository and passes without it, like the current version
+	PreviousRegressed = "regressed"
+	// PreviousBroken means the previous version fails without the candidate
+	// repository too, so the consumer was already broken at its old version
+	PreviousBroken = "broken"
+)
+
+// PreviousTest is the outcome of testing the previous released version
+// of a consumer that fails with the candidate repository (see
+// SetTestPrevious)
+type PreviousTest struct {
+	// Version is the previous version, e.g. 8.4.0-r2
+	Version string `json:"version"`
+	// Commit is the commit of the package repository its config is read at
+	Commit  string `json:"commit"`
+	Outcome string `json:"outcome,omitempty"`
+	LogPath string `json:"logPath,omitempty"`
+	// Error is why the previous version couldn't be tested, if it wasn't
+	Error string `json:"error,omitempty"`
+}
+
+// SetTestPrevious also tests the previous released version of each consumer
+// that fails with the candidate repository, as of the last commit before
+// its config's current version, to tell consumers that were already broken
+// at their old version from regressions the candidate repository introduces
+func (r *RegressionTestRunner) SetTestPrevious(enabled bool) {
+	r.testPrevious = enabled
+}
+
+// String describes the outcome, e.g. "previous version 8.4.0-r2 passes"
+func (p *PreviousTest) String() string {
+	switch p.Outcome {
+	case PreviousPasses:
+		return fmt.Sprintf("previous version %s passes", p.Version)
+	case PreviousRegressed:
+		return fmt.Sprintf("previous version %s regressed too", p.Version)
+	case PreviousBroken:
+		return fmt.Sprintf("previous version %s was already broken", p.Version)
+	}
+	if p.Version == "" {
+		return "previous version not tested"
+	}
+	return fmt.Sprintf("previous version %s not tested", p.Version)
+}
+
+// previousNote formats the outcome of the previous version of a consumer,
+// or returns "" if it wasn't tested
+func (r *RegressionTestRunner) previousNote(pkg, format string) string {
+	result, ok := r.packageResults[pkg][true]
+	if !ok || result.Previous == nil {
+		return ""
+	}
+	return fmt.Sprintf(format, result.Previous)
+}
+
+// testPreviousVersion tests the previous version of packageName with the
+// candidate repository and, if that fails, without it, in a worktree of
+// the package repository at the commit of that version
+func (r *RegressionTestRunner) testPreviousVersion(ctx context.Context, packageName string, executor TestExecutor, scratch string) *PreviousTest {
+	if r.melange == nil {
+		return nil
+	}
+	configPath, err := r.melange.locator.Locate(packageName)
+	if err != nil {
+		return &PreviousTest{Error: err.Error()}
+	}
+	rel, err := filepath.Rel(r.repoPath, configPath)
+	if err != nil {
+		return &PreviousTest{Error: err.Error()}
+	}
+	commit, version, err := previousVersionCommit(r.repoPath, filepath.ToSlash(rel))
+	if err != nil {
+		return &PreviousTest{Error: err.Error()}
+	}
+	previous := &PreviousTest{Version: version, Commit: commit}
+
+	worktree, err := NewWorktree(r.repoPath, commit)
+	if err != nil {
+		previous.Error = err.Error()
+		return previous
+	}
+	defer func() {
+		if err := worktree.Remove(); err != nil {
+			fmt.Printf("Warning: %v\n", err)
+		}
+	}()
+
+	test := func(withRepo bool) TestResult {
+		if scratch != "" {
+			if err := emptyDir(scratch); err != nil {
+				fmt.Printf("Warning: failed to clean worker directory %s: %v\n", scratch, err)
+			}
+		}
+		r.watchdog.started(packageName)
+		defer r.watchdog.finished(packageName)
+		return markInfra(executor.Execute(ctx, packageName, ExecuteOptions{
+			WithRepo: withRepo,
+			APKRepo:  r.apkRepo,
+			Timeout:  r.timeoutFor(packageName),
+			WorkDir:  scratch,
+			Previous: worktree.Path,
+		}))
+	}
+
+	withRepo := test(true)
+	result := withRepo
+	if !withRepo.Success && !withRepo.Skipped && withRepo.Infra == "" && !withRepo.Hung {
+		result = test(false)
+	}
+	previous.LogPath = result.LogPath
+
+	switch {
+	case result.Infra != "":
+		previous.Error = "infrastructure failure: " + result.Infra
+	case result.Hung:
+		previous.Error = "test hung"
+	case result.Skipped:
+		previous.Error = fmt.Sprint(result.Error)
+	case withRepo.Success:
+		previous.Outcome = PreviousPasses
+	case result.Success:
+		previous.Outcome = PreviousRegressed
+	default:
+		previous.Outcome = PreviousBroken
+	}
+	return previous
+}
+
+// previousVersionCommit searches the history of the config at path,
+// relative to the git repository at repoPath, for the last commit at which
+// it had another version than in the working tree, and returns it with
+// that version
+func previousVersionCommit(repoPath, path string) (commit, version string, err error) {
+	current, err := configVersionAt(repoPath, path, "")
+	if err != nil {
+		return "", "", err
+	}
+
+	log := exec.Command("git", "log", "--format=%H", fmt.Sprintf("-n%d", maxPreviousCommits), "--", path)
+	log.Dir = repoPath
+	output, err := log.Output()
+	if err != nil {
+		return "", "", fmt.Errorf("failed to read the history of %s: %w", path, commandError(err))
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		commit := strings.TrimSpace(scanner.Text())
+		version, err := configVersionAt(repoPath, path, commit)
+		if err != nil {
+			// Deleted or renamed at this commit, so there is no earlier
+			// version at this path
+			break
+		}
+		if version != current {
+			return commit, version, nil
+		}
+	}
+	return "", "", fmt.Errorf("no previous version of %s in its last %d commits", path, maxPreviousCommits)
+}
+
+// configVersionAt returns the full version of the config at path at the git
+// ref, or in the working tree if ref is empty, e.g. 8.4.0-r2
+func configVersionAt(repoPath, path, ref string) (string, error) {
+	data, err := readConfigAt(repoPath, path, ref)
+	if err != nil {
+		return "", err
+	}
+	var config MelangeConfig
+	if err := yaml.Unmarshal(data, &config); err != nil || config.Package.Version == "" {
+		return "", fmt.Errorf("%s is not a melange config", path)
+	}
+	return fmt.Sprintf("%s-r%d", config.Package.Version, config.Package.Epoch), nil
+}
@@ -0,0 +1,124 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// previousRepo creates a git repository in which curl.yaml was bumped from
+// 8.9.0 to 8.10.0, and returns it with the last commit of 8.9.0
+func previousRepo(t *testing.T) (string, string) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+
+	repo := t.TempDir()
+	git := func(args ...string) string {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		cmd.Dir = repo
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v failed: %v\n%s", args, err, output)
+		}
+		return strings.TrimSpace(string(output))
+	}
+	write := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(repo, "curl.yaml"), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	git("init", "-q")
+	write("package:\n  name: curl\n  version: 8.9.0\n")
+	git("add", ".")
+	git("commit", "-q", "-m", "initial")
+	write("package:\n  name: curl\n  version: 8.9.0\n  description: URL retrieval utility\n")
+	git("commit", "-q", "-am", "describe")
+	previous := git("rev-parse", "HEAD")
+	write("package:\n  name: curl\n  version: 8.10.0\n  description: URL retrieval utility\n")
+	git("commit", "-q", "-am", "update")
+	return repo, previous
+}
+
+func TestPreviousVersionCommit(t *testing.T) {
+	repo, expected := previousRepo(t)
+
+	commit, version, err := previousVersionCommit(repo, "curl.yaml")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if commit != expected {
+		t.Errorf("Expected commit %s, got %s", expected, commit)
+	}
+	if version != "8.9.0-r0" {
+		t.Errorf("Expected version 8.9.0-r0, got %s", version)
+	}
+
+	// Without a bump, there is no previous version
+	if err := os.WriteFile(filepath.Join(repo, "wget.yaml"), []byte("package:\n  name: wget\n  version: 1.24.5\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := previousVersionCommit(repo, "wget.yaml"); err == nil {
+		t.Error("Expected an error for a config without history")
+	}
+}
+
+func TestRunnerTestPrevious(t *testing.T) {
+	tests := []struct {
+		name     string
+		passing  map[string]bool // tests of the previous version that pass, e.g. "previous with"
+		expected string
+	}{
+		{"previous passes", map[string]bool{"previous with": true}, PreviousPasses},
+		{"previous regressed", map[string]bool{"previous without": true}, PreviousRegressed},
+		{"previous broken", map[string]bool{}, PreviousBroken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo, _ := previousRepo(t)
+			runner := NewRegressionTestRunner("", "https://example.com/repo", repo, "wolfi", 1, false, time.Minute, false)
+			runner.setLogDir(t.TempDir())
+			runner.SetTestPrevious(true)
+			runner.SetExecutor(TestExecutorFunc(func(ctx context.Context, pkg string, opts ExecuteOptions) TestResult {
+				key := "current"
+				if opts.Previous != "" {
+					data, err := os.ReadFile(filepath.Join(opts.Previous, "curl.yaml"))
+					if err != nil || !strings.Contains(string(data), "8.9.0") {
+						t.Errorf("Expected the previous config in %s, got %q (%v)", opts.Previous, data, err)
+					}
+					key = "previous"
+				}
+				key += map[bool]string{true: " with", false: " without"}[opts.WithRepo]
+				// The current version regresses
+				if key == "current without" || tt.passing[key] {
+					return newTestResult(pkg, opts.WithRepo, time.Now(), "", nil)
+				}
+				return newTestResult(pkg, opts.WithRepo, time.Now(), "", errors.New("test failed"))
+			}))
+
+			runner.RunFromPackageList([]string{"curl"})
+			previous := runner.packageResults["curl"][true].Previous
+			if previous == nil {
+				t.Fatal("Expected the previous version to be tested")
+			}
+			if previous.Outcome != tt.expected || previous.Version != "8.9.0-r0" {
+				t.Errorf("Expected 8.9.0-r0 to be %s, got %+v", tt.expected, previous)
+			}
+			if runner.summary.Regressions[0] != "curl" {
+				t.Errorf("Expected curl to remain a regression, got %v", runner.summary.Regressions)
+			}
+		})
+	}
+}
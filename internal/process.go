@@ -0,0 +1,100 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"context"
+	"os/exec"
+	"time"
+)
+
+// StopGrace is how long the tests of a stopped run have to stop before they
+// are killed, short enough for the run to report its results within the
+// grace period of container orchestrators and of the daemon
+const StopGrace = 10 * time.Second
+
+// waitWithTimeout waits for a command started with startInProcessGroup to
+// exit. If it is still running after timeout, the whole process group is
+// killed and ErrTestHung is returned. If ctx is cancelled first, the group is
+// asked to stop, killed if it is still running after StopGrace, and ctx's
+// error is returned.
+func waitWithTimeout(ctx context.Context, cmd *exec.Cmd, timeout time.Duration) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case err := <-done:
+		return err
+	case <-timer.C:
+		killProcessGroup(cmd)
+		<-done
+		return ErrTestHung
+	case <-ctx.Done():
+	}
+	terminate(cmd, done, StopGrace)
+	return ctx.Err()
+}
+
+// testUsage is the resources a test used
+type testUsage struct {
+	// PeakMemory is the peak resident memory of its process tree in bytes
+	PeakMemory uint64
+	// CPUTime is the user and system CPU time of its process tree, as far
+	// as the processes were waited for
+	CPUTime time.Duration
+	// OOMKilled is set if the kernel killed one of its processes for lack
+	// of memory
+	OOMKilled bool
+}
+
+// waitMeasured waits for a command started with startInProcessGroup like
+// waitWithTimeout and records the resources its process tree used in usage
+func waitMeasured(ctx context.Context, cmd *exec.Cmd, timeout time.Duration, usage *testUsage) error {
+	oom := startOOMWatch()
+	sampler := startMemorySampler(cmd.Process.Pid, memorySampleInterval)
+	err := waitWithTimeout(ctx, cmd, timeout)
+	usage.PeakMemory = sampler.Stop()
+	usage.OOMKilled = oom.killed(sampler.processes(), err)
+	if cmd.ProcessState != nil {
+		// The rusage of a process includes the children it waited for
+		usage.CPUTime = cmd.ProcessState.UserTime() + cmd.ProcessState.SystemTime()
+	}
+	return err
+}
+
+// waitTerminating waits for a command started with startInProcessGroup to
+// exit like waitContext, but asks its process group to stop when ctx is
+// done, killing it only if the command is still running after grace
+func waitTerminating(ctx context.Context, cmd *exec.Cmd, grace time.Duration) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+	}
+	terminate(cmd, done, grace)
+	return ctx.Err()
+}
+
+// terminate asks the process group of cmd to stop and kills it if it is
+// still running after grace. done receives once cmd was waited for.
+func terminate(cmd *exec.Cmd, done <-chan error, grace time.Duration) {
+	terminateProcessGroup(cmd)
+	timer := time.NewTimer(grace)
+	defer timer.Stop()
+	select {
+	case <-done:
+	case <-timer.C:
+		killProcessGroup(cmd)
+		<-done
+	}
+}
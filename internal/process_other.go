@@ -0,0 +1,24 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+//go:build !unix
+
+package internal
+
+import "os/exec"
+
+// startInProcessGroup starts cmd. Platforms without process groups can't
+// run tests (see CheckPlatform), but still build so they can report that.
+func startInProcessGroup(cmd *exec.Cmd) error {
+	return cmd.Start()
+}
+
+// killProcessGroup kills the command; its child processes are left running
+func killProcessGroup(cmd *exec.Cmd) {
+	cmd.Process.Kill()
+}
+
+// terminateProcessGroup kills the command, as it can't be asked to stop
+func terminateProcessGroup(cmd *exec.Cmd) {
+	cmd.Process.Kill()
+}
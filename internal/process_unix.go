@@ -0,0 +1,42 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+//go:build unix
+
+package internal
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// startInProcessGroup starts cmd in its own process group so that
+// waitWithTimeout can kill all of its child processes on timeout.
+func startInProcessGroup(cmd *exec.Cmd) error {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	return cmd.Start()
+}
+
+// killProcessGroup kills a command started with startInProcessGroup and all
+// of its child processes
+func killProcessGroup(cmd *exec.Cmd) {
+	pgid, err := syscall.Getpgid(cmd.Process.Pid)
+	if err == nil {
+		// Kill the process group (negative PID kills the group)
+		syscall.Kill(-pgid, syscall.SIGKILL)
+	} else {
+		// Fallback to killing just the main process
+		cmd.Process.Kill()
+	}
+}
+
+// terminateProcessGroup asks a command started with startInProcessGroup
+// and its child processes to stop, letting them clean up
+func terminateProcessGroup(cmd *exec.Cmd) {
+	pgid, err := syscall.Getpgid(cmd.Process.Pid)
+	if err == nil {
+		syscall.Kill(-pgid, syscall.SIGTERM)
+	} else {
+		cmd.Process.Signal(syscall.SIGTERM)
+	}
+}
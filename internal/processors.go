@@ -0,0 +1,272 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ResultProcessor receives the results of a run as they come in, e.g. to
+// send them to an internal dashboard or a data lake. Processors are
+// compiled in with RegisterResultProcessor, or run as separate programs
+// reading the results as NDJSON on stdin (see "exec").
+type ResultProcessor interface {
+	// Start is called before the first test with the log directory and
+	// manifest of the run
+	Start(logDir string, manifest *RunManifest) error
+	// Process is called with each test result as it is collected
+	Process(result TestResult) error
+	// Finish is called with the summary once the result files are written
+	Finish(summary SummaryReport) error
+}
+
+// ResultProcessorFactory creates a processor from its configuration, the
+// part of its spec after the colon
+type ResultProcessorFactory func(config string) (ResultProcessor, error)
+
+var (
+	resultProcessorsMu sync.Mutex
+	resultProcessors   = map[string]ResultProcessorFactory{
+		"exec": newExecProcessor,
+		"file": newFileProcessor,
+	}
+)
+
+// RegisterResultProcessor makes a processor available under name, for
+// NewResultProcessor and --result-processor. It is meant to be called from
+// init functions of packages built into apkregress.
+func RegisterResultProcessor(name string, factory ResultProcessorFactory) {
+	resultProcessorsMu.Lock()
+	defer resultProcessorsMu.Unlock()
+	resultProcessors[name] = factory
+}
+
+// ResultProcessorNames returns the sorted names of the registered processors
+func ResultProcessorNames() []string {
+	resultProcessorsMu.Lock()
+	defer resultProcessorsMu.Unlock()
+	return sortedKeys(resultProcessors)
+}
+
+// NewResultProcessor creates the processor of spec, NAME or NAME:CONFIG, e.g.
+// "exec:./upload.py --table regressions"
+func NewResultProcessor(spec string) (ResultProcessor, error) {
+	name, config, _ := strings.Cut(spec, ":")
+	resultProcessorsMu.Lock()
+	factory, ok := resultProcessors[name]
+	resultProcessorsMu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown result processor %q, expected one of %s", name, strings.Join(ResultProcessorNames(), ", "))
+	}
+	processor, err := factory(config)
+	if err != nil {
+		return nil, fmt.Errorf("result processor %s: %w", name, err)
+	}
+	return processor, nil
+}
+
+// AddResultProcessor passes the results of runs to processor. A processor
+// that fails is reported and gets nothing more, without failing the run.
+func (r *RegressionTestRunner) AddResultProcessor(processor ResultProcessor) {
+	r.processors = append(r.processors, processor)
+}
+
+// startProcessors starts the result processors, dropping those that fail
+func (r *RegressionTestRunner) startProcessors() {
+	r.activeProcessors = nil
+	for _, processor := range r.processors {
+		if err := processor.Start(r.logDir, r.manifest); err != nil {
+			fmt.Printf("Warning: result processor failed to start: %v\n", err)
+			continue
+		}
+		r.activeProcessors = append(r.activeProcessors, processor)
+	}
+}
+
+// processResult passes result to the result processors, dropping those that
+// fail
+func (r *RegressionTestRunner) processResult(result TestResult) {
+	kept := r.activeProcessors[:0]
+	for _, processor := range r.activeProcessors {
+		if err := processor.Process(result); err != nil {
+			r.printResult("Warning: result processor failed, it gets no more results: %v\n", err)
+			continue
+		}
+		kept = append(kept, processor)
+	}
+	r.activeProcessors = kept
+}
+
+// finishProcessors passes the summary of the run to the result processors
+func (r *RegressionTestRunner) finishProcessors() {
+	if len(r.activeProcessors) == 0 {
+		return
+	}
+	summary := r.summaryReport()
+	for _, processor := range r.activeProcessors {
+		if err := processor.Finish(summary); err != nil {
+			fmt.Printf("Warning: result processor failed: %v\n", err)
+		}
+	}
+	r.activeProcessors = nil
+}
+
+// processorRecord is a line of the NDJSON stream of the exec and file
+// processors: the manifest first, then each result, then the summary
+type processorRecord struct {
+	Type     string         `json:"type"`
+	Manifest *RunManifest   `json:"manifest,omitempty"`
+	Result   *TestResult    `json:"result,omitempty"`
+	Summary  *SummaryReport `json:"summary,omitempty"`
+}
+
+// Types of processorRecord
+const (
+	processorRecordStart   = "start"
+	processorRecordResult  = "result"
+	processorRecordSummary = "summary"
+)
+
+// ndjsonProcessor writes the records of a run to a stream, one JSON object
+// per line
+type ndjsonProcessor struct {
+	encoder *json.Encoder
+}
+
+func (p *ndjsonProcessor) Process(result TestResult) error {
+	return p.encoder.Encode(processorRecord{Type: processorRecordResult, Result: &result})
+}
+
+// processorTimeout is how long an exec processor may take to exit once it
+// got the summary
+const processorTimeout = 10 * time.Minute
+
+// processorLogFile logs the output of exec processors in the log directory
+const processorLogFile = "processors.log"
+
+// execProcessor runs a command with sh -c and writes the records of the run
+// to its stdin
+type execProcessor struct {
+	ndjsonProcessor
+	command string
+	cmd     *exec.Cmd
+	stdin   io.WriteCloser
+	log     *os.File
+}
+
+func newExecProcessor(command string) (ResultProcessor, error) {
+	if command == "" {
+		return nil, errors.New("no command given, expected exec:COMMAND")
+	}
+	return &execProcessor{command: command}, nil
+}
+
+func (p *execProcessor) Start(logDir string, manifest *RunManifest) error {
+	log, err := os.OpenFile(filepath.Join(logDir, processorLogFile), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open processor log: %w", err)
+	}
+	fmt.Fprintf(log, "=== %s ===\n", p.command)
+
+	cmd := exec.Command("sh", "-c", p.command)
+	cmd.Env = append(os.Environ(), "APKREGRESS_RUN_ID="+filepath.Base(logDir), "APKREGRESS_LOG_DIR="+logDir)
+	cmd.Stdout = log
+	cmd.Stderr = log
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		log.Close()
+		return fmt.Errorf("failed to start %q: %w", p.command, err)
+	}
+	if err := startInProcessGroup(cmd); err != nil {
+		log.Close()
+		return fmt.Errorf("failed to start %q: %w", p.command, err)
+	}
+	p.cmd, p.stdin, p.log = cmd, stdin, log
+	p.encoder = json.NewEncoder(stdin)
+
+	if err := p.encoder.Encode(processorRecord{Type: processorRecordStart, Manifest: manifest}); err != nil {
+		p.stop()
+		return fmt.Errorf("%q: %w", p.command, err)
+	}
+	return nil
+}
+
+func (p *execProcessor) Process(result TestResult) error {
+	if err := p.ndjsonProcessor.Process(result); err != nil {
+		p.stop()
+		return fmt.Errorf("%q: %w, see %s", p.command, err, p.log.Name())
+	}
+	return nil
+}
+
+func (p *execProcessor) Finish(summary SummaryReport) error {
+	if err := p.encoder.Encode(processorRecord{Type: processorRecordSummary, Summary: &summary}); err != nil {
+		p.stop()
+		return fmt.Errorf("%q: %w, see %s", p.command, err, p.log.Name())
+	}
+	defer p.log.Close()
+	p.stdin.Close()
+	if err := waitWithTimeout(context.Background(), p.cmd, processorTimeout); err != nil {
+		if errors.Is(err, ErrTestHung) {
+			return fmt.Errorf("%q killed after %v, see %s", p.command, processorTimeout, p.log.Name())
+		}
+		return fmt.Errorf("%q: %w, see %s", p.command, err, p.log.Name())
+	}
+	return nil
+}
+
+// stop stops a processor that can't take more records
+func (p *execProcessor) stop() {
+	p.stdin.Close()
+	killProcessGroup(p.cmd)
+	p.cmd.Wait()
+	p.log.Close()
+}
+
+// fileProcessor appends the records of each run to a file, e.g. one a data
+// lake ingests
+type fileProcessor struct {
+	ndjsonProcessor
+	path string
+	file *os.File
+}
+
+func newFileProcessor(path string) (ResultProcessor, error) {
+	if path == "" {
+		return nil, errors.New("no file given, expected file:PATH")
+	}
+	return &fileProcessor{path: path}, nil
+}
+
+func (p *fileProcessor) Start(logDir string, manifest *RunManifest) error {
+	file, err := os.OpenFile(p.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", p.path, err)
+	}
+	p.file = file
+	p.encoder = json.NewEncoder(file)
+	if err := p.encoder.Encode(processorRecord{Type: processorRecordStart, Manifest: manifest}); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write %s: %w", p.path, err)
+	}
+	return nil
+}
+
+func (p *fileProcessor) Finish(summary SummaryReport) error {
+	if err := p.encoder.Encode(processorRecord{Type: processorRecordSummary, Summary: &summary}); err != nil {
+		p.file.Close()
+		return fmt.Errorf("failed to write %s: %w", p.path, err)
+	}
+	return p.file.Close()
+}
@@ -0,0 +1,154 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// recordingProcessor records what it gets
+type recordingProcessor struct {
+	started  bool
+	packages []string
+	summary  *SummaryReport
+	failAt   int
+}
+
+func (p *recordingProcessor) Start(logDir string, manifest *RunManifest) error {
+	p.started = true
+	return nil
+}
+
+func (p *recordingProcessor) Process(result TestResult) error {
+	if p.failAt > 0 && len(p.packages)+1 == p.failAt {
+		return errors.New("sink unavailable")
+	}
+	p.packages = append(p.packages, result.Package)
+	return nil
+}
+
+func (p *recordingProcessor) Finish(summary SummaryReport) error {
+	p.summary = &summary
+	return nil
+}
+
+func TestNewResultProcessor(t *testing.T) {
+	recorder := &recordingProcessor{}
+	RegisterResultProcessor("test-recorder", func(config string) (ResultProcessor, error) {
+		if config != "x" {
+			return nil, errors.New("expected x")
+		}
+		return recorder, nil
+	})
+
+	tests := []struct {
+		spec    string
+		wantErr string
+	}{
+		{"test-recorder:x", ""},
+		{"test-recorder:y", "result processor test-recorder: expected x"},
+		{"exec:cat", ""},
+		{"exec", "no command given"},
+		{"file:/tmp/results.ndjson", ""},
+		{"dashboard", `unknown result processor "dashboard"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			processor, err := NewResultProcessor(tt.spec)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil || processor == nil {
+				t.Errorf("Expected a processor, got %v", err)
+			}
+		})
+	}
+}
+
+func newProcessorTestRunner(t *testing.T, packages []string) *RegressionTestRunner {
+	t.Helper()
+	repoPath := t.TempDir()
+	for _, pkg := range packages {
+		if err := os.WriteFile(filepath.Join(repoPath, pkg+".yaml"), []byte("package:\n  name: "+pkg+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	runner := NewRegressionTestRunner("", "https://example.com/repo", repoPath, "wolfi", 1, false, time.Minute, false)
+	runner.setLogDir(t.TempDir())
+	runner.SetExecutor(TestExecutorFunc(func(ctx context.Context, pkg string, opts ExecuteOptions) TestResult {
+		return newTestResult(pkg, opts.WithRepo, time.Now(), "", nil)
+	}))
+	return runner
+}
+
+func TestRunnerResultProcessors(t *testing.T) {
+	runner := newProcessorTestRunner(t, []string{"a", "b", "c"})
+	recorder := &recordingProcessor{}
+	failing := &recordingProcessor{failAt: 2}
+	runner.AddResultProcessor(recorder)
+	runner.AddResultProcessor(failing)
+
+	if err := runner.RunFromPackageList([]string{"a", "b", "c"}); err != nil {
+		t.Fatalf("Expected a failing processor not to fail the run, got %v", err)
+	}
+	if !recorder.started || !reflect.DeepEqual(recorder.packages, []string{"a", "b", "c"}) {
+		t.Errorf("Expected every result, got %v", recorder.packages)
+	}
+	if recorder.summary == nil || len(recorder.summary.Successful) != 3 {
+		t.Errorf("Expected the summary, got %+v", recorder.summary)
+	}
+	if !reflect.DeepEqual(failing.packages, []string{"a"}) || failing.summary != nil {
+		t.Errorf("Expected a failed processor to get nothing more, got %v and %+v", failing.packages, failing.summary)
+	}
+}
+
+func TestExecResultProcessor(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "stream.ndjson")
+	runner := newProcessorTestRunner(t, []string{"a", "b"})
+	processor, err := NewResultProcessor(`exec:cat > ` + out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	runner.AddResultProcessor(processor)
+
+	if err := runner.RunFromPackageList([]string{"a", "b"}); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := os.Open(out)
+	if err != nil {
+		t.Fatalf("Expected the command to get the records: %v", err)
+	}
+	defer file.Close()
+
+	var types []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var record struct {
+			Type   string          `json:"type"`
+			Result json.RawMessage `json:"result"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatalf("Expected a JSON object per line, got %q: %v", scanner.Text(), err)
+		}
+		types = append(types, record.Type)
+	}
+	expected := []string{processorRecordStart, processorRecordResult, processorRecordResult, processorRecordSummary}
+	if !reflect.DeepEqual(types, expected) {
+		t.Errorf("Expected records %v, got %v", expected, types)
+	}
+}
@@ -0,0 +1,99 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ErrUnknownProfile indicates that the config file has no profile of the
+// name given
+var ErrUnknownProfile = errors.New("unknown profile")
+
+// configFile is the format of the apkregress config file. Profiles bundle
+// flag values under a name, so that a team runs e.g. its nightly tests the
+// same way:
+//
+//	profiles:
+//	  quick:
+//	    concurrency: 8
+//	    hang-timeout: 10m
+//	    two-phase: true
+//	  enterprise-nightly:
+//	    repo-type: enterprise
+//	    summary-json: true
+//	    exclude: [llvm-*, gcc]
+type configFile struct {
+	Profiles map[string]map[string]yaml.Node `yaml:"profiles"`
+	// Matrices are the test matrices of --matrix (see LoadMatrix)
+	Matrices map[string]yaml.Node `yaml:"matrices"`
+	// RepoTypes are repository types besides the built-in ones (see
+	// RepoType)
+	RepoTypes map[string]RepoType `yaml:"repo-types"`
+	// Owners and FailureBudgets are the release policies of
+	// --failure-budget (see FailureBudget)
+	Owners         map[string][]string     `yaml:"owners"`
+	FailureBudgets map[string][]BudgetRule `yaml:"failure-budgets"`
+}
+
+// DefaultConfigPath returns the path of the config file in the user's config
+// directory
+func DefaultConfigPath() (string, error) {
+	userConfigDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to determine config directory: %w", err)
+	}
+	return filepath.Join(userConfigDir, "apkregress", "config.yaml"), nil
+}
+
+// LoadProfile reads the named profile from the config file at path. It
+// returns the values of each flag the profile sets; lists set a flag once
+// per item.
+func LoadProfile(path, name string) (map[string][]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var file configFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	profile, ok := file.Profiles[name]
+	if !ok {
+		names := make([]string, 0, len(file.Profiles))
+		for name := range file.Profiles {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("%w %q in %s, expected one of: %s", ErrUnknownProfile, name, path, strings.Join(names, ", "))
+	}
+
+	values := make(map[string][]string, len(profile))
+	for flag, node := range profile {
+		switch node.Kind {
+		case yaml.ScalarNode:
+			values[flag] = []string{node.Value}
+		case yaml.SequenceNode:
+			items := make([]string, 0, len(node.Content))
+			for _, item := range node.Content {
+				if item.Kind != yaml.ScalarNode {
+					return nil, fmt.Errorf("profile %s in %s: %s must be a value or a list of values", name, path, flag)
+				}
+				items = append(items, item.Value)
+			}
+			values[flag] = items
+		default:
+			return nil, fmt.Errorf("profile %s in %s: %s must be a value or a list of values", name, path, flag)
+		}
+	}
+	return values, nil
+}
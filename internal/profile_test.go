@@ -0,0 +1,68 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadProfile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	config := `profiles:
+  quick:
+    concurrency: 8
+    hang-timeout: 10m
+    two-phase: true
+  nightly:
+    repo-type: enterprise
+    exclude: [llvm-*, gcc]
+  broken:
+    exclude:
+      name: gcc
+`
+	if err := os.WriteFile(path, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		expected map[string][]string
+		wantErr  string
+	}{
+		{"quick", map[string][]string{"concurrency": {"8"}, "hang-timeout": {"10m"}, "two-phase": {"true"}}, ""},
+		{"nightly", map[string][]string{"repo-type": {"enterprise"}, "exclude": {"llvm-*", "gcc"}}, ""},
+		{"broken", nil, "exclude must be a value or a list of values"},
+		{"full", nil, "expected one of: broken, nightly, quick"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values, err := LoadProfile(path, tt.name)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(values, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, values)
+			}
+		})
+	}
+
+	if _, err := LoadProfile(path, "full"); !errors.Is(err, ErrUnknownProfile) {
+		t.Errorf("Expected ErrUnknownProfile, got %v", err)
+	}
+	if _, err := LoadProfile(filepath.Join(t.TempDir(), "missing.yaml"), "quick"); err == nil {
+		t.Error("Expected an error for a missing config file")
+	}
+}
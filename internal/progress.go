@@ -0,0 +1,142 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// ProgressTracker counts the packages of a run as their tests complete, by
+// outcome. It is safe for concurrent use, so that workers update it while
+// reporters such as the progress line, heartbeats or a TUI read it. The zero
+// value is ready to use.
+type ProgressTracker struct {
+	completed atomic.Int64
+	total     atomic.Int64
+
+	passed    atomic.Int64
+	failed    atomic.Int64
+	regressed atomic.Int64
+	hung      atomic.Int64
+	skipped   atomic.Int64
+	infra     atomic.Int64
+}
+
+// Progress is a snapshot of a ProgressTracker
+type Progress struct {
+	Completed int64 `json:"completed"`
+	Total     int64 `json:"total"`
+	Passed    int64 `json:"passed"`
+	Failed    int64 `json:"failed"`
+	Regressed int64 `json:"regressed"`
+	Hung      int64 `json:"hung"`
+	Skipped   int64 `json:"skipped"`
+	// Infra counts the packages whose tests failed on the infrastructure
+	Infra int64 `json:"infra"`
+}
+
+// Progress returns the progress of the run, for reporters to read
+func (r *RegressionTestRunner) Progress() *ProgressTracker {
+	return &r.progress
+}
+
+// SetTotal sets the number of packages of the run
+func (p *ProgressTracker) SetTotal(total int64) {
+	p.total.Store(total)
+}
+
+// AddTotal changes the number of packages of the run by delta, as packages
+// are added or cancelled while it is in progress
+func (p *ProgressTracker) AddTotal(delta int64) {
+	p.total.Add(delta)
+}
+
+// Complete counts a package whose tests finished. It returns the number of
+// completed packages and the total, and false if all packages were already
+// counted, in which case the count is unchanged. The count is claimed with
+// a single atomic add and given back if it overshot the total, so that
+// concurrent calls can't both pass a check made before incrementing.
+func (p *ProgressTracker) Complete() (int64, int64, bool) {
+	completed := p.completed.Add(1)
+	total := p.total.Load()
+	if completed > total {
+		p.completed.Add(-1)
+		return total, total, false
+	}
+	return completed, total, true
+}
+
+// Count records the outcome of a package's tests with the candidate
+// repository and, if it ran, without it
+func (p *ProgressTracker) Count(withRepo TestResult, withoutRepo *TestResult) {
+	switch packageStatus(withRepo, withoutRepo) {
+	case StatusInfra:
+		p.infra.Add(1)
+	case StatusHung:
+		p.hung.Add(1)
+	case StatusSkipped:
+		p.skipped.Add(1)
+	case StatusPass:
+		p.passed.Add(1)
+	case StatusRegression:
+		p.regressed.Add(1)
+	default:
+		p.failed.Add(1)
+	}
+}
+
+// packageStatus returns the outcome of a package's tests with the candidate
+// repository and, if it ran, without it, as recorded in the results
+// database
+func packageStatus(withRepo TestResult, withoutRepo *TestResult) string {
+	switch {
+	case withRepo.Infra != "" || (withoutRepo != nil && withoutRepo.Infra != ""):
+		return StatusInfra
+	case withRepo.Hung || (withoutRepo != nil && withoutRepo.Hung):
+		return StatusHung
+	case withRepo.Skipped:
+		return StatusSkipped
+	case withRepo.Success:
+		return StatusPass
+	case withoutRepo != nil && withoutRepo.Success:
+		return StatusRegression
+	default:
+		return StatusFail
+	}
+}
+
+// Snapshot returns the current counts. A completed count briefly past the
+// total while a call to Complete gives it back is reported as the total.
+func (p *ProgressTracker) Snapshot() Progress {
+	total := p.total.Load()
+	return Progress{
+		Completed: min(p.completed.Load(), total),
+		Total:     total,
+		Passed:    p.passed.Load(),
+		Failed:    p.failed.Load(),
+		Regressed: p.regressed.Load(),
+		Hung:      p.hung.Load(),
+		Skipped:   p.skipped.Load(),
+		Infra:     p.infra.Load(),
+	}
+}
+
+// Percent is the share of completed packages
+func (p Progress) Percent() float64 {
+	if p.Total <= 0 {
+		return 0
+	}
+	return float64(p.Completed) / float64(p.Total) * 100
+}
+
+// Counts formats the per-status counts, e.g. "✅ 40 ❌ 2 🔴 1 ⏰ 0 ⏭️ 3",
+// followed by the infrastructure failures, if any
+func (p Progress) Counts() string {
+	counts := fmt.Sprintf("✅ %d ❌ %d 🔴 %d ⏰ %d ⏭️ %d", p.Passed, p.Failed, p.Regressed, p.Hung, p.Skipped)
+	if p.Infra > 0 {
+		counts += fmt.Sprintf(" 🛠️ %d", p.Infra)
+	}
+	return counts
+}
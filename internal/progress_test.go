@@ -0,0 +1,137 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestProgressTrackerConcurrentComplete(t *testing.T) {
+	var p ProgressTracker
+	p.SetTotal(50)
+
+	var ok atomic.Int64
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			completed, total, counted := p.Complete()
+			if completed > total {
+				t.Errorf("Expected completed to stay within %d, got %d", total, completed)
+			}
+			if counted {
+				ok.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if ok.Load() != 50 {
+		t.Errorf("Expected 50 counted completions, got %d", ok.Load())
+	}
+	if completed := p.Snapshot().Completed; completed != 50 {
+		t.Errorf("Expected 50 completed, got %d", completed)
+	}
+}
+
+func TestProgressTrackerConcurrentUpdates(t *testing.T) {
+	var p ProgressTracker
+
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			p.AddTotal(1)
+			p.Count(TestResult{Success: i%2 == 0}, nil)
+			p.Complete()
+			// Reporters read while workers write
+			p.Snapshot().Counts()
+		}(i)
+	}
+	wg.Wait()
+
+	got := p.Snapshot()
+	if got.Total != 100 {
+		t.Errorf("Expected total 100, got %d", got.Total)
+	}
+	if got.Completed != 100 {
+		t.Errorf("Expected 100 completed, got %d", got.Completed)
+	}
+	if got.Passed != 50 || got.Failed != 50 {
+		t.Errorf("Expected 50 passed and 50 failed, got %d and %d", got.Passed, got.Failed)
+	}
+	if got.Percent() != 100 {
+		t.Errorf("Expected 100%%, got %v", got.Percent())
+	}
+}
+
+func TestProgressSnapshot(t *testing.T) {
+	tests := []struct {
+		name      string
+		completed int64
+		total     int64
+		want      int64
+		percent   float64
+	}{
+		{"no packages", 0, 0, 0, 0},
+		{"half done", 5, 10, 5, 50},
+		{"done", 10, 10, 10, 100},
+		{"overshoot being given back", 11, 10, 10, 100},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var p ProgressTracker
+			p.SetTotal(tt.total)
+			p.completed.Store(tt.completed)
+
+			got := p.Snapshot()
+			if got.Completed != tt.want {
+				t.Errorf("Expected %d completed, got %d", tt.want, got.Completed)
+			}
+			if got.Percent() != tt.percent {
+				t.Errorf("Expected %v%%, got %v", tt.percent, got.Percent())
+			}
+		})
+	}
+}
+
+func TestRunnerProgressConcurrentWorkers(t *testing.T) {
+	repoPath := t.TempDir()
+	var packages []string
+	for i := 0; i < 40; i++ {
+		pkg := fmt.Sprintf("pkg-%d", i)
+		packages = append(packages, pkg)
+		if err := os.WriteFile(filepath.Join(repoPath, pkg+".yaml"), []byte("package:\n  name: "+pkg+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	runner := NewRegressionTestRunner("openssl", "https://example.com/repo", repoPath, "wolfi", 16, false, time.Minute, false)
+	runner.setLogDir(t.TempDir())
+	runner.SetExecutor(TestExecutorFunc(func(ctx context.Context, pkg string, opts ExecuteOptions) TestResult {
+		return newTestResult(pkg, opts.WithRepo, time.Now(), "", nil)
+	}))
+
+	if err := runner.RunFromPackageList(packages); err != nil {
+		t.Fatal(err)
+	}
+
+	got := runner.Progress().Snapshot()
+	if got.Completed != int64(len(packages)) || got.Total != int64(len(packages)) {
+		t.Errorf("Expected %d of %d completed, got %d of %d", len(packages), len(packages), got.Completed, got.Total)
+	}
+	if got.Passed != int64(len(packages)) {
+		t.Errorf("Expected %d passed, got %d", len(packages), got.Passed)
+	}
+}
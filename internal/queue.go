@@ -0,0 +1,199 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// JobStatus is the state of a queued regression job
+type JobStatus string
+
+const (
+	JobQueued    JobStatus = "queued"
+	JobRunning   JobStatus = "running"
+	JobSucceeded JobStatus = "succeeded"
+	JobFailed    JobStatus = "failed"
+)
+
+// Job is a regression run submitted to the daemon. Args are apkregress
+// command line arguments, run in WorkDir so relative paths resolve as they
+// would have for the submitter.
+type Job struct {
+	ID          string    `json:"id"`
+	Args        []string  `json:"args"`
+	WorkDir     string    `json:"workDir"`
+	Status      JobStatus `json:"status"`
+	SubmittedAt time.Time `json:"submittedAt"`
+	StartedAt   time.Time `json:"startedAt"`
+	FinishedAt  time.Time `json:"finishedAt"`
+	ExitCode    int       `json:"exitCode"`
+	Error       string    `json:"error,omitempty"`
+	LogPath     string    `json:"logPath"`
+}
+
+// ErrJobNotFound indicates that no job with the requested ID exists
+var ErrJobNotFound = errors.New("job not found")
+
+// JobQueue is a persistent queue of jobs, stored as one JSON file per job so
+// that submitting from another process and restarting the daemon are safe.
+type JobQueue struct {
+	dir string
+	mu  sync.Mutex
+}
+
+// NewJobQueue opens the queue in dir. An empty dir selects queue/ in the
+// user's cache directory.
+func NewJobQueue(dir string) (*JobQueue, error) {
+	if dir == "" {
+		userCacheDir, err := os.UserCacheDir()
+		if err != nil {
+			return nil, fmt.Errorf("failed to determine cache directory: %w", err)
+		}
+		dir = filepath.Join(userCacheDir, "apkregress", "queue")
+	}
+
+	if err := os.MkdirAll(filepath.Join(dir, "jobs"), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create queue directory: %w", err)
+	}
+	return &JobQueue{dir: dir}, nil
+}
+
+func (q *JobQueue) jobPath(id string) string {
+	return filepath.Join(q.dir, "jobs", id+".json")
+}
+
+// Submit adds a job running args in workDir to the queue
+func (q *JobQueue) Submit(args []string, workDir string) (*Job, error) {
+	if len(args) == 0 {
+		return nil, errors.New("job has no arguments")
+	}
+
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return nil, err
+	}
+	id := fmt.Sprintf("%s-%s", time.Now().Format("20060102-150405"), hex.EncodeToString(suffix))
+
+	job := &Job{
+		ID:          id,
+		Args:        args,
+		WorkDir:     workDir,
+		Status:      JobQueued,
+		SubmittedAt: time.Now(),
+		LogPath:     filepath.Join(q.dir, "jobs", id+".log"),
+	}
+	if err := q.Save(job); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// Save writes a job's current state
+func (q *JobQueue) Save(job *Job) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	data, err := json.MarshalIndent(job, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so readers never see a partial job
+	tmpFile, err := os.CreateTemp(filepath.Join(q.dir, "jobs"), "job-*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to save job %s: %w", job.ID, err)
+	}
+	defer os.Remove(tmpFile.Name())
+
+	if _, err := tmpFile.Write(append(data, '\n')); err != nil {
+		tmpFile.Close()
+		return fmt.Errorf("failed to save job %s: %w", job.ID, err)
+	}
+	if err := tmpFile.Close(); err != nil {
+		return fmt.Errorf("failed to save job %s: %w", job.ID, err)
+	}
+
+	return os.Rename(tmpFile.Name(), q.jobPath(job.ID))
+}
+
+// Get returns the job with the given ID
+func (q *JobQueue) Get(id string) (*Job, error) {
+	if id == "" || strings.ContainsAny(id, `/\`) {
+		return nil, ErrJobNotFound
+	}
+
+	data, err := os.ReadFile(q.jobPath(id))
+	if os.IsNotExist(err) {
+		return nil, ErrJobNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var job Job
+	if err := json.Unmarshal(data, &job); err != nil {
+		return nil, fmt.Errorf("failed to parse job %s: %w", id, err)
+	}
+	return &job, nil
+}
+
+// Jobs returns all jobs, oldest first
+func (q *JobQueue) Jobs() ([]*Job, error) {
+	matches, err := filepath.Glob(filepath.Join(q.dir, "jobs", "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	var jobs []*Job
+	for _, match := range matches {
+		job, err := q.Get(strings.TrimSuffix(filepath.Base(match), ".json"))
+		if err != nil {
+			// Skip jobs that were removed or are unreadable
+			continue
+		}
+		jobs = append(jobs, job)
+	}
+
+	sort.Slice(jobs, func(i, j int) bool {
+		if !jobs[i].SubmittedAt.Equal(jobs[j].SubmittedAt) {
+			return jobs[i].SubmittedAt.Before(jobs[j].SubmittedAt)
+		}
+		return jobs[i].ID < jobs[j].ID
+	})
+	return jobs, nil
+}
+
+// Recover requeues jobs that were running when the daemon stopped, so they
+// are run again after a restart
+func (q *JobQueue) Recover() ([]*Job, error) {
+	jobs, err := q.Jobs()
+	if err != nil {
+		return nil, err
+	}
+
+	var recovered []*Job
+	for _, job := range jobs {
+		if job.Status != JobRunning {
+			continue
+		}
+		job.Status = JobQueued
+		job.StartedAt = time.Time{}
+		if err := q.Save(job); err != nil {
+			return nil, err
+		}
+		recovered = append(recovered, job)
+	}
+	return recovered, nil
+}
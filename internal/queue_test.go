@@ -0,0 +1,84 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestJobQueueSubmitAndGet(t *testing.T) {
+	queue, err := NewJobQueue(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+
+	args := []string{"--package", "openssl", "--repo", "./packages"}
+	job, err := queue.Submit(args, "/work")
+	if err != nil {
+		t.Fatalf("Failed to submit job: %v", err)
+	}
+	if job.Status != JobQueued {
+		t.Errorf("Expected status %s, got %s", JobQueued, job.Status)
+	}
+	if filepath.Base(job.LogPath) != job.ID+".log" {
+		t.Errorf("Unexpected log path: %s", job.LogPath)
+	}
+
+	loaded, err := queue.Get(job.ID)
+	if err != nil {
+		t.Fatalf("Failed to get job: %v", err)
+	}
+	if !reflect.DeepEqual(loaded.Args, args) || loaded.WorkDir != "/work" {
+		t.Errorf("Unexpected job: %+v", loaded)
+	}
+
+	if _, err := queue.Submit(nil, "/work"); err == nil {
+		t.Error("Expected error submitting a job without arguments")
+	}
+
+	for _, id := range []string{"missing", "../jobs/" + job.ID, ""} {
+		if _, err := queue.Get(id); !errors.Is(err, ErrJobNotFound) {
+			t.Errorf("Expected ErrJobNotFound for %q, got %v", id, err)
+		}
+	}
+}
+
+func TestJobQueueRecover(t *testing.T) {
+	dir := t.TempDir()
+	queue, err := NewJobQueue(dir)
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+
+	first, _ := queue.Submit([]string{"--package", "curl"}, "/work")
+	second, _ := queue.Submit([]string{"--package", "git"}, "/work")
+	first.Status = JobRunning
+	second.Status = JobSucceeded
+	queue.Save(first)
+	queue.Save(second)
+
+	// A restarted daemon opens the same directory
+	reopened, err := NewJobQueue(dir)
+	if err != nil {
+		t.Fatalf("Failed to reopen queue: %v", err)
+	}
+	recovered, err := reopened.Recover()
+	if err != nil {
+		t.Fatalf("Failed to recover: %v", err)
+	}
+	if len(recovered) != 1 || recovered[0].ID != first.ID {
+		t.Fatalf("Expected %s to be recovered, got %v", first.ID, recovered)
+	}
+
+	jobs, err := reopened.Jobs()
+	if err != nil {
+		t.Fatalf("Failed to list jobs: %v", err)
+	}
+	if len(jobs) != 2 || jobs[0].ID != first.ID || jobs[0].Status != JobQueued || jobs[1].Status != JobSucceeded {
+		t.Errorf("Unexpected jobs after recovery: %+v, %+v", jobs[0], jobs[1])
+	}
+}
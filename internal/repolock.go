@@ -0,0 +1,131 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// RepoLockFile is the lock file a run holds in each package repository it
+// runs make targets in
+const RepoLockFile = ".apkregress.lock"
+
+// RepoLock is an advisory lock on a package repository, so that two runs
+// don't build in the same checkout at once and overwrite each other's
+// outputs
+type RepoLock struct {
+	path string
+	file *os.File
+}
+
+// lockOwner is written to the lock file to tell other runs who holds it
+type lockOwner struct {
+	PID     int       `json:"pid"`
+	Host    string    `json:"host"`
+	Started time.Time `json:"started"`
+	Command string    `json:"command"`
+}
+
+// String describes the owner for the error of a run that found the lock held
+func (o lockOwner) String() string {
+	if o.PID == 0 {
+		return "another run"
+	}
+	return fmt.Sprintf("pid %d on %s since %s (%s)", o.PID, o.Host, o.Started.Local().Format(time.DateTime), o.Command)
+}
+
+// LockRepoPath locks the package repository at repoPath, failing with
+// ErrRepoLocked if another run holds the lock. The lock is released when
+// the process exits, so a lock file left behind by a killed run doesn't
+// block later ones.
+func LockRepoPath(repoPath string) (*RepoLock, error) {
+	path := filepath.Join(repoPath, RepoLockFile)
+	for {
+		file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create lock file: %w", err)
+		}
+		locked, err := tryLockFile(file)
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("failed to lock %s: %w", path, err)
+		}
+		if !locked {
+			owner := readLockOwner(file)
+			file.Close()
+			return nil, fmt.Errorf("%w: %s is in use by %s", ErrRepoLocked, repoPath, owner)
+		}
+
+		// Unlock removes the file, so the lock is only ours if the run
+		// that held it didn't remove it between our open and lock
+		if sameFile(file, path) {
+			lock := &RepoLock{path: path, file: file}
+			if err := lock.writeOwner(); err != nil {
+				lock.Unlock()
+				return nil, err
+			}
+			return lock, nil
+		}
+		file.Close()
+	}
+}
+
+// Unlock releases the lock and removes the lock file
+func (l *RepoLock) Unlock() error {
+	// Removed while still locked, so a run waiting on this file retries
+	// with a new one
+	os.Remove(l.path)
+	return l.file.Close()
+}
+
+// writeOwner records this process as the lock's owner
+func (l *RepoLock) writeOwner() error {
+	host, _ := os.Hostname()
+	data, err := json.Marshal(lockOwner{
+		PID:     os.Getpid(),
+		Host:    host,
+		Started: time.Now(),
+		Command: strings.Join(os.Args, " "),
+	})
+	if err != nil {
+		return err
+	}
+	if err := l.file.Truncate(0); err != nil {
+		return fmt.Errorf("failed to write lock file: %w", err)
+	}
+	if _, err := l.file.WriteAt(append(data, '\n'), 0); err != nil {
+		return fmt.Errorf("failed to write lock file: %w", err)
+	}
+	return nil
+}
+
+// readLockOwner reads the owner of a held lock, or the zero owner if it
+// hasn't written itself yet
+func readLockOwner(file *os.File) lockOwner {
+	var owner lockOwner
+	data, err := io.ReadAll(file)
+	if err == nil {
+		json.Unmarshal(data, &owner)
+	}
+	return owner
+}
+
+// sameFile reports whether file is still the file at path
+func sameFile(file *os.File, path string) bool {
+	opened, err := file.Stat()
+	if err != nil {
+		return false
+	}
+	current, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+	return os.SameFile(opened, current)
+}
@@ -0,0 +1,66 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLockRepoPath(t *testing.T) {
+	repoPath := t.TempDir()
+
+	lock, err := LockRepoPath(repoPath)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	_, err = LockRepoPath(repoPath)
+	if !errors.Is(err, ErrRepoLocked) {
+		t.Fatalf("Expected ErrRepoLocked, got %v", err)
+	}
+	if expected := fmt.Sprintf("pid %d", os.Getpid()); !strings.Contains(err.Error(), expected) {
+		t.Errorf("Expected the error to name the owner (%s), got %v", expected, err)
+	}
+
+	if err := lock.Unlock(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(repoPath, RepoLockFile)); !os.IsNotExist(err) {
+		t.Errorf("Expected the lock file to be removed, got %v", err)
+	}
+
+	lock, err = LockRepoPath(repoPath)
+	if err != nil {
+		t.Fatalf("Expected the path to be lockable again, got %v", err)
+	}
+	lock.Unlock()
+}
+
+func TestLockRepoPathStaleFile(t *testing.T) {
+	repoPath := t.TempDir()
+	// Left behind by a killed run, whose lock the kernel released
+	stale := `{"pid":1,"host":"ci","command":"apkregress"}`
+	if err := os.WriteFile(filepath.Join(repoPath, RepoLockFile), []byte(stale), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	lock, err := LockRepoPath(repoPath)
+	if err != nil {
+		t.Fatalf("Expected a stale lock file not to block, got %v", err)
+	}
+	defer lock.Unlock()
+
+	data, err := os.ReadFile(filepath.Join(repoPath, RepoLockFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), `"host":"ci"`) {
+		t.Errorf("Expected the stale owner to be replaced, got %s", data)
+	}
+}
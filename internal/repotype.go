@@ -0,0 +1,199 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Authentication providers of repository types
+const (
+	// RepoAuthNone fetches the index anonymously, or with the credentials
+	// file's credentials for its host
+	RepoAuthNone = "none"
+	// RepoAuthChainctl authenticates with a chainctl token
+	RepoAuthChainctl = "chainctl"
+)
+
+// RepoType is a repository type: where its package index is served and how
+// to authenticate to it. Besides the built-in types, types can be defined in
+// the config file, e.g. for a new catalog or a private mirror:
+//
+//	repo-types:
+//	  internal:
+//	    index: https://apk.example.com/os/{arch}/APKINDEX.tar.gz
+//	    auth: chainctl
+//	    audience: apk.example.com
+type RepoType struct {
+	Name string `yaml:"-"`
+	// Index is the URL of the package index, with {arch} standing for the
+	// architecture, e.g. x86_64
+	Index string `yaml:"index"`
+	// Auth is the authentication provider, RepoAuthNone (the default) or
+	// RepoAuthChainctl
+	Auth string `yaml:"auth"`
+	// Audience is the audience of chainctl tokens, by default the host of
+	// Index
+	Audience string `yaml:"audience"`
+	// Advisories is the URL of the security feed the fixes of a version
+	// change are read from, if there is one
+	Advisories string `yaml:"advisories"`
+	// Keys are the keys (files or URLs) the index is signed with, for
+	// --verify-index
+	Keys []string `yaml:"keys"`
+}
+
+// builtinRepoTypes are the Chainguard repository types
+var builtinRepoTypes = []RepoType{
+	{
+		Name:       "wolfi",
+		Index:      "https://packages.wolfi.dev/os/{arch}/APKINDEX.tar.gz",
+		Auth:       RepoAuthNone,
+		Advisories: "https://packages.wolfi.dev/os/security.json",
+		Keys:       []string{"https://packages.wolfi.dev/os/wolfi-signing.rsa.pub"},
+	},
+	{
+		Name:       "enterprise",
+		Index:      "https://apk.cgr.dev/chainguard-private/{arch}/APKINDEX.tar.gz",
+		Auth:       RepoAuthChainctl,
+		Advisories: "https://packages.cgr.dev/chainguard/security.json",
+	},
+	{
+		Name:       "extras",
+		Index:      "https://apk.cgr.dev/extra-packages/{arch}/APKINDEX.tar.gz",
+		Auth:       RepoAuthChainctl,
+		Advisories: "https://packages.cgr.dev/extras/security.json",
+	},
+}
+
+// customRepoTypes are the repository types defined in the config file (see
+// RegisterRepoTypes)
+var customRepoTypes map[string]RepoType
+
+// LookupRepoType returns the repository type of the given name; "" is wolfi
+func LookupRepoType(name string) (RepoType, bool) {
+	if name == "" {
+		name = "wolfi"
+	}
+	for _, t := range builtinRepoTypes {
+		if t.Name == name {
+			return t, true
+		}
+	}
+	t, ok := customRepoTypes[name]
+	return t, ok
+}
+
+// repoTypeOrDefault returns the repository type of the given name, or wolfi
+// if there is none of that name
+func repoTypeOrDefault(name string) RepoType {
+	if t, ok := LookupRepoType(name); ok {
+		return t
+	}
+	return builtinRepoTypes[0]
+}
+
+// RepoTypeNames returns the names of the repository types, the built-in
+// ones first
+func RepoTypeNames() []string {
+	names := make([]string, 0, len(builtinRepoTypes)+len(customRepoTypes))
+	for _, t := range builtinRepoTypes {
+		names = append(names, t.Name)
+	}
+	return append(names, sortedKeys(customRepoTypes)...)
+}
+
+// ValidateRepoType checks that a repository type of the given name exists
+func ValidateRepoType(name string) error {
+	if _, ok := LookupRepoType(name); ok {
+		return nil
+	}
+	names := RepoTypeNames()
+	return fmt.Errorf("invalid repository type: %s (must be %s, or %s)", name, strings.Join(names[:len(names)-1], ", "), names[len(names)-1])
+}
+
+// indexURL returns the index URL of the repository type for arch
+func (t RepoType) indexURL(arch string) string {
+	return strings.ReplaceAll(t.Index, "{arch}", arch)
+}
+
+// audience returns the audience of the chainctl tokens of the repository
+// type
+func (t RepoType) audience() string {
+	if t.Audience != "" {
+		return t.Audience
+	}
+	return urlHost(t.Index)
+}
+
+// LoadRepoTypes reads the repository types defined in the config file at
+// path. A missing file defines none.
+func LoadRepoTypes(path string) (map[string]RepoType, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var file configFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	for name, t := range file.RepoTypes {
+		t.Name = name
+		file.RepoTypes[name] = t
+	}
+	return file.RepoTypes, nil
+}
+
+// RegisterRepoTypes adds repository types to the built-in ones, e.g. those
+// of the config file. The built-in types can't be redefined; --mirror
+// serves them from elsewhere.
+func RegisterRepoTypes(types map[string]RepoType) error {
+	registered := make(map[string]RepoType, len(types))
+	for _, name := range sortedKeys(types) {
+		t := types[name]
+		t.Name = name
+		if err := t.validate(); err != nil {
+			return fmt.Errorf("repository type %s: %w", name, err)
+		}
+		registered[name] = t
+	}
+	customRepoTypes = registered
+	return nil
+}
+
+func (t RepoType) validate() error {
+	if t.Name == RepoTypeAlpine {
+		return errors.New("the name is reserved")
+	}
+	for _, builtin := range builtinRepoTypes {
+		if t.Name == builtin.Name {
+			return errors.New("built-in types can't be redefined")
+		}
+	}
+	if strings.ContainsAny(t.Name, ", /") {
+		return errors.New("names can't contain commas, spaces or slashes")
+	}
+	if !strings.Contains(t.Index, "{arch}") {
+		return fmt.Errorf("index %q must contain {arch}", t.Index)
+	}
+	if u, err := url.Parse(t.Index); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("index %q must be an http or https URL", t.Index)
+	}
+	switch t.Auth {
+	case "", RepoAuthNone, RepoAuthChainctl:
+	default:
+		return fmt.Errorf("unknown auth %q, expected %s or %s", t.Auth, RepoAuthNone, RepoAuthChainctl)
+	}
+	return nil
+}
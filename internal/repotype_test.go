@@ -0,0 +1,104 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadRepoTypes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	config := `repo-types:
+  staging:
+    index: https://apk.example.com/staging/{arch}/APKINDEX.tar.gz
+    auth: chainctl
+    advisories: https://apk.example.com/staging/security.json
+    keys: [https://apk.example.com/staging.rsa.pub]
+  mirror:
+    index: https://mirror.example.com/os/{arch}/APKINDEX.tar.gz
+`
+	if err := os.WriteFile(path, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	types, err := LoadRepoTypes(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer RegisterRepoTypes(nil)
+	if err := RegisterRepoTypes(types); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if names := RepoTypeNames(); !reflect.DeepEqual(names, []string{"wolfi", "enterprise", "extras", "mirror", "staging"}) {
+		t.Errorf("Expected the built-in types and then the custom ones, got %v", names)
+	}
+	client := NewApkraneClient(false, "staging")
+	if url := client.getIndexURL("aarch64"); url != "https://apk.example.com/staging/aarch64/APKINDEX.tar.gz" {
+		t.Errorf("Expected the staging index for aarch64, got %s", url)
+	}
+	if !repoTypeRequiresAuth("staging") || repoTypeRequiresAuth("mirror") {
+		t.Errorf("Expected only staging to require chainctl authentication")
+	}
+	if audience := repoTypeOrDefault("staging").audience(); audience != "apk.example.com" {
+		t.Errorf("Expected the index host as the audience, got %s", audience)
+	}
+	if feed := advisoryFeedURL("mirror"); feed != "" {
+		t.Errorf("Expected no security feed for mirror, got %s", feed)
+	}
+	if keys := DefaultIndexKeys("staging"); !reflect.DeepEqual(keys, []string{"https://apk.example.com/staging.rsa.pub"}) {
+		t.Errorf("Expected the staging key, got %v", keys)
+	}
+
+	missing, err := LoadRepoTypes(filepath.Join(t.TempDir(), "missing.yaml"))
+	if err != nil || missing != nil {
+		t.Errorf("Expected a missing config file to define no types, got %v (%v)", missing, err)
+	}
+}
+
+func TestRegisterRepoTypesInvalid(t *testing.T) {
+	defer RegisterRepoTypes(nil)
+
+	tests := []struct {
+		name          string
+		repoType      RepoType
+		expectedError string
+	}{
+		{"wolfi", RepoType{Index: "https://example.com/{arch}/APKINDEX.tar.gz"}, "can't be redefined"},
+		{"alpine", RepoType{Index: "https://example.com/{arch}/APKINDEX.tar.gz"}, "reserved"},
+		{"a,b", RepoType{Index: "https://example.com/{arch}/APKINDEX.tar.gz"}, "commas"},
+		{"noarch", RepoType{Index: "https://example.com/x86_64/APKINDEX.tar.gz"}, "{arch}"},
+		{"relative", RepoType{Index: "os/{arch}/APKINDEX.tar.gz"}, "http or https"},
+		{"token", RepoType{Index: "https://example.com/{arch}/APKINDEX.tar.gz", Auth: "token"}, "unknown auth"},
+	}
+
+	for _, tt := range tests {
+		err := RegisterRepoTypes(map[string]RepoType{tt.name: tt.repoType})
+		if err == nil || !strings.Contains(err.Error(), tt.expectedError) {
+			t.Errorf("Expected an error containing %q for %s, got %v", tt.expectedError, tt.name, err)
+		}
+	}
+}
+
+func TestValidateRepoType(t *testing.T) {
+	defer RegisterRepoTypes(nil)
+	if err := RegisterRepoTypes(map[string]RepoType{"staging": {Index: "https://example.com/{arch}/APKINDEX.tar.gz"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"wolfi", "enterprise", "extras", "staging"} {
+		if err := ValidateRepoType(name); err != nil {
+			t.Errorf("Expected %s to be valid, got %v", name, err)
+		}
+	}
+	err := ValidateRepoType("invalid")
+	expected := "invalid repository type: invalid (must be wolfi, enterprise, extras, or staging)"
+	if err == nil || err.Error() != expected {
+		t.Errorf("Expected %q, got %v", expected, err)
+	}
+}
@@ -0,0 +1,166 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ResultLists are the package lists written after a run, each to a file of
+// its name, e.g. regressions.txt
+var ResultLists = []string{"successful", "failed", "regressions", "hung", "skipped"}
+
+// ResultFormats are the formats result files can be written in
+var ResultFormats = []string{"txt", "json", "csv"}
+
+// ResultFiles selects the result files written after a run. The zero value
+// writes every list as txt to the log directory.
+type ResultFiles struct {
+	// Lists are the lists written (see ResultLists), all if nil
+	Lists []string
+	// Formats are the formats each list is written in, txt if empty
+	Formats []string
+	// Dir is the directory the files are written to instead of the log
+	// directory, e.g. a fixed path downstream automation reads
+	Dir string
+}
+
+// NewResultFiles checks the lists and formats of result files; "none" as the
+// only list writes none
+func NewResultFiles(lists, formats []string, dir string) (ResultFiles, error) {
+	files := ResultFiles{Dir: dir}
+	if len(lists) == 1 && lists[0] == "none" {
+		files.Lists = []string{}
+	} else if len(lists) > 0 {
+		for _, list := range lists {
+			if !isOneOf(ResultLists, list) {
+				return ResultFiles{}, fmt.Errorf("invalid result file: %s (must be %s or none)", list, strings.Join(ResultLists, ", "))
+			}
+		}
+		files.Lists = lists
+	}
+	for _, format := range formats {
+		if !isOneOf(ResultFormats, format) {
+			return ResultFiles{}, fmt.Errorf("invalid result file format: %s (must be %s)", format, strings.Join(ResultFormats, ", "))
+		}
+	}
+	files.Formats = formats
+	return files, nil
+}
+
+// isOneOf reports whether value is one of values
+func isOneOf(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// SetResultFiles selects the result files written after the run and after
+// every chunk
+func (r *RegressionTestRunner) SetResultFiles(files ResultFiles) {
+	r.resultFiles = files
+}
+
+// resultEntry is a package in a result file, labeled with its repository
+// type or variant in a matrix
+type resultEntry struct {
+	Label   string `json:"label,omitempty"`
+	Package string `json:"package"`
+}
+
+// String is the entry in a txt file, e.g. wolfi/curl
+func (e resultEntry) String() string {
+	if e.Label != "" {
+		return e.Label + "/" + e.Package
+	}
+	return e.Package
+}
+
+// write writes the selected lists to dir, or else the log directory, in
+// the selected formats. The entries of a matrix are labeled.
+func (f ResultFiles) write(logDir string, labeled bool, lists map[string][]resultEntry) {
+	dir := f.Dir
+	if dir == "" {
+		dir = logDir
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		fmt.Printf("Warning: failed to create result directory %s: %v\n", dir, err)
+		return
+	}
+
+	selected := f.Lists
+	if selected == nil {
+		selected = ResultLists
+	}
+	formats := f.Formats
+	if len(formats) == 0 {
+		formats = []string{"txt"}
+	}
+	for _, list := range selected {
+		for _, format := range formats {
+			filename := list + "." + format
+			content, err := encodeResultFile(format, labeled, lists[list])
+			if err == nil {
+				err = os.WriteFile(filepath.Join(dir, filename), content, 0644)
+			}
+			if err != nil {
+				fmt.Printf("Warning: failed to write %s: %v\n", filename, err)
+			}
+		}
+	}
+}
+
+// encodeResultFile encodes a result file: one entry per line (txt), an
+// array of objects (json), or rows with a header (csv)
+func encodeResultFile(format string, labeled bool, entries []resultEntry) ([]byte, error) {
+	switch format {
+	case "json":
+		if entries == nil {
+			entries = []resultEntry{}
+		}
+		data, err := json.MarshalIndent(entries, "", "  ")
+		return append(data, '\n'), err
+	case "csv":
+		var buf bytes.Buffer
+		w := csv.NewWriter(&buf)
+		if labeled {
+			w.Write([]string{"label", "package"})
+		} else {
+			w.Write([]string{"package"})
+		}
+		for _, entry := range entries {
+			if labeled {
+				w.Write([]string{entry.Label, entry.Package})
+			} else {
+				w.Write([]string{entry.Package})
+			}
+		}
+		w.Flush()
+		return buf.Bytes(), w.Error()
+	default:
+		var buf bytes.Buffer
+		for _, entry := range entries {
+			fmt.Fprintln(&buf, entry)
+		}
+		return buf.Bytes(), nil
+	}
+}
+
+// resultEntries makes entries of packages, labeled in a matrix
+func resultEntries(label string, packages []string) []resultEntry {
+	entries := make([]resultEntry, 0, len(packages))
+	for _, pkg := range packages {
+		entries = append(entries, resultEntry{Label: label, Package: pkg})
+	}
+	return entries
+}
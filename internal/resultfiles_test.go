@@ -0,0 +1,98 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNewResultFiles(t *testing.T) {
+	tests := []struct {
+		name          string
+		lists         []string
+		formats       []string
+		expected      []string
+		expectedError string
+	}{
+		{"default", nil, nil, nil, ""},
+		{"selected", []string{"regressions", "hung"}, []string{"json"}, []string{"regressions", "hung"}, ""},
+		{"none", []string{"none"}, nil, []string{}, ""},
+		{"unknown list", []string{"passed"}, nil, nil, "invalid result file: passed"},
+		{"unknown format", nil, []string{"xml"}, nil, "invalid result file format: xml"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files, err := NewResultFiles(tt.lists, tt.formats, "")
+			if tt.expectedError != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.expectedError) {
+					t.Errorf("Expected an error containing %q, got %v", tt.expectedError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(files.Lists, tt.expected) {
+				t.Errorf("Expected lists %#v, got %#v", tt.expected, files.Lists)
+			}
+		})
+	}
+}
+
+func TestWriteSelectedResultFiles(t *testing.T) {
+	logDir, resultsDir := t.TempDir(), filepath.Join(t.TempDir(), "out")
+	runner := &RegressionTestRunner{logDir: logDir}
+	runner.SetResultFiles(ResultFiles{Lists: []string{"regressions", "failed"}, Formats: []string{"json", "csv"}, Dir: resultsDir})
+
+	runner.writeResultFiles([]string{"git"}, nil, []string{"curl", "wget"}, nil, nil)
+
+	expected := map[string]string{
+		"regressions.json": "[\n  {\n    \"package\": \"curl\"\n  },\n  {\n    \"package\": \"wget\"\n  }\n]\n",
+		"regressions.csv":  "package\ncurl\nwget\n",
+		"failed.json":      "[]\n",
+		"failed.csv":       "package\n",
+	}
+	for filename, content := range expected {
+		data, err := os.ReadFile(filepath.Join(resultsDir, filename))
+		if err != nil {
+			t.Errorf("Expected %s in the results directory: %v", filename, err)
+			continue
+		}
+		if string(data) != content {
+			t.Errorf("Expected %q in %s, got %q", content, filename, data)
+		}
+	}
+	for _, filename := range []string{"successful.json", "regressions.txt"} {
+		if _, err := os.Stat(filepath.Join(resultsDir, filename)); err == nil {
+			t.Errorf("Expected no %s", filename)
+		}
+	}
+	if entries, _ := os.ReadDir(logDir); len(entries) != 0 {
+		t.Errorf("Expected nothing in the log directory, got %d files", len(entries))
+	}
+}
+
+func TestLoadRegressionsFromFormats(t *testing.T) {
+	for _, format := range []string{"json", "csv"} {
+		t.Run(format, func(t *testing.T) {
+			resultsDir, logDir := t.TempDir(), t.TempDir()
+			files := ResultFiles{Lists: []string{"regressions"}, Formats: []string{format}, Dir: resultsDir}
+			files.write(logDir, true, map[string][]resultEntry{"regressions": resultEntries("wolfi", []string{"curl"})})
+
+			regressions, err := LoadRegressionsFrom(resultsDir, logDir, "")
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			expected := []Regression{{Package: "curl", RepoType: "wolfi", LogPath: filepath.Join(logDir, "wolfi", "curl_with_repo.log")}}
+			if !reflect.DeepEqual(regressions, expected) {
+				t.Errorf("Expected %+v, got %+v", expected, regressions)
+			}
+		})
+	}
+}
@@ -0,0 +1,173 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Package statuses recorded in the results database
+const (
+	StatusPass       = "pass"
+	StatusFail       = "fail"
+	StatusRegression = "regression"
+	StatusHung       = "hung"
+	StatusSkipped    = "skipped"
+	// StatusArchExcluded is a package that isn't built for the
+	// architecture the run tested on
+	StatusArchExcluded = "arch-excluded"
+	// StatusUntestable is a package the Makefile of the package repository
+	// has no test target for
+	StatusUntestable = "untestable"
+	// StatusInfra is a package whose tests failed on the infrastructure
+	// rather than on their own
+	StatusInfra = "infra"
+)
+
+// PackageRecord is the outcome of testing one package in a run
+type PackageRecord struct {
+	Package  string        `json:"package"`
+	Status   string        `json:"status"`
+	Duration time.Duration `json:"duration"`
+	// Key is the content key of the package's test (see testKeyInputs)
+	Key string `json:"key,omitempty"`
+}
+
+// RunRecord is one run stored in the results database
+type RunRecord struct {
+	RunID    string `json:"runId"`
+	Target   string `json:"target"`
+	APKRepo  string `json:"apkRepo"`
+	RepoType string `json:"repoType,omitempty"`
+	// Variant is the name of the variant of a test matrix the run tested
+	Variant    string          `json:"variant,omitempty"`
+	LogDir     string          `json:"logDir"`
+	StartedAt  time.Time       `json:"startedAt"`
+	FinishedAt time.Time       `json:"finishedAt"`
+	Packages   []PackageRecord `json:"packages"`
+	// Notes are the operator's notes about the run
+	Notes []string `json:"notes,omitempty"`
+	// TargetVersion is the version of Target the run was tied to with
+	// package@version
+	TargetVersion string `json:"targetVersion,omitempty"`
+}
+
+// ResultsDB is an append-only store of run results, one JSON record per
+// line, shared between runs to provide history.
+type ResultsDB struct {
+	path string
+}
+
+// NewResultsDB opens the results database at path. An empty path selects
+// results.jsonl in the user's cache directory, or results-fake.jsonl with the
+// fake backend.
+func NewResultsDB(path string) (*ResultsDB, error) {
+	if path == "" {
+		userCacheDir, err := os.UserCacheDir()
+		if err != nil {
+			return nil, fmt.Errorf("failed to determine cache directory: %w", err)
+		}
+		path = filepath.Join(userCacheDir, "apkregress", "results.jsonl")
+		// Simulated runs don't belong in the history of real ones
+		if FakeBackend() {
+			path = filepath.Join(userCacheDir, "apkregress", "results-fake.jsonl")
+		}
+	}
+
+	return &ResultsDB{path: path}, nil
+}
+
+// Append adds a run to the database
+func (db *ResultsDB) Append(run RunRecord) error {
+	return appendJSONLine(db.path, run)
+}
+
+// appendJSONLine appends v to the JSON lines file at path
+func appendJSONLine(path string, v interface{}) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create results database directory: %w", err)
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open results database %s: %w", path, err)
+	}
+	defer file.Close()
+
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write results database %s: %w", path, err)
+	}
+	return nil
+}
+
+// Runs returns all runs in the database, oldest first. A missing database
+// is treated as empty.
+func (db *ResultsDB) Runs() ([]RunRecord, error) {
+	file, err := os.Open(db.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open results database %s: %w", db.path, err)
+	}
+	defer file.Close()
+
+	var runs []RunRecord
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 1024*1024), 64*1024*1024)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var run RunRecord
+		if err := json.Unmarshal(scanner.Bytes(), &run); err != nil {
+			// Skip records written by incompatible versions or partial writes
+			continue
+		}
+		runs = append(runs, run)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read results database %s: %w", db.path, err)
+	}
+	return runs, nil
+}
+
+// AverageDurations returns the mean recorded test duration of each package
+// across all runs. Skipped packages don't contribute.
+func (db *ResultsDB) AverageDurations() (map[string]time.Duration, error) {
+	runs, err := db.Runs()
+	if err != nil {
+		return nil, err
+	}
+
+	totals := make(map[string]time.Duration)
+	counts := make(map[string]int)
+	for _, run := range runs {
+		for _, pkg := range run.Packages {
+			if pkg.Status == StatusSkipped || pkg.Status == StatusArchExcluded || pkg.Status == StatusUntestable || pkg.Status == StatusInfra || pkg.Duration <= 0 {
+				continue
+			}
+			totals[pkg.Package] += pkg.Duration
+			counts[pkg.Package]++
+		}
+	}
+
+	averages := make(map[string]time.Duration, len(totals))
+	for pkg, total := range totals {
+		averages[pkg] = total / time.Duration(counts[pkg])
+	}
+	return averages, nil
+}
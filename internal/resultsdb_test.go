@@ -0,0 +1,90 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestResultsDBAppendAndRuns(t *testing.T) {
+	db, err := NewResultsDB(filepath.Join(t.TempDir(), "nested", "results.jsonl"))
+	if err != nil {
+		t.Fatalf("Failed to open results DB: %v", err)
+	}
+
+	runs, err := db.Runs()
+	if err != nil {
+		t.Fatalf("Unexpected error reading empty DB: %v", err)
+	}
+	if len(runs) != 0 {
+		t.Errorf("Expected no runs, got %d", len(runs))
+	}
+
+	for _, id := range []string{"run-1", "run-2"} {
+		err := db.Append(RunRecord{
+			RunID:  id,
+			Target: "openssl",
+			Packages: []PackageRecord{
+				{Package: "curl", Status: StatusPass, Duration: time.Minute},
+			},
+		})
+		if err != nil {
+			t.Fatalf("Failed to append run: %v", err)
+		}
+	}
+
+	runs, err = db.Runs()
+	if err != nil {
+		t.Fatalf("Failed to read runs: %v", err)
+	}
+	if len(runs) != 2 || runs[0].RunID != "run-1" || runs[1].RunID != "run-2" {
+		t.Errorf("Unexpected runs: %+v", runs)
+	}
+}
+
+func TestResultsDBSkipsCorruptRecords(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "results.jsonl")
+	content := `{"runId":"good","packages":[]}
+{"runId":"partial
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write DB: %v", err)
+	}
+
+	db, _ := NewResultsDB(path)
+	runs, err := db.Runs()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(runs) != 1 || runs[0].RunID != "good" {
+		t.Errorf("Expected only the valid record, got %+v", runs)
+	}
+}
+
+func TestResultsDBAverageDurations(t *testing.T) {
+	db, _ := NewResultsDB(filepath.Join(t.TempDir(), "results.jsonl"))
+
+	db.Append(RunRecord{RunID: "1", Packages: []PackageRecord{
+		{Package: "curl", Status: StatusPass, Duration: 2 * time.Minute},
+		{Package: "git", Status: StatusSkipped, Duration: time.Second},
+	}})
+	db.Append(RunRecord{RunID: "2", Packages: []PackageRecord{
+		{Package: "curl", Status: StatusFail, Duration: 4 * time.Minute},
+	}})
+
+	averages, err := db.AverageDurations()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if averages["curl"] != 3*time.Minute {
+		t.Errorf("Expected curl average 3m, got %v", averages["curl"])
+	}
+	if _, ok := averages["git"]; ok {
+		t.Error("Expected skipped packages to be excluded from averages")
+	}
+}
@@ -5,25 +5,215 @@ package internal
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"net/url"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
-	"sync/atomic"
 	"time"
-
-	"golang.org/x/sync/semaphore"
 )
 
 type TestResult struct {
-	Package  string
-	WithRepo bool
-	Success  bool
-	Error    error
-	Hung     bool
-	Skipped  bool
+	Package        string
+	WithRepo       bool
+	Success        bool
+	Error          error
+	Hung           bool
+	Skipped        bool
+	StartedAt      time.Time
+	Duration       time.Duration
+	LogPath        string
+	ExitCode       int
+	Classification Classification
+	// RepoType is the repository type whose index the package was found in
+	RepoType string
+	// UnchangedSince is the run in which a test with the same content key
+	// passed, when the test was skipped for that reason
+	UnchangedSince string
+	// ArtifactsDir holds the artifacts collected from the test, if any
+	ArtifactsDir string
+	// WorkspaceSnapshot is the archived workspace of a re-run of a regressed
+	// with-repo test, if one was taken
+	WorkspaceSnapshot string
+	// FailingStep is the test pipeline step a failed test stopped in, if
+	// its log names one
+	FailingStep string
+	// SmokeOnly is set for packages that only had the install smoke test
+	// of a two-phase run, since the candidate repository doesn't change
+	// their test environment
+	SmokeOnly bool
+	// PeakMemory is the peak resident memory of the test's processes in
+	// bytes, 0 if it couldn't be measured
+	PeakMemory uint64
+	// CPUTime is the CPU time the test's processes used
+	CPUTime time.Duration
+	// Toolchain is the tooling the test ran with
+	Toolchain Toolchain
+	// ArchExcluded is set for skipped packages that aren't built for the
+	// architecture tests run on
+	ArchExcluded bool
+	// Untestable is set for skipped packages the Makefile has no test
+	// target for
+	Untestable bool
+	// Infra is the infrastructure failure the test ran into rather than
+	// failing on its own, e.g. "network down"
+	Infra string
+	// OOMKilled is set for failed and hung tests of which the kernel killed
+	// a process for lack of memory
+	OOMKilled bool
+	// Previous is the outcome of testing the previous version of a package
+	// that failed with the candidate repository, with SetTestPrevious
+	Previous *PreviousTest
+	// Command is the command line the test ran, if the executor ran one
+	Command *CommandLine
+}
+
+// Classification describes the outcome of a single test
+type Classification string
+
+const (
+	ClassificationPass    Classification = "pass"
+	ClassificationFail    Classification = "fail"
+	ClassificationHung    Classification = "hung"
+	ClassificationSkipped Classification = "skipped"
+	// ClassificationArchExcluded means the package isn't built for the
+	// architecture tests run on
+	ClassificationArchExcluded Classification = "arch-excluded"
+	// ClassificationUntestable means the Makefile has no test target for
+	// the package
+	ClassificationUntestable Classification = "untestable"
+	// ClassificationError means the test could not be run at all
+	ClassificationError Classification = "error"
+	// ClassificationInfra means the test failed on the infrastructure
+	// rather than on its own
+	ClassificationInfra Classification = "infra"
+)
+
+// newTestResult builds the result of a test that started at startedAt and
+// just finished with err
+func newTestResult(packageName string, withRepo bool, startedAt time.Time, logPath string, err error) TestResult {
+	result := TestResult{
+		Package:   packageName,
+		WithRepo:  withRepo,
+		Success:   err == nil,
+		Error:     err,
+		Hung:      errors.Is(err, ErrTestHung),
+		Skipped:   errors.Is(err, ErrPackageYAMLNotFound) || errors.Is(err, ErrArchExcluded) || errors.Is(err, ErrUntestableTarget),
+		StartedAt: startedAt,
+		Duration:  time.Since(startedAt),
+		LogPath:   logPath,
+		ExitCode:  exitCode(err),
+	}
+	result.ArchExcluded = errors.Is(err, ErrArchExcluded)
+	result.Untestable = errors.Is(err, ErrUntestableTarget)
+	result.Classification = classify(result)
+	return result
+}
+
+// exitCode returns the exit status of a failed command, 0 on success or -1
+// if the command didn't exit on its own
+func exitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	// *exec.ExitError, or the exit of a fake test
+	var exitErr interface{ ExitCode() int }
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	return -1
+}
+
+func classify(result TestResult) Classification {
+	switch {
+	case result.Success:
+		return ClassificationPass
+	case result.ArchExcluded:
+		return ClassificationArchExcluded
+	case result.Untestable:
+		return ClassificationUntestable
+	case result.Skipped:
+		return ClassificationSkipped
+	// Tests that hung because a process was killed for lack of memory
+	// failed on the infrastructure
+	case result.Infra != "":
+		return ClassificationInfra
+	case result.Hung:
+		return ClassificationHung
+	case result.ExitCode > 0:
+		return ClassificationFail
+	default:
+		return ClassificationError
+	}
+}
+
+// MarshalJSON encodes the result with its error as a string
+func (t TestResult) MarshalJSON() ([]byte, error) {
+	errMsg := ""
+	if t.Error != nil {
+		errMsg = t.Error.Error()
+	}
+
+	return json.Marshal(struct {
+		Package           string         `json:"package"`
+		WithRepo          bool           `json:"withRepo"`
+		Success           bool           `json:"success"`
+		Error             string         `json:"error,omitempty"`
+		Hung              bool           `json:"hung"`
+		Skipped           bool           `json:"skipped"`
+		ArchExcluded      bool           `json:"archExcluded,omitempty"`
+		Untestable        bool           `json:"untestable,omitempty"`
+		StartedAt         time.Time      `json:"startedAt"`
+		Duration          time.Duration  `json:"duration"`
+		LogPath           string         `json:"logPath,omitempty"`
+		ExitCode          int            `json:"exitCode"`
+		Classification    Classification `json:"classification"`
+		RepoType          string         `json:"repoType,omitempty"`
+		UnchangedSince    string         `json:"unchangedSince,omitempty"`
+		ArtifactsDir      string         `json:"artifactsDir,omitempty"`
+		WorkspaceSnapshot string         `json:"workspaceSnapshot,omitempty"`
+		FailingStep       string         `json:"failingStep,omitempty"`
+		SmokeOnly         bool           `json:"smokeOnly,omitempty"`
+		PeakMemory        uint64         `json:"peakMemory,omitempty"`
+		CPUTime           time.Duration  `json:"cpuTime,omitempty"`
+		Toolchain         *Toolchain     `json:"toolchain,omitempty"`
+		Infra             string         `json:"infra,omitempty"`
+		OOMKilled         bool           `json:"oomKilled,omitempty"`
+		Previous          *PreviousTest  `json:"previous,omitempty"`
+		Command           *CommandLine   `json:"command,omitempty"`
+	}{
+		Package:           t.Package,
+		WithRepo:          t.WithRepo,
+		Success:           t.Success,
+		Error:             errMsg,
+		Hung:              t.Hung,
+		Skipped:           t.Skipped,
+		ArchExcluded:      t.ArchExcluded,
+		Untestable:        t.Untestable,
+		StartedAt:         t.StartedAt,
+		Duration:          t.Duration,
+		LogPath:           t.LogPath,
+		ExitCode:          t.ExitCode,
+		Classification:    t.Classification,
+		RepoType:          t.RepoType,
+		UnchangedSince:    t.UnchangedSince,
+		ArtifactsDir:      t.ArtifactsDir,
+		WorkspaceSnapshot: t.WorkspaceSnapshot,
+		FailingStep:       t.FailingStep,
+		SmokeOnly:         t.SmokeOnly,
+		PeakMemory:        t.PeakMemory,
+		CPUTime:           t.CPUTime,
+		Toolchain:         toolchainOrNil(t.Toolchain),
+		Infra:             t.Infra,
+		OOMKilled:         t.OOMKilled,
+		Previous:          t.Previous,
+		Command:           t.Command,
+	})
 }
 
 type RegressionTestRunner struct {
@@ -38,33 +228,231 @@ type RegressionTestRunner struct {
 	markdownOutput bool
 	apkrane        *ApkraneClient
 	melange        *MelangeClient
-	completedTests int64
-	totalTests     int64
+	apko           *ApkoClient
+	progress       ProgressTracker
 	startTime      time.Time
+	sbomPackages   []string
+	sbomRestrict   bool
+	resultsDB      *ResultsDB
+	eta            *etaEstimator
+	durationsMu    sync.Mutex
+	durations      map[string]time.Duration
+	packageResults map[string]map[bool]TestResult
+	packageOptions map[string]PackageOptions
+	executor       TestExecutor
+	summary        runSummary
+	heartbeat      *heartbeatSender
+	// inMatrix suppresses the per-run summary; the matrix prints a merged one
+	inMatrix bool
+	// matrixLabel tags the packages of the runner in the merged report of a
+	// matrix with variants (see label)
+	matrixLabel string
+	// advisories are the vulnerabilities the candidate version of the
+	// package fixes, when checkAdvisories is set
+	checkAdvisories bool
+	advisories      *AdvisoryReport
+	// maxPackages is the number of packages testing more of needs
+	// confirmBudget to agree
+	maxPackages   int
+	confirmBudget func(count int, estimate time.Duration) bool
+	// chunkSize is the number of packages tested before the results so
+	// far are written, chunksDone the number of chunks finished
+	chunkSize  int
+	chunksDone int
+	// tempRoot is where the temporary directory of the run, tempDir, is
+	// created; cleanLeaks removes what's left of it after the run
+	tempRoot   string
+	tempDir    string
+	cleanLeaks bool
+	// gatePath is the expected results the run is gated on, gate the
+	// statuses read from it
+	gatePath string
+	gate     *runBaseline
+	// candidateChange is what to do when the candidate repository changes
+	// during the run, checked every candidatePoll by candidateWatch (see
+	// SetCandidateChange)
+	candidateChange string
+	candidatePoll   time.Duration
+	candidateWatch  *candidateWatch
+	// exclude are the patterns of packages left out of runs
+	exclude []string
+	// pins are the candidate packages with-repo tests install at exact
+	// versions (see SetPins)
+	pins []Pin
+	// follow are the packages whose test output is streamed from the start
+	// of the run (see SetFollow)
+	follow []string
+	// notes are the operator's notes about the run (see SetNotes)
+	notes []string
+	// hooks run around the run and the tests of each package
+	hooks Hooks
+	// processors receive the results of each run as they come in;
+	// activeProcessors are those of the current run that haven't failed
+	processors       []ResultProcessor
+	activeProcessors []ResultProcessor
+	// variant is the variant of a test matrix the run tests, if any
+	variant *Variant
+	// sanityTargets are the target packages tested on their own before
+	// their reverse dependencies, which aren't tested if one fails unless
+	// force is set
+	sanityTargets []string
+	force         bool
+	// depth is how many levels of consumers are tested; with
+	// pruneOnRegression, queued consumers of regressed ones are pruned
+	// along the consumer graph
+	depth             int
+	pruneOnRegression bool
+	consumerParents   map[string][]string
+	consumerChildren  map[string][]string
+	pruned            []string
+	// infra re-queues packages after infrastructure failures, unless
+	// noInfraBackoff is set
+	noInfraBackoff bool
+	infra          *infraBackoff
+	// versionChange is the version of the package in the index and in the
+	// candidate repository
+	versionChange *VersionChange
+	// targetVersion is the version of the package the run is tied to, with
+	// package@version (see SetTargetVersion)
+	targetVersion string
+	// baseline is the earlier run regressions are compared against, read
+	// from previousResults or else the results database
+	previousResults string
+	baseline        *runBaseline
+	invocation      *Invocation
+	manifest        *RunManifest
+	// logURL is the URL the logs directory is published at, for linking
+	// logs from the markdown summary
+	logURL string
+	// testKeys are the content keys of the package tests with skipUnchanged,
+	// recorded in the results database. Packages whose key passed in an
+	// earlier run aren't tested again; unchanged maps them to that run.
+	skipUnchanged bool
+	testKeys      map[string]string
+	unchanged     map[string]string
+	// queue holds the packages of the run in progress, which Enqueue and
+	// Cancel change
+	queueMu sync.Mutex
+	queue   *packageQueue
+	// snapshotRegressions re-runs regressed tests keeping their workspace
+	snapshotRegressions bool
+	// failOnEmpty fails runs without reverse dependencies, which set empty
+	failOnEmpty bool
+	empty       bool
+	// targetsOf maps each reverse dependency to the targets it depends on
+	// when several targets are tested at once
+	targetsOf map[string][]string
+	// twoPhase runs an install smoke test first; packages it finds
+	// unaffected by the candidate repository aren't tested in full
+	twoPhase   bool
+	unaffected map[string]bool
+	// summaryJSON, github, compressLogs and artifactLogDir make the outputs
+	// of a run fit for CI, see SetSummaryJSON, SetGitHubActions,
+	// SetLogCompression and SetArtifactLogDir
+	summaryJSON    bool
+	github         *GitHubActions
+	compressLogs   bool
+	artifactLogDir bool
+	// watchdog reports runs in which no test started or finished for
+	// watchdogTimeout, see SetWatchdog
+	watchdogTimeout time.Duration
+	onWedged        func()
+	watchdog        *watchdog
+	// resultFiles selects the package lists written after the run
+	resultFiles ResultFiles
+	// testPrevious tests the previous version of consumers that fail with
+	// the candidate repository, see SetTestPrevious
+	testPrevious bool
+	// failureBudget decides whether regressions fail the run, see
+	// SetFailureBudget
+	failureBudget *FailureBudget
+	// buildDeps maps the consumers whose melange configs install the
+	// package in their build environment to the packages they declare,
+	// runtimeDeps the consumers the index lists
+	buildDeps   map[string][]string
+	runtimeDeps map[string]bool
+	// depKind selects the consumers tested by how they depend on the
+	// package, see SetDepKind
+	depKind string
+	// health is marked ready once testing starts, see SetHealth
+	health *Health
+	// ctx stops the run when cancelled, see SetContext
+	ctx context.Context
 }
 
-func (r *RegressionTestRunner) updateProgress() {
-	// Check current value before incrementing
-	current := atomic.LoadInt64(&r.completedTests)
-	total := r.totalTests
+// runSummary is the outcome of a run, by package
+type runSummary struct {
+	Total       int
+	Tested      int
+	Skipped     []string
+	Successful  []string
+	Failed      []string
+	Regressions []string
+	Hung        []string
+	// ArchExcluded are the packages not built for the architecture tests
+	// ran on
+	ArchExcluded []string
+	// Untestable are the packages the Makefile has no test target for
+	Untestable []string
+	// Fixed are the packages that regressed in the baseline and pass now
+	Fixed []string
+	// FailureClusters group failed and regressed packages by error
+	FailureClusters []FailureCluster
+	// Memory are the packages whose tests used the most memory
+	Memory []packageMemory
+	// Utilization is how well the tests used the workers and the machine
+	Utilization utilization
+	// Gate is how the run deviates from the expected results, with --gate
+	Gate *gateResult
+	// Budget is how the regressions fit the failure budget, if one is set
+	Budget *budgetResult
+	// ToolingDrift is how the tooling changed while the run was in progress
+	ToolingDrift []string
+	// CandidateChanges are the changes of the candidate repository while
+	// the run was in progress, with --candidate-change
+	CandidateChanges []candidateChange
+	// Backoffs are the reductions of the concurrency after infrastructure
+	// failures
+	Backoffs []concurrencyBackoff
+	// Infra are the packages whose tests failed on the infrastructure, with
+	// the failure, e.g. "curl (network down)"
+	Infra []string
+	// OOMKilled are the packages whose tests the kernel killed for lack of
+	// memory, which aren't in Infra
+	OOMKilled []oomKill
+	// Pruned are the consumers not tested because the consumers they depend
+	// on regressed, with --prune-on-regression
+	Pruned []string
+	// Ecosystems are the outcomes by language ecosystem, if the packages
+	// span several
+	Ecosystems []ecosystemStats
+	// DepKinds are the outcomes by how the consumers depend on the package,
+	// if they depend on it in several ways
+	DepKinds []depKindStats
+}
 
-	if current >= total {
-		return // Already at or past completion
+// updateProgress counts a package whose tests finished and updates the
+// progress line
+func (r *RegressionTestRunner) updateProgress() {
+	completed, total, ok := r.progress.Complete()
+	if !ok {
+		return // Already at completion
 	}
 
-	completed := atomic.AddInt64(&r.completedTests, 1)
-
 	if r.verbose {
 		return // Don't show progress in verbose mode
 	}
 
 	// Calculate progress percentage
+	snapshot := r.progress.Snapshot()
 	progress := float64(completed) / float64(total) * 100
 
 	// Calculate elapsed time and estimate remaining time
 	elapsed := time.Since(r.startTime)
 	var eta time.Duration
-	if completed > 0 {
+	if r.eta != nil {
+		eta = r.eta.estimate(time.Now())
+	} else if completed > 0 {
 		avgTimePerTest := elapsed / time.Duration(completed)
 		remaining := total - completed
 		eta = avgTimePerTest * time.Duration(remaining)
@@ -72,9 +460,9 @@ func (r *RegressionTestRunner) updateProgress() {
 
 	// Format the progress update
 	if eta > 0 {
-		fmt.Printf("\rProgress: %d/%d (%.1f%%) - ETA: %v", completed, total, progress, eta.Round(time.Second))
+		fmt.Printf("\rProgress: %d/%d (%.1f%%) %s - ETA: %v", completed, total, progress, snapshot.Counts(), eta.Round(time.Second))
 	} else {
-		fmt.Printf("\rProgress: %d/%d (%.1f%%)", completed, total, progress)
+		fmt.Printf("\rProgress: %d/%d (%.1f%%) %s", completed, total, progress, snapshot.Counts())
 	}
 
 	// Print newline when complete
@@ -133,344 +521,1707 @@ func NewRegressionTestRunnerFromPackageList(packages []string, apkRepo, repoPath
 	}
 }
 
-func (r *RegressionTestRunner) Run() error {
-	// Create log directory
-	if err := os.MkdirAll(r.logDir, 0755); err != nil {
-		return fmt.Errorf("failed to create log directory %s: %w", r.logDir, err)
+func NewRegressionTestRunnerFromApkoConfigs(configDir, apkRepo string, concurrency int, verbose bool, hangTimeout time.Duration, markdownOutput bool) *RegressionTestRunner {
+	// Create log directory with timestamp
+	timestamp := time.Now().Format("20060102-150405")
+	logDir := filepath.Join("logs", fmt.Sprintf("apko-config-test-%s", timestamp))
+
+	// Default to 30 minutes if no timeout specified
+	if hangTimeout == 0 {
+		hangTimeout = 30 * time.Minute
 	}
 
-	reverseDeps, err := r.apkrane.GetReverseDependencies(r.packageName)
-	if err != nil {
-		return fmt.Errorf("failed to get reverse dependencies: %w", err)
+	return &RegressionTestRunner{
+		packageName:    fmt.Sprintf("apko configs in %s", configDir),
+		apkRepo:        apkRepo,
+		concurrency:    concurrency,
+		verbose:        verbose,
+		logDir:         logDir,
+		hangTimeout:    hangTimeout,
+		markdownOutput: markdownOutput,
+		apko:           NewApkoClient(verbose, logDir, hangTimeout),
 	}
+}
 
-	if len(reverseDeps) == 0 {
-		fmt.Printf("No reverse dependencies found for package: %s\n", r.packageName)
+// SetIndexCache enables on-disk caching of parsed package indexes
+func (r *RegressionTestRunner) SetIndexCache(cache *IndexCache) {
+	if r.apkrane != nil {
+		r.apkrane.SetIndexCache(cache)
+	}
+}
+
+// SetBuildCache shares build tool caches in dir between all package tests
+func (r *RegressionTestRunner) SetBuildCache(dir string) error {
+	if r.melange == nil {
 		return nil
 	}
+	return r.melange.SetBuildCache(dir)
+}
 
-	fmt.Printf("Testing %d reverse dependencies with concurrency %d\n", len(reverseDeps), r.concurrency)
-	fmt.Printf("Logs will be saved to: %s\n", r.logDir)
+// SetAPKCache makes all tests and builds share the APK download cache in
+// dir, which is created if needed
+func (r *RegressionTestRunner) SetAPKCache(dir string) error {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return fmt.Errorf("failed to resolve APK cache directory: %w", err)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create APK cache directory: %w", err)
+	}
 
-	// Initialize progress tracking
-	r.totalTests = int64(len(reverseDeps))
-	r.startTime = time.Now()
+	if r.melange != nil {
+		r.melange.SetAPKCache(dir)
+	}
+	if r.apko != nil {
+		r.apko.SetAPKCache(dir)
+	}
+	return nil
+}
 
-	results := make(chan TestResult, len(reverseDeps)*2)
-	ctx := context.Background()
-	sem := semaphore.NewWeighted(int64(r.concurrency))
-	var wg sync.WaitGroup
+// SetPackageOptions applies per-package overrides, typically read from a
+// package file
+func (r *RegressionTestRunner) SetPackageOptions(options map[string]PackageOptions) {
+	r.packageOptions = options
+	for pkg, opts := range options {
+		if opts.Timeout > 0 && r.melange != nil {
+			r.melange.SetPackageTimeout(pkg, opts.Timeout)
+		}
+	}
+}
 
-	for _, pkg := range reverseDeps {
-		wg.Add(1)
-		go func(packageName string) {
-			defer wg.Done()
-			sem.Acquire(ctx, 1)
-			defer sem.Release(1)
-
-			// First test with repo
-			err := r.melange.TestPackage(packageName, true, r.apkRepo)
-
-			withRepoResult := TestResult{
-				Package:  packageName,
-				WithRepo: true,
-				Success:  err == nil,
-				Error:    err,
-				Hung:     errors.Is(err, ErrTestHung),
-				Skipped:  errors.Is(err, ErrPackageYAMLNotFound),
-			}
-			results <- withRepoResult
+// timeoutFor returns the hang timeout that applies to a package
+func (r *RegressionTestRunner) timeoutFor(pkg string) time.Duration {
+	if opts, ok := r.packageOptions[pkg]; ok && opts.Timeout > 0 {
+		return opts.Timeout
+	}
+	return r.hangTimeout
+}
 
-			// Only test without repo if test with repo failed and wasn't skipped
-			if !withRepoResult.Success && !withRepoResult.Skipped {
-				err := r.melange.TestPackage(packageName, false, r.apkRepo)
+// hungTimeout returns the timeout a hung test, e.g. "curl (with repo)", was
+// killed after
+func (r *RegressionTestRunner) hungTimeout(test string) time.Duration {
+	pkg, _, _ := strings.Cut(test, " (")
+	return r.timeoutFor(pkg)
+}
 
-				// Skip if YAML file not found (shouldn't happen since we already checked, but for safety)
-				if errors.Is(err, ErrPackageYAMLNotFound) {
-					r.updateProgress()
-					return
-				}
+// SetConfigLocator changes how package YAML files are found in the repository
+func (r *RegressionTestRunner) SetConfigLocator(locator ConfigLocator) {
+	if r.melange != nil {
+		r.melange.SetConfigLocator(locator)
+	}
+}
 
-				results <- TestResult{
-					Package:  packageName,
-					WithRepo: false,
-					Success:  err == nil,
-					Error:    err,
-					Hung:     errors.Is(err, ErrTestHung),
-					Skipped:  errors.Is(err, ErrPackageYAMLNotFound),
-				}
-			}
+// SetResultsDB records every run in db and uses its history to estimate
+// per-package durations
+func (r *RegressionTestRunner) SetResultsDB(db *ResultsDB) {
+	r.resultsDB = db
+}
 
-			// Update progress after completing all tests for this package
-			r.updateProgress()
-		}(pkg)
+// SetHeartbeat POSTs progress to url every interval while tests run, and
+// once more when the run finishes
+func (r *RegressionTestRunner) SetHeartbeat(url string, interval time.Duration) {
+	r.heartbeat = newHeartbeatSender(url, interval, r.verbose)
+}
+
+// heartbeatSnapshot reports the current progress of the run
+func (r *RegressionTestRunner) heartbeatSnapshot(status string) Heartbeat {
+	progress := r.progress.Snapshot()
+	beat := Heartbeat{
+		RunID:       filepath.Base(r.logDir),
+		Target:      r.packageName,
+		RepoType:    r.repoType,
+		Status:      status,
+		Completed:   progress.Completed,
+		Total:       progress.Total,
+		Regressions: progress.Regressed,
+		Hung:        progress.Hung,
+		Elapsed:     time.Since(r.startTime),
+		Notes:       r.notes,
+	}
+	if r.eta != nil && status == HeartbeatRunning {
+		beat.ETA = r.eta.estimate(time.Now())
 	}
+	return beat
+}
 
-	go func() {
-		wg.Wait()
-		close(results)
-	}()
+// SetSBOMPackages limits (or, when restrict is false, prioritizes) testing to
+// reverse dependencies that produce one of the given packages, typically the
+// package list of one or more shipped images.
+func (r *RegressionTestRunner) SetSBOMPackages(packages []string, restrict bool) {
+	r.sbomPackages = packages
+	r.sbomRestrict = restrict
+}
+
+// applySBOMFilter restricts or reorders reverse dependencies based on the
+// configured SBOM packages. Packages found in the SBOM keep their relative
+// order and come first when prioritizing.
+func (r *RegressionTestRunner) applySBOMFilter(reverseDeps []string) ([]string, error) {
+	if len(r.sbomPackages) == 0 {
+		return reverseDeps, nil
+	}
+
+	shipped, err := r.apkrane.OriginsForPackages(r.sbomPackages)
+	if err != nil {
+		return nil, fmt.Errorf("failed to map SBOM packages to origins: %w", err)
+	}
+
+	var inSBOM, notInSBOM []string
+	for _, pkg := range reverseDeps {
+		if shipped[pkg] {
+			inSBOM = append(inSBOM, pkg)
+		} else {
+			notInSBOM = append(notInSBOM, pkg)
+		}
+	}
+
+	if r.sbomRestrict {
+		fmt.Printf("SBOM filter: %d of %d reverse dependencies ship in the provided images\n", len(inSBOM), len(reverseDeps))
+		return inSBOM, nil
+	}
 
-	return r.analyzeResults(results, len(reverseDeps))
+	fmt.Printf("SBOM filter: prioritizing %d of %d reverse dependencies that ship in the provided images\n", len(inSBOM), len(reverseDeps))
+	return append(inSBOM, notInSBOM...), nil
 }
 
-func (r *RegressionTestRunner) RunFromPackageList(packages []string) error {
-	// Create log directory
-	if err := os.MkdirAll(r.logDir, 0755); err != nil {
-		return fmt.Errorf("failed to create log directory %s: %w", r.logDir, err)
+// SetArtifactCollection collects test artifacts in an artifacts directory
+// below the log directory (see MelangeClient.SetArtifactCollection)
+func (r *RegressionTestRunner) SetArtifactCollection(enabled bool) {
+	if r.melange != nil {
+		r.melange.SetArtifactCollection(enabled)
 	}
+}
 
-	if len(packages) == 0 {
-		fmt.Println("No packages provided")
+// SetWorkspaceSnapshots re-runs the with-repo test of every regression with
+// melange keeping its workspace, which is archived next to the logs so the
+// failing build tree can be inspected
+func (r *RegressionTestRunner) SetWorkspaceSnapshots(enabled bool) {
+	r.snapshotRegressions = enabled
+}
+
+// SetMelangeRunner selects the melange runner tests use (see CheckPlatform)
+func (r *RegressionTestRunner) SetMelangeRunner(runner string) {
+	if r.melange != nil {
+		r.melange.SetRunner(runner)
+	}
+}
+
+// SetPipelineFilter restricts the test pipelines melange runs (see
+// MelangeClient.SetPipelineFilter)
+func (r *RegressionTestRunner) SetPipelineFilter(only, skip []string) {
+	if r.melange != nil {
+		r.melange.SetPipelineFilter(only, skip)
+	}
+}
+
+// SetTestCommand runs tests with a command rendered from a template instead
+// of make (see MelangeClient.SetTestCommand)
+func (r *RegressionTestRunner) SetTestCommand(text string) error {
+	if r.melange == nil {
 		return nil
 	}
+	return r.melange.SetTestCommand(text)
+}
 
-	fmt.Printf("Testing %d packages with concurrency %d\n", len(packages), r.concurrency)
-	fmt.Printf("Logs will be saved to: %s\n", r.logDir)
+// SetInvocation records how apkregress was started in the run manifest
+func (r *RegressionTestRunner) SetInvocation(invocation Invocation) {
+	r.invocation = &invocation
+}
 
-	// Initialize progress tracking
-	r.totalTests = int64(len(packages))
-	r.startTime = time.Now()
+// SetLogURL links the logs in the markdown summary to where the directory
+// containing the run's log directory is published, e.g. the prefix logs are
+// uploaded to by CI
+func (r *RegressionTestRunner) SetLogURL(url string) {
+	r.logURL = strings.TrimSuffix(url, "/")
+}
 
-	results := make(chan TestResult, len(packages)*2)
-	ctx := context.Background()
-	sem := semaphore.NewWeighted(int64(r.concurrency))
-	var wg sync.WaitGroup
+// logLink returns the published URL of a log, or "" without a log URL
+func (r *RegressionTestRunner) logLink(logPath string) string {
+	if r.logURL == "" {
+		return ""
+	}
 
-	for _, pkg := range packages {
-		wg.Add(1)
-		go func(packageName string) {
-			defer wg.Done()
-			sem.Acquire(ctx, 1)
-			defer sem.Release(1)
-
-			// First test with repo
-			err := r.melange.TestPackage(packageName, true, r.apkRepo)
-
-			withRepoResult := TestResult{
-				Package:  packageName,
-				WithRepo: true,
-				Success:  err == nil,
-				Error:    err,
-				Hung:     errors.Is(err, ErrTestHung),
-				Skipped:  errors.Is(err, ErrPackageYAMLNotFound),
-			}
-			results <- withRepoResult
+	// Matrix runs log to a subdirectory of the shared log directory
+	runDir := r.logDir
+	if r.inMatrix {
+		runDir = filepath.Dir(runDir)
+	}
+	rel, err := filepath.Rel(filepath.Dir(runDir), logPath)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return ""
+	}
 
-			// Only test without repo if test with repo failed and wasn't skipped
-			if !withRepoResult.Success && !withRepoResult.Skipped {
-				err := r.melange.TestPackage(packageName, false, r.apkRepo)
+	segments := strings.Split(filepath.ToSlash(rel), "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return r.logURL + "/" + strings.Join(segments, "/")
+}
 
-				// Skip if YAML file not found (shouldn't happen since we already checked, but for safety)
-				if errors.Is(err, ErrPackageYAMLNotFound) {
-					r.updateProgress()
-					return
-				}
+// SetSkipUnchanged skips the tests of packages whose config, candidate
+// dependency versions and melange version are the same as in an earlier run
+// in which they passed
+func (r *RegressionTestRunner) SetSkipUnchanged(enabled bool) {
+	r.skipUnchanged = enabled
+}
 
-				results <- TestResult{
-					Package:  packageName,
-					WithRepo: false,
-					Success:  err == nil,
-					Error:    err,
-					Hung:     errors.Is(err, ErrTestHung),
-					Skipped:  errors.Is(err, ErrPackageYAMLNotFound),
-				}
-			}
+// loadTestKeys computes the content keys of the packages under test, which
+// are recorded with the run, and finds the ones that passed before with the
+// same key
+func (r *RegressionTestRunner) loadTestKeys(packages []string) error {
+	melangeVersion := r.manifest.Tools["melange"]
+	if melangeVersion == "" {
+		return errors.New("melange version unknown")
+	}
 
-			// Update progress after completing all tests for this package
-			r.updateProgress()
-		}(pkg)
+	base, err := r.apkrane.loadIndex()
+	if err != nil {
+		return err
+	}
+	candidate, err := loadCandidateIndex(candidateIndexURL(r.apkRepo, hostArch()))
+	if err != nil {
+		return err
 	}
 
-	go func() {
-		wg.Wait()
-		close(results)
-	}()
+	passed, err := r.resultsDB.PassedKeys()
+	if err != nil {
+		return err
+	}
 
-	return r.analyzeResults(results, len(packages))
+	keyer := newTestKeyer(r.melange.locator, r.repoType, melangeVersion, base, candidate.Packages)
+	keyer.pipelines = r.melange.pipelineFilter()
+	keyer.env = r.melange.env
+	keyer.pins = r.melange.pins
+	keyer.testCommand = r.melange.testCommandText
+	r.testKeys, r.unchanged = keyedPackages(keyer, passed, packages)
+	return nil
 }
 
-func (r *RegressionTestRunner) analyzeResults(results chan TestResult, expectedPackages int) error {
-	packageResults := make(map[string]map[bool]TestResult)
+// SetPreviousResults compares regressions with the results.json of an earlier
+// run, given as the file or its log directory, instead of the latest run of
+// the same target in the results database
+func (r *RegressionTestRunner) SetPreviousResults(path string) {
+	r.previousResults = path
+}
 
-	for result := range results {
-		if packageResults[result.Package] == nil {
-			packageResults[result.Package] = make(map[bool]TestResult)
-		}
-		packageResults[result.Package][result.WithRepo] = result
+// loadBaseline finds the run to compare this one with, if any
+func (r *RegressionTestRunner) loadBaseline() (*runBaseline, error) {
+	if r.previousResults != "" {
+		return loadBaselineResults(r.previousResults, r.repoType)
+	}
+	if r.resultsDB == nil {
+		return nil, nil
 	}
 
-	var regressions []string
-	var hungTests []string
-	var successfulPackages []string
-	var failedPackages []string
-	var skippedPackages []string
-	var successCount, failureCount, skippedCount int
+	run, err := r.resultsDB.LatestRun(r.packageName, r.repoType, r.variantName())
+	if err != nil || run == nil {
+		return nil, err
+	}
+	return baselineFromRun(run), nil
+}
 
-	fmt.Println("\n=== Test Results ===")
-	for pkg, results := range packageResults {
-		withRepoResult, hasWithRepo := results[true]
-		withoutRepoResult, hasWithoutRepo := results[false]
+// SetAdvisoryCheck enables annotating the summary with the vulnerabilities
+// the candidate version of the package fixes
+func (r *RegressionTestRunner) SetAdvisoryCheck(enabled bool) {
+	r.checkAdvisories = enabled
+}
 
-		if !hasWithRepo {
-			fmt.Printf("⚠️  %s: Incomplete test results\n", pkg)
-			continue
-		}
+// loadAdvisories correlates the versions of the package in the index and in
+// the candidate repository with the repository type's security feed. It
+// returns nil if the candidate repository doesn't contain the package or the
+// repository type has no security feed.
+func (r *RegressionTestRunner) loadAdvisories() (*AdvisoryReport, error) {
+	fromVersion, toVersion, err := r.targetVersions()
+	if err != nil || toVersion == "" {
+		return nil, err
+	}
 
-		// Check for skipped tests first
-		if withRepoResult.Skipped {
-			skippedCount++
-			skippedPackages = append(skippedPackages, pkg)
-			if r.verbose {
-				fmt.Printf("⏭️  %s: SKIPPED (YAML file not found)\n", pkg)
-			}
-			continue
-		}
+	feed := advisoryFeedURL(r.repoType)
+	if feed == "" {
+		return nil, nil
+	}
+	db, err := fetchSecurityDB(feed)
+	if err != nil {
+		return nil, err
+	}
 
-		// Check for hung tests
-		if withRepoResult.Hung {
-			hungTests = append(hungTests, fmt.Sprintf("%s (with repo)", pkg))
-			fmt.Printf("⏰ %s: HUNG (with repo - killed after %v)\n", pkg, r.hangTimeout)
-			if hasWithoutRepo && withoutRepoResult.Hung {
-				hungTests = append(hungTests, fmt.Sprintf("%s (without repo)", pkg))
-				fmt.Printf("⏰ %s: HUNG (without repo - killed after %v)\n", pkg, r.hangTimeout)
-			}
-			continue
-		}
-		if hasWithoutRepo && withoutRepoResult.Hung {
-			hungTests = append(hungTests, fmt.Sprintf("%s (without repo)", pkg))
-			fmt.Printf("⏰ %s: HUNG (without repo - killed after %v)\n", pkg, r.hangTimeout)
-			continue
-		}
+	return &AdvisoryReport{
+		Package:     r.packageName,
+		FromVersion: fromVersion,
+		ToVersion:   toVersion,
+		Feed:        feed,
+		Fixes:       correlateAdvisories(db, r.packageName, fromVersion, toVersion),
+	}, nil
+}
 
-		// If with-repo test passed, we didn't run without-repo test
-		if withRepoResult.Success && !hasWithoutRepo {
-			successCount++
-			successfulPackages = append(successfulPackages, pkg)
-			if r.verbose {
-				fmt.Printf("✅ %s: PASS (with repo, without-repo test skipped)\n", pkg)
-			}
-		} else if !withRepoResult.Success && hasWithoutRepo {
-			// Both tests were run because with-repo failed
-			if withoutRepoResult.Success {
-				regressions = append(regressions, pkg)
-				fmt.Printf("🔴 %s: REGRESSION DETECTED (fails with repo, passes without)\n", pkg)
-			} else {
-				failureCount++
-				failedPackages = append(failedPackages, pkg)
-				if r.verbose {
-					fmt.Printf("❌ %s: FAIL (both scenarios)\n", pkg)
-				}
-			}
-		} else if !withRepoResult.Success && !hasWithoutRepo {
-			fmt.Printf("⚠️  %s: Incomplete test results (with-repo failed but no without-repo test)\n", pkg)
-			continue
+func (r *RegressionTestRunner) Run() error {
+	// Create log directory
+	if err := os.MkdirAll(r.logDir, 0755); err != nil {
+		return fmt.Errorf("failed to create log directory %s: %w", r.logDir, err)
+	}
+
+	// A matrix checks that the package is in any of its indexes instead
+	if !r.inMatrix {
+		if err := r.apkrane.ValidatePackage(r.packageName); err != nil {
+			return err
 		}
 	}
 
-	// Generate result files
-	r.writeResultFiles(successfulPackages, failedPackages, regressions, hungTests, skippedPackages)
+	graph, err := r.apkrane.GetConsumerGraph(r.packageName, r.depth)
+	if err != nil {
+		return fmt.Errorf("failed to get reverse dependencies: %w", err)
+	}
+	buildOnly, err := r.findBuildConsumers(r.packageName, graph.Packages())
+	if err != nil {
+		return err
+	}
+	graph.addDirect(buildOnly)
+	graph.keep(r.selectConsumers(graph.Packages()))
+	r.setConsumerGraph(graph)
+	r.pruned = nil
+	reverseDeps := graph.Packages()
 
-	if r.markdownOutput {
-		r.printMarkdownSummary(expectedPackages, skippedCount, len(packageResults)-skippedCount, len(regressions), len(hungTests), successCount, failureCount, regressions, hungTests)
-	} else {
-		fmt.Printf("\n=== Summary ===\n")
-		fmt.Printf("Total packages found: %d\n", expectedPackages)
-		fmt.Printf("Packages skipped (no YAML): %d\n", skippedCount)
-		fmt.Printf("Packages tested: %d\n", len(packageResults)-skippedCount)
-		fmt.Printf("Regressions detected: %d\n", len(regressions))
-		fmt.Printf("Hung tests: %d\n", len(hungTests))
-		fmt.Printf("Successful packages: %d\n", successCount)
-		fmt.Printf("Failed packages: %d\n", failureCount)
+	reverseDeps, err = r.applySBOMFilter(reverseDeps)
+	if err != nil {
+		return err
 	}
 
-	if !r.markdownOutput {
-		if len(hungTests) > 0 {
-			fmt.Printf("\nTests that hung (killed after 30 minutes):\n")
-			for _, test := range hungTests {
-				fmt.Printf("  - %s\n", test)
-			}
-		}
+	r.versionChange, err = r.loadVersionChange()
+	if err != nil {
+		fmt.Printf("Warning: failed to compare versions of %s: %v\n", r.packageName, err)
+	} else if r.versionChange != nil && !r.inMatrix {
+		fmt.Printf("Version change: %s\n", r.versionChange)
+	}
 
-		if len(regressions) > 0 {
-			fmt.Printf("\nPackages with regressions:\n")
-			for _, pkg := range regressions {
-				fmt.Printf("  - %s\n", pkg)
-			}
+	// The fake backend has no advisory feed to correlate with
+	if r.checkAdvisories && !FakeBackend() {
+		r.advisories, err = r.loadAdvisories()
+		if err != nil {
+			fmt.Printf("Warning: failed to correlate security advisories: %v\n", err)
+		} else if r.advisories != nil && r.verbose {
+			fmt.Printf("Security fixes: %s\n", r.advisories)
 		}
 	}
 
-	if len(regressions) > 0 {
-		return fmt.Errorf("found %d regressions", len(regressions))
+	if len(reverseDeps) == 0 {
+		return r.reportNoReverseDependencies(fmt.Sprintf("package: %s", r.packageName))
 	}
 
-	if len(hungTests) > 0 {
-		return fmt.Errorf("found %d hung tests", len(hungTests))
+	fmt.Printf("Testing %d reverse dependencies with concurrency %d\n", len(reverseDeps), r.concurrency)
+	if len(graph.Levels) > 1 {
+		fmt.Printf("Consumers by distance: %s\n", graph.levelCounts())
 	}
+	fmt.Printf("Logs will be saved to: %s\n", r.logDir)
 
-	return nil
+	r.sanityTargets = []string{r.packageName}
+	return r.runTests(reverseDeps, r.packageExecutor())
 }
 
-func (r *RegressionTestRunner) printMarkdownSummary(totalPackages, skippedCount, testedCount, regressionsCount, hungCount, successCount, failureCount int, regressions, hungTests []string) {
-	fmt.Printf("\n## APK Regression Test Summary\n\n")
-	fmt.Printf("**Package:** %s  \n", r.packageName)
-	fmt.Printf("**APK Repository:** %s  \n", r.apkRepo)
-	fmt.Printf("**Test Duration:** %v  \n\n", time.Since(r.startTime).Round(time.Second))
-
-	fmt.Printf("### Test Results\n\n")
-	fmt.Printf("| Metric | Count |\n")
-	fmt.Printf("|--------|-------|\n")
-	fmt.Printf("| Total packages found | %d |\n", totalPackages)
-	fmt.Printf("| Packages skipped (no YAML) | %d |\n", skippedCount)
-	fmt.Printf("| Packages tested | %d |\n", testedCount)
-	fmt.Printf("| **Regressions detected** | **%d** |\n", regressionsCount)
-	fmt.Printf("| Hung tests | %d |\n", hungCount)
-	fmt.Printf("| Successful packages | %d |\n", successCount)
-	fmt.Printf("| Failed packages | %d |\n", failureCount)
+// RunReverseDependencies tests the reverse dependencies of several packages
+// at once, e.g. every package rebuilt between two releases. Consumers of more
+// than one of them are tested once.
+func (r *RegressionTestRunner) RunReverseDependencies(targets []string) error {
+	if err := os.MkdirAll(r.logDir, 0755); err != nil {
+		return fmt.Errorf("failed to create log directory %s: %w", r.logDir, err)
+	}
 
-	if regressionsCount > 0 {
-		fmt.Printf("\n### 🔴 Packages with Regressions\n\n")
-		fmt.Printf("The following packages **fail with the new APK repository** but **pass without it**, indicating potential regressions:\n\n")
-		for _, pkg := range regressions {
-			fmt.Printf("- `%s`\n", pkg)
+	seen := make(map[string]bool)
+	var reverseDeps []string
+	for _, target := range targets {
+		deps, err := r.apkrane.GetReverseDependencies(target)
+		if err != nil {
+			return fmt.Errorf("failed to get reverse dependencies of %s: %w", target, err)
+		}
+		buildOnly, err := r.findBuildConsumers(target, deps)
+		if err != nil {
+			return err
+		}
+		deps = append(deps, buildOnly...)
+		for _, dep := range deps {
+			if len(targets) > 1 {
+				r.addTarget(dep, target)
+			}
+			if !seen[dep] {
+				seen[dep] = true
+				reverseDeps = append(reverseDeps, dep)
+			}
 		}
 	}
+	sort.Strings(reverseDeps)
+	reverseDeps = r.selectConsumers(reverseDeps)
 
-	if hungCount > 0 {
-		fmt.Printf("\n### ⏰ Tests That Hung\n\n")
-		fmt.Printf("The following tests were killed after %v timeout:\n\n", r.hangTimeout)
-		for _, test := range hungTests {
-			fmt.Printf("- `%s`\n", test)
-		}
+	reverseDeps, err := r.applySBOMFilter(reverseDeps)
+	if err != nil {
+		return err
 	}
 
-	if regressionsCount == 0 && hungCount == 0 {
-		fmt.Printf("\n### ✅ All Tests Passed\n\n")
-		fmt.Printf("No regressions were detected. All packages either passed with the new repository or failed consistently in both scenarios.\n")
+	if len(reverseDeps) == 0 {
+		return r.reportNoReverseDependencies(fmt.Sprintf("%d packages", len(targets)))
 	}
 
-	fmt.Printf("\n---\n")
-	fmt.Printf("*Generated by apk-regression-test-runner*\n")
+	fmt.Printf("Testing %d reverse dependencies of %d packages with concurrency %d\n", len(reverseDeps), len(targets), r.concurrency)
+	fmt.Printf("Logs will be saved to: %s\n", r.logDir)
+
+	return r.runTests(reverseDeps, r.packageExecutor())
 }
 
-func (r *RegressionTestRunner) writeResultFiles(successful, failed, regressions, hung, skipped []string) {
-	files := map[string][]string{
-		"successful.txt":  successful,
-		"failed.txt":      failed,
-		"regressions.txt": regressions,
-		"hung.txt":        hung,
-		"skipped.txt":     skipped,
+func (r *RegressionTestRunner) RunFromPackageList(packages []string) error {
+	// Create log directory
+	if err := os.MkdirAll(r.logDir, 0755); err != nil {
+		return fmt.Errorf("failed to create log directory %s: %w", r.logDir, err)
 	}
 
-	for filename, packages := range files {
-		filePath := filepath.Join(r.logDir, filename)
-		content := strings.Join(packages, "\n")
-		if content != "" {
-			content += "\n"
-		}
-
-		if err := os.WriteFile(filePath, []byte(content), 0644); err != nil {
-			fmt.Printf("Warning: failed to write %s: %v\n", filename, err)
-		}
+	if len(packages) == 0 {
+		fmt.Println("No packages provided")
+		return nil
 	}
+
+	fmt.Printf("Testing %d packages with concurrency %d\n", len(packages), r.concurrency)
+	fmt.Printf("Logs will be saved to: %s\n", r.logDir)
+
+	return r.runTests(packages, r.packageExecutor())
+}
+
+// RunApkoConfigs builds every apko configuration found in configDir with and
+// without the candidate repository. Many regressions only show up when images
+// are composed, e.g. as package resolution conflicts.
+func (r *RegressionTestRunner) RunApkoConfigs(configDir string) error {
+	// Create log directory
+	if err := os.MkdirAll(r.logDir, 0755); err != nil {
+		return fmt.Errorf("failed to create log directory %s: %w", r.logDir, err)
+	}
+
+	configs, err := FindApkoConfigs(configDir)
+	if err != nil {
+		return err
+	}
+
+	if len(configs) == 0 {
+		fmt.Printf("No apko configs found in: %s\n", configDir)
+		return nil
+	}
+
+	fmt.Printf("Building %d apko configs with concurrency %d\n", len(configs), r.concurrency)
+	fmt.Printf("Logs will be saved to: %s\n", r.logDir)
+
+	return r.runTests(sortedKeys(configs), r.apko.Executor(configs))
+}
+
+// SetExecutor replaces the backend that runs package tests, which defaults
+// to running melange tests locally
+func (r *RegressionTestRunner) SetExecutor(executor TestExecutor) {
+	r.executor = executor
+}
+
+// packageExecutor returns the executor for package tests
+func (r *RegressionTestRunner) packageExecutor() TestExecutor {
+	if r.executor != nil {
+		return r.executor
+	}
+	if FakeBackend() {
+		return newFakeExecutor(r.melange)
+	}
+	return r.melange
+}
+
+// runTests tests each package with the candidate repository, retrying
+// without it on failure, and analyzes the collected results.
+func (r *RegressionTestRunner) runTests(packages []string, executor TestExecutor) error {
+	ctx := r.runContext()
+	if err := r.stoppedErr(ctx); err != nil {
+		return err
+	}
+
+	packages, duplicates := dedupePackages(packages)
+	for _, pkg := range duplicates {
+		fmt.Printf("Warning: ignoring duplicate package %s\n", pkg)
+	}
+	packages, excluded := r.excludePackages(packages)
+	if len(excluded) > 0 {
+		fmt.Printf("Excluding %d packages: %s\n", len(excluded), strings.Join(excluded, ", "))
+	}
+	r.warnUnfollowed(packages)
+
+	// MelangeClient checks that make and melange are installed, the fake
+	// backend its outcomes
+	if checker, ok := executor.(interface{ CheckTools() error }); ok {
+		if err := checker.CheckTools(); err != nil {
+			return err
+		}
+	}
+
+	var history map[string]time.Duration
+	if r.resultsDB != nil {
+		var err error
+		history, err = r.resultsDB.AverageDurations()
+		if err != nil && r.verbose {
+			fmt.Printf("Warning: failed to load duration history: %v\n", err)
+		}
+	}
+	if err := r.checkPackageBudget(packages, history); err != nil {
+		return err
+	}
+	if r.gatePath != "" {
+		gate, err := loadGate(r.gatePath, r.repoType)
+		if err != nil {
+			return err
+		}
+		r.gate = gate
+	}
+	if err := r.resolveRunPins(); err != nil {
+		return err
+	}
+
+	tempDir, err := makeRunTempDir(r.tempRoot)
+	if err != nil {
+		return err
+	}
+	r.tempDir = tempDir
+	// Deferred in this order so that the leftovers are audited once the run
+	// removed its temporary directory
+	defer r.auditTempLeaks()
+	defer removeRunTempDir(tempDir)
+
+	// Tests of private repositories fetch packages from apk.cgr.dev too
+	if r.melange != nil {
+		r.melange.SetAuth(RepositoryAuth(r.repoType, r.apkRepo))
+	}
+	if err := r.checkTargets(executor); err != nil {
+		return err
+	}
+
+	// Initialize progress tracking
+	r.progress.SetTotal(int64(len(packages)))
+	r.startTime = time.Now()
+	r.durations = make(map[string]time.Duration, len(packages))
+
+	r.manifest = r.newRunManifest(packages)
+	if err := writeRunManifest(r.logDir, r.manifest); err != nil {
+		fmt.Printf("Warning: %v\n", err)
+	}
+	if err := r.runPreRunHook(); err != nil {
+		return err
+	}
+
+	r.watchdog = r.startWatchdog()
+	defer r.watchdog.Stop()
+	r.health.SetReady()
+
+	r.candidateWatch, err = r.startCandidateWatch()
+	if err != nil {
+		return err
+	}
+	defer r.candidateWatch.Stop()
+
+	if r.skipUnchanged && r.resultsDB != nil && r.melange != nil {
+		if err := r.loadTestKeys(packages); err != nil {
+			fmt.Printf("Warning: failed to compute test content keys, testing all packages: %v\n", err)
+		}
+	}
+	if len(r.unchanged) > 0 {
+		fmt.Printf("Skipping %d packages that passed in earlier runs with the same config, candidate dependencies and melange version\n", len(r.unchanged))
+	}
+	if r.twoPhase {
+		r.runSmokePhase(packages, executor)
+	}
+
+	var estimated []string
+	for _, pkg := range packages {
+		if _, ok := r.unchanged[pkg]; !ok && !r.unaffected[pkg] {
+			estimated = append(estimated, pkg)
+		}
+	}
+	r.eta = newETAEstimator(estimated, history, r.concurrency)
+
+	// A fixed pool of workers takes packages from the queue, to which
+	// Enqueue can add more while the run is in progress. The results channel
+	// only needs to absorb bursts, since analyzeResults consumes it as tests
+	// finish, so memory stays flat regardless of the number of packages.
+	workers := r.concurrency
+	if workers < 1 {
+		workers = 1
+	}
+
+	queue := newPackageQueue(packages)
+	if r.chunkSize > 0 {
+		queue.setChunkSize(r.chunkSize)
+	}
+	r.queueMu.Lock()
+	r.queue = queue
+	r.queueMu.Unlock()
+	if !r.noInfraBackoff {
+		r.infra = newInfraBackoff(workers)
+	}
+
+	r.startProcessors()
+
+	results := make(chan TestResult, workers*2)
+	stopWatching := r.stopOnCancel(ctx, queue)
+	defer stopWatching()
+	wg := r.startWorkers(ctx, queue, workers, executor, results)
+
+	if r.heartbeat != nil {
+		r.heartbeat.start(func() Heartbeat {
+			if queue.isPaused() {
+				return r.heartbeatSnapshot(HeartbeatPaused)
+			}
+			return r.heartbeatSnapshot(HeartbeatRunning)
+		})
+	}
+
+	go func() {
+		wg.Wait()
+		r.rerunInfraFailures(ctx, executor, results)
+		close(results)
+	}()
+
+	err = r.analyzeResults(results, len(packages))
+	if stopped := r.stoppedErr(ctx); stopped != nil {
+		r.manifest.Stopped = context.Cause(ctx).Error()
+		err = stopped
+	}
+
+	r.queueMu.Lock()
+	r.queue = nil
+	r.queueMu.Unlock()
+
+	r.manifest.FinishedAt = time.Now()
+	r.manifest.Commands = testCommands(r.packageResults)
+	if err := writeRunManifest(r.logDir, r.manifest); err != nil {
+		fmt.Printf("Warning: %v\n", err)
+	}
+
+	if r.heartbeat != nil {
+		r.heartbeat.finish(r.heartbeatSnapshot(HeartbeatFinished))
+	}
+	r.finishProcessors()
+	r.runPostRunHook(err)
+	return err
+}
+
+// startWorkers starts workers that test the packages of queue until it is
+// drained, sending the results to results
+func (r *RegressionTestRunner) startWorkers(ctx context.Context, queue *packageQueue, workers int, executor TestExecutor, results chan<- TestResult) *sync.WaitGroup {
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+
+			// Each worker reuses one scratch directory for all of its tests
+			// instead of creating a new temp directory per test
+			scratch, err := os.MkdirTemp(r.tempDir, fmt.Sprintf("worker-%d-", worker))
+			if err != nil {
+				fmt.Printf("Warning: failed to create worker directory, tests will use their own: %v\n", err)
+				scratch = ""
+			} else {
+				defer os.RemoveAll(scratch)
+			}
+
+			for ctx.Err() == nil {
+				packageName, ok := queue.pop()
+				if !ok {
+					return
+				}
+				r.testPackage(ctx, packageName, executor, scratch, results)
+				queue.done()
+			}
+		}(i)
+	}
+	return &wg
+}
+
+// ErrNoReverseDependencies indicates that a run had nothing to test, with
+// --fail-on-empty
+var ErrNoReverseDependencies = errors.New("no reverse dependencies found")
+
+// SetFailOnEmpty makes runs that find no reverse dependencies fail with
+// ErrNoReverseDependencies instead of succeeding
+func (r *RegressionTestRunner) SetFailOnEmpty(enabled bool) {
+	r.failOnEmpty = enabled
+}
+
+// reportNoReverseDependencies reports a run of target that found nothing to
+// test. The usual result files are written, empty, with run.json marking the
+// run, so that tooling reading them can tell it apart from a clean run.
+func (r *RegressionTestRunner) reportNoReverseDependencies(target string) error {
+	r.empty = true
+	if r.markdownOutput && !r.inMatrix {
+		fmt.Printf("## Regression Test Results\n\nNo reverse dependencies found for %s, nothing was tested.\n", target)
+	} else {
+		fmt.Printf("No reverse dependencies found for %s\n", target)
+	}
+
+	r.startTime = time.Now()
+	r.manifest = r.newRunManifest([]string{})
+	r.manifest.NoReverseDependencies = true
+	r.manifest.FinishedAt = r.startTime
+	if err := writeRunManifest(r.logDir, r.manifest); err != nil {
+		fmt.Printf("Warning: %v\n", err)
+	}
+	r.writeResultsJSON(nil)
+	r.writeResultFiles(nil, nil, nil, nil, nil)
+	r.reportCI(func(w io.Writer) {
+		fmt.Fprintf(w, "## Regression Test Results\n\nNo reverse dependencies found for %s, nothing was tested.\n", target)
+	})
+	if r.heartbeat != nil {
+		r.heartbeat.finish(r.heartbeatSnapshot(HeartbeatFinished))
+	}
+
+	if r.failOnEmpty && !r.inMatrix {
+		return ErrNoReverseDependencies
+	}
+	return nil
+}
+
+// ErrNoRunInProgress indicates that the packages of a run can't be changed
+// because the runner isn't testing
+var ErrNoRunInProgress = errors.New("no run in progress")
+
+// activeQueue returns the package queue of the run in progress, or nil
+func (r *RegressionTestRunner) activeQueue() *packageQueue {
+	r.queueMu.Lock()
+	defer r.queueMu.Unlock()
+	return r.queue
+}
+
+// Enqueue adds packages to the run in progress, e.g. when a failure suggests
+// related breakage. They are tested before queued packages of a lower
+// priority; the packages the run started with have priority 0. Packages
+// that are already part of the run are ignored, and an invalid name (see
+// ValidatePackageName) adds none of them. It returns the packages that were
+// added.
+func (r *RegressionTestRunner) Enqueue(packages []string, priority int) ([]string, error) {
+	if r.apko != nil {
+		return nil, errors.New("packages can't be added to apko config builds")
+	}
+	queue := r.activeQueue()
+	if queue == nil {
+		return nil, ErrNoRunInProgress
+	}
+	for _, pkg := range packages {
+		if err := ValidatePackageName(pkg); err != nil {
+			return nil, err
+		}
+	}
+
+	var added []string
+	for _, pkg := range packages {
+		if !queue.push(pkg, priority) {
+			continue
+		}
+		added = append(added, pkg)
+		r.progress.AddTotal(1)
+		r.eta.queue(pkg)
+	}
+
+	r.queueMu.Lock()
+	r.manifest.Packages = append(r.manifest.Packages, added...)
+	r.queueMu.Unlock()
+	return added, nil
+}
+
+// Cancel removes packages that haven't started testing from the run in
+// progress. It returns the packages that were removed.
+func (r *RegressionTestRunner) Cancel(packages []string) ([]string, error) {
+	queue := r.activeQueue()
+	if queue == nil {
+		return nil, ErrNoRunInProgress
+	}
+
+	var cancelled []string
+	removed := make(map[string]bool)
+	for _, pkg := range packages {
+		if !queue.cancel(pkg) {
+			continue
+		}
+		cancelled = append(cancelled, pkg)
+		removed[pkg] = true
+		r.progress.AddTotal(-1)
+		r.eta.cancel(pkg)
+	}
+
+	r.queueMu.Lock()
+	var kept []string
+	for _, pkg := range r.manifest.Packages {
+		if !removed[pkg] {
+			kept = append(kept, pkg)
+		}
+	}
+	r.manifest.Packages = kept
+	r.queueMu.Unlock()
+	return cancelled, nil
+}
+
+// Pause stops starting tests of the run in progress, e.g. to yield the
+// machine for a while. Running tests finish, and the run waits until it is
+// resumed.
+func (r *RegressionTestRunner) Pause() error {
+	queue := r.activeQueue()
+	if queue == nil {
+		return ErrNoRunInProgress
+	}
+	if !queue.setPaused(true) {
+		r.printResult("⏸️  Paused: running tests will finish, no new tests start until resumed\n")
+	}
+	return nil
+}
+
+// Resume continues a paused run
+func (r *RegressionTestRunner) Resume() error {
+	queue := r.activeQueue()
+	if queue == nil {
+		return ErrNoRunInProgress
+	}
+	if queue.setPaused(false) {
+		r.printResult("▶️  Resumed\n")
+	}
+	return nil
+}
+
+// QueuedPackages returns the packages of the run in progress that haven't
+// started testing, in the order they will
+func (r *RegressionTestRunner) QueuedPackages() ([]string, error) {
+	queue := r.activeQueue()
+	if queue == nil {
+		return nil, ErrNoRunInProgress
+	}
+	return queue.queued(), nil
+}
+
+// testPackage tests a package with the candidate repository and, if that
+// fails, without it. scratch is the worker's scratch directory, emptied
+// before every test.
+func (r *RegressionTestRunner) testPackage(ctx context.Context, packageName string, executor TestExecutor, scratch string, results chan<- TestResult) {
+	startedAt := time.Now()
+
+	if runID, ok := r.unchanged[packageName]; ok {
+		result := r.tag(TestResult{
+			Package:        packageName,
+			WithRepo:       true,
+			Success:        true,
+			StartedAt:      startedAt,
+			Classification: ClassificationPass,
+			UnchangedSince: runID,
+		})
+		results <- result
+		r.progress.Count(result, nil)
+		r.updateProgress()
+		return
+	}
+	if r.unaffected[packageName] {
+		result := r.tag(TestResult{
+			Package:        packageName,
+			WithRepo:       true,
+			Success:        true,
+			StartedAt:      startedAt,
+			Classification: ClassificationPass,
+			SmokeOnly:      true,
+		})
+		results <- result
+		r.progress.Count(result, nil)
+		r.updateProgress()
+		return
+	}
+
+	r.eta.started(packageName, startedAt)
+
+	r.runTestHook(HookPreTest, packageName, nil)
+	var tested []TestResult
+	defer func() { r.runTestHook(HookPostTest, packageName, tested) }()
+
+	test := func(withRepo bool, workspace string) TestResult {
+		if scratch != "" {
+			if err := emptyDir(scratch); err != nil {
+				fmt.Printf("Warning: failed to clean worker directory %s: %v\n", scratch, err)
+			}
+		}
+		r.watchdog.started(packageName)
+		defer r.watchdog.finished(packageName)
+		result := markInfra(r.tag(executor.Execute(ctx, packageName, ExecuteOptions{
+			WithRepo:     withRepo,
+			APKRepo:      r.apkRepo,
+			Timeout:      r.timeoutFor(packageName),
+			WorkDir:      scratch,
+			WorkspaceDir: workspace,
+		})))
+		tested = append(tested, result)
+		return result
+	}
+
+	// First test with repo. Tests that failed on the infrastructure are
+	// tested again later instead of being reported. A package retried for
+	// its without-repo test keeps its with-repo result.
+	withRepoResult, retried := r.infra.takeWithRepo(packageName)
+	if !retried {
+		withRepoResult = test(true, "")
+		// A test interrupted by stopping the run tells nothing
+		if ctx.Err() != nil {
+			return
+		}
+		if r.retryInfraFailure(packageName, withRepoResult, nil) {
+			return
+		}
+	}
+	// A test without the repository tells nothing about an infrastructure
+	// failure
+	runWithoutRepo := !withRepoResult.Success && !withRepoResult.Skipped && withRepoResult.Infra == "" && !r.packageOptions[packageName].SkipWithoutRepo
+
+	// A failure that may turn out to be a regression is held back until the
+	// workspace snapshot or the outcome of the previous version can be
+	// attached to it
+	hold := (r.snapshotRegressions || r.testPrevious) && runWithoutRepo && !withRepoResult.Hung
+	if !hold && !retried {
+		results <- withRepoResult
+	}
+
+	// Only test without repo if test with repo failed and wasn't skipped
+	if runWithoutRepo {
+		withoutRepoResult := test(false, "")
+		if ctx.Err() != nil {
+			return
+		}
+		// The retry only tests without the repository again
+		if r.retryInfraFailure(packageName, withoutRepoResult, &withRepoResult) {
+			return
+		}
+		if hold {
+			if withoutRepoResult.Success && r.snapshotRegressions {
+				withRepoResult.WorkspaceSnapshot = r.snapshotWorkspace(packageName, test)
+			}
+			if r.testPrevious && !withoutRepoResult.Skipped && !withoutRepoResult.Hung {
+				withRepoResult.Previous = r.testPreviousVersion(ctx, packageName, executor, scratch)
+			}
+			results <- withRepoResult
+		}
+
+		// Skip if YAML file not found (shouldn't happen since we already checked, but for safety)
+		if withoutRepoResult.Skipped {
+			r.progress.Count(withRepoResult, nil)
+			r.recordDuration(packageName, time.Since(startedAt))
+			r.updateProgress()
+			return
+		}
+
+		results <- withoutRepoResult
+		r.progress.Count(withRepoResult, &withoutRepoResult)
+	} else {
+		r.progress.Count(withRepoResult, nil)
+	}
+
+	// Update progress after completing all tests for this package
+	r.recordDuration(packageName, time.Since(startedAt))
+	r.updateProgress()
+}
+
+// snapshotWorkspace re-runs the with-repo test of a regression through test
+// with the workspace kept, and archives the workspace in the log directory.
+// It returns the archive's path, or "" if the executor didn't keep the
+// workspace.
+func (r *RegressionTestRunner) snapshotWorkspace(packageName string, test func(withRepo bool, workspace string) TestResult) string {
+	workspace, err := os.MkdirTemp(r.tempDir, fmt.Sprintf("workspace-%s-", packageName))
+	if err != nil {
+		fmt.Printf("Warning: failed to create workspace directory for %s: %v\n", packageName, err)
+		return ""
+	}
+	defer os.RemoveAll(workspace)
+
+	if result := test(true, workspace); result.Success {
+		r.printResult("⚠️  %s: passed when re-run for a workspace snapshot, the regression may be flaky\n", packageName)
+	}
+	if !hasArtifacts(workspace) {
+		return ""
+	}
+
+	archive := filepath.Join(r.logDir, fmt.Sprintf("%s_workspace.tar.gz", packageName))
+	if err := archiveDir(workspace, archive); err != nil {
+		fmt.Printf("Warning: failed to snapshot workspace of %s: %v\n", packageName, err)
+		return ""
+	}
+	return archive
+}
+
+// emptyDir removes everything inside dir
+func emptyDir(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if err := os.RemoveAll(filepath.Join(dir, entry.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tag records which repository type a result belongs to
+func (r *RegressionTestRunner) tag(result TestResult) TestResult {
+	result.RepoType = r.repoType
+	return result
+}
+
+// recordDuration notes how long all tests of a package took
+func (r *RegressionTestRunner) recordDuration(packageName string, duration time.Duration) {
+	r.eta.finished(packageName, duration)
+
+	r.durationsMu.Lock()
+	r.durations[packageName] = duration
+	r.durationsMu.Unlock()
+}
+
+// recordRun appends the outcome of this run to the results database
+func (r *RegressionTestRunner) recordRun(statuses map[string]string) {
+	if r.resultsDB == nil {
+		return
+	}
+
+	run := RunRecord{
+		RunID:      filepath.Base(r.logDir),
+		Target:     r.packageName,
+		APKRepo:    r.apkRepo,
+		RepoType:   r.repoType,
+		Variant:    r.variantName(),
+		LogDir:     r.logDir,
+		StartedAt:  r.startTime,
+		FinishedAt: time.Now(),
+		Notes:      r.notes,
+
+		TargetVersion: r.targetVersion,
+	}
+	for _, pkg := range sortedKeys(statuses) {
+		r.durationsMu.Lock()
+		duration := r.durations[pkg]
+		r.durationsMu.Unlock()
+
+		run.Packages = append(run.Packages, PackageRecord{
+			Package:  pkg,
+			Status:   statuses[pkg],
+			Duration: duration,
+			Key:      r.testKeys[pkg],
+		})
+	}
+
+	if err := r.resultsDB.Append(run); err != nil {
+		fmt.Printf("Warning: failed to record results: %v\n", err)
+	}
+}
+
+// resultTally collects the outcomes of the packages reported so far
+type resultTally struct {
+	regressions []string
+	hungTests   []string
+	successful  []string
+	failed      []string
+	skipped     []string
+	statuses    map[string]string
+	// archExcluded are skipped too, but for their architecture, and
+	// untestable because the Makefile has no test target for them
+	archExcluded []string
+	untestable   []string
+	// infra failed on the infrastructure, with the failure, e.g.
+	// "curl (network down)", except for oomKilled
+	infra     []string
+	oomKilled []oomKill
+}
+
+// reportPackage classifies a package and prints its outcome once its results
+// are complete. It returns false while a without-repo test is still to come.
+func (r *RegressionTestRunner) reportPackage(t *resultTally, pkg string, results map[bool]TestResult) bool {
+	withRepoResult, hasWithRepo := results[true]
+	withoutRepoResult, hasWithoutRepo := results[false]
+
+	if !hasWithRepo {
+		return false
+	}
+
+	// Check for skipped tests first
+	if withRepoResult.ArchExcluded {
+		t.archExcluded = append(t.archExcluded, pkg)
+		t.statuses[pkg] = StatusArchExcluded
+		if r.verbose {
+			fmt.Printf("⏭️  %s: SKIPPED (not built for %s)\n", pkg, hostArch())
+		}
+		return true
+	}
+	if withRepoResult.Untestable {
+		t.untestable = append(t.untestable, pkg)
+		t.statuses[pkg] = StatusUntestable
+		if r.verbose {
+			fmt.Printf("⏭️  %s: UNTESTABLE (%v)\n", pkg, withRepoResult.Error)
+		}
+		return true
+	}
+	if withRepoResult.Skipped {
+		t.skipped = append(t.skipped, pkg)
+		t.statuses[pkg] = StatusSkipped
+		if r.verbose {
+			fmt.Printf("⏭️  %s: SKIPPED (YAML file not found)\n", pkg)
+		}
+		return true
+	}
+
+	// Tests that failed on the infrastructure tell nothing about the
+	// candidate repository
+	if infra := infraResult(withRepoResult, withoutRepoResult, hasWithoutRepo); infra != nil {
+		t.statuses[pkg] = StatusInfra
+		if infra.OOMKilled {
+			kill := oomKill{Package: pkg, Peak: infra.PeakMemory}
+			t.oomKilled = append(t.oomKilled, kill)
+			r.printResult("💥 %s: KILLED FOR LACK OF MEMORY (not counted) - log: %s\n", kill, infra.LogPath)
+			return true
+		}
+		t.infra = append(t.infra, fmt.Sprintf("%s (%s)", pkg, infra.Infra))
+		r.printResult("🛠️  %s: INFRASTRUCTURE FAILURE (%s, not counted) - log: %s\n", pkg, infra.Infra, infra.LogPath)
+		return true
+	}
+
+	// Check for hung tests
+	if withRepoResult.Hung {
+		t.hungTests = append(t.hungTests, fmt.Sprintf("%s (with repo)", pkg))
+		t.statuses[pkg] = StatusHung
+		r.printResult("⏰ %s: HUNG (with repo - killed after %v)\n", pkg, r.timeoutFor(pkg))
+		if hasWithoutRepo && withoutRepoResult.Hung {
+			t.hungTests = append(t.hungTests, fmt.Sprintf("%s (without repo)", pkg))
+			r.printResult("⏰ %s: HUNG (without repo - killed after %v)\n", pkg, r.timeoutFor(pkg))
+		}
+		return true
+	}
+	if hasWithoutRepo && withoutRepoResult.Hung {
+		t.hungTests = append(t.hungTests, fmt.Sprintf("%s (without repo)", pkg))
+		t.statuses[pkg] = StatusHung
+		r.printResult("⏰ %s: HUNG (without repo - killed after %v)\n", pkg, r.timeoutFor(pkg))
+		return true
+	}
+
+	switch {
+	case withRepoResult.Success && !hasWithoutRepo:
+		// If with-repo test passed, we didn't run without-repo test
+		t.successful = append(t.successful, pkg)
+		t.statuses[pkg] = StatusPass
+		switch {
+		case !r.verbose:
+		case withRepoResult.UnchangedSince != "":
+			fmt.Printf("✅ %s: PASS (unchanged since %s, not tested)\n", pkg, withRepoResult.UnchangedSince)
+		case withRepoResult.SmokeOnly:
+			fmt.Printf("✅ %s: PASS (test environment unaffected, not tested in full)\n", pkg)
+		default:
+			fmt.Printf("✅ %s: PASS (with repo, without-repo test skipped) [%v]\n", pkg, withRepoResult.Duration.Round(time.Second))
+		}
+	case !withRepoResult.Success && hasWithoutRepo:
+		// Both tests were run because with-repo failed
+		if withoutRepoResult.Success {
+			t.regressions = append(t.regressions, pkg)
+			t.statuses[pkg] = StatusRegression
+			r.printResult("🔴 %s: REGRESSION DETECTED (fails with repo, passes without)%s%s%s%s - log: %s%s\n", pkg, r.regressionNote(pkg, " [%s]"), r.previousNote(pkg, " [%s]"), stepNote(withRepoResult, " in step %s"), toolingNote(withRepoResult, withoutRepoResult, " (tooling changed: %s)"), withRepoResult.LogPath, artifactsNote(withRepoResult))
+		} else {
+			t.failed = append(t.failed, pkg)
+			t.statuses[pkg] = StatusFail
+			if r.verbose {
+				fmt.Printf("❌ %s: FAIL (both scenarios, exit code %d)%s - log: %s%s\n", pkg, withRepoResult.ExitCode, r.previousNote(pkg, " [%s]"), withRepoResult.LogPath, artifactsNote(withRepoResult))
+			}
+		}
+	case !withRepoResult.Success && r.packageOptions[pkg].SkipWithoutRepo:
+		t.failed = append(t.failed, pkg)
+		t.statuses[pkg] = StatusFail
+		if r.verbose {
+			fmt.Printf("❌ %s: FAIL (with repo, without-repo test disabled) - log: %s%s\n", pkg, withRepoResult.LogPath, artifactsNote(withRepoResult))
+		}
+	default:
+		// The without-repo test is still running
+		return false
+	}
+	return true
+}
+
+// stepNote formats the step a failed test stopped in, or returns "" if it
+// isn't known
+func stepNote(result TestResult, format string) string {
+	if result.FailingStep == "" {
+		return ""
+	}
+	return fmt.Sprintf(format, result.FailingStep)
+}
+
+// artifactsNote points at the artifacts and workspace snapshot of a result,
+// if any were collected
+func artifactsNote(result TestResult) string {
+	var note string
+	if result.WorkspaceSnapshot != "" {
+		note += fmt.Sprintf(", workspace: %s", result.WorkspaceSnapshot)
+	}
+	if result.ArtifactsDir != "" {
+		note += fmt.Sprintf(", artifacts: %s", result.ArtifactsDir)
+	}
+	return note
+}
+
+// printResult prints an outcome over the progress line, which the next
+// progress update redraws below it
+func (r *RegressionTestRunner) printResult(format string, args ...interface{}) {
+	if !r.verbose {
+		fmt.Print("\r\033[K")
+	}
+	fmt.Printf(format, args...)
+}
+
+// analyzeResults consumes test results as they arrive and reports each
+// package as soon as its outcome is known, so that regressions in a long run
+// show up within minutes. The summary follows once all tests finished.
+func (r *RegressionTestRunner) analyzeResults(results chan TestResult, expectedPackages int) error {
+	packageResults := make(map[string]map[bool]TestResult)
+	r.packageResults = packageResults
+
+	// Load the baseline before this run is recorded
+	baseline, err := r.loadBaseline()
+	if err != nil {
+		fmt.Printf("Warning: failed to load previous results: %v\n", err)
+	}
+	r.baseline = baseline
+
+	tally := &resultTally{statuses: make(map[string]string)}
+	reported := make(map[string]bool)
+
+	collect := func(result TestResult) {
+		r.processResult(result)
+		pkg := result.Package
+		if packageResults[pkg] == nil {
+			packageResults[pkg] = make(map[bool]TestResult)
+		}
+		packageResults[pkg][result.WithRepo] = result
+
+		if reported[pkg] {
+			// A package that hung with the repository is reported right
+			// away; its without-repo test may hang as well
+			if !result.WithRepo && result.Hung {
+				tally.hungTests = append(tally.hungTests, fmt.Sprintf("%s (without repo)", pkg))
+				r.printResult("⏰ %s: HUNG (without repo - killed after %v)\n", pkg, r.timeoutFor(pkg))
+			}
+			return
+		}
+		reported[pkg] = r.reportPackage(tally, pkg, packageResults[pkg])
+		if reported[pkg] && tally.statuses[pkg] == StatusRegression {
+			r.pruneConsumers(pkg, tally.statuses)
+		}
+	}
+
+	var chunks <-chan struct{}
+	if queue := r.activeQueue(); queue != nil {
+		chunks = queue.chunks()
+	}
+
+	fmt.Println("\n=== Test Results ===")
+	for results != nil {
+		select {
+		case result, ok := <-results:
+			if !ok {
+				results = nil
+				continue
+			}
+			collect(result)
+		case <-chunks:
+			// The chunk's tests all finished, so its results are buffered
+			for len(results) > 0 {
+				collect(<-results)
+			}
+			r.flushChunk(tally, packageResults)
+			r.activeQueue().nextChunk()
+		}
+	}
+	// Changes after the last test finished didn't affect the run
+	r.candidateWatch.Stop()
+
+	// Packages may have been added or cancelled while the run was in progress
+	if queue := r.activeQueue(); queue != nil {
+		expectedPackages = queue.size()
+	}
+
+	for _, pkg := range sortedKeys(packageResults) {
+		if reported[pkg] {
+			continue
+		}
+		if _, ok := packageResults[pkg][true]; !ok {
+			fmt.Printf("⚠️  %s: Incomplete test results\n", pkg)
+		} else {
+			fmt.Printf("⚠️  %s: Incomplete test results (with-repo failed but no without-repo test)\n", pkg)
+		}
+	}
+
+	regressions := tally.regressions
+	hungTests := tally.hungTests
+	successfulPackages := tally.successful
+	failedPackages := tally.failed
+	skippedPackages := tally.skipped
+	successCount, failureCount, skippedCount := len(successfulPackages), len(failedPackages), len(skippedPackages)
+	testedCount := len(packageResults) - skippedCount - len(tally.archExcluded) - len(tally.untestable) - len(tally.infra) - len(tally.oomKilled)
+	statuses := tally.statuses
+
+	if r.compressLogs {
+		compressPassingLogs(packageResults, statuses)
+	}
+	dedupeLogs(packageResults)
+	failureClusters := clusterFailures(packageResults, statuses)
+
+	// Generate result files
+	r.writeResultFiles(successfulPackages, failedPackages, regressions, hungTests, skippedPackages)
+	r.writeResultsJSON(packageResults)
+	r.recordRun(statuses)
+
+	r.summary = runSummary{
+		Total:       expectedPackages,
+		Tested:      testedCount,
+		Skipped:     skippedPackages,
+		Successful:  successfulPackages,
+		Failed:      failedPackages,
+		Regressions: regressions,
+		Hung:        hungTests,
+		Fixed:       baseline.fixed(statuses),
+
+		ArchExcluded:    tally.archExcluded,
+		Untestable:      tally.untestable,
+		FailureClusters: failureClusters,
+		Memory:          topMemory(packageResults, memoryTopN),
+		Utilization:     measureUtilization(packageResults, time.Since(r.startTime), r.concurrency),
+		ToolingDrift:    toolingDrift(packageResults),
+
+		CandidateChanges: r.candidateWatch.results(packageResults),
+		Backoffs:         r.infra.results(),
+		Infra:            tally.infra,
+		OOMKilled:        tally.oomKilled,
+		Pruned:           r.pruned,
+		Ecosystems:       r.ecosystemBreakdown(statuses),
+		DepKinds:         r.depKindBreakdown(statuses),
+	}
+	if r.gate != nil {
+		gate := evaluateGate(r.gate, statuses)
+		r.summary.Gate = &gate
+	}
+	if r.failureBudget != nil {
+		ecosystemOf := make(map[string]string, len(regressions))
+		for _, pkg := range regressions {
+			ecosystemOf[pkg] = r.packageEcosystem(pkg)
+		}
+		budget := r.failureBudget.evaluate(regressions, ecosystemOf)
+		r.summary.Budget = &budget
+	}
+	r.reportCI(func(w io.Writer) {
+		r.printMarkdownSummary(w, expectedPackages, skippedCount, testedCount, len(regressions), len(hungTests), successCount, failureCount, regressions, hungTests)
+	})
+	if r.inMatrix {
+		return nil
+	}
+
+	if r.markdownOutput {
+		r.printMarkdownSummary(os.Stdout, expectedPackages, skippedCount, testedCount, len(regressions), len(hungTests), successCount, failureCount, regressions, hungTests)
+	} else {
+		fmt.Printf("\n=== Summary ===\n")
+		if r.targetVersion != "" {
+			fmt.Printf("Package: %s\n", r.targetSpec())
+		}
+		printNotes(os.Stdout, r.notes)
+		fmt.Printf("Total packages found: %d\n", expectedPackages)
+		fmt.Printf("Packages skipped (no YAML): %d\n", skippedCount)
+		if len(tally.archExcluded) > 0 {
+			fmt.Printf("Packages skipped (not built for %s): %d\n", hostArch(), len(tally.archExcluded))
+		}
+		if len(tally.untestable) > 0 {
+			fmt.Printf("Packages skipped (no make target): %d\n", len(tally.untestable))
+		}
+		fmt.Printf("Packages tested: %d\n", testedCount)
+		fmt.Printf("Regressions detected: %d\n", len(regressions))
+		fmt.Printf("Hung tests: %d\n", len(hungTests))
+		fmt.Printf("Successful packages: %d\n", successCount)
+		if len(r.unchanged) > 0 {
+			fmt.Printf("Unchanged since an earlier pass (not tested): %d\n", len(r.unchanged))
+		}
+		if len(r.unaffected) > 0 {
+			fmt.Printf("Unaffected in the smoke test (not tested in full): %d\n", len(r.unaffected))
+		}
+		fmt.Printf("Failed packages: %d\n", failureCount)
+		if len(tally.infra) > 0 {
+			fmt.Printf("Infrastructure failures (not counted): %d\n", len(tally.infra))
+		}
+		if len(tally.oomKilled) > 0 {
+			fmt.Printf("Killed for lack of memory (not counted): %d\n", len(tally.oomKilled))
+		}
+		if len(r.pruned) > 0 {
+			fmt.Printf("Pruned (consumers of regressions, not tested): %d\n", len(r.pruned))
+		}
+		if r.versionChange != nil {
+			fmt.Printf("Version change: %s\n", r.versionChange)
+		}
+		if r.advisories != nil {
+			fmt.Printf("Security fixes: %s\n", r.advisories)
+		}
+	}
+
+	if !r.markdownOutput {
+		if len(hungTests) > 0 {
+			fmt.Printf("\nTests that hung:\n")
+			for _, test := range hungTests {
+				fmt.Printf("  - %s, killed after %v\n", test, r.hungTimeout(test))
+			}
+		}
+
+		if len(regressions) > 0 {
+			fmt.Printf("\nPackages with regressions:\n")
+			for _, pkg := range regressions {
+				fmt.Printf("  - %s%s%s%s%s%s\n", pkg, r.regressionNote(pkg, " (%s)"), r.previousNote(pkg, " (%s)"), stepNote(r.packageResults[pkg][true], " in step %s"), toolingNote(r.packageResults[pkg][true], r.packageResults[pkg][false], " (tooling changed: %s)"), r.targetNote(pkg))
+			}
+		}
+		r.printTargetBreakdown(os.Stdout)
+		printEcosystems(os.Stdout, r.summary.Ecosystems)
+		printDepKinds(os.Stdout, r.summary.DepKinds)
+		r.printUntestable(os.Stdout)
+
+		if len(r.summary.Fixed) > 0 {
+			fmt.Printf("\nFixed since last run (%s):\n", r.baseline.Source)
+			for _, pkg := range r.summary.Fixed {
+				fmt.Printf("  - %s\n", pkg)
+			}
+		}
+
+		if len(failureClusters) > 0 {
+			fmt.Printf("\nFailure clusters:\n")
+			for _, cluster := range failureClusters {
+				fmt.Printf("  - %s\n", cluster)
+			}
+		}
+		if len(r.summary.ToolingDrift) > 0 {
+			fmt.Printf("\n⚠️  Tooling changed during the run, which can cause failures of its own:\n")
+			for _, change := range r.summary.ToolingDrift {
+				fmt.Printf("  - %s\n", change)
+			}
+		}
+		printCandidateChanges(os.Stdout, r.summary.CandidateChanges)
+		printInfra(os.Stdout, r.summary.Infra)
+		printOOMKills(os.Stdout, r.summary.OOMKilled, r.concurrency)
+		if len(r.summary.Pruned) > 0 {
+			fmt.Printf("\nPruned consumers of regressions (not tested):\n")
+			for _, pkg := range r.summary.Pruned {
+				fmt.Printf("  - %s%s\n", pkg, parentNote(r.consumerParents[pkg]))
+			}
+		}
+		printBackoffs(os.Stdout, r.summary.Backoffs)
+		printMemory(os.Stdout, r.summary.Memory, r.concurrency)
+		r.summary.Utilization.print(os.Stdout)
+		if r.summary.Gate != nil {
+			r.summary.Gate.print(os.Stdout)
+		}
+		if r.summary.Budget != nil {
+			r.summary.Budget.print(os.Stdout)
+		}
+	}
+
+	if err := r.candidateErr(); err != nil {
+		return err
+	}
+
+	if r.summary.Gate != nil {
+		return r.summary.Gate.err()
+	}
+
+	if r.summary.Budget != nil {
+		if err := r.summary.Budget.err(); err != nil {
+			return err
+		}
+	} else if len(regressions) > 0 {
+		return fmt.Errorf("found %d regressions", len(regressions))
+	}
+
+	if len(hungTests) > 0 {
+		return fmt.Errorf("found %d hung tests", len(hungTests))
+	}
+
+	return nil
+}
+
+func (r *RegressionTestRunner) printMarkdownSummary(w io.Writer, totalPackages, skippedCount, testedCount, regressionsCount, hungCount, successCount, failureCount int, regressions, hungTests []string) {
+	fmt.Fprintf(w, "\n## APK Regression Test Summary\n\n")
+	fmt.Fprintf(w, "**Package:** %s  \n", r.targetSpec())
+	fmt.Fprintf(w, "**APK Repository:** %s  \n", r.apkRepo)
+	fmt.Fprintf(w, "**Test Duration:** %v  \n\n", time.Since(r.startTime).Round(time.Second))
+	printNotesMarkdown(w, r.notes)
+
+	fmt.Fprintf(w, "### Test Results\n\n")
+	fmt.Fprintf(w, "| Metric | Count |\n")
+	fmt.Fprintf(w, "|--------|-------|\n")
+	fmt.Fprintf(w, "| Total packages found | %d |\n", totalPackages)
+	fmt.Fprintf(w, "| Packages skipped (no YAML) | %d |\n", skippedCount)
+	if len(r.summary.ArchExcluded) > 0 {
+		fmt.Fprintf(w, "| Packages skipped (not built for %s) | %d |\n", hostArch(), len(r.summary.ArchExcluded))
+	}
+	if len(r.summary.Untestable) > 0 {
+		fmt.Fprintf(w, "| Packages skipped (no make target) | %d |\n", len(r.summary.Untestable))
+	}
+	fmt.Fprintf(w, "| Packages tested | %d |\n", testedCount)
+	fmt.Fprintf(w, "| **Regressions detected** | **%d** |\n", regressionsCount)
+	fmt.Fprintf(w, "| Hung tests | %d |\n", hungCount)
+	fmt.Fprintf(w, "| Successful packages | %d |\n", successCount)
+	if len(r.unchanged) > 0 {
+		fmt.Fprintf(w, "| Unchanged since an earlier pass (not tested) | %d |\n", len(r.unchanged))
+	}
+	if len(r.unaffected) > 0 {
+		fmt.Fprintf(w, "| Unaffected in the smoke test (not tested in full) | %d |\n", len(r.unaffected))
+	}
+	fmt.Fprintf(w, "| Failed packages | %d |\n", failureCount)
+	if len(r.summary.Infra) > 0 {
+		fmt.Fprintf(w, "| Infrastructure failures (not counted) | %d |\n", len(r.summary.Infra))
+	}
+	if len(r.summary.OOMKilled) > 0 {
+		fmt.Fprintf(w, "| Killed for lack of memory (not counted) | %d |\n", len(r.summary.OOMKilled))
+	}
+	if len(r.summary.Pruned) > 0 {
+		fmt.Fprintf(w, "| Pruned (consumers of regressions, not tested) | %d |\n", len(r.summary.Pruned))
+	}
+
+	if r.versionChange != nil {
+		r.versionChange.printMarkdown(w)
+	}
+	if r.advisories != nil {
+		r.advisories.printMarkdown(w)
+	}
+	if r.summary.Gate != nil {
+		r.summary.Gate.printMarkdown(w)
+	}
+	if r.summary.Budget != nil {
+		r.summary.Budget.printMarkdown(w)
+	}
+
+	if regressionsCount > 0 {
+		fmt.Fprintf(w, "\n### 🔴 Packages with Regressions\n\n")
+		fmt.Fprintf(w, "The following packages **fail with the new APK repository** but **pass without it**, indicating potential regressions:\n\n")
+		for _, pkg := range regressions {
+			fmt.Fprintf(w, "- `%s`%s%s%s%s\n", pkg, r.regressionNote(pkg, " **(%s)**"), r.previousNote(pkg, " (%s)"), r.targetNote(pkg), r.markdownResultDetails(pkg))
+		}
+	}
+	r.printTargetBreakdown(w)
+	printEcosystemsMarkdown(w, r.summary.Ecosystems)
+	printDepKindsMarkdown(w, r.summary.DepKinds)
+	r.printUntestableMarkdown(w)
+
+	if len(r.summary.Fixed) > 0 {
+		fmt.Fprintf(w, "\n### 🟢 Fixed Since Last Run\n\n")
+		fmt.Fprintf(w, "The following packages regressed in the previous run but pass now:\n\n")
+		for _, pkg := range r.summary.Fixed {
+			fmt.Fprintf(w, "- `%s`\n", pkg)
+		}
+	}
+
+	if hungCount > 0 {
+		fmt.Fprintf(w, "\n### ⏰ Tests That Hung\n\n")
+		fmt.Fprintf(w, "The following tests were killed after their timeout:\n\n")
+		for _, test := range hungTests {
+			fmt.Fprintf(w, "- `%s` (after %v)\n", test, r.hungTimeout(test))
+		}
+	}
+
+	if len(r.summary.FailureClusters) > 0 {
+		fmt.Fprintf(w, "\n### 🔁 Failure Clusters\n\n")
+		fmt.Fprintf(w, "These packages failed with the same error, which usually points at a shared cause rather than each package:\n\n")
+		for _, cluster := range r.summary.FailureClusters {
+			fmt.Fprintf(w, "- %s\n", cluster.markdown(r.logLink(cluster.LogPath)))
+		}
+	}
+	if len(r.summary.ToolingDrift) > 0 {
+		fmt.Fprintf(w, "\n### ⚠️ Tooling Changed During the Run\n\n")
+		fmt.Fprintf(w, "Tests ran with different tooling, which can cause failures of its own:\n\n")
+		for _, change := range r.summary.ToolingDrift {
+			fmt.Fprintf(w, "- %s\n", change)
+		}
+	}
+	printCandidateChangesMarkdown(w, r.summary.CandidateChanges)
+	printInfraMarkdown(w, r.summary.Infra)
+	printOOMKillsMarkdown(w, r.summary.OOMKilled, r.concurrency)
+	printBackoffsMarkdown(w, r.summary.Backoffs)
+	printMemoryMarkdown(w, r.summary.Memory)
+	r.summary.Utilization.printMarkdown(w)
+
+	if regressionsCount == 0 && hungCount == 0 {
+		fmt.Fprintf(w, "\n### ✅ All Tests Passed\n\n")
+		fmt.Fprintf(w, "No regressions were detected. All packages either passed with the new repository or failed consistently in both scenarios.\n")
+	}
+
+	fmt.Fprintf(w, "\n---\n")
+	fmt.Fprintf(w, "*Generated by apk-regression-test-runner*\n")
+}
+
+// regressionNote formats whether a regression is new or was already seen in
+// the baseline run, or returns "" without a baseline
+func (r *RegressionTestRunner) regressionNote(pkg, format string) string {
+	note := r.baseline.regressionNote(pkg)
+	if note == "" {
+		return ""
+	}
+	return fmt.Sprintf(format, note)
+}
+
+// markdownResultDetails describes the with-repo test of a package for the
+// markdown summary, e.g.
+// " — exit code 2 after 3m12s in step `python/import` (log: `curl_with_repo.log`)",
+// linking the log when a log URL is set
+func (r *RegressionTestRunner) markdownResultDetails(pkg string) string {
+	result, ok := r.packageResults[pkg][true]
+	if !ok {
+		return ""
+	}
+
+	details := fmt.Sprintf(" — exit code %d after %v%s%s", result.ExitCode, result.Duration.Round(time.Second), stepNote(result, " in step `%s`"), toolingNote(result, r.packageResults[pkg][false], " (tooling changed: %s)"))
+	if link := r.logLink(result.LogPath); link != "" {
+		details += fmt.Sprintf(" (log: [`%s`](%s))", filepath.Base(result.LogPath), link)
+	} else if result.LogPath != "" {
+		details += fmt.Sprintf(" (log: `%s`)", filepath.Base(result.LogPath))
+	}
+	if link := r.logLink(result.WorkspaceSnapshot); link != "" {
+		details += fmt.Sprintf(" (workspace: [`%s`](%s))", filepath.Base(result.WorkspaceSnapshot), link)
+	} else if result.WorkspaceSnapshot != "" {
+		details += fmt.Sprintf(" (workspace: `%s`)", filepath.Base(result.WorkspaceSnapshot))
+	}
+	if link := r.logLink(result.ArtifactsDir); link != "" {
+		details += fmt.Sprintf(" (artifacts: [`%s`](%s))", r.artifactsName(result.ArtifactsDir), link)
+	} else if result.ArtifactsDir != "" {
+		details += fmt.Sprintf(" (artifacts: `%s`)", r.artifactsName(result.ArtifactsDir))
+	}
+	return details
+}
+
+// artifactsName returns an artifact directory relative to the run's log
+// directory, e.g. artifacts/curl/with_repo
+func (r *RegressionTestRunner) artifactsName(dir string) string {
+	if rel, err := filepath.Rel(r.logDir, dir); err == nil {
+		return filepath.ToSlash(rel)
+	}
+	return dir
+}
+
+// writeResultsJSON writes every individual test result to results.json for
+// consumption by downstream tooling
+func (r *RegressionTestRunner) writeResultsJSON(packageResults map[string]map[bool]TestResult) {
+	all := []TestResult{}
+	for _, pkg := range sortedKeys(packageResults) {
+		for _, withRepo := range []bool{true, false} {
+			if result, ok := packageResults[pkg][withRepo]; ok {
+				all = append(all, result)
+			}
+		}
+	}
+
+	data, err := json.MarshalIndent(all, "", "  ")
+	if err != nil {
+		fmt.Printf("Warning: failed to encode results.json: %v\n", err)
+		return
+	}
+
+	if err := os.WriteFile(filepath.Join(r.logDir, "results.json"), append(data, '\n'), 0644); err != nil {
+		fmt.Printf("Warning: failed to write results.json: %v\n", err)
+	}
+}
+
+func (r *RegressionTestRunner) writeResultFiles(successful, failed, regressions, hung, skipped []string) {
+	r.resultFiles.write(r.logDir, false, map[string][]resultEntry{
+		"successful":  resultEntries("", successful),
+		"failed":      resultEntries("", failed),
+		"regressions": resultEntries("", regressions),
+		"hung":        resultEntries("", hung),
+		"skipped":     resultEntries("", skipped),
+	})
 }
@@ -4,9 +4,13 @@
 package internal
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"reflect"
 	"strings"
@@ -408,17 +412,16 @@ func TestLogDirectoryCreation(t *testing.T) {
 
 func TestProgressTracking(t *testing.T) {
 	runner := &RegressionTestRunner{
-		completedTests: 0,
-		totalTests:     10,
-		startTime:      time.Now().Add(-time.Minute), // 1 minute ago
-		verbose:        false,
+		startTime: time.Now().Add(-time.Minute), // 1 minute ago
+		verbose:   false,
 	}
+	runner.progress.SetTotal(10)
 
 	// Test progress update
 	runner.updateProgress()
 	
-	if runner.completedTests != 1 {
-		t.Errorf("Expected completedTests to be 1, got %d", runner.completedTests)
+	if completed := runner.progress.Snapshot().Completed; completed != 1 {
+		t.Errorf("Expected completedTests to be 1, got %d", completed)
 	}
 
 	// Test multiple updates
@@ -426,25 +429,24 @@ func TestProgressTracking(t *testing.T) {
 		runner.updateProgress()
 	}
 	
-	if runner.completedTests != 6 {
-		t.Errorf("Expected completedTests to be 6, got %d", runner.completedTests)
+	if completed := runner.progress.Snapshot().Completed; completed != 6 {
+		t.Errorf("Expected completedTests to be 6, got %d", completed)
 	}
 }
 
 func TestProgressTrackingVerboseMode(t *testing.T) {
 	runner := &RegressionTestRunner{
-		completedTests: 0,
-		totalTests:     10,
-		startTime:      time.Now(),
-		verbose:        true, // In verbose mode, progress updates should be skipped
+		startTime: time.Now(),
+		verbose:   true, // In verbose mode, progress updates should be skipped
 	}
+	runner.progress.SetTotal(10)
 
-	originalCompleted := runner.completedTests
+	originalCompleted := runner.progress.Snapshot().Completed
 	runner.updateProgress()
 	
 	// In verbose mode, completedTests should still be incremented
 	// but no progress display should occur
-	if runner.completedTests != originalCompleted+1 {
+	if runner.progress.Snapshot().Completed != originalCompleted+1 {
 		t.Errorf("Expected completedTests to be incremented even in verbose mode")
 	}
 }
@@ -479,24 +481,414 @@ func TestProgressBoundaryConditions(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			runner := &RegressionTestRunner{
-				completedTests: tt.completedTests,
-				totalTests:     tt.totalTests,
-				startTime:      time.Now(),
-				verbose:        false,
+				startTime: time.Now(),
+				verbose:   false,
 			}
+			runner.progress.SetTotal(tt.totalTests)
+			runner.progress.completed.Store(tt.completedTests)
 
-			originalCompleted := runner.completedTests
+			originalCompleted := runner.progress.Snapshot().Completed
 			runner.updateProgress()
 
 			if tt.shouldUpdate {
-				if runner.completedTests != originalCompleted+1 {
+				if runner.progress.Snapshot().Completed != originalCompleted+1 {
 					t.Errorf("Expected completedTests to be incremented")
 				}
 			} else {
-				if runner.completedTests != originalCompleted {
+				if runner.progress.Snapshot().Completed != originalCompleted {
 					t.Errorf("Expected completedTests to remain unchanged when over total")
 				}
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+
+func TestApplySBOMFilter(t *testing.T) {
+	index := []Package{
+		{Name: "py3-cryptography", Origin: "py3-cryptography"},
+		{Name: "curl", Origin: "curl"},
+		{Name: "libcurl-openssl4", Origin: "curl"},
+		{Name: "nginx", Origin: "nginx"},
+	}
+
+	tests := []struct {
+		name     string
+		restrict bool
+		expected []string
+	}{
+		{
+			name:     "restrict to shipped consumers",
+			restrict: true,
+			expected: []string{"curl"},
+		},
+		{
+			name:     "prioritize shipped consumers",
+			restrict: false,
+			expected: []string{"curl", "nginx", "py3-cryptography"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runner := &RegressionTestRunner{
+				apkrane: &ApkraneClient{packages: index},
+			}
+			runner.SetSBOMPackages([]string{"libcurl-openssl4", "busybox"}, tt.restrict)
+
+			filtered, err := runner.applySBOMFilter([]string{"nginx", "curl", "py3-cryptography"})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(filtered, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, filtered)
+			}
+		})
+	}
+}
+
+func TestNewTestResult(t *testing.T) {
+	exitErr := exec.Command("sh", "-c", "exit 3").Run()
+
+	tests := []struct {
+		name                   string
+		err                    error
+		expectedExitCode       int
+		expectedClassification Classification
+	}{
+		{
+			name:                   "success",
+			err:                    nil,
+			expectedExitCode:       0,
+			expectedClassification: ClassificationPass,
+		},
+		{
+			name:                   "failed with exit code",
+			err:                    fmt.Errorf("make test/foo failed: %w", exitErr),
+			expectedExitCode:       3,
+			expectedClassification: ClassificationFail,
+		},
+		{
+			name:                   "hung",
+			err:                    ErrTestHung,
+			expectedExitCode:       -1,
+			expectedClassification: ClassificationHung,
+		},
+		{
+			name:                   "skipped",
+			err:                    ErrPackageYAMLNotFound,
+			expectedExitCode:       -1,
+			expectedClassification: ClassificationSkipped,
+		},
+		{
+			name:                   "could not start",
+			err:                    errors.New("failed to start make test/foo"),
+			expectedExitCode:       -1,
+			expectedClassification: ClassificationError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			startedAt := time.Now().Add(-time.Minute)
+			result := newTestResult("foo", true, startedAt, "/logs/foo_with_repo.log", tt.err)
+
+			if result.ExitCode != tt.expectedExitCode {
+				t.Errorf("Expected ExitCode=%d, got %d", tt.expectedExitCode, result.ExitCode)
+			}
+			if result.Classification != tt.expectedClassification {
+				t.Errorf("Expected Classification=%s, got %s", tt.expectedClassification, result.Classification)
+			}
+			if result.Duration < time.Minute {
+				t.Errorf("Expected Duration of at least 1m, got %v", result.Duration)
+			}
+			if !result.StartedAt.Equal(startedAt) {
+				t.Errorf("Expected StartedAt=%v, got %v", startedAt, result.StartedAt)
+			}
+			if result.LogPath != "/logs/foo_with_repo.log" {
+				t.Errorf("Expected LogPath to be set, got %s", result.LogPath)
+			}
+		})
+	}
+}
+
+func TestWriteResultsJSON(t *testing.T) {
+	tmpDir := t.TempDir()
+	runner := &RegressionTestRunner{logDir: tmpDir}
+
+	packageResults := map[string]map[bool]TestResult{
+		"curl": {
+			true:  {Package: "curl", WithRepo: true, Error: ErrTestHung, Hung: true, Classification: ClassificationHung},
+			false: {Package: "curl", WithRepo: false, Success: true, Classification: ClassificationPass},
+		},
+	}
+	runner.writeResultsJSON(packageResults)
+
+	data, err := os.ReadFile(filepath.Join(tmpDir, "results.json"))
+	if err != nil {
+		t.Fatalf("Failed to read results.json: %v", err)
+	}
+
+	var decoded []map[string]interface{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Failed to decode results.json: %v", err)
+	}
+
+	if len(decoded) != 2 {
+		t.Fatalf("Expected 2 results, got %d", len(decoded))
+	}
+	if decoded[0]["withRepo"] != true || decoded[0]["error"] != ErrTestHung.Error() || decoded[0]["classification"] != "hung" {
+		t.Errorf("Unexpected first result: %v", decoded[0])
+	}
+	if _, ok := decoded[1]["error"]; ok {
+		t.Errorf("Expected no error field for a passing test, got %v", decoded[1])
+	}
+}
+
+func TestLogLink(t *testing.T) {
+	tests := []struct {
+		name     string
+		logURL   string
+		logDir   string
+		inMatrix bool
+		logPath  string
+		expected string
+	}{
+		{
+			name:     "no log URL",
+			logDir:   "logs/regression-test-openssl-20250106-120000",
+			logPath:  "logs/regression-test-openssl-20250106-120000/curl_with_repo.log",
+			expected: "",
+		},
+		{
+			name:     "single run",
+			logURL:   "https://storage.example/ci/logs/",
+			logDir:   "logs/regression-test-openssl-20250106-120000",
+			logPath:  "logs/regression-test-openssl-20250106-120000/curl_with_repo.log",
+			expected: "https://storage.example/ci/logs/regression-test-openssl-20250106-120000/curl_with_repo.log",
+		},
+		{
+			name:     "matrix run",
+			logURL:   "https://storage.example/ci/logs",
+			logDir:   "logs/regression-test-openssl-20250106-120000/wolfi",
+			inMatrix: true,
+			logPath:  "logs/regression-test-openssl-20250106-120000/wolfi/c++utils_with_repo.log",
+			expected: "https://storage.example/ci/logs/regression-test-openssl-20250106-120000/wolfi/c++utils_with_repo.log",
+		},
+		{
+			name:     "log outside the logs directory",
+			logURL:   "https://storage.example/ci/logs",
+			logDir:   "logs/regression-test-openssl-20250106-120000",
+			logPath:  "/tmp/curl_with_repo.log",
+			expected: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runner := &RegressionTestRunner{logDir: tt.logDir, inMatrix: tt.inMatrix}
+			runner.SetLogURL(tt.logURL)
+			if link := runner.logLink(tt.logPath); link != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, link)
+			}
+		})
+	}
+}
+
+func TestMarkdownResultDetailsLinksLog(t *testing.T) {
+	runner := &RegressionTestRunner{
+		logDir: "logs/run",
+		packageResults: map[string]map[bool]TestResult{
+			"curl": {true: {ExitCode: 2, Duration: time.Minute, LogPath: "logs/run/curl_with_repo.log"}},
+		},
+	}
+
+	expected := " — exit code 2 after 1m0s (log: `curl_with_repo.log`)"
+	if details := runner.markdownResultDetails("curl"); details != expected {
+		t.Errorf("Expected %q, got %q", expected, details)
+	}
+
+	runner.SetLogURL("https://logs.example")
+	expected = " — exit code 2 after 1m0s (log: [`curl_with_repo.log`](https://logs.example/run/curl_with_repo.log))"
+	if details := runner.markdownResultDetails("curl"); details != expected {
+		t.Errorf("Expected %q, got %q", expected, details)
+	}
+}
+
+func TestAnalyzeResultsReportsPackagesAsTheyComplete(t *testing.T) {
+	runner := &RegressionTestRunner{logDir: t.TempDir(), inMatrix: true, hangTimeout: time.Minute}
+
+	results := make(chan TestResult)
+	done := make(chan error)
+	go func() {
+		done <- runner.analyzeResults(results, 5)
+	}()
+
+	results <- TestResult{Package: "curl", WithRepo: true, Success: true}
+	results <- TestResult{Package: "git", WithRepo: true}
+	results <- TestResult{Package: "jq", WithRepo: true, Hung: true}
+	results <- TestResult{Package: "git", WithRepo: false, Success: true}
+	results <- TestResult{Package: "jq", WithRepo: false, Hung: true}
+	// The without-repo test of wget never reported
+	results <- TestResult{Package: "wget", WithRepo: true}
+	close(results)
+
+	if err := <-done; err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	summary := runner.summary
+	if !reflect.DeepEqual(summary.Successful, []string{"curl"}) {
+		t.Errorf("Expected curl to pass, got %v", summary.Successful)
+	}
+	if !reflect.DeepEqual(summary.Regressions, []string{"git"}) {
+		t.Errorf("Expected git to regress, got %v", summary.Regressions)
+	}
+	if expected := []string{"jq (with repo)", "jq (without repo)"}; !reflect.DeepEqual(summary.Hung, expected) {
+		t.Errorf("Expected hung tests %v, got %v", expected, summary.Hung)
+	}
+	if len(summary.Failed) != 0 {
+		t.Errorf("Expected no failures, got %v", summary.Failed)
+	}
+}
+
+func TestTestPackageCountsSkippedRetry(t *testing.T) {
+	runner := NewRegressionTestRunner("openssl", "https://example.com/repo", t.TempDir(), "wolfi", 1, false, time.Minute, false)
+	runner.eta = newETAEstimator([]string{"curl"}, nil, 1)
+	runner.durations = make(map[string]time.Duration)
+	runner.progress.SetTotal(1)
+	executor := TestExecutorFunc(func(ctx context.Context, packageName string, opts ExecuteOptions) TestResult {
+		// The without-repo test is skipped, e.g. its YAML file went missing
+		return TestResult{Package: packageName, WithRepo: opts.WithRepo, Skipped: !opts.WithRepo}
+	})
+
+	results := make(chan TestResult, 2)
+	runner.testPackage(context.Background(), "curl", executor, "", results)
+	close(results)
+
+	progress := runner.progress.Snapshot()
+	if progress.Completed != 1 {
+		t.Errorf("Expected the package to be completed, got %d", progress.Completed)
+	}
+	if progress.Failed != 1 {
+		t.Errorf("Expected the package to be counted as failed, got %q", progress.Counts())
+	}
+}
+
+func TestMarkdownSummaryReportsHangTimeoutPerPackage(t *testing.T) {
+	runner := &RegressionTestRunner{
+		packageName:    "openssl",
+		hangTimeout:    30 * time.Minute,
+		packageOptions: map[string]PackageOptions{"llvm": {Timeout: 2 * time.Hour}},
+	}
+
+	var out bytes.Buffer
+	hung := []string{"llvm (with repo)", "curl (without repo)"}
+	runner.printMarkdownSummary(&out, 2, 0, 2, 0, len(hung), 0, 0, nil, hung)
+	for _, expected := range []string{"- `llvm (with repo)` (after 2h0m0s)", "- `curl (without repo)` (after 30m0s)"} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("Expected %q in the summary, got:\n%s", expected, out.String())
+		}
+	}
+}
+
+func TestRunWithoutReverseDependencies(t *testing.T) {
+	for _, failOnEmpty := range []bool{false, true} {
+		runner := NewRegressionTestRunner("leaf", "https://example.com/repo", t.TempDir(), "wolfi", 2, false, time.Minute, false)
+		runner.setLogDir(t.TempDir())
+		runner.SetAdvisoryCheck(false)
+		runner.SetFailOnEmpty(failOnEmpty)
+		runner.apkrane.packages = []Package{{Name: "leaf", Origin: "leaf"}, {Name: "curl", Origin: "curl"}}
+
+		err := runner.Run()
+		if failOnEmpty && !errors.Is(err, ErrNoReverseDependencies) {
+			t.Errorf("Expected ErrNoReverseDependencies with --fail-on-empty, got %v", err)
+		}
+		if !failOnEmpty && err != nil {
+			t.Errorf("Expected no error, got %v", err)
+		}
+
+		manifest, err := LoadRunManifest(runner.logDir)
+		if err != nil {
+			t.Fatalf("Expected a run manifest, got %v", err)
+		}
+		if !manifest.NoReverseDependencies || manifest.Packages == nil {
+			t.Errorf("Expected the manifest to record that nothing was tested, got %+v", manifest)
+		}
+		data, err := os.ReadFile(filepath.Join(runner.logDir, "results.json"))
+		if err != nil || strings.TrimSpace(string(data)) != "[]" {
+			t.Errorf("Expected empty results.json, got %q (%v)", data, err)
+		}
+		if _, err := os.Stat(filepath.Join(runner.logDir, "regressions.txt")); err != nil {
+			t.Errorf("Expected regressions.txt to be written: %v", err)
+		}
+	}
+}
+
+func TestRunnerChunks(t *testing.T) {
+	repoPath := t.TempDir()
+	packages := []string{"curl", "git", "wget"}
+	for _, pkg := range packages {
+		if err := os.WriteFile(filepath.Join(repoPath, pkg+".yaml"), []byte("package:\n  name: "+pkg+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	runner := NewRegressionTestRunner("openssl", "https://example.com/repo", repoPath, "wolfi", 2, false, time.Minute, false)
+	logDir := t.TempDir()
+	runner.setLogDir(logDir)
+	runner.SetChunkSize(2)
+
+	// The package of the second chunk sees the results of the first
+	var flushed []byte
+	runner.SetExecutor(TestExecutorFunc(func(ctx context.Context, pkg string, opts ExecuteOptions) TestResult {
+		if pkg == "wget" && opts.WithRepo {
+			flushed, _ = os.ReadFile(filepath.Join(logDir, "successful.txt"))
+		}
+		return newTestResult(pkg, opts.WithRepo, time.Now(), "", nil)
+	}))
+
+	if err := runner.RunFromPackageList(packages); err != nil {
+		t.Fatal(err)
+	}
+
+	if string(flushed) != "curl\ngit\n" {
+		t.Errorf("Expected the first chunk's results before the second chunk, got %q", flushed)
+	}
+	if runner.chunksDone != 1 {
+		t.Errorf("Expected 1 intermediate flush, got %d", runner.chunksDone)
+	}
+}
+
+func TestRunnerArchExcluded(t *testing.T) {
+	repoPath := t.TempDir()
+	packages := []string{"curl", "firmware"}
+	for _, pkg := range packages {
+		if err := os.WriteFile(filepath.Join(repoPath, pkg+".yaml"), []byte("package:\n  name: "+pkg+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	runner := NewRegressionTestRunner("openssl", "https://example.com/repo", repoPath, "wolfi", 2, false, time.Minute, false)
+	runner.setLogDir(t.TempDir())
+	runner.SetExecutor(TestExecutorFunc(func(ctx context.Context, pkg string, opts ExecuteOptions) TestResult {
+		var err error
+		if pkg == "firmware" {
+			err = fmt.Errorf("%w: firmware is only built for riscv64", ErrArchExcluded)
+		}
+		return newTestResult(pkg, opts.WithRepo, time.Now(), "", err)
+	}))
+
+	if err := runner.RunFromPackageList(packages); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(runner.summary.ArchExcluded, []string{"firmware"}) {
+		t.Errorf("Expected firmware to be excluded, got %v", runner.summary.ArchExcluded)
+	}
+	if len(runner.summary.Skipped) != 0 || len(runner.summary.Failed) != 0 {
+		t.Errorf("Expected no skipped or failed packages, got %v and %v", runner.summary.Skipped, runner.summary.Failed)
+	}
+	if runner.summary.Tested != 1 {
+		t.Errorf("Expected 1 tested package, got %d", runner.summary.Tested)
+	}
+}
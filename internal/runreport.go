@@ -0,0 +1,369 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Formats a stored run can be reported in
+const (
+	ReportMarkdown = "markdown"
+	ReportHTML     = "html"
+	ReportJSON     = "json"
+)
+
+// reportFormats are the formats a stored run can be reported in
+var reportFormats = []string{ReportMarkdown, ReportHTML, ReportJSON}
+
+// RunReport is the outcome of a run as recorded in the results database,
+// which outlives its log directory
+type RunReport struct {
+	RunID         string    `json:"runId"`
+	Target        string    `json:"target"`
+	TargetVersion string    `json:"targetVersion,omitempty"`
+	APKRepo       string    `json:"apkRepo"`
+	LogDir        string    `json:"logDir"`
+	LogsPruned    bool      `json:"logsPruned"`
+	StartedAt     time.Time `json:"startedAt"`
+	FinishedAt    time.Time `json:"finishedAt"`
+	Duration      string    `json:"duration"`
+	// Labels are the repository types and variants of a test matrix the
+	// packages are tagged with, e.g. "curl (wolfi)"
+	Labels []string `json:"labels,omitempty"`
+	Total  int      `json:"total"`
+	Tested int      `json:"tested"`
+	// Packages are the packages by status, see StatusPass
+	Packages map[string][]string `json:"packages"`
+	// Triage maps the regressions to their triage verdicts, if triaged
+	Triage map[string]string `json:"triage,omitempty"`
+	Notes  []string          `json:"notes,omitempty"`
+}
+
+// runDir returns the log directory of the run a record belongs to: its own,
+// or that of the test matrix it is a runner of, which logs to
+// <dir>/<repository type>[/<variant>]
+func runDir(run RunRecord) string {
+	dir := filepath.Clean(run.LogDir)
+	if run.Variant != "" {
+		dir = filepath.Dir(dir)
+	}
+	if run.RepoType != "" && filepath.Base(dir) == run.RepoType {
+		dir = filepath.Dir(dir)
+	}
+	return dir
+}
+
+// FindRun returns the records of the run with the ID or log directory id:
+// one, or one per repository type and variant of a test matrix
+func (db *ResultsDB) FindRun(id string) ([]RunRecord, error) {
+	runs, err := db.Runs()
+	if err != nil {
+		return nil, err
+	}
+
+	byDir := make(map[string][]RunRecord)
+	for _, run := range runs {
+		dir := runDir(run)
+		if filepath.Base(dir) == id || dir == filepath.Clean(id) {
+			byDir[dir] = append(byDir[dir], run)
+		}
+	}
+	dirs := sortedKeys(byDir)
+	switch len(dirs) {
+	case 0:
+		return nil, fmt.Errorf("no run %s in the results database %s", id, db.path)
+	case 1:
+		return byDir[dirs[0]], nil
+	}
+	return nil, fmt.Errorf("%d runs in the results database have the ID %s, pass the log directory of one: %s", len(dirs), id, strings.Join(dirs, ", "))
+}
+
+// NewRunReport builds the report of the records of a run (see FindRun),
+// with the triage verdicts of its regressions
+func NewRunReport(records []RunRecord, decisions []TriageDecision) *RunReport {
+	first := records[0]
+	dir := runDir(first)
+	report := &RunReport{
+		RunID:         filepath.Base(dir),
+		Target:        first.Target,
+		TargetVersion: first.TargetVersion,
+		APKRepo:       first.APKRepo,
+		LogDir:        dir,
+		StartedAt:     first.StartedAt,
+		FinishedAt:    first.FinishedAt,
+		Packages:      make(map[string][]string),
+		Notes:         first.Notes,
+	}
+	if _, err := os.Stat(dir); err != nil {
+		report.LogsPruned = true
+	}
+
+	verdicts := make(map[string]string)
+	for _, decision := range decisions {
+		if decision.RunID == report.RunID || decision.RunID == first.RunID {
+			// Later decisions override earlier ones
+			verdicts[decision.RepoType+"/"+decision.Package] = decision.Verdict
+		}
+	}
+
+	matrix := len(records) > 1 || filepath.Clean(first.LogDir) != dir
+	for _, run := range records {
+		if run.StartedAt.Before(report.StartedAt) {
+			report.StartedAt = run.StartedAt
+		}
+		if run.FinishedAt.After(report.FinishedAt) {
+			report.FinishedAt = run.FinishedAt
+		}
+		label := run.RepoType
+		if run.Variant != "" {
+			label = strings.TrimSpace(run.RepoType + " " + run.Variant)
+		}
+		if matrix {
+			report.Labels = append(report.Labels, label)
+		}
+
+		for _, pkg := range run.Packages {
+			name := pkg.Package
+			if matrix {
+				name = tagPackages([]string{name}, label)[0]
+			}
+			report.Packages[pkg.Status] = append(report.Packages[pkg.Status], name)
+			report.Total++
+			switch pkg.Status {
+			case StatusPass, StatusFail, StatusRegression, StatusHung:
+				report.Tested++
+			}
+			if pkg.Status != StatusRegression {
+				continue
+			}
+			verdict, ok := verdicts[run.RepoType+"/"+pkg.Package]
+			if !ok {
+				verdict, ok = verdicts["/"+pkg.Package]
+			}
+			if ok {
+				if report.Triage == nil {
+					report.Triage = make(map[string]string)
+				}
+				report.Triage[name] = verdict
+			}
+		}
+	}
+	for _, packages := range report.Packages {
+		sort.Strings(packages)
+	}
+	report.Duration = report.FinishedAt.Sub(report.StartedAt).Round(time.Second).String()
+	return report
+}
+
+// ValidateReportFormat checks that a stored run can be reported in format
+func ValidateReportFormat(format string) error {
+	if !isOneOf(reportFormats, format) {
+		return fmt.Errorf("invalid report format: %s (must be %s)", format, strings.Join(reportFormats, ", "))
+	}
+	return nil
+}
+
+// Write renders the report in format: markdown, html or json
+func (r *RunReport) Write(w io.Writer, format string) error {
+	if err := ValidateReportFormat(format); err != nil {
+		return err
+	}
+	switch format {
+	case ReportHTML:
+		return runReportHTML.Execute(w, r)
+	case ReportJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(r)
+	}
+	r.writeMarkdown(w)
+	return nil
+}
+
+// WriteRunList lists the latest limit runs in the results database, newest
+// first, or all if limit is 0
+func WriteRunList(w io.Writer, runs []RunRecord, limit int) error {
+	byDir := make(map[string][]RunRecord)
+	for _, run := range runs {
+		dir := runDir(run)
+		byDir[dir] = append(byDir[dir], run)
+	}
+	reports := make([]*RunReport, 0, len(byDir))
+	for _, records := range byDir {
+		reports = append(reports, NewRunReport(records, nil))
+	}
+	sort.Slice(reports, func(i, j int) bool { return reports[i].StartedAt.After(reports[j].StartedAt) })
+	if limit > 0 && len(reports) > limit {
+		reports = reports[:limit]
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "RUN\tPACKAGE\tSTARTED\tTESTED\tREGRESSIONS\tLOGS")
+	for _, report := range reports {
+		logs := "kept"
+		if report.LogsPruned {
+			logs = "pruned"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%d\t%s\n", report.RunID, report.targetSpec(), report.StartedAt.Format("2006-01-02 15:04"), report.Tested, len(report.Packages[StatusRegression]), logs)
+	}
+	return tw.Flush()
+}
+
+// targetSpec returns the package the run tested, with its version if the run
+// was tied to one
+func (r *RunReport) targetSpec() string {
+	if r.TargetVersion == "" {
+		return r.Target
+	}
+	return r.Target + "@" + r.TargetVersion
+}
+
+// reportSection is a list of packages of one status in the report
+type reportSection struct {
+	Status      string
+	Title       string
+	Description string
+}
+
+// reportSections are the statuses the report lists the packages of, in order
+var reportSections = []reportSection{
+	{StatusRegression, "🔴 Packages with Regressions", "These packages failed with the candidate repository but passed without it:"},
+	{StatusHung, "⏰ Tests That Hung", "These tests were killed after the hang timeout:"},
+	{StatusFail, "❌ Failed Packages", "These packages failed with and without the candidate repository:"},
+	{StatusInfra, "🏗️ Infrastructure Failures", "These tests failed on the infrastructure rather than on their own:"},
+	{StatusUntestable, "⏭️ Packages Without a Make Target", "The Makefile had no test target for these packages:"},
+}
+
+// Sections returns the sections of the report that list packages
+func (r *RunReport) Sections() []reportSection {
+	var sections []reportSection
+	for _, section := range reportSections {
+		if len(r.Packages[section.Status]) > 0 {
+			sections = append(sections, section)
+		}
+	}
+	return sections
+}
+
+// Counts returns the rows of the results table
+func (r *RunReport) Counts() [][2]string {
+	rows := [][2]string{
+		{"Total packages found", fmt.Sprint(r.Total)},
+		{"Packages skipped (no YAML)", fmt.Sprint(len(r.Packages[StatusSkipped]))},
+	}
+	if n := len(r.Packages[StatusArchExcluded]); n > 0 {
+		rows = append(rows, [2]string{"Packages skipped (not built for the architecture)", fmt.Sprint(n)})
+	}
+	if n := len(r.Packages[StatusUntestable]); n > 0 {
+		rows = append(rows, [2]string{"Packages skipped (no make target)", fmt.Sprint(n)})
+	}
+	rows = append(rows,
+		[2]string{"Packages tested", fmt.Sprint(r.Tested)},
+		[2]string{"Regressions detected", fmt.Sprint(len(r.Packages[StatusRegression]))},
+		[2]string{"Hung tests", fmt.Sprint(len(r.Packages[StatusHung]))},
+		[2]string{"Successful packages", fmt.Sprint(len(r.Packages[StatusPass]))},
+		[2]string{"Failed packages", fmt.Sprint(len(r.Packages[StatusFail]))},
+	)
+	if n := len(r.Packages[StatusInfra]); n > 0 {
+		rows = append(rows, [2]string{"Infrastructure failures (not counted)", fmt.Sprint(n)})
+	}
+	return rows
+}
+
+// writeMarkdown renders the report like the markdown summary of the run
+func (r *RunReport) writeMarkdown(w io.Writer) {
+	fmt.Fprintf(w, "## APK Regression Test Summary\n\n")
+	fmt.Fprintf(w, "**Run:** %s  \n", r.RunID)
+	fmt.Fprintf(w, "**Package:** %s  \n", r.targetSpec())
+	fmt.Fprintf(w, "**APK Repository:** %s  \n", r.APKRepo)
+	if len(r.Labels) > 0 {
+		fmt.Fprintf(w, "**Tested in:** %s  \n", strings.Join(r.Labels, ", "))
+	}
+	fmt.Fprintf(w, "**Started:** %s  \n", r.StartedAt.Format(time.RFC3339))
+	fmt.Fprintf(w, "**Test Duration:** %s  \n\n", r.Duration)
+	if r.LogsPruned {
+		fmt.Fprintf(w, "> The log directory `%s` is not available; this report is from the results database.\n\n", r.LogDir)
+	}
+	printNotesMarkdown(w, r.Notes)
+
+	fmt.Fprintf(w, "### Test Results\n\n")
+	fmt.Fprintf(w, "| Metric | Count |\n")
+	fmt.Fprintf(w, "|--------|-------|\n")
+	for _, row := range r.Counts() {
+		fmt.Fprintf(w, "| %s | %s |\n", row[0], row[1])
+	}
+
+	for _, section := range r.Sections() {
+		fmt.Fprintf(w, "\n### %s\n\n%s\n\n", section.Title, section.Description)
+		for _, pkg := range r.Packages[section.Status] {
+			if verdict, ok := r.Triage[pkg]; ok {
+				fmt.Fprintf(w, "- `%s` (triaged: %s)\n", pkg, verdict)
+			} else {
+				fmt.Fprintf(w, "- `%s`\n", pkg)
+			}
+		}
+	}
+}
+
+// runReportHTML renders the report as a standalone HTML page
+var runReportHTML = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>apkregress {{.RunID}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; }
+td, th { border: 1px solid #ccc; padding: 0.3em 0.8em; text-align: left; }
+.note { color: #555; }
+</style>
+</head>
+<body>
+<h1>APK Regression Test Summary</h1>
+<p>
+<b>Run:</b> {{.RunID}}<br>
+<b>Package:</b> {{.Target}}{{if .TargetVersion}}@{{.TargetVersion}}{{end}}<br>
+<b>APK Repository:</b> {{.APKRepo}}<br>
+{{- if .Labels}}
+<b>Tested in:</b> {{range $i, $label := .Labels}}{{if $i}}, {{end}}{{$label}}{{end}}<br>
+{{- end}}
+<b>Started:</b> {{.StartedAt.Format "2006-01-02T15:04:05Z07:00"}}<br>
+<b>Test Duration:</b> {{.Duration}}
+</p>
+{{- if .LogsPruned}}
+<p class="note">The log directory <code>{{.LogDir}}</code> is not available; this report is from the results database.</p>
+{{- end}}
+{{- range .Notes}}
+<blockquote>📝 {{.}}</blockquote>
+{{- end}}
+<h2>Test Results</h2>
+<table>
+<tr><th>Metric</th><th>Count</th></tr>
+{{- range .Counts}}
+<tr><td>{{index . 0}}</td><td>{{index . 1}}</td></tr>
+{{- end}}
+</table>
+{{- $report := .}}
+{{- range .Sections}}
+<h2>{{.Title}}</h2>
+<p>{{.Description}}</p>
+<ul>
+{{- range index $report.Packages .Status}}
+<li><code>{{.}}</code>{{with index $report.Triage .}} (triaged: {{.}}){{end}}</li>
+{{- end}}
+</ul>
+{{- end}}
+</body>
+</html>
+`))
@@ -0,0 +1,159 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newReportTestDB(t *testing.T, runs ...RunRecord) *ResultsDB {
+	t.Helper()
+	db, err := NewResultsDB(filepath.Join(t.TempDir(), "results.jsonl"))
+	if err != nil {
+		t.Fatalf("Failed to open results database: %v", err)
+	}
+	for _, run := range runs {
+		if err := db.Append(run); err != nil {
+			t.Fatalf("Failed to record run: %v", err)
+		}
+	}
+	return db
+}
+
+func TestFindRun(t *testing.T) {
+	start := time.Date(2025, 1, 6, 12, 0, 0, 0, time.UTC)
+	db := newReportTestDB(t,
+		RunRecord{RunID: "regression-test-openssl-1", Target: "openssl", LogDir: "logs/regression-test-openssl-1", StartedAt: start},
+		RunRecord{RunID: "wolfi", Target: "zlib", RepoType: "wolfi", LogDir: "logs/regression-test-zlib-2/wolfi", StartedAt: start},
+		RunRecord{RunID: "fips-on", Target: "zlib", RepoType: "enterprise", Variant: "fips=on", LogDir: "logs/regression-test-zlib-2/enterprise/fips-on", StartedAt: start},
+		RunRecord{RunID: "regression-test-curl-3", Target: "curl", LogDir: "a/regression-test-curl-3", StartedAt: start},
+		RunRecord{RunID: "regression-test-curl-3", Target: "curl", LogDir: "b/regression-test-curl-3", StartedAt: start},
+	)
+
+	tests := []struct {
+		id            string
+		expectedDirs  []string
+		expectedError string
+	}{
+		{id: "regression-test-openssl-1", expectedDirs: []string{"logs/regression-test-openssl-1"}},
+		{id: "logs/regression-test-openssl-1/", expectedDirs: []string{"logs/regression-test-openssl-1"}},
+		{id: "regression-test-zlib-2", expectedDirs: []string{"logs/regression-test-zlib-2/wolfi", "logs/regression-test-zlib-2/enterprise/fips-on"}},
+		{id: "b/regression-test-curl-3", expectedDirs: []string{"b/regression-test-curl-3"}},
+		{id: "regression-test-curl-3", expectedError: "2 runs in the results database have the ID regression-test-curl-3, pass the log directory of one: a/regression-test-curl-3, b/regression-test-curl-3"},
+		{id: "wolfi", expectedError: "no run wolfi in the results database"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.id, func(t *testing.T) {
+			records, err := db.FindRun(tt.id)
+			if tt.expectedError != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.expectedError) {
+					t.Errorf("Expected an error containing %q, got %v", tt.expectedError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			var dirs []string
+			for _, record := range records {
+				dirs = append(dirs, record.LogDir)
+			}
+			if !reflect.DeepEqual(dirs, tt.expectedDirs) {
+				t.Errorf("Expected runs in %v, got %v", tt.expectedDirs, dirs)
+			}
+		})
+	}
+}
+
+func TestNewRunReport(t *testing.T) {
+	logDir := t.TempDir()
+	start := time.Date(2025, 1, 6, 12, 0, 0, 0, time.UTC)
+	records := []RunRecord{
+		{
+			RunID: "wolfi", Target: "openssl", TargetVersion: "3.3.2-r1", RepoType: "wolfi",
+			LogDir: filepath.Join(logDir, "wolfi"), StartedAt: start, FinishedAt: start.Add(10 * time.Minute),
+			Packages: []PackageRecord{{Package: "curl", Status: StatusRegression}, {Package: "git", Status: StatusPass}, {Package: "foo", Status: StatusSkipped}},
+		},
+		{
+			RunID: "enterprise", Target: "openssl", TargetVersion: "3.3.2-r1", RepoType: "enterprise",
+			LogDir: filepath.Join(logDir, "enterprise"), StartedAt: start, FinishedAt: start.Add(30 * time.Minute),
+			Packages: []PackageRecord{{Package: "curl", Status: StatusRegression}, {Package: "wget", Status: StatusHung}},
+		},
+	}
+	decisions := []TriageDecision{
+		{Package: "curl", RepoType: "wolfi", RunID: filepath.Base(logDir), Verdict: VerdictFlaky},
+		{Package: "curl", RepoType: "wolfi", RunID: filepath.Base(logDir), Verdict: VerdictReal},
+		{Package: "curl", RepoType: "enterprise", RunID: "another-run", Verdict: VerdictKnown},
+	}
+
+	report := NewRunReport(records, decisions)
+	if report.RunID != filepath.Base(logDir) || report.LogDir != logDir || report.LogsPruned {
+		t.Errorf("Expected run %s in %s with its logs, got %s in %s (pruned: %v)", filepath.Base(logDir), logDir, report.RunID, report.LogDir, report.LogsPruned)
+	}
+	if report.Duration != "30m0s" || report.Total != 5 || report.Tested != 4 {
+		t.Errorf("Expected 5 packages, 4 tested in 30m0s, got %d, %d in %s", report.Total, report.Tested, report.Duration)
+	}
+	expectedPackages := map[string][]string{
+		StatusRegression: {"curl (enterprise)", "curl (wolfi)"},
+		StatusPass:       {"git (wolfi)"},
+		StatusSkipped:    {"foo (wolfi)"},
+		StatusHung:       {"wget (enterprise)"},
+	}
+	if !reflect.DeepEqual(report.Packages, expectedPackages) {
+		t.Errorf("Expected packages %v, got %v", expectedPackages, report.Packages)
+	}
+	if expected := map[string]string{"curl (wolfi)": VerdictReal}; !reflect.DeepEqual(report.Triage, expected) {
+		t.Errorf("Expected triage %v, got %v", expected, report.Triage)
+	}
+}
+
+func TestRunReportWrite(t *testing.T) {
+	start := time.Date(2025, 1, 6, 12, 0, 0, 0, time.UTC)
+	report := NewRunReport([]RunRecord{{
+		RunID: "regression-test-openssl-1", Target: "openssl", TargetVersion: "3.3.2-r1", APKRepo: "https://example.com/repo",
+		LogDir: filepath.Join(t.TempDir(), "pruned"), StartedAt: start, FinishedAt: start.Add(time.Hour),
+		Packages: []PackageRecord{{Package: "curl<x>", Status: StatusRegression}, {Package: "git", Status: StatusPass}},
+	}}, nil)
+
+	tests := []struct {
+		format   string
+		expected []string
+	}{
+		{ReportMarkdown, []string{"**Package:** openssl@3.3.2-r1", "is not available; this report is from the results database", "| Regressions detected | 1 |", "- `curl<x>`"}},
+		{ReportHTML, []string{"<b>Package:</b> openssl@3.3.2-r1", "<tr><td>Regressions detected</td><td>1</td></tr>", "<li><code>curl&lt;x&gt;</code></li>"}},
+		{ReportJSON, []string{`"targetVersion": "3.3.2-r1"`, `"logsPruned": true`, `"regression": [`}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var out bytes.Buffer
+			if err := report.Write(&out, tt.format); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			for _, expected := range tt.expected {
+				if !strings.Contains(out.String(), expected) {
+					t.Errorf("Expected %q in the report, got:\n%s", expected, out.String())
+				}
+			}
+		})
+	}
+
+	var out bytes.Buffer
+	report.Write(&out, ReportJSON)
+	var decoded RunReport
+	if err := json.Unmarshal(out.Bytes(), &decoded); err != nil || !reflect.DeepEqual(decoded.Packages, report.Packages) {
+		t.Errorf("Expected the JSON report to decode to the same packages, got %v (%v)", decoded.Packages, err)
+	}
+
+	if err := report.Write(&out, "pdf"); err == nil || !strings.Contains(err.Error(), "invalid report format: pdf") {
+		t.Errorf("Expected an invalid format error, got %v", err)
+	}
+}
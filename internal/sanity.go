@@ -0,0 +1,61 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrTargetBroken indicates that a target package fails its own test with
+// the candidate repository, so that its consumers weren't tested
+var ErrTargetBroken = errors.New("target package fails its own test with the candidate repository")
+
+// SetForce tests the reverse dependencies of target packages that fail their
+// own test with the candidate repository, instead of refusing to
+func (r *RegressionTestRunner) SetForce(force bool) {
+	r.force = force
+}
+
+// checkTargets runs the test of each target package with the candidate
+// repository before its reverse dependencies are tested, since testing
+// hundreds of consumers of a package that is broken itself wastes hours to
+// report what one test shows. Targets without a config in the package
+// repository can't be tested and are left out, as are failures on the
+// infrastructure.
+func (r *RegressionTestRunner) checkTargets(executor TestExecutor) error {
+	for _, target := range r.sanityTargets {
+		if r.melange != nil {
+			if _, err := r.melange.locator.Locate(target); err != nil {
+				if r.verbose {
+					fmt.Printf("Not testing %s itself: %v\n", target, err)
+				}
+				continue
+			}
+		}
+
+		result := markInfra(executor.Execute(context.Background(), target, ExecuteOptions{
+			WithRepo: true,
+			APKRepo:  r.apkRepo,
+			Timeout:  r.timeoutFor(target),
+		}))
+
+		switch {
+		case result.Skipped:
+			if r.verbose {
+				fmt.Printf("Not testing %s itself: %v\n", target, result.Error)
+			}
+		case result.Success:
+			fmt.Printf("✅ %s passes its own test with the candidate repository\n", target)
+		case result.Infra != "":
+			fmt.Printf("Warning: the test of %s itself failed on the infrastructure (%s), testing its reverse dependencies anyway - log: %s\n", target, result.Infra, result.LogPath)
+		case r.force:
+			fmt.Printf("⚠️  %s fails its own test with the candidate repository, testing its reverse dependencies anyway (--force) - log: %s\n", target, result.LogPath)
+		default:
+			return fmt.Errorf("%w: %s (log: %s)", ErrTargetBroken, target, result.LogPath)
+		}
+	}
+	return nil
+}
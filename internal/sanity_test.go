@@ -0,0 +1,75 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCheckTargets(t *testing.T) {
+	tests := []struct {
+		name         string
+		targetErr    error
+		force        bool
+		expectedErr  error
+		expectTested []string
+	}{
+		{"passes", nil, false, nil, []string{"curl", "openssl"}},
+		{"broken", errors.New("test failed"), false, ErrTargetBroken, []string{"openssl"}},
+		{"broken with --force", errors.New("test failed"), true, nil, []string{"curl", "openssl"}},
+		{"infrastructure failure", errors.New("Cannot connect to the Docker daemon at unix:///var/run/docker.sock"), false, nil, []string{"curl", "openssl"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repoPath := t.TempDir()
+			for _, name := range []string{"openssl", "curl"} {
+				if err := os.WriteFile(filepath.Join(repoPath, name+".yaml"), []byte("package:\n  name: "+name+"\n"), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			runner := NewRegressionTestRunner("openssl", "https://example.com/repo", repoPath, "wolfi", 1, false, time.Minute, false)
+			runner.setLogDir(t.TempDir())
+			runner.SetInfraBackoff(false)
+			runner.SetForce(tt.force)
+			runner.apkrane.packages = []Package{
+				{Name: "openssl", Origin: "openssl"},
+				{Name: "curl", Origin: "curl", Dependencies: []string{"openssl"}},
+			}
+
+			var mu sync.Mutex
+			var tested []string
+			runner.SetExecutor(TestExecutorFunc(func(ctx context.Context, pkg string, opts ExecuteOptions) TestResult {
+				mu.Lock()
+				tested = append(tested, pkg)
+				mu.Unlock()
+				if pkg == "openssl" && tt.targetErr != nil {
+					return newTestResult(pkg, opts.WithRepo, time.Now(), "", tt.targetErr)
+				}
+				return newTestResult(pkg, opts.WithRepo, time.Now(), "", nil)
+			}))
+
+			err := runner.RunTargets([]string{"openssl"})
+			if tt.expectedErr != nil && !errors.Is(err, tt.expectedErr) {
+				t.Errorf("Expected %v, got %v", tt.expectedErr, err)
+			}
+			if tt.expectedErr == nil && err != nil {
+				t.Errorf("Expected the reverse dependencies to be tested, got %v", err)
+			}
+			sort.Strings(tested)
+			if !reflect.DeepEqual(tested, tt.expectTested) {
+				t.Errorf("Expected %v to be tested, got %v", tt.expectTested, tested)
+			}
+		})
+	}
+}
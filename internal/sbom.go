@@ -0,0 +1,180 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// spdxDocument is the subset of an SPDX JSON document we care about
+type spdxDocument struct {
+	SPDXVersion string `json:"spdxVersion"`
+	Packages    []struct {
+		Name string `json:"name"`
+	} `json:"packages"`
+}
+
+// cycloneDXComponent is the subset of a CycloneDX component we care about
+type cycloneDXComponent struct {
+	Name       string               `json:"name"`
+	Components []cycloneDXComponent `json:"components"`
+}
+
+type cycloneDXDocument struct {
+	BOMFormat  string               `json:"bomFormat"`
+	Components []cycloneDXComponent `json:"components"`
+}
+
+// LoadSBOMPackages reads an SPDX or CycloneDX JSON document from disk and
+// returns the sorted, de-duplicated set of package names it lists.
+func LoadSBOMPackages(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read SBOM %s: %w", path, err)
+	}
+
+	names, err := parseSBOM(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse SBOM %s: %w", path, err)
+	}
+	return names, nil
+}
+
+// FetchImageSBOMPackages downloads the SPDX SBOM attestation attached to an
+// image reference using cosign and returns the package names it lists.
+// Chainguard images publish their SBOMs this way.
+func FetchImageSBOMPackages(imageRef string, verbose bool) ([]string, error) {
+	if verbose {
+		fmt.Printf("Downloading SBOM attestation for image: %s\n", imageRef)
+	}
+
+	cmd := exec.Command("cosign", "download", "attestation",
+		"--predicate-type", "https://spdx.dev/Document",
+		"--platform", fmt.Sprintf("linux/%s", runtime.GOARCH),
+		imageRef)
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to download SBOM attestation for %s: %w", imageRef, err)
+	}
+
+	names, err := parseAttestations(output)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse SBOM attestation for %s: %w", imageRef, err)
+	}
+	return names, nil
+}
+
+// parseAttestations decodes newline-delimited DSSE envelopes as printed by
+// `cosign download attestation` and extracts packages from their predicates.
+func parseAttestations(output []byte) ([]string, error) {
+	nameSet := make(map[string]bool)
+
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	scanner.Buffer(make([]byte, 0, 1024*1024), 256*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		var envelope struct {
+			Payload string `json:"payload"`
+		}
+		if err := json.Unmarshal([]byte(line), &envelope); err != nil {
+			return nil, fmt.Errorf("invalid attestation envelope: %w", err)
+		}
+
+		payload, err := base64.StdEncoding.DecodeString(envelope.Payload)
+		if err != nil {
+			return nil, fmt.Errorf("invalid attestation payload: %w", err)
+		}
+
+		var statement struct {
+			Predicate json.RawMessage `json:"predicate"`
+		}
+		if err := json.Unmarshal(payload, &statement); err != nil {
+			return nil, fmt.Errorf("invalid in-toto statement: %w", err)
+		}
+
+		names, err := parseSBOM(statement.Predicate)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range names {
+			nameSet[name] = true
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(nameSet) == 0 {
+		return nil, fmt.Errorf("no SBOM attestations found")
+	}
+
+	return sortedKeys(nameSet), nil
+}
+
+// parseSBOM detects whether data is an SPDX or CycloneDX JSON document and
+// returns the package names it contains.
+func parseSBOM(data []byte) ([]string, error) {
+	var probe map[string]json.RawMessage
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return nil, err
+	}
+
+	nameSet := make(map[string]bool)
+
+	switch {
+	case probe["spdxVersion"] != nil:
+		var doc spdxDocument
+		if err := json.Unmarshal(data, &doc); err != nil {
+			return nil, err
+		}
+		for _, pkg := range doc.Packages {
+			if pkg.Name != "" {
+				nameSet[pkg.Name] = true
+			}
+		}
+	case probe["bomFormat"] != nil:
+		var doc cycloneDXDocument
+		if err := json.Unmarshal(data, &doc); err != nil {
+			return nil, err
+		}
+		collectCycloneDXNames(doc.Components, nameSet)
+	default:
+		return nil, fmt.Errorf("unrecognized SBOM format (expected SPDX or CycloneDX JSON)")
+	}
+
+	return sortedKeys(nameSet), nil
+}
+
+func collectCycloneDXNames(components []cycloneDXComponent, nameSet map[string]bool) {
+	for _, c := range components {
+		if c.Name != "" {
+			nameSet[c.Name] = true
+		}
+		collectCycloneDXNames(c.Components, nameSet)
+	}
+}
+
+// sortedKeys returns the keys of m in sorted order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,98 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLoadSBOMPackages(t *testing.T) {
+	tests := []struct {
+		name          string
+		content       string
+		expectedNames []string
+		expectedError bool
+	}{
+		{
+			name: "spdx document",
+			content: `{
+  "spdxVersion": "SPDX-2.3",
+  "packages": [
+    {"name": "python-3.12"},
+    {"name": "openssl"},
+    {"name": "python-3.12"},
+    {"name": ""}
+  ]
+}`,
+			expectedNames: []string{"openssl", "python-3.12"},
+		},
+		{
+			name: "cyclonedx document with nested components",
+			content: `{
+  "bomFormat": "CycloneDX",
+  "components": [
+    {"name": "glibc", "components": [{"name": "ld-linux"}]},
+    {"name": "busybox"}
+  ]
+}`,
+			expectedNames: []string{"busybox", "glibc", "ld-linux"},
+		},
+		{
+			name:          "unknown format",
+			content:       `{"foo": "bar"}`,
+			expectedError: true,
+		},
+		{
+			name:          "invalid json",
+			content:       `not json`,
+			expectedError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "sbom.json")
+			if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+				t.Fatalf("Failed to write SBOM: %v", err)
+			}
+
+			names, err := LoadSBOMPackages(path)
+			if tt.expectedError {
+				if err == nil {
+					t.Error("Expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(names, tt.expectedNames) {
+				t.Errorf("Expected %v, got %v", tt.expectedNames, names)
+			}
+		})
+	}
+}
+
+func TestParseAttestations(t *testing.T) {
+	statement := `{"predicateType":"https://spdx.dev/Document","predicate":{"spdxVersion":"SPDX-2.3","packages":[{"name":"curl"},{"name":"libcurl-openssl4"}]}}`
+	envelope := `{"payloadType":"application/vnd.in-toto+json","payload":"` + base64.StdEncoding.EncodeToString([]byte(statement)) + `"}`
+
+	names, err := parseAttestations([]byte(envelope + "\n\n"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := []string{"curl", "libcurl-openssl4"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("Expected %v, got %v", expected, names)
+	}
+
+	if _, err := parseAttestations([]byte("")); err == nil {
+		t.Error("Expected error for empty attestation output")
+	}
+}
@@ -0,0 +1,245 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LatestReleaseURL is the GitHub API endpoint describing the latest release
+const LatestReleaseURL = "https://api.github.com/repos/chainguard-dev/apkregress/releases/latest"
+
+// releaseChecksums is the release asset listing the SHA-256 of the binaries
+// in sha256sum format
+const releaseChecksums = "checksums.txt"
+
+// Release is a published apkregress release
+type Release struct {
+	Tag    string         `json:"tag_name"`
+	URL    string         `json:"html_url"`
+	Assets []ReleaseAsset `json:"assets"`
+}
+
+// ReleaseAsset is a file attached to a release
+type ReleaseAsset struct {
+	Name string `json:"name"`
+	URL  string `json:"browser_download_url"`
+}
+
+// releaseBinary is the name of the release asset for the host, e.g.
+// apkregress_linux_amd64
+func releaseBinary() string {
+	name := fmt.Sprintf("apkregress_%s_%s", runtime.GOOS, runtime.GOARCH)
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+	return name
+}
+
+// asset returns the release asset called name
+func (r *Release) asset(name string) (ReleaseAsset, bool) {
+	for _, asset := range r.Assets {
+		if asset.Name == name {
+			return asset, true
+		}
+	}
+	return ReleaseAsset{}, false
+}
+
+// semver is a parsed semantic version such as v1.2.3-rc.1
+type semver struct {
+	core       [3]int
+	prerelease []string
+}
+
+// parseSemver parses a semantic version with an optional v prefix, ignoring
+// build metadata
+func parseSemver(version string) (semver, bool) {
+	var v semver
+	version, _, _ = strings.Cut(strings.TrimPrefix(version, "v"), "+")
+	version, pre, hasPre := strings.Cut(version, "-")
+	if hasPre {
+		if pre == "" {
+			return v, false
+		}
+		v.prerelease = strings.Split(pre, ".")
+	}
+
+	parts := strings.Split(version, ".")
+	if len(parts) != 3 {
+		return v, false
+	}
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 || part != strconv.Itoa(n) {
+			return v, false
+		}
+		v.core[i] = n
+	}
+	return v, true
+}
+
+// CompareReleaseVersions compares two semantic versions, returning -1, 0 or
+// 1 as a is older than, the same as or newer than b. It fails for versions
+// that aren't semantic versions, such as the devel version of builds from a
+// checkout.
+func CompareReleaseVersions(a, b string) (int, error) {
+	va, ok := parseSemver(a)
+	if !ok {
+		return 0, fmt.Errorf("%q is not a release version", a)
+	}
+	vb, ok := parseSemver(b)
+	if !ok {
+		return 0, fmt.Errorf("%q is not a release version", b)
+	}
+
+	for i := range va.core {
+		if c := compareInts(va.core[i], vb.core[i]); c != 0 {
+			return c, nil
+		}
+	}
+
+	// A version without a prerelease is newer than its prereleases
+	switch {
+	case va.prerelease == nil && vb.prerelease == nil:
+		return 0, nil
+	case va.prerelease == nil:
+		return 1, nil
+	case vb.prerelease == nil:
+		return -1, nil
+	}
+	for i := 0; i < len(va.prerelease) && i < len(vb.prerelease); i++ {
+		if c := comparePrerelease(va.prerelease[i], vb.prerelease[i]); c != 0 {
+			return c, nil
+		}
+	}
+	return compareInts(len(va.prerelease), len(vb.prerelease)), nil
+}
+
+// comparePrerelease compares prerelease identifiers: numeric ones
+// numerically and before alphanumeric ones, which compare in ASCII order
+func comparePrerelease(a, b string) int {
+	na, errA := strconv.Atoi(a)
+	nb, errB := strconv.Atoi(b)
+	switch {
+	case errA == nil && errB == nil:
+		return compareInts(na, nb)
+	case errA == nil:
+		return -1
+	case errB == nil:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+var releaseClient = &http.Client{Timeout: 5 * time.Minute}
+
+// FetchLatestRelease describes the latest release published at url (see
+// LatestReleaseURL)
+func FetchLatestRelease(url string) (*Release, error) {
+	data, err := fetchRelease(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch the latest release: %w", err)
+	}
+
+	var release Release
+	if err := json.Unmarshal(data, &release); err != nil {
+		return nil, fmt.Errorf("invalid release description from %s: %w", url, err)
+	}
+	if release.Tag == "" {
+		return nil, fmt.Errorf("invalid release description from %s: no tag", url)
+	}
+	return &release, nil
+}
+
+// UpdateBinary replaces the binary at path with the release's binary for the
+// host, after verifying it against the release checksums
+func UpdateBinary(release *Release, path string) error {
+	asset, ok := release.asset(releaseBinary())
+	if !ok {
+		return fmt.Errorf("release %s has no binary for %s/%s", release.Tag, runtime.GOOS, runtime.GOARCH)
+	}
+	sums, ok := release.asset(releaseChecksums)
+	if !ok {
+		return fmt.Errorf("release %s has no %s to verify the binary with", release.Tag, releaseChecksums)
+	}
+
+	checksums, err := fetchRelease(sums.URL)
+	if err != nil {
+		return fmt.Errorf("failed to download %s: %w", releaseChecksums, err)
+	}
+	expected, err := checksumFor(checksums, asset.Name)
+	if err != nil {
+		return err
+	}
+
+	binary, err := fetchRelease(asset.URL)
+	if err != nil {
+		return fmt.Errorf("failed to download %s: %w", asset.Name, err)
+	}
+	sum := sha256.Sum256(binary)
+	if got := hex.EncodeToString(sum[:]); got != expected {
+		return fmt.Errorf("checksum mismatch for %s: expected %s, got %s", asset.Name, expected, got)
+	}
+
+	// Write next to the binary so that the rename replacing it is atomic
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".apkregress-update-")
+	if err != nil {
+		return fmt.Errorf("failed to update %s: %w", path, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(binary); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to update %s: %w", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to update %s: %w", path, err)
+	}
+	if err := os.Chmod(tmp.Name(), 0755); err != nil {
+		return fmt.Errorf("failed to update %s: %w", path, err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to update %s: %w", path, err)
+	}
+	return nil
+}
+
+// checksumFor finds the SHA-256 of name in sha256sum output
+func checksumFor(checksums []byte, name string) (string, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(checksums))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == name {
+			return strings.ToLower(fields[0]), nil
+		}
+	}
+	return "", fmt.Errorf("%s lists no checksum for %s", releaseChecksums, name)
+}
+
+func fetchRelease(url string) ([]byte, error) {
+	resp, err := releaseClient.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", url, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
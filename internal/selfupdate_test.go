@@ -0,0 +1,116 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestUpdateBinary(t *testing.T) {
+	binary := []byte("new apkregress")
+	sum := sha256.Sum256(binary)
+	checksums := fmt.Sprintf("%s  %s\n", hex.EncodeToString(sum[:]), releaseBinary())
+
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/latest":
+			json.NewEncoder(w).Encode(Release{Tag: "v1.2.3", Assets: []ReleaseAsset{
+				{Name: releaseBinary(), URL: server.URL + "/binary"},
+				{Name: releaseChecksums, URL: server.URL + "/checksums"},
+			}})
+		case "/binary":
+			w.Write(binary)
+		case "/checksums":
+			w.Write([]byte(checksums))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	release, err := FetchLatestRelease(server.URL + "/latest")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if release.Tag != "v1.2.3" {
+		t.Errorf("Expected tag v1.2.3, got %s", release.Tag)
+	}
+
+	path := filepath.Join(t.TempDir(), "apkregress")
+	if err := os.WriteFile(path, []byte("old apkregress"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := UpdateBinary(release, path); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != string(binary) {
+		t.Errorf("Expected the binary to be replaced, got %q", data)
+	}
+
+	// A binary not matching its checksum must not be installed
+	checksums = strings.Repeat("0", 64) + "  " + releaseBinary() + "\n"
+	if err := os.WriteFile(path, []byte("old apkregress"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := UpdateBinary(release, path); err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Errorf("Expected a checksum mismatch, got %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "old apkregress" {
+		t.Errorf("Expected the binary to be kept, got %q", data)
+	}
+}
+
+func TestUpdateBinaryMissingAsset(t *testing.T) {
+	release := &Release{Tag: "v1.2.3", Assets: []ReleaseAsset{{Name: "apkregress_plan9_mips"}}}
+	if err := UpdateBinary(release, filepath.Join(t.TempDir(), "apkregress")); err == nil || !strings.Contains(err.Error(), "has no binary for") {
+		t.Errorf("Expected a missing binary error, got %v", err)
+	}
+}
+
+func TestCompareReleaseVersions(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected int
+	}{
+		{a: "v1.2.3", b: "v1.2.3", expected: 0},
+		{a: "v1.2.3", b: "v1.2.4", expected: -1},
+		{a: "v1.10.0", b: "v1.9.9", expected: 1},
+		{a: "v2.0.0", b: "v1.99.99", expected: 1},
+		{a: "1.2.3", b: "v1.2.3", expected: 0},
+		{a: "v1.2.3+build.5", b: "v1.2.3", expected: 0},
+		{a: "v1.2.3-rc.1", b: "v1.2.3", expected: -1},
+		{a: "v1.2.3-rc.2", b: "v1.2.3-rc.10", expected: -1},
+		{a: "v1.2.3-rc.1", b: "v1.2.3-rc.1.1", expected: -1},
+		{a: "v1.2.3-1", b: "v1.2.3-alpha", expected: -1},
+		{a: "v1.2.3-beta", b: "v1.2.3-alpha", expected: 1},
+		{a: "v1.2.4-0.20250101120000-0123456789ab", b: "v1.2.3", expected: 1},
+	}
+
+	for _, tt := range tests {
+		got, err := CompareReleaseVersions(tt.a, tt.b)
+		if err != nil {
+			t.Errorf("CompareReleaseVersions(%q, %q): expected no error, got %v", tt.a, tt.b, err)
+			continue
+		}
+		if got != tt.expected {
+			t.Errorf("CompareReleaseVersions(%q, %q): expected %d, got %d", tt.a, tt.b, tt.expected, got)
+		}
+	}
+
+	for _, version := range []string{"devel", "v1.2", "v1.2.x", "v1.02.3", "v1.2.3-", ""} {
+		if _, err := CompareReleaseVersions(version, "v1.2.3"); err == nil {
+			t.Errorf("Expected %q not to be a release version", version)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"context"
+	"fmt"
+)
+
+// SetContext stops the run when ctx is cancelled, e.g. when the process is
+// interrupted: no more tests start, the running ones are asked to stop and
+// killed after StopGrace, and the run reports the packages tested so far
+// and fails with the cause of the cancellation
+func (r *RegressionTestRunner) SetContext(ctx context.Context) {
+	r.ctx = ctx
+}
+
+// runContext returns the context of the run, see SetContext
+func (r *RegressionTestRunner) runContext() context.Context {
+	if r.ctx == nil {
+		return context.Background()
+	}
+	return r.ctx
+}
+
+// stopOnCancel drains queue once ctx is cancelled, so that the workers
+// return as soon as their tests stopped. It returns a function to stop
+// watching ctx.
+func (r *RegressionTestRunner) stopOnCancel(ctx context.Context, queue *packageQueue) func() bool {
+	return context.AfterFunc(ctx, func() {
+		dropped, running := queue.stop()
+		for _, pkg := range dropped {
+			r.progress.AddTotal(-1)
+			r.eta.cancel(pkg)
+		}
+		r.printResult("⏹️  Stopping (%v): %d queued packages won't be tested, %d running tests are asked to stop\n", context.Cause(ctx), len(dropped), running)
+	})
+}
+
+// stoppedErr fails a run whose context was cancelled, see SetContext
+func (r *RegressionTestRunner) stoppedErr(ctx context.Context) error {
+	if ctx.Err() == nil {
+		return nil
+	}
+	return fmt.Errorf("run stopped: %w, results are incomplete", context.Cause(ctx))
+}
@@ -0,0 +1,68 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRunnerStopsOnCancel(t *testing.T) {
+	repoPath := t.TempDir()
+	for _, pkg := range []string{"a", "b", "c"} {
+		if err := os.WriteFile(filepath.Join(repoPath, pkg+".yaml"), []byte("package:\n  name: "+pkg+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// a passes, the run is stopped while b is tested and c never starts
+	errStopped := errors.New("received signal terminated")
+	ctx, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
+	var tested []string
+	runner := NewRegressionTestRunner("", "https://example.com/repo", repoPath, "wolfi", 1, false, time.Minute, false)
+	logDir := t.TempDir()
+	runner.setLogDir(logDir)
+	runner.SetContext(ctx)
+	runner.SetExecutor(TestExecutorFunc(func(ctx context.Context, pkg string, opts ExecuteOptions) TestResult {
+		tested = append(tested, pkg)
+		if pkg != "b" {
+			return newTestResult(pkg, opts.WithRepo, time.Now(), "", nil)
+		}
+		cancel(errStopped)
+		<-ctx.Done()
+		return newTestResult(pkg, opts.WithRepo, time.Now(), "", ctx.Err())
+	}))
+
+	err := runner.RunFromPackageList([]string{"a", "b", "c"})
+	if !errors.Is(err, errStopped) {
+		t.Fatalf("Expected the run to fail with %v, got %v", errStopped, err)
+	}
+	if !reflect.DeepEqual(tested, []string{"a", "b"}) {
+		t.Errorf("Expected a and b to be tested, got %v", tested)
+	}
+	if !reflect.DeepEqual(runner.summary.Successful, []string{"a"}) || len(runner.summary.Failed) != 0 {
+		t.Errorf("Expected a to pass and the interrupted test not to fail, got %v and %v", runner.summary.Successful, runner.summary.Failed)
+	}
+	if _, err := os.Stat(filepath.Join(logDir, "results.json")); err != nil {
+		t.Errorf("Expected the results of the stopped run: %v", err)
+	}
+	manifest, err := LoadRunManifest(logDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if manifest.Stopped != errStopped.Error() {
+		t.Errorf("Expected the manifest to record why the run stopped, got %q", manifest.Stopped)
+	}
+
+	// Later runs with the same context don't start
+	if err := runner.RunFromPackageList([]string{"c"}); !errors.Is(err, errStopped) {
+		t.Errorf("Expected a run of a stopped context to fail, got %v", err)
+	}
+}
@@ -0,0 +1,46 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLevenshtein(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected int
+	}{
+		{"", "", 0},
+		{"curl", "curl", 0},
+		{"curl", "", 4},
+		{"opensll", "openssl", 1},
+		{"kitten", "sitting", 3},
+		{"python-3.12", "python-3.13", 1},
+	}
+
+	for _, tt := range tests {
+		if got := levenshtein(tt.a, tt.b); got != tt.expected {
+			t.Errorf("Expected distance %d between %q and %q, got %d", tt.expected, tt.a, tt.b, got)
+		}
+	}
+}
+
+func TestClosestMatches(t *testing.T) {
+	candidates := []string{"openssl", "openssh", "openssl", "curl", "libssl3", "openldap"}
+
+	got := closestMatches("opensl", candidates, 3)
+	expected := []string{"openssl", "openssh"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+
+	if got := closestMatches("opensl", candidates, 1); !reflect.DeepEqual(got, []string{"openssl"}) {
+		t.Errorf("Expected the limit to apply, got %v", got)
+	}
+	if got := closestMatches("postgresql", candidates, 3); len(got) != 0 {
+		t.Errorf("Expected no matches, got %v", got)
+	}
+}
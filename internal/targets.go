@@ -0,0 +1,123 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// targetStats summarizes the reverse dependencies of one target of a run
+// testing several targets at once
+type targetStats struct {
+	Target      string
+	Consumers   int
+	Tested      int
+	Regressions int
+}
+
+// RunTargets tests the reverse dependencies of several packages under one
+// worker pool, so that they share --concurrency instead of each run
+// saturating the machine, and reports them together. Every target must be
+// in the index.
+func (r *RegressionTestRunner) RunTargets(targets []string) error {
+	for _, target := range targets {
+		if err := r.apkrane.ValidatePackage(target); err != nil {
+			return err
+		}
+	}
+	r.sanityTargets = targets
+	return r.RunReverseDependencies(targets)
+}
+
+// addTarget records that consumer is a reverse dependency of target
+func (r *RegressionTestRunner) addTarget(consumer, target string) {
+	if r.targetsOf == nil {
+		r.targetsOf = make(map[string][]string)
+	}
+	r.targetsOf[consumer] = append(r.targetsOf[consumer], target)
+}
+
+// targetNote names the targets a consumer depends on when several were
+// tested, e.g. " (depends on openssl, zlib)"
+func (r *RegressionTestRunner) targetNote(pkg string) string {
+	targets := r.targetsOf[pkg]
+	if len(targets) == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" (depends on %s)", strings.Join(targets, ", "))
+}
+
+// targetBreakdown counts the tested reverse dependencies and regressions of
+// each target, in the order the targets were given
+func (r *RegressionTestRunner) targetBreakdown() []targetStats {
+	if len(r.targetsOf) == 0 {
+		return nil
+	}
+
+	skipped := make(map[string]bool)
+	for _, pkg := range r.summary.Skipped {
+		skipped[pkg] = true
+	}
+	regressed := make(map[string]bool)
+	for _, pkg := range r.summary.Regressions {
+		regressed[pkg] = true
+	}
+
+	stats := make(map[string]*targetStats)
+	for _, consumer := range sortedKeys(r.targetsOf) {
+		for _, target := range r.targetsOf[consumer] {
+			s, ok := stats[target]
+			if !ok {
+				s = &targetStats{Target: target}
+				stats[target] = s
+			}
+			s.Consumers++
+			if _, tested := r.packageResults[consumer]; tested && !skipped[consumer] {
+				s.Tested++
+			}
+			if regressed[consumer] {
+				s.Regressions++
+			}
+		}
+	}
+
+	breakdown := make([]targetStats, 0, len(stats))
+	for _, s := range stats {
+		breakdown = append(breakdown, *s)
+	}
+	sort.Slice(breakdown, func(i, j int) bool {
+		if breakdown[i].Regressions != breakdown[j].Regressions {
+			return breakdown[i].Regressions > breakdown[j].Regressions
+		}
+		return breakdown[i].Target < breakdown[j].Target
+	})
+	return breakdown
+}
+
+// printTargetBreakdown lists the outcome of each target of a run testing
+// several targets
+func (r *RegressionTestRunner) printTargetBreakdown(w io.Writer) {
+	breakdown := r.targetBreakdown()
+	if len(breakdown) == 0 {
+		return
+	}
+
+	if r.markdownOutput {
+		fmt.Fprintf(w, "\n### 🎯 Results by Target\n\n")
+		fmt.Fprintf(w, "| Target | Reverse dependencies | Tested | Regressions |\n")
+		fmt.Fprintf(w, "|--------|----------------------|--------|-------------|\n")
+		for _, s := range breakdown {
+			fmt.Fprintf(w, "| `%s` | %d | %d | %d |\n", s.Target, s.Consumers, s.Tested, s.Regressions)
+		}
+		return
+	}
+
+	fmt.Fprintf(w, "\nResults by target:\n")
+	for _, s := range breakdown {
+		fmt.Fprintf(w, "  - %s: %d reverse dependencies, %d tested, %d regressions\n", s.Target, s.Consumers, s.Tested, s.Regressions)
+	}
+}
@@ -0,0 +1,75 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRunTargets(t *testing.T) {
+	repoPath := t.TempDir()
+	for _, name := range []string{"curl", "git", "nginx"} {
+		if err := os.WriteFile(filepath.Join(repoPath, name+".yaml"), []byte("package:\n  name: "+name+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	runner := NewRegressionTestRunner("openssl,zlib", "https://example.com/repo", repoPath, "wolfi", 2, true, time.Minute, false)
+	runner.setLogDir(t.TempDir())
+	runner.apkrane.packages = []Package{
+		{Name: "openssl", Origin: "openssl"},
+		{Name: "zlib", Origin: "zlib"},
+		{Name: "curl", Origin: "curl", Dependencies: []string{"openssl", "zlib"}},
+		{Name: "git", Origin: "git", Dependencies: []string{"zlib"}},
+		{Name: "nginx", Origin: "nginx", Dependencies: []string{"openssl"}},
+	}
+
+	var mu sync.Mutex
+	var tested []string
+	runner.SetExecutor(TestExecutorFunc(func(ctx context.Context, pkg string, opts ExecuteOptions) TestResult {
+		mu.Lock()
+		if opts.WithRepo {
+			tested = append(tested, pkg)
+		}
+		mu.Unlock()
+
+		var err error
+		if pkg == "curl" && opts.WithRepo {
+			err = errors.New("test failed")
+		}
+		return newTestResult(pkg, opts.WithRepo, time.Now(), "", err)
+	}))
+
+	if err := runner.RunTargets([]string{"openssl", "zlib"}); err == nil || err.Error() != "found 1 regressions" {
+		t.Errorf("Expected 1 regression, got %v", err)
+	}
+
+	sort.Strings(tested)
+	if !reflect.DeepEqual(tested, []string{"curl", "git", "nginx"}) {
+		t.Errorf("Expected each reverse dependency to be tested once, got %v", tested)
+	}
+	if note := runner.targetNote("curl"); note != " (depends on openssl, zlib)" {
+		t.Errorf("Unexpected target note %q", note)
+	}
+
+	expected := []targetStats{
+		{Target: "openssl", Consumers: 2, Tested: 2, Regressions: 1},
+		{Target: "zlib", Consumers: 2, Tested: 2, Regressions: 1},
+	}
+	if got := runner.targetBreakdown(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, got)
+	}
+
+	if err := runner.RunTargets([]string{"openssl", "zlibb"}); !errors.Is(err, ErrUnknownPackage) {
+		t.Errorf("Expected unknown targets to be rejected, got %v", err)
+	}
+}
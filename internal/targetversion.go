@@ -0,0 +1,95 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ParsePackageVersion splits a package given as name@version, e.g.
+// openssl@3.3.2-r1, into its name and version, "" if it has none. The
+// version must include the release, as it stands for a single build.
+func ParsePackageVersion(spec string) (string, string, error) {
+	name, version, hasVersion := strings.Cut(spec, "@")
+	if !hasVersion {
+		return spec, "", nil
+	}
+	if name == "" || version == "" || strings.ContainsAny(version, "@<>=~ \t") {
+		return "", "", fmt.Errorf("invalid package %q: expected name@version, e.g. openssl@3.3.2-r1", spec)
+	}
+	if !hasAPKRelease(version) {
+		return "", "", fmt.Errorf("invalid package %q: the version must include the release, e.g. %s@%s-r0", spec, name, version)
+	}
+	return name, version, nil
+}
+
+// hasAPKRelease reports whether an APK version ends in a release, e.g. -r1
+func hasAPKRelease(version string) bool {
+	i := strings.LastIndex(version, "-r")
+	if i <= 0 {
+		return false
+	}
+	_, err := strconv.Atoi(version[i+2:])
+	return err == nil
+}
+
+// SetTargetVersion ties the run to one build of the package under test,
+// given as package@version: its version change and reports are about that
+// version, and with-repo tests install it even if the candidate repository
+// has a newer one
+func (r *RegressionTestRunner) SetTargetVersion(version string) {
+	r.targetVersion = version
+}
+
+// targetSpec returns the package under test as given, with its version if
+// the run is tied to one, e.g. openssl@3.3.2-r1
+func (r *RegressionTestRunner) targetSpec() string {
+	if r.targetVersion == "" {
+		return r.packageName
+	}
+	return r.packageName + "@" + r.targetVersion
+}
+
+// targetSpec is the package under test of the runners, which are all tied
+// to the same version
+func (m *MatrixRunner) targetSpec() string {
+	if len(m.runners) == 0 {
+		return m.packageName
+	}
+	return m.runners[0].targetSpec()
+}
+
+// pinTargetVersion pins the package under test at the requested version in
+// with-repo tests when the candidate repository has a newer one, which
+// would be installed otherwise
+func (r *RegressionTestRunner) pinTargetVersion(candidates []apkIndexEntry) error {
+	if r.targetVersion == "" {
+		return nil
+	}
+	for i, pin := range r.pins {
+		if pin.Name != r.packageName {
+			continue
+		}
+		if pin.Version != "" && pin.Version != r.targetVersion {
+			return fmt.Errorf("--pins pins %s at %s, but %s is to be tested", pin.Name, pin.Version, r.targetSpec())
+		}
+		r.pins[i].Version = r.targetVersion
+		return nil
+	}
+	if latest := latestVersion(candidates, r.packageName); latest == r.targetVersion {
+		return nil
+	}
+
+	supported, err := MelangeTestSupports("--test-package-append")
+	if err != nil {
+		return err
+	}
+	if !supported {
+		return fmt.Errorf("the candidate repository has a newer %s than %s, and installing the older one requires a melange whose test command supports --test-package-append", r.packageName, r.targetVersion)
+	}
+	r.pins = append(r.pins, Pin{Name: r.packageName, Version: r.targetVersion})
+	return nil
+}
@@ -0,0 +1,105 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParsePackageVersion(t *testing.T) {
+	tests := []struct {
+		spec            string
+		expectedName    string
+		expectedVersion string
+		expectedErr     string
+	}{
+		{spec: "openssl", expectedName: "openssl"},
+		{spec: "openssl@3.3.2-r1", expectedName: "openssl", expectedVersion: "3.3.2-r1"},
+		{spec: "py3-foo@1.0_rc1-r10", expectedName: "py3-foo", expectedVersion: "1.0_rc1-r10"},
+		{spec: "openssl@3.3.2", expectedErr: "must include the release, e.g. openssl@3.3.2-r0"},
+		{spec: "openssl@", expectedErr: "expected name@version"},
+		{spec: "@3.3.2-r1", expectedErr: "expected name@version"},
+		{spec: "openssl@>=3.3.2-r1", expectedErr: "expected name@version"},
+		{spec: "openssl@3.3.2-r1@2", expectedErr: "expected name@version"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			name, version, err := ParsePackageVersion(tt.spec)
+			if tt.expectedErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.expectedErr) {
+					t.Errorf("Expected an error containing %q, got %v", tt.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if name != tt.expectedName || version != tt.expectedVersion {
+				t.Errorf("Expected %s and %q, got %s and %q", tt.expectedName, tt.expectedVersion, name, version)
+			}
+		})
+	}
+}
+
+func TestPinTargetVersion(t *testing.T) {
+	t.Setenv(FakeBackendEnv, "1")
+	candidates := []apkIndexEntry{
+		{Name: "openssl", Version: "3.3.2-r1"},
+		{Name: "openssl", Version: "3.3.2-r2"},
+		{Name: "libcrypto3", Version: "3.3.2-r2"},
+	}
+
+	tests := []struct {
+		name        string
+		version     string
+		pins        []Pin
+		expected    []Pin
+		expectedErr string
+	}{
+		{name: "no version", pins: []Pin{{Name: "libcrypto3"}}, expected: []Pin{{Name: "libcrypto3"}}},
+		{name: "newest version", version: "3.3.2-r2"},
+		{name: "older version", version: "3.3.2-r1", expected: []Pin{{Name: "openssl", Version: "3.3.2-r1"}}},
+		{name: "bare pin", version: "3.3.2-r1", pins: []Pin{{Name: "openssl"}}, expected: []Pin{{Name: "openssl", Version: "3.3.2-r1"}}},
+		{name: "same pin", version: "3.3.2-r2", pins: []Pin{{Name: "openssl", Version: "3.3.2-r2"}}, expected: []Pin{{Name: "openssl", Version: "3.3.2-r2"}}},
+		{name: "conflicting pin", version: "3.3.2-r1", pins: []Pin{{Name: "openssl", Version: "3.3.2-r2"}}, expectedErr: "--pins pins openssl at 3.3.2-r2, but openssl@3.3.2-r1 is to be tested"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runner := &RegressionTestRunner{packageName: "openssl", pins: tt.pins}
+			runner.SetTargetVersion(tt.version)
+			err := runner.pinTargetVersion(candidates)
+			if tt.expectedErr != "" {
+				if err == nil || err.Error() != tt.expectedErr {
+					t.Errorf("Expected error %q, got %v", tt.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(runner.pins, tt.expected) {
+				t.Errorf("Expected pins %v, got %v", tt.expected, runner.pins)
+			}
+		})
+	}
+}
+
+func TestTargetSpec(t *testing.T) {
+	runner := &RegressionTestRunner{packageName: "openssl"}
+	if spec := runner.targetSpec(); spec != "openssl" {
+		t.Errorf("Expected openssl, got %s", spec)
+	}
+	runner.SetTargetVersion("3.3.2-r1")
+	if spec := runner.targetSpec(); spec != "openssl@3.3.2-r1" {
+		t.Errorf("Expected openssl@3.3.2-r1, got %s", spec)
+	}
+	summary := runner.summaryReport()
+	if summary.Package != "openssl" || summary.PackageVersion != "3.3.2-r1" {
+		t.Errorf("Expected openssl at 3.3.2-r1 in the summary, got %s at %q", summary.Package, summary.PackageVersion)
+	}
+}
@@ -0,0 +1,211 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// testKeyInputs are everything the outcome of a package's with-repo test is
+// assumed to depend on. Tests with equal keys are expected to have equal
+// outcomes, so a test whose key matches an earlier pass needn't run again.
+type testKeyInputs struct {
+	Package  string
+	RepoType string
+	Arch     string
+	// Config is the content of the package's melange config
+	Config []byte
+	// Dependencies are the versions of the candidate repository packages
+	// the package depends on, by candidate package name
+	Dependencies map[string]string
+	Melange      string
+	// Pipelines describes the test pipeline filter, if tests were
+	// restricted to some of their pipelines
+	Pipelines string
+	// Env is the test environment of the run's variant (see SetTestEnv)
+	Env map[string]string
+	// Pins are the resolved pins installed into the test (see SetPins)
+	Pins []Pin
+	// TestCommand is the template of the command running tests instead of
+	// make, if any (see SetTestCommand)
+	TestCommand string
+}
+
+// key returns the content key of the inputs
+func (in testKeyInputs) key() string {
+	h := sha256.New()
+	configSum := sha256.Sum256(in.Config)
+	fmt.Fprintf(h, "package:%s\nrepo-type:%s\narch:%s\nmelange:%s\nconfig:%s\n",
+		in.Package, in.RepoType, in.Arch, in.Melange, hex.EncodeToString(configSum[:]))
+	for _, name := range sortedKeys(in.Dependencies) {
+		fmt.Fprintf(h, "dep:%s=%s\n", name, in.Dependencies[name])
+	}
+	// Left out without a filter, keeping the keys of earlier runs valid
+	if in.Pipelines != "" {
+		fmt.Fprintf(h, "pipelines:%s\n", in.Pipelines)
+	}
+	for _, name := range sortedKeys(in.Env) {
+		fmt.Fprintf(h, "env:%s=%s\n", name, in.Env[name])
+	}
+	pins := make([]string, len(in.Pins))
+	for i, pin := range in.Pins {
+		pins[i] = pin.String()
+	}
+	sort.Strings(pins)
+	for _, pin := range pins {
+		fmt.Fprintf(h, "pin:%s\n", pin)
+	}
+	if in.TestCommand != "" {
+		commandSum := sha256.Sum256([]byte(in.TestCommand))
+		fmt.Fprintf(h, "test-command:%s\n", hex.EncodeToString(commandSum[:]))
+	}
+	return "sha256:" + hex.EncodeToString(h.Sum(nil))
+}
+
+// testKeyer computes the content keys of package tests against one
+// candidate repository
+type testKeyer struct {
+	locator   ConfigLocator
+	repoType  string
+	melange   string
+	pipelines string
+	env       map[string]string
+	pins      []Pin
+	// testCommand is the text of the test command template
+	testCommand string
+	// base is the repository type's index, for the runtime dependencies of
+	// the packages under test
+	base []Package
+	// providers map package names and the names they provide to the
+	// candidate package providing them
+	providers map[string]apkIndexEntry
+}
+
+func newTestKeyer(locator ConfigLocator, repoType, melangeVersion string, base []Package, candidate []apkIndexEntry) *testKeyer {
+	providers := make(map[string]apkIndexEntry)
+	add := func(name string, entry apkIndexEntry) {
+		if current, ok := providers[name]; !ok || compareAPKVersions(entry.Version, current.Version) > 0 {
+			providers[name] = entry
+		}
+	}
+	for _, entry := range candidate {
+		add(entry.Name, entry)
+		for _, provide := range entry.Provides {
+			add(provide, entry)
+		}
+	}
+
+	return &testKeyer{
+		locator:   locator,
+		repoType:  repoType,
+		melange:   melangeVersion,
+		base:      base,
+		providers: providers,
+	}
+}
+
+// key returns the content key of the test of pkg
+func (k *testKeyer) key(pkg string) (string, error) {
+	path, err := k.locator.Locate(pkg)
+	if err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read config of %s: %w", pkg, err)
+	}
+	var config MelangeConfig
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return "", fmt.Errorf("failed to parse config of %s: %w", pkg, err)
+	}
+
+	return testKeyInputs{
+		Package:      pkg,
+		RepoType:     k.repoType,
+		Arch:         hostArch(),
+		Config:       data,
+		Dependencies: k.resolve(k.dependencies(pkg, &config)),
+		Melange:      k.melange,
+		Pipelines:    k.pipelines,
+		Env:          k.env,
+		Pins:         k.pins,
+		TestCommand:  k.testCommand,
+	}.key(), nil
+}
+
+// dependencies returns the runtime dependencies of the packages built from
+// pkg's config and the packages its build and test environments install
+func (k *testKeyer) dependencies(pkg string, config *MelangeConfig) []string {
+	var deps []string
+	for _, p := range k.base {
+		if p.Origin == pkg {
+			deps = append(deps, p.Dependencies...)
+		}
+	}
+	deps = append(deps, config.Environment.Contents.Packages...)
+	deps = append(deps, config.Test.Environment.Contents.Packages...)
+	return deps
+}
+
+// resolve maps dependencies to the versions of the candidate packages that
+// provide them. Dependencies the candidate repository doesn't provide are
+// left out: they resolve the same with and without it.
+func (k *testKeyer) resolve(deps []string) map[string]string {
+	versions := make(map[string]string)
+	for _, dep := range deps {
+		name := strings.TrimPrefix(dep, "!")
+		if i := strings.IndexAny(name, "<>=~"); i >= 0 {
+			name = name[:i]
+		}
+		if entry, ok := k.providers[name]; ok && !strings.HasPrefix(dep, "!") {
+			versions[entry.Name] = entry.Version
+		}
+	}
+	return versions
+}
+
+// PassedKeys returns the content keys of the tests that passed in recorded
+// runs, mapped to the ID of the latest run they passed in
+func (db *ResultsDB) PassedKeys() (map[string]string, error) {
+	runs, err := db.Runs()
+	if err != nil {
+		return nil, err
+	}
+
+	passed := make(map[string]string)
+	for _, run := range runs {
+		for _, pkg := range run.Packages {
+			if pkg.Key != "" && pkg.Status == StatusPass {
+				passed[pkg.Key] = run.RunID
+			}
+		}
+	}
+	return passed, nil
+}
+
+// keyedPackages computes the content keys of packages and splits off those
+// that passed before with the same key. Packages whose key can't be computed
+// are tested.
+func keyedPackages(keyer *testKeyer, passed map[string]string, packages []string) (keys, unchanged map[string]string) {
+	keys = make(map[string]string)
+	unchanged = make(map[string]string)
+	for _, pkg := range packages {
+		key, err := keyer.key(pkg)
+		if err != nil {
+			continue
+		}
+		keys[pkg] = key
+		if runID, ok := passed[key]; ok {
+			unchanged[pkg] = runID
+		}
+	}
+	return keys, unchanged
+}
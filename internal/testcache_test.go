@@ -0,0 +1,199 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTestKeyInputs(t *testing.T) {
+	base := testKeyInputs{
+		Package:      "curl",
+		RepoType:     "wolfi",
+		Arch:         "x86_64",
+		Config:       []byte("package:\n  name: curl\n"),
+		Dependencies: map[string]string{"openssl": "3.3.2-r0", "zlib": "1.3-r0"},
+		Melange:      "v0.11.3",
+	}
+
+	same := base
+	same.Dependencies = map[string]string{"zlib": "1.3-r0", "openssl": "3.3.2-r0"}
+	if base.key() != same.key() {
+		t.Errorf("Expected equal inputs to have equal keys")
+	}
+
+	withEnv := base
+	withEnv.Env = map[string]string{"A": "1", "B": "2"}
+	sameEnv := base
+	sameEnv.Env = map[string]string{"B": "2", "A": "1"}
+	if withEnv.key() != sameEnv.key() {
+		t.Errorf("Expected the order of the environment not to change the key")
+	}
+
+	withPins := base
+	withPins.Pins = []Pin{{Name: "openssl", Version: "3.3.2-r1"}, {Name: "zlib", Version: "1.3-r1"}}
+	samePins := base
+	samePins.Pins = []Pin{{Name: "zlib", Version: "1.3-r1"}, {Name: "openssl", Version: "3.3.2-r1"}}
+	if withPins.key() != samePins.key() {
+		t.Errorf("Expected the order of the pins not to change the key")
+	}
+
+	changes := map[string]func(in *testKeyInputs){
+		"config":       func(in *testKeyInputs) { in.Config = []byte("package:\n  name: curl\n  epoch: 1\n") },
+		"dependency":   func(in *testKeyInputs) { in.Dependencies = map[string]string{"openssl": "3.3.3-r0", "zlib": "1.3-r0"} },
+		"melange":      func(in *testKeyInputs) { in.Melange = "v0.11.4" },
+		"repo type":    func(in *testKeyInputs) { in.RepoType = "enterprise" },
+		"pipelines":    func(in *testKeyInputs) { in.Pipelines = "only=python/import skip=" },
+		"env":          func(in *testKeyInputs) { in.Env = map[string]string{"FEATURE": "on"} },
+		"pins":         func(in *testKeyInputs) { in.Pins = []Pin{{Name: "openssl", Version: "3.3.2-r1"}} },
+		"test command": func(in *testKeyInputs) { in.TestCommand = "melange test {{.Config}} {{.ExtraRepos}}" },
+	}
+	for name, change := range changes {
+		changed := base
+		change(&changed)
+		if changed.key() == base.key() {
+			t.Errorf("Expected a changed %s to change the key", name)
+		}
+	}
+}
+
+func TestTestKeyerResolve(t *testing.T) {
+	candidate := []apkIndexEntry{
+		{Name: "openssl", Version: "3.3.2-r0", Provides: []string{"cmd:openssl"}},
+		{Name: "libssl3", Version: "3.3.1-r0", Provides: []string{"so:libssl.so.3"}},
+		{Name: "libssl3", Version: "3.3.2-r0", Provides: []string{"so:libssl.so.3"}},
+		{Name: "conflict", Version: "1.0-r0"},
+	}
+	keyer := newTestKeyer(nil, "wolfi", "v0.11.3", nil, candidate)
+
+	got := keyer.resolve([]string{"so:libssl.so.3", "openssl>=3", "busybox", "!conflict"})
+	expected := map[string]string{"libssl3": "3.3.2-r0", "openssl": "3.3.2-r0"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+}
+
+func TestKeyedPackages(t *testing.T) {
+	repoPath := t.TempDir()
+	configs := map[string]string{
+		"curl": "package:\n  name: curl\ntest:\n  environment:\n    contents:\n      packages:\n        - openssl\n",
+		"wget": "package:\n  name: wget\n",
+	}
+	for name, config := range configs {
+		if err := os.WriteFile(filepath.Join(repoPath, name+".yaml"), []byte(config), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	locator := &flatLocator{repoPath: repoPath}
+	base := []Package{{Name: "wget", Origin: "wget", Dependencies: []string{"so:libssl.so.3"}}}
+	candidate := []apkIndexEntry{{Name: "openssl", Version: "3.3.2-r0", Provides: []string{"so:libssl.so.3"}}}
+	keyer := newTestKeyer(locator, "wolfi", "v0.11.3", base, candidate)
+
+	keys, unchanged := keyedPackages(keyer, nil, []string{"curl", "wget", "missing"})
+	if len(keys) != 2 || keys["curl"] == "" || keys["wget"] == "" {
+		t.Fatalf("Expected keys for curl and wget, got %v", keys)
+	}
+	if len(unchanged) != 0 {
+		t.Errorf("Expected no unchanged packages without passes, got %v", unchanged)
+	}
+
+	_, unchanged = keyedPackages(keyer, map[string]string{keys["wget"]: "run-1"}, []string{"curl", "wget"})
+	if !reflect.DeepEqual(unchanged, map[string]string{"wget": "run-1"}) {
+		t.Errorf("Expected wget to be unchanged since run-1, got %v", unchanged)
+	}
+
+	// A new candidate version of a dependency invalidates the pass
+	updated := newTestKeyer(locator, "wolfi", "v0.11.3", base, []apkIndexEntry{{Name: "openssl", Version: "3.3.3-r0", Provides: []string{"so:libssl.so.3"}}})
+	_, unchanged = keyedPackages(updated, map[string]string{keys["wget"]: "run-1"}, []string{"wget"})
+	if len(unchanged) != 0 {
+		t.Errorf("Expected a new dependency version to change the key, got %v", unchanged)
+	}
+}
+
+func TestPassedKeys(t *testing.T) {
+	db, err := NewResultsDB(filepath.Join(t.TempDir(), "results.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	runs := []RunRecord{
+		{RunID: "run-1", Packages: []PackageRecord{
+			{Package: "curl", Status: StatusPass, Key: "sha256:a"},
+			{Package: "wget", Status: StatusFail, Key: "sha256:b"},
+			{Package: "git", Status: StatusPass},
+		}},
+		{RunID: "run-2", Packages: []PackageRecord{
+			{Package: "curl", Status: StatusPass, Key: "sha256:a"},
+		}},
+	}
+	for _, run := range runs {
+		if err := db.Append(run); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	passed, err := db.PassedKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{"sha256:a": "run-2"}
+	if !reflect.DeepEqual(passed, expected) {
+		t.Errorf("Expected %v, got %v", expected, passed)
+	}
+}
+
+func TestRunnerSkipsUnchangedPackages(t *testing.T) {
+	dir := t.TempDir()
+	runner := NewRegressionTestRunnerFromPackageList([]string{"curl", "wget"}, "https://example.com/repo", "/tmp", "wolfi", 2, true, time.Minute, false)
+	runner.setLogDir(filepath.Join(dir, "logs"))
+	db, err := NewResultsDB(filepath.Join(dir, "results.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	runner.SetResultsDB(db)
+
+	// Keys are normally computed by loadTestKeys, which needs the indexes
+	runner.testKeys = map[string]string{"curl": "sha256:curl", "wget": "sha256:wget"}
+	runner.unchanged = map[string]string{"wget": "run-1"}
+
+	var mu sync.Mutex
+	var tested []string
+	runner.SetExecutor(TestExecutorFunc(func(ctx context.Context, pkg string, opts ExecuteOptions) TestResult {
+		mu.Lock()
+		tested = append(tested, pkg)
+		mu.Unlock()
+		return newTestResult(pkg, opts.WithRepo, time.Now(), "", nil)
+	}))
+
+	if err := runner.RunFromPackageList([]string{"curl", "wget"}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if !reflect.DeepEqual(tested, []string{"curl"}) {
+		t.Errorf("Expected only curl to be tested, got %v", tested)
+	}
+	successful := append([]string(nil), runner.summary.Successful...)
+	sort.Strings(successful)
+	if !reflect.DeepEqual(successful, []string{"curl", "wget"}) {
+		t.Errorf("Expected both packages to pass, got %v", runner.summary.Successful)
+	}
+
+	runs, err := db.Runs()
+	if err != nil || len(runs) != 1 {
+		t.Fatalf("Expected one recorded run, got %d (%v)", len(runs), err)
+	}
+	for _, pkg := range runs[0].Packages {
+		if pkg.Key != runner.testKeys[pkg.Package] || pkg.Status != StatusPass {
+			t.Errorf("Expected %s to be recorded as a pass with its key, got %+v", pkg.Package, pkg)
+		}
+	}
+}
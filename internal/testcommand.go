@@ -0,0 +1,102 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// testCommandData is what a test command template is rendered with
+type testCommandData struct {
+	// Package is the package under test and Config its melange config,
+	// relative to the repository
+	Package string
+	Config  string
+	// Target is the name of the config without its extension, e.g. the
+	// make target is test/<Target>
+	Target   string
+	RepoPath string
+	// ExtraRepos adds the candidate repository and the pins when testing
+	// with it, e.g. "--repository-append https://... --test-package-append
+	// openssl=3.3.2-r1"
+	ExtraRepos string
+	// ExtraOpts are the other melange options of the test, e.g. caches,
+	// runner and pipeline filters
+	ExtraOpts string
+	// WorkDir is the scratch directory of the test, also set as TMPDIR
+	WorkDir string
+}
+
+// SetTestCommand runs tests with a shell command rendered from the Go
+// template text instead of `make test/<config>`, for repositories without
+// a Makefile, e.g.
+//
+//	melange test {{.Config}} {{.ExtraRepos}} {{.ExtraOpts}}
+//
+// See testCommandData for the fields available.
+func (m *MelangeClient) SetTestCommand(text string) error {
+	tmpl, err := template.New("test-command").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return fmt.Errorf("invalid test command template: %w", err)
+	}
+	// Unknown fields only fail when rendering, so catch them now
+	if err := tmpl.Execute(&strings.Builder{}, testCommandData{}); err != nil {
+		return fmt.Errorf("invalid test command template: %w", err)
+	}
+	m.testCommand = tmpl
+	m.testCommandText = text
+	return nil
+}
+
+// CheckTools makes sure the tools tests need are installed. A test command
+// names its own tools, which show up in the log when missing.
+func (m *MelangeClient) CheckTools() error {
+	if m.testCommand != nil {
+		return nil
+	}
+	return CheckMelangeTools()
+}
+
+// command returns the command running the test of configPath, and how to
+// describe it in errors. extraOpts are the melange options of the test
+// besides the candidate repository.
+func (m *MelangeClient) command(packageName, configPath string, opts ExecuteOptions, workDir string, extraOpts []string) (*exec.Cmd, string, error) {
+	target := configTarget(configPath)
+	if m.testCommand == nil {
+		// The make target is named after the config, which differs from
+		// the package name for subpackages and renamed configs
+		target = fmt.Sprintf("test/%s", target)
+		return exec.Command("make", target), "make " + target, nil
+	}
+
+	repoPath := m.repoPath
+	if opts.Previous != "" {
+		repoPath = opts.Previous
+	}
+	config := configPath
+	if rel, err := filepath.Rel(repoPath, configPath); err == nil && !strings.HasPrefix(rel, "..") {
+		config = rel
+	}
+	data := testCommandData{
+		Package:  packageName,
+		Config:   config,
+		Target:   target,
+		RepoPath: repoPath,
+		WorkDir:  workDir,
+	}
+	if opts.WithRepo {
+		data.ExtraRepos = strings.Join(append(repoOpts(opts.APKRepo), m.pinOpts()...), " ")
+	}
+	data.ExtraOpts = strings.Join(extraOpts, " ")
+
+	var command strings.Builder
+	if err := m.testCommand.Execute(&command, data); err != nil {
+		return nil, "", fmt.Errorf("failed to render test command: %w", err)
+	}
+	return exec.Command("sh", "-c", command.String()), command.String(), nil
+}
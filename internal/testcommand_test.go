@@ -0,0 +1,96 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSetTestCommand(t *testing.T) {
+	tests := []struct {
+		name    string
+		command string
+		wantErr bool
+	}{
+		{"fields", "melange test {{.Config}} {{.ExtraRepos}} {{.ExtraOpts}}", false},
+		{"no fields", "true", false},
+		{"syntax error", "melange test {{.Config}", true},
+		{"unknown field", "melange test {{.Yaml}}", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := NewMelangeClient(t.TempDir(), false, t.TempDir(), time.Minute)
+			err := client.SetTestCommand(tt.command)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Expected error %v, got %v", tt.wantErr, err)
+			}
+			if err == nil && client.CheckTools() != nil {
+				t.Errorf("Expected no make or melange check with a test command")
+			}
+		})
+	}
+}
+
+func TestTestCommand(t *testing.T) {
+	tmpDir := t.TempDir()
+	logDir := filepath.Join(tmpDir, "logs")
+	os.MkdirAll(logDir, 0755)
+	os.WriteFile(filepath.Join(tmpDir, "test-package.yaml"), []byte("test"), 0644)
+
+	client := NewMelangeClient(tmpDir, false, logDir, time.Minute)
+	client.SetRunner("docker")
+	if err := client.SetTestCommand("echo pkg={{.Package}} config={{.Config}} target={{.Target}} repos={{.ExtraRepos}} opts={{.ExtraOpts}}; test -d $TMPDIR"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		withRepo bool
+		expected string
+	}{
+		{"with repo", true, "pkg=test-package config=test-package.yaml target=test-package repos=--repository-append https://example.com/repo opts=--runner docker"},
+		{"without repo", false, "pkg=test-package config=test-package.yaml target=test-package repos= opts=--runner docker"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := client.Execute(context.Background(), "test-package", ExecuteOptions{WithRepo: tt.withRepo, APKRepo: "https://example.com/repo"})
+			if !result.Success {
+				t.Fatalf("Expected test to pass, got %v", result.Error)
+			}
+			content, _ := os.ReadFile(result.LogPath)
+			if strings.TrimSpace(string(content)) != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, content)
+			}
+		})
+	}
+
+	// With-repo tests get the pins like MELANGE_EXTRA_OPTS does
+	client.SetPins([]Pin{{Name: "openssl", Version: "3.3.2-r1"}})
+	result := client.Execute(context.Background(), "test-package", ExecuteOptions{WithRepo: true, APKRepo: "https://example.com/repo"})
+	content, _ := os.ReadFile(result.LogPath)
+	if expected := "repos=--repository-append https://example.com/repo --test-package-append openssl=3.3.2-r1 opts="; !strings.Contains(string(content), expected) {
+		t.Errorf("Expected the pins in %q, got %q", expected, content)
+	}
+	result = client.Execute(context.Background(), "test-package", ExecuteOptions{APKRepo: "https://example.com/repo"})
+	content, _ = os.ReadFile(result.LogPath)
+	if strings.Contains(string(content), "--test-package-append") {
+		t.Errorf("Expected no pins without the candidate repository, got %q", content)
+	}
+
+	// Failures name the rendered command
+	if err := client.SetTestCommand("exit 3 # {{.Target}}"); err != nil {
+		t.Fatal(err)
+	}
+	result = client.Execute(context.Background(), "test-package", ExecuteOptions{})
+	if result.Success || !strings.Contains(result.Error.Error(), "exit 3 # test-package failed") {
+		t.Errorf("Expected the failing command in the error, got %v", result.Error)
+	}
+}
@@ -0,0 +1,65 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"regexp"
+)
+
+// stepPattern matches the line melange logs when a test pipeline step
+// starts, e.g. `INFO running step "python/import"`. Steps without a name
+// are logged with the pipeline they use.
+var stepPattern = regexp.MustCompile(`running step "?([^"]+?)"?\s*$`)
+
+// FailingStep returns the test pipeline step a failed melange test stopped
+// in: the last step started in its log, or "" if none was logged
+func FailingStep(logPath string) string {
+	file, err := os.Open(logPath)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	var step string
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if match := stepPattern.FindStringSubmatch(scanner.Text()); match != nil {
+			step = match[1]
+		}
+	}
+	return step
+}
+
+// MatchStep tells whether a failing step matches one of patterns, which are
+// path.Match globs such as "python/*". Everything matches without patterns.
+func MatchStep(step string, patterns []string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, step); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// FilterRegressionsByStep keeps the regressions whose failing step matches
+// one of patterns
+func FilterRegressionsByStep(regressions []Regression, patterns []string) []Regression {
+	if len(patterns) == 0 {
+		return regressions
+	}
+	var filtered []Regression
+	for _, regression := range regressions {
+		if MatchStep(regression.FailingStep, patterns) {
+			filtered = append(filtered, regression)
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,68 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestFailingStep(t *testing.T) {
+	tests := []struct {
+		name     string
+		log      string
+		expected string
+	}{
+		{
+			name:     "last step started",
+			log:      "INFO running step \"uses: test/tw/ldd-check\"\nINFO running step \"python/import\"\nERRO failed to test package\n",
+			expected: "python/import",
+		},
+		{
+			name:     "unquoted step",
+			log:      "ℹ️  x86_64    | running step Check version\nexit status 1\n",
+			expected: "Check version",
+		},
+		{
+			name:     "no steps",
+			log:      "Error: unsatisfiable constraints\n",
+			expected: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "test.log")
+			if err := os.WriteFile(path, []byte(tt.log), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if got := FailingStep(path); got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+
+	if got := FailingStep(filepath.Join(t.TempDir(), "missing.log")); got != "" {
+		t.Errorf("Expected no step for a missing log, got %q", got)
+	}
+}
+
+func TestFilterRegressionsByStep(t *testing.T) {
+	regressions := []Regression{
+		{Package: "py3-requests", FailingStep: "python/import"},
+		{Package: "curl", FailingStep: "Check version"},
+		{Package: "git"},
+	}
+
+	if got := FilterRegressionsByStep(regressions, nil); !reflect.DeepEqual(got, regressions) {
+		t.Errorf("Expected every regression without patterns, got %+v", got)
+	}
+
+	got := FilterRegressionsByStep(regressions, []string{"python/*", "test/*"})
+	if len(got) != 1 || got[0].Package != "py3-requests" {
+		t.Errorf("Expected only py3-requests, got %+v", got)
+	}
+}
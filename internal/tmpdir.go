@@ -0,0 +1,76 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+// tempPrefix starts the name of the temporary directory of every run,
+// followed by the process ID, so that leftovers can be attributed to runs
+const tempPrefix = "apkregress-"
+
+// runTempDirs are the temporary directories of the runs in progress, for
+// CleanupTempDirs to remove when the process is stopped before the runs
+// finish
+var runTempDirs = struct {
+	sync.Mutex
+	paths map[string]bool
+}{paths: make(map[string]bool)}
+
+// SetTempRoot creates the temporary directories of runs in root instead of
+// the system's temporary directory
+func (r *RegressionTestRunner) SetTempRoot(root string) {
+	r.tempRoot = root
+}
+
+// makeRunTempDir creates the temporary directory of a run in root, or the
+// system's temporary directory if root is empty. Every run gets its own, so
+// runs sharing a host don't collide.
+func makeRunTempDir(root string) (string, error) {
+	if root == "" {
+		root = os.TempDir()
+	}
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return "", fmt.Errorf("failed to create temp root %s: %w", root, err)
+	}
+	dir, err := os.MkdirTemp(root, fmt.Sprintf("%s%d-", tempPrefix, os.Getpid()))
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp directory in %s: %w", root, err)
+	}
+
+	runTempDirs.Lock()
+	runTempDirs.paths[dir] = true
+	runTempDirs.Unlock()
+	return dir, nil
+}
+
+// removeRunTempDir removes the temporary directory of a finished run
+func removeRunTempDir(dir string) {
+	runTempDirs.Lock()
+	delete(runTempDirs.paths, dir)
+	runTempDirs.Unlock()
+
+	// What can't be removed is reported by auditTempLeaks
+	os.RemoveAll(dir)
+}
+
+// CleanupTempDirs removes the temporary directories of all runs in
+// progress, for when the process is stopped, e.g. by a signal or the
+// watchdog, without the runs cleaning up after themselves
+func CleanupTempDirs() {
+	runTempDirs.Lock()
+	paths := make([]string, 0, len(runTempDirs.paths))
+	for path := range runTempDirs.paths {
+		paths = append(paths, path)
+	}
+	runTempDirs.paths = make(map[string]bool)
+	runTempDirs.Unlock()
+
+	for _, path := range paths {
+		os.RemoveAll(path)
+	}
+}
@@ -0,0 +1,70 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunTempDirs(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "missing", "root")
+
+	first, err := makeRunTempDir(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := makeRunTempDir(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first == second {
+		t.Errorf("Expected runs to get their own directories, both got %s", first)
+	}
+	prefix := fmt.Sprintf("%s%d-", tempPrefix, os.Getpid())
+	if !strings.HasPrefix(filepath.Base(first), prefix) {
+		t.Errorf("Expected %s to start with %s", first, prefix)
+	}
+
+	removeRunTempDir(first)
+	if _, err := os.Stat(first); !os.IsNotExist(err) {
+		t.Errorf("Expected %s to be removed", first)
+	}
+
+	CleanupTempDirs()
+	if _, err := os.Stat(second); !os.IsNotExist(err) {
+		t.Errorf("Expected %s to be removed by the cleanup", second)
+	}
+}
+
+func TestRunnerTempRoot(t *testing.T) {
+	root := t.TempDir()
+	runner := NewRegressionTestRunnerFromPackageList([]string{"curl", "git"}, "https://example.com/repo", "/tmp", "wolfi", 2, false, time.Minute, false)
+	runner.setLogDir(t.TempDir())
+	runner.SetTempRoot(root)
+
+	runner.SetExecutor(TestExecutorFunc(func(ctx context.Context, pkg string, opts ExecuteOptions) TestResult {
+		if !strings.HasPrefix(opts.WorkDir, root+string(filepath.Separator)) {
+			t.Errorf("Expected the scratch directory of %s in %s, got %s", pkg, root, opts.WorkDir)
+		}
+		return newTestResult(pkg, opts.WithRepo, time.Now(), "", nil)
+	}))
+
+	if err := runner.RunFromPackageList([]string{"curl", "git"}); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("Expected the temp root to be cleaned up, found %d entries", len(entries))
+	}
+}
@@ -0,0 +1,197 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Toolchain is the tooling a test ran with. Regressions sometimes stem from
+// tooling that changed during a run, e.g. melange upgraded or the Makefile
+// edited, rather than from the candidate repository.
+type Toolchain struct {
+	Melange string `json:"melange,omitempty"`
+	// Makefile is the git blob SHA of the package repository's Makefile,
+	// which also tells uncommitted edits apart
+	Makefile string `json:"makefile,omitempty"`
+	Kernel   string `json:"kernel,omitempty"`
+	QEMU     string `json:"qemu,omitempty"`
+}
+
+// toolVersionCache caches the version of each tool binary by path until the
+// binary changes, so recording the toolchain of every test stays cheap
+var toolVersionCache = struct {
+	sync.Mutex
+	versions map[string]cachedToolVersion
+}{versions: make(map[string]cachedToolVersion)}
+
+type cachedToolVersion struct {
+	modTime time.Time
+	size    int64
+	version string
+}
+
+// probeToolchain returns the toolchain tests in repoPath currently run with
+func probeToolchain(repoPath string) Toolchain {
+	return Toolchain{
+		Melange:  toolVersion("melange", "version"),
+		Makefile: gitBlobSHA(filepath.Join(repoPath, "Makefile")),
+		Kernel:   kernelRelease(),
+		QEMU:     toolVersion("qemu-system-"+hostArch(), "--version"),
+	}
+}
+
+// toolVersion returns the version line of the installed tool, or "" if it
+// isn't installed
+func toolVersion(name string, args ...string) string {
+	path, err := exec.LookPath(name)
+	if err != nil {
+		return ""
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return ""
+	}
+
+	toolVersionCache.Lock()
+	cached, ok := toolVersionCache.versions[path]
+	toolVersionCache.Unlock()
+	if ok && cached.modTime.Equal(info.ModTime()) && cached.size == info.Size() {
+		return cached.version
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	output, err := exec.CommandContext(ctx, path, args...).CombinedOutput()
+	if err != nil {
+		return ""
+	}
+	version := versionLine(string(output))
+
+	toolVersionCache.Lock()
+	toolVersionCache.versions[path] = cachedToolVersion{modTime: info.ModTime(), size: info.Size(), version: version}
+	toolVersionCache.Unlock()
+	return version
+}
+
+// gitBlobSHA returns the SHA git gives the contents of path, or "" if it
+// can't be read
+func gitBlobSHA(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	h := sha1.New()
+	fmt.Fprintf(h, "blob %d\x00", len(data))
+	h.Write(data)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// kernelRelease returns the release of the running kernel, e.g.
+// "6.8.0-45-generic"
+func kernelRelease() string {
+	if data, err := os.ReadFile("/proc/sys/kernel/osrelease"); err == nil {
+		return strings.TrimSpace(string(data))
+	}
+	output, err := exec.Command("uname", "-r").Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(output))
+}
+
+// changes describes how the toolchain differs from other, e.g.
+// "melange v0.11.3 → v0.11.4"
+func (t Toolchain) changes(other Toolchain) []string {
+	var changes []string
+	for _, tool := range []struct{ name, from, to string }{
+		{"melange", other.Melange, t.Melange},
+		{"Makefile", shortSHA(other.Makefile), shortSHA(t.Makefile)},
+		{"kernel", other.Kernel, t.Kernel},
+		{"qemu", other.QEMU, t.QEMU},
+	} {
+		// Toolchains recorded before a tool was probed have nothing to
+		// compare
+		if tool.from != tool.to && tool.from != "" && tool.to != "" {
+			changes = append(changes, fmt.Sprintf("%s %s → %s", tool.name, tool.from, tool.to))
+		}
+	}
+	return changes
+}
+
+// toolchainOrNil leaves toolchains that weren't recorded out of results.json
+func toolchainOrNil(t Toolchain) *Toolchain {
+	if t == (Toolchain{}) {
+		return nil
+	}
+	return &t
+}
+
+func shortSHA(sha string) string {
+	if len(sha) > 12 {
+		return sha[:12]
+	}
+	return sha
+}
+
+// ToolchainChanges describes how the tooling tests in repoPath would run
+// with now differs from recorded
+func ToolchainChanges(recorded Toolchain, repoPath string) []string {
+	return probeToolchain(repoPath).changes(recorded)
+}
+
+// toolingNote describes how the tooling changed from a with-repo test to
+// the without-repo test it is compared with, or returns "" if it didn't
+func toolingNote(withRepo, withoutRepo TestResult, format string) string {
+	changes := withoutRepo.Toolchain.changes(withRepo.Toolchain)
+	if len(changes) == 0 {
+		return ""
+	}
+	return fmt.Sprintf(format, strings.Join(changes, ", "))
+}
+
+// toolingDrift describes how the tooling changed over the run, comparing
+// the toolchain of every test with that of the first one
+func toolingDrift(packageResults map[string]map[bool]TestResult) []string {
+	var results []TestResult
+	for _, byRepo := range packageResults {
+		for _, result := range byRepo {
+			if !result.StartedAt.IsZero() && result.Toolchain != (Toolchain{}) {
+				results = append(results, result)
+			}
+		}
+	}
+	if len(results) == 0 {
+		return nil
+	}
+
+	first := results[0]
+	for _, result := range results[1:] {
+		if result.StartedAt.Before(first.StartedAt) {
+			first = result
+		}
+	}
+	seen := make(map[string]bool)
+	var drift []string
+	for _, result := range results {
+		for _, change := range result.Toolchain.changes(first.Toolchain) {
+			if !seen[change] {
+				seen[change] = true
+				drift = append(drift, change)
+			}
+		}
+	}
+	sort.Strings(drift)
+	return drift
+}
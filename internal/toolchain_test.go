@@ -0,0 +1,119 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGitBlobSHA(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		content  string
+		expected string
+	}{
+		{"", "e69de29bb2d1d6434b8b29ae775ad8c2e48c5391"},
+		{"hello\n", "ce013625030ba8dba906f756967f9e9ca394464a"},
+	}
+
+	for _, tt := range tests {
+		path := filepath.Join(dir, "Makefile")
+		os.WriteFile(path, []byte(tt.content), 0644)
+		if got := gitBlobSHA(path); got != tt.expected {
+			t.Errorf("Expected %s for %q, got %s", tt.expected, tt.content, got)
+		}
+	}
+
+	if got := gitBlobSHA(filepath.Join(dir, "missing")); got != "" {
+		t.Errorf("Expected no SHA for a missing file, got %s", got)
+	}
+}
+
+func TestToolchainChanges(t *testing.T) {
+	base := Toolchain{Melange: "v0.11.3", Makefile: "ce013625030ba8dba906f756967f9e9ca394464a", Kernel: "6.8.0"}
+	tests := []struct {
+		name     string
+		other    Toolchain
+		expected []string
+	}{
+		{"same", base, nil},
+		{"melange upgraded", Toolchain{Melange: "v0.11.4", Makefile: base.Makefile, Kernel: "6.8.0"}, []string{"melange v0.11.3 → v0.11.4"}},
+		{"makefile edited", Toolchain{Melange: "v0.11.3", Makefile: "e69de29bb2d1d6434b8b29ae775ad8c2e48c5391", Kernel: "6.8.0"}, []string{"Makefile ce013625030b → e69de29bb2d1"}},
+		{"not recorded", Toolchain{}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.other.changes(base); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestToolingDrift(t *testing.T) {
+	start := time.Now()
+	results := map[string]map[bool]TestResult{
+		"curl": {
+			true:  {Package: "curl", WithRepo: true, StartedAt: start, Toolchain: Toolchain{Melange: "v1"}},
+			false: {Package: "curl", StartedAt: start.Add(time.Minute), Toolchain: Toolchain{Melange: "v2"}},
+		},
+		"git": {
+			true: {Package: "git", WithRepo: true, StartedAt: start.Add(2 * time.Minute), Toolchain: Toolchain{Melange: "v2"}},
+		},
+		// Cached results weren't tested in this run
+		"wget": {
+			true: {Package: "wget", WithRepo: true},
+		},
+	}
+
+	if got := toolingDrift(results); !reflect.DeepEqual(got, []string{"melange v1 → v2"}) {
+		t.Errorf("Expected the melange upgrade, got %v", got)
+	}
+	if note := toolingNote(results["curl"][true], results["curl"][false], " (tooling changed: %s)"); note != " (tooling changed: melange v1 → v2)" {
+		t.Errorf("Expected a tooling note, got %q", note)
+	}
+	if note := toolingNote(results["git"][true], results["curl"][false], " (%s)"); note != "" {
+		t.Errorf("Expected no tooling note, got %q", note)
+	}
+}
+
+func TestToolVersionCache(t *testing.T) {
+	dir := t.TempDir()
+	tool := filepath.Join(dir, "fake-tool")
+	os.WriteFile(tool, []byte("#!/bin/sh\necho v1\n"), 0755)
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	if got := toolVersion("fake-tool"); got != "v1" {
+		t.Fatalf("Expected v1, got %q", got)
+	}
+
+	// Upgrading the tool is noticed
+	os.WriteFile(tool, []byte("#!/bin/sh\necho v2.0\n"), 0755)
+	if got := toolVersion("fake-tool"); got != "v2.0" {
+		t.Errorf("Expected v2.0 after the upgrade, got %q", got)
+	}
+
+	if got := toolVersion("missing-fake-tool"); got != "" {
+		t.Errorf("Expected no version for a missing tool, got %q", got)
+	}
+}
+
+func TestTestResultToolchainJSON(t *testing.T) {
+	data, _ := json.Marshal(TestResult{Package: "curl"})
+	if strings.Contains(string(data), "toolchain") {
+		t.Errorf("Expected no toolchain when none was recorded, got %s", data)
+	}
+
+	data, _ = json.Marshal(TestResult{Package: "curl", Toolchain: Toolchain{Melange: "v0.11.3", Kernel: "6.8.0"}})
+	if !strings.Contains(string(data), `"toolchain":{"melange":"v0.11.3","kernel":"6.8.0"}`) {
+		t.Errorf("Expected the toolchain, got %s", data)
+	}
+}
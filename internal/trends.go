@@ -0,0 +1,254 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// PackageTrend aggregates the recorded results of one package
+type PackageTrend struct {
+	Package  string
+	RepoType string
+	// Runs counts the runs that tested the package; skips aren't counted
+	Runs        int
+	Passes      int
+	Failures    int
+	Regressions int
+	Hung        int
+	// Flips counts how often the outcome changed between passing and not
+	// passing from one run to the next
+	Flips           int
+	AverageDuration time.Duration
+	LastStatus      string
+	LastSeen        time.Time
+}
+
+// RegressionRate is the share of runs in which the package regressed
+func (p PackageTrend) RegressionRate() float64 {
+	if p.Runs == 0 {
+		return 0
+	}
+	return float64(p.Regressions) / float64(p.Runs)
+}
+
+// FlakinessRate is the share of consecutive runs in which the outcome
+// flipped; a package that alternates between passing and failing scores 1
+func (p PackageTrend) FlakinessRate() float64 {
+	if p.Runs < 2 {
+		return 0
+	}
+	return float64(p.Flips) / float64(p.Runs-1)
+}
+
+// WeekTrend aggregates the runs started in one week
+type WeekTrend struct {
+	Start       time.Time
+	Runs        int
+	Tested      int
+	Regressions int
+}
+
+// AnnotatedRun is a run the operator left notes on (see SetNotes)
+type AnnotatedRun struct {
+	RunID       string
+	Target      string
+	StartedAt   time.Time
+	Regressions int
+	Notes       []string
+}
+
+// Trends summarises the results database
+type Trends struct {
+	Packages []PackageTrend
+	Weeks    []WeekTrend
+	// Annotated are the runs with notes, oldest first
+	Annotated []AnnotatedRun
+}
+
+// ComputeTrends aggregates the runs started at or after since. Packages are
+// ordered by regression rate, then flakiness, weeks oldest first.
+func ComputeTrends(runs []RunRecord, since time.Time) *Trends {
+	runs = append([]RunRecord(nil), runs...)
+	sort.SliceStable(runs, func(i, j int) bool { return runs[i].StartedAt.Before(runs[j].StartedAt) })
+
+	type key struct{ pkg, repoType string }
+	packages := make(map[key]*PackageTrend)
+	durations := make(map[key]time.Duration)
+	timed := make(map[key]int)
+	weeks := make(map[time.Time]*WeekTrend)
+	trends := &Trends{}
+
+	for _, run := range runs {
+		if run.StartedAt.Before(since) {
+			continue
+		}
+
+		start := weekStart(run.StartedAt)
+		week := weeks[start]
+		if week == nil {
+			week = &WeekTrend{Start: start}
+			weeks[start] = week
+		}
+		week.Runs++
+
+		var annotated *AnnotatedRun
+		if len(run.Notes) > 0 {
+			annotated = &AnnotatedRun{RunID: run.RunID, Target: run.Target, StartedAt: run.StartedAt, Notes: run.Notes}
+		}
+
+		for _, pkg := range run.Packages {
+			if pkg.Status == StatusSkipped || pkg.Status == StatusArchExcluded || pkg.Status == StatusUntestable || pkg.Status == StatusInfra {
+				continue
+			}
+
+			k := key{pkg.Package, run.RepoType}
+			trend := packages[k]
+			if trend == nil {
+				trend = &PackageTrend{Package: pkg.Package, RepoType: run.RepoType}
+				packages[k] = trend
+			}
+
+			if trend.Runs > 0 && (trend.LastStatus == StatusPass) != (pkg.Status == StatusPass) {
+				trend.Flips++
+			}
+			trend.Runs++
+			switch pkg.Status {
+			case StatusPass:
+				trend.Passes++
+			case StatusFail:
+				trend.Failures++
+			case StatusRegression:
+				trend.Regressions++
+				week.Regressions++
+				if annotated != nil {
+					annotated.Regressions++
+				}
+			case StatusHung:
+				trend.Hung++
+			}
+			trend.LastStatus = pkg.Status
+			trend.LastSeen = run.StartedAt
+			week.Tested++
+
+			if pkg.Duration > 0 {
+				durations[k] += pkg.Duration
+				timed[k]++
+			}
+		}
+		if annotated != nil {
+			trends.Annotated = append(trends.Annotated, *annotated)
+		}
+	}
+
+	for k, trend := range packages {
+		if timed[k] > 0 {
+			trend.AverageDuration = durations[k] / time.Duration(timed[k])
+		}
+		trends.Packages = append(trends.Packages, *trend)
+	}
+	sort.Slice(trends.Packages, func(i, j int) bool {
+		a, b := trends.Packages[i], trends.Packages[j]
+		if a.RegressionRate() != b.RegressionRate() {
+			return a.RegressionRate() > b.RegressionRate()
+		}
+		if a.FlakinessRate() != b.FlakinessRate() {
+			return a.FlakinessRate() > b.FlakinessRate()
+		}
+		if a.Package != b.Package {
+			return a.Package < b.Package
+		}
+		return a.RepoType < b.RepoType
+	})
+
+	for _, week := range weeks {
+		trends.Weeks = append(trends.Weeks, *week)
+	}
+	sort.Slice(trends.Weeks, func(i, j int) bool { return trends.Weeks[i].Start.Before(trends.Weeks[j].Start) })
+
+	return trends
+}
+
+// weekStart returns midnight UTC of the Monday starting t's week
+func weekStart(t time.Time) time.Time {
+	t = t.UTC()
+	offset := (int(t.Weekday()) + 6) % 7
+	return time.Date(t.Year(), t.Month(), t.Day()-offset, 0, 0, 0, 0, time.UTC)
+}
+
+// WriteTables writes the package and weekly tables, listing at most limit
+// packages if limit is positive
+func (t *Trends) WriteTables(w io.Writer, limit int) error {
+	packages := t.Packages
+	if limit > 0 && len(packages) > limit {
+		packages = packages[:limit]
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "PACKAGE\tREPOSITORY\tRUNS\tREGRESSIONS\tREGRESSION RATE\tFLAKINESS\tHUNG\tAVG DURATION\tLAST STATUS\n")
+	for _, p := range packages {
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%.0f%%\t%.0f%%\t%d\t%v\t%s\n",
+			p.Package, p.RepoType, p.Runs, p.Regressions, p.RegressionRate()*100, p.FlakinessRate()*100,
+			p.Hung, p.AverageDuration.Round(time.Second), p.LastStatus)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	if len(packages) < len(t.Packages) {
+		fmt.Fprintf(w, "(%d more packages)\n", len(t.Packages)-len(packages))
+	}
+
+	fmt.Fprintln(w)
+	tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "WEEK\tRUNS\tPACKAGES TESTED\tREGRESSIONS\n")
+	for _, week := range t.Weeks {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\n", week.Start.Format("2006-01-02"), week.Runs, week.Tested, week.Regressions)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	if len(t.Annotated) == 0 {
+		return nil
+	}
+
+	fmt.Fprintln(w)
+	tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "STARTED\tRUN\tTARGET\tREGRESSIONS\tNOTES\n")
+	for _, run := range t.Annotated {
+		notes := strings.ReplaceAll(strings.Join(run.Notes, "; "), "\n", " ")
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%s\n", run.StartedAt.Format("2006-01-02 15:04"), run.RunID, run.Target, run.Regressions, notes)
+	}
+	return tw.Flush()
+}
+
+// WriteCSV writes one row per package
+func (t *Trends) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"package", "repo_type", "runs", "passes", "failures", "regressions", "hung", "regression_rate", "flakiness_rate", "avg_duration_seconds", "last_status", "last_seen"})
+	for _, p := range t.Packages {
+		cw.Write([]string{
+			p.Package,
+			p.RepoType,
+			strconv.Itoa(p.Runs),
+			strconv.Itoa(p.Passes),
+			strconv.Itoa(p.Failures),
+			strconv.Itoa(p.Regressions),
+			strconv.Itoa(p.Hung),
+			strconv.FormatFloat(p.RegressionRate(), 'f', 4, 64),
+			strconv.FormatFloat(p.FlakinessRate(), 'f', 4, 64),
+			strconv.FormatFloat(p.AverageDuration.Seconds(), 'f', 0, 64),
+			p.LastStatus,
+			p.LastSeen.Format(time.RFC3339),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,144 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"bytes"
+	"encoding/csv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func trendRuns() []RunRecord {
+	monday := time.Date(2025, 1, 6, 12, 0, 0, 0, time.UTC)
+	statuses := [][2]string{
+		{StatusPass, StatusRegression},
+		{StatusFail, StatusRegression},
+		{StatusPass, StatusPass},
+		{StatusFail, StatusSkipped},
+	}
+
+	var runs []RunRecord
+	for i, s := range statuses {
+		runs = append(runs, RunRecord{
+			RunID:     "run",
+			Target:    "openssl",
+			RepoType:  "wolfi",
+			StartedAt: monday.Add(time.Duration(i) * 72 * time.Hour),
+			Packages: []PackageRecord{
+				{Package: "curl", Status: s[0], Duration: time.Duration(i+1) * time.Minute},
+				{Package: "git", Status: s[1], Duration: 2 * time.Minute},
+			},
+		})
+	}
+	return runs
+}
+
+func TestComputeTrends(t *testing.T) {
+	trends := ComputeTrends(trendRuns(), time.Time{})
+
+	if len(trends.Packages) != 2 {
+		t.Fatalf("Expected 2 packages, got %d", len(trends.Packages))
+	}
+
+	git := trends.Packages[0]
+	if git.Package != "git" || git.Runs != 3 || git.Regressions != 2 {
+		t.Errorf("Expected git first with 3 runs and 2 regressions, got %+v", git)
+	}
+	if rate := git.RegressionRate(); rate < 0.66 || rate > 0.67 {
+		t.Errorf("Expected git regression rate 2/3, got %v", rate)
+	}
+
+	curl := trends.Packages[1]
+	if curl.Runs != 4 || curl.Flips != 3 || curl.FlakinessRate() != 1 {
+		t.Errorf("Expected curl to flip on every run, got %+v", curl)
+	}
+	if curl.AverageDuration != 150*time.Second {
+		t.Errorf("Expected curl average duration 2m30s, got %v", curl.AverageDuration)
+	}
+	if curl.LastStatus != StatusFail {
+		t.Errorf("Expected curl last status fail, got %s", curl.LastStatus)
+	}
+
+	// Runs on Jan 6 and 9 fall in the first week, Jan 12 in the same week
+	// (Sunday), Jan 15 in the next
+	if len(trends.Weeks) != 2 {
+		t.Fatalf("Expected 2 weeks, got %d", len(trends.Weeks))
+	}
+	if trends.Weeks[0].Runs != 3 || trends.Weeks[0].Regressions != 2 {
+		t.Errorf("Unexpected first week: %+v", trends.Weeks[0])
+	}
+	if trends.Weeks[1].Start.Format("2006-01-02") != "2025-01-13" {
+		t.Errorf("Expected second week to start 2025-01-13, got %v", trends.Weeks[1].Start)
+	}
+}
+
+func TestComputeTrendsSince(t *testing.T) {
+	runs := trendRuns()
+	trends := ComputeTrends(runs, runs[2].StartedAt)
+
+	for _, p := range trends.Packages {
+		if p.Package == "curl" && p.Runs != 2 {
+			t.Errorf("Expected 2 curl runs since the third run, got %d", p.Runs)
+		}
+	}
+}
+
+func TestTrendsOutput(t *testing.T) {
+	trends := ComputeTrends(trendRuns(), time.Time{})
+
+	var table bytes.Buffer
+	if err := trends.WriteTables(&table, 1); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.Contains(table.String(), "git") || strings.Contains(table.String(), "curl ") {
+		t.Errorf("Expected only git in the limited table, got:\n%s", table.String())
+	}
+	if !strings.Contains(table.String(), "(1 more packages)") {
+		t.Errorf("Expected truncation note, got:\n%s", table.String())
+	}
+
+	var out bytes.Buffer
+	if err := trends.WriteCSV(&out); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	records, err := csv.NewReader(&out).ReadAll()
+	if err != nil {
+		t.Fatalf("Invalid CSV: %v", err)
+	}
+	if len(records) != 3 || records[0][0] != "package" || records[1][0] != "git" {
+		t.Errorf("Unexpected CSV: %v", records)
+	}
+}
+
+func TestTrendsAnnotatedRuns(t *testing.T) {
+	runs := trendRuns()
+	runs[1].Notes = []string{"validating openssl 3.3 bump", "second\nline"}
+
+	trends := ComputeTrends(runs, time.Time{})
+	if len(trends.Annotated) != 1 {
+		t.Fatalf("Expected 1 annotated run, got %d", len(trends.Annotated))
+	}
+	if run := trends.Annotated[0]; run.Regressions != 1 || !run.StartedAt.Equal(runs[1].StartedAt) {
+		t.Errorf("Expected the second run with 1 regression, got %+v", run)
+	}
+
+	var table bytes.Buffer
+	if err := trends.WriteTables(&table, 0); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.Contains(table.String(), "validating openssl 3.3 bump; second line") {
+		t.Errorf("Expected the notes in the tables, got:\n%s", table.String())
+	}
+
+	// Runs without notes don't add the table
+	table.Reset()
+	if err := ComputeTrends(trendRuns(), time.Time{}).WriteTables(&table, 0); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if strings.Contains(table.String(), "NOTES") {
+		t.Errorf("Expected no annotated runs table, got:\n%s", table.String())
+	}
+}
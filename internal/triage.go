@@ -0,0 +1,191 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Triage verdicts for a regression
+const (
+	VerdictReal  = "real"
+	VerdictFlaky = "flaky"
+	VerdictKnown = "known"
+)
+
+// TriageDecision records how a maintainer classified a regression
+type TriageDecision struct {
+	Package   string    `json:"package"`
+	RepoType  string    `json:"repoType,omitempty"`
+	Target    string    `json:"target,omitempty"`
+	RunID     string    `json:"runId"`
+	Verdict   string    `json:"verdict"`
+	Issue     string    `json:"issue,omitempty"`
+	DecidedAt time.Time `json:"decidedAt"`
+}
+
+// Regression is a regression listed in a run's log directory
+type Regression struct {
+	Package  string
+	RepoType string
+	LogPath  string
+	// ArtifactsDir holds the artifacts of the with-repo test, if collected
+	ArtifactsDir string
+	// WorkspaceSnapshot is the archived workspace of a re-run of the
+	// with-repo test, if one was taken
+	WorkspaceSnapshot string
+	// FailingStep is the test pipeline step the with-repo test stopped in
+	FailingStep string
+}
+
+// triagePath is where triage decisions are stored, next to the runs, e.g.
+// results-triage.jsonl for results.jsonl
+func (db *ResultsDB) triagePath() string {
+	return strings.TrimSuffix(db.path, filepath.Ext(db.path)) + "-triage.jsonl"
+}
+
+// AppendTriage records a triage decision
+func (db *ResultsDB) AppendTriage(decision TriageDecision) error {
+	return appendJSONLine(db.triagePath(), decision)
+}
+
+// TriageDecisions returns all recorded decisions, oldest first
+func (db *ResultsDB) TriageDecisions() ([]TriageDecision, error) {
+	file, err := os.Open(db.triagePath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open triage decisions: %w", err)
+	}
+	defer file.Close()
+
+	var decisions []TriageDecision
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var decision TriageDecision
+		if err := json.Unmarshal(scanner.Bytes(), &decision); err != nil {
+			continue
+		}
+		decisions = append(decisions, decision)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read triage decisions: %w", err)
+	}
+	return decisions, nil
+}
+
+// LatestTriage returns the most recent decision for a package, or nil
+func LatestTriage(decisions []TriageDecision, pkg, repoType string) *TriageDecision {
+	for i := len(decisions) - 1; i >= 0; i-- {
+		if decisions[i].Package == pkg && decisions[i].RepoType == repoType {
+			return &decisions[i]
+		}
+	}
+	return nil
+}
+
+// LoadRegressions reads the regressions of a run from regressions.txt in its
+// log directory. Entries of repository type matrices ("wolfi/curl") log to
+// a subdirectory per type; other entries belong to repoType.
+func LoadRegressions(logDir, repoType string) ([]Regression, error) {
+	return LoadRegressionsFrom(logDir, logDir, repoType)
+}
+
+// LoadRegressionsFrom reads the regressions of a run whose result files were
+// written to resultsDir (see ResultFiles): regressions.txt, or else
+// regressions.json or regressions.csv
+func LoadRegressionsFrom(resultsDir, logDir, repoType string) ([]Regression, error) {
+	lines, err := readRegressionsFile(resultsDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read regressions: %w", err)
+	}
+
+	var regressions []Regression
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		regression := Regression{Package: line, RepoType: repoType}
+		dir := logDir
+		if t, pkg, ok := strings.Cut(line, "/"); ok {
+			regression = Regression{Package: pkg, RepoType: t}
+			dir = filepath.Join(logDir, t)
+		}
+		regression.LogPath = filepath.Join(dir, fmt.Sprintf("%s_with_repo.log", regression.Package))
+		regression.FailingStep = FailingStep(regression.LogPath)
+		if artifacts := artifactDir(dir, regression.Package, true); hasArtifacts(artifacts) {
+			regression.ArtifactsDir = artifacts
+		}
+		snapshot := filepath.Join(dir, fmt.Sprintf("%s_workspace.tar.gz", regression.Package))
+		if _, err := os.Stat(snapshot); err == nil {
+			regression.WorkspaceSnapshot = snapshot
+		}
+		regressions = append(regressions, regression)
+	}
+	return regressions, nil
+}
+
+// CreateGitHubIssue opens an issue with the gh CLI and returns its URL. An
+// empty repo selects the repository of the current directory.
+func CreateGitHubIssue(repo, title, body string) (string, error) {
+	args := []string{"issue", "create", "--title", title, "--body", body}
+	if repo != "" {
+		args = append(args, "--repo", repo)
+	}
+
+	output, err := exec.Command("gh", args...).Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to create issue: %w", commandError(err))
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// readRegressionsFile returns the entries of the regressions file in dir in
+// the format of regressions.txt, e.g. wolfi/curl
+func readRegressionsFile(dir string) ([]string, error) {
+	data, err := os.ReadFile(filepath.Join(dir, "regressions.txt"))
+	if err == nil {
+		return strings.Split(string(data), "\n"), nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	if data, err := os.ReadFile(filepath.Join(dir, "regressions.json")); err == nil {
+		var entries []resultEntry
+		if err := json.Unmarshal(data, &entries); err != nil {
+			return nil, fmt.Errorf("regressions.json: %w", err)
+		}
+		lines := make([]string, 0, len(entries))
+		for _, entry := range entries {
+			lines = append(lines, entry.String())
+		}
+		return lines, nil
+	}
+
+	if data, err := os.ReadFile(filepath.Join(dir, "regressions.csv")); err == nil {
+		rows, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+		if err != nil || len(rows) == 0 {
+			return nil, fmt.Errorf("regressions.csv: invalid file")
+		}
+		var lines []string
+		for _, row := range rows[1:] {
+			lines = append(lines, strings.Join(row, "/"))
+		}
+		return lines, nil
+	}
+	return nil, fmt.Errorf("no regressions.txt, regressions.json or regressions.csv in %s", dir)
+}
@@ -0,0 +1,84 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestTriageDecisions(t *testing.T) {
+	dir := t.TempDir()
+	db, err := NewResultsDB(filepath.Join(dir, "results.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decisions, err := db.TriageDecisions()
+	if err != nil || len(decisions) != 0 {
+		t.Errorf("Expected no decisions, got %v (%v)", decisions, err)
+	}
+
+	for _, d := range []TriageDecision{
+		{Package: "curl", RepoType: "wolfi", RunID: "run-1", Verdict: VerdictFlaky},
+		{Package: "curl", RepoType: "enterprise", RunID: "run-1", Verdict: VerdictKnown},
+		{Package: "curl", RepoType: "wolfi", RunID: "run-2", Verdict: VerdictReal, Issue: "https://github.com/wolfi-dev/os/issues/1"},
+	} {
+		if err := db.AppendTriage(d); err != nil {
+			t.Fatalf("Failed to append decision: %v", err)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "results-triage.jsonl")); err != nil {
+		t.Errorf("Expected decisions in results-triage.jsonl: %v", err)
+	}
+
+	decisions, err = db.TriageDecisions()
+	if err != nil {
+		t.Fatalf("Failed to read decisions: %v", err)
+	}
+	if len(decisions) != 3 {
+		t.Fatalf("Expected 3 decisions, got %d", len(decisions))
+	}
+
+	latest := LatestTriage(decisions, "curl", "wolfi")
+	if latest == nil || latest.RunID != "run-2" || latest.Verdict != VerdictReal {
+		t.Errorf("Expected the run-2 decision, got %+v", latest)
+	}
+	if latest := LatestTriage(decisions, "openssl", "wolfi"); latest != nil {
+		t.Errorf("Expected no decision for openssl, got %+v", latest)
+	}
+}
+
+func TestLoadRegressions(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "regressions.txt"), []byte("curl\n\nwolfi/git\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "curl_workspace.tar.gz"), []byte("snapshot"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "curl_with_repo.log"), []byte("INFO running step \"python/import\"\nERRO failed to test package\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	regressions, err := LoadRegressions(dir, "enterprise")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := []Regression{
+		{Package: "curl", RepoType: "enterprise", LogPath: filepath.Join(dir, "curl_with_repo.log"), WorkspaceSnapshot: filepath.Join(dir, "curl_workspace.tar.gz"), FailingStep: "python/import"},
+		{Package: "git", RepoType: "wolfi", LogPath: filepath.Join(dir, "wolfi", "git_with_repo.log")},
+	}
+	if !reflect.DeepEqual(regressions, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, regressions)
+	}
+
+	if _, err := LoadRegressions(t.TempDir(), ""); err == nil {
+		t.Error("Expected an error without regressions.txt")
+	}
+}
@@ -0,0 +1,142 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// installPattern matches the packages apk reports installing while melange
+// sets up a test environment, e.g. "installing openssl (3.3.2-r0)"
+var installPattern = regexp.MustCompile(`installing (\S+) \(([^)\s]+)\)`)
+
+// installedPackages returns the versions of the packages installed by the
+// test whose log is at logPath, by name
+func installedPackages(logPath string) map[string]string {
+	file, err := os.Open(logPath)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	installed := make(map[string]string)
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if match := installPattern.FindStringSubmatch(scanner.Text()); match != nil {
+			installed[match[1]] = match[2]
+		}
+	}
+	return installed
+}
+
+// SetTwoPhase first runs a quick install smoke test of every package with
+// and without the candidate repository, which only resolves and installs the
+// test environment, and then tests in full only the packages whose smoke
+// test failed or whose test environment the candidate repository changes.
+// The others can't be affected by it and are reported as passing.
+func (r *RegressionTestRunner) SetTwoPhase(enabled bool) {
+	r.twoPhase = enabled
+}
+
+// smokeAffected tells whether the smoke tests of a package with and without
+// the candidate repository call for testing it in full, and why
+func smokeAffected(withRepo, withoutRepo TestResult) (bool, string) {
+	switch {
+	case withRepo.Skipped:
+		return true, "no config"
+	case !withRepo.Success:
+		return true, "fails with the candidate repository"
+	case !withoutRepo.Success:
+		return true, "fails without the candidate repository"
+	}
+
+	with, without := installedPackages(withRepo.LogPath), installedPackages(withoutRepo.LogPath)
+	switch {
+	case len(with) == 0 || len(without) == 0:
+		// Without a record of what was installed, a difference can't be
+		// ruled out
+		return true, "installed packages not logged"
+	case !reflect.DeepEqual(with, without):
+		return true, "test environment changes"
+	}
+	return false, ""
+}
+
+// runSmokePhase runs the smoke tests of packages, except those skipped as
+// unchanged, and records the ones that don't need testing in full in
+// r.unaffected
+func (r *RegressionTestRunner) runSmokePhase(packages []string, executor TestExecutor) {
+	var pending []string
+	for _, pkg := range packages {
+		if _, ok := r.unchanged[pkg]; !ok {
+			pending = append(pending, pkg)
+		}
+	}
+	if len(pending) == 0 {
+		return
+	}
+
+	fmt.Printf("Phase 1: install smoke test of %d packages\n", len(pending))
+	startedAt := time.Now()
+
+	queue := make(chan string)
+	var mu sync.Mutex
+	unaffected := make(map[string]bool)
+	var wg sync.WaitGroup
+	for i := 0; i < max(r.concurrency, 1); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for pkg := range queue {
+				smoke := func(withRepo bool) TestResult {
+					r.watchdog.started(pkg)
+					defer r.watchdog.finished(pkg)
+					return executor.Execute(context.Background(), pkg, ExecuteOptions{
+						WithRepo: withRepo,
+						APKRepo:  r.apkRepo,
+						Timeout:  r.timeoutFor(pkg),
+						Smoke:    true,
+					})
+				}
+				affected, reason := smokeAffected(smoke(true), smoke(false))
+				if r.verbose {
+					if affected {
+						fmt.Printf("🔎 %s: testing in full (%s)\n", pkg, reason)
+					} else {
+						fmt.Printf("⏩ %s: test environment unaffected by the candidate repository\n", pkg)
+					}
+				}
+				if !affected {
+					mu.Lock()
+					unaffected[pkg] = true
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	for _, pkg := range pending {
+		queue <- pkg
+	}
+	close(queue)
+	wg.Wait()
+
+	// Unaffected packages weren't tested, so they mustn't be recorded as
+	// passing with their content key
+	for pkg := range unaffected {
+		delete(r.testKeys, pkg)
+	}
+	r.unaffected = unaffected
+
+	fmt.Printf("Phase 1 finished in %v: %d of %d packages affected by the candidate repository\n",
+		time.Since(startedAt).Round(time.Second), len(pending)-len(unaffected), len(pending))
+	fmt.Printf("Phase 2: full tests\n")
+}
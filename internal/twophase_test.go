@@ -0,0 +1,117 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSmokeAffected(t *testing.T) {
+	dir := t.TempDir()
+	writeLog := func(name, content string) string {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	old := writeLog("old.log", "INFO installing curl (8.9.0-r0)\nINFO installing openssl (3.3.1-r0)\n")
+	same := writeLog("same.log", "INFO installing openssl (3.3.1-r0)\nINFO installing curl (8.9.0-r0)\n")
+	bumped := writeLog("bumped.log", "INFO installing curl (8.9.0-r0)\nINFO installing openssl (3.3.2-r0)\n")
+	empty := writeLog("empty.log", "nothing installed\n")
+
+	pass := func(logPath string) TestResult { return TestResult{Success: true, LogPath: logPath} }
+	tests := []struct {
+		name        string
+		withRepo    TestResult
+		withoutRepo TestResult
+		expected    bool
+	}{
+		{"same environment", pass(same), pass(old), false},
+		{"changed environment", pass(bumped), pass(old), true},
+		{"fails with repo", TestResult{LogPath: bumped}, pass(old), true},
+		{"fails without repo", pass(bumped), TestResult{LogPath: old}, true},
+		{"nothing logged", pass(empty), pass(empty), true},
+		{"no config", TestResult{Skipped: true}, TestResult{Skipped: true}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if affected, reason := smokeAffected(tt.withRepo, tt.withoutRepo); affected != tt.expected {
+				t.Errorf("Expected affected=%v, got %v (%s)", tt.expected, affected, reason)
+			}
+		})
+	}
+}
+
+func TestRunnerTwoPhase(t *testing.T) {
+	repoPath := t.TempDir()
+	for _, name := range []string{"curl", "git", "nginx"} {
+		if err := os.WriteFile(filepath.Join(repoPath, name+".yaml"), []byte("package:\n  name: "+name+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	runner := NewRegressionTestRunner("openssl", "https://example.com/repo", repoPath, "wolfi", 2, true, time.Minute, false)
+	logDir := t.TempDir()
+	runner.setLogDir(logDir)
+	runner.SetTwoPhase(true)
+
+	var mu sync.Mutex
+	var full []string
+	runner.SetExecutor(TestExecutorFunc(func(ctx context.Context, pkg string, opts ExecuteOptions) TestResult {
+		logPath := filepath.Join(logDir, fmt.Sprintf("%s_%v_%v.log", pkg, opts.WithRepo, opts.Smoke))
+		// curl picks up the candidate openssl, git doesn't install it
+		installed := "INFO installing busybox (1.36.1-r0)\n"
+		if pkg == "curl" && opts.WithRepo {
+			installed += "INFO installing openssl (3.3.2-r0)\n"
+		}
+		if err := os.WriteFile(logPath, []byte(installed), 0644); err != nil {
+			t.Error(err)
+		}
+
+		var err error
+		switch {
+		case opts.Smoke && pkg == "nginx" && opts.WithRepo:
+			err = errors.New("unsatisfiable constraints")
+		case opts.Smoke:
+		default:
+			mu.Lock()
+			full = append(full, fmt.Sprintf("%s/%v", pkg, opts.WithRepo))
+			mu.Unlock()
+			if pkg == "curl" && opts.WithRepo {
+				err = errors.New("test failed")
+			}
+		}
+		return newTestResult(pkg, opts.WithRepo, time.Now(), logPath, err)
+	}))
+
+	if err := runner.RunFromPackageList([]string{"curl", "git", "nginx"}); err == nil || err.Error() != "found 1 regressions" {
+		t.Errorf("Expected 1 regression, got %v", err)
+	}
+
+	sort.Strings(full)
+	if expected := []string{"curl/false", "curl/true", "nginx/true"}; !reflect.DeepEqual(full, expected) {
+		t.Errorf("Expected only affected packages to be tested in full, got %v", full)
+	}
+	if !reflect.DeepEqual(runner.unaffected, map[string]bool{"git": true}) {
+		t.Errorf("Expected git to be unaffected, got %v", runner.unaffected)
+	}
+	if result := runner.packageResults["git"][true]; !result.Success || !result.SmokeOnly {
+		t.Errorf("Expected git to pass after the smoke test only, got %+v", result)
+	}
+	if !reflect.DeepEqual(runner.summary.Successful, []string{"git", "nginx"}) {
+		t.Errorf("Expected git and nginx to pass, got %v", runner.summary.Successful)
+	}
+}
@@ -0,0 +1,122 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+	"time"
+)
+
+// utilization is how well a run used its workers and the machine, to guide
+// the choice of --concurrency
+type utilization struct {
+	// Wall is the time from the start of testing until all tests finished
+	Wall time.Duration `json:"wall"`
+	// Busy is the sum of the durations of all tests, the time workers spent
+	// testing
+	Busy time.Duration `json:"busy"`
+	// CPU is the CPU time of all tests
+	CPU         time.Duration `json:"cpuTime"`
+	Concurrency int           `json:"concurrency"`
+	CPUs        int           `json:"cpus"`
+}
+
+// measureUtilization sums up the tests of a run that took wall with
+// concurrency workers
+func measureUtilization(packageResults map[string]map[bool]TestResult, wall time.Duration, concurrency int) utilization {
+	u := utilization{Wall: wall, Concurrency: max(concurrency, 1), CPUs: runtime.NumCPU()}
+	for _, results := range packageResults {
+		for _, result := range results {
+			u.Busy += result.Duration
+			u.CPU += result.CPUTime
+		}
+	}
+	return u
+}
+
+// merge adds the utilization of a run that followed u
+func (u utilization) merge(other utilization) utilization {
+	return utilization{
+		Wall:        u.Wall + other.Wall,
+		Busy:        u.Busy + other.Busy,
+		CPU:         u.CPU + other.CPU,
+		Concurrency: max(u.Concurrency, other.Concurrency),
+		CPUs:        max(u.CPUs, other.CPUs),
+	}
+}
+
+// cpuHours is the CPU time of all tests in hours
+func (u utilization) cpuHours() float64 {
+	return u.CPU.Hours()
+}
+
+// parallelism is the average number of tests running at once
+func (u utilization) parallelism() float64 {
+	if u.Wall <= 0 {
+		return 0
+	}
+	return float64(u.Busy) / float64(u.Wall)
+}
+
+// idle is the time workers waited without a test, e.g. for the last tests
+// of the run or while the run was paused
+func (u utilization) idle() time.Duration {
+	return max(time.Duration(u.Concurrency)*u.Wall-u.Busy, 0)
+}
+
+// cpuLoad is the share of the machine's CPU capacity the tests used
+func (u utilization) cpuLoad() float64 {
+	if u.Wall <= 0 || u.CPUs == 0 {
+		return 0
+	}
+	return float64(u.CPU) / (float64(u.Wall) * float64(u.CPUs))
+}
+
+// advice suggests how to change --concurrency, or returns "" if the run
+// used the machine well or too little was measured to tell
+func (u utilization) advice() string {
+	switch {
+	case u.Wall < time.Minute || u.CPU == 0:
+		return ""
+	case u.cpuLoad() > 0.9:
+		return "the CPUs were saturated; a higher --concurrency won't make runs faster"
+	case u.parallelism() < 0.75*float64(u.Concurrency):
+		return "workers were mostly idle waiting for the slowest tests; a higher --concurrency won't make runs much faster"
+	case u.cpuLoad() < 0.5:
+		return fmt.Sprintf("the CPUs were %.0f%% busy; a higher --concurrency would likely make runs faster", 100*u.cpuLoad())
+	}
+	return ""
+}
+
+// print writes the utilization to the text summary
+func (u utilization) print(w io.Writer) {
+	if u.Busy == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\nMachine utilization:\n")
+	fmt.Fprintf(w, "  CPU time: %.2f CPU-hours (%.0f%% of %d CPUs)\n", u.cpuHours(), 100*u.cpuLoad(), u.CPUs)
+	fmt.Fprintf(w, "  Average parallelism: %.1f of %d workers\n", u.parallelism(), u.Concurrency)
+	fmt.Fprintf(w, "  Scheduler idle time: %v\n", u.idle().Round(time.Second))
+	if advice := u.advice(); advice != "" {
+		fmt.Fprintf(w, "  Hint: %s\n", advice)
+	}
+}
+
+// printMarkdown writes the utilization to the markdown summary
+func (u utilization) printMarkdown(w io.Writer) {
+	if u.Busy == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\n### ⚙️ Machine Utilization\n\n")
+	fmt.Fprintf(w, "| Metric | Value |\n")
+	fmt.Fprintf(w, "|--------|-------|\n")
+	fmt.Fprintf(w, "| CPU time | %.2f CPU-hours (%.0f%% of %d CPUs) |\n", u.cpuHours(), 100*u.cpuLoad(), u.CPUs)
+	fmt.Fprintf(w, "| Average parallelism | %.1f of %d workers |\n", u.parallelism(), u.Concurrency)
+	fmt.Fprintf(w, "| Scheduler idle time | %v |\n", u.idle().Round(time.Second))
+	if advice := u.advice(); advice != "" {
+		fmt.Fprintf(w, "\n💡 %s\n", advice)
+	}
+}
@@ -0,0 +1,85 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMeasureUtilization(t *testing.T) {
+	packageResults := map[string]map[bool]TestResult{
+		"curl": {
+			true:  {Duration: 20 * time.Minute, CPUTime: 30 * time.Minute},
+			false: {Duration: 10 * time.Minute, CPUTime: 15 * time.Minute},
+		},
+		"git": {true: {Duration: 30 * time.Minute, CPUTime: 45 * time.Minute}},
+	}
+
+	u := measureUtilization(packageResults, 40*time.Minute, 2)
+	u.CPUs = 8
+
+	if u.cpuHours() != 1.5 {
+		t.Errorf("Expected 1.5 CPU-hours, got %v", u.cpuHours())
+	}
+	if u.parallelism() != 1.5 {
+		t.Errorf("Expected an average parallelism of 1.5, got %v", u.parallelism())
+	}
+	if u.idle() != 20*time.Minute {
+		t.Errorf("Expected 20m of idle time, got %v", u.idle())
+	}
+
+	var out bytes.Buffer
+	u.print(&out)
+	for _, line := range []string{"CPU time: 1.50 CPU-hours (28% of 8 CPUs)", "Average parallelism: 1.5 of 2 workers", "Scheduler idle time: 20m0s"} {
+		if !strings.Contains(out.String(), line) {
+			t.Errorf("Expected %q in the summary, got %q", line, out.String())
+		}
+	}
+}
+
+func TestUtilizationAdvice(t *testing.T) {
+	tests := []struct {
+		name     string
+		u        utilization
+		expected string
+	}{
+		{
+			name:     "saturated",
+			u:        utilization{Wall: time.Hour, Busy: 4 * time.Hour, CPU: 4 * time.Hour, Concurrency: 4, CPUs: 4},
+			expected: "saturated",
+		},
+		{
+			name:     "waiting for stragglers",
+			u:        utilization{Wall: time.Hour, Busy: 2 * time.Hour, CPU: time.Hour, Concurrency: 4, CPUs: 8},
+			expected: "idle",
+		},
+		{
+			name:     "spare cpus",
+			u:        utilization{Wall: time.Hour, Busy: 4 * time.Hour, CPU: 2 * time.Hour, Concurrency: 4, CPUs: 8},
+			expected: "higher --concurrency would",
+		},
+		{
+			name:     "well used",
+			u:        utilization{Wall: time.Hour, Busy: 4 * time.Hour, CPU: 6 * time.Hour, Concurrency: 4, CPUs: 8},
+			expected: "",
+		},
+		{
+			name:     "too short",
+			u:        utilization{Wall: time.Second, Busy: time.Second, CPU: time.Second, Concurrency: 4, CPUs: 8},
+			expected: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			advice := tt.u.advice()
+			if (tt.expected == "") != (advice == "") || !strings.Contains(advice, tt.expected) {
+				t.Errorf("Expected advice containing %q, got %q", tt.expected, advice)
+			}
+		})
+	}
+}
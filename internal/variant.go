@@ -0,0 +1,340 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ErrUnknownMatrix indicates that the config file has no matrix of the name
+// given
+var ErrUnknownMatrix = errors.New("unknown matrix")
+
+// testEnvFile is the environment file of each test with a test environment
+// (see SetTestEnv), written to its scratch directory
+const testEnvFile = "apkregress-test.env"
+
+// Variant is a combination of values of the axes of a test matrix, with
+// which every reverse dependency is tested
+type Variant struct {
+	// Name labels the variant in reports, e.g. "fips=on,fix=a"
+	Name string `json:"name"`
+	// Env is added to the environment of tests
+	Env map[string]string `json:"env,omitempty"`
+	// Repo replaces the candidate repository, if set
+	Repo string `json:"repo,omitempty"`
+}
+
+// axisValue is a value of a matrix axis in the config file
+type axisValue struct {
+	Name string            `yaml:"name"`
+	Env  map[string]string `yaml:"env"`
+	Repo string            `yaml:"repo"`
+}
+
+// matrixAxis is a dimension of a test matrix, e.g. a feature flag that is
+// either set or not
+type matrixAxis struct {
+	Name   string
+	Values []axisValue
+}
+
+// LoadMatrix reads the named matrix from the config file at path and returns
+// its variants, every combination of the values of its axes. Matrices map
+// axis names to their values, each setting environment variables of tests
+// or the candidate repository:
+//
+//	matrices:
+//	  fips-fixes:
+//	    fips:
+//	      - name: off
+//	      - name: on
+//	        env: {OPENSSL_FIPS: "1"}
+//	    fix:
+//	      - name: a
+//	        repo: https://example.com/fix-a
+//	      - name: b
+//	        repo: https://example.com/fix-b
+func LoadMatrix(path, name string) ([]Variant, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var file configFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	node, ok := file.Matrices[name]
+	if !ok {
+		names := sortedKeys(file.Matrices)
+		return nil, fmt.Errorf("%w %q in %s, expected one of: %s", ErrUnknownMatrix, name, path, strings.Join(names, ", "))
+	}
+
+	axes, err := parseMatrixAxes(node)
+	if err != nil {
+		return nil, fmt.Errorf("matrix %s in %s: %w", name, path, err)
+	}
+	variants, err := matrixVariants(axes)
+	if err != nil {
+		return nil, fmt.Errorf("matrix %s in %s: %w", name, path, err)
+	}
+	return variants, nil
+}
+
+// parseMatrixAxes decodes the axes of a matrix in the order of the config
+// file, which is the order of the variants
+func parseMatrixAxes(node yaml.Node) ([]matrixAxis, error) {
+	if node.Kind != yaml.MappingNode || len(node.Content) == 0 {
+		return nil, errors.New("must map axis names to their values")
+	}
+	var axes []matrixAxis
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		axis := matrixAxis{Name: node.Content[i].Value}
+		if err := node.Content[i+1].Decode(&axis.Values); err != nil {
+			return nil, fmt.Errorf("axis %s: %w", axis.Name, err)
+		}
+		if len(axis.Values) == 0 {
+			return nil, fmt.Errorf("axis %s has no values", axis.Name)
+		}
+		seen := make(map[string]bool)
+		for _, value := range axis.Values {
+			if value.Name == "" {
+				return nil, fmt.Errorf("axis %s has a value without a name", axis.Name)
+			}
+			if seen[value.Name] {
+				return nil, fmt.Errorf("axis %s has the value %s twice", axis.Name, value.Name)
+			}
+			seen[value.Name] = true
+		}
+		axes = append(axes, axis)
+	}
+	return axes, nil
+}
+
+// matrixVariants returns every combination of the values of axes, the
+// values of the last axis changing fastest
+func matrixVariants(axes []matrixAxis) ([]Variant, error) {
+	variants := []Variant{{}}
+	for _, axis := range axes {
+		var next []Variant
+		for _, variant := range variants {
+			for _, value := range axis.Values {
+				combined, err := variant.with(axis.Name, value)
+				if err != nil {
+					return nil, err
+				}
+				next = append(next, combined)
+			}
+		}
+		variants = next
+	}
+	return variants, nil
+}
+
+// with returns the variant combined with the value of an axis
+func (v Variant) with(axis string, value axisValue) (Variant, error) {
+	combined := Variant{Name: axis + "=" + value.Name, Repo: v.Repo, Env: make(map[string]string)}
+	if v.Name != "" {
+		combined.Name = v.Name + "," + combined.Name
+	}
+	for name, val := range v.Env {
+		combined.Env[name] = val
+	}
+	for name, val := range value.Env {
+		if _, ok := combined.Env[name]; ok {
+			return Variant{}, fmt.Errorf("%s sets %s, which another axis sets as well", combined.Name, name)
+		}
+		combined.Env[name] = val
+	}
+	if value.Repo != "" {
+		if combined.Repo != "" {
+			return Variant{}, fmt.Errorf("%s sets the candidate repository on more than one axis", combined.Name)
+		}
+		combined.Repo = value.Repo
+	}
+	if len(combined.Env) == 0 {
+		combined.Env = nil
+	}
+	return combined, nil
+}
+
+// dirName returns a name for the log directory of the variant, e.g.
+// "fips-on_fix-a"
+func (v Variant) dirName() string {
+	return strings.NewReplacer("=", "-", ",", "_", "/", "-", " ", "-").Replace(v.Name)
+}
+
+// String describes the variant, e.g. "fips=on,fix=a (OPENSSL_FIPS=1)"
+func (v Variant) String() string {
+	var settings []string
+	for _, name := range sortedKeys(v.Env) {
+		settings = append(settings, name+"="+v.Env[name])
+	}
+	if v.Repo != "" {
+		settings = append(settings, "repository "+v.Repo)
+	}
+	if len(settings) == 0 {
+		return v.Name
+	}
+	return fmt.Sprintf("%s (%s)", v.Name, strings.Join(settings, ", "))
+}
+
+// SetVariant tests with a variant of a test matrix: with its environment
+// and, if it sets one, against its candidate repository. Runs of a variant
+// are compared only with earlier runs of the same variant.
+func (r *RegressionTestRunner) SetVariant(variant Variant) {
+	r.variant = &variant
+	if variant.Repo != "" {
+		r.apkRepo = variant.Repo
+	}
+	if r.melange != nil {
+		r.melange.SetTestEnv(variant.Env)
+	}
+}
+
+// variantName returns the name of the run's variant, or "" outside a test
+// matrix
+func (r *RegressionTestRunner) variantName() string {
+	if r.variant == nil {
+		return ""
+	}
+	return r.variant.Name
+}
+
+// variantStatuses pivots the outcomes of the packages tested by runners, one
+// per variant, by variant: the status of each package in each variant, for
+// packages whose status isn't the same passing one in every variant
+func variantStatuses(runners []*RegressionTestRunner) (packages []string, statuses map[string][]string) {
+	statuses = make(map[string][]string)
+	for i, runner := range runners {
+		for pkg, results := range runner.packageResults {
+			withRepo, ok := results[true]
+			if !ok {
+				continue
+			}
+			if statuses[pkg] == nil {
+				statuses[pkg] = make([]string, len(runners))
+			}
+			var withoutRepo *TestResult
+			if result, ok := results[false]; ok {
+				withoutRepo = &result
+			}
+			statuses[pkg][i] = packageStatus(withRepo, withoutRepo)
+		}
+	}
+
+	for pkg, row := range statuses {
+		if uniform(row, StatusPass) || uniform(row, StatusSkipped) {
+			delete(statuses, pkg)
+			continue
+		}
+		packages = append(packages, pkg)
+	}
+	sort.Strings(packages)
+	return packages, statuses
+}
+
+// uniform tells whether every status is status
+func uniform(statuses []string, status string) bool {
+	for _, s := range statuses {
+		if s != status {
+			return false
+		}
+	}
+	return true
+}
+
+// statusSymbols are the symbols of package statuses in the variant table,
+// matching the progress line
+var statusSymbols = map[string]string{
+	StatusPass:       "✅",
+	StatusFail:       "❌",
+	StatusRegression: "🔴",
+	StatusHung:       "⏰",
+	StatusSkipped:    "⏭️",
+	StatusInfra:      "🛠️",
+	"":               "–",
+}
+
+// variantResults returns the status of each package that doesn't pass in
+// every variant, by variant name, for summary.json
+func (m *MatrixRunner) variantResults() map[string]map[string]string {
+	if !m.variants {
+		return nil
+	}
+	packages, statuses := variantStatuses(m.runners)
+	results := make(map[string]map[string]string, len(packages))
+	for _, pkg := range packages {
+		results[pkg] = make(map[string]string)
+		for i, runner := range m.runners {
+			if status := statuses[pkg][i]; status != "" {
+				results[pkg][runner.label()] = status
+			}
+		}
+	}
+	return results
+}
+
+// printVariants writes the variants and the packages that don't pass in
+// every variant to the text summary, pivoted by variant
+func (m *MatrixRunner) printVariants(w io.Writer) {
+	if !m.variants {
+		return
+	}
+	fmt.Fprintf(w, "\nVariants:\n")
+	for _, runner := range m.runners {
+		fmt.Fprintf(w, "  - %s\n", runner.variant)
+	}
+
+	packages, statuses := variantStatuses(m.runners)
+	if len(packages) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\nResults by variant (packages that don't pass in every variant):\n")
+	for _, pkg := range packages {
+		cells := make([]string, 0, len(m.runners))
+		for i, runner := range m.runners {
+			cells = append(cells, fmt.Sprintf("%s %s", statusSymbols[statuses[pkg][i]], runner.label()))
+		}
+		fmt.Fprintf(w, "  - %s: %s\n", pkg, strings.Join(cells, ", "))
+	}
+}
+
+// printVariantsMarkdown is printVariants for the markdown summary
+func (m *MatrixRunner) printVariantsMarkdown(w io.Writer) {
+	if !m.variants {
+		return
+	}
+	fmt.Fprintf(w, "\n### 🧪 Results by Variant\n\n")
+	for _, runner := range m.runners {
+		fmt.Fprintf(w, "- `%s`\n", runner.variant)
+	}
+
+	packages, statuses := variantStatuses(m.runners)
+	if len(packages) == 0 {
+		fmt.Fprintf(w, "\nEvery package passes in every variant.\n")
+		return
+	}
+	fmt.Fprintf(w, "\nPackages that don't pass in every variant:\n\n")
+	fmt.Fprintf(w, "| Package |")
+	for _, runner := range m.runners {
+		fmt.Fprintf(w, " %s |", runner.label())
+	}
+	fmt.Fprintf(w, "\n|---------|%s\n", strings.Repeat("---|", len(m.runners)))
+	for _, pkg := range packages {
+		fmt.Fprintf(w, "| `%s` |", pkg)
+		for i := range m.runners {
+			fmt.Fprintf(w, " %s |", statusSymbols[statuses[pkg][i]])
+		}
+		fmt.Fprintf(w, "\n")
+	}
+}
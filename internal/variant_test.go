@@ -0,0 +1,151 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadMatrix(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	config := `matrices:
+  fips-fixes:
+    fips:
+      - name: off
+      - name: on
+        env: {OPENSSL_FIPS: "1"}
+    fix:
+      - name: a
+        repo: https://example.com/fix-a
+      - name: b
+        repo: https://example.com/fix-b
+  clash:
+    one:
+      - name: x
+        env: {A: "1"}
+    two:
+      - name: y
+        env: {A: "2"}
+  two-repos:
+    one:
+      - name: x
+        repo: https://example.com/x
+    two:
+      - name: y
+        repo: https://example.com/y
+  duplicate:
+    one:
+      - name: x
+      - name: x
+`
+	if err := os.WriteFile(path, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	variants, err := LoadMatrix(path, "fips-fixes")
+	if err != nil {
+		t.Fatalf("Expected the matrix to load, got %v", err)
+	}
+	expected := []Variant{
+		{Name: "fips=off,fix=a", Repo: "https://example.com/fix-a"},
+		{Name: "fips=off,fix=b", Repo: "https://example.com/fix-b"},
+		{Name: "fips=on,fix=a", Env: map[string]string{"OPENSSL_FIPS": "1"}, Repo: "https://example.com/fix-a"},
+		{Name: "fips=on,fix=b", Env: map[string]string{"OPENSSL_FIPS": "1"}, Repo: "https://example.com/fix-b"},
+	}
+	if !reflect.DeepEqual(variants, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, variants)
+	}
+
+	tests := []struct {
+		name    string
+		wantErr string
+	}{
+		{"clash", "sets A, which another axis sets as well"},
+		{"two-repos", "sets the candidate repository on more than one axis"},
+		{"duplicate", "has the value x twice"},
+		{"nightly", "expected one of: clash, duplicate, fips-fixes, two-repos"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadMatrix(path, tt.name)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+	if _, err := LoadMatrix(path, "nightly"); !errors.Is(err, ErrUnknownMatrix) {
+		t.Errorf("Expected ErrUnknownMatrix, got %v", err)
+	}
+}
+
+func TestSetVariant(t *testing.T) {
+	runner := NewRegressionTestRunner("openssl", "https://example.com/repo", "/tmp/os", "wolfi", 1, false, time.Minute, false)
+	runner.SetVariant(Variant{Name: "fix=b", Repo: "https://example.com/fix-b", Env: map[string]string{"FEATURE": "1"}})
+	if runner.apkRepo != "https://example.com/fix-b" {
+		t.Errorf("Expected the variant's candidate repository, got %s", runner.apkRepo)
+	}
+
+	dir := t.TempDir()
+	path, err := runner.melange.writeTestEnv(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path)
+	if string(data) != "FEATURE=1\n" {
+		t.Errorf("Expected the test environment file to set FEATURE, got %q", data)
+	}
+}
+
+func TestMatrixRunnerVariants(t *testing.T) {
+	result := func(pkg string, withRepo, success bool) TestResult {
+		return TestResult{Package: pkg, WithRepo: withRepo, Success: success}
+	}
+	runners := []*RegressionTestRunner{
+		NewRegressionTestRunner("openssl", "https://example.com/repo", "/tmp/os", "wolfi", 1, false, time.Minute, false),
+		NewRegressionTestRunner("openssl", "https://example.com/repo", "/tmp/os", "wolfi", 1, false, time.Minute, false),
+	}
+	runners[0].SetVariant(Variant{Name: "fix=a"})
+	runners[1].SetVariant(Variant{Name: "fix=b", Env: map[string]string{"FEATURE": "1"}})
+	matrix := NewMatrixRunner("openssl", "https://example.com/repo", runners, false)
+
+	if expected := filepath.Join(matrix.logDir, "wolfi", "fix-b"); runners[1].logDir != expected {
+		t.Errorf("Expected the variant to log to %s, got %s", expected, runners[1].logDir)
+	}
+
+	runners[0].packageResults = map[string]map[bool]TestResult{
+		"curl": {true: result("curl", true, true)},
+		"git":  {true: result("git", true, true)},
+	}
+	runners[1].packageResults = map[string]map[bool]TestResult{
+		"curl": {true: result("curl", true, false), false: result("curl", false, true)},
+		"git":  {true: result("git", true, true)},
+	}
+	runners[1].summary.Regressions = []string{"curl"}
+
+	expected := map[string]map[string]string{"curl": {"fix=a": StatusPass, "fix=b": StatusRegression}}
+	if got := matrix.summaryReport().Variants; !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+	if got := matrix.tagged(func(s runSummary) []string { return s.Regressions }); !reflect.DeepEqual(got, []string{"curl (fix=b)"}) {
+		t.Errorf("Expected the regression to be tagged with its variant, got %v", got)
+	}
+
+	var buf bytes.Buffer
+	matrix.printVariantsMarkdown(&buf)
+	for _, want := range []string{"fix=b (FEATURE=1)", "| Package | fix=a | fix=b |", "| `curl` | ✅ | 🔴 |"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("Expected %q in the markdown summary, got %q", want, buf.String())
+		}
+	}
+	if strings.Contains(buf.String(), "`git`") {
+		t.Errorf("Expected packages passing in every variant to be left out, got %q", buf.String())
+	}
+}
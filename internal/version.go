@@ -0,0 +1,85 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+)
+
+// Build information of release binaries, set with
+//
+//	-ldflags "-X github.com/chainguard-dev/apkregress/internal.version=v1.2.3 ..."
+//
+// Binaries built otherwise fall back to the module version and VCS stamp
+// recorded by the Go toolchain.
+var (
+	version   string
+	commit    string
+	buildDate string
+)
+
+// BuildInfo describes the running apkregress binary
+type BuildInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`
+	BuildDate string `json:"buildDate,omitempty"`
+	// Modified is set for builds from a checkout with uncommitted changes
+	Modified  bool   `json:"modified,omitempty"`
+	GoVersion string `json:"goVersion"`
+	Platform  string `json:"platform"`
+}
+
+// CurrentBuild returns the build information of the running binary
+func CurrentBuild() BuildInfo {
+	info := BuildInfo{
+		Version:   version,
+		Commit:    commit,
+		BuildDate: buildDate,
+		GoVersion: runtime.Version(),
+		Platform:  fmt.Sprintf("%s/%s", runtime.GOOS, runtime.GOARCH),
+	}
+
+	if build, ok := debug.ReadBuildInfo(); ok {
+		if info.Version == "" && build.Main.Version != "(devel)" {
+			info.Version = build.Main.Version
+		}
+		for _, setting := range build.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				if info.Commit == "" {
+					info.Commit = setting.Value
+				}
+			case "vcs.time":
+				if info.BuildDate == "" {
+					info.BuildDate = setting.Value
+				}
+			case "vcs.modified":
+				info.Modified = setting.Value == "true"
+			}
+		}
+	}
+
+	if info.Version == "" {
+		info.Version = "devel"
+	}
+	return info
+}
+
+// String returns the version with the abbreviated commit, e.g.
+// "v1.2.3 (0123abcd)"
+func (b BuildInfo) String() string {
+	if b.Commit == "" {
+		return b.Version
+	}
+	short := b.Commit
+	if len(short) > 8 {
+		short = short[:8]
+	}
+	if b.Modified {
+		short += "-dirty"
+	}
+	return fmt.Sprintf("%s (%s)", b.Version, short)
+}
@@ -0,0 +1,38 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"testing"
+)
+
+func TestBuildInfoString(t *testing.T) {
+	tests := []struct {
+		build    BuildInfo
+		expected string
+	}{
+		{BuildInfo{Version: "v1.2.3"}, "v1.2.3"},
+		{BuildInfo{Version: "v1.2.3", Commit: "0123456789abcdef"}, "v1.2.3 (01234567)"},
+		{BuildInfo{Version: "devel", Commit: "0123456789abcdef", Modified: true}, "devel (01234567-dirty)"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.build.String(); got != tt.expected {
+			t.Errorf("Expected %q, got %q", tt.expected, got)
+		}
+	}
+}
+
+func TestCurrentBuildPrefersLinkerFlags(t *testing.T) {
+	defer func(v, c, d string) { version, commit, buildDate = v, c, d }(version, commit, buildDate)
+	version, commit, buildDate = "v1.2.3", "0123456789abcdef", "2025-06-01T00:00:00Z"
+
+	build := CurrentBuild()
+	if build.Version != "v1.2.3" || build.Commit != "0123456789abcdef" || build.BuildDate != "2025-06-01T00:00:00Z" {
+		t.Errorf("Expected the linker flags to take precedence, got %+v", build)
+	}
+	if build.GoVersion == "" || build.Platform == "" {
+		t.Errorf("Expected Go version and platform, got %+v", build)
+	}
+}
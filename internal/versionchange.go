@@ -0,0 +1,136 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Kinds of version change of the package under test
+const (
+	BumpMajor     = "major"
+	BumpMinor     = "minor"
+	BumpPatch     = "patch"
+	BumpRebuild   = "rebuild"
+	BumpDowngrade = "downgrade"
+	BumpNone      = "none"
+)
+
+// bumpExpectations tell how many regressions are plausible for each kind of
+// version change
+var bumpExpectations = map[string]string{
+	BumpMajor:     "breaking changes are allowed, so regressions in several consumers are plausible",
+	BumpMinor:     "new features and deprecations, so a few regressions are plausible",
+	BumpPatch:     "bug fixes only, so any regression deserves a close look",
+	BumpRebuild:   "the same upstream version rebuilt, so regressions are unlikely and point at the build",
+	BumpDowngrade: "the candidate is older than the published version, so regressions may be fixes being undone",
+	BumpNone:      "the candidate has the published version, so regressions point at other packages in the repository",
+}
+
+// VersionChange is the version of the package under test in the index and
+// in the candidate repository
+type VersionChange struct {
+	Package     string `json:"package"`
+	FromVersion string `json:"fromVersion"`
+	ToVersion   string `json:"toVersion"`
+	// Bump is the kind of change: major, minor, patch, rebuild, downgrade or
+	// none
+	Bump string `json:"bump"`
+}
+
+// newVersionChange classifies the change of pkg from one version to another
+func newVersionChange(pkg, from, to string) *VersionChange {
+	return &VersionChange{Package: pkg, FromVersion: from, ToVersion: to, Bump: classifyBump(from, to)}
+}
+
+// classifyBump tells which part of a semantic version changed between two
+// APK versions. The upstream version's first dotted segment is the major
+// version and the second the minor one; while the major version is 0, a
+// minor bump counts as major since anything may change before 1.0.
+func classifyBump(from, to string) string {
+	switch c := compareAPKVersions(from, to); {
+	case c == 0:
+		return BumpNone
+	case c > 0:
+		return BumpDowngrade
+	}
+
+	upstreamFrom, _ := splitAPKRelease(from)
+	upstreamTo, _ := splitAPKRelease(to)
+	if upstreamFrom == upstreamTo {
+		return BumpRebuild
+	}
+
+	segmentsFrom := versionSegments(upstreamFrom)
+	segmentsTo := versionSegments(upstreamTo)
+	switch {
+	case segmentsFrom[0] != segmentsTo[0]:
+		return BumpMajor
+	case segmentsFrom[1] != segmentsTo[1]:
+		if numberFrom, _ := splitVersionSegment(segmentsFrom[0]); numberFrom == 0 {
+			return BumpMajor
+		}
+		return BumpMinor
+	}
+	return BumpPatch
+}
+
+// versionSegments returns the major and minor segments of an upstream
+// version such as 3.3.2_rc1, "" for missing ones
+func versionSegments(upstream string) [2]string {
+	number, _, _ := strings.Cut(upstream, "_")
+	var segments [2]string
+	copy(segments[:], strings.SplitN(number, ".", 3))
+	return segments
+}
+
+// expectation tells how many regressions are plausible for the change
+func (v *VersionChange) expectation() string {
+	return bumpExpectations[v.Bump]
+}
+
+// String describes the change for the text summary, e.g.
+// "openssl 3.3.2-r0 → 3.4.0-r0 (minor: new features and deprecations, ...)"
+func (v *VersionChange) String() string {
+	return fmt.Sprintf("%s %s → %s (%s: %s)", v.Package, v.FromVersion, v.ToVersion, v.Bump, v.expectation())
+}
+
+// printMarkdown writes the change as a section of the markdown summary
+func (v *VersionChange) printMarkdown(w io.Writer) {
+	fmt.Fprintf(w, "\n### 📦 Version Change\n\n")
+	fmt.Fprintf(w, "`%s` `%s` → `%s`: **%s** (%s)\n", v.Package, v.FromVersion, v.ToVersion, v.Bump, v.expectation())
+}
+
+// targetVersions returns the newest versions of the package under test in
+// the index and in the candidate repository, "" where it isn't listed. The
+// candidate version is the one the run is tied to, if any.
+func (r *RegressionTestRunner) targetVersions() (string, string, error) {
+	toVersion := r.targetVersion
+	if toVersion == "" {
+		index, err := loadCandidateIndex(candidateIndexURL(r.apkRepo, hostArch()))
+		if err != nil {
+			return "", "", err
+		}
+		toVersion = latestVersion(index.Packages, r.packageName)
+	}
+
+	fromVersion, err := r.apkrane.LatestVersion(r.packageName)
+	if err != nil {
+		return "", "", err
+	}
+	return fromVersion, toVersion, nil
+}
+
+// loadVersionChange compares the versions of the package under test in the
+// index and in the candidate repository. It returns nil if either doesn't
+// list the package.
+func (r *RegressionTestRunner) loadVersionChange() (*VersionChange, error) {
+	fromVersion, toVersion, err := r.targetVersions()
+	if err != nil || fromVersion == "" || toVersion == "" {
+		return nil, err
+	}
+	return newVersionChange(r.packageName, fromVersion, toVersion), nil
+}
@@ -0,0 +1,54 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestClassifyBump(t *testing.T) {
+	tests := []struct {
+		from     string
+		to       string
+		expected string
+	}{
+		{"3.3.2-r0", "4.0.0-r0", BumpMajor},
+		{"3.3.2-r0", "3.4.0-r0", BumpMinor},
+		{"3.3.2-r0", "3.3.3-r0", BumpPatch},
+		{"3.3.2-r0", "3.3.2_p1-r0", BumpPatch},
+		{"1.1.1v-r0", "1.1.1w-r0", BumpPatch},
+		{"3.3.2-r0", "3.3.2-r1", BumpRebuild},
+		{"3.3.2-r1", "3.3.2-r0", BumpDowngrade},
+		{"3.3.2-r0", "3.3.2-r0", BumpNone},
+		{"0.9.1-r0", "0.10.0-r0", BumpMajor},
+		{"2024.01.15-r0", "2024.02.01-r0", BumpMinor},
+		{"9-r0", "10-r0", BumpMajor},
+		{"1.2-r0", "1.2.1-r0", BumpPatch},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.from+" to "+tt.to, func(t *testing.T) {
+			if got := classifyBump(tt.from, tt.to); got != tt.expected {
+				t.Errorf("Expected %s, got %s", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestVersionChangeSummary(t *testing.T) {
+	change := newVersionChange("openssl", "3.3.2-r0", "3.4.0-r0")
+
+	expected := "openssl 3.3.2-r0 → 3.4.0-r0 (minor: new features and deprecations, so a few regressions are plausible)"
+	if got := change.String(); got != expected {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+
+	var out bytes.Buffer
+	change.printMarkdown(&out)
+	if !strings.Contains(out.String(), "`openssl` `3.3.2-r0` → `3.4.0-r0`: **minor**") {
+		t.Errorf("Expected the change in the markdown summary, got %q", out.String())
+	}
+}
@@ -0,0 +1,216 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime/pprof"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// watchdogGrace is added to the longest test timeout for the automatic
+// watchdog timeout, since a test may run that long without any other test
+// starting or finishing
+const watchdogGrace = 15 * time.Minute
+
+// watchdogStacksFile is the file in the log directory the goroutine stacks
+// of a wedged run are written to
+const watchdogStacksFile = "watchdog-goroutines.txt"
+
+// watchdog notices when no test started or finished for timeout, e.g.
+// because the scheduler deadlocked or a fetch hangs, and reports it instead
+// of letting the run hang silently
+type watchdog struct {
+	mu       sync.Mutex
+	timeout  time.Duration
+	last     time.Time
+	running  map[string]time.Time
+	paused   func() bool
+	onWedged func(report string)
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// newWatchdog returns a watchdog calling onWedged with a diagnostic report
+// whenever no test started or finished for timeout while the run wasn't
+// paused
+func newWatchdog(timeout time.Duration, paused func() bool, onWedged func(report string)) *watchdog {
+	return &watchdog{
+		timeout:  timeout,
+		last:     time.Now(),
+		running:  make(map[string]time.Time),
+		paused:   paused,
+		onWedged: onWedged,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// start checks for activity in the background until stopped
+func (w *watchdog) start() {
+	interval := min(w.timeout/4, time.Minute)
+	go func() {
+		defer close(w.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-w.stop:
+				return
+			case now := <-ticker.C:
+				if report, wedged := w.check(now); wedged {
+					w.onWedged(report)
+				}
+			}
+		}
+	}()
+}
+
+// Stop stops checking
+func (w *watchdog) Stop() {
+	if w == nil {
+		return
+	}
+	close(w.stop)
+	<-w.done
+}
+
+// started records that the test of pkg started
+func (w *watchdog) started(pkg string) {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.last = time.Now()
+	w.running[pkg] = w.last
+}
+
+// finished records that the test of pkg finished
+func (w *watchdog) finished(pkg string) {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.last = time.Now()
+	delete(w.running, pkg)
+}
+
+// check tells whether the run is wedged at now, with a report of the tests
+// in progress. Time spent paused doesn't count, and a wedged run is
+// reported again only after another timeout.
+func (w *watchdog) check(now time.Time) (string, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.paused != nil && w.paused() {
+		w.last = now
+		return "", false
+	}
+	idle := now.Sub(w.last)
+	if idle < w.timeout {
+		return "", false
+	}
+	w.last = now
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "No test started or finished for %v, the run seems to be wedged.\n", idle.Round(time.Second))
+	if len(w.running) == 0 {
+		fmt.Fprintf(&b, "No test is running, so the scheduler or a fetch it waits for is stuck.\n")
+	} else {
+		fmt.Fprintf(&b, "Tests running:\n")
+		pkgs := make([]string, 0, len(w.running))
+		for pkg := range w.running {
+			pkgs = append(pkgs, pkg)
+		}
+		sort.Strings(pkgs)
+		for _, pkg := range pkgs {
+			fmt.Fprintf(&b, "  - %s (for %v)\n", pkg, now.Sub(w.running[pkg]).Round(time.Second))
+		}
+	}
+	return b.String(), true
+}
+
+// SetWatchdog reports a run in which no test started or finished for
+// timeout, with the goroutine stacks written to watchdog-goroutines.txt in
+// the log directory, and then calls onWedged, e.g. to stop the run with
+// SetContext. 0 picks the longest test timeout plus 15 minutes; a negative
+// timeout disables the watchdog.
+func (r *RegressionTestRunner) SetWatchdog(timeout time.Duration, onWedged func()) {
+	r.watchdogTimeout = timeout
+	r.onWedged = onWedged
+}
+
+// watchdogPeriod returns the watchdog timeout of the run, 0 if disabled
+func (r *RegressionTestRunner) watchdogPeriod() time.Duration {
+	switch {
+	case r.watchdogTimeout < 0:
+		return 0
+	case r.watchdogTimeout > 0:
+		return r.watchdogTimeout
+	}
+	longest := r.hangTimeout
+	for _, options := range r.packageOptions {
+		longest = max(longest, options.Timeout)
+	}
+	return longest + watchdogGrace
+}
+
+// startWatchdog starts the watchdog of the run, if enabled
+func (r *RegressionTestRunner) startWatchdog() *watchdog {
+	timeout := r.watchdogPeriod()
+	if timeout <= 0 {
+		return nil
+	}
+	paused := func() bool {
+		queue := r.activeQueue()
+		return queue != nil && queue.isPaused()
+	}
+	w := newWatchdog(timeout, paused, r.reportWedged)
+	w.start()
+	return w
+}
+
+// reportWedged prints the watchdog's report and dumps the goroutine stacks
+func (r *RegressionTestRunner) reportWedged(report string) {
+	fmt.Fprintf(os.Stderr, "\n⚠️  Watchdog: %s", report)
+
+	path := filepath.Join(r.logDir, watchdogStacksFile)
+	if err := writeGoroutineStacks(path); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to write goroutine stacks: %v\n", err)
+		writeStacks(os.Stderr)
+	} else {
+		fmt.Fprintf(os.Stderr, "Goroutine stacks written to %s\n", path)
+	}
+
+	if r.onWedged != nil {
+		r.onWedged()
+	}
+}
+
+// writeGoroutineStacks writes the stacks of all goroutines to path
+func writeGoroutineStacks(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := writeStacks(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// writeStacks writes the stacks of all goroutines to w, in the format of an
+// unrecovered panic
+func writeStacks(w io.Writer) error {
+	return pprof.Lookup("goroutine").WriteTo(w, 2)
+}
@@ -0,0 +1,110 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWatchdogCheck(t *testing.T) {
+	paused := false
+	w := newWatchdog(time.Hour, func() bool { return paused }, nil)
+	start := w.last
+
+	if _, wedged := w.check(start.Add(30 * time.Minute)); wedged {
+		t.Error("Expected no report before the timeout")
+	}
+
+	w.started("curl")
+	w.running["curl"] = start
+	w.last = start
+	report, wedged := w.check(start.Add(2 * time.Hour))
+	if !wedged {
+		t.Fatal("Expected a report after the timeout")
+	}
+	if !strings.Contains(report, "  - curl (for 2h0m0s)") {
+		t.Errorf("Expected the running test in the report, got %q", report)
+	}
+	if _, wedged := w.check(start.Add(2*time.Hour + time.Minute)); wedged {
+		t.Error("Expected no second report before another timeout")
+	}
+
+	paused = true
+	if _, wedged := w.check(start.Add(5 * time.Hour)); wedged {
+		t.Error("Expected no report while paused")
+	}
+	paused = false
+	if _, wedged := w.check(start.Add(5*time.Hour + time.Minute)); wedged {
+		t.Error("Expected time paused not to count")
+	}
+
+	w.finished("curl")
+	report, wedged = w.check(start.Add(7 * time.Hour))
+	if !wedged || !strings.Contains(report, "No test is running") {
+		t.Errorf("Expected a report of a stuck scheduler, got %q", report)
+	}
+}
+
+func TestWatchdogPeriod(t *testing.T) {
+	runner := NewRegressionTestRunner("openssl", "https://example.com/repo", t.TempDir(), "wolfi", 2, false, 30*time.Minute, false)
+	runner.SetPackageOptions(map[string]PackageOptions{"rust": {Timeout: 2 * time.Hour}})
+
+	tests := []struct {
+		timeout  time.Duration
+		expected time.Duration
+	}{
+		{0, 2*time.Hour + watchdogGrace},
+		{time.Hour, time.Hour},
+		{-1, 0},
+	}
+
+	for _, tt := range tests {
+		runner.SetWatchdog(tt.timeout, nil)
+		if got := runner.watchdogPeriod(); got != tt.expected {
+			t.Errorf("Expected %v for --watchdog-timeout %v, got %v", tt.expected, tt.timeout, got)
+		}
+	}
+}
+
+func TestRunnerWatchdog(t *testing.T) {
+	repoPath := t.TempDir()
+	if err := os.WriteFile(filepath.Join(repoPath, "curl.yaml"), []byte("package:\n  name: curl\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	runner := NewRegressionTestRunner("openssl", "https://example.com/repo", repoPath, "wolfi", 1, false, time.Minute, false)
+	logDir := t.TempDir()
+	runner.setLogDir(logDir)
+
+	// The test only finishes once the watchdog found the run wedged
+	release := make(chan struct{})
+	var once sync.Once
+	runner.SetWatchdog(50*time.Millisecond, func() { once.Do(func() { close(release) }) })
+	runner.SetExecutor(TestExecutorFunc(func(ctx context.Context, pkg string, opts ExecuteOptions) TestResult {
+		select {
+		case <-release:
+		case <-time.After(10 * time.Second):
+			t.Error("Expected the watchdog to report the wedged test")
+		}
+		return newTestResult(pkg, opts.WithRepo, time.Now(), "", nil)
+	}))
+
+	if err := runner.RunFromPackageList([]string{"curl"}); err != nil {
+		t.Fatal(err)
+	}
+
+	stacks, err := os.ReadFile(filepath.Join(logDir, watchdogStacksFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(stacks), "goroutine") {
+		t.Errorf("Expected goroutine stacks, got %q", stacks)
+	}
+}
@@ -0,0 +1,49 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+// Worktree is a temporary git worktree of a package repository
+type Worktree struct {
+	Path string
+	repo string
+}
+
+// NewWorktree checks ref of the git repository at repoPath out into a new
+// temporary worktree with a detached HEAD
+func NewWorktree(repoPath, ref string) (*Worktree, error) {
+	dir, err := os.MkdirTemp("", "apkregress-worktree-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create worktree directory: %w", err)
+	}
+
+	cmd := exec.Command("git", "worktree", "add", "--detach", dir, ref)
+	cmd.Dir = repoPath
+	if _, err := cmd.Output(); err != nil {
+		os.RemoveAll(dir)
+		return nil, fmt.Errorf("failed to check out %s of %s: %w", ref, repoPath, commandError(err))
+	}
+	return &Worktree{Path: dir, repo: repoPath}, nil
+}
+
+// Remove deletes the worktree and unregisters it from the repository
+func (w *Worktree) Remove() error {
+	cmd := exec.Command("git", "worktree", "remove", "--force", w.Path)
+	cmd.Dir = w.repo
+	if _, err := cmd.Output(); err != nil {
+		// Fall back to deleting the directory and pruning the registration
+		os.RemoveAll(w.Path)
+		prune := exec.Command("git", "worktree", "prune")
+		prune.Dir = w.repo
+		if _, pruneErr := prune.Output(); pruneErr != nil {
+			return fmt.Errorf("failed to remove worktree %s: %w", w.Path, commandError(err))
+		}
+	}
+	return nil
+}
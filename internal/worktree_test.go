@@ -0,0 +1,71 @@
+// SPDX-License-Identifier: Apache-2.0
+// SPDX-FileCopyrightText: Copyright 2025 Chainguard, Inc.
+
+package internal
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWorktree(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+
+	repo := t.TempDir()
+	git := func(args ...string) string {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		cmd.Dir = repo
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v failed: %v\n%s", args, err, output)
+		}
+		return strings.TrimSpace(string(output))
+	}
+	write := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(repo, "curl.yaml"), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	git("init", "-q")
+	write("package:\n  name: curl\n  version: 8.9.0\n")
+	git("add", ".")
+	git("commit", "-q", "-m", "initial")
+	git("tag", "v1")
+	write("package:\n  name: curl\n  version: 8.10.0\n")
+	git("commit", "-q", "-am", "update")
+
+	worktree, err := NewWorktree(repo, "v1")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(worktree.Path, "curl.yaml"))
+	if err != nil || !strings.Contains(string(data), "8.9.0") {
+		t.Errorf("Expected curl.yaml at v1 in the worktree, got %q (%v)", data, err)
+	}
+	if commit := GitCommit(worktree.Path); commit != git("rev-parse", "v1") {
+		t.Errorf("Expected the worktree at v1, got %s", commit)
+	}
+
+	if err := worktree.Remove(); err != nil {
+		t.Fatalf("Failed to remove worktree: %v", err)
+	}
+	if _, err := os.Stat(worktree.Path); !os.IsNotExist(err) {
+		t.Errorf("Expected %s to be removed", worktree.Path)
+	}
+	if list := git("worktree", "list"); strings.Contains(list, worktree.Path) {
+		t.Errorf("Expected the worktree to be unregistered, got:\n%s", list)
+	}
+
+	if _, err := NewWorktree(repo, "no-such-ref"); err == nil {
+		t.Error("Expected an error for an unknown ref")
+	}
+}